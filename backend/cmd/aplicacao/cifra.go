@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/cripto"
+	"github.com/tassyosilva/consultapix/internal/database"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+// carenciaChaveDados é o tempo que uma chave de dados desativada é mantida,
+// para que as instâncias da API recarreguem as chaves antes de ela sumir
+const carenciaChaveDados = 5 * time.Minute
+
+// executarCifra trata o subcomando "cifra", que mantém as chaves de dados e
+// recifra as colunas sensíveis das consultas PIX e CCS
+func executarCifra(cfg *config.Config, args []string) error {
+	uso := errors.New("uso: cifra [status | reembrulhar | rotacionar | recifrar | decifrar]")
+	if len(args) != 1 {
+		return uso
+	}
+	if len(cfg.ChaveDados) == 0 {
+		return errors.New("defina DATA_ENCRYPTION_KEY com a chave mestra")
+	}
+	chaveiro, err := cripto.NovoChaveiro(cfg.ChaveDados, cfg.ChavesDadosAnteriores...)
+	if err != nil {
+		return err
+	}
+
+	if err := database.Conectar(cfg); err != nil {
+		return err
+	}
+	defer database.DB.Close()
+
+	repo := repository.NewChaveDadosRepository()
+	if args[0] == "status" {
+		return mostrarStatusCifra(repo, chaveiro)
+	}
+
+	// Depois de uma troca da chave mestra, as chaves de dados ainda estão
+	// embrulhadas pela anterior; elas são reembrulhadas antes de tudo
+	n, err := repo.Reembrulhar(chaveiro)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		fmt.Printf("%d chave(s) de dados reembrulhada(s) com a chave mestra %s\n", n, chaveiro.Atual())
+	}
+	if err := repository.AtivarCifraDados(chaveiro); err != nil {
+		return err
+	}
+
+	progresso := func(tabela string, linhas int) {
+		fmt.Printf("%-24s %d linha(s)\n", tabela, linhas)
+	}
+
+	switch args[0] {
+	case "reembrulhar":
+		return nil
+
+	case "rotacionar":
+		if err := repo.Rotacionar(chaveiro); err != nil {
+			return err
+		}
+		if err := repository.RecarregarCifraDados(); err != nil {
+			return err
+		}
+		if err := repo.Recifrar(false, progresso); err != nil {
+			return err
+		}
+		fmt.Printf("Execute \"cifra recifrar\" depois de %s para alcançar as linhas gravadas pelas instâncias em execução e remover a chave anterior\n", carenciaChaveDados)
+		return nil
+
+	case "recifrar":
+		if err := repo.Recifrar(false, progresso); err != nil {
+			return err
+		}
+		removidas, err := repo.RemoverSemUso(carenciaChaveDados)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d chave(s) de dados sem uso removida(s)\n", removidas)
+		return nil
+
+	case "decifrar":
+		return repo.Recifrar(true, progresso)
+
+	default:
+		return uso
+	}
+}
+
+func mostrarStatusCifra(repo *repository.ChaveDadosRepository, chaveiro *cripto.Chaveiro) error {
+	chaves, err := repo.Listar()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Chave mestra atual: %s\n\n", chaveiro.Atual())
+	for _, c := range chaves {
+		situacao := "ativa"
+		if !c.Ativa {
+			situacao = "inativa"
+		}
+		fmt.Printf("%4d %-7s mestra %s %-8s criada em %s %d linha(s)\n",
+			c.ID, c.Finalidade, c.ChaveMestra, situacao, c.CriadaEm.Format("02/01/2006 15:04:05"), c.Linhas)
+	}
+
+	emClaro, err := repo.LinhasEmClaro()
+	if err != nil {
+		return err
+	}
+	tabelas := make([]string, 0, len(emClaro))
+	for tabela := range emClaro {
+		tabelas = append(tabelas, tabela)
+	}
+	sort.Strings(tabelas)
+	fmt.Println("\nLinhas em claro:")
+	for _, tabela := range tabelas {
+		fmt.Printf("%-24s %d\n", tabela, emClaro[tabela])
+	}
+	return nil
+}
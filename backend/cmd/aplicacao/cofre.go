@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+)
+
+// executarCofre trata o subcomando "cofre", que mantém o arquivo local de
+// segredos cifrados indicado em CONSULTAPIX_VAULT_FILE
+func executarCofre(args []string) error {
+	uso := errors.New("uso: cofre [listar | definir NOME | remover NOME]")
+	if len(args) == 0 {
+		return uso
+	}
+
+	caminho, senha, err := config.LocalizarCofre()
+	if err != nil {
+		return err
+	}
+	if caminho == "" {
+		return errors.New("defina CONSULTAPIX_VAULT_FILE com o caminho do cofre")
+	}
+
+	segredos, err := config.AbrirCofre(caminho, senha)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "listar":
+		nomes := make([]string, 0, len(segredos))
+		for nome := range segredos {
+			nomes = append(nomes, nome)
+		}
+		sort.Strings(nomes)
+		for _, nome := range nomes {
+			fmt.Println(nome)
+		}
+		return nil
+
+	case "definir":
+		if len(args) != 2 {
+			return uso
+		}
+		// O valor vem da entrada padrão para não ficar no histórico do shell
+		fmt.Fprintf(os.Stderr, "Valor de %s: ", args[1])
+		valor, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && valor == "" {
+			return err
+		}
+		valor = strings.TrimRight(valor, "\r\n")
+		if valor == "" {
+			return errors.New("valor vazio")
+		}
+		segredos[args[1]] = valor
+
+	case "remover":
+		if len(args) != 2 {
+			return uso
+		}
+		delete(segredos, args[1])
+
+	default:
+		return uso
+	}
+
+	if err := config.SalvarCofre(caminho, senha, segredos); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Cofre atualizado")
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database"
+)
+
+// executarMigrate trata o subcomando "migrate" da aplicação
+func executarMigrate(cfg *config.Config, args []string) error {
+	acao := "up"
+	if len(args) > 0 {
+		acao = args[0]
+	}
+
+	if err := database.Conectar(cfg); err != nil {
+		return err
+	}
+	defer database.DB.Close()
+
+	switch acao {
+	case "up":
+		aplicadas, err := database.Migrar(database.DB)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d migração(ões) aplicada(s)\n", aplicadas)
+
+	case "down":
+		passos := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("número de passos inválido: %s", args[1])
+			}
+			passos = n
+		}
+		revertidas, err := database.Reverter(database.DB, passos)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d migração(ões) revertida(s)\n", revertidas)
+
+	case "status":
+		status, err := database.StatusMigracoes(database.DB)
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			situacao := "pendente"
+			if s.Aplicada {
+				situacao = "aplicada em " + s.AplicadaEm.Format("02/01/2006 15:04:05")
+			}
+			fmt.Printf("%04d_%-40s %s\n", s.Versao, s.Nome, situacao)
+		}
+
+	default:
+		return errors.New("uso: migrate [up | down [passos] | status]")
+	}
+
+	return nil
+}
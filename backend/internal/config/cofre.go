@@ -0,0 +1,149 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Parâmetros do scrypt usados para derivar a chave do cofre a partir da senha
+const (
+	versaoCofre  = 1
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	tamanhoChave = 32
+	tamanhoSal   = 16
+)
+
+// ErrSenhaCofreInvalida indica que o cofre não pôde ser decifrado com a senha informada
+var ErrSenhaCofreInvalida = errors.New("senha do cofre inválida ou arquivo corrompido")
+
+// arquivoCofre é o formato em disco do cofre: um mapa JSON de segredos cifrado
+// com AES-256-GCM, com chave derivada da senha via scrypt
+type arquivoCofre struct {
+	Versao int    `json:"versao"`
+	Sal    string `json:"sal"`
+	Nonce  string `json:"nonce"`
+	Dados  string `json:"dados"`
+}
+
+func cifradorCofre(senha string, sal []byte) (cipher.AEAD, error) {
+	chave, err := scrypt.Key([]byte(senha), sal, scryptN, scryptR, scryptP, tamanhoChave)
+	if err != nil {
+		return nil, err
+	}
+	bloco, err := aes.NewCipher(chave)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(bloco)
+}
+
+// AbrirCofre decifra o arquivo do cofre e devolve os segredos armazenados.
+// Um arquivo inexistente é tratado como cofre vazio.
+func AbrirCofre(caminho, senha string) (map[string]string, error) {
+	conteudo, err := os.ReadFile(caminho)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var arquivo arquivoCofre
+	if err := json.Unmarshal(conteudo, &arquivo); err != nil {
+		return nil, fmt.Errorf("cofre %s malformado: %w", caminho, err)
+	}
+	if arquivo.Versao != versaoCofre {
+		return nil, fmt.Errorf("versão do cofre não suportada: %d", arquivo.Versao)
+	}
+
+	sal, err1 := base64.StdEncoding.DecodeString(arquivo.Sal)
+	nonce, err2 := base64.StdEncoding.DecodeString(arquivo.Nonce)
+	dados, err3 := base64.StdEncoding.DecodeString(arquivo.Dados)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil, fmt.Errorf("cofre %s malformado", caminho)
+	}
+
+	aead, err := cifradorCofre(senha, sal)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("cofre %s malformado", caminho)
+	}
+	claro, err := aead.Open(nil, nonce, dados, nil)
+	if err != nil {
+		return nil, ErrSenhaCofreInvalida
+	}
+
+	segredos := map[string]string{}
+	if err := json.Unmarshal(claro, &segredos); err != nil {
+		return nil, fmt.Errorf("cofre %s malformado: %w", caminho, err)
+	}
+	return segredos, nil
+}
+
+// SalvarCofre cifra os segredos com a senha e grava o cofre com permissão 0600.
+// A gravação usa um arquivo temporário e rename para nunca deixar o cofre pela metade.
+func SalvarCofre(caminho, senha string, segredos map[string]string) error {
+	if senha == "" {
+		return errors.New("a senha do cofre não pode ser vazia")
+	}
+
+	claro, err := json.Marshal(segredos)
+	if err != nil {
+		return err
+	}
+
+	sal := make([]byte, tamanhoSal)
+	if _, err := rand.Read(sal); err != nil {
+		return err
+	}
+	aead, err := cifradorCofre(senha, sal)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	conteudo, err := json.MarshalIndent(arquivoCofre{
+		Versao: versaoCofre,
+		Sal:    base64.StdEncoding.EncodeToString(sal),
+		Nonce:  base64.StdEncoding.EncodeToString(nonce),
+		Dados:  base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, claro, nil)),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temporario, err := os.CreateTemp(filepath.Dir(caminho), ".cofre-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporario.Name())
+
+	if err := temporario.Chmod(0600); err != nil {
+		temporario.Close()
+		return err
+	}
+	if _, err := temporario.Write(conteudo); err != nil {
+		temporario.Close()
+		return err
+	}
+	if err := temporario.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporario.Name(), caminho)
+}
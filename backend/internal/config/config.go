@@ -1,28 +1,422 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/cripto"
 )
 
+// jwtSecretDesenvolvimento só é aceito com APP_ENV=development. Era o valor
+// padrão de versões anteriores e por isso é recusado em produção.
+const jwtSecretDesenvolvimento = "zH4NRP1HMALxxCFnRZABFA7GOJtzU_gIj02alfL1lvI"
+
+// senhaAdminDesenvolvimento é a senha inicial do administrador padrão sem
+// ADMIN_INITIAL_PASSWORD, aceita só com APP_ENV=development
+const senhaAdminDesenvolvimento = "admin"
+
+// tamanhoMinimoJWTSecret é o tamanho mínimo aceito para a chave de assinatura dos tokens
+const tamanhoMinimoJWTSecret = 32
+
+// valoresDeExemplo são placeholders distribuídos no código e na documentação
+var valoresDeExemplo = map[string]bool{
+	jwtSecretDesenvolvimento:                           true,
+	"USUÁRIO FORNECIDO PELO BACEN. EX. eju**.s-apiccs": true,
+	"SENHA DE ACESSO":                                  true,
+	"seu_usuario_bacen":                                true,
+	"sua_senha_bacen":                                  true,
+	"troque-esta-chave":                                true,
+}
+
+// CredenciaisBacen são o usuário e a senha de acesso às APIs do BACEN
+type CredenciaisBacen struct {
+	Usuario string
+	Senha   string
+}
+
 // Config armazena todas as configurações da aplicação
 type Config struct {
-	DatabaseURL       string
-	BacenUsername     string
-	BacenPassword     string
-	JWTSecret         string
-	ServerPort        string
-	TokenExpiryHours  int
+	DatabaseURL string
+	JWTSecret   string
+	ServerPort  string
+	Ambiente    string
+	// DuracaoAccessToken é a validade do JWT de acesso (ACCESS_TOKEN_TTL) e
+	// DuracaoRefreshToken a do refresh token que o renova (REFRESH_TOKEN_TTL)
+	DuracaoAccessToken  time.Duration
+	DuracaoRefreshToken time.Duration
+	// InatividadeSessao encerra a sessão sem requisições por esse tempo
+	// (SESSION_IDLE_TIMEOUT, padrão 30m); zero desativa
+	InatividadeSessao time.Duration
+	// PermitirTokenNaURL aceita o token no parâmetro ?token= (AUTH_ALLOW_QUERY_TOKEN).
+	// Obsoleto: o token vaza em logs, histórico e Referer; use o cabeçalho
+	// Authorization ou o cookie de sessão.
+	PermitirTokenNaURL bool
+	// CookieSeguro marca os cookies de sessão como Secure (AUTH_COOKIE_SECURE,
+	// padrão true fora do modo de desenvolvimento)
+	CookieSeguro bool
+	// OrigensCORS são as origens aceitas pelo CORS (CORS_ALLOWED_ORIGINS,
+	// separadas por vírgula). Com "*" os cookies não são enviados entre origens.
+	OrigensCORS []string
+	// ChaveCredenciaisBacen cifra as senhas dos perfis de credencial por lotação
+	// (BACEN_CREDENTIALS_KEY, 32 bytes em base64). Sem ela só a credencial
+	// padrão pode ser usada.
+	ChaveCredenciaisBacen []byte
+	// ChaveDoisFatores cifra os segredos TOTP dos usuários (MFA_ENCRYPTION_KEY,
+	// 32 bytes em base64). Sem ela não é possível cadastrar o segundo fator.
+	ChaveDoisFatores []byte
+	// ChaveDados é a chave mestra que embrulha as chaves de dados das colunas
+	// sensíveis das consultas PIX e CCS (DATA_ENCRYPTION_KEY, 32 bytes em
+	// base64). Sem ela esses dados são gravados em claro.
+	ChaveDados []byte
+	// ChavesDadosAnteriores são chaves mestras substituídas, aceitas apenas
+	// para desembrulhar até a rotação (DATA_ENCRYPTION_PREVIOUS_KEYS, separadas
+	// por vírgula)
+	ChavesDadosAnteriores [][]byte
+	// ProvedoresAutenticacao são os provedores de identidade consultados no
+	// login, nesta ordem (AUTH_PROVIDERS, padrão local). O provedor oidc não
+	// recebe senha: habilita o login único pelo navegador.
+	ProvedoresAutenticacao []string
+	LDAP                   ConfigLDAP
+	OIDC                   ConfigOIDC
+	// TaxaBacenPorSegundo e RajadaBacen configuram o limitador de chamadas ao
+	// BACEN (BACEN_RATE_PER_SECOND e BACEN_RATE_BURST). Taxa zero desativa.
+	TaxaBacenPorSegundo float64
+	RajadaBacen         int
+	// JanelaReaproveitamento é o período em que uma consulta PIX repetida no
+	// mesmo caso devolve o resultado gravado (BACEN_REUSE_WINDOW, ex. "30m").
+	// Zero desativa o reaproveitamento.
+	JanelaReaproveitamento time.Duration
+	// PoliticaSenha e BloqueioLogin protegem as senhas locais (ver senha.go)
+	PoliticaSenha PoliticaSenha
+	BloqueioLogin BloqueioLogin
+	// PrazoRedefinicaoSenha é a validade do link de redefinição de senha
+	// (PASSWORD_RESET_TTL, padrão 30m)
+	PrazoRedefinicaoSenha time.Duration
+	// SenhaAdminInicial é a senha do administrador padrão, criado quando não
+	// há nenhum (ADMIN_INITIAL_PASSWORD). Ela precisa ser trocada no primeiro login.
+	SenhaAdminInicial string
+	// ProxiesConfiaveis são as redes cujo cabeçalho X-Real-IP é aceito como
+	// IP do cliente (TRUSTED_PROXIES, CIDRs separados por vírgula)
+	ProxiesConfiaveis []*net.IPNet
+	// URLPublica é o endereço da aplicação usado nos links enviados por
+	// e-mail (APP_URL, padrão http://localhost)
+	URLPublica string
+	// PrazoCadastro é o tempo para confirmar o e-mail e obter a aprovação de
+	// um autocadastro (REGISTRATION_TTL, padrão 72h); depois ele é removido
+	PrazoCadastro time.Duration
+	// IntervaloMonitoramento é o intervalo do agendador de monitoramentos
+	// (WATCHLIST_INTERVAL, padrão 1m). Zero desativa o agendador.
+	IntervaloMonitoramento time.Duration
+
+	// Canal de e-mail das notificações (SMTP_HOST vazio desativa). Sem
+	// SMTP_USERNAME o envio é feito sem autenticação, como num servidor local de testes.
+	SMTPHost      string
+	SMTPPort      string
+	SMTPUsuario   string
+	SMTPSenha     string
+	SMTPRemetente string
+	// Webhook das notificações (NOTIFY_WEBHOOK_URL vazio desativa), assinado
+	// com HMAC-SHA256 usando NOTIFY_WEBHOOK_SECRET
+	WebhookNotificacaoURL     string
+	WebhookNotificacaoSegredo string
+
+	segredos *fonteSegredos
+	bacen    atomic.Pointer[CredenciaisBacen]
+}
+
+// NewConfig carrega a configuração a partir de arquivos de secrets, variáveis
+// de ambiente e do cofre local. A validação dos segredos fica em Validar, para
+// que subcomandos que só usam o banco não exijam as demais credenciais.
+func NewConfig() (*Config, error) {
+	segredos, err := novaFonteSegredos()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{
+		ServerPort: getEnvOrDefault("PORT", "8080"),
+		Ambiente:   getEnvOrDefault("APP_ENV", "production"),
+		segredos:   segredos,
+	}
+
+	if cfg.DatabaseURL, err = segredos.ler("DATABASE_URL"); err != nil {
+		return nil, err
+	}
+	if cfg.JWTSecret, err = segredos.ler("JWT_SECRET"); err != nil {
+		return nil, err
+	}
+	if cfg.JWTSecret == "" && cfg.Desenvolvimento() {
+		log.Println("AVISO: JWT_SECRET não definido, usando chave de desenvolvimento")
+		cfg.JWTSecret = jwtSecretDesenvolvimento
+	}
+
+	chave, err := segredos.ler("BACEN_CREDENTIALS_KEY")
+	if err != nil {
+		return nil, err
+	}
+	if chave != "" {
+		if cfg.ChaveCredenciaisBacen, err = cripto.DecodificarChave(chave); err != nil {
+			return nil, fmt.Errorf("BACEN_CREDENTIALS_KEY inválida: %w", err)
+		}
+	}
+
+	chaveDoisFatores, err := segredos.ler("MFA_ENCRYPTION_KEY")
+	if err != nil {
+		return nil, err
+	}
+	if chaveDoisFatores != "" {
+		if cfg.ChaveDoisFatores, err = cripto.DecodificarChave(chaveDoisFatores); err != nil {
+			return nil, fmt.Errorf("MFA_ENCRYPTION_KEY inválida: %w", err)
+		}
+	}
+
+	chaveDados, err := segredos.ler("DATA_ENCRYPTION_KEY")
+	if err != nil {
+		return nil, err
+	}
+	if chaveDados != "" {
+		if cfg.ChaveDados, err = cripto.DecodificarChave(chaveDados); err != nil {
+			return nil, fmt.Errorf("DATA_ENCRYPTION_KEY inválida: %w", err)
+		}
+	}
+	anteriores, err := segredos.ler("DATA_ENCRYPTION_PREVIOUS_KEYS")
+	if err != nil {
+		return nil, err
+	}
+	for _, valor := range strings.Split(anteriores, ",") {
+		if valor = strings.TrimSpace(valor); valor == "" {
+			continue
+		}
+		anterior, err := cripto.DecodificarChave(valor)
+		if err != nil {
+			return nil, fmt.Errorf("DATA_ENCRYPTION_PREVIOUS_KEYS inválida: %w", err)
+		}
+		cfg.ChavesDadosAnteriores = append(cfg.ChavesDadosAnteriores, anterior)
+	}
+
+	if cfg.TaxaBacenPorSegundo, err = strconv.ParseFloat(getEnvOrDefault("BACEN_RATE_PER_SECOND", "5"), 64); err != nil {
+		return nil, fmt.Errorf("BACEN_RATE_PER_SECOND inválido: %w", err)
+	}
+	if cfg.RajadaBacen, err = strconv.Atoi(getEnvOrDefault("BACEN_RATE_BURST", "10")); err != nil {
+		return nil, fmt.Errorf("BACEN_RATE_BURST inválido: %w", err)
+	}
+
+	if cfg.JanelaReaproveitamento, err = time.ParseDuration(getEnvOrDefault("BACEN_REUSE_WINDOW", "0s")); err != nil {
+		return nil, fmt.Errorf("BACEN_REUSE_WINDOW inválido: %w", err)
+	}
+
+	if cfg.DuracaoAccessToken, err = time.ParseDuration(getEnvOrDefault("ACCESS_TOKEN_TTL", "15m")); err != nil {
+		return nil, fmt.Errorf("ACCESS_TOKEN_TTL inválido: %w", err)
+	}
+	if cfg.DuracaoRefreshToken, err = time.ParseDuration(getEnvOrDefault("REFRESH_TOKEN_TTL", "720h")); err != nil {
+		return nil, fmt.Errorf("REFRESH_TOKEN_TTL inválido: %w", err)
+	}
+	if cfg.InatividadeSessao, err = time.ParseDuration(getEnvOrDefault("SESSION_IDLE_TIMEOUT", "30m")); err != nil {
+		return nil, fmt.Errorf("SESSION_IDLE_TIMEOUT inválido: %w", err)
+	}
+
+	if cfg.PermitirTokenNaURL, err = strconv.ParseBool(getEnvOrDefault("AUTH_ALLOW_QUERY_TOKEN", "false")); err != nil {
+		return nil, fmt.Errorf("AUTH_ALLOW_QUERY_TOKEN inválido: %w", err)
+	}
+	if cfg.CookieSeguro, err = strconv.ParseBool(getEnvOrDefault("AUTH_COOKIE_SECURE", strconv.FormatBool(!cfg.Desenvolvimento()))); err != nil {
+		return nil, fmt.Errorf("AUTH_COOKIE_SECURE inválido: %w", err)
+	}
+	for _, origem := range strings.Split(getEnvOrDefault("CORS_ALLOWED_ORIGINS", "*"), ",") {
+		if origem = strings.TrimSpace(origem); origem != "" {
+			cfg.OrigensCORS = append(cfg.OrigensCORS, origem)
+		}
+	}
+
+	cfg.URLPublica = strings.TrimSuffix(getEnvOrDefault("APP_URL", "http://localhost"), "/")
+	if cfg.PrazoCadastro, err = time.ParseDuration(getEnvOrDefault("REGISTRATION_TTL", "72h")); err != nil {
+		return nil, fmt.Errorf("REGISTRATION_TTL inválido: %w", err)
+	}
+
+	if cfg.IntervaloMonitoramento, err = time.ParseDuration(getEnvOrDefault("WATCHLIST_INTERVAL", "1m")); err != nil {
+		return nil, fmt.Errorf("WATCHLIST_INTERVAL inválido: %w", err)
+	}
+
+	cfg.SMTPHost = os.Getenv("SMTP_HOST")
+	cfg.SMTPPort = getEnvOrDefault("SMTP_PORT", "25")
+	cfg.SMTPUsuario = os.Getenv("SMTP_USERNAME")
+	cfg.SMTPRemetente = getEnvOrDefault("SMTP_FROM", "consultapix@localhost")
+	if cfg.SMTPSenha, err = segredos.ler("SMTP_PASSWORD"); err != nil {
+		return nil, err
+	}
+	cfg.WebhookNotificacaoURL = os.Getenv("NOTIFY_WEBHOOK_URL")
+	if cfg.WebhookNotificacaoSegredo, err = segredos.ler("NOTIFY_WEBHOOK_SECRET"); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.lerPoliticaSenha(); err != nil {
+		return nil, err
+	}
+	if cfg.SenhaAdminInicial, err = segredos.ler("ADMIN_INITIAL_PASSWORD"); err != nil {
+		return nil, err
+	}
+	if cfg.SenhaAdminInicial == "" && cfg.Desenvolvimento() {
+		cfg.SenhaAdminInicial = senhaAdminDesenvolvimento
+	}
+
+	if err := cfg.lerProvedoresAutenticacao(); err != nil {
+		return nil, err
+	}
+
+	credenciais, err := cfg.lerCredenciaisBacen()
+	if err != nil {
+		return nil, err
+	}
+	cfg.bacen.Store(credenciais)
+
+	return cfg, nil
+}
+
+// Desenvolvimento indica se a aplicação roda em modo de desenvolvimento, no
+// qual segredos vazios ou de exemplo geram apenas avisos
+func (c *Config) Desenvolvimento() bool {
+	switch strings.ToLower(c.Ambiente) {
+	case "dev", "development", "desenvolvimento":
+		return true
+	}
+	return false
+}
+
+// Validar recusa segredos vazios ou de exemplo fora do modo de desenvolvimento
+func (c *Config) Validar() error {
+	var problemas []string
+	if c.DatabaseURL == "" {
+		problemas = append(problemas, "DATABASE_URL não definido")
+	}
+	problemas = append(problemas, validarJWTSecret(c.JWTSecret)...)
+	problemas = append(problemas, validarCredenciaisBacen(c.CredenciaisBacen())...)
+	problemas = append(problemas, c.validarSenhaAdminInicial()...)
+	if len(c.ChaveDados) == 0 {
+		problemas = append(problemas, "DATA_ENCRYPTION_KEY não definida, dados das consultas gravados em claro")
+	}
+	problemas = append(problemas, c.validarLDAP()...)
+	problemas = append(problemas, c.validarOIDC()...)
+	if c.WebhookNotificacaoURL != "" && len(c.WebhookNotificacaoSegredo) < tamanhoMinimoJWTSecret {
+		problemas = append(problemas, fmt.Sprintf("NOTIFY_WEBHOOK_SECRET deve ter ao menos %d caracteres", tamanhoMinimoJWTSecret))
+	}
+
+	if len(problemas) == 0 {
+		return nil
+	}
+	if c.Desenvolvimento() {
+		for _, p := range problemas {
+			log.Printf("AVISO: %s (aceito apenas em desenvolvimento)", p)
+		}
+		return nil
+	}
+	return fmt.Errorf("configuração insegura: %s", strings.Join(problemas, "; "))
+}
+
+func validarJWTSecret(segredo string) []string {
+	switch {
+	case segredo == "":
+		return []string{"JWT_SECRET não definido"}
+	case valoresDeExemplo[segredo]:
+		return []string{"JWT_SECRET usa o valor padrão de exemplo"}
+	case len(segredo) < tamanhoMinimoJWTSecret:
+		return []string{fmt.Sprintf("JWT_SECRET deve ter ao menos %d caracteres", tamanhoMinimoJWTSecret)}
+	}
+	return nil
+}
+
+// validarSenhaAdminInicial recusa a senha padrão do administrador. Vazia, ela
+// só é exigida na criação do administrador padrão, quando não há nenhum.
+func (c *Config) validarSenhaAdminInicial() []string {
+	switch {
+	case c.SenhaAdminInicial == "":
+		return nil
+	case c.SenhaAdminInicial == senhaAdminDesenvolvimento:
+		return []string{"ADMIN_INITIAL_PASSWORD usa a senha padrão admin"}
+	case len([]rune(c.SenhaAdminInicial)) < c.PoliticaSenha.TamanhoMinimo:
+		return []string{fmt.Sprintf("ADMIN_INITIAL_PASSWORD deve ter ao menos %d caracteres", c.PoliticaSenha.TamanhoMinimo)}
+	}
+	return nil
+}
+
+func validarCredenciaisBacen(c CredenciaisBacen) []string {
+	var problemas []string
+	if c.Usuario == "" || valoresDeExemplo[c.Usuario] {
+		problemas = append(problemas, "BACEN_USERNAME vazio ou de exemplo")
+	}
+	if c.Senha == "" || valoresDeExemplo[c.Senha] {
+		problemas = append(problemas, "BACEN_PASSWORD vazio ou de exemplo")
+	}
+	return problemas
+}
+
+// lerCredenciaisBacen lê as credenciais do BACEN. usernameBC e passwordBC são
+// os nomes usados por versões anteriores.
+func (c *Config) lerCredenciaisBacen() (*CredenciaisBacen, error) {
+	usuario, err := c.segredos.ler("BACEN_USERNAME", "usernameBC")
+	if err != nil {
+		return nil, err
+	}
+	senha, err := c.segredos.ler("BACEN_PASSWORD", "passwordBC")
+	if err != nil {
+		return nil, err
+	}
+	return &CredenciaisBacen{Usuario: usuario, Senha: senha}, nil
 }
 
-// NewConfig cria uma nova instância de configuração
-func NewConfig() *Config {
-	return &Config{
-		DatabaseURL:       os.Getenv("DATABASE_URL"),
-		BacenUsername:     os.Getenv("usernameBC"),
-		BacenPassword:     os.Getenv("passwordBC"),
-		JWTSecret:         getEnvOrDefault("JWT_SECRET", "zH4NRP1HMALxxCFnRZABFA7GOJtzU_gIj02alfL1lvI"),
-		ServerPort:        getEnvOrDefault("PORT", "8080"),
-		TokenExpiryHours:  24, // Token válido por 24 horas
+// CredenciaisBacen retorna as credenciais do BACEN em vigor
+func (c *Config) CredenciaisBacen() CredenciaisBacen {
+	if credenciais := c.bacen.Load(); credenciais != nil {
+		return *credenciais
+	}
+	return CredenciaisBacen{}
+}
+
+// RecarregarCredenciaisBacen relê as credenciais do BACEN e passa a usá-las se
+// forem válidas. Retorna true quando houve troca.
+func (c *Config) RecarregarCredenciaisBacen() (bool, error) {
+	novas, err := c.lerCredenciaisBacen()
+	if err != nil {
+		return false, err
+	}
+	if *novas == c.CredenciaisBacen() {
+		return false, nil
+	}
+	if problemas := validarCredenciaisBacen(*novas); len(problemas) > 0 && !c.Desenvolvimento() {
+		return false, errors.New(strings.Join(problemas, "; "))
+	}
+
+	c.bacen.Store(novas)
+	return true, nil
+}
+
+// MonitorarCredenciaisBacen recarrega as credenciais do BACEN periodicamente e
+// sempre que algo for recebido em gatilho (por exemplo, SIGHUP). Variáveis de
+// ambiente não mudam com o processo em execução; a rotação sem reinício vale
+// para arquivos de secrets e para o cofre.
+func (c *Config) MonitorarCredenciaisBacen(intervalo time.Duration, gatilho <-chan os.Signal) {
+	ticker := time.NewTicker(intervalo)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-gatilho:
+		}
+
+		trocou, err := c.RecarregarCredenciaisBacen()
+		if err != nil {
+			log.Printf("Credenciais do BACEN mantidas: %v", err)
+			continue
+		}
+		if trocou {
+			log.Println("Credenciais do BACEN atualizadas")
+		}
 	}
 }
 
@@ -33,4 +427,4 @@ func getEnvOrDefault(key, defaultValue string) string {
 		return defaultValue
 	}
 	return value
-}
\ No newline at end of file
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Provedores de identidade aceitos em AUTH_PROVIDERS
+const (
+	ProvedorLocal = "local"
+	ProvedorLDAP  = "ldap"
+	ProvedorOIDC  = "oidc"
+)
+
+// ConfigLDAP configura a autenticação por LDAP ou Active Directory. Os
+// padrões dos atributos seguem o Active Directory.
+type ConfigLDAP struct {
+	// URL ldap:// ou ldaps:// do servidor (LDAP_URL)
+	URL string
+	// StartTLS promove a conexão ldap:// a TLS (LDAP_START_TLS)
+	StartTLS bool
+	// AutoridadesCA vêm de LDAP_CA_FILE, o certificado da autoridade que
+	// emitiu o do servidor; nil usa as autoridades do sistema
+	AutoridadesCA *x509.CertPool
+	// Conta de serviço usada para localizar o usuário (LDAP_BIND_DN e
+	// LDAP_BIND_PASSWORD); vazia faz a busca anônima
+	BindDN    string
+	BindSenha string
+	// BaseDN é a raiz da busca de usuários (LDAP_BASE_DN)
+	BaseDN string
+	// ClasseUsuario e AtributoLogin localizam o usuário pelo login informado
+	// (LDAP_USER_OBJECT_CLASS, padrão person; LDAP_USER_ATTR, padrão sAMAccountName)
+	ClasseUsuario string
+	AtributoLogin string
+	// Atributos copiados para o usuário no provisionamento (LDAP_ATTR_*)
+	AtributoNome      string
+	AtributoEmail     string
+	AtributoCPF       string
+	AtributoLotacao   string
+	AtributoMatricula string
+	// AtributoGrupos lista os DNs dos grupos do usuário (LDAP_GROUP_ATTR, padrão memberOf)
+	AtributoGrupos string
+	// GruposAdmin são os grupos cujos membros são administradores
+	// (LDAP_ADMIN_GROUPS, DNs separados por ponto e vírgula). Vazio deixa o
+	// perfil de administrador a cargo da aplicação.
+	GruposAdmin []string
+	// GruposPermitidos restringe o login aos membros destes grupos
+	// (LDAP_ALLOWED_GROUPS, DNs separados por ponto e vírgula); vazio permite todos
+	GruposPermitidos []string
+}
+
+// ProvedorHabilitado indica se o provedor está na lista de AUTH_PROVIDERS
+func (c *Config) ProvedorHabilitado(provedor string) bool {
+	for _, p := range c.ProvedoresAutenticacao {
+		if p == provedor {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) lerProvedoresAutenticacao() error {
+	for _, p := range strings.Split(getEnvOrDefault("AUTH_PROVIDERS", ProvedorLocal), ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		switch p {
+		case "":
+			continue
+		case ProvedorLocal, ProvedorLDAP, ProvedorOIDC:
+			c.ProvedoresAutenticacao = append(c.ProvedoresAutenticacao, p)
+		default:
+			return fmt.Errorf("AUTH_PROVIDERS: provedor %q desconhecido", p)
+		}
+	}
+
+	if err := c.lerOIDC(); err != nil {
+		return err
+	}
+	if !c.ProvedorHabilitado(ProvedorLDAP) {
+		return nil
+	}
+
+	var err error
+	l := &c.LDAP
+	l.URL = os.Getenv("LDAP_URL")
+	if l.StartTLS, err = strconv.ParseBool(getEnvOrDefault("LDAP_START_TLS", "false")); err != nil {
+		return fmt.Errorf("LDAP_START_TLS inválido: %w", err)
+	}
+	if arquivo := os.Getenv("LDAP_CA_FILE"); arquivo != "" {
+		pem, err := os.ReadFile(arquivo)
+		if err != nil {
+			return fmt.Errorf("LDAP_CA_FILE: %w", err)
+		}
+		l.AutoridadesCA = x509.NewCertPool()
+		if !l.AutoridadesCA.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("LDAP_CA_FILE: nenhum certificado encontrado")
+		}
+	}
+	l.BindDN = os.Getenv("LDAP_BIND_DN")
+	if l.BindSenha, err = c.segredos.ler("LDAP_BIND_PASSWORD"); err != nil {
+		return err
+	}
+	l.BaseDN = os.Getenv("LDAP_BASE_DN")
+	l.ClasseUsuario = getEnvOrDefault("LDAP_USER_OBJECT_CLASS", "person")
+	l.AtributoLogin = getEnvOrDefault("LDAP_USER_ATTR", "sAMAccountName")
+	l.AtributoNome = getEnvOrDefault("LDAP_ATTR_NAME", "displayName")
+	l.AtributoEmail = getEnvOrDefault("LDAP_ATTR_EMAIL", "mail")
+	l.AtributoCPF = getEnvOrDefault("LDAP_ATTR_CPF", "employeeNumber")
+	l.AtributoLotacao = getEnvOrDefault("LDAP_ATTR_LOTACAO", "department")
+	l.AtributoMatricula = getEnvOrDefault("LDAP_ATTR_MATRICULA", "employeeID")
+	l.AtributoGrupos = getEnvOrDefault("LDAP_GROUP_ATTR", "memberOf")
+	l.GruposAdmin = listaDNs(os.Getenv("LDAP_ADMIN_GROUPS"))
+	l.GruposPermitidos = listaDNs(os.Getenv("LDAP_ALLOWED_GROUPS"))
+	return nil
+}
+
+// listaDNs separa DNs por ponto e vírgula, já que eles contêm vírgulas
+func listaDNs(valor string) []string {
+	var dns []string
+	for _, dn := range strings.Split(valor, ";") {
+		if dn = strings.TrimSpace(dn); dn != "" {
+			dns = append(dns, dn)
+		}
+	}
+	return dns
+}
+
+// validarLDAP aponta configuração incompleta ou que trafega senhas sem TLS
+func (c *Config) validarLDAP() []string {
+	if !c.ProvedorHabilitado(ProvedorLDAP) {
+		return nil
+	}
+	var problemas []string
+	if c.LDAP.URL == "" || c.LDAP.BaseDN == "" {
+		problemas = append(problemas, "LDAP_URL e LDAP_BASE_DN são obrigatórios com o provedor ldap")
+	}
+	if strings.HasPrefix(c.LDAP.URL, "ldap://") && !c.LDAP.StartTLS {
+		problemas = append(problemas, "LDAP_URL sem TLS: use ldaps:// ou LDAP_START_TLS=true")
+	}
+	return problemas
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ConfigOIDC configura o login único por OpenID Connect (Keycloak, gov.br e
+// outros), no fluxo authorization code com PKCE
+type ConfigOIDC struct {
+	// URLDescoberta é o documento .well-known/openid-configuration do
+	// provedor (OIDC_DISCOVERY_URL)
+	URLDescoberta string
+	// Credenciais do cliente registrado no provedor (OIDC_CLIENT_ID e
+	// OIDC_CLIENT_SECRET); sem segredo o cliente é público e só o PKCE o protege
+	ClienteID      string
+	ClienteSegredo string
+	// URLRetorno é o endereço de /api/user/oidc/callback visto pelo navegador
+	// e cadastrado no provedor (OIDC_REDIRECT_URL)
+	URLRetorno string
+	// URLFrontend é a página do frontend que conclui o login
+	// (OIDC_POST_LOGIN_URL, padrão /login/sso)
+	URLFrontend string
+	// Escopos pedidos na autorização (OIDC_SCOPES, padrão "openid profile email")
+	Escopos []string
+	// NomeExibicao aparece no botão de login (OIDC_DISPLAY_NAME, padrão SSO)
+	NomeExibicao string
+	// Claims do ID token copiadas para o usuário (OIDC_CLAIM_*). Aceitam
+	// caminhos com ponto, como realm_access.roles no Keycloak. No gov.br o
+	// CPF é o próprio sub.
+	ClaimCPF       string
+	ClaimNome      string
+	ClaimEmail     string
+	ClaimLotacao   string
+	ClaimMatricula string
+	// ClaimGrupos lista os grupos ou papéis do usuário (OIDC_CLAIM_GROUPS, padrão groups)
+	ClaimGrupos string
+	// GruposAdmin e GruposPermitidos funcionam como no LDAP
+	// (OIDC_ADMIN_GROUPS e OIDC_ALLOWED_GROUPS, separados por ponto e vírgula)
+	GruposAdmin      []string
+	GruposPermitidos []string
+}
+
+func (c *Config) lerOIDC() error {
+	if !c.ProvedorHabilitado(ProvedorOIDC) {
+		return nil
+	}
+
+	var err error
+	o := &c.OIDC
+	o.URLDescoberta = os.Getenv("OIDC_DISCOVERY_URL")
+	o.ClienteID = os.Getenv("OIDC_CLIENT_ID")
+	if o.ClienteSegredo, err = c.segredos.ler("OIDC_CLIENT_SECRET"); err != nil {
+		return err
+	}
+	o.URLRetorno = os.Getenv("OIDC_REDIRECT_URL")
+	o.URLFrontend = getEnvOrDefault("OIDC_POST_LOGIN_URL", "/login/sso")
+	o.Escopos = strings.Fields(getEnvOrDefault("OIDC_SCOPES", "openid profile email"))
+	o.NomeExibicao = getEnvOrDefault("OIDC_DISPLAY_NAME", "SSO")
+	o.ClaimCPF = getEnvOrDefault("OIDC_CLAIM_CPF", "cpf")
+	o.ClaimNome = getEnvOrDefault("OIDC_CLAIM_NAME", "name")
+	o.ClaimEmail = getEnvOrDefault("OIDC_CLAIM_EMAIL", "email")
+	o.ClaimLotacao = getEnvOrDefault("OIDC_CLAIM_LOTACAO", "lotacao")
+	o.ClaimMatricula = getEnvOrDefault("OIDC_CLAIM_MATRICULA", "matricula")
+	o.ClaimGrupos = getEnvOrDefault("OIDC_CLAIM_GROUPS", "groups")
+	o.GruposAdmin = listaDNs(os.Getenv("OIDC_ADMIN_GROUPS"))
+	o.GruposPermitidos = listaDNs(os.Getenv("OIDC_ALLOWED_GROUPS"))
+	return nil
+}
+
+// validarOIDC aponta configuração incompleta ou que trafega códigos sem TLS
+func (c *Config) validarOIDC() []string {
+	if !c.ProvedorHabilitado(ProvedorOIDC) {
+		return nil
+	}
+	o := c.OIDC
+	if o.URLDescoberta == "" || o.ClienteID == "" || o.URLRetorno == "" {
+		return []string{"OIDC_DISCOVERY_URL, OIDC_CLIENT_ID e OIDC_REDIRECT_URL são obrigatórios com o provedor oidc"}
+	}
+	var problemas []string
+	for nome, valor := range map[string]string{"OIDC_DISCOVERY_URL": o.URLDescoberta, "OIDC_REDIRECT_URL": o.URLRetorno} {
+		if u, err := url.Parse(valor); err != nil || u.Scheme != "https" {
+			problemas = append(problemas, nome+" deve usar https://")
+		}
+	}
+	if !contem(o.Escopos, "openid") {
+		problemas = append(problemas, "OIDC_SCOPES deve incluir openid")
+	}
+	return problemas
+}
+
+func contem(lista []string, valor string) bool {
+	for _, v := range lista {
+		if v == valor {
+			return true
+		}
+	}
+	return false
+}
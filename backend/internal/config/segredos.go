@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Variáveis que localizam e abrem o cofre local de segredos
+const (
+	envCaminhoCofre = "CONSULTAPIX_VAULT_FILE"
+	envSenhaCofre   = "CONSULTAPIX_VAULT_PASSWORD"
+)
+
+// fonteSegredos resolve um segredo, nesta ordem de prioridade:
+//  1. arquivo indicado em NOME_FILE (secrets do Docker/Kubernetes)
+//  2. variável de ambiente NOME
+//  3. entrada NOME do cofre local cifrado
+//
+// Arquivos e cofre são relidos a cada chamada, o que permite trocar um
+// segredo sem reiniciar a aplicação.
+type fonteSegredos struct {
+	caminhoCofre string
+	senhaCofre   string
+	mu           sync.Mutex
+}
+
+// LocalizarCofre devolve o caminho do cofre e sua senha. O caminho vazio indica
+// que nenhum cofre foi configurado.
+func LocalizarCofre() (caminho, senha string, err error) {
+	caminho = os.Getenv(envCaminhoCofre)
+	if caminho == "" {
+		return "", "", nil
+	}
+
+	senha, err = lerVariavelOuArquivo(envSenhaCofre)
+	if err != nil {
+		return "", "", err
+	}
+	if senha == "" {
+		return "", "", fmt.Errorf("%s definido sem %s ou %s_FILE", envCaminhoCofre, envSenhaCofre, envSenhaCofre)
+	}
+	return caminho, senha, nil
+}
+
+func novaFonteSegredos() (*fonteSegredos, error) {
+	caminho, senha, err := LocalizarCofre()
+	if err != nil {
+		return nil, err
+	}
+	f := &fonteSegredos{caminhoCofre: caminho, senhaCofre: senha}
+	if f.caminhoCofre == "" {
+		return f, nil
+	}
+
+	// Falha cedo se a senha não abrir o cofre
+	if _, err := AbrirCofre(f.caminhoCofre, f.senhaCofre); err != nil {
+		return nil, fmt.Errorf("erro ao abrir o cofre %s: %w", f.caminhoCofre, err)
+	}
+	return f, nil
+}
+
+// lerVariavelOuArquivo lê NOME_FILE, se definido, ou a variável NOME
+func lerVariavelOuArquivo(nome string) (string, error) {
+	if caminho := os.Getenv(nome + "_FILE"); caminho != "" {
+		conteudo, err := os.ReadFile(caminho)
+		if err != nil {
+			return "", fmt.Errorf("erro ao ler %s_FILE: %w", nome, err)
+		}
+		return strings.TrimSpace(string(conteudo)), nil
+	}
+	return os.Getenv(nome), nil
+}
+
+// ler devolve o primeiro valor não vazio entre os nomes informados (o primeiro
+// é o nome oficial, os demais são nomes legados aceitos por compatibilidade)
+func (f *fonteSegredos) ler(nomes ...string) (string, error) {
+	for _, nome := range nomes {
+		valor, err := lerVariavelOuArquivo(nome)
+		if err != nil {
+			return "", err
+		}
+		if valor != "" {
+			return valor, nil
+		}
+	}
+
+	if f.caminhoCofre == "" {
+		return "", nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cofre, err := AbrirCofre(f.caminhoCofre, f.senhaCofre)
+	if err != nil {
+		return "", fmt.Errorf("erro ao abrir o cofre %s: %w", f.caminhoCofre, err)
+	}
+	for _, nome := range nomes {
+		if valor := cofre[nome]; valor != "" {
+			return valor, nil
+		}
+	}
+	return "", nil
+}
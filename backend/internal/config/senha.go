@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaximoHistoricoSenhas é o maior PASSWORD_HISTORY aceito, e também quantas
+// senhas antigas o banco guarda por usuário
+const MaximoHistoricoSenhas = 24
+
+// PoliticaSenha define as exigências para as senhas locais
+type PoliticaSenha struct {
+	// TamanhoMinimo em caracteres (PASSWORD_MIN_LENGTH, padrão 12)
+	TamanhoMinimo int
+	// ClassesMinimas é quantas classes, entre minúsculas, maiúsculas, dígitos
+	// e símbolos, a senha precisa ter (PASSWORD_MIN_CLASSES, padrão 3)
+	ClassesMinimas int
+	// Historico é quantas das últimas senhas, contando a atual, não podem ser
+	// repetidas (PASSWORD_HISTORY, padrão 5)
+	Historico int
+	// Validade obriga a troca depois desse tempo (PASSWORD_MAX_AGE, ex. "2160h");
+	// zero desativa a expiração
+	Validade time.Duration
+}
+
+// BloqueioLogin configura o bloqueio progressivo contra força bruta
+type BloqueioLogin struct {
+	// TentativasConta e TentativasIP são as falhas aceitas antes do bloqueio,
+	// por login informado e por IP (LOGIN_MAX_ATTEMPTS, padrão 5;
+	// LOGIN_IP_MAX_ATTEMPTS, padrão 20)
+	TentativasConta int
+	TentativasIP    int
+	// Espera é o primeiro bloqueio, dobrado a cada nova falha até EsperaMaxima
+	// (LOGIN_LOCKOUT_BASE, padrão 1m; LOGIN_LOCKOUT_MAX, padrão 1h)
+	Espera       time.Duration
+	EsperaMaxima time.Duration
+	// Janela é o tempo sem falhas após o qual a contagem recomeça
+	// (LOGIN_ATTEMPT_WINDOW, padrão 15m)
+	Janela time.Duration
+}
+
+func (c *Config) lerPoliticaSenha() error {
+	var err error
+	p := &c.PoliticaSenha
+	if p.TamanhoMinimo, err = strconv.Atoi(getEnvOrDefault("PASSWORD_MIN_LENGTH", "12")); err != nil || p.TamanhoMinimo < 1 {
+		return fmt.Errorf("PASSWORD_MIN_LENGTH inválido")
+	}
+	if p.ClassesMinimas, err = strconv.Atoi(getEnvOrDefault("PASSWORD_MIN_CLASSES", "3")); err != nil || p.ClassesMinimas < 0 || p.ClassesMinimas > 4 {
+		return fmt.Errorf("PASSWORD_MIN_CLASSES deve estar entre 0 e 4")
+	}
+	if p.Historico, err = strconv.Atoi(getEnvOrDefault("PASSWORD_HISTORY", "5")); err != nil || p.Historico < 0 || p.Historico > MaximoHistoricoSenhas {
+		return fmt.Errorf("PASSWORD_HISTORY deve estar entre 0 e %d", MaximoHistoricoSenhas)
+	}
+	if p.Validade, err = time.ParseDuration(getEnvOrDefault("PASSWORD_MAX_AGE", "0s")); err != nil {
+		return fmt.Errorf("PASSWORD_MAX_AGE inválido: %w", err)
+	}
+	if c.PrazoRedefinicaoSenha, err = time.ParseDuration(getEnvOrDefault("PASSWORD_RESET_TTL", "30m")); err != nil {
+		return fmt.Errorf("PASSWORD_RESET_TTL inválido: %w", err)
+	}
+
+	b := &c.BloqueioLogin
+	if b.TentativasConta, err = strconv.Atoi(getEnvOrDefault("LOGIN_MAX_ATTEMPTS", "5")); err != nil {
+		return fmt.Errorf("LOGIN_MAX_ATTEMPTS inválido: %w", err)
+	}
+	if b.TentativasIP, err = strconv.Atoi(getEnvOrDefault("LOGIN_IP_MAX_ATTEMPTS", "20")); err != nil {
+		return fmt.Errorf("LOGIN_IP_MAX_ATTEMPTS inválido: %w", err)
+	}
+	if b.Espera, err = time.ParseDuration(getEnvOrDefault("LOGIN_LOCKOUT_BASE", "1m")); err != nil {
+		return fmt.Errorf("LOGIN_LOCKOUT_BASE inválido: %w", err)
+	}
+	if b.EsperaMaxima, err = time.ParseDuration(getEnvOrDefault("LOGIN_LOCKOUT_MAX", "1h")); err != nil {
+		return fmt.Errorf("LOGIN_LOCKOUT_MAX inválido: %w", err)
+	}
+	if b.Janela, err = time.ParseDuration(getEnvOrDefault("LOGIN_ATTEMPT_WINDOW", "15m")); err != nil {
+		return fmt.Errorf("LOGIN_ATTEMPT_WINDOW inválido: %w", err)
+	}
+
+	// Padrão: a própria máquina e as redes privadas, onde ficam o nginx e o Docker
+	for _, faixa := range strings.Split(getEnvOrDefault("TRUSTED_PROXIES", "127.0.0.0/8,::1/128,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16"), ",") {
+		if faixa = strings.TrimSpace(faixa); faixa == "" {
+			continue
+		}
+		_, rede, err := net.ParseCIDR(faixa)
+		if err != nil {
+			return fmt.Errorf("TRUSTED_PROXIES: %w", err)
+		}
+		c.ProxiesConfiaveis = append(c.ProxiesConfiaveis, rede)
+	}
+	return nil
+}
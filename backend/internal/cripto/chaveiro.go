@@ -0,0 +1,81 @@
+package cripto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrChaveMestraDesconhecida indica chave embrulhada por uma chave mestra que
+// não está entre a atual e as anteriores
+var ErrChaveMestraDesconhecida = errors.New("chave embrulhada por uma chave mestra desconhecida")
+
+// Chaveiro embrulha chaves de dados com a chave mestra atual e desembrulha com
+// ela ou com as anteriores, identificadas pela impressão digital
+type Chaveiro struct {
+	atual   string
+	mestras map[string]*Cifrador
+}
+
+// NovoChaveiro cria o chaveiro com a chave mestra atual e as substituídas
+func NovoChaveiro(atual []byte, anteriores ...[]byte) (*Chaveiro, error) {
+	c := &Chaveiro{atual: ImpressaoDigital(atual), mestras: map[string]*Cifrador{}}
+	for _, chave := range append([][]byte{atual}, anteriores...) {
+		cifrador, err := NovoCifrador(chave)
+		if err != nil {
+			return nil, err
+		}
+		c.mestras[ImpressaoDigital(chave)] = cifrador
+	}
+	return c, nil
+}
+
+// ImpressaoDigital identifica uma chave sem revelá-la: os primeiros 8 bytes
+// do SHA-256, em hexadecimal
+func ImpressaoDigital(chave []byte) string {
+	soma := sha256.Sum256(chave)
+	return hex.EncodeToString(soma[:8])
+}
+
+// Atual retorna a impressão digital da chave mestra atual
+func (c *Chaveiro) Atual() string {
+	return c.atual
+}
+
+// Embrulhar cifra a chave de dados com a chave mestra atual
+func (c *Chaveiro) Embrulhar(chave, contexto []byte) (string, []byte, error) {
+	embrulhada, err := c.mestras[c.atual].Cifrar(chave, contexto)
+	return c.atual, embrulhada, err
+}
+
+// Desembrulhar decifra a chave de dados com a chave mestra que a embrulhou
+func (c *Chaveiro) Desembrulhar(mestra string, embrulhada, contexto []byte) ([]byte, error) {
+	cifrador, ok := c.mestras[mestra]
+	if !ok {
+		return nil, fmt.Errorf("%w (%s)", ErrChaveMestraDesconhecida, mestra)
+	}
+	return cifrador.Decifrar(embrulhada, contexto)
+}
+
+// NovaChave gera uma chave aleatória de TamanhoChave bytes
+func NovaChave() ([]byte, error) {
+	chave := make([]byte, TamanhoChave)
+	if _, err := rand.Read(chave); err != nil {
+		return nil, err
+	}
+	return chave, nil
+}
+
+// IndiceCego calcula o HMAC-SHA256 de um valor já normalizado. O tipo separa
+// os domínios, para que um documento e uma conta com os mesmos dígitos não
+// gerem o mesmo índice.
+func IndiceCego(chave []byte, tipo, valor string) []byte {
+	mac := hmac.New(sha256.New, chave)
+	mac.Write([]byte(tipo))
+	mac.Write([]byte{0})
+	mac.Write([]byte(valor))
+	return mac.Sum(nil)
+}
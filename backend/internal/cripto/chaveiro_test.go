@@ -0,0 +1,106 @@
+package cripto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func novoChaveiroTeste(t *testing.T, atual []byte, anteriores ...[]byte) *Chaveiro {
+	t.Helper()
+	chaveiro, err := NovoChaveiro(atual, anteriores...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chaveiro
+}
+
+func TestEmbrulharDesembrulhar(t *testing.T) {
+	mestra := novaChaveTeste(t)
+	chaveiro := novoChaveiroTeste(t, mestra)
+	dados := novaChaveTeste(t)
+	contexto := []byte("chave_dados:1")
+
+	impressao, embrulhada, err := chaveiro.Embrulhar(dados, contexto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if impressao != ImpressaoDigital(mestra) || impressao != chaveiro.Atual() {
+		t.Fatalf("embrulhada com %q, esperada a chave atual %q", impressao, chaveiro.Atual())
+	}
+	desembrulhada, err := chaveiro.Desembrulhar(impressao, embrulhada, contexto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(desembrulhada, dados) {
+		t.Fatal("chave desembrulhada difere da original")
+	}
+}
+
+func TestChaveiroRotacao(t *testing.T) {
+	antiga, nova := novaChaveTeste(t), novaChaveTeste(t)
+	dados := novaChaveTeste(t)
+	contexto := []byte("chave_dados:1")
+
+	impressaoAntiga, embrulhada, err := novoChaveiroTeste(t, antiga).Embrulhar(dados, contexto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Depois da rotação, a chave anterior ainda desembrulha e a nova embrulha
+	rotacionado := novoChaveiroTeste(t, nova, antiga)
+	desembrulhada, err := rotacionado.Desembrulhar(impressaoAntiga, embrulhada, contexto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(desembrulhada, dados) {
+		t.Fatal("chave desembrulhada pela chave anterior difere da original")
+	}
+	if impressao, _, _ := rotacionado.Embrulhar(dados, contexto); impressao != ImpressaoDigital(nova) {
+		t.Fatalf("rotacionado embrulha com %q, esperada a chave nova %q", impressao, ImpressaoDigital(nova))
+	}
+
+	// Sem a chave anterior configurada, o embrulho antigo é recusado
+	if _, err := novoChaveiroTeste(t, nova).Desembrulhar(impressaoAntiga, embrulhada, contexto); !errors.Is(err, ErrChaveMestraDesconhecida) {
+		t.Fatalf("Desembrulhar sem a chave anterior = %v, esperado ErrChaveMestraDesconhecida", err)
+	}
+	// Uma chave mestra errada, ainda que informada como a que embrulhou, não decifra
+	if _, err := rotacionado.Desembrulhar(ImpressaoDigital(nova), embrulhada, contexto); !errors.Is(err, ErrDadosCorrompidos) {
+		t.Fatalf("Desembrulhar com a chave mestra errada = %v, esperado ErrDadosCorrompidos", err)
+	}
+	// Nem com o contexto de outra chave de dados
+	if _, err := rotacionado.Desembrulhar(impressaoAntiga, embrulhada, []byte("chave_dados:2")); !errors.Is(err, ErrDadosCorrompidos) {
+		t.Fatalf("Desembrulhar com outro contexto = %v, esperado ErrDadosCorrompidos", err)
+	}
+}
+
+func TestNovoChaveiroChaveInvalida(t *testing.T) {
+	if _, err := NovoChaveiro(make([]byte, 16)); err == nil {
+		t.Error("chave mestra de 16 bytes aceita")
+	}
+	if _, err := NovoChaveiro(novaChaveTeste(t), make([]byte, 31)); err == nil {
+		t.Error("chave anterior de 31 bytes aceita")
+	}
+}
+
+func TestIndiceCego(t *testing.T) {
+	chave, outraChave := novaChaveTeste(t), novaChaveTeste(t)
+	indice := IndiceCego(chave, "documento", "12345678909")
+
+	if !bytes.Equal(indice, IndiceCego(chave, "documento", "12345678909")) {
+		t.Fatal("índice cego não é determinístico")
+	}
+	if len(indice) != 32 {
+		t.Fatalf("índice com %d bytes, esperado 32", len(indice))
+	}
+	if bytes.Equal(indice, IndiceCego(chave, "conta", "12345678909")) {
+		t.Error("tipos diferentes geraram o mesmo índice")
+	}
+	if bytes.Equal(indice, IndiceCego(outraChave, "documento", "12345678909")) {
+		t.Error("chaves diferentes geraram o mesmo índice")
+	}
+	// O separador impede que o fim do tipo se confunda com o início do valor
+	if bytes.Equal(IndiceCego(chave, "ab", "c"), IndiceCego(chave, "a", "bc")) {
+		t.Error("tipo e valor concatenados sem separação")
+	}
+}
@@ -0,0 +1,74 @@
+package cripto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// TamanhoChave é o tamanho, em bytes, das chaves AES-256 aceitas
+const TamanhoChave = 32
+
+// ErrDadosCorrompidos indica um texto cifrado adulterado ou cifrado com outra chave
+var ErrDadosCorrompidos = errors.New("dados cifrados inválidos ou chave incorreta")
+
+// Cifrador cifra e decifra valores com AES-256-GCM. O nonce aleatório é
+// gravado no início do texto cifrado.
+type Cifrador struct {
+	aead cipher.AEAD
+}
+
+// NovoCifrador cria um cifrador a partir de uma chave de 32 bytes
+func NovoCifrador(chave []byte) (*Cifrador, error) {
+	if len(chave) != TamanhoChave {
+		return nil, fmt.Errorf("a chave deve ter %d bytes, tem %d", TamanhoChave, len(chave))
+	}
+	bloco, err := aes.NewCipher(chave)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(bloco)
+	if err != nil {
+		return nil, err
+	}
+	return &Cifrador{aead: aead}, nil
+}
+
+// DecodificarChave interpreta uma chave em base64 (padrão ou URL, com ou sem padding)
+func DecodificarChave(valor string) ([]byte, error) {
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if chave, err := enc.DecodeString(valor); err == nil {
+			if len(chave) != TamanhoChave {
+				return nil, fmt.Errorf("a chave deve ter %d bytes, tem %d", TamanhoChave, len(chave))
+			}
+			return chave, nil
+		}
+	}
+	return nil, errors.New("a chave deve estar codificada em base64")
+}
+
+// Cifrar cifra o valor; dadosAssociados vinculam o resultado a um contexto
+// (por exemplo, a tabela e o registro) e precisam ser repetidos ao decifrar
+func (c *Cifrador) Cifrar(valor, dadosAssociados []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, valor, dadosAssociados), nil
+}
+
+// Decifrar reverte Cifrar
+func (c *Cifrador) Decifrar(cifrado, dadosAssociados []byte) ([]byte, error) {
+	tamanhoNonce := c.aead.NonceSize()
+	if len(cifrado) < tamanhoNonce {
+		return nil, ErrDadosCorrompidos
+	}
+	valor, err := c.aead.Open(nil, cifrado[:tamanhoNonce], cifrado[tamanhoNonce:], dadosAssociados)
+	if err != nil {
+		return nil, ErrDadosCorrompidos
+	}
+	return valor, nil
+}
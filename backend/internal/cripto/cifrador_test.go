@@ -0,0 +1,117 @@
+package cripto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func novaChaveTeste(t *testing.T) []byte {
+	t.Helper()
+	chave, err := NovaChave()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chave
+}
+
+func novoCifradorTeste(t *testing.T, chave []byte) *Cifrador {
+	t.Helper()
+	cifrador, err := NovoCifrador(chave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cifrador
+}
+
+func TestCifrarDecifrar(t *testing.T) {
+	cifrador := novoCifradorTeste(t, novaChaveTeste(t))
+	contexto := []byte("chave_pix:42")
+
+	for _, valor := range []string{"", "123.456.789-09", `{"nome":"Fulano de Tal","agencia":"0001"}`} {
+		cifrado, err := cifrador.Cifrar([]byte(valor), contexto)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if valor != "" && bytes.Contains(cifrado, []byte(valor)) {
+			t.Fatalf("texto cifrado contém o valor em claro %q", valor)
+		}
+		decifrado, err := cifrador.Decifrar(cifrado, contexto)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(decifrado) != valor {
+			t.Fatalf("decifrado %q, esperado %q", decifrado, valor)
+		}
+	}
+
+	// O nonce aleatório faz o mesmo valor gerar textos cifrados diferentes
+	a, _ := cifrador.Cifrar([]byte("valor"), contexto)
+	b, _ := cifrador.Cifrar([]byte("valor"), contexto)
+	if bytes.Equal(a, b) {
+		t.Fatal("duas cifragens do mesmo valor são iguais")
+	}
+}
+
+func TestDecifrarRecusa(t *testing.T) {
+	chave := novaChaveTeste(t)
+	cifrador := novoCifradorTeste(t, chave)
+	contexto := []byte("chave_pix:42")
+	cifrado, err := cifrador.Cifrar([]byte("123.456.789-09"), contexto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adulterado := append([]byte(nil), cifrado...)
+	adulterado[len(adulterado)-1] ^= 1
+
+	casos := []struct {
+		nome     string
+		cifrador *Cifrador
+		cifrado  []byte
+		contexto []byte
+	}{
+		{"outra chave", novoCifradorTeste(t, novaChaveTeste(t)), cifrado, contexto},
+		{"outro contexto", cifrador, cifrado, []byte("chave_pix:43")},
+		{"sem contexto", cifrador, cifrado, nil},
+		{"adulterado", cifrador, adulterado, contexto},
+		{"truncado", cifrador, cifrado[:len(cifrado)-1], contexto},
+		{"menor que o nonce", cifrador, cifrado[:4], contexto},
+		{"vazio", cifrador, nil, contexto},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			if _, err := c.cifrador.Decifrar(c.cifrado, c.contexto); !errors.Is(err, ErrDadosCorrompidos) {
+				t.Fatalf("Decifrar = %v, esperado ErrDadosCorrompidos", err)
+			}
+		})
+	}
+}
+
+func TestNovoCifradorTamanhoChave(t *testing.T) {
+	for _, tamanho := range []int{0, 16, 24, 31, 33} {
+		if _, err := NovoCifrador(make([]byte, tamanho)); err == nil {
+			t.Errorf("chave de %d bytes aceita", tamanho)
+		}
+	}
+}
+
+func TestDecodificarChave(t *testing.T) {
+	chave := novaChaveTeste(t)
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		decodificada, err := DecodificarChave(enc.EncodeToString(chave))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decodificada, chave) {
+			t.Fatal("chave decodificada difere da original")
+		}
+	}
+
+	for _, valor := range []string{"", "não é base64!", base64.StdEncoding.EncodeToString(make([]byte, 16))} {
+		if _, err := DecodificarChave(valor); err == nil {
+			t.Errorf("DecodificarChave(%q) aceita", valor)
+		}
+	}
+}
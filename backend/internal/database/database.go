@@ -12,8 +12,8 @@ import (
 // DB é uma instância global do banco de dados
 var DB *sql.DB
 
-// Initialize inicializa a conexão com o banco de dados
-func Initialize(cfg *config.Config) error {
+// Conectar abre a conexão com o banco de dados sem executar migrações
+func Conectar(cfg *config.Config) error {
 	var err error
 	// Abrir conexão com o PostgreSQL
 	DB, err = sql.Open("postgres", cfg.DatabaseURL)
@@ -25,11 +25,24 @@ func Initialize(cfg *config.Config) error {
 		return fmt.Errorf("erro ao verificar conexão com banco de dados: %w", err)
 	}
 	log.Println("Conexão com o banco de dados estabelecida com sucesso")
-	
+	return nil
+}
+
+// Initialize inicializa a conexão com o banco de dados e aplica as migrações pendentes
+func Initialize(cfg *config.Config) error {
+	if err := Conectar(cfg); err != nil {
+		return err
+	}
+
 	// Executar migrações
-	if err = SetupTables(DB); err != nil {
-		return fmt.Errorf("erro ao configurar tabelas: %w", err)
+	log.Println("Executando migrações do banco de dados...")
+	if _, err := Migrar(DB); err != nil {
+		return fmt.Errorf("erro ao executar migrações: %w", err)
+	}
+	if err := garantirAdministradorPadrao(DB, cfg.SenhaAdminInicial); err != nil {
+		return fmt.Errorf("erro ao criar administrador padrão: %w", err)
 	}
+	log.Println("Todas as migrações executadas com sucesso!")
 	return nil
 }
 
@@ -37,25 +50,3 @@ func Initialize(cfg *config.Config) error {
 func GetDB() *sql.DB {
 	return DB
 }
-
-// RunMigrations executa as migrações do banco de dados
-func RunMigrations(db *sql.DB) error {
-	// Implementação das migrações aqui
-	// Exemplo simples: criar tabela se não existir
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS pix_queries (
-			id SERIAL PRIMARY KEY,
-			key_type VARCHAR(20) NOT NULL,
-			key_value VARCHAR(100) NOT NULL,
-			status VARCHAR(20) NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		return fmt.Errorf("erro ao criar tabela de consultas: %w", err)
-	}
-	
-	log.Println("Migrações executadas com sucesso")
-	return nil
-}
\ No newline at end of file
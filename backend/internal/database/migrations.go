@@ -1,243 +1,322 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
 	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
-// SetupTables verifica e cria as tabelas necessárias no banco de dados
-func SetupTables(db *sql.DB) error {
-	log.Println("Executando migrações do banco de dados...")
+//go:embed migrations/*.sql
+var arquivosMigracoes embed.FS
+
+// chaveLockMigracoes identifica o advisory lock que impede duas instâncias
+// de migrarem o banco ao mesmo tempo
+const chaveLockMigracoes = 7243118
+
+// nomeArquivoMigracao segue o padrão 0001_descricao.up.sql / 0001_descricao.down.sql
+var nomeArquivoMigracao = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migracao representa um par de scripts SQL versionados
+type Migracao struct {
+	Versao int
+	Nome   string
+	Up     string
+	Down   string
+}
+
+// StatusMigracao indica se uma migração já foi aplicada
+type StatusMigracao struct {
+	Versao     int        `json:"versao"`
+	Nome       string     `json:"nome"`
+	Aplicada   bool       `json:"aplicada"`
+	AplicadaEm *time.Time `json:"aplicadaEm,omitempty"`
+}
+
+// carregarMigracoes lê os scripts embutidos e os ordena por versão
+func carregarMigracoes() ([]Migracao, error) {
+	entradas, err := arquivosMigracoes.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	porVersao := make(map[int]*Migracao)
+	for _, entrada := range entradas {
+		partes := nomeArquivoMigracao.FindStringSubmatch(entrada.Name())
+		if partes == nil {
+			return nil, fmt.Errorf("nome de migração inválido: %s", entrada.Name())
+		}
+
+		versao, _ := strconv.Atoi(partes[1])
+		conteudo, err := arquivosMigracoes.ReadFile(path.Join("migrations", entrada.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := porVersao[versao]
+		if !ok {
+			m = &Migracao{Versao: versao, Nome: partes[2]}
+			porVersao[versao] = m
+		} else if m.Nome != partes[2] {
+			return nil, fmt.Errorf("versão %d de migração duplicada: %s e %s", versao, m.Nome, partes[2])
+		}
+
+		if partes[3] == "up" {
+			m.Up = string(conteudo)
+		} else {
+			m.Down = string(conteudo)
+		}
+	}
+
+	migracoes := make([]Migracao, 0, len(porVersao))
+	for _, m := range porVersao {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migração %04d_%s precisa dos scripts up e down", m.Versao, m.Nome)
+		}
+		migracoes = append(migracoes, *m)
+	}
+	sort.Slice(migracoes, func(i, j int) bool { return migracoes[i].Versao < migracoes[j].Versao })
+
+	return migracoes, nil
+}
 
-	// Criar tabela de usuários
+// prepararControleMigracoes cria a tabela schema_migrations se necessário
+func prepararControleMigracoes(db *sql.DB) error {
 	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS usuario (
-			id SERIAL PRIMARY KEY,
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			versao INT PRIMARY KEY,
 			nome VARCHAR(255) NOT NULL,
-			cpf VARCHAR(20) NOT NULL UNIQUE,
-			email VARCHAR(255) NOT NULL UNIQUE,
-			password VARCHAR(255) NOT NULL,
-			lotacao VARCHAR(255),
-			matricula VARCHAR(50) UNIQUE,
-			admin BOOLEAN NOT NULL DEFAULT FALSE
+			aplicada_em TIMESTAMPTZ NOT NULL DEFAULT NOW()
 		)
 	`)
+	return err
+}
+
+// versoesAplicadas retorna as migrações já registradas em schema_migrations
+func versoesAplicadas(db *sql.DB) (map[int]time.Time, error) {
+	rows, err := db.Query(`SELECT versao, aplicada_em FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aplicadas := make(map[int]time.Time)
+	for rows.Next() {
+		var versao int
+		var aplicadaEm time.Time
+		if err := rows.Scan(&versao, &aplicadaEm); err != nil {
+			return nil, err
+		}
+		aplicadas[versao] = aplicadaEm
+	}
+	return aplicadas, rows.Err()
+}
+
+// comLock executa fn segurando o advisory lock de migrações
+func comLock(db *sql.DB, fn func() error) error {
+	if err := prepararControleMigracoes(db); err != nil {
+		return fmt.Errorf("erro ao criar schema_migrations: %w", err)
+	}
+
+	// O lock pertence à sessão, então é obtido e liberado na mesma conexão
+	conn, err := db.Conn(context.Background())
 	if err != nil {
 		return err
 	}
-	log.Println("Tabela 'usuario' verificada/criada com sucesso")
+	defer conn.Close()
 
-	// Verificar se existe algum usuário administrador
-	var count int
-	err = db.QueryRow("SELECT COUNT(*) FROM usuario WHERE admin = true").Scan(&count)
+	if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_lock($1)`, chaveLockMigracoes); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, chaveLockMigracoes)
+
+	return fn()
+}
+
+// executarMigracao roda um script e atualiza schema_migrations na mesma transação
+func executarMigracao(db *sql.DB, m Migracao, subir bool) error {
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	// Se não existe nenhum admin, criar um usuário padrão
-	if count == 0 {
-		// Gerar hash da senha
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte("admin"), bcrypt.DefaultCost)
+	script := m.Down
+	if subir {
+		script = m.Up
+	}
+	if _, err = tx.Exec(script); err != nil {
+		return err
+	}
+
+	if subir {
+		_, err = tx.Exec(`INSERT INTO schema_migrations (versao, nome) VALUES ($1, $2)`, m.Versao, m.Nome)
+	} else {
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE versao = $1`, m.Versao)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Migrar aplica, em ordem, todas as migrações pendentes e retorna quantas foram aplicadas
+func Migrar(db *sql.DB) (int, error) {
+	migracoes, err := carregarMigracoes()
+	if err != nil {
+		return 0, err
+	}
+
+	aplicadasAgora := 0
+	err = comLock(db, func() error {
+		aplicadas, err := versoesAplicadas(db)
 		if err != nil {
 			return err
 		}
 
-		// Inserir usuário admin
-		_, err = db.Exec(`
-			INSERT INTO usuario (nome, cpf, email, password, lotacao, matricula, admin) 
-			VALUES ('Administrador', '000.000.000-00', 'admin@admin.com', $1, 'Admin', 'admin', true)
-		`, string(hashedPassword))
+		for _, m := range migracoes {
+			if _, ok := aplicadas[m.Versao]; ok {
+				continue
+			}
+			if err := executarMigracao(db, m, true); err != nil {
+				return fmt.Errorf("erro na migração %04d_%s: %w", m.Versao, m.Nome, err)
+			}
+			log.Printf("Migração %04d_%s aplicada", m.Versao, m.Nome)
+			aplicadasAgora++
+		}
+		return nil
+	})
+
+	return aplicadasAgora, err
+}
+
+// Reverter desfaz as últimas migrações aplicadas, da mais recente para a mais antiga
+func Reverter(db *sql.DB, passos int) (int, error) {
+	migracoes, err := carregarMigracoes()
+	if err != nil {
+		return 0, err
+	}
+
+	revertidas := 0
+	err = comLock(db, func() error {
+		aplicadas, err := versoesAplicadas(db)
 		if err != nil {
 			return err
 		}
-		log.Println("Usuário administrador padrão criado com sucesso")
-	}
 
-	// Tabelas para PIX
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS requisicao_pix (
-			id SERIAL PRIMARY KEY,
-			data TIMESTAMP NOT NULL,
-			cpf_responsavel VARCHAR(20) NOT NULL,
-			lotacao VARCHAR(255),
-			caso VARCHAR(255),
-			tipo_busca VARCHAR(50) NOT NULL,
-			chave_busca VARCHAR(255) NOT NULL,
-			motivo_busca VARCHAR(255) NOT NULL,
-			resultado VARCHAR(255) NOT NULL,
-			vinculos JSONB,
-			autorizado BOOLEAN NOT NULL,
-			cpf_autorizacao VARCHAR(20),
-			nome_autorizacao VARCHAR(255),
-			data_hora_autorizacao VARCHAR(50),
-			token_autorizacao VARCHAR(255)
-		)
-	`)
+		for i := len(migracoes) - 1; i >= 0 && revertidas < passos; i-- {
+			m := migracoes[i]
+			if _, ok := aplicadas[m.Versao]; !ok {
+				continue
+			}
+			if err := executarMigracao(db, m, false); err != nil {
+				return fmt.Errorf("erro ao reverter migração %04d_%s: %w", m.Versao, m.Nome, err)
+			}
+			log.Printf("Migração %04d_%s revertida", m.Versao, m.Nome)
+			revertidas++
+		}
+		return nil
+	})
+
+	return revertidas, err
+}
+
+// StatusMigracoes lista todas as migrações conhecidas e se já foram aplicadas
+func StatusMigracoes(db *sql.DB) ([]StatusMigracao, error) {
+	migracoes, err := carregarMigracoes()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := prepararControleMigracoes(db); err != nil {
+		return nil, err
 	}
-	log.Println("Tabela 'requisicao_pix' verificada/criada com sucesso")
 
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS chave_pix (
-			id SERIAL PRIMARY KEY,
-			chave VARCHAR(255),
-			tipo_chave VARCHAR(50),
-			status VARCHAR(50),
-			data_abertura_reivindicacao VARCHAR(50),
-			cpf_cnpj VARCHAR(20),
-			nome_proprietario VARCHAR(255),
-			nome_fantasia VARCHAR(255),
-			participante VARCHAR(20),
-			agencia VARCHAR(20),
-			numero_conta VARCHAR(50),
-			tipo_conta VARCHAR(50),
-			data_abertura_conta VARCHAR(50),
-			proprietario_da_chave_desde VARCHAR(50),
-			data_criacao VARCHAR(50),
-			ultima_modificacao VARCHAR(50),
-			numero_banco VARCHAR(10),
-			nome_banco VARCHAR(255),
-			cpf_cnpj_busca VARCHAR(20),
-			nome_proprietario_busca VARCHAR(255),
-			id_requisicao INT NOT NULL,
-			FOREIGN KEY (id_requisicao) REFERENCES requisicao_pix(id) ON DELETE CASCADE,
-			UNIQUE(chave, id_requisicao)
-		)
-	`)
+	aplicadas, err := versoesAplicadas(db)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	log.Println("Tabela 'chave_pix' verificada/criada com sucesso")
 
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS evento_chave_pix (
-			id SERIAL PRIMARY KEY,
-			tipo_evento VARCHAR(50),
-			motivo_evento VARCHAR(255),
-			data_evento VARCHAR(50),
-			chave VARCHAR(255),
-			tipo_chave VARCHAR(50),
-			cpf_cnpj VARCHAR(20),
-			nome_proprietario VARCHAR(255),
-			nome_fantasia VARCHAR(255),
-			participante VARCHAR(20),
-			agencia VARCHAR(20),
-			numero_conta VARCHAR(50),
-			tipo_conta VARCHAR(50),
-			data_abertura_conta VARCHAR(50),
-			numero_banco VARCHAR(10),
-			nome_banco VARCHAR(255),
-			id_chave INT NOT NULL,
-			FOREIGN KEY (id_chave) REFERENCES chave_pix(id) ON DELETE CASCADE
-		)
-	`)
-	if err != nil {
-		return err
+	status := make([]StatusMigracao, 0, len(migracoes))
+	for _, m := range migracoes {
+		s := StatusMigracao{Versao: m.Versao, Nome: m.Nome}
+		if aplicadaEm, ok := aplicadas[m.Versao]; ok {
+			s.Aplicada = true
+			s.AplicadaEm = &aplicadaEm
+		}
+		status = append(status, s)
 	}
-	log.Println("Tabela 'evento_chave_pix' verificada/criada com sucesso")
+	return status, nil
+}
 
-	// Tabelas para CCS
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS requisicao_relacionamento_ccs (
-			id SERIAL PRIMARY KEY,
-			data_requisicao VARCHAR(50) NOT NULL,
-			data_inicio_consulta VARCHAR(50),
-			data_fim_consulta VARCHAR(50),
-			cpf_cnpj_consulta VARCHAR(20),
-			numero_processo VARCHAR(50),
-			motivo_busca VARCHAR(255),
-			cpf_responsavel VARCHAR(20),
-			lotacao VARCHAR(255),
-			caso VARCHAR(255),
-			numero_requisicao VARCHAR(50),
-			cpf_cnpj VARCHAR(20),
-			tipo_pessoa VARCHAR(10),
-			nome VARCHAR(255),
-			autorizado BOOLEAN NOT NULL,
-			cpf_autorizacao VARCHAR(20),
-			nome_autorizacao VARCHAR(255),
-			data_hora_autorizacao VARCHAR(50),
-			token_autorizacao VARCHAR(255),
-			status VARCHAR(50) NOT NULL,
-			detalhamento BOOLEAN NOT NULL DEFAULT FALSE
-		)
-	`)
+// garantirAdministradorPadrao cria o usuário administrador padrão se não
+// existir nenhum admin, com a senha inicial da configuração e a troca
+// obrigatória no primeiro login. O administrador padrão que ainda usa a senha
+// "admin" de versões anteriores também passa a ter de trocá-la.
+func garantirAdministradorPadrao(db *sql.DB, senhaInicial string) error {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM usuario WHERE admin = true").Scan(&count)
 	if err != nil {
 		return err
 	}
-	log.Println("Tabela 'requisicao_relacionamento_ccs' verificada/criada com sucesso")
+	if count > 0 {
+		return exigirTrocaSenhaPadrao(db)
+	}
+	if senhaInicial == "" {
+		return errors.New("nenhum administrador cadastrado: defina ADMIN_INITIAL_PASSWORD para criar o administrador padrão")
+	}
 
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS relacionamento_ccs (
-			id SERIAL PRIMARY KEY,
-			numero_requisicao VARCHAR(50),
-			id_pessoa VARCHAR(20),
-			nome_pessoa VARCHAR(255),
-			tipo_pessoa VARCHAR(10),
-			cnpj_responsavel VARCHAR(20),
-			numero_banco_responsavel VARCHAR(10),
-			nome_banco_responsavel VARCHAR(255),
-			cnpj_participante VARCHAR(20),
-			numero_banco_participante VARCHAR(10),
-			nome_banco_participante VARCHAR(255),
-			data_inicio_relacionamento VARCHAR(50),
-			data_fim_relacionamento VARCHAR(50),
-			id_requisicao INT NOT NULL,
-			data_requisicao_detalhamento VARCHAR(50),
-			status_detalhamento VARCHAR(50) NOT NULL DEFAULT 'Nao Solicitado',
-			responde_detalhamento BOOLEAN,
-			resposta BOOLEAN NOT NULL DEFAULT FALSE,
-			codigo_resposta VARCHAR(50),
-			codigo_if_resposta VARCHAR(50),
-			nuop_resposta VARCHAR(50),
-			FOREIGN KEY (id_requisicao) REFERENCES requisicao_relacionamento_ccs(id) ON DELETE CASCADE
-		)
-	`)
+	// Gerar hash da senha
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(senhaInicial), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
-	log.Println("Tabela 'relacionamento_ccs' verificada/criada com sucesso")
 
+	// Inserir usuário admin
 	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS bem_direito_valor_ccs (
-			id SERIAL PRIMARY KEY,
-			cnpj_participante VARCHAR(20),
-			tipo VARCHAR(50),
-			agencia VARCHAR(20),
-			conta VARCHAR(50),
-			vinculo VARCHAR(50),
-			nome_pessoa VARCHAR(255),
-			data_inicio VARCHAR(50),
-			data_fim VARCHAR(50),
-			id_relacionamento INT NOT NULL,
-			FOREIGN KEY (id_relacionamento) REFERENCES relacionamento_ccs(id) ON DELETE CASCADE
-		)
-	`)
+		INSERT INTO usuario (nome, cpf, email, password, lotacao, matricula, admin, troca_senha_obrigatoria)
+		VALUES ('Administrador', '000.000.000-00', $2, $1, 'Admin', 'admin', true, true)
+	`, string(hashedPassword), emailAdministradorPadrao)
 	if err != nil {
 		return err
 	}
-	log.Println("Tabela 'bem_direito_valor_ccs' verificada/criada com sucesso")
+	log.Println("Usuário administrador padrão criado com sucesso")
+	return nil
+}
 
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS vinculados_bdv_ccs (
-			id SERIAL PRIMARY KEY,
-			id_bdv INT NOT NULL,
-			data_inicio VARCHAR(50),
-			data_fim VARCHAR(50),
-			id_pessoa VARCHAR(20),
-			nome_pessoa VARCHAR(255),
-			nome_pessoa_receita VARCHAR(255),
-			tipo VARCHAR(50),
-			FOREIGN KEY (id_bdv) REFERENCES bem_direito_valor_ccs(id) ON DELETE CASCADE
-		)
-	`)
+// emailAdministradorPadrao é o login do administrador criado na instalação
+const emailAdministradorPadrao = "admin@admin.com"
+
+// exigirTrocaSenhaPadrao marca para troca a senha "admin" do administrador
+// padrão criado por versões anteriores
+func exigirTrocaSenhaPadrao(db *sql.DB) error {
+	var id int
+	var hash string
+	err := db.QueryRow(`
+		SELECT id, password FROM usuario
+		WHERE email = $1 AND origem = 'local' AND NOT troca_senha_obrigatoria
+	`, emailAdministradorPadrao).Scan(&id, &hash)
+	if err == sql.ErrNoRows {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	log.Println("Tabela 'vinculados_bdv_ccs' verificada/criada com sucesso")
-
-	log.Println("Todas as migrações executadas com sucesso!")
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte("admin")) != nil {
+		return nil
+	}
+	if _, err := db.Exec(`UPDATE usuario SET troca_senha_obrigatoria = TRUE WHERE id = $1`, id); err != nil {
+		return err
+	}
+	log.Println("AVISO: o administrador padrão ainda usa a senha admin e precisará trocá-la no próximo login")
 	return nil
-}
\ No newline at end of file
+}
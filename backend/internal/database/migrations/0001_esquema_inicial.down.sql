@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS vinculados_bdv_ccs;
+DROP TABLE IF EXISTS bem_direito_valor_ccs;
+DROP TABLE IF EXISTS relacionamento_ccs;
+DROP TABLE IF EXISTS requisicao_relacionamento_ccs;
+DROP TABLE IF EXISTS evento_chave_pix;
+DROP TABLE IF EXISTS chave_pix;
+DROP TABLE IF EXISTS requisicao_pix;
+DROP TABLE IF EXISTS usuario;
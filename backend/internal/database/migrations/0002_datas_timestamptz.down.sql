@@ -0,0 +1,42 @@
+SET LOCAL TIME ZONE 'America/Sao_Paulo';
+
+ALTER TABLE vinculados_bdv_ccs
+	ALTER COLUMN data_inicio TYPE VARCHAR(50) USING consultapix_formatar_data(data_inicio),
+	ALTER COLUMN data_fim TYPE VARCHAR(50) USING consultapix_formatar_data(data_fim);
+
+ALTER TABLE bem_direito_valor_ccs
+	ALTER COLUMN data_inicio TYPE VARCHAR(50) USING consultapix_formatar_data(data_inicio),
+	ALTER COLUMN data_fim TYPE VARCHAR(50) USING consultapix_formatar_data(data_fim);
+
+ALTER TABLE relacionamento_ccs
+	ALTER COLUMN data_inicio_relacionamento TYPE VARCHAR(50) USING consultapix_formatar_data(data_inicio_relacionamento),
+	ALTER COLUMN data_fim_relacionamento TYPE VARCHAR(50) USING consultapix_formatar_data(data_fim_relacionamento),
+	ALTER COLUMN data_requisicao_detalhamento TYPE VARCHAR(50) USING consultapix_formatar_data(data_requisicao_detalhamento);
+
+ALTER TABLE requisicao_relacionamento_ccs
+	ALTER COLUMN data_requisicao TYPE VARCHAR(50) USING consultapix_formatar_data(data_requisicao),
+	ALTER COLUMN data_inicio_consulta TYPE VARCHAR(50) USING consultapix_formatar_data(data_inicio_consulta),
+	ALTER COLUMN data_fim_consulta TYPE VARCHAR(50) USING consultapix_formatar_data(data_fim_consulta),
+	ALTER COLUMN data_hora_autorizacao TYPE VARCHAR(50) USING consultapix_formatar_data(data_hora_autorizacao);
+
+UPDATE requisicao_relacionamento_ccs SET data_requisicao = '' WHERE data_requisicao IS NULL;
+ALTER TABLE requisicao_relacionamento_ccs ALTER COLUMN data_requisicao SET NOT NULL;
+
+ALTER TABLE evento_chave_pix
+	ALTER COLUMN data_evento TYPE VARCHAR(50) USING consultapix_formatar_data(data_evento),
+	ALTER COLUMN data_abertura_conta TYPE VARCHAR(50) USING consultapix_formatar_data(data_abertura_conta);
+
+ALTER TABLE chave_pix
+	ALTER COLUMN data_abertura_reivindicacao TYPE VARCHAR(50) USING consultapix_formatar_data(data_abertura_reivindicacao),
+	ALTER COLUMN data_abertura_conta TYPE VARCHAR(50) USING consultapix_formatar_data(data_abertura_conta),
+	ALTER COLUMN proprietario_da_chave_desde TYPE VARCHAR(50) USING consultapix_formatar_data(proprietario_da_chave_desde),
+	ALTER COLUMN data_criacao TYPE VARCHAR(50) USING consultapix_formatar_data(data_criacao),
+	ALTER COLUMN ultima_modificacao TYPE VARCHAR(50) USING consultapix_formatar_data(ultima_modificacao);
+
+ALTER TABLE requisicao_pix
+	ALTER COLUMN data TYPE TIMESTAMP USING data AT TIME ZONE 'UTC',
+	ALTER COLUMN data_hora_autorizacao TYPE VARCHAR(50) USING consultapix_formatar_data(data_hora_autorizacao);
+
+DROP FUNCTION IF EXISTS consultapix_formatar_data(TIMESTAMPTZ);
+DROP FUNCTION IF EXISTS consultapix_data_reconhecida(TEXT);
+DROP FUNCTION IF EXISTS consultapix_converter_data(TEXT);
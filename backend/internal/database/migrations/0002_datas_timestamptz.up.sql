@@ -0,0 +1,134 @@
+-- Converte as colunas de data armazenadas como VARCHAR(50) para TIMESTAMPTZ.
+-- Datas sem fuso informado vêm do BACEN e são interpretadas no horário de Brasília.
+SET LOCAL TIME ZONE 'America/Sao_Paulo';
+
+-- consultapix_converter_data interpreta os formatos de data usados pelo BACEN
+-- (ISO 8601 com ou sem hora/fuso, dd/mm/aaaa e aaaammdd). Textos vazios viram
+-- NULL; um formato não reconhecido é erro, para não perder a data.
+CREATE OR REPLACE FUNCTION consultapix_converter_data(valor TEXT) RETURNS TIMESTAMPTZ AS $$
+DECLARE
+	texto TEXT := btrim(valor);
+BEGIN
+	IF texto IS NULL OR texto = '' THEN
+		RETURN NULL;
+	END IF;
+
+	IF texto ~ '^\d{2}/\d{2}/\d{4} \d{2}:\d{2}:\d{2}' THEN
+		RETURN to_timestamp(substr(texto, 1, 19), 'DD/MM/YYYY HH24:MI:SS');
+	ELSIF texto ~ '^\d{2}/\d{2}/\d{4} \d{2}:\d{2}' THEN
+		RETURN to_timestamp(substr(texto, 1, 16), 'DD/MM/YYYY HH24:MI');
+	ELSIF texto ~ '^\d{2}/\d{2}/\d{4}$' THEN
+		RETURN to_timestamp(texto, 'DD/MM/YYYY');
+	END IF;
+
+	RETURN texto::TIMESTAMPTZ;
+EXCEPTION WHEN OTHERS THEN
+	RAISE EXCEPTION 'data em formato não reconhecido: %', valor;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+-- consultapix_data_reconhecida indica se consultapix_converter_data aceita o valor
+CREATE OR REPLACE FUNCTION consultapix_data_reconhecida(valor TEXT) RETURNS BOOLEAN AS $$
+BEGIN
+	PERFORM consultapix_converter_data(valor);
+	RETURN TRUE;
+EXCEPTION WHEN OTHERS THEN
+	RETURN FALSE;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+-- Antes de converter, confere todas as colunas e interrompe a migração
+-- listando as linhas com datas ilegíveis, que precisam ser corrigidas à mão.
+DO $$
+DECLARE
+	coluna RECORD;
+	linha RECORD;
+	problemas TEXT[] := '{}';
+	total INT := 0;
+BEGIN
+	FOR coluna IN SELECT * FROM (VALUES
+		('requisicao_pix', 'data_hora_autorizacao'),
+		('chave_pix', 'data_abertura_reivindicacao'),
+		('chave_pix', 'data_abertura_conta'),
+		('chave_pix', 'proprietario_da_chave_desde'),
+		('chave_pix', 'data_criacao'),
+		('chave_pix', 'ultima_modificacao'),
+		('evento_chave_pix', 'data_evento'),
+		('evento_chave_pix', 'data_abertura_conta'),
+		('requisicao_relacionamento_ccs', 'data_requisicao'),
+		('requisicao_relacionamento_ccs', 'data_inicio_consulta'),
+		('requisicao_relacionamento_ccs', 'data_fim_consulta'),
+		('requisicao_relacionamento_ccs', 'data_hora_autorizacao'),
+		('relacionamento_ccs', 'data_inicio_relacionamento'),
+		('relacionamento_ccs', 'data_fim_relacionamento'),
+		('relacionamento_ccs', 'data_requisicao_detalhamento'),
+		('bem_direito_valor_ccs', 'data_inicio'),
+		('bem_direito_valor_ccs', 'data_fim'),
+		('vinculados_bdv_ccs', 'data_inicio'),
+		('vinculados_bdv_ccs', 'data_fim')
+	) AS c(tabela, nome) LOOP
+		FOR linha IN EXECUTE format(
+			'SELECT id, %1$I AS valor FROM %2$I WHERE NOT consultapix_data_reconhecida(%1$I) ORDER BY id',
+			coluna.nome, coluna.tabela
+		) LOOP
+			total := total + 1;
+			IF total <= 50 THEN
+				problemas := problemas || format('%s.%s id %s: %L', coluna.tabela, coluna.nome, linha.id, linha.valor);
+			END IF;
+		END LOOP;
+	END LOOP;
+
+	IF total > 0 THEN
+		RAISE EXCEPTION '% data(s) em formato não reconhecido, corrija-as e rode a migração de novo (até 50 listadas): %',
+			total, array_to_string(problemas, '; ');
+	END IF;
+END;
+$$;
+
+-- consultapix_formatar_data devolve a data no horário de Brasília em ISO 8601,
+-- omitindo a hora quando ela é meia-noite (datas puras do BACEN). NULL vira ''.
+CREATE OR REPLACE FUNCTION consultapix_formatar_data(valor TIMESTAMPTZ) RETURNS TEXT AS $$
+	SELECT CASE
+		WHEN valor IS NULL THEN ''
+		WHEN (valor AT TIME ZONE 'America/Sao_Paulo')::TIME = '00:00:00' THEN
+			to_char(valor AT TIME ZONE 'America/Sao_Paulo', 'YYYY-MM-DD')
+		ELSE to_char(valor AT TIME ZONE 'America/Sao_Paulo', 'YYYY-MM-DD"T"HH24:MI:SS')
+	END
+$$ LANGUAGE sql STABLE;
+
+-- requisicao_pix.data era TIMESTAMP gravado pelo servidor em UTC.
+ALTER TABLE requisicao_pix
+	ALTER COLUMN data TYPE TIMESTAMPTZ USING data AT TIME ZONE 'UTC',
+	ALTER COLUMN data_hora_autorizacao TYPE TIMESTAMPTZ USING consultapix_converter_data(data_hora_autorizacao);
+
+ALTER TABLE chave_pix
+	ALTER COLUMN data_abertura_reivindicacao TYPE TIMESTAMPTZ USING consultapix_converter_data(data_abertura_reivindicacao),
+	ALTER COLUMN data_abertura_conta TYPE TIMESTAMPTZ USING consultapix_converter_data(data_abertura_conta),
+	ALTER COLUMN proprietario_da_chave_desde TYPE TIMESTAMPTZ USING consultapix_converter_data(proprietario_da_chave_desde),
+	ALTER COLUMN data_criacao TYPE TIMESTAMPTZ USING consultapix_converter_data(data_criacao),
+	ALTER COLUMN ultima_modificacao TYPE TIMESTAMPTZ USING consultapix_converter_data(ultima_modificacao);
+
+ALTER TABLE evento_chave_pix
+	ALTER COLUMN data_evento TYPE TIMESTAMPTZ USING consultapix_converter_data(data_evento),
+	ALTER COLUMN data_abertura_conta TYPE TIMESTAMPTZ USING consultapix_converter_data(data_abertura_conta);
+
+-- data_requisicao deixa de ser NOT NULL: o BACEN pode devolvê-la vazia.
+ALTER TABLE requisicao_relacionamento_ccs
+	ALTER COLUMN data_requisicao DROP NOT NULL,
+	ALTER COLUMN data_requisicao TYPE TIMESTAMPTZ USING consultapix_converter_data(data_requisicao),
+	ALTER COLUMN data_inicio_consulta TYPE TIMESTAMPTZ USING consultapix_converter_data(data_inicio_consulta),
+	ALTER COLUMN data_fim_consulta TYPE TIMESTAMPTZ USING consultapix_converter_data(data_fim_consulta),
+	ALTER COLUMN data_hora_autorizacao TYPE TIMESTAMPTZ USING consultapix_converter_data(data_hora_autorizacao);
+
+ALTER TABLE relacionamento_ccs
+	ALTER COLUMN data_inicio_relacionamento TYPE TIMESTAMPTZ USING consultapix_converter_data(data_inicio_relacionamento),
+	ALTER COLUMN data_fim_relacionamento TYPE TIMESTAMPTZ USING consultapix_converter_data(data_fim_relacionamento),
+	ALTER COLUMN data_requisicao_detalhamento TYPE TIMESTAMPTZ USING consultapix_converter_data(data_requisicao_detalhamento);
+
+ALTER TABLE bem_direito_valor_ccs
+	ALTER COLUMN data_inicio TYPE TIMESTAMPTZ USING consultapix_converter_data(data_inicio),
+	ALTER COLUMN data_fim TYPE TIMESTAMPTZ USING consultapix_converter_data(data_fim);
+
+ALTER TABLE vinculados_bdv_ccs
+	ALTER COLUMN data_inicio TYPE TIMESTAMPTZ USING consultapix_converter_data(data_inicio),
+	ALTER COLUMN data_fim TYPE TIMESTAMPTZ USING consultapix_converter_data(data_fim);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_bdv_ccs_data_inicio;
+DROP INDEX IF EXISTS idx_relacionamento_ccs_data_inicio;
+DROP INDEX IF EXISTS idx_evento_chave_pix_data_evento;
+DROP INDEX IF EXISTS idx_requisicao_ccs_data_requisicao;
+DROP INDEX IF EXISTS idx_requisicao_pix_data;
@@ -0,0 +1,6 @@
+-- Índices para ordenação e filtros por período sobre as novas colunas TIMESTAMPTZ
+CREATE INDEX IF NOT EXISTS idx_requisicao_pix_data ON requisicao_pix (data);
+CREATE INDEX IF NOT EXISTS idx_requisicao_ccs_data_requisicao ON requisicao_relacionamento_ccs (data_requisicao);
+CREATE INDEX IF NOT EXISTS idx_evento_chave_pix_data_evento ON evento_chave_pix (data_evento);
+CREATE INDEX IF NOT EXISTS idx_relacionamento_ccs_data_inicio ON relacionamento_ccs (data_inicio_relacionamento);
+CREATE INDEX IF NOT EXISTS idx_bdv_ccs_data_inicio ON bem_direito_valor_ccs (data_inicio);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_vinculados_bdv_ccs_bdv;
+DROP INDEX IF EXISTS idx_bdv_ccs_relacionamento;
+DROP INDEX IF EXISTS idx_relacionamento_ccs_requisicao;
+DROP INDEX IF EXISTS idx_evento_chave_pix_chave;
+DROP INDEX IF EXISTS idx_chave_pix_requisicao;
+DROP INDEX IF EXISTS idx_requisicao_ccs_cpf_cnpj_consulta;
+DROP INDEX IF EXISTS idx_requisicao_ccs_responsavel;
+DROP INDEX IF EXISTS idx_requisicao_pix_chave_busca;
+DROP INDEX IF EXISTS idx_requisicao_pix_responsavel_data;
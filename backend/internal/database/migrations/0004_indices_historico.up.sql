@@ -0,0 +1,12 @@
+-- Índices para as listagens de histórico e para a montagem das árvores de detalhe
+CREATE INDEX IF NOT EXISTS idx_requisicao_pix_responsavel_data ON requisicao_pix (cpf_responsavel, data DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_requisicao_pix_chave_busca ON requisicao_pix (chave_busca);
+CREATE INDEX IF NOT EXISTS idx_requisicao_ccs_responsavel ON requisicao_relacionamento_ccs (cpf_responsavel, id DESC);
+CREATE INDEX IF NOT EXISTS idx_requisicao_ccs_cpf_cnpj_consulta ON requisicao_relacionamento_ccs (cpf_cnpj_consulta);
+
+-- Chaves estrangeiras usadas nas subconsultas json_agg
+CREATE INDEX IF NOT EXISTS idx_chave_pix_requisicao ON chave_pix (id_requisicao);
+CREATE INDEX IF NOT EXISTS idx_evento_chave_pix_chave ON evento_chave_pix (id_chave);
+CREATE INDEX IF NOT EXISTS idx_relacionamento_ccs_requisicao ON relacionamento_ccs (id_requisicao);
+CREATE INDEX IF NOT EXISTS idx_bdv_ccs_relacionamento ON bem_direito_valor_ccs (id_relacionamento);
+CREATE INDEX IF NOT EXISTS idx_vinculados_bdv_ccs_bdv ON vinculados_bdv_ccs (id_bdv);
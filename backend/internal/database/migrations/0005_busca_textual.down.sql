@@ -0,0 +1,24 @@
+DROP INDEX IF EXISTS idx_fts_vinculados_bdv_ccs_nomes;
+DROP INDEX IF EXISTS idx_fts_bdv_ccs_nomes;
+DROP INDEX IF EXISTS idx_fts_relacionamento_ccs_nomes;
+DROP INDEX IF EXISTS idx_fts_evento_chave_pix_nomes;
+DROP INDEX IF EXISTS idx_fts_chave_pix_nomes;
+
+DROP INDEX IF EXISTS idx_trgm_vinculados_bdv_ccs_nome_pessoa_receita;
+DROP INDEX IF EXISTS idx_trgm_vinculados_bdv_ccs_nome_pessoa;
+DROP INDEX IF EXISTS idx_trgm_bdv_ccs_conta;
+DROP INDEX IF EXISTS idx_trgm_bdv_ccs_agencia;
+DROP INDEX IF EXISTS idx_trgm_bdv_ccs_nome_pessoa;
+DROP INDEX IF EXISTS idx_trgm_relacionamento_ccs_nome_pessoa;
+DROP INDEX IF EXISTS idx_trgm_evento_chave_pix_numero_conta;
+DROP INDEX IF EXISTS idx_trgm_evento_chave_pix_agencia;
+DROP INDEX IF EXISTS idx_trgm_evento_chave_pix_chave;
+DROP INDEX IF EXISTS idx_trgm_evento_chave_pix_nome_fantasia;
+DROP INDEX IF EXISTS idx_trgm_evento_chave_pix_nome_proprietario;
+DROP INDEX IF EXISTS idx_trgm_chave_pix_numero_conta;
+DROP INDEX IF EXISTS idx_trgm_chave_pix_agencia;
+DROP INDEX IF EXISTS idx_trgm_chave_pix_chave;
+DROP INDEX IF EXISTS idx_trgm_chave_pix_nome_fantasia;
+DROP INDEX IF EXISTS idx_trgm_chave_pix_nome_proprietario;
+
+-- A extensão pg_trgm é mantida: pode estar em uso por outros objetos do banco
@@ -0,0 +1,37 @@
+-- Busca textual e aproximada sobre os dados financeiros armazenados
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Índices trigram (ILIKE e word_similarity) por coluna pesquisável
+CREATE INDEX IF NOT EXISTS idx_trgm_chave_pix_nome_proprietario ON chave_pix USING gin (nome_proprietario gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_trgm_chave_pix_nome_fantasia ON chave_pix USING gin (nome_fantasia gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_trgm_chave_pix_chave ON chave_pix USING gin (chave gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_trgm_chave_pix_agencia ON chave_pix USING gin (agencia gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_trgm_chave_pix_numero_conta ON chave_pix USING gin (numero_conta gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_trgm_evento_chave_pix_nome_proprietario ON evento_chave_pix USING gin (nome_proprietario gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_trgm_evento_chave_pix_nome_fantasia ON evento_chave_pix USING gin (nome_fantasia gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_trgm_evento_chave_pix_chave ON evento_chave_pix USING gin (chave gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_trgm_evento_chave_pix_agencia ON evento_chave_pix USING gin (agencia gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_trgm_evento_chave_pix_numero_conta ON evento_chave_pix USING gin (numero_conta gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_trgm_relacionamento_ccs_nome_pessoa ON relacionamento_ccs USING gin (nome_pessoa gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_trgm_bdv_ccs_nome_pessoa ON bem_direito_valor_ccs USING gin (nome_pessoa gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_trgm_bdv_ccs_agencia ON bem_direito_valor_ccs USING gin (agencia gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_trgm_bdv_ccs_conta ON bem_direito_valor_ccs USING gin (conta gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_trgm_vinculados_bdv_ccs_nome_pessoa ON vinculados_bdv_ccs USING gin (nome_pessoa gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_trgm_vinculados_bdv_ccs_nome_pessoa_receita ON vinculados_bdv_ccs USING gin (nome_pessoa_receita gin_trgm_ops);
+
+-- Índices tsvector sobre os nomes. As expressões precisam ser idênticas às
+-- usadas em repository.BuscaRepository para que o planejador as aproveite.
+CREATE INDEX IF NOT EXISTS idx_fts_chave_pix_nomes ON chave_pix
+	USING gin (to_tsvector('simple', coalesce(nome_proprietario, '') || ' ' || coalesce(nome_fantasia, '')));
+CREATE INDEX IF NOT EXISTS idx_fts_evento_chave_pix_nomes ON evento_chave_pix
+	USING gin (to_tsvector('simple', coalesce(nome_proprietario, '') || ' ' || coalesce(nome_fantasia, '')));
+CREATE INDEX IF NOT EXISTS idx_fts_relacionamento_ccs_nomes ON relacionamento_ccs
+	USING gin (to_tsvector('simple', coalesce(nome_pessoa, '')));
+CREATE INDEX IF NOT EXISTS idx_fts_bdv_ccs_nomes ON bem_direito_valor_ccs
+	USING gin (to_tsvector('simple', coalesce(nome_pessoa, '')));
+CREATE INDEX IF NOT EXISTS idx_fts_vinculados_bdv_ccs_nomes ON vinculados_bdv_ccs
+	USING gin (to_tsvector('simple', coalesce(nome_pessoa, '') || ' ' || coalesce(nome_pessoa_receita, '')));
@@ -0,0 +1,9 @@
+ALTER TABLE vinculados_bdv_ccs DROP COLUMN IF EXISTS pessoa_id;
+ALTER TABLE relacionamento_ccs DROP COLUMN IF EXISTS pessoa_id;
+ALTER TABLE requisicao_relacionamento_ccs DROP COLUMN IF EXISTS pessoa_id;
+ALTER TABLE evento_chave_pix DROP COLUMN IF EXISTS pessoa_id;
+ALTER TABLE chave_pix DROP COLUMN IF EXISTS pessoa_busca_id, DROP COLUMN IF EXISTS pessoa_id;
+
+DROP TABLE IF EXISTS pessoa_atributo;
+DROP TABLE IF EXISTS pessoa;
+DROP FUNCTION IF EXISTS consultapix_normalizar_documento(TEXT);
@@ -0,0 +1,173 @@
+-- Entidade "pessoa": consolida, por documento normalizado, todos os CPFs/CNPJs
+-- que aparecem nas consultas PIX e CCS
+
+-- consultapix_normalizar_documento mantém apenas os dígitos e completa com zeros
+-- à esquerda até 11 (CPF) ou 14 (CNPJ). Deve seguir repository.NormalizarDocumento.
+CREATE OR REPLACE FUNCTION consultapix_normalizar_documento(valor TEXT) RETURNS TEXT AS $$
+	SELECT CASE
+		WHEN d = '' OR d ~ '^0+$' THEN NULL
+		WHEN length(d) <= 11 THEN lpad(d, 11, '0')
+		WHEN length(d) <= 14 THEN lpad(d, 14, '0')
+		ELSE NULL
+	END
+	FROM (SELECT regexp_replace(coalesce(valor, ''), '\D', '', 'g') AS d) s
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE TABLE pessoa (
+	id SERIAL PRIMARY KEY,
+	documento VARCHAR(14) NOT NULL UNIQUE,
+	tipo_pessoa VARCHAR(2) NOT NULL,
+	nome_principal VARCHAR(255),
+	primeira_ocorrencia TIMESTAMPTZ NOT NULL,
+	ultima_ocorrencia TIMESTAMPTZ NOT NULL
+);
+
+-- Nomes, chaves, contas e instituições conhecidos de cada pessoa
+CREATE TABLE pessoa_atributo (
+	id SERIAL PRIMARY KEY,
+	id_pessoa INT NOT NULL REFERENCES pessoa(id) ON DELETE CASCADE,
+	tipo VARCHAR(20) NOT NULL,
+	valor VARCHAR(255) NOT NULL,
+	fontes TEXT[] NOT NULL DEFAULT '{}',
+	primeira_ocorrencia TIMESTAMPTZ NOT NULL,
+	ultima_ocorrencia TIMESTAMPTZ NOT NULL,
+	ocorrencias INT NOT NULL DEFAULT 1,
+	UNIQUE (id_pessoa, tipo, valor)
+);
+
+-- As colunas id_pessoa de relacionamento_ccs e vinculados_bdv_ccs já guardam o
+-- documento vindo do BACEN, por isso a referência à entidade se chama pessoa_id
+ALTER TABLE chave_pix
+	ADD COLUMN pessoa_id INT REFERENCES pessoa(id) ON DELETE SET NULL,
+	ADD COLUMN pessoa_busca_id INT REFERENCES pessoa(id) ON DELETE SET NULL;
+ALTER TABLE evento_chave_pix ADD COLUMN pessoa_id INT REFERENCES pessoa(id) ON DELETE SET NULL;
+ALTER TABLE requisicao_relacionamento_ccs ADD COLUMN pessoa_id INT REFERENCES pessoa(id) ON DELETE SET NULL;
+ALTER TABLE relacionamento_ccs ADD COLUMN pessoa_id INT REFERENCES pessoa(id) ON DELETE SET NULL;
+ALTER TABLE vinculados_bdv_ccs ADD COLUMN pessoa_id INT REFERENCES pessoa(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_chave_pix_pessoa ON chave_pix (pessoa_id);
+CREATE INDEX idx_chave_pix_pessoa_busca ON chave_pix (pessoa_busca_id);
+CREATE INDEX idx_evento_chave_pix_pessoa ON evento_chave_pix (pessoa_id);
+CREATE INDEX idx_requisicao_ccs_pessoa ON requisicao_relacionamento_ccs (pessoa_id);
+CREATE INDEX idx_relacionamento_ccs_pessoa ON relacionamento_ccs (pessoa_id);
+CREATE INDEX idx_vinculados_bdv_ccs_pessoa ON vinculados_bdv_ccs (pessoa_id);
+
+-- Carga inicial a partir dos dados já armazenados
+CREATE TEMP TABLE ocorrencia_pessoa ON COMMIT DROP AS
+SELECT consultapix_normalizar_documento(o.documento) AS documento, NULLIF(btrim(o.nome), '') AS nome, o.visto, o.fonte
+FROM (
+	SELECT c.cpf_cnpj AS documento, c.nome_proprietario AS nome, r.data AS visto, 'chave_pix' AS fonte
+	FROM chave_pix c JOIN requisicao_pix r ON r.id = c.id_requisicao
+	UNION ALL
+	SELECT c.cpf_cnpj_busca, c.nome_proprietario_busca, r.data, 'chave_pix'
+	FROM chave_pix c JOIN requisicao_pix r ON r.id = c.id_requisicao
+	UNION ALL
+	SELECT e.cpf_cnpj, e.nome_proprietario, r.data, 'evento_chave_pix'
+	FROM evento_chave_pix e
+	JOIN chave_pix c ON c.id = e.id_chave
+	JOIN requisicao_pix r ON r.id = c.id_requisicao
+	UNION ALL
+	SELECT COALESCE(NULLIF(q.cpf_cnpj, ''), q.cpf_cnpj_consulta), q.nome, COALESCE(q.data_requisicao, NOW()), 'requisicao_relacionamento_ccs'
+	FROM requisicao_relacionamento_ccs q
+	UNION ALL
+	SELECT rc.id_pessoa, rc.nome_pessoa, COALESCE(q.data_requisicao, NOW()), 'relacionamento_ccs'
+	FROM relacionamento_ccs rc JOIN requisicao_relacionamento_ccs q ON q.id = rc.id_requisicao
+	UNION ALL
+	SELECT v.id_pessoa, v.nome_pessoa, COALESCE(q.data_requisicao, NOW()), 'vinculados_bdv_ccs'
+	FROM vinculados_bdv_ccs v
+	JOIN bem_direito_valor_ccs b ON b.id = v.id_bdv
+	JOIN relacionamento_ccs rc ON rc.id = b.id_relacionamento
+	JOIN requisicao_relacionamento_ccs q ON q.id = rc.id_requisicao
+	UNION ALL
+	SELECT v.id_pessoa, v.nome_pessoa_receita, COALESCE(q.data_requisicao, NOW()), 'vinculados_bdv_ccs'
+	FROM vinculados_bdv_ccs v
+	JOIN bem_direito_valor_ccs b ON b.id = v.id_bdv
+	JOIN relacionamento_ccs rc ON rc.id = b.id_relacionamento
+	JOIN requisicao_relacionamento_ccs q ON q.id = rc.id_requisicao
+) o
+WHERE consultapix_normalizar_documento(o.documento) IS NOT NULL;
+
+INSERT INTO pessoa (documento, tipo_pessoa, nome_principal, primeira_ocorrencia, ultima_ocorrencia)
+SELECT documento,
+	CASE WHEN length(documento) = 11 THEN 'PF' ELSE 'PJ' END,
+	(array_agg(nome ORDER BY visto DESC) FILTER (WHERE nome IS NOT NULL))[1],
+	MIN(visto), MAX(visto)
+FROM ocorrencia_pessoa
+GROUP BY documento;
+
+UPDATE chave_pix SET pessoa_id = p.id FROM pessoa p WHERE p.documento = consultapix_normalizar_documento(chave_pix.cpf_cnpj);
+UPDATE chave_pix SET pessoa_busca_id = p.id FROM pessoa p WHERE p.documento = consultapix_normalizar_documento(chave_pix.cpf_cnpj_busca);
+UPDATE evento_chave_pix SET pessoa_id = p.id FROM pessoa p WHERE p.documento = consultapix_normalizar_documento(evento_chave_pix.cpf_cnpj);
+UPDATE requisicao_relacionamento_ccs SET pessoa_id = p.id FROM pessoa p
+	WHERE p.documento = consultapix_normalizar_documento(COALESCE(NULLIF(requisicao_relacionamento_ccs.cpf_cnpj, ''), requisicao_relacionamento_ccs.cpf_cnpj_consulta));
+UPDATE relacionamento_ccs SET pessoa_id = p.id FROM pessoa p WHERE p.documento = consultapix_normalizar_documento(relacionamento_ccs.id_pessoa);
+UPDATE vinculados_bdv_ccs SET pessoa_id = p.id FROM pessoa p WHERE p.documento = consultapix_normalizar_documento(vinculados_bdv_ccs.id_pessoa);
+
+-- Atributos. Os formatos de conta e instituição seguem repository.formatarConta
+-- e repository.nomeInstituicao.
+CREATE TEMP TABLE ocorrencia_atributo ON COMMIT DROP AS
+SELECT o.id_pessoa, o.tipo, o.valor, o.fonte, o.visto
+FROM (
+	SELECT p.id AS id_pessoa, 'nome' AS tipo, op.nome AS valor, op.fonte, op.visto
+	FROM ocorrencia_pessoa op JOIN pessoa p ON p.documento = op.documento
+	WHERE op.nome IS NOT NULL
+	UNION ALL
+	SELECT c.pessoa_id, 'chave_pix', NULLIF(btrim(c.chave), ''), 'chave_pix', r.data
+	FROM chave_pix c JOIN requisicao_pix r ON r.id = c.id_requisicao
+	UNION ALL
+	SELECT c.pessoa_id, 'conta',
+		CASE WHEN NULLIF(btrim(c.numero_conta), '') IS NULL THEN NULL
+		ELSE concat_ws(' / ', NULLIF(btrim(c.participante), ''), NULLIF(btrim(c.agencia), ''), btrim(c.numero_conta)) END,
+		'chave_pix', r.data
+	FROM chave_pix c JOIN requisicao_pix r ON r.id = c.id_requisicao
+	UNION ALL
+	SELECT c.pessoa_id, 'instituicao',
+		CASE WHEN NULLIF(btrim(c.nome_banco), '') IS NULL OR c.nome_banco = 'BANCO NÃO INFORMADO'
+		THEN NULLIF(btrim(c.participante), '') ELSE btrim(c.nome_banco) END,
+		'chave_pix', r.data
+	FROM chave_pix c JOIN requisicao_pix r ON r.id = c.id_requisicao
+	UNION ALL
+	SELECT e.pessoa_id, 'chave_pix', NULLIF(btrim(e.chave), ''), 'evento_chave_pix', r.data
+	FROM evento_chave_pix e JOIN chave_pix c ON c.id = e.id_chave JOIN requisicao_pix r ON r.id = c.id_requisicao
+	UNION ALL
+	SELECT e.pessoa_id, 'conta',
+		CASE WHEN NULLIF(btrim(e.numero_conta), '') IS NULL THEN NULL
+		ELSE concat_ws(' / ', NULLIF(btrim(e.participante), ''), NULLIF(btrim(e.agencia), ''), btrim(e.numero_conta)) END,
+		'evento_chave_pix', r.data
+	FROM evento_chave_pix e JOIN chave_pix c ON c.id = e.id_chave JOIN requisicao_pix r ON r.id = c.id_requisicao
+	UNION ALL
+	SELECT e.pessoa_id, 'instituicao',
+		CASE WHEN NULLIF(btrim(e.nome_banco), '') IS NULL OR e.nome_banco = 'BANCO NÃO INFORMADO'
+		THEN NULLIF(btrim(e.participante), '') ELSE btrim(e.nome_banco) END,
+		'evento_chave_pix', r.data
+	FROM evento_chave_pix e JOIN chave_pix c ON c.id = e.id_chave JOIN requisicao_pix r ON r.id = c.id_requisicao
+	UNION ALL
+	SELECT rc.pessoa_id, 'instituicao',
+		CASE WHEN NULLIF(btrim(rc.nome_banco_participante), '') IS NULL OR rc.nome_banco_participante = 'BANCO NÃO INFORMADO'
+		THEN NULLIF(btrim(rc.cnpj_participante), '') ELSE btrim(rc.nome_banco_participante) END,
+		'relacionamento_ccs', COALESCE(q.data_requisicao, NOW())
+	FROM relacionamento_ccs rc JOIN requisicao_relacionamento_ccs q ON q.id = rc.id_requisicao
+	UNION ALL
+	SELECT rc.pessoa_id, 'conta',
+		CASE WHEN NULLIF(btrim(b.conta), '') IS NULL THEN NULL
+		ELSE concat_ws(' / ', NULLIF(btrim(b.cnpj_participante), ''), NULLIF(btrim(b.agencia), ''), btrim(b.conta)) END,
+		'bem_direito_valor_ccs', COALESCE(q.data_requisicao, NOW())
+	FROM bem_direito_valor_ccs b
+	JOIN relacionamento_ccs rc ON rc.id = b.id_relacionamento
+	JOIN requisicao_relacionamento_ccs q ON q.id = rc.id_requisicao
+	UNION ALL
+	SELECT v.pessoa_id, 'conta',
+		CASE WHEN NULLIF(btrim(b.conta), '') IS NULL THEN NULL
+		ELSE concat_ws(' / ', NULLIF(btrim(b.cnpj_participante), ''), NULLIF(btrim(b.agencia), ''), btrim(b.conta)) END,
+		'vinculados_bdv_ccs', COALESCE(q.data_requisicao, NOW())
+	FROM vinculados_bdv_ccs v
+	JOIN bem_direito_valor_ccs b ON b.id = v.id_bdv
+	JOIN relacionamento_ccs rc ON rc.id = b.id_relacionamento
+	JOIN requisicao_relacionamento_ccs q ON q.id = rc.id_requisicao
+) o
+WHERE o.id_pessoa IS NOT NULL AND o.valor IS NOT NULL;
+
+INSERT INTO pessoa_atributo (id_pessoa, tipo, valor, fontes, primeira_ocorrencia, ultima_ocorrencia, ocorrencias)
+SELECT id_pessoa, tipo, left(valor, 255), array_agg(DISTINCT fonte), MIN(visto), MAX(visto), COUNT(*)
+FROM ocorrencia_atributo
+GROUP BY id_pessoa, tipo, left(valor, 255);
@@ -0,0 +1,7 @@
+ALTER TABLE bem_direito_valor_ccs DROP COLUMN IF EXISTS conta_id;
+ALTER TABLE evento_chave_pix DROP COLUMN IF EXISTS conta_id;
+ALTER TABLE chave_pix DROP COLUMN IF EXISTS conta_id;
+
+DROP TABLE IF EXISTS conta;
+DROP FUNCTION IF EXISTS consultapix_normalizar_conta(TEXT);
+DROP FUNCTION IF EXISTS consultapix_normalizar_ispb(TEXT);
@@ -0,0 +1,82 @@
+-- Entidade "conta": consolida as contas citadas em chaves PIX, eventos de chave
+-- e BDVs do CCS pela chave (ISPB, agência, número)
+
+-- consultapix_normalizar_ispb aceita o ISPB (8 dígitos, PIX) ou o CNPJ do
+-- participante (CCS), cuja raiz é o ISPB. Deve seguir repository.NormalizarISPB.
+CREATE OR REPLACE FUNCTION consultapix_normalizar_ispb(valor TEXT) RETURNS TEXT AS $$
+	SELECT CASE
+		WHEN d = '' THEN NULL
+		WHEN length(d) <= 8 THEN lpad(d, 8, '0')
+		WHEN length(d) <= 14 THEN left(lpad(d, 14, '0'), 8)
+		ELSE NULL
+	END
+	FROM (SELECT regexp_replace(coalesce(valor, ''), '\D', '', 'g') AS d) s
+$$ LANGUAGE sql IMMUTABLE;
+
+-- consultapix_normalizar_conta remove pontuação e zeros à esquerda de agências e
+-- números de conta. Deve seguir repository.NormalizarNumeroConta.
+CREATE OR REPLACE FUNCTION consultapix_normalizar_conta(valor TEXT) RETURNS TEXT AS $$
+	SELECT ltrim(regexp_replace(upper(coalesce(valor, '')), '[^0-9A-Z]', '', 'g'), '0')
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE TABLE conta (
+	id SERIAL PRIMARY KEY,
+	ispb VARCHAR(8) NOT NULL,
+	agencia VARCHAR(20) NOT NULL DEFAULT '',
+	numero VARCHAR(40) NOT NULL,
+	tipo_conta VARCHAR(50),
+	nome_instituicao VARCHAR(255),
+	primeira_ocorrencia TIMESTAMPTZ NOT NULL,
+	ultima_ocorrencia TIMESTAMPTZ NOT NULL,
+	UNIQUE (ispb, agencia, numero)
+);
+
+ALTER TABLE chave_pix ADD COLUMN conta_id INT REFERENCES conta(id) ON DELETE SET NULL;
+ALTER TABLE evento_chave_pix ADD COLUMN conta_id INT REFERENCES conta(id) ON DELETE SET NULL;
+ALTER TABLE bem_direito_valor_ccs ADD COLUMN conta_id INT REFERENCES conta(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_chave_pix_conta ON chave_pix (conta_id);
+CREATE INDEX idx_evento_chave_pix_conta ON evento_chave_pix (conta_id);
+CREATE INDEX idx_bem_direito_valor_ccs_conta ON bem_direito_valor_ccs (conta_id);
+
+-- Carga inicial a partir dos dados já armazenados
+INSERT INTO conta (ispb, agencia, numero, tipo_conta, nome_instituicao, primeira_ocorrencia, ultima_ocorrencia)
+SELECT o.ispb, o.agencia, o.numero,
+	(array_agg(o.tipo ORDER BY o.visto DESC) FILTER (WHERE o.tipo IS NOT NULL))[1],
+	(array_agg(o.nome ORDER BY o.visto DESC) FILTER (WHERE o.nome IS NOT NULL))[1],
+	MIN(o.visto), MAX(o.visto)
+FROM (
+	SELECT consultapix_normalizar_ispb(c.participante) AS ispb, consultapix_normalizar_conta(c.agencia) AS agencia,
+		consultapix_normalizar_conta(c.numero_conta) AS numero, NULLIF(btrim(c.tipo_conta), '') AS tipo,
+		CASE WHEN c.nome_banco = 'BANCO NÃO INFORMADO' THEN NULL ELSE NULLIF(btrim(c.nome_banco), '') END AS nome,
+		r.data AS visto
+	FROM chave_pix c JOIN requisicao_pix r ON r.id = c.id_requisicao
+	UNION ALL
+	SELECT consultapix_normalizar_ispb(e.participante), consultapix_normalizar_conta(e.agencia),
+		consultapix_normalizar_conta(e.numero_conta), NULLIF(btrim(e.tipo_conta), ''),
+		CASE WHEN e.nome_banco = 'BANCO NÃO INFORMADO' THEN NULL ELSE NULLIF(btrim(e.nome_banco), '') END,
+		r.data
+	FROM evento_chave_pix e JOIN chave_pix c ON c.id = e.id_chave JOIN requisicao_pix r ON r.id = c.id_requisicao
+	UNION ALL
+	SELECT consultapix_normalizar_ispb(b.cnpj_participante), consultapix_normalizar_conta(b.agencia),
+		consultapix_normalizar_conta(b.conta), NULL, NULL,
+		COALESCE(q.data_requisicao, NOW())
+	FROM bem_direito_valor_ccs b
+	JOIN relacionamento_ccs rc ON rc.id = b.id_relacionamento
+	JOIN requisicao_relacionamento_ccs q ON q.id = rc.id_requisicao
+) o
+WHERE o.ispb IS NOT NULL AND o.numero <> ''
+GROUP BY o.ispb, o.agencia, o.numero;
+
+UPDATE chave_pix SET conta_id = ct.id FROM conta ct
+	WHERE ct.ispb = consultapix_normalizar_ispb(chave_pix.participante)
+		AND ct.agencia = consultapix_normalizar_conta(chave_pix.agencia)
+		AND ct.numero = consultapix_normalizar_conta(chave_pix.numero_conta);
+UPDATE evento_chave_pix SET conta_id = ct.id FROM conta ct
+	WHERE ct.ispb = consultapix_normalizar_ispb(evento_chave_pix.participante)
+		AND ct.agencia = consultapix_normalizar_conta(evento_chave_pix.agencia)
+		AND ct.numero = consultapix_normalizar_conta(evento_chave_pix.numero_conta);
+UPDATE bem_direito_valor_ccs SET conta_id = ct.id FROM conta ct
+	WHERE ct.ispb = consultapix_normalizar_ispb(bem_direito_valor_ccs.cnpj_participante)
+		AND ct.agencia = consultapix_normalizar_conta(bem_direito_valor_ccs.agencia)
+		AND ct.numero = consultapix_normalizar_conta(bem_direito_valor_ccs.conta);
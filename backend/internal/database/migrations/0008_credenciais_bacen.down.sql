@@ -0,0 +1,4 @@
+ALTER TABLE requisicao_relacionamento_ccs DROP COLUMN IF EXISTS usuario_bacen, DROP COLUMN IF EXISTS id_credencial_bacen;
+ALTER TABLE requisicao_pix DROP COLUMN IF EXISTS usuario_bacen, DROP COLUMN IF EXISTS id_credencial_bacen;
+
+DROP TABLE IF EXISTS credencial_bacen;
@@ -0,0 +1,25 @@
+-- Perfis de credencial do BACEN por lotação. A senha é cifrada pela aplicação
+-- (AES-256-GCM com a chave BACEN_CREDENTIALS_KEY) e nunca fica em claro no banco.
+CREATE TABLE credencial_bacen (
+	id SERIAL PRIMARY KEY,
+	lotacao VARCHAR(100) NOT NULL UNIQUE,
+	descricao VARCHAR(255),
+	usuario VARCHAR(100) NOT NULL,
+	senha_cifrada BYTEA NOT NULL,
+	ativo BOOLEAN NOT NULL DEFAULT TRUE,
+	criado_em TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	atualizado_em TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Credencial usada em cada requisição. id_credencial_bacen fica nulo quando foi
+-- usada a credencial padrão da configuração; usuario_bacen guarda o usuário
+-- efetivo mesmo que o perfil seja removido depois.
+ALTER TABLE requisicao_pix
+	ADD COLUMN id_credencial_bacen INT REFERENCES credencial_bacen(id) ON DELETE SET NULL,
+	ADD COLUMN usuario_bacen VARCHAR(100);
+ALTER TABLE requisicao_relacionamento_ccs
+	ADD COLUMN id_credencial_bacen INT REFERENCES credencial_bacen(id) ON DELETE SET NULL,
+	ADD COLUMN usuario_bacen VARCHAR(100);
+
+CREATE INDEX idx_requisicao_pix_credencial ON requisicao_pix (id_credencial_bacen);
+CREATE INDEX idx_requisicao_ccs_credencial ON requisicao_relacionamento_ccs (id_credencial_bacen);
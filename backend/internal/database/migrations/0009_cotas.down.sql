@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS uso_cota;
+DROP TABLE IF EXISTS limite_cota;
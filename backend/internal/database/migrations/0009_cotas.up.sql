@@ -0,0 +1,26 @@
+-- Limites de consultas ao BACEN por usuário (CPF) e por lotação. O alvo '*'
+-- define o limite padrão de quem não tem um limite próprio. Limite nulo
+-- significa ilimitado no período.
+CREATE TABLE limite_cota (
+	id SERIAL PRIMARY KEY,
+	escopo VARCHAR(10) NOT NULL CHECK (escopo IN ('usuario', 'lotacao')),
+	alvo VARCHAR(255) NOT NULL,
+	operacao VARCHAR(30) NOT NULL CHECK (operacao IN ('pix_chave', 'pix_cpf_cnpj', 'ccs_relacionamento', 'ccs_detalhamento')),
+	limite_diario INT CHECK (limite_diario >= 0),
+	limite_mensal INT CHECK (limite_mensal >= 0),
+	atualizado_em TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	UNIQUE (escopo, alvo, operacao)
+);
+
+-- Cada chamada autorizada ao BACEN consome uma unidade da cota do usuário e da
+-- lotação em que ele estava no momento da consulta
+CREATE TABLE uso_cota (
+	id BIGSERIAL PRIMARY KEY,
+	cpf_usuario VARCHAR(20) NOT NULL,
+	lotacao VARCHAR(255) NOT NULL DEFAULT '',
+	operacao VARCHAR(30) NOT NULL,
+	criado_em TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_uso_cota_usuario ON uso_cota (cpf_usuario, operacao, criado_em);
+CREATE INDEX idx_uso_cota_lotacao ON uso_cota (lotacao, operacao, criado_em);
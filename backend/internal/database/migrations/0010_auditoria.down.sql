@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_requisicao_pix_reaproveitamento;
+DROP TABLE IF EXISTS auditoria;
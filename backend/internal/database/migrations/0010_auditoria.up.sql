@@ -0,0 +1,18 @@
+-- Trilha de auditoria de ações sensíveis. detalhes guarda os dados específicos
+-- de cada ação (por exemplo, a justificativa de uma consulta forçada).
+CREATE TABLE auditoria (
+	id BIGSERIAL PRIMARY KEY,
+	data TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	cpf_usuario VARCHAR(20),
+	lotacao VARCHAR(255),
+	acao VARCHAR(50) NOT NULL,
+	alvo VARCHAR(255),
+	detalhes JSONB NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX idx_auditoria_data ON auditoria (data, id);
+CREATE INDEX idx_auditoria_acao ON auditoria (acao, data);
+CREATE INDEX idx_auditoria_usuario ON auditoria (cpf_usuario, data);
+
+-- Busca da requisição mais recente do mesmo alvo no mesmo caso
+CREATE INDEX idx_requisicao_pix_reaproveitamento ON requisicao_pix (tipo_busca, chave_busca, caso, data DESC);
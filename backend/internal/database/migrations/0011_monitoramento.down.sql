@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS notificacao;
+DROP TABLE IF EXISTS monitoramento_responsavel;
+DROP TABLE IF EXISTS monitoramento;
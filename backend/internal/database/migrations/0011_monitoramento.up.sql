@@ -0,0 +1,47 @@
+-- Monitoramento periódico de chaves PIX e CPFs/CNPJs de um caso. O alvo é
+-- reconsultado a cada frequencia_horas até a autorização expirar.
+CREATE TABLE monitoramento (
+	id SERIAL PRIMARY KEY,
+	tipo_busca VARCHAR(50) NOT NULL CHECK (tipo_busca IN ('chave', 'cpf/cnpj')),
+	alvo VARCHAR(255) NOT NULL,
+	caso VARCHAR(255) NOT NULL,
+	motivo VARCHAR(255) NOT NULL,
+	numero_autorizacao VARCHAR(255) NOT NULL,
+	autorizacao_expira_em TIMESTAMPTZ NOT NULL,
+	frequencia_horas INT NOT NULL CHECK (frequencia_horas >= 1),
+	cpf_responsavel VARCHAR(20) NOT NULL,
+	lotacao VARCHAR(255),
+	ativo BOOLEAN NOT NULL DEFAULT TRUE,
+	encerrado_em TIMESTAMPTZ,
+	motivo_encerramento VARCHAR(255),
+	ultima_execucao TIMESTAMPTZ,
+	proxima_execucao TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	id_ultima_requisicao INT REFERENCES requisicao_pix(id) ON DELETE SET NULL,
+	ultimo_erro TEXT,
+	criado_em TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_monitoramento_pendente ON monitoramento (proxima_execucao) WHERE ativo;
+
+-- Agentes notificados quando o monitoramento detecta alterações
+CREATE TABLE monitoramento_responsavel (
+	id_monitoramento INT NOT NULL REFERENCES monitoramento(id) ON DELETE CASCADE,
+	cpf_usuario VARCHAR(20) NOT NULL,
+	PRIMARY KEY (id_monitoramento, cpf_usuario)
+);
+
+CREATE INDEX idx_monitoramento_responsavel_usuario ON monitoramento_responsavel (cpf_usuario);
+
+-- Caixa de entrada de notificações de cada usuário
+CREATE TABLE notificacao (
+	id BIGSERIAL PRIMARY KEY,
+	cpf_usuario VARCHAR(20) NOT NULL,
+	tipo VARCHAR(50) NOT NULL,
+	titulo VARCHAR(255) NOT NULL,
+	mensagem TEXT NOT NULL,
+	dados JSONB NOT NULL DEFAULT '{}',
+	lida_em TIMESTAMPTZ,
+	criado_em TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_notificacao_usuario ON notificacao (cpf_usuario, criado_em DESC);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS preferencia_notificacao;
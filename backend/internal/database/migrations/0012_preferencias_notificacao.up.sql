@@ -0,0 +1,10 @@
+-- Preferências de notificação por evento e canal. Sem registro vale o padrão
+-- da aplicação (somente caixa de entrada).
+CREATE TABLE preferencia_notificacao (
+	cpf_usuario VARCHAR(20) NOT NULL,
+	evento VARCHAR(50) NOT NULL,
+	canal VARCHAR(20) NOT NULL CHECK (canal IN ('inbox', 'email', 'webhook')),
+	ativo BOOLEAN NOT NULL,
+	atualizado_em TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (cpf_usuario, evento, canal)
+);
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS trg_evento_monitoramento ON monitoramento;
+DROP TRIGGER IF EXISTS trg_evento_notificacao ON notificacao;
+DROP TRIGGER IF EXISTS trg_evento_relacionamento_ccs ON relacionamento_ccs;
+DROP FUNCTION IF EXISTS consultapix_evento_monitoramento();
+DROP FUNCTION IF EXISTS consultapix_evento_notificacao();
+DROP FUNCTION IF EXISTS consultapix_evento_relacionamento_ccs();
+DROP FUNCTION IF EXISTS consultapix_publicar_evento(TEXT[], TEXT, JSONB);
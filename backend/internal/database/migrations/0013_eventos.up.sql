@@ -0,0 +1,83 @@
+-- Eventos enviados ao stream da API (GET /api/eventos) por NOTIFY no canal
+-- consultapix_eventos. Os gatilhos cobrem qualquer processo que altere as
+-- tabelas, então todas as instâncias da API recebem os mesmos eventos. O
+-- payload do NOTIFY é limitado a 8000 bytes: os eventos levam só
+-- identificadores e o estado, e o cliente busca o resto pela API.
+CREATE OR REPLACE FUNCTION consultapix_publicar_evento(destinatarios TEXT[], tipo TEXT, dados JSONB) RETURNS VOID AS $$
+BEGIN
+	PERFORM pg_notify('consultapix_eventos', jsonb_build_object(
+		'destinatarios', to_jsonb(destinatarios),
+		'tipo', tipo,
+		'dados', dados
+	)::text);
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION consultapix_evento_relacionamento_ccs() RETURNS TRIGGER AS $$
+BEGIN
+	PERFORM consultapix_publicar_evento(
+		ARRAY(SELECT q.cpf_responsavel FROM requisicao_relacionamento_ccs q WHERE q.id = NEW.id_requisicao),
+		'relacionamento_ccs',
+		jsonb_build_object(
+			'id', NEW.id,
+			'idRequisicao', NEW.id_requisicao,
+			'numeroRequisicao', NEW.numero_requisicao,
+			'nomeBancoResponsavel', NEW.nome_banco_responsavel,
+			'statusDetalhamento', NEW.status_detalhamento,
+			'statusAnterior', OLD.status_detalhamento,
+			'resposta', NEW.resposta
+		)
+	);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_evento_relacionamento_ccs
+	AFTER UPDATE OF status_detalhamento ON relacionamento_ccs
+	FOR EACH ROW
+	WHEN (OLD.status_detalhamento IS DISTINCT FROM NEW.status_detalhamento)
+	EXECUTE FUNCTION consultapix_evento_relacionamento_ccs();
+
+CREATE OR REPLACE FUNCTION consultapix_evento_notificacao() RETURNS TRIGGER AS $$
+BEGIN
+	PERFORM consultapix_publicar_evento(
+		ARRAY[NEW.cpf_usuario],
+		'notificacao',
+		jsonb_build_object('id', NEW.id, 'tipo', NEW.tipo, 'titulo', NEW.titulo, 'criadoEm', NEW.criado_em)
+	);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_evento_notificacao
+	AFTER INSERT ON notificacao
+	FOR EACH ROW
+	EXECUTE FUNCTION consultapix_evento_notificacao();
+
+-- Execuções do agendador de monitoramentos, os jobs em segundo plano do usuário
+CREATE OR REPLACE FUNCTION consultapix_evento_monitoramento() RETURNS TRIGGER AS $$
+BEGIN
+	PERFORM consultapix_publicar_evento(
+		ARRAY(
+			SELECT NEW.cpf_responsavel
+			UNION
+			SELECT mr.cpf_usuario FROM monitoramento_responsavel mr WHERE mr.id_monitoramento = NEW.id
+		),
+		'monitoramento',
+		jsonb_build_object(
+			'id', NEW.id,
+			'alvo', NEW.alvo,
+			'ativo', NEW.ativo,
+			'ultimaExecucao', NEW.ultima_execucao,
+			'proximaExecucao', NEW.proxima_execucao,
+			'ultimoErro', NEW.ultimo_erro
+		)
+	);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_evento_monitoramento
+	AFTER UPDATE OF ultima_execucao, ativo ON monitoramento
+	FOR EACH ROW
+	EXECUTE FUNCTION consultapix_evento_monitoramento();
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS token_revogado;
+DROP TABLE IF EXISTS refresh_token;
+DROP TRIGGER IF EXISTS trg_invalidar_tokens_usuario ON usuario;
+DROP FUNCTION IF EXISTS consultapix_invalidar_tokens_usuario();
+ALTER TABLE usuario DROP COLUMN IF EXISTS tokens_validos_desde;
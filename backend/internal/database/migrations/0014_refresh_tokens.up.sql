@@ -0,0 +1,43 @@
+-- Tokens emitidos antes de tokens_validos_desde são recusados, tanto o JWT de
+-- acesso (pelo iat) quanto o refresh token (pela criação)
+ALTER TABLE usuario ADD COLUMN tokens_validos_desde TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+-- Troca de senha, perda de administrador ou mudança de lotação invalidam os
+-- tokens emitidos, qualquer que seja o caminho da alteração
+CREATE OR REPLACE FUNCTION consultapix_invalidar_tokens_usuario() RETURNS TRIGGER AS $$
+BEGIN
+	IF NEW.password IS DISTINCT FROM OLD.password
+		OR (OLD.admin AND NOT NEW.admin)
+		OR NEW.lotacao IS DISTINCT FROM OLD.lotacao THEN
+		NEW.tokens_validos_desde := NOW();
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_invalidar_tokens_usuario
+	BEFORE UPDATE ON usuario
+	FOR EACH ROW
+	EXECUTE FUNCTION consultapix_invalidar_tokens_usuario();
+
+-- Refresh tokens, guardados só como SHA-256. Cada uso gera um novo token da
+-- mesma família; apresentar um token já usado revoga a família inteira.
+CREATE TABLE refresh_token (
+	id BIGSERIAL PRIMARY KEY,
+	id_usuario INT NOT NULL REFERENCES usuario(id) ON DELETE CASCADE,
+	familia VARCHAR(64) NOT NULL,
+	hash_token CHAR(64) NOT NULL UNIQUE,
+	criado_em TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	expira_em TIMESTAMPTZ NOT NULL,
+	usado_em TIMESTAMPTZ,
+	revogado_em TIMESTAMPTZ
+);
+
+CREATE INDEX idx_refresh_token_usuario ON refresh_token (id_usuario);
+CREATE INDEX idx_refresh_token_familia ON refresh_token (familia);
+
+-- JWTs de acesso revogados antes de expirar (logout), pelo jti
+CREATE TABLE token_revogado (
+	jti VARCHAR(64) PRIMARY KEY,
+	expira_em TIMESTAMPTZ NOT NULL
+);
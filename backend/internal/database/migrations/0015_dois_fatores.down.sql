@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS politica_dois_fatores;
+DROP TABLE IF EXISTS desafio_dois_fatores;
+DROP TABLE IF EXISTS codigo_recuperacao;
+ALTER TABLE usuario
+	DROP COLUMN IF EXISTS totp_ultimo_passo,
+	DROP COLUMN IF EXISTS totp_ativo,
+	DROP COLUMN IF EXISTS totp_segredo;
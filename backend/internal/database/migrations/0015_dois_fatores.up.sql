@@ -0,0 +1,32 @@
+-- Autenticação em dois fatores (TOTP). O segredo é cifrado com
+-- MFA_ENCRYPTION_KEY; totp_ultimo_passo impede reutilizar um código aceito.
+ALTER TABLE usuario
+	ADD COLUMN totp_segredo BYTEA,
+	ADD COLUMN totp_ativo BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN totp_ultimo_passo BIGINT;
+
+-- Códigos de recuperação de uso único, guardados como SHA-256
+CREATE TABLE codigo_recuperacao (
+	id BIGSERIAL PRIMARY KEY,
+	id_usuario INT NOT NULL REFERENCES usuario(id) ON DELETE CASCADE,
+	hash_codigo CHAR(64) NOT NULL,
+	usado_em TIMESTAMPTZ,
+	UNIQUE (id_usuario, hash_codigo)
+);
+
+-- Segundo passo do login: o desafio vale por poucos minutos e aceita um
+-- número limitado de tentativas
+CREATE TABLE desafio_dois_fatores (
+	hash_desafio CHAR(64) PRIMARY KEY,
+	id_usuario INT NOT NULL REFERENCES usuario(id) ON DELETE CASCADE,
+	expira_em TIMESTAMPTZ NOT NULL,
+	tentativas INT NOT NULL DEFAULT 0
+);
+
+-- Obrigatoriedade do segundo fator por papel
+CREATE TABLE politica_dois_fatores (
+	papel VARCHAR(20) PRIMARY KEY CHECK (papel IN ('admin', 'usuario')),
+	obrigatorio BOOLEAN NOT NULL,
+	atualizado_em TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	atualizado_por VARCHAR(20)
+);
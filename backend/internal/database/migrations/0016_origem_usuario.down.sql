@@ -0,0 +1,4 @@
+ALTER TABLE usuario
+	DROP CONSTRAINT IF EXISTS usuario_origem_id_externo_key,
+	DROP COLUMN IF EXISTS id_externo,
+	DROP COLUMN IF EXISTS origem;
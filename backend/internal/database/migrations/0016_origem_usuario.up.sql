@@ -0,0 +1,7 @@
+-- Origem da identidade do usuário. Usuários de provedores externos (LDAP) são
+-- criados e atualizados no login e não autenticam pela senha local;
+-- id_externo é o identificador deles no provedor (o DN, no LDAP).
+ALTER TABLE usuario
+	ADD COLUMN origem VARCHAR(20) NOT NULL DEFAULT 'local' CHECK (origem IN ('local', 'ldap')),
+	ADD COLUMN id_externo VARCHAR(512),
+	ADD CONSTRAINT usuario_origem_id_externo_key UNIQUE (origem, id_externo);
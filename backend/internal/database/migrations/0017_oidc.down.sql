@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS login_oidc;
+
+UPDATE usuario SET origem = 'local', id_externo = NULL WHERE origem = 'oidc';
+
+ALTER TABLE usuario
+	DROP CONSTRAINT IF EXISTS usuario_origem_check,
+	ADD CONSTRAINT usuario_origem_check CHECK (origem IN ('local', 'ldap'));
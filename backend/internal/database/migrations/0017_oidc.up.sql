@@ -0,0 +1,14 @@
+-- Login único por OpenID Connect. Usuários do provedor OIDC têm origem 'oidc'
+-- e id_externo no formato emissor|sub.
+ALTER TABLE usuario
+	DROP CONSTRAINT IF EXISTS usuario_origem_check,
+	ADD CONSTRAINT usuario_origem_check CHECK (origem IN ('local', 'ldap', 'oidc'));
+
+-- Logins OIDC em andamento: o estado enviado ao provedor (guardado como hash),
+-- o verificador PKCE e o nonce esperado no ID token
+CREATE TABLE IF NOT EXISTS login_oidc (
+	hash_estado CHAR(64) PRIMARY KEY,
+	verificador VARCHAR(128) NOT NULL,
+	nonce VARCHAR(128) NOT NULL,
+	expira_em TIMESTAMPTZ NOT NULL
+);
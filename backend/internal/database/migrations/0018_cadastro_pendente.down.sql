@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_usuario_cadastro_pendente;
+DELETE FROM usuario WHERE situacao <> 'ativo';
+ALTER TABLE usuario
+	DROP COLUMN IF EXISTS decidido_em,
+	DROP COLUMN IF EXISTS decidido_por,
+	DROP COLUMN IF EXISTS email_verificado_em,
+	DROP COLUMN IF EXISTS cadastrado_em,
+	DROP COLUMN IF EXISTS situacao;
@@ -0,0 +1,11 @@
+-- Autocadastro com confirmação do e-mail e aprovação por um administrador da
+-- mesma lotação. Usuários existentes continuam ativos; cadastros rejeitados ou
+-- não aprovados no prazo são removidos pela limpeza periódica.
+ALTER TABLE usuario
+	ADD COLUMN situacao VARCHAR(20) NOT NULL DEFAULT 'ativo' CHECK (situacao IN ('pendente', 'ativo', 'rejeitado')),
+	ADD COLUMN cadastrado_em TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	ADD COLUMN email_verificado_em TIMESTAMPTZ,
+	ADD COLUMN decidido_por VARCHAR(20),
+	ADD COLUMN decidido_em TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_usuario_cadastro_pendente ON usuario (lotacao, cadastrado_em) WHERE situacao <> 'ativo';
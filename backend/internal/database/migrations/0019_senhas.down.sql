@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS tentativa_login;
+DROP TABLE IF EXISTS redefinicao_senha;
+DROP TRIGGER IF EXISTS trg_registrar_troca_senha ON usuario;
+DROP FUNCTION IF EXISTS consultapix_registrar_troca_senha();
+DROP TABLE IF EXISTS historico_senha;
+ALTER TABLE usuario DROP COLUMN IF EXISTS senha_alterada_em;
@@ -0,0 +1,52 @@
+-- Política de senhas: data da última troca (para a expiração) e as senhas
+-- anteriores, guardadas como hash bcrypt, para impedir a reutilização
+ALTER TABLE usuario ADD COLUMN senha_alterada_em TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE TABLE historico_senha (
+	id BIGSERIAL PRIMARY KEY,
+	id_usuario INT NOT NULL REFERENCES usuario(id) ON DELETE CASCADE,
+	hash VARCHAR(255) NOT NULL,
+	criada_em TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_historico_senha_usuario ON historico_senha (id_usuario, id DESC);
+
+-- Toda troca de senha, qualquer que seja o caminho, atualiza a data e guarda
+-- a senha anterior; contas sem senha local (LDAP, OIDC) não entram no histórico
+CREATE OR REPLACE FUNCTION consultapix_registrar_troca_senha() RETURNS TRIGGER AS $$
+BEGIN
+	IF NEW.password IS DISTINCT FROM OLD.password THEN
+		NEW.senha_alterada_em := NOW();
+		IF OLD.password <> '' THEN
+			INSERT INTO historico_senha (id_usuario, hash) VALUES (OLD.id, OLD.password);
+			DELETE FROM historico_senha
+			WHERE id_usuario = OLD.id AND id NOT IN (
+				SELECT id FROM historico_senha WHERE id_usuario = OLD.id ORDER BY id DESC LIMIT 24
+			);
+		END IF;
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_registrar_troca_senha
+	BEFORE UPDATE ON usuario
+	FOR EACH ROW
+	EXECUTE FUNCTION consultapix_registrar_troca_senha();
+
+-- Links de redefinição de senha, guardados só como SHA-256 e de uso único
+CREATE TABLE redefinicao_senha (
+	hash_token CHAR(64) PRIMARY KEY,
+	id_usuario INT NOT NULL REFERENCES usuario(id) ON DELETE CASCADE,
+	expira_em TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_redefinicao_senha_usuario ON redefinicao_senha (id_usuario);
+
+-- Falhas de login por chave ("conta:<login>" ou "ip:<endereço>") e o bloqueio em vigor
+CREATE TABLE tentativa_login (
+	chave VARCHAR(300) PRIMARY KEY,
+	falhas INT NOT NULL DEFAULT 0,
+	ultima_falha TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	bloqueado_ate TIMESTAMPTZ
+);
@@ -0,0 +1,23 @@
+-- Sessões: cada login abre uma, ligada à família dos seus refresh tokens, com
+-- o dispositivo de origem e a última atividade (base do encerramento por inatividade)
+CREATE TABLE sessao (
+	id BIGSERIAL PRIMARY KEY,
+	id_usuario INT NOT NULL REFERENCES usuario(id) ON DELETE CASCADE,
+	familia VARCHAR(64) NOT NULL UNIQUE,
+	ip VARCHAR(45),
+	agente_usuario VARCHAR(512),
+	criada_em TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	ultima_atividade TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	encerrada_em TIMESTAMPTZ,
+	motivo_encerramento VARCHAR(30)
+);
+
+CREATE INDEX idx_sessao_usuario ON sessao (id_usuario, ultima_atividade DESC);
+
+-- Os logins já abertos viram sessões sem dispositivo conhecido; os tokens de
+-- acesso antigos, sem sessão, são recusados e renovados por elas
+INSERT INTO sessao (id_usuario, familia, criada_em, ultima_atividade)
+SELECT id_usuario, familia, MIN(criado_em), MAX(criado_em)
+FROM refresh_token
+WHERE revogado_em IS NULL AND expira_em > NOW()
+GROUP BY id_usuario, familia;
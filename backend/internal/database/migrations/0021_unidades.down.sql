@@ -0,0 +1,19 @@
+CREATE OR REPLACE FUNCTION consultapix_invalidar_tokens_usuario() RETURNS TRIGGER AS $$
+BEGIN
+	IF NEW.password IS DISTINCT FROM OLD.password
+		OR (OLD.admin AND NOT NEW.admin)
+		OR NEW.lotacao IS DISTINCT FROM OLD.lotacao THEN
+		NEW.tokens_validos_desde := NOW();
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_unidade_requisicao_ccs ON requisicao_relacionamento_ccs;
+DROP TRIGGER IF EXISTS trg_unidade_requisicao_pix ON requisicao_pix;
+DROP FUNCTION IF EXISTS consultapix_unidade_requisicao();
+ALTER TABLE requisicao_relacionamento_ccs DROP COLUMN IF EXISTS id_unidade;
+ALTER TABLE requisicao_pix DROP COLUMN IF EXISTS id_unidade;
+ALTER TABLE usuario DROP COLUMN IF EXISTS perfil;
+ALTER TABLE usuario DROP COLUMN IF EXISTS id_unidade;
+DROP TABLE IF EXISTS unidade;
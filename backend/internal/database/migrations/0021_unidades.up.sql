@@ -0,0 +1,63 @@
+-- Hierarquia de unidades (departamento → regional → delegacia). Cada nível só
+-- se subordina ao nível imediatamente acima, o que impede ciclos.
+CREATE TABLE unidade (
+	id SERIAL PRIMARY KEY,
+	nome VARCHAR(255) NOT NULL,
+	tipo VARCHAR(20) NOT NULL CHECK (tipo IN ('departamento', 'regional', 'delegacia')),
+	id_superior INT REFERENCES unidade(id),
+	criada_em TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	CHECK ((tipo = 'departamento') = (id_superior IS NULL)),
+	UNIQUE (id_superior, nome)
+);
+
+CREATE INDEX idx_unidade_superior ON unidade (id_superior);
+
+-- Unidade e perfil de acesso do usuário: o analista vê as próprias
+-- requisições, o supervisor as da sua unidade e das subordinadas, e o
+-- auditor todas, sem poder consultar nem alterar
+ALTER TABLE usuario
+	ADD COLUMN id_unidade INT REFERENCES unidade(id),
+	ADD COLUMN perfil VARCHAR(20) NOT NULL DEFAULT 'analista' CHECK (perfil IN ('analista', 'supervisor', 'auditor'));
+
+CREATE INDEX idx_usuario_unidade ON usuario (id_unidade);
+
+-- A requisição guarda a unidade do responsável no momento em que foi feita,
+-- como já guarda a lotação
+ALTER TABLE requisicao_pix ADD COLUMN id_unidade INT REFERENCES unidade(id);
+ALTER TABLE requisicao_relacionamento_ccs ADD COLUMN id_unidade INT REFERENCES unidade(id);
+
+CREATE INDEX idx_requisicao_pix_unidade ON requisicao_pix (id_unidade, data DESC, id DESC);
+CREATE INDEX idx_requisicao_ccs_unidade ON requisicao_relacionamento_ccs (id_unidade, id DESC);
+
+CREATE OR REPLACE FUNCTION consultapix_unidade_requisicao() RETURNS TRIGGER AS $$
+BEGIN
+	IF NEW.id_unidade IS NULL THEN
+		SELECT u.id_unidade INTO NEW.id_unidade FROM usuario u WHERE u.cpf = NEW.cpf_responsavel;
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_unidade_requisicao_pix
+	BEFORE INSERT ON requisicao_pix
+	FOR EACH ROW
+	EXECUTE FUNCTION consultapix_unidade_requisicao();
+
+CREATE TRIGGER trg_unidade_requisicao_ccs
+	BEFORE INSERT ON requisicao_relacionamento_ccs
+	FOR EACH ROW
+	EXECUTE FUNCTION consultapix_unidade_requisicao();
+
+-- Mudar a unidade ou o perfil também invalida os tokens, que carregam o escopo
+CREATE OR REPLACE FUNCTION consultapix_invalidar_tokens_usuario() RETURNS TRIGGER AS $$
+BEGIN
+	IF NEW.password IS DISTINCT FROM OLD.password
+		OR (OLD.admin AND NOT NEW.admin)
+		OR NEW.lotacao IS DISTINCT FROM OLD.lotacao
+		OR NEW.id_unidade IS DISTINCT FROM OLD.id_unidade
+		OR NEW.perfil IS DISTINCT FROM OLD.perfil THEN
+		NEW.tokens_validos_desde := NOW();
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
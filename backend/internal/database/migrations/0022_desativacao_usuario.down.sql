@@ -0,0 +1,24 @@
+CREATE OR REPLACE FUNCTION consultapix_invalidar_tokens_usuario() RETURNS TRIGGER AS $$
+BEGIN
+	IF NEW.password IS DISTINCT FROM OLD.password
+		OR (OLD.admin AND NOT NEW.admin)
+		OR NEW.lotacao IS DISTINCT FROM OLD.lotacao
+		OR NEW.id_unidade IS DISTINCT FROM OLD.id_unidade
+		OR NEW.perfil IS DISTINCT FROM OLD.perfil THEN
+		NEW.tokens_validos_desde := NOW();
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Contas inativas não existiam antes desta migração
+DELETE FROM usuario WHERE situacao = 'inativo';
+DROP INDEX IF EXISTS idx_usuario_inativo;
+ALTER TABLE usuario
+	DROP COLUMN IF EXISTS justificativa_reativacao,
+	DROP COLUMN IF EXISTS reativacao_solicitada_em,
+	DROP COLUMN IF EXISTS motivo_desativacao,
+	DROP COLUMN IF EXISTS desativado_por,
+	DROP COLUMN IF EXISTS desativado_em;
+ALTER TABLE usuario DROP CONSTRAINT IF EXISTS usuario_situacao_check;
+ALTER TABLE usuario ADD CONSTRAINT usuario_situacao_check CHECK (situacao IN ('pendente', 'ativo', 'rejeitado'));
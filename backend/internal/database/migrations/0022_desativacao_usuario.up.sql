@@ -0,0 +1,28 @@
+-- Usuários deixam de ser excluídos: a conta é desativada, com motivo, data e
+-- autor, e as requisições e a auditoria continuam ligadas a ela. A
+-- reativação, pedida pelo próprio usuário ou não, depende de um administrador.
+ALTER TABLE usuario DROP CONSTRAINT IF EXISTS usuario_situacao_check;
+ALTER TABLE usuario
+	ADD CONSTRAINT usuario_situacao_check CHECK (situacao IN ('pendente', 'ativo', 'rejeitado', 'inativo')),
+	ADD COLUMN desativado_em TIMESTAMPTZ,
+	ADD COLUMN desativado_por VARCHAR(20),
+	ADD COLUMN motivo_desativacao VARCHAR(500),
+	ADD COLUMN reativacao_solicitada_em TIMESTAMPTZ,
+	ADD COLUMN justificativa_reativacao VARCHAR(500);
+
+CREATE INDEX IF NOT EXISTS idx_usuario_inativo ON usuario (desativado_em DESC) WHERE situacao = 'inativo';
+
+-- Desativar a conta também invalida os tokens emitidos para ela
+CREATE OR REPLACE FUNCTION consultapix_invalidar_tokens_usuario() RETURNS TRIGGER AS $$
+BEGIN
+	IF NEW.password IS DISTINCT FROM OLD.password
+		OR (OLD.admin AND NOT NEW.admin)
+		OR NEW.lotacao IS DISTINCT FROM OLD.lotacao
+		OR NEW.id_unidade IS DISTINCT FROM OLD.id_unidade
+		OR NEW.perfil IS DISTINCT FROM OLD.perfil
+		OR (OLD.situacao = 'ativo' AND NEW.situacao <> 'ativo') THEN
+		NEW.tokens_validos_desde := NOW();
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,23 @@
+-- Os dados cifrados só podem ser lidos pela aplicação: reverta depois de
+-- devolvê-los às colunas em claro com "consultapix-api cifra decifrar"
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM requisicao_pix WHERE dados_cifrados IS NOT NULL)
+		OR EXISTS (SELECT 1 FROM chave_pix WHERE dados_cifrados IS NOT NULL)
+		OR EXISTS (SELECT 1 FROM evento_chave_pix WHERE dados_cifrados IS NOT NULL)
+		OR EXISTS (SELECT 1 FROM relacionamento_ccs WHERE dados_cifrados IS NOT NULL)
+		OR EXISTS (SELECT 1 FROM bem_direito_valor_ccs WHERE dados_cifrados IS NOT NULL)
+		OR EXISTS (SELECT 1 FROM vinculados_bdv_ccs WHERE dados_cifrados IS NOT NULL) THEN
+		RAISE EXCEPTION 'há dados cifrados; execute "consultapix-api cifra decifrar" antes de reverter';
+	END IF;
+END;
+$$;
+
+ALTER TABLE vinculados_bdv_ccs DROP COLUMN IF EXISTS indices, DROP COLUMN IF EXISTS dados_cifrados, DROP COLUMN IF EXISTS id_chave_dados;
+ALTER TABLE bem_direito_valor_ccs DROP COLUMN IF EXISTS indices, DROP COLUMN IF EXISTS dados_cifrados, DROP COLUMN IF EXISTS id_chave_dados;
+ALTER TABLE relacionamento_ccs DROP COLUMN IF EXISTS indices, DROP COLUMN IF EXISTS dados_cifrados, DROP COLUMN IF EXISTS id_chave_dados;
+ALTER TABLE evento_chave_pix DROP COLUMN IF EXISTS indices, DROP COLUMN IF EXISTS dados_cifrados, DROP COLUMN IF EXISTS id_chave_dados;
+ALTER TABLE chave_pix DROP COLUMN IF EXISTS indices, DROP COLUMN IF EXISTS dados_cifrados, DROP COLUMN IF EXISTS id_chave_dados;
+ALTER TABLE requisicao_pix DROP COLUMN IF EXISTS dados_cifrados, DROP COLUMN IF EXISTS id_chave_dados;
+
+DROP TABLE IF EXISTS chave_dados;
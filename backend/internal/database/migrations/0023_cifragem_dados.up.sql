@@ -0,0 +1,62 @@
+-- Cifragem em envelope das colunas sensíveis das consultas PIX e CCS. As
+-- chaves de dados são geradas pela aplicação e gravadas embrulhadas pela chave
+-- mestra (DATA_ENCRYPTION_KEY), identificada pela impressão digital. A chave de
+-- finalidade 'dados' cifra as linhas; a de finalidade 'indice' calcula os
+-- índices cegos usados nas buscas por igualdade.
+CREATE TABLE IF NOT EXISTS chave_dados (
+	id SERIAL PRIMARY KEY,
+	finalidade VARCHAR(10) NOT NULL CHECK (finalidade IN ('dados', 'indice')),
+	chave_mestra VARCHAR(16) NOT NULL,
+	chave_embrulhada BYTEA NOT NULL,
+	ativa BOOLEAN NOT NULL DEFAULT TRUE,
+	criada_em TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	desativada_em TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_chave_dados_ativa ON chave_dados (finalidade) WHERE ativa;
+
+-- Com a cifragem ativa, as colunas sensíveis ficam nulas e o seu conteúdo vai
+-- em dados_cifrados (AES-256-GCM, vinculado à tabela e ao id da linha).
+-- indices guarda os HMACs dos documentos, chaves e contas normalizados.
+ALTER TABLE requisicao_pix
+	ADD COLUMN id_chave_dados INT REFERENCES chave_dados(id),
+	ADD COLUMN dados_cifrados BYTEA;
+
+ALTER TABLE chave_pix
+	ADD COLUMN id_chave_dados INT REFERENCES chave_dados(id),
+	ADD COLUMN dados_cifrados BYTEA,
+	ADD COLUMN indices BYTEA[];
+
+ALTER TABLE evento_chave_pix
+	ADD COLUMN id_chave_dados INT REFERENCES chave_dados(id),
+	ADD COLUMN dados_cifrados BYTEA,
+	ADD COLUMN indices BYTEA[];
+
+ALTER TABLE relacionamento_ccs
+	ADD COLUMN id_chave_dados INT REFERENCES chave_dados(id),
+	ADD COLUMN dados_cifrados BYTEA,
+	ADD COLUMN indices BYTEA[];
+
+ALTER TABLE bem_direito_valor_ccs
+	ADD COLUMN id_chave_dados INT REFERENCES chave_dados(id),
+	ADD COLUMN dados_cifrados BYTEA,
+	ADD COLUMN indices BYTEA[];
+
+ALTER TABLE vinculados_bdv_ccs
+	ADD COLUMN id_chave_dados INT REFERENCES chave_dados(id),
+	ADD COLUMN dados_cifrados BYTEA,
+	ADD COLUMN indices BYTEA[];
+
+-- A recifragem procura as linhas que não estão na chave ativa
+CREATE INDEX IF NOT EXISTS idx_requisicao_pix_chave_dados ON requisicao_pix (id_chave_dados);
+CREATE INDEX IF NOT EXISTS idx_chave_pix_chave_dados ON chave_pix (id_chave_dados);
+CREATE INDEX IF NOT EXISTS idx_evento_chave_pix_chave_dados ON evento_chave_pix (id_chave_dados);
+CREATE INDEX IF NOT EXISTS idx_relacionamento_ccs_chave_dados ON relacionamento_ccs (id_chave_dados);
+CREATE INDEX IF NOT EXISTS idx_bdv_ccs_chave_dados ON bem_direito_valor_ccs (id_chave_dados);
+CREATE INDEX IF NOT EXISTS idx_vinculados_bdv_ccs_chave_dados ON vinculados_bdv_ccs (id_chave_dados);
+
+CREATE INDEX IF NOT EXISTS idx_chave_pix_indices ON chave_pix USING GIN (indices);
+CREATE INDEX IF NOT EXISTS idx_evento_chave_pix_indices ON evento_chave_pix USING GIN (indices);
+CREATE INDEX IF NOT EXISTS idx_relacionamento_ccs_indices ON relacionamento_ccs USING GIN (indices);
+CREATE INDEX IF NOT EXISTS idx_bdv_ccs_indices ON bem_direito_valor_ccs USING GIN (indices);
+CREATE INDEX IF NOT EXISTS idx_vinculados_bdv_ccs_indices ON vinculados_bdv_ccs USING GIN (indices);
@@ -0,0 +1,13 @@
+-- A tabela volta vazia: as linhas de origem podem estar cifradas e não são
+-- legíveis aqui. Ela é preenchida de novo pelas gravações seguintes.
+CREATE TABLE IF NOT EXISTS pessoa_atributo (
+	id SERIAL PRIMARY KEY,
+	id_pessoa INT NOT NULL REFERENCES pessoa(id) ON DELETE CASCADE,
+	tipo VARCHAR(20) NOT NULL,
+	valor VARCHAR(255) NOT NULL,
+	fontes TEXT[] NOT NULL DEFAULT '{}',
+	primeira_ocorrencia TIMESTAMPTZ NOT NULL,
+	ultima_ocorrencia TIMESTAMPTZ NOT NULL,
+	ocorrencias INT NOT NULL DEFAULT 1,
+	UNIQUE (id_pessoa, tipo, valor)
+);
@@ -0,0 +1,4 @@
+-- Os atributos do perfil de uma pessoa passam a ser reconstruídos das linhas
+-- visíveis no escopo de quem consulta. A agregação gravada misturava linhas de
+-- todos os usuários e deixa de ser usada.
+DROP TABLE IF EXISTS pessoa_atributo;
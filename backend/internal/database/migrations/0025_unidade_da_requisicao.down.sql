@@ -0,0 +1,18 @@
+CREATE OR REPLACE FUNCTION consultapix_unidade_requisicao() RETURNS TRIGGER AS $$
+BEGIN
+	IF NEW.id_unidade IS NULL THEN
+		SELECT u.id_unidade INTO NEW.id_unidade FROM usuario u WHERE u.cpf = NEW.cpf_responsavel;
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_unidade_requisicao_pix
+	BEFORE INSERT ON requisicao_pix
+	FOR EACH ROW
+	EXECUTE FUNCTION consultapix_unidade_requisicao();
+
+CREATE TRIGGER trg_unidade_requisicao_ccs
+	BEFORE INSERT ON requisicao_relacionamento_ccs
+	FOR EACH ROW
+	EXECUTE FUNCTION consultapix_unidade_requisicao();
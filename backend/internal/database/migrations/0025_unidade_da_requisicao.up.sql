@@ -0,0 +1,5 @@
+-- A unidade da requisição passa a ser gravada pela aplicação, a partir do
+-- usuário autenticado; o gatilho a derivava do CPF informado na consulta
+DROP TRIGGER IF EXISTS trg_unidade_requisicao_ccs ON requisicao_relacionamento_ccs;
+DROP TRIGGER IF EXISTS trg_unidade_requisicao_pix ON requisicao_pix;
+DROP FUNCTION IF EXISTS consultapix_unidade_requisicao();
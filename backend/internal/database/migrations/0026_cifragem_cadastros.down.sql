@@ -0,0 +1,32 @@
+-- Os dados cifrados só podem ser lidos pela aplicação: reverta depois de
+-- devolvê-los às colunas em claro com "consultapix-api cifra decifrar"
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM requisicao_pix WHERE dados_cifrados IS NOT NULL)
+		OR EXISTS (SELECT 1 FROM requisicao_relacionamento_ccs WHERE dados_cifrados IS NOT NULL)
+		OR EXISTS (SELECT 1 FROM pessoa WHERE dados_cifrados IS NOT NULL)
+		OR EXISTS (SELECT 1 FROM conta WHERE dados_cifrados IS NOT NULL) THEN
+		RAISE EXCEPTION 'há dados cifrados; execute "consultapix-api cifra decifrar" antes de reverter';
+	END IF;
+END;
+$$;
+
+DROP INDEX IF EXISTS idx_requisicao_ccs_indices;
+DROP INDEX IF EXISTS idx_requisicao_pix_indices;
+
+ALTER TABLE conta
+	DROP COLUMN IF EXISTS indice_unico, DROP COLUMN IF EXISTS dados_cifrados, DROP COLUMN IF EXISTS id_chave_dados,
+	ALTER COLUMN numero SET NOT NULL,
+	ALTER COLUMN agencia SET NOT NULL,
+	ALTER COLUMN ispb SET NOT NULL;
+
+ALTER TABLE pessoa
+	DROP COLUMN IF EXISTS indice_unico, DROP COLUMN IF EXISTS dados_cifrados, DROP COLUMN IF EXISTS id_chave_dados,
+	ALTER COLUMN documento SET NOT NULL;
+
+ALTER TABLE requisicao_relacionamento_ccs
+	DROP COLUMN IF EXISTS indices, DROP COLUMN IF EXISTS dados_cifrados, DROP COLUMN IF EXISTS id_chave_dados;
+
+ALTER TABLE requisicao_pix
+	DROP COLUMN IF EXISTS indices,
+	ALTER COLUMN chave_busca SET NOT NULL;
@@ -0,0 +1,34 @@
+-- Passam a ser cifrados também os alvos das requisições (a chave buscada no
+-- PIX, o documento consultado e o nome devolvido no CCS) e os cadastros
+-- consolidados de pessoas e contas. Nestes, indice_unico é o índice cego do
+-- documento, ou de instituição, agência e número, que substitui a unicidade
+-- das colunas em claro quando elas ficam nulas.
+ALTER TABLE requisicao_pix
+	ALTER COLUMN chave_busca DROP NOT NULL,
+	ADD COLUMN indices BYTEA[];
+
+ALTER TABLE requisicao_relacionamento_ccs
+	ADD COLUMN id_chave_dados INT REFERENCES chave_dados(id),
+	ADD COLUMN dados_cifrados BYTEA,
+	ADD COLUMN indices BYTEA[];
+
+ALTER TABLE pessoa
+	ALTER COLUMN documento DROP NOT NULL,
+	ADD COLUMN id_chave_dados INT REFERENCES chave_dados(id),
+	ADD COLUMN dados_cifrados BYTEA,
+	ADD COLUMN indice_unico BYTEA UNIQUE;
+
+ALTER TABLE conta
+	ALTER COLUMN ispb DROP NOT NULL,
+	ALTER COLUMN agencia DROP NOT NULL,
+	ALTER COLUMN numero DROP NOT NULL,
+	ADD COLUMN id_chave_dados INT REFERENCES chave_dados(id),
+	ADD COLUMN dados_cifrados BYTEA,
+	ADD COLUMN indice_unico BYTEA UNIQUE;
+
+CREATE INDEX IF NOT EXISTS idx_requisicao_ccs_chave_dados ON requisicao_relacionamento_ccs (id_chave_dados);
+CREATE INDEX IF NOT EXISTS idx_pessoa_chave_dados ON pessoa (id_chave_dados);
+CREATE INDEX IF NOT EXISTS idx_conta_chave_dados ON conta (id_chave_dados);
+
+CREATE INDEX IF NOT EXISTS idx_requisicao_pix_indices ON requisicao_pix USING GIN (indices);
+CREATE INDEX IF NOT EXISTS idx_requisicao_ccs_indices ON requisicao_relacionamento_ccs USING GIN (indices);
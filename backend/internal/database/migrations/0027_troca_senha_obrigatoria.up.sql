@@ -0,0 +1,21 @@
+-- Contas que precisam trocar a senha no próximo login, independentemente da
+-- validade da política (o administrador padrão criado na instalação). A
+-- troca da senha, por qualquer caminho, desfaz a exigência.
+ALTER TABLE usuario ADD COLUMN troca_senha_obrigatoria BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE OR REPLACE FUNCTION consultapix_registrar_troca_senha() RETURNS TRIGGER AS $$
+BEGIN
+	IF NEW.password IS DISTINCT FROM OLD.password THEN
+		NEW.senha_alterada_em := NOW();
+		NEW.troca_senha_obrigatoria := FALSE;
+		IF OLD.password <> '' THEN
+			INSERT INTO historico_senha (id_usuario, hash) VALUES (OLD.id, OLD.password);
+			DELETE FROM historico_senha
+			WHERE id_usuario = OLD.id AND id NOT IN (
+				SELECT id FROM historico_senha WHERE id_usuario = OLD.id ORDER BY id DESC LIMIT 24
+			);
+		END IF;
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
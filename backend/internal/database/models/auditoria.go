@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// EventoAuditoria é um registro da trilha de auditoria
+type EventoAuditoria struct {
+	ID         int64                  `json:"id"`
+	Data       time.Time              `json:"data"`
+	CPFUsuario string                 `json:"cpfUsuario"`
+	Lotacao    string                 `json:"lotacao"`
+	Acao       string                 `json:"acao"`
+	Alvo       string                 `json:"alvo"`
+	Detalhes   map[string]interface{} `json:"detalhes"`
+}
@@ -0,0 +1,17 @@
+package models
+
+// ResultadoBusca é um registro encontrado pela busca textual, identificado pela
+// tabela de origem e pela requisição (PIX ou CCS) a que pertence
+type ResultadoBusca struct {
+	Tipo         string  `json:"tipo"`
+	Origem       string  `json:"origem"`
+	ID           int     `json:"id"`
+	IDRequisicao int     `json:"idRequisicao"`
+	Caso         string  `json:"caso"`
+	Nome         string  `json:"nome"`
+	Documento    string  `json:"documento"`
+	Chave        string  `json:"chave,omitempty"`
+	Agencia      string  `json:"agencia,omitempty"`
+	Conta        string  `json:"conta,omitempty"`
+	Relevancia   float64 `json:"relevancia"`
+}
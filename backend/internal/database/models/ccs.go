@@ -1,10 +1,12 @@
 package models
 
+import "time"
+
 type RequisicaoRelacionamentoCCS struct {
 	ID                  int                   `json:"id" db:"id"`
-	DataRequisicao      string                `json:"dataRequisicao" db:"data_requisicao"`
-	DataInicioConsulta  string                `json:"dataInicioConsulta" db:"data_inicio_consulta"`
-	DataFimConsulta     string                `json:"dataFimConsulta" db:"data_fim_consulta"`
+	DataRequisicao      time.Time             `json:"dataRequisicao" db:"data_requisicao"`
+	DataInicioConsulta  *time.Time            `json:"dataInicioConsulta" db:"data_inicio_consulta"`
+	DataFimConsulta     *time.Time            `json:"dataFimConsulta" db:"data_fim_consulta"`
 	CPFCNPJConsulta     string                `json:"cpfCnpjConsulta" db:"cpf_cnpj_consulta"`
 	NumeroProcesso      string                `json:"numeroProcesso" db:"numero_processo"`
 	MotivoBusca         string                `json:"motivoBusca" db:"motivo_busca"`
@@ -19,10 +21,13 @@ type RequisicaoRelacionamentoCCS struct {
 	Autorizado          bool                  `json:"autorizado" db:"autorizado"`
 	CPFAutorizacao      string                `json:"cpfAutorizacao" db:"cpf_autorizacao"`
 	NomeAutorizacao     string                `json:"nomeAutorizacao" db:"nome_autorizacao"`
-	DataHoraAutorizacao string                `json:"dataHoraAutorizacao" db:"data_hora_autorizacao"`
+	DataHoraAutorizacao *time.Time            `json:"dataHoraAutorizacao" db:"data_hora_autorizacao"`
 	TokenAutorizacao    string                `json:"tokenAutorizacao" db:"token_autorizacao"`
 	Status              string                `json:"status" db:"status"`
 	Detalhamento        bool                  `json:"detalhamento" db:"detalhamento"`
+	IDCredencialBacen   *int                  `json:"idCredencialBacen,omitempty" db:"id_credencial_bacen"`
+	UsuarioBacen        string                `json:"usuarioBacen" db:"usuario_bacen"`
+	IDUnidade           *int                  `json:"idUnidade,omitempty" db:"id_unidade"`
 }
 
 type RelacionamentoCCS struct {
@@ -37,10 +42,10 @@ type RelacionamentoCCS struct {
 	CNPJParticipante        string                `json:"cnpjParticipante" db:"cnpj_participante"`
 	NumeroBancoParticipante string                `json:"numeroBancoParticipante" db:"numero_banco_participante"`
 	NomeBancoParticipante   string                `json:"nomeBancoParticipante" db:"nome_banco_participante"`
-	DataInicioRelacionamento string               `json:"dataInicioRelacionamento" db:"data_inicio_relacionamento"`
-	DataFimRelacionamento   string                `json:"dataFimRelacionamento" db:"data_fim_relacionamento"`
+	DataInicioRelacionamento *time.Time           `json:"dataInicioRelacionamento" db:"data_inicio_relacionamento"`
+	DataFimRelacionamento   *time.Time            `json:"dataFimRelacionamento" db:"data_fim_relacionamento"`
 	IDRequisicao            int                   `json:"idRequisicao" db:"id_requisicao"`
-	DataRequisicaoDetalhamento string             `json:"dataRequisicaoDetalhamento" db:"data_requisicao_detalhamento"`
+	DataRequisicaoDetalhamento *time.Time         `json:"dataRequisicaoDetalhamento" db:"data_requisicao_detalhamento"`
 	StatusDetalhamento      string                `json:"statusDetalhamento" db:"status_detalhamento"`
 	RespondeDetalhamento    bool                  `json:"respondeDetalhamento" db:"responde_detalhamento"`
 	Resposta                bool                  `json:"resposta" db:"resposta"`
@@ -48,6 +53,7 @@ type RelacionamentoCCS struct {
 	CodigoIfResposta        string                `json:"codigoIfResposta" db:"codigo_if_resposta"`
 	NuopResposta            string                `json:"nuopResposta" db:"nuop_resposta"`
 	BemDireitoValorCCS      []BemDireitoValorCCS  `json:"bemDireitoValorCCS,omitempty"`
+	Cifrado                 *DadosCifrados        `json:"cifrado,omitempty"`
 }
 
 type BemDireitoValorCCS struct {
@@ -58,19 +64,36 @@ type BemDireitoValorCCS struct {
 	Conta             string              `json:"conta" db:"conta"`
 	Vinculo           string              `json:"vinculo" db:"vinculo"`
 	NomePessoa        string              `json:"nomePessoa" db:"nome_pessoa"`
-	DataInicio        string              `json:"dataInicio" db:"data_inicio"`
-	DataFim           string              `json:"dataFim" db:"data_fim"`
+	DataInicio        *time.Time          `json:"dataInicio" db:"data_inicio"`
+	DataFim           *time.Time          `json:"dataFim" db:"data_fim"`
 	IDRelacionamento  int                 `json:"idRelacionamento" db:"id_relacionamento"`
 	Vinculados        []VinculadosBDVCCS  `json:"vinculados,omitempty"`
+	Cifrado           *DadosCifrados      `json:"cifrado,omitempty"`
 }
 
 type VinculadosBDVCCS struct {
 	ID                 int    `json:"id" db:"id"`
 	IDBDV              int    `json:"idBDV" db:"id_bdv"`
-	DataInicio         string `json:"dataInicio" db:"data_inicio"`
-	DataFim            string `json:"dataFim" db:"data_fim"`
+	DataInicio         *time.Time `json:"dataInicio" db:"data_inicio"`
+	DataFim            *time.Time `json:"dataFim" db:"data_fim"`
 	IDPessoa           string `json:"idPessoa" db:"id_pessoa"`
 	NomePessoa         string `json:"nomePessoa" db:"nome_pessoa"`
 	NomePessoaReceita  string `json:"nomePessoaReceita" db:"nome_pessoa_receita"`
 	Tipo               string `json:"tipo" db:"tipo"`
-}
\ No newline at end of file
+	Cifrado            *DadosCifrados `json:"cifrado,omitempty"`
+}
+// ResumoRequisicaoCCS é a projeção leve usada nas listagens de histórico
+type ResumoRequisicaoCCS struct {
+	ID                        int       `json:"id"`
+	DataRequisicao            time.Time `json:"dataRequisicao"`
+	CPFCNPJConsulta           string    `json:"cpfCnpjConsulta"`
+	Nome                      string    `json:"nome"`
+	NumeroProcesso            string    `json:"numeroProcesso"`
+	NumeroRequisicao          string    `json:"numeroRequisicao"`
+	CPFResponsavel            string    `json:"cpfResponsavel"`
+	Lotacao                   string    `json:"lotacao"`
+	Caso                      string    `json:"caso"`
+	Status                    string    `json:"status"`
+	QuantidadeRelacionamentos int       `json:"quantidadeRelacionamentos"`
+	DetalhamentosConcluidos   int       `json:"detalhamentosConcluidos"`
+}
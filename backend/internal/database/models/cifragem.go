@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// DadosCifrados é o conteúdo cifrado de uma linha, como lido do banco. Os
+// repositórios decifram e preenchem os campos do modelo antes de devolvê-lo,
+// de modo que ele nunca chega às respostas da API.
+type DadosCifrados struct {
+	Chave int    `json:"chave"`
+	Dados []byte `json:"dados"`
+}
+
+// ChaveDados descreve uma chave de dados, sem o seu conteúdo
+type ChaveDados struct {
+	ID           int        `json:"id"`
+	Finalidade   string     `json:"finalidade"`
+	ChaveMestra  string     `json:"chaveMestra"`
+	Ativa        bool       `json:"ativa"`
+	CriadaEm     time.Time  `json:"criadaEm"`
+	DesativadaEm *time.Time `json:"desativadaEm,omitempty"`
+	Linhas       int        `json:"linhas"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Situações de um item na comparação entre duas requisições
+const (
+	SituacaoAdicionado = "adicionado"
+	SituacaoRemovido   = "removido"
+	SituacaoModificado = "modificado"
+)
+
+// AlteracaoCampo é a mudança de valor de um campo entre duas requisições
+type AlteracaoCampo struct {
+	Campo    string `json:"campo"`
+	Anterior string `json:"anterior"`
+	Atual    string `json:"atual"`
+}
+
+// ItemComparacao é uma chave, evento, relacionamento ou BDV que mudou.
+// Identificador é legível e inclui o item pai (por exemplo, a chave de um evento).
+type ItemComparacao struct {
+	Tipo          string           `json:"tipo"`
+	Identificador string           `json:"identificador"`
+	Situacao      string           `json:"situacao"`
+	Campos        []AlteracaoCampo `json:"campos,omitempty"`
+}
+
+// ComparacaoRequisicoes é o resultado da comparação entre duas requisições do mesmo alvo
+type ComparacaoRequisicoes struct {
+	Origem       string           `json:"origem"`
+	Alvo         string           `json:"alvo"`
+	IDAnterior   int              `json:"idAnterior"`
+	DataAnterior time.Time        `json:"dataAnterior"`
+	IDAtual      int              `json:"idAtual"`
+	DataAtual    time.Time        `json:"dataAtual"`
+	Adicionados  []ItemComparacao `json:"adicionados"`
+	Removidos    []ItemComparacao `json:"removidos"`
+	Modificados  []ItemComparacao `json:"modificados"`
+}
+
+// SemAlteracoes indica que as duas requisições trouxeram o mesmo resultado
+func (c *ComparacaoRequisicoes) SemAlteracoes() bool {
+	return len(c.Adicionados) == 0 && len(c.Removidos) == 0 && len(c.Modificados) == 0
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Conta consolida uma conta bancária citada em chaves PIX, eventos de chave e
+// BDVs do CCS, identificada por ISPB, agência e número normalizados
+type Conta struct {
+	ID                 int       `json:"id"`
+	ISPB               string    `json:"ispb"`
+	Agencia            string    `json:"agencia"`
+	Numero             string    `json:"numero"`
+	TipoConta          string    `json:"tipoConta"`
+	NomeInstituicao    string    `json:"nomeInstituicao"`
+	PrimeiraOcorrencia time.Time `json:"primeiraOcorrencia"`
+	UltimaOcorrencia   time.Time `json:"ultimaOcorrencia"`
+}
+
+// FonteConta é uma linha armazenada que cita a conta
+type FonteConta struct {
+	Tipo         string    `json:"tipo"`
+	Origem       string    `json:"origem"`
+	ID           int       `json:"id"`
+	IDRequisicao int       `json:"idRequisicao"`
+	Data         time.Time `json:"data"`
+}
+
+// ChaveConta é uma chave PIX que aponta ou apontou para a conta
+type ChaveConta struct {
+	Chave              string    `json:"chave"`
+	TipoChave          string    `json:"tipoChave"`
+	Status             string    `json:"status"`
+	Fontes             []string  `json:"fontes"`
+	PrimeiraOcorrencia time.Time `json:"primeiraOcorrencia"`
+	UltimaOcorrencia   time.Time `json:"ultimaOcorrencia"`
+}
+
+// PessoaConta é um titular ou vinculado (cotitular, representante) da conta
+type PessoaConta struct {
+	ID                 int       `json:"id"`
+	Documento          string    `json:"documento"`
+	Nome               string    `json:"nome"`
+	Papel              string    `json:"papel"`
+	Fontes             []string  `json:"fontes"`
+	PrimeiraOcorrencia time.Time `json:"primeiraOcorrencia"`
+	UltimaOcorrencia   time.Time `json:"ultimaOcorrencia"`
+}
+
+// DetalheConta responde quais chaves e quais pessoas apontam para a conta
+type DetalheConta struct {
+	Conta
+	Chaves  []ChaveConta  `json:"chaves"`
+	Pessoas []PessoaConta `json:"pessoas"`
+	Fontes  []FonteConta  `json:"fontes"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// LimiteCota é o limite diário e mensal de uma operação do BACEN para um
+// usuário (CPF) ou uma lotação. Alvo "*" vale para quem não tem limite próprio
+// e limites nulos significam ilimitado.
+type LimiteCota struct {
+	ID           int       `json:"id"`
+	Escopo       string    `json:"escopo"`
+	Alvo         string    `json:"alvo"`
+	Operacao     string    `json:"operacao"`
+	LimiteDiario *int      `json:"limiteDiario"`
+	LimiteMensal *int      `json:"limiteMensal"`
+	AtualizadoEm time.Time `json:"atualizadoEm"`
+}
+
+// UsoCota é o consumo de uma operação no dia e no mês corrente, com os limites
+// em vigor para o usuário ou para a lotação
+type UsoCota struct {
+	Escopo       string `json:"escopo"`
+	Alvo         string `json:"alvo"`
+	Operacao     string `json:"operacao"`
+	UsoDiario    int    `json:"usoDiario"`
+	LimiteDiario *int   `json:"limiteDiario"`
+	UsoMensal    int    `json:"usoMensal"`
+	LimiteMensal *int   `json:"limiteMensal"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// CredencialBacen é o perfil de acesso às APIs do BACEN de uma lotação. A senha
+// só existe cifrada no banco e nunca é devolvida pela API.
+type CredencialBacen struct {
+	ID           int       `json:"id"`
+	Lotacao      string    `json:"lotacao"`
+	Descricao    string    `json:"descricao"`
+	Usuario      string    `json:"usuario"`
+	SenhaCifrada []byte    `json:"-"`
+	Ativo        bool      `json:"ativo"`
+	CriadoEm     time.Time `json:"criadoEm"`
+	AtualizadoEm time.Time `json:"atualizadoEm"`
+}
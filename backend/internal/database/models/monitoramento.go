@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Monitoramento é uma chave PIX ou CPF/CNPJ reconsultado periodicamente
+// enquanto a autorização do caso estiver válida
+type Monitoramento struct {
+	ID                  int        `json:"id"`
+	TipoBusca           string     `json:"tipoBusca"`
+	Alvo                string     `json:"alvo"`
+	Caso                string     `json:"caso"`
+	Motivo              string     `json:"motivo"`
+	NumeroAutorizacao   string     `json:"numeroAutorizacao"`
+	AutorizacaoExpiraEm time.Time  `json:"autorizacaoExpiraEm"`
+	FrequenciaHoras     int        `json:"frequenciaHoras"`
+	CPFResponsavel      string     `json:"cpfResponsavel"`
+	Lotacao             string     `json:"lotacao"`
+	Responsaveis        []string   `json:"responsaveis"`
+	Ativo               bool       `json:"ativo"`
+	EncerradoEm         *time.Time `json:"encerradoEm"`
+	MotivoEncerramento  string     `json:"motivoEncerramento"`
+	UltimaExecucao      *time.Time `json:"ultimaExecucao"`
+	ProximaExecucao     time.Time  `json:"proximaExecucao"`
+	IDUltimaRequisicao  *int       `json:"idUltimaRequisicao"`
+	UltimoErro          string     `json:"ultimoErro"`
+	CriadoEm            time.Time  `json:"criadoEm"`
+}
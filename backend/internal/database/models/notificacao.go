@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Notificacao é uma mensagem da caixa de entrada de um usuário
+type Notificacao struct {
+	ID         int64                  `json:"id"`
+	CPFUsuario string                 `json:"cpfUsuario"`
+	Tipo       string                 `json:"tipo"`
+	Titulo     string                 `json:"titulo"`
+	Mensagem   string                 `json:"mensagem"`
+	Dados      map[string]interface{} `json:"dados"`
+	LidaEm     *time.Time             `json:"lidaEm"`
+	CriadoEm   time.Time              `json:"criadoEm"`
+}
+
+// PreferenciaNotificacao indica se o usuário recebe um evento por um canal
+type PreferenciaNotificacao struct {
+	Evento string `json:"evento"`
+	Canal  string `json:"canal"`
+	Ativo  bool   `json:"ativo"`
+}
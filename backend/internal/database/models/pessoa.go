@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Pessoa consolida, pelo documento normalizado, um CPF/CNPJ que aparece nas
+// consultas PIX e CCS
+type Pessoa struct {
+	ID                 int       `json:"id"`
+	Documento          string    `json:"documento"`
+	TipoPessoa         string    `json:"tipoPessoa"`
+	NomePrincipal      string    `json:"nomePrincipal"`
+	PrimeiraOcorrencia time.Time `json:"primeiraOcorrencia"`
+	UltimaOcorrencia   time.Time `json:"ultimaOcorrencia"`
+}
+
+// AtributoPessoa é um nome, chave, conta ou instituição observado para a pessoa
+type AtributoPessoa struct {
+	Valor              string    `json:"valor"`
+	Fontes             []string  `json:"fontes"`
+	PrimeiraOcorrencia time.Time `json:"primeiraOcorrencia"`
+	UltimaOcorrencia   time.Time `json:"ultimaOcorrencia"`
+	Ocorrencias        int       `json:"ocorrencias"`
+}
+
+// ReferenciaRequisicao aponta para uma requisição PIX ou CCS
+type ReferenciaRequisicao struct {
+	Origem         string    `json:"origem"`
+	ID             int       `json:"id"`
+	Data           time.Time `json:"data"`
+	Caso           string    `json:"caso"`
+	CPFResponsavel string    `json:"cpfResponsavel"`
+}
+
+// PerfilPessoa reúne tudo o que se sabe sobre uma pessoa
+type PerfilPessoa struct {
+	Pessoa
+	Nomes        []AtributoPessoa       `json:"nomes"`
+	Chaves       []AtributoPessoa       `json:"chaves"`
+	Contas       []AtributoPessoa       `json:"contas"`
+	Instituicoes []AtributoPessoa       `json:"instituicoes"`
+	Requisicoes  []ReferenciaRequisicao `json:"requisicoes"`
+}
@@ -17,8 +17,11 @@ type RequisicaoPix struct {
 	Autorizado      bool        `json:"autorizado" db:"autorizado"`
 	CPFAutorizacao  string      `json:"cpfAutorizacao" db:"cpf_autorizacao"`
 	NomeAutorizacao string      `json:"nomeAutorizacao" db:"nome_autorizacao"`
-	DataHoraAutorizacao string  `json:"dataHoraAutorizacao" db:"data_hora_autorizacao"`
+	DataHoraAutorizacao *time.Time `json:"dataHoraAutorizacao" db:"data_hora_autorizacao"`
 	TokenAutorizacao string     `json:"tokenAutorizacao" db:"token_autorizacao"`
+	IDCredencialBacen *int      `json:"idCredencialBacen,omitempty" db:"id_credencial_bacen"`
+	UsuarioBacen     string     `json:"usuarioBacen" db:"usuario_bacen"`
+	IDUnidade        *int       `json:"idUnidade,omitempty" db:"id_unidade"`
 }
 
 type ChavePix struct {
@@ -26,7 +29,7 @@ type ChavePix struct {
 	Chave                  string            `json:"chave" db:"chave"`
 	TipoChave              string            `json:"tipoChave" db:"tipo_chave"`
 	Status                 string            `json:"status" db:"status"`
-	DataAberturaReivindicacao string         `json:"dataAberturaReivindicacao" db:"data_abertura_reivindicacao"`
+	DataAberturaReivindicacao *time.Time     `json:"dataAberturaReivindicacao" db:"data_abertura_reivindicacao"`
 	CPFCNPJ                string            `json:"cpfCnpj" db:"cpf_cnpj"`
 	NomeProprietario       string            `json:"nomeProprietario" db:"nome_proprietario"`
 	NomeFantasia           string            `json:"nomeFantasia" db:"nome_fantasia"`
@@ -34,15 +37,16 @@ type ChavePix struct {
 	Agencia                string            `json:"agencia" db:"agencia"`
 	NumeroConta            string            `json:"numeroConta" db:"numero_conta"`
 	TipoConta              string            `json:"tipoConta" db:"tipo_conta"`
-	DataAberturaConta      string            `json:"dataAberturaConta" db:"data_abertura_conta"`
-	ProprietarioDaChaveDesde string          `json:"proprietarioDaChaveDesde" db:"proprietario_da_chave_desde"`
-	DataCriacao            string            `json:"dataCriacao" db:"data_criacao"`
-	UltimaModificacao      string            `json:"ultimaModificacao" db:"ultima_modificacao"`
+	DataAberturaConta      *time.Time        `json:"dataAberturaConta" db:"data_abertura_conta"`
+	ProprietarioDaChaveDesde *time.Time      `json:"proprietarioDaChaveDesde" db:"proprietario_da_chave_desde"`
+	DataCriacao            *time.Time        `json:"dataCriacao" db:"data_criacao"`
+	UltimaModificacao      *time.Time        `json:"ultimaModificacao" db:"ultima_modificacao"`
 	NumeroBanco            string            `json:"numeroBanco" db:"numero_banco"`
 	NomeBanco              string            `json:"nomeBanco" db:"nome_banco"`
 	CPFCNPJBusca           string            `json:"cpfCnpjBusca" db:"cpf_cnpj_busca"`
 	NomeProprietarioBusca  string            `json:"nomeProprietarioBusca" db:"nome_proprietario_busca"`
 	EventosVinculo         []EventoChavePix  `json:"eventosVinculo,omitempty"`
+	Cifrado                *DadosCifrados    `json:"cifrado,omitempty"`
 	IDRequisicao           int               `json:"idRequisicao" db:"id_requisicao"`
 }
 
@@ -50,7 +54,7 @@ type EventoChavePix struct {
 	ID                int     `json:"id" db:"id"`
 	TipoEvento        string  `json:"tipoEvento" db:"tipo_evento"`
 	MotivoEvento      string  `json:"motivoEvento" db:"motivo_evento"`
-	DataEvento        string  `json:"dataEvento" db:"data_evento"`
+	DataEvento        *time.Time `json:"dataEvento" db:"data_evento"`
 	Chave             string  `json:"chave" db:"chave"`
 	TipoChave         string  `json:"tipoChave" db:"tipo_chave"`
 	CPFCNPJ           string  `json:"cpfCnpj" db:"cpf_cnpj"`
@@ -60,8 +64,23 @@ type EventoChavePix struct {
 	Agencia           string  `json:"agencia" db:"agencia"`
 	NumeroConta       string  `json:"numeroConta" db:"numero_conta"`
 	TipoConta         string  `json:"tipoConta" db:"tipo_conta"`
-	DataAberturaConta string  `json:"dataAberturaConta" db:"data_abertura_conta"`
+	DataAberturaConta *time.Time `json:"dataAberturaConta" db:"data_abertura_conta"`
 	NumeroBanco       string  `json:"numeroBanco" db:"numero_banco"`
 	NomeBanco         string  `json:"nomeBanco" db:"nome_banco"`
+	Cifrado           *DadosCifrados `json:"cifrado,omitempty"`
 	IDChave           int     `json:"idChave" db:"id_chave"`
-}
\ No newline at end of file
+}
+// ResumoRequisicaoPix é a projeção leve usada nas listagens de histórico
+type ResumoRequisicaoPix struct {
+	ID              int       `json:"id"`
+	Data            time.Time `json:"data"`
+	CPFResponsavel  string    `json:"cpfResponsavel"`
+	Lotacao         string    `json:"lotacao"`
+	Caso            string    `json:"caso"`
+	TipoBusca       string    `json:"tipoBusca"`
+	ChaveBusca      string    `json:"chaveBusca"`
+	MotivoBusca     string    `json:"motivoBusca"`
+	Resultado       string    `json:"resultado"`
+	Autorizado      bool      `json:"autorizado"`
+	QuantidadeChaves int      `json:"quantidadeChaves"`
+}
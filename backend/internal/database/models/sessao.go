@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Sessao é um login ativo em um dispositivo
+type Sessao struct {
+	ID              int64     `json:"id"`
+	IDUsuario       int       `json:"-"`
+	Familia         string    `json:"-"`
+	IP              string    `json:"ip"`
+	AgenteUsuario   string    `json:"agenteUsuario"`
+	CriadaEm        time.Time `json:"criadaEm"`
+	UltimaAtividade time.Time `json:"ultimaAtividade"`
+	Encerrada       bool      `json:"-"`
+	// Atual indica a sessão usada na própria requisição
+	Atual bool `json:"atual"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Unidade é um nó da hierarquia organizacional: departamento, regional ou
+// delegacia. Só o departamento não tem unidade superior.
+type Unidade struct {
+	ID         int       `json:"id"`
+	Nome       string    `json:"nome"`
+	Tipo       string    `json:"tipo"`
+	IDSuperior *int      `json:"idSuperior"`
+	CriadaEm   time.Time `json:"criadaEm"`
+	// Usuarios é quantos usuários estão atribuídos diretamente à unidade
+	Usuarios int `json:"usuarios"`
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type Usuario struct {
 	ID        int    `json:"id" db:"id"`
 	Nome      string `json:"nome" db:"nome"`
@@ -9,4 +11,47 @@ type Usuario struct {
 	Lotacao   string `json:"lotacao" db:"lotacao"`
 	Matricula string `json:"matricula" db:"matricula"`
 	Admin     bool   `json:"admin" db:"admin"`
-}
\ No newline at end of file
+	// DoisFatoresAtivo indica que o login exige o código TOTP ou um código de recuperação
+	DoisFatoresAtivo bool `json:"doisFatoresAtivo" db:"totp_ativo"`
+	// Origem é o provedor da identidade: local (senha própria) ou ldap
+	Origem string `json:"origem" db:"origem"`
+	// Situacao é pendente enquanto o autocadastro aguarda a confirmação do
+	// e-mail e a aprovação; só usuários ativos entram
+	Situacao string `json:"situacao" db:"situacao"`
+	// SenhaAlteradaEm é a data da última troca da senha local, base da expiração
+	SenhaAlteradaEm time.Time `json:"-" db:"senha_alterada_em"`
+	// TrocaSenhaObrigatoria exige a troca da senha local no próximo login,
+	// independentemente da validade (o administrador padrão da instalação)
+	TrocaSenhaObrigatoria bool `json:"-" db:"troca_senha_obrigatoria"`
+	// Perfil de acesso às requisições: analista, supervisor ou auditor
+	Perfil string `json:"perfil" db:"perfil"`
+	// IDUnidade é a unidade do usuário na hierarquia; zero enquanto não atribuída
+	IDUnidade int `json:"idUnidade" db:"id_unidade"`
+}
+
+// CadastroPendente é um autocadastro aguardando a decisão de um administrador
+// da lotação
+type CadastroPendente struct {
+	ID              int       `json:"id"`
+	Nome            string    `json:"nome"`
+	CPF             string    `json:"cpf"`
+	Email           string    `json:"email"`
+	Lotacao         string    `json:"lotacao"`
+	Matricula       string    `json:"matricula"`
+	CadastradoEm    time.Time `json:"cadastradoEm"`
+	EmailVerificado bool      `json:"emailVerificado"`
+}
+
+// UsuarioInativo é uma conta desativada, com o pedido de reativação, se houver
+type UsuarioInativo struct {
+	ID                     int        `json:"id"`
+	Nome                   string     `json:"nome"`
+	CPF                    string     `json:"cpf"`
+	Email                  string     `json:"email"`
+	Lotacao                string     `json:"lotacao"`
+	DesativadoEm           time.Time  `json:"desativadoEm"`
+	DesativadoPor          string     `json:"desativadoPor"`
+	Motivo                 string     `json:"motivo"`
+	ReativacaoSolicitadaEm *time.Time `json:"reativacaoSolicitadaEm,omitempty"`
+	Justificativa          string     `json:"justificativa,omitempty"`
+}
@@ -0,0 +1,49 @@
+package auditoria
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/handlers/bacen/historico"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+type Handler struct {
+	auditoria *repository.AuditoriaRepository
+}
+
+type ListaResponse struct {
+	Itens         []models.EventoAuditoria `json:"itens"`
+	ProximoCursor string                   `json:"proximoCursor,omitempty"`
+}
+
+func NewHandler() *Handler {
+	return &Handler{
+		auditoria: repository.NewAuditoriaRepository(),
+	}
+}
+
+// Handle lista a trilha de auditoria. Aceita acao, cpfResponsavel (usuário da
+// ação), alvo, dataInicio, dataFim e a paginação do histórico.
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	filtro, err := historico.ParseFiltro(r, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	itens, proximoCursor, err := h.auditoria.ListarEventos(r.URL.Query().Get("acao"), filtro)
+	if err == repository.ErrCursorInvalido {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao buscar auditoria", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ListaResponse{Itens: itens, ProximoCursor: proximoCursor})
+}
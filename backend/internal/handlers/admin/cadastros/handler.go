@@ -0,0 +1,84 @@
+package cadastros
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/handlers/bacen/historico"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/auth"
+)
+
+type Handler struct {
+	cadastroService *auth.CadastroService
+}
+
+// RejeitarRequest informa, opcionalmente, o motivo enviado ao usuário
+type RejeitarRequest struct {
+	Motivo string `json:"motivo"`
+}
+
+func NewHandler(cfg *config.Config) *Handler {
+	return &Handler{
+		cadastroService: auth.NewCadastroService(cfg),
+	}
+}
+
+// HandleListar lista os autocadastros pendentes da lotação do administrador
+func (h *Handler) HandleListar(w http.ResponseWriter, r *http.Request) {
+	admin := middleware.UsuarioAutenticado(r)
+	cadastros, err := h.cadastroService.ListarPendentes(admin.Lotacao)
+	if err != nil {
+		http.Error(w, "Erro ao listar os cadastros pendentes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(cadastros)
+}
+
+// HandleAprovar libera o acesso de um cadastro com e-mail confirmado
+func (h *Handler) HandleAprovar(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	admin := middleware.UsuarioAutenticado(r)
+	escreverDecisao(w, h.cadastroService.Aprovar(admin.CPF, admin.Lotacao, id))
+}
+
+// HandleRejeitar recusa um cadastro; ele é removido na próxima limpeza
+func (h *Handler) HandleRejeitar(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// O corpo é opcional
+	var req RejeitarRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	admin := middleware.UsuarioAutenticado(r)
+	escreverDecisao(w, h.cadastroService.Rejeitar(admin.CPF, admin.Lotacao, id, strings.TrimSpace(req.Motivo)))
+}
+
+func escreverDecisao(w http.ResponseWriter, err error) {
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case repository.ErrCadastroNaoEncontrado:
+		http.Error(w, "Cadastro pendente não encontrado na sua lotação", http.StatusNotFound)
+	case repository.ErrEmailNaoVerificado:
+		http.Error(w, "O usuário ainda não confirmou o e-mail", http.StatusConflict)
+	default:
+		http.Error(w, "Erro ao registrar a decisão", http.StatusInternalServerError)
+	}
+}
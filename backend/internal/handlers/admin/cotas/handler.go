@@ -0,0 +1,90 @@
+package cotas
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/handlers/bacen/historico"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+type Handler struct {
+	cotas *repository.CotaRepository
+}
+
+func NewHandler() *Handler {
+	return &Handler{
+		cotas: repository.NewCotaRepository(),
+	}
+}
+
+// HandleListar lista os limites de cota configurados
+func (h *Handler) HandleListar(w http.ResponseWriter, r *http.Request) {
+	limites, err := h.cotas.ListarLimites()
+	if err != nil {
+		http.Error(w, "Erro ao listar limites de cota", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(limites)
+}
+
+// HandleSalvar cria ou substitui o limite de um alvo para uma operação
+func (h *Handler) HandleSalvar(w http.ResponseWriter, r *http.Request) {
+	var limite models.LimiteCota
+	if err := json.NewDecoder(r.Body).Decode(&limite); err != nil {
+		http.Error(w, "Falha ao processar requisição", http.StatusBadRequest)
+		return
+	}
+
+	limite.Alvo = strings.TrimSpace(limite.Alvo)
+	switch {
+	case !repository.EscopoCotaValido(limite.Escopo):
+		http.Error(w, "Escopo inválido: use usuario ou lotacao", http.StatusBadRequest)
+		return
+	case !repository.OperacaoCotaValida(limite.Operacao):
+		http.Error(w, "Operação inválida: use "+strings.Join(repository.OperacoesCota, ", "), http.StatusBadRequest)
+		return
+	case limite.Alvo == "":
+		http.Error(w, "Informe o alvo (CPF, lotação ou * para o padrão)", http.StatusBadRequest)
+		return
+	case (limite.LimiteDiario != nil && *limite.LimiteDiario < 0) || (limite.LimiteMensal != nil && *limite.LimiteMensal < 0):
+		http.Error(w, "Os limites não podem ser negativos", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.cotas.SalvarLimite(&limite); err != nil {
+		http.Error(w, "Erro ao salvar limite de cota", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(limite)
+}
+
+// HandleRemover exclui um limite de cota
+func (h *Handler) HandleRemover(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.cotas.RemoverLimite(id)
+	if err == repository.ErrLimiteNaoEncontrado {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao remover limite de cota", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+package credenciaisbacen
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/handlers/bacen/historico"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/bacen"
+)
+
+type Handler struct {
+	service *bacen.CredenciaisBacenService
+}
+
+// SalvarRequest cria (sem id) ou atualiza um perfil. Na atualização, senha
+// vazia mantém a senha atual.
+type SalvarRequest struct {
+	ID        int    `json:"id"`
+	Lotacao   string `json:"lotacao"`
+	Descricao string `json:"descricao"`
+	Usuario   string `json:"usuario"`
+	Senha     string `json:"senha"`
+	Ativo     bool   `json:"ativo"`
+}
+
+func NewHandler(cfg *config.Config) *Handler {
+	return &Handler{
+		service: bacen.NewCredenciaisBacenService(cfg),
+	}
+}
+
+// HandleListar lista os perfis de credencial, sem as senhas
+func (h *Handler) HandleListar(w http.ResponseWriter, r *http.Request) {
+	credenciais, err := h.service.Listar()
+	if err != nil {
+		http.Error(w, "Erro ao listar credenciais do BACEN", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(credenciais)
+}
+
+// HandleSalvar cria ou atualiza um perfil de credencial
+func (h *Handler) HandleSalvar(w http.ResponseWriter, r *http.Request) {
+	var req SalvarRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Falha ao processar requisição", http.StatusBadRequest)
+		return
+	}
+
+	credencial := &models.CredencialBacen{
+		ID:        req.ID,
+		Lotacao:   req.Lotacao,
+		Descricao: req.Descricao,
+		Usuario:   req.Usuario,
+		Ativo:     req.Ativo,
+	}
+
+	err := h.service.Salvar(credencial, req.Senha)
+	switch {
+	case err == repository.ErrCredencialNaoEncontrada:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err == bacen.ErrChaveCredenciaisAusente:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(credencial)
+}
+
+// HandleRemover exclui um perfil de credencial
+func (h *Handler) HandleRemover(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.service.Remover(id)
+	if err == repository.ErrCredencialNaoEncontrada {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao remover credencial do BACEN", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,109 @@
+package desativacoes
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/handlers/bacen/historico"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/auth"
+)
+
+type Handler struct {
+	desativacaoService *auth.DesativacaoService
+}
+
+// MotivoRequest informa o motivo da desativação ou, opcionalmente, da
+// rejeição do pedido de reativação
+type MotivoRequest struct {
+	Motivo string `json:"motivo"`
+}
+
+func NewHandler(cfg *config.Config) *Handler {
+	return &Handler{
+		desativacaoService: auth.NewDesativacaoService(cfg),
+	}
+}
+
+// HandleListar lista as contas desativadas, com os pedidos de reativação primeiro
+func (h *Handler) HandleListar(w http.ResponseWriter, r *http.Request) {
+	usuarios, err := h.desativacaoService.ListarInativos()
+	if err != nil {
+		log.Printf("Erro ao listar os usuários desativados: %v", err)
+		http.Error(w, "Erro ao listar os usuários desativados", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(usuarios)
+}
+
+// HandleDesativar bloqueia o acesso do usuário, mantendo o histórico dele
+func (h *Handler) HandleDesativar(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req MotivoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Falha ao processar requisição", http.StatusBadRequest)
+		return
+	}
+
+	admin := middleware.UsuarioAutenticado(r)
+	escreverDecisao(w, h.desativacaoService.Desativar(admin.CPF, admin.Lotacao, admin.ID, id, req.Motivo))
+}
+
+// HandleReativar devolve o acesso a uma conta desativada
+func (h *Handler) HandleReativar(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	admin := middleware.UsuarioAutenticado(r)
+	escreverDecisao(w, h.desativacaoService.Reativar(admin.CPF, admin.Lotacao, id))
+}
+
+// HandleRejeitar recusa o pedido de reativação; a conta continua desativada
+func (h *Handler) HandleRejeitar(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// O corpo é opcional
+	var req MotivoRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	admin := middleware.UsuarioAutenticado(r)
+	escreverDecisao(w, h.desativacaoService.RejeitarReativacao(admin.CPF, admin.Lotacao, id, strings.TrimSpace(req.Motivo)))
+}
+
+func escreverDecisao(w http.ResponseWriter, err error) {
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case auth.ErrMotivoDesativacao:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case auth.ErrAutoDesativacao:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case repository.ErrUsuarioNaoEncontrado:
+		http.Error(w, "Usuário ativo não encontrado", http.StatusNotFound)
+	case repository.ErrUsuarioNaoInativo:
+		http.Error(w, "Usuário desativado ou pedido de reativação não encontrado", http.StatusNotFound)
+	default:
+		log.Printf("Erro ao registrar a desativação ou reativação: %v", err)
+		http.Error(w, "Erro ao registrar a decisão", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,106 @@
+package doisfatores
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/handlers/bacen/historico"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/auth"
+)
+
+type Handler struct {
+	doisFatores *auth.DoisFatoresService
+	usuarios    *repository.UserRepository
+}
+
+// PoliticaRequest define a obrigatoriedade do segundo fator para um papel
+type PoliticaRequest struct {
+	Papel       string `json:"papel"`
+	Obrigatorio bool   `json:"obrigatorio"`
+}
+
+// ResetRequest justifica a remoção do segundo fator de um usuário
+type ResetRequest struct {
+	Justificativa string `json:"justificativa"`
+}
+
+func NewHandler(cfg *config.Config) *Handler {
+	return &Handler{
+		doisFatores: auth.NewDoisFatoresService(cfg),
+		usuarios:    repository.NewUserRepository(),
+	}
+}
+
+// HandlePolitica retorna, por papel (admin, usuario), se o segundo fator é obrigatório
+func (h *Handler) HandlePolitica(w http.ResponseWriter, r *http.Request) {
+	politica, err := h.doisFatores.Politica()
+	if err != nil {
+		http.Error(w, "Erro ao consultar a política de dois fatores", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(politica)
+}
+
+// HandleSalvarPolitica altera a obrigatoriedade de um papel
+func (h *Handler) HandleSalvarPolitica(w http.ResponseWriter, r *http.Request) {
+	var req PoliticaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Falha ao processar requisição", http.StatusBadRequest)
+		return
+	}
+	if req.Papel != repository.PapelAdmin && req.Papel != repository.PapelUsuario {
+		http.Error(w, "Papel inválido: use "+strings.Join(repository.PapeisUsuario, ", "), http.StatusBadRequest)
+		return
+	}
+
+	admin := middleware.UsuarioAutenticado(r)
+	if err := h.doisFatores.SalvarPolitica(admin.CPF, admin.Lotacao, req.Papel, req.Obrigatorio); err != nil {
+		http.Error(w, "Erro ao salvar a política de dois fatores", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleReset remove o segundo fator de um usuário que perdeu o dispositivo e
+// os códigos de recuperação. A justificativa fica na auditoria.
+func (h *Handler) HandleReset(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req ResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Falha ao processar requisição", http.StatusBadRequest)
+		return
+	}
+	req.Justificativa = strings.TrimSpace(req.Justificativa)
+	if req.Justificativa == "" {
+		http.Error(w, "Informe a justificativa", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.usuarios.FindByID(id)
+	if err != nil {
+		http.Error(w, "Usuário não encontrado", http.StatusNotFound)
+		return
+	}
+
+	admin := middleware.UsuarioAutenticado(r)
+	if err := h.doisFatores.Resetar(admin.CPF, admin.Lotacao, user, req.Justificativa); err != nil {
+		http.Error(w, "Erro ao remover a autenticação em dois fatores", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
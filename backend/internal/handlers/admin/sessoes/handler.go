@@ -0,0 +1,110 @@
+package sessoes
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/handlers/bacen/historico"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/auth"
+)
+
+type Handler struct {
+	sessaoService *auth.SessaoService
+	usuarios      *repository.UserRepository
+}
+
+func NewHandler(cfg *config.Config) *Handler {
+	return &Handler{
+		sessaoService: auth.NewSessaoService(cfg),
+		usuarios:      repository.NewUserRepository(),
+	}
+}
+
+// HandleListar lista as sessões ativas de um usuário
+func (h *Handler) HandleListar(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	admin := middleware.UsuarioAutenticado(r)
+	atual := int64(0)
+	if admin.ID == id {
+		atual = admin.Sessao
+	}
+	sessoes, err := h.sessaoService.Listar(id, atual)
+	if err != nil {
+		log.Printf("Erro ao listar as sessões do usuário %d: %v", id, err)
+		http.Error(w, "Erro ao listar as sessões", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(sessoes)
+}
+
+// HandleEncerrar encerra uma sessão do usuário
+func (h *Handler) HandleEncerrar(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	idSessao, err := strconv.ParseInt(mux.Vars(r)["sid"], 10, 64)
+	if err != nil {
+		http.Error(w, "ID de sessão inválido", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.usuarios.FindByID(id)
+	if err != nil {
+		http.Error(w, "Usuário não encontrado", http.StatusNotFound)
+		return
+	}
+
+	admin := middleware.UsuarioAutenticado(r)
+	err = h.sessaoService.EncerrarPorAdmin(admin.CPF, admin.Lotacao, user, idSessao)
+	if err == repository.ErrSessaoNaoEncontrada {
+		http.Error(w, "Sessão não encontrada", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erro ao encerrar a sessão %d: %v", idSessao, err)
+		http.Error(w, "Erro ao encerrar a sessão", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleEncerrarTodas encerra todas as sessões do usuário, em qualquer dispositivo
+func (h *Handler) HandleEncerrarTodas(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.usuarios.FindByID(id)
+	if err != nil {
+		http.Error(w, "Usuário não encontrado", http.StatusNotFound)
+		return
+	}
+
+	admin := middleware.UsuarioAutenticado(r)
+	if err := h.sessaoService.EncerrarTodasPorAdmin(admin.CPF, admin.Lotacao, user); err != nil {
+		log.Printf("Erro ao encerrar as sessões do usuário %d: %v", id, err)
+		http.Error(w, "Erro ao encerrar as sessões", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,204 @@
+package unidades
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/handlers/bacen/historico"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+type Handler struct {
+	unidades  *repository.UnidadeRepository
+	usuarios  *repository.UserRepository
+	auditoria *repository.AuditoriaRepository
+}
+
+// AcessoRequest atribui ao usuário a unidade (zero para nenhuma) e o perfil de acesso
+type AcessoRequest struct {
+	IDUnidade int    `json:"idUnidade"`
+	Perfil    string `json:"perfil"`
+}
+
+func NewHandler() *Handler {
+	return &Handler{
+		unidades:  repository.NewUnidadeRepository(),
+		usuarios:  repository.NewUserRepository(),
+		auditoria: repository.NewAuditoriaRepository(),
+	}
+}
+
+// HandleListar lista a hierarquia de unidades, do departamento à delegacia
+func (h *Handler) HandleListar(w http.ResponseWriter, r *http.Request) {
+	unidades, err := h.unidades.Listar()
+	if err != nil {
+		http.Error(w, "Erro ao listar unidades", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(unidades)
+}
+
+// HandleCriar cadastra uma unidade subordinada à unidade do nível acima
+func (h *Handler) HandleCriar(w http.ResponseWriter, r *http.Request) {
+	var unidade models.Unidade
+	if err := json.NewDecoder(r.Body).Decode(&unidade); err != nil {
+		http.Error(w, "Falha ao processar requisição", http.StatusBadRequest)
+		return
+	}
+	if !repository.TipoUnidadeValido(unidade.Tipo) {
+		http.Error(w, "Tipo inválido: use "+strings.Join(repository.TiposUnidade, ", "), http.StatusBadRequest)
+		return
+	}
+	h.salvar(w, r, &unidade, h.unidades.Criar, http.StatusCreated)
+}
+
+// HandleAtualizar renomeia a unidade ou a move para outra superior; o tipo não muda
+func (h *Handler) HandleAtualizar(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var unidade models.Unidade
+	if err := json.NewDecoder(r.Body).Decode(&unidade); err != nil {
+		http.Error(w, "Falha ao processar requisição", http.StatusBadRequest)
+		return
+	}
+	unidade.ID = id
+	h.salvar(w, r, &unidade, h.unidades.Atualizar, http.StatusOK)
+}
+
+func (h *Handler) salvar(w http.ResponseWriter, r *http.Request, unidade *models.Unidade, gravar func(*models.Unidade) error, status int) {
+	unidade.Nome = strings.TrimSpace(unidade.Nome)
+	if unidade.Nome == "" {
+		http.Error(w, "Informe o nome da unidade", http.StatusBadRequest)
+		return
+	}
+
+	err := gravar(unidade)
+	var hierarquia *repository.ErroHierarquiaUnidade
+	switch {
+	case err == repository.ErrUnidadeNaoEncontrada:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.As(err, &hierarquia):
+		http.Error(w, hierarquia.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("Erro ao salvar a unidade %q: %v", unidade.Nome, err)
+		http.Error(w, "Erro ao salvar a unidade", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditar(r, repository.AcaoUnidadeAlterada, unidade.Nome, map[string]interface{}{
+		"idUnidade": unidade.ID, "tipo": unidade.Tipo, "idSuperior": unidade.IDSuperior,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(unidade)
+}
+
+// HandleRemover exclui uma unidade sem subordinadas, usuários nem requisições
+func (h *Handler) HandleRemover(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	unidade, err := h.unidades.Buscar(id)
+	if err == nil {
+		err = h.unidades.Remover(id)
+	}
+	switch {
+	case err == repository.ErrUnidadeNaoEncontrada:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err == repository.ErrUnidadeEmUso:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Erro ao remover a unidade", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditar(r, repository.AcaoUnidadeAlterada, unidade.Nome, map[string]interface{}{
+		"idUnidade": id, "removida": true,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAtribuir define a unidade e o perfil de acesso de um usuário. Os
+// tokens dele são invalidados, pois carregam o escopo antigo.
+func (h *Handler) HandleAtribuir(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req AcessoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Falha ao processar requisição", http.StatusBadRequest)
+		return
+	}
+	if !repository.PerfilAcessoValido(req.Perfil) {
+		http.Error(w, "Perfil inválido: use "+strings.Join(repository.PerfisAcesso, ", "), http.StatusBadRequest)
+		return
+	}
+	if req.IDUnidade < 0 {
+		http.Error(w, "Unidade inválida", http.StatusBadRequest)
+		return
+	}
+	if req.IDUnidade > 0 {
+		if _, err := h.unidades.Buscar(req.IDUnidade); err != nil {
+			http.Error(w, "Unidade não encontrada", http.StatusBadRequest)
+			return
+		}
+	}
+
+	user, err := h.usuarios.FindByID(id)
+	if err != nil {
+		http.Error(w, "Usuário não encontrado", http.StatusNotFound)
+		return
+	}
+
+	if err := h.unidades.AtribuirUsuario(user.ID, req.IDUnidade, req.Perfil); err != nil {
+		log.Printf("Erro ao atribuir a unidade do usuário %d: %v", id, err)
+		http.Error(w, "Erro ao atribuir a unidade", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditar(r, repository.AcaoAcessoUsuarioAlterado, user.CPF, map[string]interface{}{
+		"idUsuario":         user.ID,
+		"perfilAnterior":    user.Perfil,
+		"perfil":            req.Perfil,
+		"idUnidadeAnterior": user.IDUnidade,
+		"idUnidade":         req.IDUnidade,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) auditar(r *http.Request, acao, alvo string, detalhes map[string]interface{}) {
+	admin := middleware.UsuarioAutenticado(r)
+	if err := h.auditoria.Registrar(&models.EventoAuditoria{
+		CPFUsuario: admin.CPF,
+		Lotacao:    admin.Lotacao,
+		Acao:       acao,
+		Alvo:       alvo,
+		Detalhes:   detalhes,
+	}); err != nil {
+		log.Printf("Erro ao auditar %s de %s: %v", acao, alvo, err)
+	}
+}
@@ -6,16 +6,20 @@ import (
 	"strconv"
 
 	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
 	"github.com/tassyosilva/consultapix/internal/services/bacen"
 )
 
 type Handler struct {
 	ccsService *bacen.CCSService
+	ccsRepo    *repository.CCSRepository
 }
 
 func NewHandler(cfg *config.Config) *Handler {
 	return &Handler{
 		ccsService: bacen.NewCCSService(cfg),
+		ccsRepo:    repository.NewCCSRepository(),
 	}
 }
 
@@ -37,7 +41,15 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// O token já foi validado pelo middleware de autenticação
+	// Só detalha relacionamentos de requisições visíveis para o usuário
+	if err := h.ccsRepo.VerificarRelacionamentoNoEscopo(middleware.EscopoDaRequisicao(r), id); err != nil {
+		if err == repository.ErrRequisicaoNaoEncontrada {
+			http.Error(w, "Relacionamento não encontrado", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Erro ao buscar relacionamento", http.StatusInternalServerError)
+		return
+	}
 
 	resultado, err := h.ccsService.SolicitarDetalhamento(numeroRequisicao, cpfCnpj, cnpjResponsavel, cnpjParticipante, dataInicioRelacionamento, nomeBancoResponsavel, id)
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/middleware"
 	"github.com/tassyosilva/consultapix/internal/services/bacen"
 )
 
@@ -19,9 +20,13 @@ func NewHandler(cfg *config.Config) *Handler {
 }
 
 func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	// O responsável e a lotação vêm do token, não dos parâmetros
+	usuario, ok := middleware.ResponsavelDaConsulta(w, r)
+	if !ok {
+		return
+	}
+
 	// Obter parâmetros da URL
-	cpfResponsavel := r.URL.Query().Get("cpfResponsavel")
-	lotacao := r.URL.Query().Get("lotacao")
 	cpfCnpj := r.URL.Query().Get("cpfCnpj")
 	dataInicio := r.URL.Query().Get("dataInicio")
 	dataFim := r.URL.Query().Get("dataFim")
@@ -31,7 +36,7 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 	
 	// O token já foi validado pelo middleware de autenticação
 
-	resultado, err := h.ccsService.ConsultarRelacionamento(cpfCnpj, dataInicio, dataFim, numProcesso, motivo, cpfResponsavel, lotacao, caso)
+	resultado, err := h.ccsService.ConsultarRelacionamento(cpfCnpj, dataInicio, dataFim, numProcesso, motivo, bacen.Responsavel{CPF: usuario.CPF, Lotacao: usuario.Lotacao, Unidade: usuario.Unidade}, caso)
 	if err != nil {
 		http.Error(w, "Erro ao consultar relacionamentos CCS", http.StatusInternalServerError)
 		return
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/middleware"
 	"github.com/tassyosilva/consultapix/internal/services/bacen"
 )
 
@@ -19,12 +20,8 @@ func NewHandler(cfg *config.Config) *Handler {
 }
 
 func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
-	// Obter parâmetros da URL
-	cpfResponsavel := r.URL.Query().Get("cpfResponsavel")
-	
-	// O token já foi validado pelo middleware de autenticação
-
-	requisicoes, err := h.ccsService.BuscarRequisicoesCCS(cpfResponsavel)
+	// O escopo vem do token, não de um CPF informado na URL
+	requisicoes, err := h.ccsService.BuscarRequisicoesCCS(middleware.EscopoDaRequisicao(r))
 	if err != nil {
 		http.Error(w, "Erro ao buscar requisições CCS", http.StatusInternalServerError)
 		return
@@ -0,0 +1,26 @@
+package historico
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/services/comparacao"
+)
+
+// EscreverComparacao responde a comparação em JSON ou, com formato=csv, como
+// arquivo CSV para relatórios
+func EscreverComparacao(w http.ResponseWriter, r *http.Request, c *models.ComparacaoRequisicoes) {
+	if r.URL.Query().Get("formato") == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="comparacao-%s-%d-%d.csv"`, c.Origem, c.IDAnterior, c.IDAtual))
+		w.WriteHeader(http.StatusOK)
+		comparacao.EscreverCSV(w, c)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(c)
+}
@@ -0,0 +1,77 @@
+package historico
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/bacen"
+)
+
+// ParseFiltro lê os filtros de histórico dos parâmetros da URL. O filtro por
+// responsável só é respeitado quando o escopo alcança outros usuários
+// (administrador, auditor ou supervisor); os demais veem apenas as próprias.
+func ParseFiltro(r *http.Request, compartilhado bool) (repository.FiltroHistorico, error) {
+	q := r.URL.Query()
+
+	filtro := repository.FiltroHistorico{
+		Caso:      q.Get("caso"),
+		TipoBusca: q.Get("tipoBusca"),
+		Alvo:      q.Get("alvo"),
+		Resultado: q.Get("resultado"),
+		Status:    q.Get("status"),
+		Crescente: q.Get("ordem") == "asc",
+		Cursor:    q.Get("cursor"),
+	}
+	if compartilhado {
+		filtro.CPFResponsavel = q.Get("cpfResponsavel")
+	}
+
+	if v := q.Get("limite"); v != "" {
+		limite, err := strconv.Atoi(v)
+		if err != nil || limite < 1 {
+			return filtro, fmt.Errorf("limite inválido: %s", v)
+		}
+		filtro.Limite = limite
+	}
+
+	dataInicio, err := bacen.ParseDataBacen(q.Get("dataInicio"))
+	if err != nil {
+		return filtro, err
+	}
+	filtro.DataInicio = dataInicio
+
+	dataFim, err := bacen.ParseDataBacen(q.Get("dataFim"))
+	if err != nil {
+		return filtro, err
+	}
+	// Uma data sem hora inclui o dia inteiro
+	if dataFim != nil && len(q.Get("dataFim")) <= len("02/01/2006") {
+		fim := dataFim.Add(24 * time.Hour)
+		dataFim = &fim
+	}
+	filtro.DataFim = dataFim
+
+	return filtro, nil
+}
+
+// ParseID lê o identificador numérico da rota
+func ParseID(valor string) (int, error) {
+	id, err := strconv.Atoi(valor)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("id inválido: %s", valor)
+	}
+	return id, nil
+}
+
+// ParseIDComparado lê o parâmetro com, a requisição a comparar. Retorna zero
+// quando ausente, caso em que se compara com a requisição anterior do mesmo alvo.
+func ParseIDComparado(r *http.Request) (int, error) {
+	valor := r.URL.Query().Get("com")
+	if valor == "" {
+		return 0, nil
+	}
+	return ParseID(valor)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/middleware"
 	"github.com/tassyosilva/consultapix/internal/services/bacen"
 )
 
@@ -19,16 +20,20 @@ func NewHandler(cfg *config.Config) *Handler {
 }
 
 func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	// O responsável e a lotação vêm do token, não dos parâmetros
+	usuario, ok := middleware.ResponsavelDaConsulta(w, r)
+	if !ok {
+		return
+	}
+
 	// Obter parâmetros da URL
-	cpfResponsavel := r.URL.Query().Get("cpfResponsavel")
-	lotacao := r.URL.Query().Get("lotacao")
 	chave := r.URL.Query().Get("chave")
 	motivo := r.URL.Query().Get("motivo")
 	caso := r.URL.Query().Get("caso")
 	
 	// O token já foi validado pelo middleware de autenticação
 
-	chaves, err := h.pixService.ConsultarChavePix(chave, motivo, cpfResponsavel, lotacao, caso)
+	chaves, err := h.pixService.ConsultarChavePix(chave, motivo, bacen.Responsavel{CPF: usuario.CPF, Lotacao: usuario.Lotacao, Unidade: usuario.Unidade}, caso)
 	if err != nil {
 		http.Error(w, "Erro ao consultar chave PIX", http.StatusInternalServerError)
 		return
@@ -0,0 +1,135 @@
+package historicopix
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/handlers/bacen/historico"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/comparacao"
+)
+
+type Handler struct {
+	pixRepo *repository.PixRepository
+}
+
+type ListaResponse struct {
+	Itens         []models.ResumoRequisicaoPix `json:"itens"`
+	ProximoCursor string                       `json:"proximoCursor,omitempty"`
+}
+
+func NewHandler() *Handler {
+	return &Handler{
+		pixRepo: repository.NewPixRepository(),
+	}
+}
+
+// Handle lista o histórico de requisições PIX com filtros e paginação por cursor
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	escopo := middleware.EscopoDaRequisicao(r)
+
+	filtro, err := historico.ParseFiltro(r, escopo.Compartilhado())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	itens, proximoCursor, err := h.pixRepo.ListarRequisicoesPix(escopo, filtro)
+	if err == repository.ErrCursorInvalido {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao buscar histórico PIX", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ListaResponse{Itens: itens, ProximoCursor: proximoCursor})
+}
+
+// HandleDetalhe retorna uma requisição PIX completa, com chaves e eventos
+func (h *Handler) HandleDetalhe(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	requisicao, err := h.pixRepo.BuscarRequisicaoPixPorID(middleware.EscopoDaRequisicao(r), id)
+	if err == repository.ErrRequisicaoNaoEncontrada {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao buscar requisição PIX", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(requisicao)
+}
+
+// HandleComparacao mostra o que mudou entre a requisição e outra consulta do
+// mesmo alvo (parâmetro com) ou, por padrão, a consulta anterior
+func (h *Handler) HandleComparacao(w http.ResponseWriter, r *http.Request) {
+	escopo := middleware.EscopoDaRequisicao(r)
+
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	idAnterior, err := historico.ParseIDComparado(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	atual, err := h.pixRepo.BuscarRequisicaoPixPorID(escopo, id)
+	if err == repository.ErrRequisicaoNaoEncontrada {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao buscar requisição PIX", http.StatusInternalServerError)
+		return
+	}
+
+	if idAnterior == 0 {
+		idAnterior, err = h.pixRepo.BuscarIDRequisicaoPixAnterior(escopo, atual)
+		if err == repository.ErrRequisicaoNaoEncontrada {
+			http.Error(w, "Não há consulta anterior do mesmo alvo", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao buscar requisição PIX", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	anterior, err := h.pixRepo.BuscarRequisicaoPixPorID(escopo, idAnterior)
+	if err == repository.ErrRequisicaoNaoEncontrada {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao buscar requisição PIX", http.StatusInternalServerError)
+		return
+	}
+
+	if anterior.TipoBusca != atual.TipoBusca || anterior.ChaveBusca != atual.ChaveBusca {
+		http.Error(w, "As requisições não são do mesmo alvo", http.StatusBadRequest)
+		return
+	}
+	if anterior.Data.After(atual.Data) {
+		anterior, atual = atual, anterior
+	}
+
+	historico.EscreverComparacao(w, r, comparacao.CompararPix(anterior, atual))
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/tassyosilva/consultapix/internal/middleware"
 	"github.com/tassyosilva/consultapix/internal/repository"
 )
 
@@ -18,12 +19,8 @@ func NewHandler() *Handler {
 }
 
 func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
-	// Obter parâmetros da URL
-	cpfResponsavel := r.URL.Query().Get("cpfCnpj")
-	
-	// O token já foi validado pelo middleware de autenticação
-
-	requisicoes, err := h.pixRepo.BuscarRequisicoesPix(cpfResponsavel)
+	// O escopo vem do token, não de um CPF informado na URL
+	requisicoes, err := h.pixRepo.BuscarRequisicoesPix(middleware.EscopoDaRequisicao(r))
 	if err != nil {
 		http.Error(w, "Erro ao buscar requisições PIX", http.StatusInternalServerError)
 		return
@@ -0,0 +1,62 @@
+package busca
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+// tamanhoMinimoTermo evita buscas que casariam com quase todos os registros
+const tamanhoMinimoTermo = 3
+
+type Handler struct {
+	buscaRepo *repository.BuscaRepository
+}
+
+func NewHandler() *Handler {
+	return &Handler{
+		buscaRepo: repository.NewBuscaRepository(),
+	}
+}
+
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	// Obter parâmetros da URL
+	termo := strings.TrimSpace(r.URL.Query().Get("q"))
+	if utf8.RuneCountInString(termo) < tamanhoMinimoTermo {
+		http.Error(w, "Informe ao menos 3 caracteres para a busca", http.StatusBadRequest)
+		return
+	}
+
+	var tipos []string
+	if v := r.URL.Query().Get("tipos"); v != "" {
+		validos := make(map[string]bool)
+		for _, t := range repository.TiposBusca() {
+			validos[t] = true
+		}
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			if !validos[t] {
+				http.Error(w, "Tipo de busca inválido: "+t, http.StatusBadRequest)
+				return
+			}
+			tipos = append(tipos, t)
+		}
+	}
+
+	limite, _ := strconv.Atoi(r.URL.Query().Get("limite"))
+
+	resultados, err := h.buscaRepo.Buscar(middleware.EscopoDaRequisicao(r), termo, tipos, limite)
+	if err != nil {
+		http.Error(w, "Erro ao realizar busca", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resultados)
+}
@@ -0,0 +1,72 @@
+package conta
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tassyosilva/consultapix/internal/handlers/bacen/historico"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+type Handler struct {
+	contaRepo *repository.ContaRepository
+}
+
+func NewHandler() *Handler {
+	return &Handler{
+		contaRepo: repository.NewContaRepository(),
+	}
+}
+
+// Handle localiza a conta pela instituição (ISPB ou CNPJ), agência e número e
+// responde quais chaves e pessoas apontam para ela
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	participante := query.Get("participante")
+	numero := query.Get("conta")
+	if participante == "" || numero == "" {
+		http.Error(w, "Informe participante (ISPB ou CNPJ) e conta", http.StatusBadRequest)
+		return
+	}
+
+	id, err := h.contaRepo.BuscarContaPorNumero(participante, query.Get("agencia"), numero)
+	if err == repository.ErrContaNaoEncontrada {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao buscar conta", http.StatusInternalServerError)
+		return
+	}
+
+	h.responderDetalhe(w, r, id)
+}
+
+// HandleDetalhe responde quais chaves e pessoas apontam para a conta do ID informado
+func (h *Handler) HandleDetalhe(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.responderDetalhe(w, r, id)
+}
+
+func (h *Handler) responderDetalhe(w http.ResponseWriter, r *http.Request, id int) {
+	detalhe, err := h.contaRepo.BuscarDetalheConta(middleware.EscopoDaRequisicao(r), id)
+	if err == repository.ErrContaNaoEncontrada {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao buscar conta", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(detalhe)
+}
@@ -0,0 +1,49 @@
+package cota
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+type Handler struct {
+	cotas *repository.CotaRepository
+}
+
+func NewHandler() *Handler {
+	return &Handler{
+		cotas: repository.NewCotaRepository(),
+	}
+}
+
+// Handle retorna o consumo de cotas do usuário autenticado e da sua lotação.
+// Administradores podem consultar outro usuário ou lotação por cpf e lotacao.
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.UsuarioAutenticado(r)
+	if claims == nil {
+		http.Error(w, "Token inválido", http.StatusUnauthorized)
+		return
+	}
+
+	cpf, lotacao := claims.CPF, claims.Lotacao
+	if claims.Admin {
+		if v := r.URL.Query().Get("cpf"); v != "" {
+			cpf = v
+		}
+		if v := r.URL.Query().Get("lotacao"); v != "" {
+			lotacao = v
+		}
+	}
+
+	usos, err := h.cotas.Uso(cpf, lotacao)
+	if err != nil {
+		http.Error(w, "Erro ao consultar o uso de cotas", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(usos)
+}
@@ -0,0 +1,158 @@
+package doisfatores
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/qrcode"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/auth"
+)
+
+type Handler struct {
+	doisFatores *auth.DoisFatoresService
+	usuarios    *repository.UserRepository
+}
+
+// CodigoRequest carrega o código TOTP ou de recuperação que confirma a operação
+type CodigoRequest struct {
+	Codigo string `json:"codigo"`
+}
+
+func NewHandler(cfg *config.Config) *Handler {
+	return &Handler{
+		doisFatores: auth.NewDoisFatoresService(cfg),
+		usuarios:    repository.NewUserRepository(),
+	}
+}
+
+// usuario relê o usuário autenticado, para ter o estado atual do segundo fator
+func (h *Handler) usuario(w http.ResponseWriter, r *http.Request) *models.Usuario {
+	user, err := h.usuarios.FindByID(middleware.UsuarioAutenticado(r).ID)
+	if err != nil {
+		http.Error(w, "Usuário não encontrado", http.StatusUnauthorized)
+		return nil
+	}
+	return user
+}
+
+// Handle retorna se o segundo fator está ativo, se é obrigatório e quantos
+// códigos de recuperação restam
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	user := h.usuario(w, r)
+	if user == nil {
+		return
+	}
+
+	status, err := h.doisFatores.Status(user)
+	if err != nil {
+		http.Error(w, "Erro ao consultar a autenticação em dois fatores", http.StatusInternalServerError)
+		return
+	}
+
+	responder(w, status)
+}
+
+// HandleCadastro gera um novo segredo e devolve a URI otpauth:// e o QR code
+// dela em SVG (data URI). O cadastro só vale depois de confirmado em HandleAtivar.
+func (h *Handler) HandleCadastro(w http.ResponseWriter, r *http.Request) {
+	user := h.usuario(w, r)
+	if user == nil {
+		return
+	}
+
+	segredo, uri, err := h.doisFatores.Cadastrar(user)
+	if err != nil {
+		responderErro(w, err, "Erro ao iniciar o cadastro")
+		return
+	}
+
+	qr, err := qrcode.Codificar(uri)
+	if err != nil {
+		http.Error(w, "Erro ao gerar o QR code", http.StatusInternalServerError)
+		return
+	}
+
+	responder(w, map[string]string{
+		"segredo": segredo,
+		"uri":     uri,
+		"qrCode":  "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(qr.SVG())),
+	})
+}
+
+// HandleAtivar confirma o cadastro com um código do aplicativo e devolve os
+// códigos de recuperação, que não são exibidos novamente. As demais sessões
+// são encerradas; o cliente precisa entrar de novo.
+func (h *Handler) HandleAtivar(w http.ResponseWriter, r *http.Request) {
+	h.comCodigo(w, r, func(user *models.Usuario, codigo string) {
+		codigos, err := h.doisFatores.Ativar(user, codigo)
+		if err != nil {
+			responderErro(w, err, "Erro ao ativar a autenticação em dois fatores")
+			return
+		}
+		responder(w, map[string][]string{"codigosRecuperacao": codigos})
+	})
+}
+
+// HandleDesativar remove o segundo fator, se a política permitir
+func (h *Handler) HandleDesativar(w http.ResponseWriter, r *http.Request) {
+	h.comCodigo(w, r, func(user *models.Usuario, codigo string) {
+		if err := h.doisFatores.Desativar(user, codigo); err != nil {
+			responderErro(w, err, "Erro ao desativar a autenticação em dois fatores")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// HandleCodigosRecuperacao substitui os códigos de recuperação
+func (h *Handler) HandleCodigosRecuperacao(w http.ResponseWriter, r *http.Request) {
+	h.comCodigo(w, r, func(user *models.Usuario, codigo string) {
+		codigos, err := h.doisFatores.GerarCodigosRecuperacao(user, codigo)
+		if err != nil {
+			responderErro(w, err, "Erro ao gerar códigos de recuperação")
+			return
+		}
+		responder(w, map[string][]string{"codigosRecuperacao": codigos})
+	})
+}
+
+func (h *Handler) comCodigo(w http.ResponseWriter, r *http.Request, operacao func(*models.Usuario, string)) {
+	var req CodigoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Codigo == "" {
+		http.Error(w, "Informe o código de verificação", http.StatusBadRequest)
+		return
+	}
+
+	user := h.usuario(w, r)
+	if user == nil {
+		return
+	}
+	operacao(user, req.Codigo)
+}
+
+func responder(w http.ResponseWriter, corpo interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(corpo)
+}
+
+// responderErro traduz os erros esperados do fluxo em status HTTP
+func responderErro(w http.ResponseWriter, err error, mensagem string) {
+	switch err {
+	case auth.ErrCodigoInvalido:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case auth.ErrDoisFatoresObrigatorio:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case repository.ErrDoisFatoresJaAtivo, repository.ErrDoisFatoresNaoCadastrado:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case auth.ErrChaveDoisFatoresAusente:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, mensagem, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,80 @@
+package eventos
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/services/eventos"
+)
+
+// intervaloHeartbeat mantém a conexão viva através de proxies que encerram
+// conexões ociosas (o nginx usa 60s por padrão)
+const intervaloHeartbeat = 25 * time.Second
+
+type Handler struct {
+	barramento *eventos.Barramento
+}
+
+func NewHandler() *Handler {
+	return &Handler{
+		barramento: eventos.Padrao(),
+	}
+}
+
+// Handle abre um stream Server-Sent Events com as mudanças de status dos
+// relacionamentos CCS, as execuções de monitoramentos e as novas notificações
+// do usuário. O stream termina quando o token expira; o EventSource do
+// navegador reconecta sozinho e a autenticação é refeita com o token atual.
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.UsuarioAutenticado(r)
+	if claims == nil {
+		http.Error(w, "Token inválido", http.StatusUnauthorized)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming não suportado", http.StatusInternalServerError)
+		return
+	}
+
+	fila, cancelar := h.barramento.Assinar(claims.CPF)
+	defer cancelar()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+	flusher.Flush()
+
+	var expiracao <-chan time.Time
+	if claims.ExpiresAt != nil {
+		timer := time.NewTimer(time.Until(claims.ExpiresAt.Time))
+		defer timer.Stop()
+		expiracao = timer.C
+	}
+	heartbeat := time.NewTicker(intervaloHeartbeat)
+	defer heartbeat.Stop()
+
+	for id := 1; ; {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-expiracao:
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case evento := <-fila:
+			dados, err := json.Marshal(evento.Dados)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, evento.Tipo, dados)
+			id++
+		}
+		flusher.Flush()
+	}
+}
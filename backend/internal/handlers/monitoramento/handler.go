@@ -0,0 +1,142 @@
+package monitoramento
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/handlers/bacen/historico"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/bacen"
+)
+
+// frequenciaPadraoHoras é usada quando a frequência não é informada
+const frequenciaPadraoHoras = 24
+
+type Handler struct {
+	monitoramentos *repository.MonitoramentoRepository
+}
+
+type CriarRequest struct {
+	TipoBusca           string    `json:"tipoBusca"`
+	Alvo                string    `json:"alvo"`
+	Caso                string    `json:"caso"`
+	Motivo              string    `json:"motivo"`
+	NumeroAutorizacao   string    `json:"numeroAutorizacao"`
+	AutorizacaoExpiraEm time.Time `json:"autorizacaoExpiraEm"`
+	FrequenciaHoras     int       `json:"frequenciaHoras"`
+	Responsaveis        []string  `json:"responsaveis"`
+}
+
+func NewHandler() *Handler {
+	return &Handler{
+		monitoramentos: repository.NewMonitoramentoRepository(),
+	}
+}
+
+// Handle lista os monitoramentos criados pelo usuário ou em que ele é responsável
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	monitoramentos, err := h.monitoramentos.Listar(middleware.EscopoDaRequisicao(r))
+	if err != nil {
+		http.Error(w, "Erro ao listar monitoramentos", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(monitoramentos)
+}
+
+// HandleCriar inclui uma chave PIX ou CPF/CNPJ no monitoramento
+func (h *Handler) HandleCriar(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.UsuarioAutenticado(r)
+	if claims == nil {
+		http.Error(w, "Token inválido", http.StatusUnauthorized)
+		return
+	}
+
+	var req CriarRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Falha ao processar requisição", http.StatusBadRequest)
+		return
+	}
+
+	m := &models.Monitoramento{
+		TipoBusca:           req.TipoBusca,
+		Alvo:                strings.TrimSpace(req.Alvo),
+		Caso:                strings.TrimSpace(req.Caso),
+		Motivo:              strings.TrimSpace(req.Motivo),
+		NumeroAutorizacao:   strings.TrimSpace(req.NumeroAutorizacao),
+		AutorizacaoExpiraEm: req.AutorizacaoExpiraEm,
+		FrequenciaHoras:     req.FrequenciaHoras,
+		CPFResponsavel:      claims.CPF,
+		Lotacao:             claims.Lotacao,
+	}
+	if m.FrequenciaHoras == 0 {
+		m.FrequenciaHoras = frequenciaPadraoHoras
+	}
+	for _, cpf := range req.Responsaveis {
+		if cpf = strings.TrimSpace(cpf); cpf != "" {
+			m.Responsaveis = append(m.Responsaveis, cpf)
+		}
+	}
+
+	switch {
+	case m.TipoBusca != bacen.TipoBuscaChave && m.TipoBusca != bacen.TipoBuscaCPFCNPJ:
+		http.Error(w, "tipoBusca deve ser chave ou cpf/cnpj", http.StatusBadRequest)
+		return
+	case m.Alvo == "" || m.Caso == "" || m.Motivo == "" || m.NumeroAutorizacao == "":
+		http.Error(w, "Alvo, caso, motivo e número da autorização são obrigatórios", http.StatusBadRequest)
+		return
+	case !m.AutorizacaoExpiraEm.After(time.Now()):
+		http.Error(w, "A autorização deve expirar no futuro", http.StatusBadRequest)
+		return
+	case m.FrequenciaHoras < 1:
+		http.Error(w, "A frequência deve ser de ao menos 1 hora", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.monitoramentos.Criar(m); err != nil {
+		http.Error(w, "Erro ao criar monitoramento", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(m)
+}
+
+// HandleEncerrar interrompe um monitoramento antes da expiração da autorização
+func (h *Handler) HandleEncerrar(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	escopo := middleware.EscopoDaRequisicao(r)
+	if _, err := h.monitoramentos.BuscarPorID(escopo, id); err != nil {
+		if err == repository.ErrMonitoramentoNaoEncontrado {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Erro ao buscar monitoramento", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.monitoramentos.Encerrar(id, "Encerrado por "+escopo.CPF)
+	if err == repository.ErrMonitoramentoNaoEncontrado {
+		http.Error(w, "Monitoramento já encerrado", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao encerrar monitoramento", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
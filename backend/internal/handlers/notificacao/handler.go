@@ -0,0 +1,99 @@
+package notificacao
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/handlers/bacen/historico"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+type Handler struct {
+	notificacoes *repository.NotificacaoRepository
+}
+
+func NewHandler() *Handler {
+	return &Handler{
+		notificacoes: repository.NewNotificacaoRepository(),
+	}
+}
+
+// Handle lista as notificações do usuário; naoLidas=true traz só as não lidas
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	escopo := middleware.EscopoDaRequisicao(r)
+
+	notificacoes, err := h.notificacoes.Listar(escopo.CPF, r.URL.Query().Get("naoLidas") == "true")
+	if err != nil {
+		http.Error(w, "Erro ao listar notificações", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(notificacoes)
+}
+
+// HandleMarcarLida marca uma notificação do usuário como lida
+func (h *Handler) HandleMarcarLida(w http.ResponseWriter, r *http.Request) {
+	id, err := historico.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.notificacoes.MarcarLida(middleware.EscopoDaRequisicao(r).CPF, int64(id))
+	if err == repository.ErrNotificacaoNaoEncontrada {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao marcar notificação", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlePreferencias lista, para cada evento e canal, se o usuário é notificado
+func (h *Handler) HandlePreferencias(w http.ResponseWriter, r *http.Request) {
+	preferencias, err := h.notificacoes.Preferencias(middleware.EscopoDaRequisicao(r).CPF)
+	if err != nil {
+		http.Error(w, "Erro ao listar preferências de notificação", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(preferencias)
+}
+
+// HandleSalvarPreferencias grava as preferências enviadas; as omitidas não mudam
+func (h *Handler) HandleSalvarPreferencias(w http.ResponseWriter, r *http.Request) {
+	var preferencias []models.PreferenciaNotificacao
+	if err := json.NewDecoder(r.Body).Decode(&preferencias); err != nil {
+		http.Error(w, "Dados inválidos", http.StatusBadRequest)
+		return
+	}
+
+	for _, p := range preferencias {
+		if !repository.EventoNotificacaoValido(p.Evento) {
+			http.Error(w, "Evento de notificação inválido: "+p.Evento, http.StatusBadRequest)
+			return
+		}
+		if !repository.CanalNotificacaoValido(p.Canal) {
+			http.Error(w, "Canal de notificação inválido: "+p.Canal, http.StatusBadRequest)
+			return
+		}
+	}
+
+	cpf := middleware.EscopoDaRequisicao(r).CPF
+	if err := h.notificacoes.SalvarPreferencias(cpf, preferencias); err != nil {
+		http.Error(w, "Erro ao salvar preferências de notificação", http.StatusInternalServerError)
+		return
+	}
+
+	h.HandlePreferencias(w, r)
+}
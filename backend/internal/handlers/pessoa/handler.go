@@ -0,0 +1,43 @@
+package pessoa
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+type Handler struct {
+	pessoaRepo *repository.PessoaRepository
+}
+
+func NewHandler() *Handler {
+	return &Handler{
+		pessoaRepo: repository.NewPessoaRepository(),
+	}
+}
+
+// Handle retorna o perfil consolidado de uma pessoa pelo CPF/CNPJ, com ou sem máscara
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	documento := repository.NormalizarDocumento(mux.Vars(r)["documento"])
+	if documento == "" {
+		http.Error(w, "CPF/CNPJ inválido", http.StatusBadRequest)
+		return
+	}
+
+	perfil, err := h.pessoaRepo.BuscarPerfil(middleware.EscopoDaRequisicao(r), documento)
+	if err == repository.ErrPessoaNaoEncontrada {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao buscar perfil da pessoa", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(perfil)
+}
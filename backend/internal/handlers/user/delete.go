@@ -4,15 +4,22 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/middleware"
 	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/auth"
 )
 
+// DeleteHandler atende a rota antiga de exclusão, que agora desativa a
+// conta: as requisições e a auditoria continuam ligadas ao usuário
 type DeleteHandler struct {
-	userRepo *repository.UserRepository
+	desativacaoService *auth.DesativacaoService
 }
 
 type DeleteRequest struct {
 	ID int `json:"id"`
+	// Motivo da desativação, obrigatório
+	Motivo string `json:"motivo"`
 }
 
 type DeleteResponse struct {
@@ -20,9 +27,9 @@ type DeleteResponse struct {
 	Message string `json:"message"`
 }
 
-func NewDeleteHandler() *DeleteHandler {
+func NewDeleteHandler(cfg *config.Config) *DeleteHandler {
 	return &DeleteHandler{
-		userRepo: repository.NewUserRepository(),
+		desativacaoService: auth.NewDesativacaoService(cfg),
 	}
 }
 
@@ -33,21 +40,23 @@ func (h *DeleteHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.userRepo.Delete(req.ID)
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(DeleteResponse{
-			Status:  500,
-			Message: "Erro ao deletar usuário",
-		})
-		return
+	resposta := DeleteResponse{Status: 201, Message: "Usuário desativado!"}
+	admin := middleware.UsuarioAutenticado(r)
+	if admin == nil || !admin.Admin {
+		resposta = DeleteResponse{Status: 403, Message: "Acesso restrito a administradores"}
+	} else {
+		switch err := h.desativacaoService.Desativar(admin.CPF, admin.Lotacao, admin.ID, req.ID, req.Motivo); err {
+		case nil:
+		case auth.ErrMotivoDesativacao, auth.ErrAutoDesativacao:
+			resposta = DeleteResponse{Status: 400, Message: err.Error()}
+		case repository.ErrUsuarioNaoEncontrado:
+			resposta = DeleteResponse{Status: 404, Message: "Usuário ativo não encontrado"}
+		default:
+			resposta = DeleteResponse{Status: 500, Message: "Erro ao desativar usuário"}
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(DeleteResponse{
-		Status:  201,
-		Message: "Usuário Deletado!",
-	})
-}
\ No newline at end of file
+	json.NewEncoder(w).Encode(resposta)
+}
@@ -4,12 +4,17 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/tassyosilva/consultapix/internal/config"
 	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/middleware"
 	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/auth"
 )
 
+// EditHandler atende a edição de usuários, restrita a administradores na rota
 type EditHandler struct {
-	userRepo *repository.UserRepository
+	userRepo     *repository.UserRepository
+	senhaService *auth.SenhaService
 }
 
 type EditRequest struct {
@@ -28,9 +33,10 @@ type EditResponse struct {
 	Message string `json:"message"`
 }
 
-func NewEditHandler() *EditHandler {
+func NewEditHandler(cfg *config.Config) *EditHandler {
 	return &EditHandler{
-		userRepo: repository.NewUserRepository(),
+		userRepo:     repository.NewUserRepository(),
+		senhaService: auth.NewSenhaService(cfg),
 	}
 }
 
@@ -52,6 +58,19 @@ func (h *EditHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		Admin:     req.Admin,
 	}
 
+	// A nova senha, se informada, segue a política
+	if user.Password != "" {
+		if err := h.senhaService.Validar(user, user.Password); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(EditResponse{
+				Status:  400,
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
 	err := h.userRepo.Update(user)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
@@ -63,6 +82,11 @@ func (h *EditHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Password != "" {
+		autor := middleware.UsuarioAutenticado(r)
+		h.senhaService.RegistrarAlteracao(autor.CPF, autor.Lotacao, user)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(EditResponse{
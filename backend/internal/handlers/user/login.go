@@ -2,31 +2,44 @@ package user
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
 	"github.com/tassyosilva/consultapix/internal/services/auth"
 )
 
 type LoginHandler struct {
 	authService *auth.AuthService
+	config      *config.Config
 }
 
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// Cookie pede a sessão em cookies HttpOnly em vez dos tokens no corpo
+	Cookie bool `json:"cookie"`
 }
 
 type LoginResponse struct {
 	Status  int         `json:"status"`
 	Message string      `json:"message"`
-	Token   string      `json:"token,omitempty"`
+	Token        string      `json:"token,omitempty"`
+	RefreshToken string      `json:"refreshToken,omitempty"`
+	// Desafio é devolvido no lugar dos tokens quando o login ainda depende do
+	// código do segundo fator (POST /api/user/login/2fa)
+	Desafio string      `json:"desafio,omitempty"`
 	Payload interface{} `json:"payload,omitempty"`
 }
 
 func NewLoginHandler(cfg *config.Config) *LoginHandler {
 	return &LoginHandler{
 		authService: auth.NewAuthService(cfg),
+		config:      cfg,
 	}
 }
 
@@ -37,19 +50,101 @@ func (h *LoginHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, user, err := h.authService.Login(req.Email, req.Password)
+	tokens, user, err := h.authService.Login(req.Email, req.Password, middleware.ClienteSessao(r, h.config.ProxiesConfiaveis))
 	if err != nil {
+		status := 409
+		if bloqueio, ok := err.(*auth.ErroBloqueio); ok {
+			status = 429
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(bloqueio.Espera.Seconds()))))
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(LoginResponse{
-			Status:  409,
+			Status:  status,
 			Message: err.Error(),
 		})
 		return
 	}
 
-	// Criar payload similar ao da implementação original
-	payload := map[string]interface{}{
+	if tokens.Desafio != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(LoginResponse{
+			Status:  202,
+			Message: "Informe o código de verificação",
+			Desafio: tokens.Desafio,
+		})
+		return
+	}
+
+	escreverSessao(w, h.config, req.Cookie, "Bem-vindo!", tokens, user)
+}
+
+// LoginDoisFatoresRequest conclui o login com o desafio devolvido pela senha
+type LoginDoisFatoresRequest struct {
+	Desafio string `json:"desafio"`
+	Codigo  string `json:"codigo"`
+	Cookie  bool   `json:"cookie"`
+}
+
+// HandleDoisFatores troca o desafio e o código TOTP ou de recuperação pelos
+// tokens da sessão
+func (h *LoginHandler) HandleDoisFatores(w http.ResponseWriter, r *http.Request) {
+	var req LoginDoisFatoresRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Falha ao processar requisição", http.StatusBadRequest)
+		return
+	}
+
+	tokens, user, err := h.authService.ConfirmarDoisFatores(req.Desafio, req.Codigo, middleware.ClienteSessao(r, h.config.ProxiesConfiaveis))
+	if err != nil {
+		status, mensagem := 409, "Código de verificação inválido"
+		if err == repository.ErrDesafioInvalido {
+			status, mensagem = 401, "Login expirado; informe a senha novamente"
+		}
+		if bloqueio, ok := err.(*auth.ErroBloqueio); ok {
+			status, mensagem = 429, bloqueio.Error()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(bloqueio.Espera.Seconds()))))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(LoginResponse{
+			Status:  status,
+			Message: mensagem,
+		})
+		return
+	}
+
+	escreverSessao(w, h.config, req.Cookie, "Bem-vindo!", tokens, user)
+}
+
+// escreverSessao entrega os tokens em cookies, para o navegador, ou no corpo,
+// para clientes que usam o cabeçalho Authorization
+func escreverSessao(w http.ResponseWriter, cfg *config.Config, cookie bool, mensagem string, tokens *auth.Tokens, user *models.Usuario) {
+	payload := payloadUsuario(user)
+	payload["cadastroDoisFatores"] = tokens.CadastroDoisFatores
+	payload["trocaSenha"] = tokens.TrocaSenha
+	resposta := LoginResponse{
+		Status:  201,
+		Message: mensagem,
+		Payload: payload,
+	}
+	if cookie {
+		middleware.DefinirCookiesSessao(w, cfg, tokens.AccessToken, tokens.RefreshToken)
+	} else {
+		resposta.Token = tokens.AccessToken
+		resposta.RefreshToken = tokens.RefreshToken
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resposta)
+}
+
+// payloadUsuario monta os dados do usuário devolvidos junto com os tokens,
+// similar ao da implementação original
+func payloadUsuario(user *models.Usuario) map[string]interface{} {
+	return map[string]interface{}{
 		"id":        user.ID,
 		"cpf":       user.CPF,
 		"name":      user.Nome,
@@ -57,14 +152,9 @@ func (h *LoginHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		"lotacao":   user.Lotacao,
 		"matricula": user.Matricula,
 		"admin":     user.Admin,
-	}
+		"perfil":    user.Perfil,
+		"idUnidade": user.IDUnidade,
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(LoginResponse{
-		Status:  201,
-		Message: "Bem-vindo!",
-		Token:   token,
-		Payload: payload,
-	})
+		"doisFatoresAtivo": user.DoisFatoresAtivo,
+	}
 }
\ No newline at end of file
@@ -0,0 +1,61 @@
+package user
+
+import (
+	"net/http"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/services/auth"
+)
+
+type LogoutHandler struct {
+	authService *auth.AuthService
+	config      *config.Config
+}
+
+func NewLogoutHandler(cfg *config.Config) *LogoutHandler {
+	return &LogoutHandler{
+		authService: auth.NewAuthService(cfg),
+		config:      cfg,
+	}
+}
+
+// Handle encerra a sessão atual: revoga o token de acesso usado na chamada e
+// o refresh token informado no corpo ou no cookie, se houver
+func (h *LogoutHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.UsuarioAutenticado(r)
+	if claims == nil {
+		http.Error(w, "Token inválido", http.StatusUnauthorized)
+		return
+	}
+
+	refreshToken, _, ok := lerRefreshToken(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.Logout(claims, refreshToken); err != nil {
+		http.Error(w, "Erro ao encerrar a sessão", http.StatusInternalServerError)
+		return
+	}
+
+	middleware.LimparCookiesSessao(w, h.config)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleTodas encerra todas as sessões do usuário, em qualquer dispositivo
+func (h *LogoutHandler) HandleTodas(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.UsuarioAutenticado(r)
+	if claims == nil {
+		http.Error(w, "Token inválido", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.authService.EncerrarSessoes(claims.ID); err != nil {
+		http.Error(w, "Erro ao encerrar as sessões", http.StatusInternalServerError)
+		return
+	}
+
+	middleware.LimparCookiesSessao(w, h.config)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,119 @@
+package user
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/auth"
+)
+
+// cookieEstadoOIDC liga o retorno do provedor ao navegador que iniciou o
+// login. É SameSite=Lax porque o retorno é uma navegação vinda do provedor.
+const cookieEstadoOIDC = "consultapix_oidc"
+
+type OIDCHandler struct {
+	oidcService *auth.OIDCService
+	config      *config.Config
+}
+
+func NewOIDCHandler(cfg *config.Config) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: auth.NewOIDCService(cfg),
+		config:      cfg,
+	}
+}
+
+// HandleProvedores informa ao frontend se o login único está disponível
+func (h *OIDCHandler) HandleProvedores(w http.ResponseWriter, r *http.Request) {
+	resposta := map[string]interface{}{"oidc": h.oidcService.Habilitado()}
+	if h.oidcService.Habilitado() {
+		resposta["nomeOIDC"] = h.oidcService.NomeExibicao()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resposta)
+}
+
+// HandleLogin redireciona o navegador para a autorização no provedor
+func (h *OIDCHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.oidcService.Habilitado() {
+		http.NotFound(w, r)
+		return
+	}
+
+	urlAutorizacao, estado, err := h.oidcService.IniciarLogin()
+	if err != nil {
+		log.Printf("Erro ao iniciar login OIDC: %v", err)
+		http.Error(w, "Provedor de login único indisponível", http.StatusBadGateway)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name: cookieEstadoOIDC, Value: estado, Path: "/api/user/oidc",
+		MaxAge: int((10 * time.Minute) / time.Second), HttpOnly: true, Secure: h.config.CookieSeguro, SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, urlAutorizacao, http.StatusFound)
+}
+
+// HandleCallback recebe o código do provedor, inicia a sessão em cookies e
+// devolve o navegador ao frontend. O desafio do segundo fator e os erros vão
+// no fragmento da URL, que não é enviado a servidores nem gravado em logs.
+func (h *OIDCHandler) HandleCallback(w http.ResponseWriter, r *http.Request) {
+	if !h.oidcService.Habilitado() {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name: cookieEstadoOIDC, Value: "", Path: "/api/user/oidc", MaxAge: -1,
+		HttpOnly: true, Secure: h.config.CookieSeguro, SameSite: http.SameSiteLaxMode,
+	})
+
+	q := r.URL.Query()
+	if erro := q.Get("error"); erro != "" {
+		log.Printf("Login OIDC recusado pelo provedor: %s %s", erro, q.Get("error_description"))
+		h.voltarAoFrontend(w, r, "erro", "Login não autorizado pelo provedor")
+		return
+	}
+
+	estado := q.Get("state")
+	cookie, err := r.Cookie(cookieEstadoOIDC)
+	if estado == "" || err != nil || cookie.Value != estado {
+		h.voltarAoFrontend(w, r, "erro", "Login expirado; tente novamente")
+		return
+	}
+
+	tokens, _, err := h.oidcService.ConcluirLogin(estado, q.Get("code"), middleware.ClienteSessao(r, h.config.ProxiesConfiaveis))
+	if err != nil {
+		log.Printf("Erro ao concluir login OIDC: %v", err)
+		mensagem := "Não foi possível concluir o login"
+		switch err {
+		case auth.ErrAcessoNegado:
+			mensagem = "Usuário sem permissão de acesso"
+		case repository.ErrContaExistente:
+			mensagem = err.Error()
+		}
+		h.voltarAoFrontend(w, r, "erro", mensagem)
+		return
+	}
+
+	if tokens.Desafio != "" {
+		h.voltarAoFrontend(w, r, "desafio", tokens.Desafio)
+		return
+	}
+	middleware.DefinirCookiesSessao(w, h.config, tokens.AccessToken, tokens.RefreshToken)
+	h.voltarAoFrontend(w, r, "", "")
+}
+
+func (h *OIDCHandler) voltarAoFrontend(w http.ResponseWriter, r *http.Request, chave, valor string) {
+	destino := h.oidcService.URLFrontend()
+	if chave != "" {
+		destino += "#" + url.Values{chave: {valor}}.Encode()
+	}
+	http.Redirect(w, r, destino, http.StatusFound)
+}
@@ -0,0 +1,63 @@
+package user
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/auth"
+)
+
+type ReativacaoHandler struct {
+	desativacaoService *auth.DesativacaoService
+	config             *config.Config
+}
+
+// ReativacaoRequest pede a reativação da conta desativada, identificada
+// pelas mesmas credenciais do login
+type ReativacaoRequest struct {
+	Email         string `json:"email"`
+	Password      string `json:"password"`
+	Justificativa string `json:"justificativa"`
+}
+
+func NewReativacaoHandler(cfg *config.Config) *ReativacaoHandler {
+	return &ReativacaoHandler{
+		desativacaoService: auth.NewDesativacaoService(cfg),
+		config:             cfg,
+	}
+}
+
+// Handle registra o pedido de reativação para a análise de um administrador
+func (h *ReativacaoHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	var req ReativacaoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Falha ao processar requisição", http.StatusBadRequest)
+		return
+	}
+
+	err := h.desativacaoService.SolicitarReativacao(req.Email, req.Password, req.Justificativa, middleware.ClienteSessao(r, h.config.ProxiesConfiaveis))
+	if bloqueio, ok := err.(*auth.ErroBloqueio); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(bloqueio.Espera.Seconds()))))
+		http.Error(w, bloqueio.Error(), http.StatusTooManyRequests)
+		return
+	}
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusAccepted)
+	case auth.ErrJustificativaReativacao:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case auth.ErrCredenciaisInvalidas:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case repository.ErrUsuarioNaoInativo:
+		http.Error(w, "A conta não está desativada", http.StatusConflict)
+	default:
+		log.Printf("Erro ao solicitar a reativação: %v", err)
+		http.Error(w, "Erro ao solicitar a reativação", http.StatusInternalServerError)
+	}
+}
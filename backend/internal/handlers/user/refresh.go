@@ -0,0 +1,71 @@
+package user
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/services/auth"
+)
+
+type RefreshHandler struct {
+	authService *auth.AuthService
+	config      *config.Config
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+func NewRefreshHandler(cfg *config.Config) *RefreshHandler {
+	return &RefreshHandler{
+		authService: auth.NewAuthService(cfg),
+		config:      cfg,
+	}
+}
+
+// Handle troca o refresh token por um novo par de tokens. O token recebido
+// deixa de valer; reapresentá-lo encerra a sessão. A resposta segue o meio em
+// que o token veio: cookie ou corpo.
+func (h *RefreshHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	refreshToken, cookie, ok := lerRefreshToken(w, r)
+	if !ok {
+		return
+	}
+	if refreshToken == "" {
+		http.Error(w, "Refresh token não fornecido", http.StatusBadRequest)
+		return
+	}
+
+	tokens, user, err := h.authService.Renovar(refreshToken)
+	if err != nil {
+		if cookie {
+			middleware.LimparCookiesSessao(w, h.config)
+		}
+		http.Error(w, "Sessão expirada", http.StatusUnauthorized)
+		return
+	}
+
+	escreverSessao(w, h.config, cookie, "Sessão renovada", tokens, user)
+}
+
+// lerRefreshToken busca o refresh token no corpo ou, na falta dele, no cookie
+// de sessão, exigindo o CSRF nesse caso. O corpo é opcional.
+func lerRefreshToken(w http.ResponseWriter, r *http.Request) (token string, cookie bool, ok bool) {
+	var req RefreshRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	if req.RefreshToken != "" {
+		return req.RefreshToken, false, true
+	}
+
+	c, err := r.Cookie(middleware.CookieRefresh)
+	if err != nil || c.Value == "" {
+		return "", false, true
+	}
+	if !middleware.CSRFValido(r) {
+		http.Error(w, "Token CSRF inválido", http.StatusForbidden)
+		return "", true, false
+	}
+	return c.Value, true, true
+}
@@ -3,15 +3,20 @@ package user
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
+	"github.com/tassyosilva/consultapix/internal/config"
 	"github.com/tassyosilva/consultapix/internal/database/models"
-	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/auth"
 )
 
 type RegisterHandler struct {
-	userRepo *repository.UserRepository
+	cadastroService *auth.CadastroService
+	config          *config.Config
 }
 
+// RegisterRequest é o autocadastro. O perfil de administrador não é aceito
+// aqui: só um administrador pode concedê-lo depois.
 type RegisterRequest struct {
 	Nome      string `json:"nome"`
 	CPF       string `json:"cpf"`
@@ -19,7 +24,6 @@ type RegisterRequest struct {
 	Password  string `json:"password"`
 	Lotacao   string `json:"lotacao"`
 	Matricula string `json:"matricula"`
-	Admin     bool   `json:"admin"`
 }
 
 type RegisterResponse struct {
@@ -27,9 +31,10 @@ type RegisterResponse struct {
 	Message string `json:"message"`
 }
 
-func NewRegisterHandler() *RegisterHandler {
+func NewRegisterHandler(cfg *config.Config) *RegisterHandler {
 	return &RegisterHandler{
-		userRepo: repository.NewUserRepository(),
+		cadastroService: auth.NewCadastroService(cfg),
+		config:          cfg,
 	}
 }
 
@@ -41,19 +46,34 @@ func (h *RegisterHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	user := &models.Usuario{
-		Nome:      req.Nome,
-		CPF:       req.CPF,
-		Email:     req.Email,
+		Nome:      strings.TrimSpace(req.Nome),
+		CPF:       strings.TrimSpace(req.CPF),
+		Email:     strings.TrimSpace(req.Email),
 		Password:  req.Password,
-		Lotacao:   req.Lotacao,
-		Matricula: req.Matricula,
-		Admin:     req.Admin,
+		Lotacao:   strings.TrimSpace(req.Lotacao),
+		Matricula: strings.TrimSpace(req.Matricula),
 	}
 
-	_, err := h.userRepo.Create(user)
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	// A lotação define quem aprova o cadastro
+	if user.Nome == "" || user.CPF == "" || user.Email == "" || user.Password == "" || user.Lotacao == "" {
+		json.NewEncoder(w).Encode(RegisterResponse{
+			Status:  400,
+			Message: "Informe nome, CPF, e-mail, senha e lotação",
+		})
+		return
+	}
+
+	if err := h.cadastroService.Cadastrar(user); err != nil {
+		if politica, ok := err.(*auth.ErroPoliticaSenha); ok {
+			json.NewEncoder(w).Encode(RegisterResponse{
+				Status:  400,
+				Message: politica.Error(),
+			})
+			return
+		}
 		json.NewEncoder(w).Encode(RegisterResponse{
 			Status:  409,
 			Message: "Usuário já cadastrado",
@@ -61,10 +81,18 @@ func (h *RegisterHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(RegisterResponse{
 		Status:  201,
-		Message: "Obrigado pelo cadastro!",
+		Message: "Obrigado pelo cadastro! Confirme o e-mail pelo link enviado e aguarde a aprovação de um administrador da sua lotação.",
 	})
-}
\ No newline at end of file
+}
+
+// HandleVerificar confirma o e-mail pelo link enviado no cadastro e leva o
+// navegador à tela de login com o resultado no fragmento da URL
+func (h *RegisterHandler) HandleVerificar(w http.ResponseWriter, r *http.Request) {
+	resultado := "email-confirmado"
+	if err := h.cadastroService.ConfirmarEmail(r.URL.Query().Get("token")); err != nil {
+		resultado = "link-invalido"
+	}
+	http.Redirect(w, r, h.config.URLPublica+"/#cadastro="+resultado, http.StatusFound)
+}
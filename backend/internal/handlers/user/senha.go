@@ -0,0 +1,140 @@
+package user
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/auth"
+)
+
+type SenhaHandler struct {
+	senhaService *auth.SenhaService
+	config       *config.Config
+}
+
+// AlterarSenhaRequest troca a senha do usuário autenticado
+type AlterarSenhaRequest struct {
+	SenhaAtual string `json:"senhaAtual"`
+	NovaSenha  string `json:"novaSenha"`
+	// Cookie pede a nova sessão em cookies HttpOnly, como no login
+	Cookie bool `json:"cookie"`
+}
+
+// EsqueciSenhaRequest pede o link de redefinição para o e-mail
+type EsqueciSenhaRequest struct {
+	Email string `json:"email"`
+}
+
+// RedefinirSenhaRequest cadastra a nova senha com o token do link
+type RedefinirSenhaRequest struct {
+	Token     string `json:"token"`
+	NovaSenha string `json:"novaSenha"`
+}
+
+// PoliticaSenhaResponse descreve a política para orientar o formulário
+type PoliticaSenhaResponse struct {
+	TamanhoMinimo  int `json:"tamanhoMinimo"`
+	ClassesMinimas int `json:"classesMinimas"`
+	Historico      int `json:"historico"`
+	// ValidadeDias é zero quando a senha não expira
+	ValidadeDias int `json:"validadeDias"`
+}
+
+func NewSenhaHandler(cfg *config.Config) *SenhaHandler {
+	return &SenhaHandler{
+		senhaService: auth.NewSenhaService(cfg),
+		config:       cfg,
+	}
+}
+
+// HandlePolitica informa as exigências da política de senhas
+func (h *SenhaHandler) HandlePolitica(w http.ResponseWriter, r *http.Request) {
+	politica := h.config.PoliticaSenha
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PoliticaSenhaResponse{
+		TamanhoMinimo:  politica.TamanhoMinimo,
+		ClassesMinimas: politica.ClassesMinimas,
+		Historico:      politica.Historico,
+		ValidadeDias:   int(politica.Validade.Hours() / 24),
+	})
+}
+
+// Handle troca a senha do usuário autenticado. As demais sessões são
+// encerradas e a resposta traz a nova sessão, como no login.
+func (h *SenhaHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.UsuarioAutenticado(r)
+	if claims == nil {
+		http.Error(w, "Token inválido", http.StatusUnauthorized)
+		return
+	}
+
+	var req AlterarSenhaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Falha ao processar requisição", http.StatusBadRequest)
+		return
+	}
+
+	tokens, user, err := h.senhaService.Alterar(claims.ID, req.SenhaAtual, req.NovaSenha, middleware.ClienteSessao(r, h.config.ProxiesConfiaveis))
+	if err != nil {
+		escreverErroSenha(w, err)
+		return
+	}
+
+	escreverSessao(w, h.config, req.Cookie, "Senha alterada", tokens, user)
+}
+
+// HandleEsqueci envia o link de redefinição. A resposta é a mesma exista ou
+// não uma conta com o e-mail.
+func (h *SenhaHandler) HandleEsqueci(w http.ResponseWriter, r *http.Request) {
+	var req EsqueciSenhaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "Informe o e-mail", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.senhaService.SolicitarRedefinicao(req.Email, middleware.IPCliente(r, h.config.ProxiesConfiaveis)); err != nil {
+		log.Printf("Erro ao solicitar a redefinição de senha: %v", err)
+		http.Error(w, "Erro ao solicitar a redefinição de senha", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// HandleRedefinir cadastra a nova senha com o link recebido por e-mail
+func (h *SenhaHandler) HandleRedefinir(w http.ResponseWriter, r *http.Request) {
+	var req RedefinirSenhaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Falha ao processar requisição", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.senhaService.Redefinir(req.Token, req.NovaSenha, middleware.IPCliente(r, h.config.ProxiesConfiaveis)); err != nil {
+		escreverErroSenha(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func escreverErroSenha(w http.ResponseWriter, err error) {
+	if politica, ok := err.(*auth.ErroPoliticaSenha); ok {
+		http.Error(w, politica.Error(), http.StatusBadRequest)
+		return
+	}
+	switch err {
+	case auth.ErrSenhaAtualIncorreta:
+		http.Error(w, "Senha atual incorreta", http.StatusBadRequest)
+	case auth.ErrSenhaExterna:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case repository.ErrRedefinicaoInvalida:
+		http.Error(w, "Link de redefinição inválido ou expirado; solicite um novo", http.StatusGone)
+	default:
+		log.Printf("Erro ao alterar a senha: %v", err)
+		http.Error(w, "Erro ao alterar a senha", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,47 @@
+package user
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+type SessaoHandler struct {
+	userRepo *repository.UserRepository
+}
+
+func NewSessaoHandler() *SessaoHandler {
+	return &SessaoHandler{
+		userRepo: repository.NewUserRepository(),
+	}
+}
+
+// Handle devolve os dados do usuário da sessão em cookies, como no login. É
+// usado pelo frontend quando a sessão foi aberta fora dele, no login único.
+func (h *SessaoHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.UsuarioAutenticado(r)
+	if claims == nil {
+		http.Error(w, "Token inválido", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.userRepo.FindByID(claims.ID)
+	if err != nil {
+		http.Error(w, "Usuário não encontrado", http.StatusNotFound)
+		return
+	}
+
+	payload := payloadUsuario(user)
+	payload["cadastroDoisFatores"] = claims.CadastroDoisFatores
+	payload["trocaSenha"] = claims.TrocaSenha
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(LoginResponse{
+		Status:  200,
+		Message: "Sessão ativa",
+		Payload: payload,
+	})
+}
@@ -0,0 +1,72 @@
+package user
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/auth"
+)
+
+type SessoesHandler struct {
+	sessaoService *auth.SessaoService
+}
+
+func NewSessoesHandler(cfg *config.Config) *SessoesHandler {
+	return &SessoesHandler{
+		sessaoService: auth.NewSessaoService(cfg),
+	}
+}
+
+// Handle lista as sessões ativas do usuário, com IP, navegador e última atividade
+func (h *SessoesHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.UsuarioAutenticado(r)
+	if claims == nil {
+		http.Error(w, "Token inválido", http.StatusUnauthorized)
+		return
+	}
+
+	sessoes, err := h.sessaoService.Listar(claims.ID, claims.Sessao)
+	if err != nil {
+		log.Printf("Erro ao listar as sessões: %v", err)
+		http.Error(w, "Erro ao listar as sessões", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(sessoes)
+}
+
+// HandleRevogar encerra uma sessão do usuário, como a de um dispositivo perdido
+func (h *SessoesHandler) HandleRevogar(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.UsuarioAutenticado(r)
+	if claims == nil {
+		http.Error(w, "Token inválido", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	err = h.sessaoService.Revogar(claims.ID, id)
+	if err == repository.ErrSessaoNaoEncontrada {
+		http.Error(w, "Sessão não encontrada", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erro ao encerrar a sessão %d: %v", id, err)
+		http.Error(w, "Erro ao encerrar a sessão", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,186 @@
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Classes e tags BER usadas pelo LDAP (RFC 4511, codificação da X.690)
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0A
+	tagSequence    = 0x30
+	tagSet         = 0x31
+
+	classeAplicacao = 0x40
+	classeContexto  = 0x80
+	construido      = 0x20
+)
+
+// tamanhoMaximoMensagem limita a memória usada por uma resposta do servidor
+const tamanhoMaximoMensagem = 4 << 20
+
+var errBERInvalido = errors.New("ldap: resposta BER inválida")
+
+// elemento é um TLV BER; Filhos é preenchido nos construídos
+type elemento struct {
+	Tag    byte
+	Valor  []byte
+	Filhos []elemento
+}
+
+// codificar serializa tag, comprimento e conteúdo
+func codificar(tag byte, conteudo []byte) []byte {
+	saida := []byte{tag}
+	n := len(conteudo)
+	switch {
+	case n < 0x80:
+		saida = append(saida, byte(n))
+	case n <= 0xFF:
+		saida = append(saida, 0x81, byte(n))
+	case n <= 0xFFFF:
+		saida = append(saida, 0x82, byte(n>>8), byte(n))
+	default:
+		saida = append(saida, 0x84, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(saida, conteudo...)
+}
+
+func construir(tag byte, filhos ...[]byte) []byte {
+	var conteudo []byte
+	for _, f := range filhos {
+		conteudo = append(conteudo, f...)
+	}
+	return codificar(tag, conteudo)
+}
+
+func texto(tag byte, s string) []byte {
+	return codificar(tag, []byte(s))
+}
+
+func inteiro(tag byte, v int) []byte {
+	// Complemento de dois com o menor número de bytes
+	var b []byte
+	for {
+		b = append([]byte{byte(v)}, b...)
+		if (v >= -0x80 && v < 0x80) || len(b) == 4 {
+			break
+		}
+		v >>= 8
+	}
+	return codificar(tag, b)
+}
+
+func booleano(v bool) []byte {
+	if v {
+		return codificar(tagBoolean, []byte{0xFF})
+	}
+	return codificar(tagBoolean, []byte{0x00})
+}
+
+// lerMensagem lê um elemento BER completo do fluxo
+func lerMensagem(r *bufio.Reader) (elemento, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return elemento{}, err
+	}
+	n, err := lerComprimento(r)
+	if err != nil {
+		return elemento{}, err
+	}
+	if n > tamanhoMaximoMensagem {
+		return elemento{}, fmt.Errorf("ldap: mensagem de %d bytes excede o limite", n)
+	}
+	conteudo := make([]byte, n)
+	if _, err := io.ReadFull(r, conteudo); err != nil {
+		return elemento{}, err
+	}
+	return decodificarConteudo(tag, conteudo)
+}
+
+func lerComprimento(r io.ByteReader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b < 0x80 {
+		return int(b), nil
+	}
+	bytes := int(b & 0x7F)
+	if bytes == 0 || bytes > 4 {
+		return 0, errBERInvalido
+	}
+	n := 0
+	for i := 0; i < bytes; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n = n<<8 | int(b)
+	}
+	return n, nil
+}
+
+// decodificar separa os elementos BER consecutivos de buf
+func decodificar(buf []byte) ([]elemento, error) {
+	var elementos []elemento
+	for len(buf) > 0 {
+		if len(buf) < 2 {
+			return nil, errBERInvalido
+		}
+		tag := buf[0]
+		leitor := &leitorBytes{buf: buf[1:]}
+		n, err := lerComprimento(leitor)
+		if err != nil || n > len(leitor.buf) {
+			return nil, errBERInvalido
+		}
+		e, err := decodificarConteudo(tag, leitor.buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		elementos = append(elementos, e)
+		buf = leitor.buf[n:]
+	}
+	return elementos, nil
+}
+
+func decodificarConteudo(tag byte, conteudo []byte) (elemento, error) {
+	e := elemento{Tag: tag, Valor: conteudo}
+	if tag&construido != 0 {
+		filhos, err := decodificar(conteudo)
+		if err != nil {
+			return elemento{}, err
+		}
+		e.Filhos = filhos
+	}
+	return e, nil
+}
+
+// Inteiro interpreta o valor como INTEGER ou ENUMERATED
+func (e elemento) Inteiro() int {
+	if len(e.Valor) == 0 {
+		return 0
+	}
+	v := int(int8(e.Valor[0]))
+	for _, b := range e.Valor[1:] {
+		v = v<<8 | int(b)
+	}
+	return v
+}
+
+type leitorBytes struct {
+	buf []byte
+}
+
+func (l *leitorBytes) ReadByte() (byte, error) {
+	if len(l.buf) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b := l.buf[0]
+	l.buf = l.buf[1:]
+	return b, nil
+}
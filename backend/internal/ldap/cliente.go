@@ -0,0 +1,313 @@
+// Package ldap implementa o subconjunto do LDAPv3 (RFC 4511) usado na
+// autenticação: StartTLS, bind simples e busca por igualdade de atributos.
+package ldap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Operações do protocolo ([APPLICATION n])
+const (
+	opBindRequest       = classeAplicacao | construido | 0
+	opBindResponse      = classeAplicacao | construido | 1
+	opUnbindRequest     = classeAplicacao | 2
+	opSearchRequest     = classeAplicacao | construido | 3
+	opSearchResultEntry = classeAplicacao | construido | 4
+	opSearchResultDone  = classeAplicacao | construido | 5
+	opExtendedRequest   = classeAplicacao | construido | 23
+	opExtendedResponse  = classeAplicacao | construido | 24
+)
+
+// Códigos de resultado relevantes
+const (
+	ResultadoSucesso              = 0
+	ResultadoCredenciaisInvalidas = 49
+)
+
+// oidStartTLS é o nome da operação estendida StartTLS (RFC 4511, 4.14)
+const oidStartTLS = "1.3.6.1.4.1.1466.20037"
+
+// limiteEntradas é o sizeLimit das buscas; a autenticação espera uma entrada
+const limiteEntradas = 2
+
+// ErrCredenciaisInvalidas indica DN ou senha recusados pelo servidor
+var ErrCredenciaisInvalidas = errors.New("ldap: credenciais inválidas")
+
+// ErroLDAP é um resultado diferente de sucesso devolvido pelo servidor
+type ErroLDAP struct {
+	Codigo   int
+	Mensagem string
+}
+
+func (e *ErroLDAP) Error() string {
+	return fmt.Sprintf("ldap: resultado %d: %s", e.Codigo, e.Mensagem)
+}
+
+// Opcoes configura a conexão
+type Opcoes struct {
+	// URL no formato ldap://host[:389] ou ldaps://host[:636]
+	URL string
+	// StartTLS promove uma conexão ldap:// a TLS antes do bind
+	StartTLS bool
+	TLS      *tls.Config
+	Timeout  time.Duration
+}
+
+// Entrada é um objeto devolvido pela busca
+type Entrada struct {
+	DN        string
+	Atributos map[string][]string
+}
+
+// Valor retorna o primeiro valor do atributo (sem diferenciar maiúsculas no nome)
+func (e *Entrada) Valor(atributo string) string {
+	if valores := e.Valores(atributo); len(valores) > 0 {
+		return valores[0]
+	}
+	return ""
+}
+
+// Valores retorna todos os valores do atributo
+func (e *Entrada) Valores(atributo string) []string {
+	for nome, valores := range e.Atributos {
+		if strings.EqualFold(nome, atributo) {
+			return valores
+		}
+	}
+	return nil
+}
+
+// Conexao é uma conexão síncrona: uma operação por vez
+type Conexao struct {
+	conn      net.Conn
+	leitor    *bufio.Reader
+	timeout   time.Duration
+	proximoID int
+}
+
+// Conectar abre a conexão e, se pedido, negocia o StartTLS
+func Conectar(opcoes Opcoes) (*Conexao, error) {
+	u, err := url.Parse(opcoes.URL)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: URL inválida: %w", err)
+	}
+	timeout := opcoes.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	tlsConfig := opcoes.TLS
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	tlsConfig = tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ldap":
+		conn, err = dialer.Dial("tcp", hostPorta(u, "389"))
+	case "ldaps":
+		conn, err = tls.DialWithDialer(dialer, "tcp", hostPorta(u, "636"), tlsConfig)
+	default:
+		return nil, fmt.Errorf("ldap: esquema %q não suportado", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Conexao{conn: conn, leitor: bufio.NewReader(conn), timeout: timeout}
+	if opcoes.StartTLS && u.Scheme == "ldap" {
+		if err := c.startTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func hostPorta(u *url.URL, padrao string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), padrao)
+}
+
+// Fechar envia o unbind e encerra a conexão
+func (c *Conexao) Fechar() error {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	c.enviar(codificar(opUnbindRequest, nil))
+	return c.conn.Close()
+}
+
+// Bind autentica com DN e senha. Senha vazia é recusada aqui, pois o
+// servidor a aceitaria como bind não autenticado (RFC 4513, 5.1.2).
+func (c *Conexao) Bind(dn, senha string) error {
+	if senha == "" {
+		return ErrCredenciaisInvalidas
+	}
+	id, err := c.enviar(construir(opBindRequest,
+		inteiro(tagInteger, 3),
+		texto(tagOctetString, dn),
+		texto(classeContexto|0, senha),
+	))
+	if err != nil {
+		return err
+	}
+
+	resposta, err := c.receber(id)
+	if err != nil {
+		return err
+	}
+	if resposta.Tag != opBindResponse {
+		return errBERInvalido
+	}
+	err = resultado(resposta)
+	var erroLDAP *ErroLDAP
+	if errors.As(err, &erroLDAP) && erroLDAP.Codigo == ResultadoCredenciaisInvalidas {
+		return ErrCredenciaisInvalidas
+	}
+	return err
+}
+
+// Buscar procura na subárvore de base os objetos em que todos os atributos de
+// filtro são iguais aos valores informados, trazendo os atributos pedidos
+func (c *Conexao) Buscar(base string, filtro map[string]string, atributos []string) ([]Entrada, error) {
+	var igualdades [][]byte
+	for atributo, valor := range filtro {
+		igualdades = append(igualdades, construir(classeContexto|construido|3,
+			texto(tagOctetString, atributo),
+			texto(tagOctetString, valor),
+		))
+	}
+	var listaAtributos [][]byte
+	for _, a := range atributos {
+		listaAtributos = append(listaAtributos, texto(tagOctetString, a))
+	}
+
+	id, err := c.enviar(construir(opSearchRequest,
+		texto(tagOctetString, base),
+		inteiro(tagEnumerated, 2), // wholeSubtree
+		inteiro(tagEnumerated, 0), // neverDerefAliases
+		inteiro(tagInteger, limiteEntradas),
+		inteiro(tagInteger, int(c.timeout/time.Second)),
+		booleano(false),
+		construir(classeContexto|construido|0, igualdades...), // and
+		construir(tagSequence, listaAtributos...),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var entradas []Entrada
+	for {
+		resposta, err := c.receber(id)
+		if err != nil {
+			return nil, err
+		}
+		switch resposta.Tag {
+		case opSearchResultEntry:
+			entrada, err := lerEntrada(resposta)
+			if err != nil {
+				return nil, err
+			}
+			entradas = append(entradas, entrada)
+		case opSearchResultDone:
+			return entradas, resultado(resposta)
+		}
+		// Referências (SearchResultReference) não são seguidas
+	}
+}
+
+func (c *Conexao) startTLS(tlsConfig *tls.Config) error {
+	id, err := c.enviar(construir(opExtendedRequest, texto(classeContexto|0, oidStartTLS)))
+	if err != nil {
+		return err
+	}
+	resposta, err := c.receber(id)
+	if err != nil {
+		return err
+	}
+	if resposta.Tag != opExtendedResponse {
+		return errBERInvalido
+	}
+	if err := resultado(resposta); err != nil {
+		return err
+	}
+
+	conn := tls.Client(c.conn, tlsConfig)
+	conn.SetDeadline(time.Now().Add(c.timeout))
+	if err := conn.Handshake(); err != nil {
+		return err
+	}
+	c.conn = conn
+	c.leitor = bufio.NewReader(conn)
+	return nil
+}
+
+// enviar embrulha a operação num LDAPMessage com novo messageID
+func (c *Conexao) enviar(operacao []byte) (int, error) {
+	c.proximoID++
+	mensagem := construir(tagSequence, inteiro(tagInteger, c.proximoID), operacao)
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	_, err := c.conn.Write(mensagem)
+	return c.proximoID, err
+}
+
+// receber devolve a próxima operação endereçada ao messageID
+func (c *Conexao) receber(id int) (elemento, error) {
+	for {
+		mensagem, err := lerMensagem(c.leitor)
+		if err != nil {
+			return elemento{}, err
+		}
+		if mensagem.Tag != tagSequence || len(mensagem.Filhos) < 2 {
+			return elemento{}, errBERInvalido
+		}
+		switch mensagem.Filhos[0].Inteiro() {
+		case id:
+			return mensagem.Filhos[1], nil
+		case 0:
+			// Notice of Disconnection (RFC 4511, 4.4.1)
+			return elemento{}, fmt.Errorf("ldap: servidor encerrou a conexão: %w", resultado(mensagem.Filhos[1]))
+		}
+	}
+}
+
+// resultado interpreta o LDAPResult no início da resposta
+func resultado(resposta elemento) error {
+	if len(resposta.Filhos) < 3 {
+		return errBERInvalido
+	}
+	codigo := resposta.Filhos[0].Inteiro()
+	if codigo == ResultadoSucesso {
+		return nil
+	}
+	return &ErroLDAP{Codigo: codigo, Mensagem: string(resposta.Filhos[2].Valor)}
+}
+
+func lerEntrada(resposta elemento) (Entrada, error) {
+	if len(resposta.Filhos) < 2 {
+		return Entrada{}, errBERInvalido
+	}
+	entrada := Entrada{DN: string(resposta.Filhos[0].Valor), Atributos: map[string][]string{}}
+	for _, atributo := range resposta.Filhos[1].Filhos {
+		if len(atributo.Filhos) < 2 {
+			return Entrada{}, errBERInvalido
+		}
+		nome := string(atributo.Filhos[0].Valor)
+		for _, valor := range atributo.Filhos[1].Filhos {
+			entrada.Atributos[nome] = append(entrada.Atributos[nome], string(valor.Valor))
+		}
+	}
+	return entrada, nil
+}
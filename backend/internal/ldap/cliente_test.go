@@ -0,0 +1,111 @@
+package ldap
+
+import (
+	"errors"
+	"testing"
+)
+
+func novoDiretorioTeste(t *testing.T) *ServidorSimulado {
+	t.Helper()
+	servidor, err := NovoServidorSimulado(
+		EntradaSimulada{Entrada: Entrada{DN: "cn=servico,dc=exemplo,dc=local"}, Senha: "servico123"},
+		EntradaSimulada{
+			Entrada: Entrada{DN: "uid=agente,ou=pessoas,dc=exemplo,dc=local", Atributos: map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {"agente"},
+				"mail":        {"agente@exemplo.local"},
+				"memberOf":    {"cn=policia,dc=exemplo,dc=local", "cn=admin,dc=exemplo,dc=local"},
+			}},
+			Senha: "senha123",
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { servidor.Fechar() })
+	return servidor
+}
+
+func conectarTeste(t *testing.T, servidor *ServidorSimulado) *Conexao {
+	t.Helper()
+	conn, err := Conectar(Opcoes{URL: servidor.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Fechar() })
+	return conn
+}
+
+func TestBind(t *testing.T) {
+	servidor := novoDiretorioTeste(t)
+
+	casos := []struct {
+		nome   string
+		dn     string
+		senha  string
+		espera error
+	}{
+		{"senha certa", "uid=agente,ou=pessoas,dc=exemplo,dc=local", "senha123", nil},
+		{"DN sem diferenciar maiúsculas", "UID=agente,OU=pessoas,DC=exemplo,DC=local", "senha123", nil},
+		{"senha errada", "uid=agente,ou=pessoas,dc=exemplo,dc=local", "errada", ErrCredenciaisInvalidas},
+		{"DN inexistente", "uid=outro,ou=pessoas,dc=exemplo,dc=local", "senha123", ErrCredenciaisInvalidas},
+		// O bind sem senha seria anônimo no servidor (RFC 4513, 5.1.2)
+		{"senha vazia", "uid=agente,ou=pessoas,dc=exemplo,dc=local", "", ErrCredenciaisInvalidas},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			conn := conectarTeste(t, servidor)
+			if err := conn.Bind(c.dn, c.senha); !errors.Is(err, c.espera) {
+				t.Fatalf("Bind = %v, esperado %v", err, c.espera)
+			}
+		})
+	}
+}
+
+func TestBuscar(t *testing.T) {
+	servidor := novoDiretorioTeste(t)
+	conn := conectarTeste(t, servidor)
+	if err := conn.Bind("cn=servico,dc=exemplo,dc=local", "servico123"); err != nil {
+		t.Fatal(err)
+	}
+
+	entradas, err := conn.Buscar("dc=exemplo,dc=local",
+		map[string]string{"objectClass": "person", "uid": "agente"},
+		[]string{"mail", "memberOf", "employeeNumber"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entradas) != 1 {
+		t.Fatalf("%d entradas, esperada 1", len(entradas))
+	}
+	e := entradas[0]
+	if e.DN != "uid=agente,ou=pessoas,dc=exemplo,dc=local" {
+		t.Errorf("DN = %q", e.DN)
+	}
+	if e.Valor("MAIL") != "agente@exemplo.local" {
+		t.Errorf("mail = %q", e.Valor("mail"))
+	}
+	if grupos := e.Valores("memberOf"); len(grupos) != 2 {
+		t.Errorf("memberOf = %v, esperados 2 grupos", grupos)
+	}
+	if e.Valor("employeeNumber") != "" {
+		t.Errorf("atributo ausente veio preenchido: %q", e.Valor("employeeNumber"))
+	}
+
+	entradas, err = conn.Buscar("dc=exemplo,dc=local", map[string]string{"uid": "ninguem"}, []string{"mail"})
+	if err != nil || len(entradas) != 0 {
+		t.Fatalf("busca sem resultado = %v, %v", entradas, err)
+	}
+}
+
+func TestInteiroBER(t *testing.T) {
+	for _, v := range []int{0, 1, 127, 128, 255, 256, 65535, 1 << 20, -1, -128, -129} {
+		elementos, err := decodificar(inteiro(tagInteger, v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := elementos[0].Inteiro(); got != v {
+			t.Errorf("inteiro(%d) decodificado como %d", v, got)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package ldap
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+)
+
+// resultadoErroProtocolo responde às operações que o servidor simulado não atende
+const resultadoErroProtocolo = 2
+
+// EntradaSimulada é um objeto do diretório simulado. Senha vazia recusa o
+// bind no DN da entrada.
+type EntradaSimulada struct {
+	Entrada
+	Senha string
+}
+
+// ServidorSimulado é um servidor LDAP em processo, para os testes da
+// autenticação sem um diretório de verdade. Atende bind simples, busca por
+// igualdade de atributos (a usada por Buscar) e unbind, sem TLS.
+type ServidorSimulado struct {
+	// URL ldap:// do servidor, para Opcoes.URL
+	URL string
+
+	listener net.Listener
+	entradas []EntradaSimulada
+	conexoes sync.WaitGroup
+}
+
+// NovoServidorSimulado começa a atender em uma porta livre do loopback
+func NovoServidorSimulado(entradas ...EntradaSimulada) (*ServidorSimulado, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &ServidorSimulado{
+		URL:      "ldap://" + listener.Addr().String(),
+		listener: listener,
+		entradas: entradas,
+	}
+	go s.aceitar()
+	return s, nil
+}
+
+// Fechar para de aceitar conexões e espera as abertas terminarem
+func (s *ServidorSimulado) Fechar() error {
+	err := s.listener.Close()
+	s.conexoes.Wait()
+	return err
+}
+
+func (s *ServidorSimulado) aceitar() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.conexoes.Add(1)
+		go func() {
+			defer s.conexoes.Done()
+			defer conn.Close()
+			s.atender(conn)
+		}()
+	}
+}
+
+// atender responde às operações da conexão até o unbind ou o fim do fluxo
+func (s *ServidorSimulado) atender(conn net.Conn) {
+	leitor := bufio.NewReader(conn)
+	for {
+		mensagem, err := lerMensagem(leitor)
+		if err != nil || mensagem.Tag != tagSequence || len(mensagem.Filhos) < 2 {
+			return
+		}
+		id, operacao := mensagem.Filhos[0].Inteiro(), mensagem.Filhos[1]
+
+		var respostas [][]byte
+		switch operacao.Tag {
+		case opBindRequest:
+			respostas = append(respostas, respostaResultado(opBindResponse, s.bind(operacao)))
+		case opSearchRequest:
+			entradas, codigo := s.buscar(operacao)
+			respostas = append(respostas, entradas...)
+			respostas = append(respostas, respostaResultado(opSearchResultDone, codigo))
+		case opExtendedRequest:
+			respostas = append(respostas, respostaResultado(opExtendedResponse, resultadoErroProtocolo))
+		default:
+			// Unbind e operações não atendidas encerram a conexão
+			return
+		}
+
+		for _, resposta := range respostas {
+			if _, err := conn.Write(construir(tagSequence, inteiro(tagInteger, id), resposta)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func respostaResultado(operacao byte, codigo int) []byte {
+	return construir(operacao,
+		inteiro(tagEnumerated, codigo),
+		texto(tagOctetString, ""),
+		texto(tagOctetString, ""),
+	)
+}
+
+// bind confere a senha simples do DN
+func (s *ServidorSimulado) bind(operacao elemento) int {
+	if len(operacao.Filhos) < 3 || operacao.Filhos[2].Tag != classeContexto|0 {
+		return resultadoErroProtocolo
+	}
+	dn, senha := string(operacao.Filhos[1].Valor), string(operacao.Filhos[2].Valor)
+	for _, e := range s.entradas {
+		if strings.EqualFold(e.DN, dn) && e.Senha != "" && e.Senha == senha {
+			return ResultadoSucesso
+		}
+	}
+	return ResultadoCredenciaisInvalidas
+}
+
+// buscar devolve as entradas da subárvore da base que atendem a todas as
+// igualdades do filtro and, com os atributos pedidos
+func (s *ServidorSimulado) buscar(operacao elemento) ([][]byte, int) {
+	if len(operacao.Filhos) < 8 || operacao.Filhos[6].Tag != classeContexto|construido|0 {
+		return nil, resultadoErroProtocolo
+	}
+	base := strings.ToLower(string(operacao.Filhos[0].Valor))
+	filtro := operacao.Filhos[6].Filhos
+	var pedidos []string
+	for _, a := range operacao.Filhos[7].Filhos {
+		pedidos = append(pedidos, string(a.Valor))
+	}
+
+	var respostas [][]byte
+	for _, e := range s.entradas {
+		if !strings.HasSuffix(strings.ToLower(e.DN), base) || !atendeFiltro(e.Entrada, filtro) {
+			continue
+		}
+		var atributos [][]byte
+		for _, nome := range pedidos {
+			valores := e.Valores(nome)
+			if len(valores) == 0 {
+				continue
+			}
+			codificados := make([][]byte, len(valores))
+			for i, v := range valores {
+				codificados[i] = texto(tagOctetString, v)
+			}
+			atributos = append(atributos, construir(tagSequence, texto(tagOctetString, nome), construir(tagSet, codificados...)))
+		}
+		respostas = append(respostas, construir(opSearchResultEntry,
+			texto(tagOctetString, e.DN),
+			construir(tagSequence, atributos...),
+		))
+	}
+	return respostas, ResultadoSucesso
+}
+
+func atendeFiltro(e Entrada, igualdades []elemento) bool {
+	for _, igualdade := range igualdades {
+		if igualdade.Tag != classeContexto|construido|3 || len(igualdade.Filhos) < 2 {
+			return false
+		}
+		encontrado := false
+		for _, v := range e.Valores(string(igualdade.Filhos[0].Valor)) {
+			if strings.EqualFold(v, string(igualdade.Filhos[1].Valor)) {
+				encontrado = true
+				break
+			}
+		}
+		if !encontrado {
+			return false
+		}
+	}
+	return true
+}
@@ -2,31 +2,44 @@ package middleware
 
 import (
 	"context"
+	"log"
 	"net/http"
-	
+	"strings"
+	"sync"
+
 	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/repository"
 	"github.com/tassyosilva/consultapix/internal/services/auth"
 )
 
 type AuthMiddleware struct {
-	authService *auth.AuthService
+	authService        *auth.AuthService
+	permitirTokenNaURL bool
+	avisoTokenNaURL    sync.Once
 }
 
 func NewAuthMiddleware(cfg *config.Config) *AuthMiddleware {
 	return &AuthMiddleware{
-		authService: auth.NewAuthService(cfg),
+		authService:        auth.NewAuthService(cfg),
+		permitirTokenNaURL: cfg.PermitirTokenNaURL,
 	}
 }
 
 func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Obter token do parâmetro de consulta
-		token := r.URL.Query().Get("token")
+		// Obter token do cabeçalho, do cookie de sessão ou, se permitido, da URL
+		token, origem := m.extrairToken(w, r)
 		if token == "" {
 			http.Error(w, "Token não fornecido", http.StatusUnauthorized)
 			return
 		}
 
+		// O navegador envia o cookie sozinho, inclusive em requisições forjadas
+		if origem == origemCookie && !CSRFValido(r) {
+			http.Error(w, "Token CSRF inválido", http.StatusForbidden)
+			return
+		}
+
 		// Validar token
 		claims, err := m.authService.ValidateToken(token)
 		if err != nil {
@@ -34,8 +47,181 @@ func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		// A sessão precisa continuar aberta: revogada, encerrada pelo
+		// administrador ou parada além do limite de inatividade, o token cai
+		if err := m.authService.RegistrarAtividade(claims); err != nil {
+			if err != auth.ErrSessaoInvalida && err != auth.ErrSessaoInativa {
+				log.Printf("Erro ao conferir a sessão: %v", err)
+			}
+			http.Error(w, "Sessão expirada", http.StatusUnauthorized)
+			return
+		}
+
+		// Enquanto o segundo fator obrigatório não for cadastrado ou a senha
+		// expirada não for trocada, o token só serve para resolver isso ou sair
+		if (claims.CadastroDoisFatores || claims.TrocaSenha) && !rotaLiberada(claims, r.URL.Path) {
+			mensagem := "Cadastre a autenticação em dois fatores para continuar"
+			if claims.TrocaSenha {
+				mensagem = "Sua senha expirou; cadastre uma nova senha para continuar"
+			}
+			http.Error(w, mensagem, http.StatusForbidden)
+			return
+		}
+
 		// Armazenar informações do usuário no contexto
 		ctx := context.WithValue(r.Context(), "user", claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
-}
\ No newline at end of file
+}
+
+// rotaLiberada indica as rotas liberadas a quem ainda precisa cadastrar o
+// segundo fator ou trocar a senha expirada
+func rotaLiberada(claims *auth.JWTClaims, caminho string) bool {
+	switch {
+	case caminho == "/api/user/sessao", caminho == "/api/user/logout", caminho == "/api/user/logout-all":
+		return true
+	case claims.CadastroDoisFatores && strings.HasPrefix(caminho, "/api/user/2fa"):
+		return true
+	case claims.TrocaSenha && caminho == "/api/user/senha":
+		return true
+	}
+	return false
+}
+
+// Origens possíveis do token de acesso
+const (
+	origemCabecalho = "cabecalho"
+	origemCookie    = "cookie"
+	origemURL       = "url"
+)
+
+func (m *AuthMiddleware) extrairToken(w http.ResponseWriter, r *http.Request) (string, string) {
+	if cabecalho := r.Header.Get("Authorization"); strings.HasPrefix(cabecalho, "Bearer ") {
+		return strings.TrimPrefix(cabecalho, "Bearer "), origemCabecalho
+	}
+	if cookie, err := r.Cookie(CookieToken); err == nil && cookie.Value != "" {
+		return cookie.Value, origemCookie
+	}
+	if token := r.URL.Query().Get("token"); token != "" && m.permitirTokenNaURL {
+		m.avisoTokenNaURL.Do(func() {
+			log.Println("Aviso: token recebido na URL; este modo está obsoleto e será removido (AUTH_ALLOW_QUERY_TOKEN)")
+		})
+		w.Header().Set("Deprecation", "true")
+		return token, origemURL
+	}
+	return "", ""
+}
+
+// UsuarioAutenticado retorna as claims do usuário armazenadas pelo Authenticate
+func UsuarioAutenticado(r *http.Request) *auth.JWTClaims {
+	claims, _ := r.Context().Value("user").(*auth.JWTClaims)
+	return claims
+}
+
+// EscopoDaRequisicao define quais requisições o usuário autenticado pode ver
+func EscopoDaRequisicao(r *http.Request) repository.Escopo {
+	claims := UsuarioAutenticado(r)
+	if claims == nil {
+		return repository.Escopo{}
+	}
+	return repository.Escopo{
+		CPF:     claims.CPF,
+		Lotacao: claims.Lotacao,
+		Admin:   claims.Admin,
+		Perfil:  claims.Perfil,
+		Unidade: claims.Unidade,
+	}
+}
+
+// ResponsavelDaConsulta devolve o usuário autenticado, que responde pela
+// consulta ao BACEN: a lotação dele escolhe a credencial, como já escolhe a
+// cota. Os parâmetros cpfResponsavel e lotacao, que o frontend ainda envia,
+// só são aceitos quando coincidem com o token. Em caso de erro a resposta já
+// foi escrita.
+func ResponsavelDaConsulta(w http.ResponseWriter, r *http.Request) (*auth.JWTClaims, bool) {
+	claims := UsuarioAutenticado(r)
+	if claims == nil {
+		http.Error(w, "Token inválido", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	q := r.URL.Query()
+	if cpf := strings.TrimSpace(q.Get("cpfResponsavel")); cpf != "" && cpf != claims.CPF {
+		http.Error(w, "O responsável pela consulta deve ser o usuário autenticado", http.StatusForbidden)
+		return nil, false
+	}
+	if lotacao := strings.TrimSpace(q.Get("lotacao")); lotacao != "" && lotacao != claims.Lotacao {
+		http.Error(w, "A lotação da consulta deve ser a do usuário autenticado", http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
+
+// ExigirConsulta restringe a rota a quem pode consultar o BACEN; o auditor só
+// lê. As consultas usam GET e por isso não passam por ExigirPerfilNaEscrita.
+// Deve ser usado depois de Authenticate.
+func ExigirConsulta(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := UsuarioAutenticado(r)
+		if claims == nil {
+			http.Error(w, "Token inválido", http.StatusUnauthorized)
+			return
+		}
+		if somenteLeitura(claims) {
+			http.Error(w, "O perfil de auditor é somente leitura", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// rotasDaPropriaConta são os prefixos em que o usuário só altera a própria
+// conta (senha, dois fatores, sessões, notificações) e que o auditor também usa
+var rotasDaPropriaConta = []string{"/api/user/", "/api/notificacoes/"}
+
+// ExigirPerfilNaEscrita aplica o perfil a todas as rotas que alteram dados:
+// fora das rotas da própria conta, o auditor só pode usar GET. Deve ser usado
+// depois de Authenticate, no roteador das rotas protegidas.
+func ExigirPerfilNaEscrita(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, prefixo := range rotasDaPropriaConta {
+			if strings.HasPrefix(r.URL.Path, prefixo) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		claims := UsuarioAutenticado(r)
+		if claims == nil {
+			http.Error(w, "Token inválido", http.StatusUnauthorized)
+			return
+		}
+		if somenteLeitura(claims) {
+			http.Error(w, "O perfil de auditor é somente leitura", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// somenteLeitura indica o auditor sem perfil de administrador
+func somenteLeitura(claims *auth.JWTClaims) bool {
+	return claims.Perfil == repository.PerfilAuditor && !claims.Admin
+}
+
+// SomenteAdmin restringe a rota a administradores. Deve ser usado depois de Authenticate.
+func SomenteAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := UsuarioAutenticado(r)
+		if claims == nil || !claims.Admin {
+			http.Error(w, "Acesso restrito a administradores", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
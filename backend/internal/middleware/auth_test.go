@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/auth"
+)
+
+func TestExigirPerfilNaEscrita(t *testing.T) {
+	auditor := &auth.JWTClaims{CPF: "00000000191", Perfil: repository.PerfilAuditor}
+	analista := &auth.JWTClaims{CPF: "00000000272", Perfil: repository.PerfilAnalista}
+	auditorAdmin := &auth.JWTClaims{CPF: "00000000353", Perfil: repository.PerfilAuditor, Admin: true}
+
+	casos := []struct {
+		nome    string
+		metodo  string
+		caminho string
+		claims  *auth.JWTClaims
+		status  int
+	}{
+		{"auditor lê", http.MethodGet, "/api/monitoramentos", auditor, http.StatusOK},
+		{"auditor cria monitoramento", http.MethodPost, "/api/monitoramentos", auditor, http.StatusForbidden},
+		{"auditor encerra monitoramento", http.MethodPost, "/api/monitoramentos/1/encerrar", auditor, http.StatusForbidden},
+		{"auditor remove unidade", http.MethodDelete, "/api/admin/unidades/1", auditor, http.StatusForbidden},
+		{"auditor troca a própria senha", http.MethodPost, "/api/user/senha", auditor, http.StatusOK},
+		{"auditor marca notificação lida", http.MethodPost, "/api/notificacoes/1/lida", auditor, http.StatusOK},
+		{"auditor administrador", http.MethodPost, "/api/monitoramentos", auditorAdmin, http.StatusOK},
+		{"analista", http.MethodPost, "/api/monitoramentos", analista, http.StatusOK},
+		{"sem token", http.MethodPost, "/api/monitoramentos", nil, http.StatusUnauthorized},
+	}
+
+	handler := ExigirPerfilNaEscrita(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			r := httptest.NewRequest(c.metodo, c.caminho, nil)
+			if c.claims != nil {
+				r = r.WithContext(context.WithValue(r.Context(), "user", c.claims))
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != c.status {
+				t.Fatalf("esperava %d, obteve %d", c.status, w.Code)
+			}
+		})
+	}
+}
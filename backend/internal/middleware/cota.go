@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/notificacao"
+)
+
+type CotaMiddleware struct {
+	cotas        *repository.CotaRepository
+	notificacoes *notificacao.NotificacaoService
+}
+
+func NewCotaMiddleware(cfg *config.Config) *CotaMiddleware {
+	return &CotaMiddleware{
+		cotas:        repository.NewCotaRepository(),
+		notificacoes: notificacao.NewNotificacaoService(cfg),
+	}
+}
+
+// Limitar consome uma unidade da cota da operação antes de chamar o handler.
+// A cota é contada pelo CPF e pela lotação do token, não pelos parâmetros da
+// consulta. Deve ser usado depois de Authenticate.
+func (m *CotaMiddleware) Limitar(operacao string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := UsuarioAutenticado(r)
+		if claims == nil {
+			http.Error(w, "Token inválido", http.StatusUnauthorized)
+			return
+		}
+
+		usos, err := m.cotas.Consumir(claims.CPF, claims.Lotacao, operacao)
+		var excedida *repository.CotaExcedidaError
+		if errors.As(err, &excedida) {
+			w.Header().Set("Retry-After", strconv.Itoa(segundosAteRenovar(excedida.Periodo, time.Now())))
+			http.Error(w, excedida.Error(), http.StatusTooManyRequests)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao verificar a cota de consultas", http.StatusInternalServerError)
+			return
+		}
+		m.notificacoes.AvisarCotaProxima(claims.CPF, usos)
+
+		next(w, r)
+	}
+}
+
+// segundosAteRenovar calcula quando a cota do período volta a ter saldo
+func segundosAteRenovar(periodo string, agora time.Time) int {
+	inicioDia := time.Date(agora.Year(), agora.Month(), agora.Day(), 0, 0, 0, 0, agora.Location())
+	renovacao := inicioDia.AddDate(0, 0, 1)
+	if periodo == "mensal" {
+		renovacao = time.Date(agora.Year(), agora.Month()+1, 1, 0, 0, 0, 0, agora.Location())
+	}
+	return int(renovacao.Sub(agora).Seconds()) + 1
+}
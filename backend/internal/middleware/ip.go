@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/tassyosilva/consultapix/internal/services/auth"
+)
+
+// IPCliente retorna o IP de quem fez a requisição. O cabeçalho X-Real-IP,
+// preenchido pelo nginx, só é aceito quando a conexão vem de um proxy
+// confiável; de qualquer outro lugar ele poderia ser forjado.
+func IPCliente(r *http.Request, proxiesConfiaveis []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remoto := net.ParseIP(host)
+	if remoto == nil {
+		return ""
+	}
+
+	if real := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); real != nil {
+		for _, rede := range proxiesConfiaveis {
+			if rede.Contains(remoto) {
+				return real.String()
+			}
+		}
+	}
+	return remoto.String()
+}
+
+// ClienteSessao identifica o dispositivo da requisição para registrar a sessão
+func ClienteSessao(r *http.Request, proxiesConfiaveis []*net.IPNet) auth.ClienteSessao {
+	return auth.ClienteSessao{
+		IP:            IPCliente(r, proxiesConfiaveis),
+		AgenteUsuario: r.UserAgent(),
+	}
+}
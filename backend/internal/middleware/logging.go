@@ -1,19 +1,48 @@
 package middleware
 
 import (
-    "log"
-    "net/http"
-    "time"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
+// parametrosSensiveis são os parâmetros de URL cujo valor nunca vai para o log
+var parametrosSensiveis = map[string]bool{
+	"token":         true,
+	"access_token":  true,
+	"refreshtoken":  true,
+	"refresh_token": true,
+	// Código de autorização do retorno do login único
+	"code": true,
+}
+
 // LoggingMiddleware registra informações sobre as requisições
 func LoggingMiddleware(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        start := time.Now()
-        log.Printf("Requisição iniciada: %s %s", r.Method, r.URL.Path)
-        
-        next.ServeHTTP(w, r)
-        
-        log.Printf("Requisição concluída: %s %s em %v", r.Method, r.URL.Path, time.Since(start))
-    })
-}
\ No newline at end of file
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		alvo := URLRedigida(r.URL)
+		log.Printf("Requisição iniciada: %s %s", r.Method, alvo)
+
+		next.ServeHTTP(w, r)
+
+		log.Printf("Requisição concluída: %s %s em %v", r.Method, alvo, time.Since(start))
+	})
+}
+
+// URLRedigida retorna o caminho e a query da URL com os tokens substituídos
+func URLRedigida(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+
+	partes := strings.Split(u.RawQuery, "&")
+	for i, parte := range partes {
+		nome, _, _ := strings.Cut(parte, "=")
+		if chave, err := url.QueryUnescape(nome); err == nil && parametrosSensiveis[strings.ToLower(chave)] {
+			partes[i] = nome + "=REDACTED"
+		}
+	}
+	return u.Path + "?" + strings.Join(partes, "&")
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/bacen"
+)
+
+type ReaproveitamentoMiddleware struct {
+	pixService *bacen.PixService
+	auditoria  *repository.AuditoriaRepository
+}
+
+func NewReaproveitamentoMiddleware(cfg *config.Config) *ReaproveitamentoMiddleware {
+	return &ReaproveitamentoMiddleware{
+		pixService: bacen.NewPixService(cfg),
+		auditoria:  repository.NewAuditoriaRepository(),
+	}
+}
+
+// Reaproveitar devolve o resultado gravado quando o mesmo alvo (lido do
+// parâmetro informado) já foi consultado no mesmo caso dentro da janela de
+// reaproveitamento, numa requisição visível ao usuário, sem chamar o BACEN nem
+// consumir cota. A idade do resultado
+// vai no cabeçalho Age. Uma nova consulta exige forcar=true e justificativa.
+// Os dois desfechos são auditados.
+func (m *ReaproveitamentoMiddleware) Reaproveitar(tipoBusca, parametro string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := UsuarioAutenticado(r)
+		if claims == nil {
+			http.Error(w, "Token inválido", http.StatusUnauthorized)
+			return
+		}
+
+		q := r.URL.Query()
+		alvo, caso := q.Get(parametro), q.Get("caso")
+		forcar := q.Get("forcar") == "true"
+		justificativa := strings.TrimSpace(q.Get("justificativa"))
+		if forcar && justificativa == "" {
+			http.Error(w, "Informe a justificativa para forçar uma nova consulta ao BACEN", http.StatusBadRequest)
+			return
+		}
+
+		recente, err := m.pixService.ResultadoRecente(EscopoDaRequisicao(r), tipoBusca, alvo, caso)
+		if err != nil {
+			http.Error(w, "Erro ao verificar consultas recentes", http.StatusInternalServerError)
+			return
+		}
+		if recente == nil {
+			next(w, r)
+			return
+		}
+
+		idade := time.Since(recente.Data)
+		evento := &models.EventoAuditoria{
+			CPFUsuario: claims.CPF,
+			Lotacao:    claims.Lotacao,
+			Acao:       repository.AcaoResultadoReaproveitado,
+			Alvo:       alvo,
+			Detalhes: map[string]interface{}{
+				"tipoBusca":            tipoBusca,
+				"caso":                 caso,
+				"idRequisicaoOriginal": recente.IDRequisicao,
+				"idadeSegundos":        int(idade.Seconds()),
+			},
+		}
+		if forcar {
+			evento.Acao = repository.AcaoConsultaForcada
+			evento.Detalhes["justificativa"] = justificativa
+		}
+		if err := m.auditoria.Registrar(evento); err != nil {
+			http.Error(w, "Erro ao registrar auditoria", http.StatusInternalServerError)
+			return
+		}
+
+		if forcar {
+			next(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Age", strconv.Itoa(int(idade.Seconds())))
+		w.Header().Set("X-Requisicao-Reaproveitada", strconv.Itoa(recente.IDRequisicao))
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(recente.Resultado)
+	}
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/auth"
+	"github.com/tassyosilva/consultapix/internal/services/bacen"
+)
+
+// conectarBancoTeste usa o PostgreSQL indicado em CONSULTAPIX_TEST_DATABASE_URL,
+// aplicando as migrações pendentes. Sem a variável o teste é ignorado.
+func conectarBancoTeste(t *testing.T) *config.Config {
+	t.Helper()
+	databaseURL := os.Getenv("CONSULTAPIX_TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("defina CONSULTAPIX_TEST_DATABASE_URL para executar os testes com banco")
+	}
+
+	cfg := &config.Config{DatabaseURL: databaseURL, JanelaReaproveitamento: time.Hour}
+	if err := database.Conectar(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.DB.Close() })
+	if _, err := database.Migrar(database.DB); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestReaproveitarRespeitaEscopoDoSupervisor(t *testing.T) {
+	cfg := conectarBancoTeste(t)
+	db := database.GetDB()
+	sufixo := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	// Dois departamentos sem relação entre si
+	unidades := repository.NewUnidadeRepository()
+	unidadeA := &models.Unidade{Nome: "Teste A " + sufixo, Tipo: repository.TipoDepartamento}
+	unidadeB := &models.Unidade{Nome: "Teste B " + sufixo, Tipo: repository.TipoDepartamento}
+	for _, u := range []*models.Unidade{unidadeA, unidadeB} {
+		if err := unidades.Criar(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Consulta recente de um analista da unidade A
+	chave, caso := "teste-"+sufixo+"@exemplo.com", "CASO-"+sufixo
+	id, err := repository.NewPixRepository().CriarRequisicaoPix(&models.RequisicaoPix{
+		Data:           time.Now(),
+		CPFResponsavel: "00000000191",
+		Lotacao:        "Teste",
+		IDUnidade:      &unidadeA.ID,
+		Caso:           caso,
+		TipoBusca:      bacen.TipoBuscaChave,
+		ChaveBusca:     chave,
+		MotivoBusca:    "teste",
+		Resultado:      "Sucesso",
+		Vinculos:       map[string]string{"chave": chave},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM requisicao_pix WHERE id = $1`, id)
+		db.Exec(`DELETE FROM unidade WHERE id IN ($1, $2)`, unidadeA.ID, unidadeB.ID)
+	})
+
+	casos := []struct {
+		nome          string
+		unidade       int
+		reaproveitada bool
+	}{
+		{"supervisor de outra unidade", unidadeB.ID, false},
+		{"supervisor da unidade", unidadeA.ID, true},
+	}
+
+	m := NewReaproveitamentoMiddleware(cfg)
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			consultou := false
+			handler := m.Reaproveitar(bacen.TipoBuscaChave, "chave", func(w http.ResponseWriter, r *http.Request) {
+				consultou = true
+			})
+
+			claims := &auth.JWTClaims{CPF: "00000000272", Lotacao: "Teste", Perfil: repository.PerfilSupervisor, Unidade: c.unidade}
+			r := httptest.NewRequest(http.MethodGet, "/api/bacen/pix/chave?"+url.Values{"chave": {chave}, "caso": {caso}}.Encode(), nil)
+			r = r.WithContext(context.WithValue(r.Context(), "user", claims))
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			reaproveitada := w.Header().Get("X-Requisicao-Reaproveitada")
+			if c.reaproveitada && reaproveitada != strconv.Itoa(id) {
+				t.Fatalf("esperava reaproveitar a requisição %d, obteve %q", id, reaproveitada)
+			}
+			if !c.reaproveitada && (reaproveitada != "" || !consultou) {
+				t.Fatalf("não esperava reaproveitamento, obteve %q", reaproveitada)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+)
+
+// Cookies da sessão do navegador
+const (
+	// CookieToken guarda o JWT de acesso, enviado em todas as rotas da API
+	CookieToken = "consultapix_token"
+	// CookieRefresh guarda o refresh token, enviado só às rotas de usuário
+	CookieRefresh = "consultapix_refresh"
+	// CookieCSRF é legível pelo JavaScript, que o devolve em CabecalhoCSRF
+	CookieCSRF = "consultapix_csrf"
+	// CabecalhoCSRF é o cabeçalho que confirma que a requisição veio do frontend
+	CabecalhoCSRF = "X-CSRF-Token"
+)
+
+// DefinirCookiesSessao grava os tokens em cookies HttpOnly e SameSite=Strict e
+// um novo valor de CSRF (double submit)
+func DefinirCookiesSessao(w http.ResponseWriter, cfg *config.Config, accessToken, refreshToken string) {
+	http.SetCookie(w, &http.Cookie{
+		Name: CookieToken, Value: accessToken, Path: "/api",
+		MaxAge: int(cfg.DuracaoAccessToken / time.Second), HttpOnly: true, Secure: cfg.CookieSeguro, SameSite: http.SameSiteStrictMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name: CookieRefresh, Value: refreshToken, Path: "/api/user",
+		MaxAge: int(cfg.DuracaoRefreshToken / time.Second), HttpOnly: true, Secure: cfg.CookieSeguro, SameSite: http.SameSiteStrictMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name: CookieCSRF, Value: valorCSRF(), Path: "/",
+		MaxAge: int(cfg.DuracaoRefreshToken / time.Second), Secure: cfg.CookieSeguro, SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// LimparCookiesSessao remove os cookies da sessão do navegador
+func LimparCookiesSessao(w http.ResponseWriter, cfg *config.Config) {
+	for nome, caminho := range map[string]string{CookieToken: "/api", CookieRefresh: "/api/user", CookieCSRF: "/"} {
+		http.SetCookie(w, &http.Cookie{
+			Name: nome, Value: "", Path: caminho, MaxAge: -1,
+			HttpOnly: nome != CookieCSRF, Secure: cfg.CookieSeguro, SameSite: http.SameSiteStrictMode,
+		})
+	}
+}
+
+// CSRFValido confere se o cabeçalho X-CSRF-Token repete o cookie de CSRF.
+// Métodos seguros não alteram estado e dispensam a verificação.
+func CSRFValido(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	cookie, err := r.Cookie(CookieCSRF)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	cabecalho := r.Header.Get(CabecalhoCSRF)
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(cabecalho)) == 1
+}
+
+func valorCSRF() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
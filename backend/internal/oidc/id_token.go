@@ -0,0 +1,250 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// algoritmosAceitos são as assinaturas assimétricas aceitas no ID token; HS256
+// e none ficam de fora
+var algoritmosAceitos = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// ErrIDTokenInvalido indica ID token com assinatura, emissor, audiência,
+// validade ou nonce incorretos
+var ErrIDTokenInvalido = errors.New("oidc: id_token inválido")
+
+// Claims são as claims do ID token ou do userinfo
+type Claims map[string]interface{}
+
+// Texto retorna a claim no caminho (com ponto entre os níveis) como texto;
+// números são mantidos como escritos no JSON
+func (c Claims) Texto(caminho string) string {
+	switch v := c.valor(caminho).(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+// Lista retorna a claim no caminho como lista de textos; um texto isolado vira
+// uma lista de um item
+func (c Claims) Lista(caminho string) []string {
+	switch v := c.valor(caminho).(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var lista []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				lista = append(lista, s)
+			}
+		}
+		return lista
+	}
+	return nil
+}
+
+func (c Claims) valor(caminho string) interface{} {
+	var atual interface{} = map[string]interface{}(c)
+	for _, parte := range strings.Split(caminho, ".") {
+		objeto, ok := atual.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		atual = objeto[parte]
+	}
+	return atual
+}
+
+// ValidarIDToken confere assinatura, emissor, audiência, validade e o nonce
+// do login (OIDC Core, 3.1.3.7)
+func (p *Provedor) ValidarIDToken(idToken, nonce string) (Claims, error) {
+	metadados, err := p.Metadados()
+	if err != nil {
+		return nil, err
+	}
+
+	parser := jwt.NewParser(jwt.WithValidMethods(algoritmosAceitos), jwt.WithJSONNumber())
+	token, err := parser.ParseWithClaims(idToken, jwt.MapClaims{}, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.chave(kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIDTokenInvalido, err)
+	}
+	claims := token.Claims.(jwt.MapClaims)
+
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("%w: sem exp", ErrIDTokenInvalido)
+	}
+	if !claims.VerifyIssuer(metadados.Emissor, true) {
+		return nil, fmt.Errorf("%w: emissor %v", ErrIDTokenInvalido, claims["iss"])
+	}
+	if !claims.VerifyAudience(p.opcoes.ClienteID, true) {
+		return nil, fmt.Errorf("%w: audiência %v", ErrIDTokenInvalido, claims["aud"])
+	}
+	// Com várias audiências, o token deve ter sido emitido para este cliente
+	if aud, ok := claims["aud"].([]interface{}); ok && len(aud) > 1 && claims["azp"] != p.opcoes.ClienteID {
+		return nil, fmt.Errorf("%w: azp %v", ErrIDTokenInvalido, claims["azp"])
+	}
+	if n, _ := claims["nonce"].(string); n == "" || n != nonce {
+		return nil, fmt.Errorf("%w: nonce", ErrIDTokenInvalido)
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, fmt.Errorf("%w: sem sub", ErrIDTokenInvalido)
+	}
+
+	return Claims(claims), nil
+}
+
+// chavePublica é uma chave de assinatura do JWKS do provedor
+type chavePublica struct {
+	kid   string
+	chave interface{}
+}
+
+// chave localiza a chave pelo kid. Um kid desconhecido recarrega o JWKS, pois
+// o provedor pode ter rotacionado as chaves.
+func (p *Provedor) chave(kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if time.Since(p.chavesEm) >= validadeMetadados {
+		p.chaves = nil
+	}
+	if c := buscarChave(p.chaves, kid); c != nil {
+		return c, nil
+	}
+	if time.Since(p.chavesEm) < intervaloRecargaChaves {
+		return nil, fmt.Errorf("chave %q desconhecida", kid)
+	}
+
+	metadados, err := p.metadadosLocked()
+	if err != nil {
+		return nil, err
+	}
+	chaves, err := p.lerChaves(metadados.URIChaves)
+	if err != nil {
+		return nil, err
+	}
+	p.chaves, p.chavesEm = chaves, time.Now()
+
+	if c := buscarChave(p.chaves, kid); c != nil {
+		return c, nil
+	}
+	return nil, fmt.Errorf("chave %q desconhecida", kid)
+}
+
+// buscarChave aceita token sem kid quando o provedor publica uma única chave
+func buscarChave(chaves []chavePublica, kid string) interface{} {
+	if kid == "" && len(chaves) == 1 {
+		return chaves[0].chave
+	}
+	for _, c := range chaves {
+		if kid != "" && c.kid == kid {
+			return c.chave
+		}
+	}
+	return nil
+}
+
+// jwk é uma chave no formato JSON Web Key (RFC 7517)
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// lerChaves busca o JWKS e converte as chaves de assinatura RSA e EC; as
+// demais são ignoradas
+func (p *Provedor) lerChaves(uri string) ([]chavePublica, error) {
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	var conjunto struct {
+		Chaves []jwk `json:"keys"`
+	}
+	if err := p.executar(req, &conjunto); err != nil {
+		return nil, fmt.Errorf("oidc: jwks: %w", err)
+	}
+
+	var chaves []chavePublica
+	for _, k := range conjunto.Chaves {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		chave, err := k.chavePublica()
+		if err != nil {
+			continue
+		}
+		chaves = append(chaves, chavePublica{kid: k.Kid, chave: chave})
+	}
+	if len(chaves) == 0 {
+		return nil, errors.New("oidc: jwks sem chaves de assinatura suportadas")
+	}
+	return chaves, nil
+}
+
+func (k jwk) chavePublica() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := inteiroBase64(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := inteiroBase64(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("expoente RSA inválido")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curva elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curva = elliptic.P256()
+		case "P-384":
+			curva = elliptic.P384()
+		case "P-521":
+			curva = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("curva %q não suportada", k.Crv)
+		}
+		x, err := inteiroBase64(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := inteiroBase64(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curva, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("tipo de chave %q não suportado", k.Kty)
+}
+
+func inteiroBase64(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("inteiro base64url inválido")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
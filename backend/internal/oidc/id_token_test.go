@@ -0,0 +1,186 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	clienteTeste = "consultapix"
+	kidTeste     = "chave-1"
+	nonceTeste   = "nonce-do-login"
+)
+
+// provedorTeste publica a descoberta e o JWKS com a chave RSA gerada no teste
+type provedorTeste struct {
+	servidor *httptest.Server
+	chave    *rsa.PrivateKey
+}
+
+func novoProvedorTeste(t *testing.T) *provedorTeste {
+	t.Helper()
+	chave, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &provedorTeste{chave: chave}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(sufixoDescoberta, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Metadados{
+			Emissor:             p.servidor.URL,
+			EndpointAutorizacao: p.servidor.URL + "/autorizar",
+			EndpointToken:       p.servidor.URL + "/token",
+			URIChaves:           p.servidor.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string][]jwk{"keys": {{
+			Kty: "RSA",
+			Kid: kidTeste,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(chave.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(chave.E)).Bytes()),
+		}}})
+	})
+	p.servidor = httptest.NewServer(mux)
+	t.Cleanup(p.servidor.Close)
+	return p
+}
+
+func (p *provedorTeste) cliente() *Provedor {
+	return Novo(Opcoes{URLDescoberta: p.servidor.URL + sufixoDescoberta, ClienteID: clienteTeste})
+}
+
+// claimsValidas são as claims de um ID token aceito, que cada caso altera
+func (p *provedorTeste) claimsValidas() jwt.MapClaims {
+	agora := time.Now()
+	return jwt.MapClaims{
+		"iss":   p.servidor.URL,
+		"sub":   "usuario-1",
+		"aud":   clienteTeste,
+		"exp":   agora.Add(5 * time.Minute).Unix(),
+		"iat":   agora.Unix(),
+		"nonce": nonceTeste,
+	}
+}
+
+func (p *provedorTeste) assinar(t *testing.T, metodo jwt.SigningMethod, chave interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(metodo, claims)
+	token.Header["kid"] = kidTeste
+	assinado, err := token.SignedString(chave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return assinado
+}
+
+func TestValidarIDToken(t *testing.T) {
+	p := novoProvedorTeste(t)
+	provedor := p.cliente()
+
+	variasAudiencias := p.claimsValidas()
+	variasAudiencias["aud"] = []string{clienteTeste, "outro-cliente"}
+	variasAudiencias["azp"] = clienteTeste
+	casos := []struct {
+		nome   string
+		claims jwt.MapClaims
+	}{
+		{"audiência única", p.claimsValidas()},
+		{"várias audiências com azp certo", variasAudiencias},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			resultado, err := provedor.ValidarIDToken(p.assinar(t, jwt.SigningMethodRS256, p.chave, c.claims), nonceTeste)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resultado.Texto("sub") != "usuario-1" {
+				t.Fatalf("sub = %q", resultado.Texto("sub"))
+			}
+		})
+	}
+}
+
+func TestValidarIDTokenRecusa(t *testing.T) {
+	p := novoProvedorTeste(t)
+	provedor := p.cliente()
+
+	outraChave, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicaDER, err := x509.MarshalPKIXPublicKey(&p.chave.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// alterar devolve as claims válidas com a mudança do caso
+	alterar := func(mudanca func(jwt.MapClaims)) jwt.MapClaims {
+		claims := p.claimsValidas()
+		mudanca(claims)
+		return claims
+	}
+	rs256 := func(claims jwt.MapClaims) string {
+		return p.assinar(t, jwt.SigningMethodRS256, p.chave, claims)
+	}
+
+	casos := []struct {
+		nome  string
+		token string
+		nonce string
+	}{
+		{"emissor errado", rs256(alterar(func(c jwt.MapClaims) { c["iss"] = "https://outro.exemplo" })), nonceTeste},
+		{"sem emissor", rs256(alterar(func(c jwt.MapClaims) { delete(c, "iss") })), nonceTeste},
+		{"audiência errada", rs256(alterar(func(c jwt.MapClaims) { c["aud"] = "outro-cliente" })), nonceTeste},
+		{"várias audiências sem azp", rs256(alterar(func(c jwt.MapClaims) { c["aud"] = []string{clienteTeste, "outro-cliente"} })), nonceTeste},
+		{"várias audiências com azp de outro cliente", rs256(alterar(func(c jwt.MapClaims) {
+			c["aud"] = []string{clienteTeste, "outro-cliente"}
+			c["azp"] = "outro-cliente"
+		})), nonceTeste},
+		{"nonce diferente", rs256(p.claimsValidas()), "outro-nonce"},
+		{"sem nonce", rs256(alterar(func(c jwt.MapClaims) { delete(c, "nonce") })), ""},
+		{"sem exp", rs256(alterar(func(c jwt.MapClaims) { delete(c, "exp") })), nonceTeste},
+		{"expirado", rs256(alterar(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() })), nonceTeste},
+		{"sem sub", rs256(alterar(func(c jwt.MapClaims) { delete(c, "sub") })), nonceTeste},
+		{"alg none", p.assinar(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, p.claimsValidas()), nonceTeste},
+		// Confusão de algoritmo: HMAC com a chave pública como segredo
+		{"alg HS256", p.assinar(t, jwt.SigningMethodHS256, publicaDER, p.claimsValidas()), nonceTeste},
+		{"assinado por outra chave", p.assinar(t, jwt.SigningMethodRS256, outraChave, p.claimsValidas()), nonceTeste},
+		{"malformado", "nao.e.jwt", nonceTeste},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			if _, err := provedor.ValidarIDToken(c.token, c.nonce); !errors.Is(err, ErrIDTokenInvalido) {
+				t.Fatalf("ValidarIDToken = %v, esperado ErrIDTokenInvalido", err)
+			}
+		})
+	}
+}
+
+func TestValidarIDTokenKidDesconhecido(t *testing.T) {
+	p := novoProvedorTeste(t)
+	provedor := p.cliente()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, p.claimsValidas())
+	token.Header["kid"] = "chave-2"
+	assinado, err := token.SignedString(p.chave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provedor.ValidarIDToken(assinado, nonceTeste); !errors.Is(err, ErrIDTokenInvalido) {
+		t.Fatalf("ValidarIDToken = %v, esperado ErrIDTokenInvalido", err)
+	}
+}
@@ -0,0 +1,264 @@
+// Package oidc implementa a parte do cliente (relying party) do OpenID
+// Connect usada no login único: descoberta, autorização com PKCE, troca do
+// código e validação do ID token.
+package oidc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sufixoDescoberta é o caminho do documento de descoberta (OIDC Discovery, 4)
+const sufixoDescoberta = "/.well-known/openid-configuration"
+
+const (
+	// validadeMetadados é por quanto tempo a descoberta e as chaves são reaproveitadas
+	validadeMetadados = time.Hour
+	// intervaloRecargaChaves limita as buscas de chaves provocadas por kid desconhecido
+	intervaloRecargaChaves = time.Minute
+	// tamanhoMaximoResposta limita a memória usada por uma resposta do provedor
+	tamanhoMaximoResposta = 1 << 20
+)
+
+// ErroProvedor é um erro OAuth 2.0 devolvido pelo provedor (RFC 6749, 5.2)
+type ErroProvedor struct {
+	Codigo    string `json:"error"`
+	Descricao string `json:"error_description"`
+}
+
+func (e *ErroProvedor) Error() string {
+	if e.Descricao == "" {
+		return "oidc: " + e.Codigo
+	}
+	return fmt.Sprintf("oidc: %s: %s", e.Codigo, e.Descricao)
+}
+
+// Opcoes configura o cliente registrado no provedor
+type Opcoes struct {
+	URLDescoberta  string
+	ClienteID      string
+	ClienteSegredo string
+	URLRetorno     string
+	Escopos        []string
+	Timeout        time.Duration
+}
+
+// Metadados são os campos usados do documento de descoberta
+type Metadados struct {
+	Emissor             string `json:"issuer"`
+	EndpointAutorizacao string `json:"authorization_endpoint"`
+	EndpointToken       string `json:"token_endpoint"`
+	EndpointInformacoes string `json:"userinfo_endpoint"`
+	URIChaves           string `json:"jwks_uri"`
+}
+
+// Provedor é um provedor OpenID Connect. Os metadados e as chaves são lidos
+// no primeiro uso e mantidos em cache.
+type Provedor struct {
+	opcoes Opcoes
+	http   *http.Client
+
+	mu          sync.Mutex
+	metadados   *Metadados
+	metadadosEm time.Time
+	chaves      []chavePublica
+	chavesEm    time.Time
+}
+
+// Novo cria o provedor sem acessá-lo
+func Novo(opcoes Opcoes) *Provedor {
+	timeout := opcoes.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	return &Provedor{opcoes: opcoes, http: &http.Client{Timeout: timeout}}
+}
+
+// Autorizacao é o início de um login: o navegador vai para URL e os demais
+// campos são guardados até o retorno
+type Autorizacao struct {
+	URL         string
+	Estado      string
+	Verificador string
+	Nonce       string
+}
+
+// IniciarAutorizacao monta o pedido de autorização com estado, nonce e o
+// desafio PKCE S256 (RFC 7636)
+func (p *Provedor) IniciarAutorizacao() (*Autorizacao, error) {
+	metadados, err := p.Metadados()
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Autorizacao{
+		Estado:      aleatorio(32),
+		Verificador: aleatorio(32),
+		Nonce:       aleatorio(32),
+	}
+	desafio := sha256.Sum256([]byte(a.Verificador))
+
+	u, err := url.Parse(metadados.EndpointAutorizacao)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: authorization_endpoint inválido: %w", err)
+	}
+	parametros := u.Query()
+	parametros.Set("response_type", "code")
+	parametros.Set("client_id", p.opcoes.ClienteID)
+	parametros.Set("redirect_uri", p.opcoes.URLRetorno)
+	parametros.Set("scope", strings.Join(p.opcoes.Escopos, " "))
+	parametros.Set("state", a.Estado)
+	parametros.Set("nonce", a.Nonce)
+	parametros.Set("code_challenge", base64.RawURLEncoding.EncodeToString(desafio[:]))
+	parametros.Set("code_challenge_method", "S256")
+	u.RawQuery = parametros.Encode()
+	a.URL = u.String()
+	return a, nil
+}
+
+// RespostaToken é a resposta do token endpoint
+type RespostaToken struct {
+	AccessToken string `json:"access_token"`
+	TipoToken   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+}
+
+// TrocarCodigo troca o código de autorização pelos tokens, provando a posse
+// do verificador PKCE
+func (p *Provedor) TrocarCodigo(codigo, verificador string) (*RespostaToken, error) {
+	metadados, err := p.Metadados()
+	if err != nil {
+		return nil, err
+	}
+
+	formulario := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {codigo},
+		"redirect_uri":  {p.opcoes.URLRetorno},
+		"code_verifier": {verificador},
+	}
+	if p.opcoes.ClienteSegredo == "" {
+		// Cliente público: só o PKCE prova que o código é deste login
+		formulario.Set("client_id", p.opcoes.ClienteID)
+	}
+	req, err := http.NewRequest(http.MethodPost, metadados.EndpointToken, strings.NewReader(formulario.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.opcoes.ClienteSegredo != "" {
+		// client_secret_basic: id e segredo codificados como formulário (RFC 6749, 2.3.1)
+		req.SetBasicAuth(url.QueryEscape(p.opcoes.ClienteID), url.QueryEscape(p.opcoes.ClienteSegredo))
+	}
+
+	var resposta RespostaToken
+	if err := p.executar(req, &resposta); err != nil {
+		return nil, err
+	}
+	if resposta.IDToken == "" {
+		return nil, errors.New("oidc: resposta sem id_token; confira se o escopo openid foi pedido")
+	}
+	return &resposta, nil
+}
+
+// InformacoesUsuario consulta o userinfo endpoint com o access token
+func (p *Provedor) InformacoesUsuario(accessToken string) (Claims, error) {
+	metadados, err := p.Metadados()
+	if err != nil {
+		return nil, err
+	}
+	if metadados.EndpointInformacoes == "" {
+		return nil, errors.New("oidc: provedor sem userinfo_endpoint")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, metadados.EndpointInformacoes, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	var claims Claims
+	if err := p.executar(req, &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Metadados retorna o documento de descoberta, relido a cada hora
+func (p *Provedor) Metadados() (*Metadados, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.metadadosLocked()
+}
+
+func (p *Provedor) metadadosLocked() (*Metadados, error) {
+	if p.metadados != nil && time.Since(p.metadadosEm) < validadeMetadados {
+		return p.metadados, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, p.opcoes.URLDescoberta, nil)
+	if err != nil {
+		return nil, err
+	}
+	var m Metadados
+	if err := p.executar(req, &m); err != nil {
+		return nil, fmt.Errorf("oidc: descoberta: %w", err)
+	}
+	if m.Emissor == "" || m.EndpointAutorizacao == "" || m.EndpointToken == "" || m.URIChaves == "" {
+		return nil, errors.New("oidc: documento de descoberta incompleto")
+	}
+	// O emissor declarado deve ser o dono do documento (OIDC Discovery, 4.3)
+	if strings.TrimSuffix(m.Emissor, "/") != strings.TrimSuffix(strings.TrimSuffix(p.opcoes.URLDescoberta, sufixoDescoberta), "/") {
+		return nil, fmt.Errorf("oidc: emissor %q não corresponde à URL de descoberta", m.Emissor)
+	}
+
+	p.metadados, p.metadadosEm = &m, time.Now()
+	return p.metadados, nil
+}
+
+// executar envia a requisição e decodifica a resposta JSON, convertendo
+// respostas de erro em ErroProvedor
+func (p *Provedor) executar(req *http.Request, destino interface{}) error {
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	corpo, err := io.ReadAll(io.LimitReader(resp.Body, tamanhoMaximoResposta))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var erroProvedor ErroProvedor
+		if json.Unmarshal(corpo, &erroProvedor) == nil && erroProvedor.Codigo != "" {
+			return &erroProvedor
+		}
+		return fmt.Errorf("oidc: %s respondeu %d", req.URL.Redacted(), resp.StatusCode)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(corpo))
+	decoder.UseNumber()
+	return decoder.Decode(destino)
+}
+
+func aleatorio(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,436 @@
+// Package qrcode gera QR codes (ISO/IEC 18004) em modo byte com correção de
+// erros nível M, suficiente para URIs de provisionamento como otpauth://.
+// Só as versões 1 a 10 (até 213 bytes) são suportadas.
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrTextoLongo indica que o texto não cabe na versão 10
+var ErrTextoLongo = errors.New("texto longo demais para o QR code")
+
+// versao descreve a capacidade de uma versão no nível M
+type versao struct {
+	codewords      int   // total de codewords (dados + correção)
+	blocos         int   // quantidade de blocos de correção
+	correcaoBloco  int   // codewords de correção por bloco
+	alinhamento    []int // posições dos padrões de alinhamento
+	bitsRemanentes int
+}
+
+var versoes = []versao{
+	1:  {26, 1, 10, nil, 0},
+	2:  {44, 1, 16, []int{6, 18}, 7},
+	3:  {70, 1, 26, []int{6, 22}, 7},
+	4:  {100, 2, 18, []int{6, 26}, 7},
+	5:  {134, 2, 24, []int{6, 30}, 7},
+	6:  {172, 4, 16, []int{6, 34}, 7},
+	7:  {196, 4, 18, []int{6, 22, 38}, 0},
+	8:  {242, 4, 22, []int{6, 24, 42}, 0},
+	9:  {292, 5, 22, []int{6, 26, 46}, 0},
+	10: {346, 5, 26, []int{6, 28, 50}, 0},
+}
+
+// Codigo é a matriz de módulos do QR code; true é escuro
+type Codigo struct {
+	Tamanho int
+	modulos [][]bool
+	funcao  [][]bool
+}
+
+// Escuro indica se o módulo da coluna x e linha y é escuro
+func (c *Codigo) Escuro(x, y int) bool {
+	return c.modulos[y][x]
+}
+
+// Codificar gera o QR code do texto na menor versão em que ele cabe
+func Codificar(texto string) (*Codigo, error) {
+	dados := []byte(texto)
+	for v := 1; v < len(versoes); v++ {
+		if len(dados) <= capacidade(v) {
+			return montar(v, dados), nil
+		}
+	}
+	return nil, ErrTextoLongo
+}
+
+// capacidade retorna quantos bytes cabem na versão, descontados o modo e o contador
+func capacidade(v int) int {
+	return versoes[v].codewords - versoes[v].blocos*versoes[v].correcaoBloco - 1 - bitsContador(v)/8
+}
+
+func bitsContador(v int) int {
+	if v < 10 {
+		return 8
+	}
+	return 16
+}
+
+func montar(v int, dados []byte) *Codigo {
+	tamanho := v*4 + 17
+	c := &Codigo{Tamanho: tamanho, modulos: matriz(tamanho), funcao: matriz(tamanho)}
+	c.desenharPadroes(v)
+	c.desenharCodewords(intercalar(v, codificarDados(v, dados)))
+
+	// Escolhe a máscara de menor penalidade
+	melhor, menorPenalidade := 0, -1
+	for mascara := 0; mascara < 8; mascara++ {
+		c.aplicarMascara(mascara)
+		c.desenharFormato(mascara)
+		if p := c.penalidade(); menorPenalidade < 0 || p < menorPenalidade {
+			melhor, menorPenalidade = mascara, p
+		}
+		c.aplicarMascara(mascara)
+	}
+	c.aplicarMascara(melhor)
+	c.desenharFormato(melhor)
+	return c
+}
+
+func matriz(tamanho int) [][]bool {
+	m := make([][]bool, tamanho)
+	for i := range m {
+		m[i] = make([]bool, tamanho)
+	}
+	return m
+}
+
+// codificarDados monta os codewords de dados: modo byte, contador, bytes,
+// terminador e preenchimento
+func codificarDados(v int, dados []byte) []byte {
+	var bits []bool
+	anexar := func(valor, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, (valor>>i)&1 == 1)
+		}
+	}
+	anexar(0x4, 4)
+	anexar(len(dados), bitsContador(v))
+	for _, b := range dados {
+		anexar(int(b), 8)
+	}
+
+	totalBits := (versoes[v].codewords - versoes[v].blocos*versoes[v].correcaoBloco) * 8
+	for i := 0; i < 4 && len(bits) < totalBits; i++ {
+		bits = append(bits, false)
+	}
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+	for preenchimento := 0xEC; len(bits) < totalBits; preenchimento ^= 0xEC ^ 0x11 {
+		anexar(preenchimento, 8)
+	}
+
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return codewords
+}
+
+// intercalar divide os dados em blocos, calcula a correção Reed-Solomon de
+// cada um e intercala os codewords na ordem de leitura
+func intercalar(v int, dados []byte) []byte {
+	ver := versoes[v]
+	curtos := ver.blocos - ver.codewords%ver.blocos
+	tamanhoCurto := ver.codewords / ver.blocos
+	divisor := divisorReedSolomon(ver.correcaoBloco)
+
+	blocos := make([][]byte, ver.blocos)
+	k := 0
+	for i := range blocos {
+		n := tamanhoCurto - ver.correcaoBloco
+		if i >= curtos {
+			n++
+		}
+		bloco := append([]byte(nil), dados[k:k+n]...)
+		k += n
+		correcao := restoReedSolomon(bloco, divisor)
+		if i < curtos {
+			bloco = append(bloco, 0)
+		}
+		blocos[i] = append(bloco, correcao...)
+	}
+
+	resultado := make([]byte, 0, ver.codewords)
+	for i := range blocos[0] {
+		for j, bloco := range blocos {
+			// Os blocos curtos não têm o último codeword de dados
+			if i != tamanhoCurto-ver.correcaoBloco || j >= curtos {
+				resultado = append(resultado, bloco[i])
+			}
+		}
+	}
+	return resultado
+}
+
+func divisorReedSolomon(grau int) []byte {
+	divisor := make([]byte, grau)
+	divisor[grau-1] = 1
+	raiz := byte(1)
+	for i := 0; i < grau; i++ {
+		for j := range divisor {
+			divisor[j] = multiplicarGF(divisor[j], raiz)
+			if j+1 < grau {
+				divisor[j] ^= divisor[j+1]
+			}
+		}
+		raiz = multiplicarGF(raiz, 0x02)
+	}
+	return divisor
+}
+
+func restoReedSolomon(dados, divisor []byte) []byte {
+	resto := make([]byte, len(divisor))
+	for _, b := range dados {
+		fator := b ^ resto[0]
+		copy(resto, resto[1:])
+		resto[len(resto)-1] = 0
+		for i := range resto {
+			resto[i] ^= multiplicarGF(divisor[i], fator)
+		}
+	}
+	return resto
+}
+
+// multiplicarGF multiplica em GF(2^8) com o polinômio 0x11D
+func multiplicarGF(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+func (c *Codigo) definir(x, y int, escuro bool) {
+	c.modulos[y][x] = escuro
+	c.funcao[y][x] = true
+}
+
+// desenharPadroes desenha os padrões fixos e reserva as áreas de formato e versão
+func (c *Codigo) desenharPadroes(v int) {
+	for i := 0; i < c.Tamanho; i++ {
+		c.definir(6, i, i%2 == 0)
+		c.definir(i, 6, i%2 == 0)
+	}
+
+	c.desenharLocalizador(3, 3)
+	c.desenharLocalizador(c.Tamanho-4, 3)
+	c.desenharLocalizador(3, c.Tamanho-4)
+
+	posicoes := versoes[v].alinhamento
+	ultimo := len(posicoes) - 1
+	for i, y := range posicoes {
+		for j, x := range posicoes {
+			// Os cantos já têm localizadores
+			if (i == 0 && j == 0) || (i == 0 && j == ultimo) || (i == ultimo && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.definir(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	c.desenharFormato(0)
+	c.desenharVersao(v)
+}
+
+func (c *Codigo) desenharLocalizador(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || y < 0 || x >= c.Tamanho || y >= c.Tamanho {
+				continue
+			}
+			distancia := max(abs(dx), abs(dy))
+			c.definir(x, y, distancia != 2 && distancia != 4)
+		}
+	}
+}
+
+// desenharFormato grava o nível de correção e a máscara, com BCH(15,5)
+func (c *Codigo) desenharFormato(mascara int) {
+	// O nível M é codificado como 00
+	dados := mascara
+	resto := dados
+	for i := 0; i < 10; i++ {
+		resto = (resto << 1) ^ ((resto >> 9) * 0x537)
+	}
+	bits := (dados<<10 | resto) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		c.definir(8, i, bit(i))
+	}
+	c.definir(8, 7, bit(6))
+	c.definir(8, 8, bit(7))
+	c.definir(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.definir(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.definir(c.Tamanho-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.definir(8, c.Tamanho-15+i, bit(i))
+	}
+	c.definir(8, c.Tamanho-8, true)
+}
+
+// desenharVersao grava a versão, com BCH(18,6), a partir da versão 7
+func (c *Codigo) desenharVersao(v int) {
+	if v < 7 {
+		return
+	}
+	resto := v
+	for i := 0; i < 12; i++ {
+		resto = (resto << 1) ^ ((resto >> 11) * 0x1F25)
+	}
+	bits := v<<12 | resto
+	for i := 0; i < 18; i++ {
+		escuro := (bits>>i)&1 == 1
+		a, b := c.Tamanho-11+i%3, i/3
+		c.definir(a, b, escuro)
+		c.definir(b, a, escuro)
+	}
+}
+
+// desenharCodewords percorre a matriz em zigue-zague, de duas em duas colunas
+// a partir da direita, preenchendo os módulos livres
+func (c *Codigo) desenharCodewords(codewords []byte) {
+	i := 0
+	for direita := c.Tamanho - 1; direita >= 1; direita -= 2 {
+		if direita == 6 {
+			direita = 5
+		}
+		for vertical := 0; vertical < c.Tamanho; vertical++ {
+			for j := 0; j < 2; j++ {
+				x := direita - j
+				y := vertical
+				if (direita+1)&2 == 0 {
+					y = c.Tamanho - 1 - vertical
+				}
+				if !c.funcao[y][x] && i < len(codewords)*8 {
+					c.modulos[y][x] = (codewords[i>>3]>>(7-i&7))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// aplicarMascara inverte os módulos de dados conforme a máscara; aplicar de
+// novo desfaz
+func (c *Codigo) aplicarMascara(mascara int) {
+	for y := 0; y < c.Tamanho; y++ {
+		for x := 0; x < c.Tamanho; x++ {
+			var inverter bool
+			switch mascara {
+			case 0:
+				inverter = (x+y)%2 == 0
+			case 1:
+				inverter = y%2 == 0
+			case 2:
+				inverter = x%3 == 0
+			case 3:
+				inverter = (x+y)%3 == 0
+			case 4:
+				inverter = (x/3+y/2)%2 == 0
+			case 5:
+				inverter = x*y%2+x*y%3 == 0
+			case 6:
+				inverter = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				inverter = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if inverter && !c.funcao[y][x] {
+				c.modulos[y][x] = !c.modulos[y][x]
+			}
+		}
+	}
+}
+
+// penalidade avalia a máscara por sequências de mesma cor, blocos 2x2 e
+// proporção de módulos escuros
+func (c *Codigo) penalidade() int {
+	total, escuros := 0, 0
+	for y := 0; y < c.Tamanho; y++ {
+		linha, coluna := 1, 1
+		for x := 0; x < c.Tamanho; x++ {
+			if c.modulos[y][x] {
+				escuros++
+			}
+			if x == 0 {
+				continue
+			}
+			if c.modulos[y][x] == c.modulos[y][x-1] {
+				linha++
+				if linha == 5 {
+					total += 3
+				} else if linha > 5 {
+					total++
+				}
+			} else {
+				linha = 1
+			}
+			if c.modulos[x][y] == c.modulos[x-1][y] {
+				coluna++
+				if coluna == 5 {
+					total += 3
+				} else if coluna > 5 {
+					total++
+				}
+			} else {
+				coluna = 1
+			}
+			if y > 0 {
+				cor := c.modulos[y][x]
+				if cor == c.modulos[y][x-1] && cor == c.modulos[y-1][x] && cor == c.modulos[y-1][x-1] {
+					total += 3
+				}
+			}
+		}
+	}
+
+	modulos := c.Tamanho * c.Tamanho
+	desvio := abs(escuros*20-modulos*10)/modulos - 1
+	if desvio > 0 {
+		total += desvio * 10
+	}
+	return total
+}
+
+// SVG desenha o QR code com a margem de quatro módulos exigida pela norma
+func (c *Codigo) SVG() string {
+	const margem = 4
+	lado := c.Tamanho + 2*margem
+
+	var caminho strings.Builder
+	for y := 0; y < c.Tamanho; y++ {
+		for x := 0; x < c.Tamanho; x++ {
+			if c.modulos[y][x] {
+				fmt.Fprintf(&caminho, "M%d,%dh1v1h-1z", x+margem, y+margem)
+			}
+		}
+	}
+
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="100%%" height="100%%" fill="#fff"/><path d="%s" fill="#000"/></svg>`, lado, lado, caminho.String())
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,204 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// formatosNivelM são as sequências de formato do nível M para as máscaras 0 a
+// 7, já com a máscara 0x5412 (ISO/IEC 18004, tabela C.1)
+var formatosNivelM = []int{0x5412, 0x5125, 0x5E7C, 0x5B4B, 0x45F9, 0x40CE, 0x4F97, 0x4AA0}
+
+// infoVersao são as sequências BCH(18,6) das versões 7 a 10 (tabela D.1)
+var infoVersao = map[int]int{7: 0x07C94, 8: 0x085BC, 9: 0x09A99, 10: 0x0A4D3}
+
+func TestRestoReedSolomon(t *testing.T) {
+	// Exemplo do anexo I da norma: "01234567" na versão 1-M
+	dados := []byte{0x10, 0x20, 0x0C, 0x56, 0x61, 0x80, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11}
+	espera := []byte{0xA5, 0x24, 0xD4, 0xC1, 0xED, 0x36, 0xC7, 0x87, 0x2C, 0x55}
+	if got := restoReedSolomon(dados, divisorReedSolomon(10)); !bytes.Equal(got, espera) {
+		t.Fatalf("correção = % X, esperado % X", got, espera)
+	}
+}
+
+func TestCodificarDados(t *testing.T) {
+	// Modo byte (0100), contador 5, "hello", terminador e preenchimento EC 11
+	espera := []byte{0x40, 0x56, 0x86, 0x56, 0xC6, 0xC6, 0xF0, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC}
+	if got := codificarDados(1, []byte("hello")); !bytes.Equal(got, espera) {
+		t.Fatalf("codewords = % X, esperado % X", got, espera)
+	}
+}
+
+func TestCodificarVersao(t *testing.T) {
+	casos := []struct {
+		nome   string
+		bytes  int
+		versao int
+	}{
+		{"vazio", 0, 1},
+		{"limite da versão 1", 14, 1},
+		{"início da versão 2", 15, 2},
+		{"URI de provisionamento", 120, 7},
+		{"limite da versão 9", 180, 9},
+		{"limite da versão 10", 213, 10},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			qr, err := Codificar(strings.Repeat("a", c.bytes))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if qr.Tamanho != c.versao*4+17 {
+				t.Fatalf("tamanho %d, esperada a versão %d (%d)", qr.Tamanho, c.versao, c.versao*4+17)
+			}
+		})
+	}
+
+	if _, err := Codificar(strings.Repeat("a", 214)); !errors.Is(err, ErrTextoLongo) {
+		t.Fatalf("Codificar com 214 bytes = %v, esperado ErrTextoLongo", err)
+	}
+}
+
+// TestCodificarLeitura lê o símbolo gerado como um leitor faria: localizadores,
+// formato, versão e, desfeita a máscara, os codewords de dados e correção
+func TestCodificarLeitura(t *testing.T) {
+	textos := []string{
+		"hello",
+		"otpauth://totp/ConsultaPix:agente%40exemplo.local?algorithm=SHA1&digits=6&issuer=ConsultaPix&period=30&secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP",
+		strings.Repeat("consultapix ", 17),
+	}
+	for _, texto := range textos {
+		qr, err := Codificar(texto)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := (qr.Tamanho - 17) / 4
+
+		conferirLocalizador(t, qr, 0, 0)
+		conferirLocalizador(t, qr, qr.Tamanho-7, 0)
+		conferirLocalizador(t, qr, 0, qr.Tamanho-7)
+		if !qr.Escuro(8, qr.Tamanho-8) {
+			t.Error("módulo escuro fixo ausente")
+		}
+
+		mascara := lerFormato(t, qr)
+		if v >= 7 {
+			if got := lerVersao(qr); got != infoVersao[v] {
+				t.Errorf("versão %d gravada como %05X, esperado %05X", v, got, infoVersao[v])
+			}
+		}
+
+		qr.aplicarMascara(mascara)
+		lidos := lerCodewords(qr)
+		qr.aplicarMascara(mascara)
+
+		dados := codificarDados(v, []byte(texto))
+		if espera := intercalar(v, dados); !bytes.Equal(lidos, espera) {
+			t.Fatalf("versão %d: codewords lidos diferem dos gravados", v)
+		}
+		if v == 1 {
+			// Um bloco só: dados seguidos da correção
+			espera := append(append([]byte(nil), dados...), restoReedSolomon(dados, divisorReedSolomon(10))...)
+			if !bytes.Equal(lidos, espera) {
+				t.Fatalf("versão 1: % X, esperado % X", lidos, espera)
+			}
+		}
+	}
+}
+
+func conferirLocalizador(t *testing.T, qr *Codigo, x0, y0 int) {
+	t.Helper()
+	for y := 0; y < 7; y++ {
+		for x := 0; x < 7; x++ {
+			borda := x == 0 || y == 0 || x == 6 || y == 6
+			centro := x >= 2 && x <= 4 && y >= 2 && y <= 4
+			if qr.Escuro(x0+x, y0+y) != (borda || centro) {
+				t.Fatalf("localizador em (%d,%d) incorreto no módulo (%d,%d)", x0, y0, x, y)
+			}
+		}
+	}
+}
+
+// lerFormato confere que as duas cópias do formato trazem a mesma sequência
+// da tabela do nível M e devolve a máscara
+func lerFormato(t *testing.T, qr *Codigo) int {
+	t.Helper()
+	var perto, longe int
+	posicoesPerto := [15][2]int{
+		{8, 0}, {8, 1}, {8, 2}, {8, 3}, {8, 4}, {8, 5}, {8, 7}, {8, 8},
+		{7, 8}, {5, 8}, {4, 8}, {3, 8}, {2, 8}, {1, 8}, {0, 8},
+	}
+	for i, p := range posicoesPerto {
+		if qr.Escuro(p[0], p[1]) {
+			perto |= 1 << i
+		}
+	}
+	for i := 0; i < 8; i++ {
+		if qr.Escuro(qr.Tamanho-1-i, 8) {
+			longe |= 1 << i
+		}
+	}
+	for i := 8; i < 15; i++ {
+		if qr.Escuro(8, qr.Tamanho-15+i) {
+			longe |= 1 << i
+		}
+	}
+
+	if perto != longe {
+		t.Fatalf("cópias do formato diferem: %015b e %015b", perto, longe)
+	}
+	for mascara, formato := range formatosNivelM {
+		if formato == perto {
+			return mascara
+		}
+	}
+	t.Fatalf("formato %015b não é do nível M", perto)
+	return 0
+}
+
+func lerVersao(qr *Codigo) int {
+	var bits int
+	for i := 0; i < 18; i++ {
+		if qr.Escuro(qr.Tamanho-11+i%3, i/3) {
+			bits |= 1 << i
+		}
+	}
+	return bits
+}
+
+// lerCodewords percorre os módulos de dados na ordem de leitura da norma
+func lerCodewords(qr *Codigo) []byte {
+	var codewords []byte
+	i := 0
+	for direita := qr.Tamanho - 1; direita >= 1; direita -= 2 {
+		if direita == 6 {
+			direita = 5
+		}
+		subindo := ((qr.Tamanho-1-direita)/2)%2 == 0
+		if direita < 6 {
+			subindo = ((qr.Tamanho-2-direita)/2)%2 == 0
+		}
+		for k := 0; k < qr.Tamanho; k++ {
+			y := k
+			if subindo {
+				y = qr.Tamanho - 1 - k
+			}
+			for _, x := range []int{direita, direita - 1} {
+				if qr.funcao[y][x] {
+					continue
+				}
+				if i%8 == 0 {
+					codewords = append(codewords, 0)
+				}
+				if qr.Escuro(x, y) {
+					codewords[len(codewords)-1] |= 1 << (7 - i%8)
+				}
+				i++
+			}
+		}
+	}
+	// Os bits remanescentes da versão não formam um codeword
+	return codewords[:i/8]
+}
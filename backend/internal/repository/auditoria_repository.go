@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tassyosilva/consultapix/internal/database"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+// Ações registradas na auditoria
+const (
+	AcaoResultadoReaproveitado = "resultado_reaproveitado"
+	AcaoConsultaForcada        = "consulta_forcada"
+
+	AcaoDoisFatoresAtivado          = "dois_fatores_ativado"
+	AcaoDoisFatoresDesativado       = "dois_fatores_desativado"
+	AcaoDoisFatoresResetado         = "dois_fatores_resetado"
+	AcaoCodigoRecuperacaoUsado      = "codigo_recuperacao_usado"
+	AcaoCodigosRecuperacaoGerados   = "codigos_recuperacao_gerados"
+	AcaoPoliticaDoisFatoresAlterada = "politica_dois_fatores_alterada"
+
+	AcaoCadastroSolicitado = "cadastro_solicitado"
+	AcaoCadastroAprovado   = "cadastro_aprovado"
+	AcaoCadastroRejeitado  = "cadastro_rejeitado"
+
+	AcaoSenhaAlterada              = "senha_alterada"
+	AcaoRedefinicaoSenhaSolicitada = "redefinicao_senha_solicitada"
+	AcaoSenhaRedefinida            = "senha_redefinida"
+	AcaoLoginBloqueado             = "login_bloqueado"
+
+	AcaoSessaoEncerrada = "sessao_encerrada"
+
+	AcaoUnidadeAlterada       = "unidade_alterada"
+	AcaoAcessoUsuarioAlterado = "acesso_usuario_alterado"
+
+	AcaoUsuarioDesativado    = "usuario_desativado"
+	AcaoReativacaoSolicitada = "reativacao_solicitada"
+	AcaoUsuarioReativado     = "usuario_reativado"
+	AcaoReativacaoRejeitada  = "reativacao_rejeitada"
+)
+
+type AuditoriaRepository struct {
+	DB *sql.DB
+}
+
+func NewAuditoriaRepository() *AuditoriaRepository {
+	return &AuditoriaRepository{
+		DB: database.GetDB(),
+	}
+}
+
+// Registrar grava um evento de auditoria
+func (r *AuditoriaRepository) Registrar(e *models.EventoAuditoria) error {
+	detalhes := e.Detalhes
+	if detalhes == nil {
+		detalhes = map[string]interface{}{}
+	}
+	detalhesJSON, err := json.Marshal(detalhes)
+	if err != nil {
+		return err
+	}
+
+	return r.DB.QueryRow(`
+		INSERT INTO auditoria (cpf_usuario, lotacao, acao, alvo, detalhes)
+		VALUES (NULLIF($1, ''), NULLIF($2, ''), $3, NULLIF($4, ''), $5)
+		RETURNING id, data
+	`, e.CPFUsuario, e.Lotacao, e.Acao, e.Alvo, detalhesJSON).Scan(&e.ID, &e.Data)
+}
+
+// ListarEventos lista a trilha de auditoria, paginada por cursor. Usa de
+// FiltroHistorico o período, o CPF do responsável (usuário da ação) e o alvo.
+func (r *AuditoriaRepository) ListarEventos(acao string, filtro FiltroHistorico) ([]models.EventoAuditoria, string, error) {
+	c := &consultaSQL{}
+	if acao != "" {
+		c.onde("a.acao = " + c.arg(acao))
+	}
+	if filtro.CPFResponsavel != "" {
+		c.onde("a.cpf_usuario = " + c.arg(filtro.CPFResponsavel))
+	}
+	if filtro.Alvo != "" {
+		c.onde("a.alvo = " + c.arg(filtro.Alvo))
+	}
+	if filtro.DataInicio != nil {
+		c.onde("a.data >= " + c.arg(*filtro.DataInicio))
+	}
+	if filtro.DataFim != nil {
+		c.onde("a.data < " + c.arg(*filtro.DataFim))
+	}
+
+	ordem, err := c.paginar(filtro, "a.data", "a.id")
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT a.id, a.data, COALESCE(a.cpf_usuario, ''), COALESCE(a.lotacao, ''), a.acao,
+			COALESCE(a.alvo, ''), a.detalhes
+		FROM auditoria a
+		%s
+		%s
+	`, c.where(), ordem)
+	rows, err := r.DB.Query(query, c.args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	eventos := make([]models.EventoAuditoria, 0)
+	for rows.Next() {
+		var e models.EventoAuditoria
+		var detalhesJSON []byte
+		if err := rows.Scan(&e.ID, &e.Data, &e.CPFUsuario, &e.Lotacao, &e.Acao, &e.Alvo, &detalhesJSON); err != nil {
+			return nil, "", err
+		}
+		if err := json.Unmarshal(detalhesJSON, &e.Detalhes); err != nil {
+			return nil, "", err
+		}
+		eventos = append(eventos, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var proximoCursor string
+	if len(eventos) > filtro.limite() {
+		eventos = eventos[:filtro.limite()]
+		ultimo := eventos[len(eventos)-1]
+		proximoCursor = codificarCursor(ultimo.Data, int(ultimo.ID))
+	}
+
+	return eventos, proximoCursor, nil
+}
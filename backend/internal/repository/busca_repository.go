@@ -0,0 +1,310 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tassyosilva/consultapix/internal/database"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+const (
+	limitePadraoBusca = 50
+	limiteMaximoBusca = 200
+)
+
+type BuscaRepository struct {
+	DB *sql.DB
+}
+
+func NewBuscaRepository() *BuscaRepository {
+	return &BuscaRepository{
+		DB: database.GetDB(),
+	}
+}
+
+// fonteBusca descreve uma tabela pesquisável. O alias x é a própria tabela e
+// req a requisição (PIX ou CCS) à qual ela pertence, usada para aplicar o escopo.
+type fonteBusca struct {
+	tipo      string
+	origem    string
+	from      string
+	nome      string
+	documento string
+	chave     string
+	agencia   string
+	conta     string
+	colunas   []string
+	// nomes deve ser idêntica à expressão dos índices tsvector da migração 0005
+	nomes string
+	// cifradas são as tabelas da fonte, por alias, cujas colunas sensíveis
+	// podem estar cifradas. Essas linhas só são encontradas pelos índices
+	// cegos de x, por igualdade de documento, chave ou conta.
+	cifradas map[string]tabelaCifrada
+	// exibicao indica, para cada campo do resultado, as colunas lidas das
+	// linhas cifradas, em ordem de preferência
+	exibicao map[string][]string
+}
+
+var fontesBusca = []fonteBusca{
+	{
+		tipo:      "chave_pix",
+		origem:    "pix",
+		from:      "chave_pix x JOIN requisicao_pix req ON req.id = x.id_requisicao",
+		nome:      "x.nome_proprietario",
+		documento: "x.cpf_cnpj",
+		chave:     "x.chave",
+		agencia:   "x.agencia",
+		conta:     "x.numero_conta",
+		colunas:   []string{"x.nome_proprietario", "x.nome_fantasia", "x.chave", "x.agencia", "x.numero_conta"},
+		nomes:     "coalesce(x.nome_proprietario, '') || ' ' || coalesce(x.nome_fantasia, '')",
+		cifradas:  map[string]tabelaCifrada{"x": tabelaChavePix},
+		exibicao:  exibicaoChavePix,
+	},
+	{
+		tipo:   "evento_chave_pix",
+		origem: "pix",
+		from: `evento_chave_pix x
+			JOIN chave_pix cp ON cp.id = x.id_chave
+			JOIN requisicao_pix req ON req.id = cp.id_requisicao`,
+		nome:      "x.nome_proprietario",
+		documento: "x.cpf_cnpj",
+		chave:     "x.chave",
+		agencia:   "x.agencia",
+		conta:     "x.numero_conta",
+		colunas:   []string{"x.nome_proprietario", "x.nome_fantasia", "x.chave", "x.agencia", "x.numero_conta"},
+		nomes:     "coalesce(x.nome_proprietario, '') || ' ' || coalesce(x.nome_fantasia, '')",
+		cifradas:  map[string]tabelaCifrada{"x": tabelaEventoChavePix},
+		exibicao:  exibicaoChavePix,
+	},
+	{
+		tipo:      "relacionamento_ccs",
+		origem:    "ccs",
+		from:      "relacionamento_ccs x JOIN requisicao_relacionamento_ccs req ON req.id = x.id_requisicao",
+		nome:      "x.nome_pessoa",
+		documento: "x.id_pessoa",
+		chave:     "NULL",
+		agencia:   "NULL",
+		conta:     "NULL",
+		colunas:   []string{"x.nome_pessoa"},
+		nomes:     "coalesce(x.nome_pessoa, '')",
+		cifradas:  map[string]tabelaCifrada{"x": tabelaRelacionamentoCCS},
+		exibicao:  map[string][]string{"nome": {"x.nome_pessoa"}, "documento": {"x.id_pessoa"}},
+	},
+	{
+		tipo:   "bem_direito_valor_ccs",
+		origem: "ccs",
+		from: `bem_direito_valor_ccs x
+			JOIN relacionamento_ccs rc ON rc.id = x.id_relacionamento
+			JOIN requisicao_relacionamento_ccs req ON req.id = rc.id_requisicao`,
+		nome:      "x.nome_pessoa",
+		documento: "rc.id_pessoa",
+		chave:     "NULL",
+		agencia:   "x.agencia",
+		conta:     "x.conta",
+		colunas:   []string{"x.nome_pessoa", "x.agencia", "x.conta"},
+		nomes:     "coalesce(x.nome_pessoa, '')",
+		cifradas:  map[string]tabelaCifrada{"x": tabelaBemDireitoValorCCS, "rc": tabelaRelacionamentoCCS},
+		exibicao: map[string][]string{
+			"nome": {"x.nome_pessoa"}, "documento": {"rc.id_pessoa"}, "agencia": {"x.agencia"}, "conta": {"x.conta"},
+		},
+	},
+	{
+		tipo:   "vinculados_bdv_ccs",
+		origem: "ccs",
+		from: `vinculados_bdv_ccs x
+			JOIN bem_direito_valor_ccs b ON b.id = x.id_bdv
+			JOIN relacionamento_ccs rc ON rc.id = b.id_relacionamento
+			JOIN requisicao_relacionamento_ccs req ON req.id = rc.id_requisicao`,
+		nome:      "COALESCE(NULLIF(x.nome_pessoa_receita, ''), x.nome_pessoa)",
+		documento: "x.id_pessoa",
+		chave:     "NULL",
+		agencia:   "b.agencia",
+		conta:     "b.conta",
+		colunas:   []string{"x.nome_pessoa", "x.nome_pessoa_receita"},
+		nomes:     "coalesce(x.nome_pessoa, '') || ' ' || coalesce(x.nome_pessoa_receita, '')",
+		cifradas:  map[string]tabelaCifrada{"x": tabelaVinculadosBDVCCS, "b": tabelaBemDireitoValorCCS},
+		exibicao: map[string][]string{
+			"nome": {"x.nome_pessoa_receita", "x.nome_pessoa"}, "documento": {"x.id_pessoa"},
+			"agencia": {"b.agencia"}, "conta": {"b.conta"},
+		},
+	},
+}
+
+var exibicaoChavePix = map[string][]string{
+	"nome": {"x.nome_proprietario"}, "documento": {"x.cpf_cnpj"}, "chave": {"x.chave"},
+	"agencia": {"x.agencia"}, "conta": {"x.numero_conta"},
+}
+
+// linhaCifradaBusca é o conteúdo cifrado de uma das tabelas da fonte
+type linhaCifradaBusca struct {
+	ID int `json:"id"`
+	models.DadosCifrados
+}
+
+// TiposBusca lista os tipos de entidade aceitos pela busca
+func TiposBusca() []string {
+	tipos := make([]string, len(fontesBusca))
+	for i, f := range fontesBusca {
+		tipos[i] = f.tipo
+	}
+	return tipos
+}
+
+// escaparLike neutraliza os curingas do ILIKE no termo informado pelo usuário
+func escaparLike(termo string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(termo)
+}
+
+// sqlFonte monta a subconsulta de uma fonte; termo, padrao e indices são os placeholders compartilhados
+func (f fonteBusca) sqlFonte(c *consultaSQL, escopo Escopo, termo, padrao, indices string) string {
+	similaridades := make([]string, len(f.colunas))
+	condicoes := make([]string, 0, len(f.colunas)*2+1)
+	for i, coluna := range f.colunas {
+		similaridades[i] = fmt.Sprintf("word_similarity(%s, %s)", termo, coluna)
+		condicoes = append(condicoes,
+			fmt.Sprintf("%s ILIKE %s", coluna, padrao),
+			fmt.Sprintf("%s <%% %s", termo, coluna),
+		)
+	}
+	tsvector := fmt.Sprintf("to_tsvector('simple', %s)", f.nomes)
+	tsquery := fmt.Sprintf("plainto_tsquery('simple', %s)", termo)
+	condicoes = append(condicoes, fmt.Sprintf("%s @@ %s", tsvector, tsquery))
+	indiceCego := fmt.Sprintf("x.indices && %s", indices)
+	condicoes = append(condicoes, indiceCego)
+
+	aliases := make([]string, 0, len(f.cifradas))
+	for alias := range f.cifradas {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	cifrados := make([]string, len(aliases))
+	for i, alias := range aliases {
+		cifrados[i] = fmt.Sprintf(`'%[1]s', CASE WHEN %[1]s.dados_cifrados IS NULL THEN NULL
+			ELSE json_build_object('id', %[1]s.id, 'chave', %[1]s.id_chave_dados, 'dados', encode(%[1]s.dados_cifrados, 'base64')) END`, alias)
+	}
+
+	return fmt.Sprintf(`
+		SELECT '%s' AS tipo, '%s' AS origem, x.id, req.id AS id_requisicao, COALESCE(req.caso, '') AS caso,
+			COALESCE(%s, '') AS nome, COALESCE(%s, '') AS documento, COALESCE(%s, '') AS chave,
+			COALESCE(%s, '') AS agencia, COALESCE(%s, '') AS conta,
+			COALESCE(GREATEST(%s), 0) + ts_rank(%s, %s) + CASE WHEN %s THEN 1 ELSE 0 END AS relevancia,
+			json_build_object(%s) AS cifrados
+		FROM %s
+		WHERE %s AND (%s)`,
+		f.tipo, f.origem, f.nome, f.documento, f.chave, f.agencia, f.conta,
+		strings.Join(similaridades, ", "), tsvector, tsquery, indiceCego, strings.Join(cifrados, ", "),
+		f.from, escopo.condicao(c, "req"), strings.Join(condicoes, " OR "),
+	)
+}
+
+// abrirResultado decifra as linhas cifradas de um resultado e preenche os
+// campos que vieram vazios
+func (f fonteBusca) abrirResultado(res *models.ResultadoBusca, cifradosJSON []byte) error {
+	var linhas map[string]*linhaCifradaBusca
+	if err := json.Unmarshal(cifradosJSON, &linhas); err != nil {
+		return err
+	}
+	valores := map[string]string{}
+	for alias, linha := range linhas {
+		if linha == nil {
+			continue
+		}
+		campos, err := decifrarCampos(f.cifradas[alias], linha.ID, &linha.DadosCifrados)
+		if err != nil {
+			return err
+		}
+		for coluna, valor := range campos {
+			valores[alias+"."+coluna] = valor
+		}
+	}
+	if len(valores) == 0 {
+		return nil
+	}
+
+	destinos := map[string]*string{
+		"nome": &res.Nome, "documento": &res.Documento, "chave": &res.Chave, "agencia": &res.Agencia, "conta": &res.Conta,
+	}
+	for campo, colunas := range f.exibicao {
+		for _, coluna := range colunas {
+			if *destinos[campo] != "" {
+				break
+			}
+			*destinos[campo] = valores[coluna]
+		}
+	}
+	return nil
+}
+
+// Buscar procura o termo nas tabelas de dados financeiros, restrito às requisições
+// visíveis no escopo, e devolve os resultados ordenados por relevância
+func (r *BuscaRepository) Buscar(escopo Escopo, termo string, tipos []string, limite int) ([]models.ResultadoBusca, error) {
+	if limite <= 0 {
+		limite = limitePadraoBusca
+	}
+	if limite > limiteMaximoBusca {
+		limite = limiteMaximoBusca
+	}
+
+	filtroTipos := make(map[string]bool)
+	for _, t := range tipos {
+		filtroTipos[t] = true
+	}
+
+	c := &consultaSQL{}
+	phTermo := c.arg(termo)
+	phPadrao := c.arg("%" + escaparLike(termo) + "%")
+	phIndices := c.arg(indicesBusca(termo)) + "::bytea[]"
+
+	subconsultas := make([]string, 0, len(fontesBusca))
+	fontes := make(map[string]fonteBusca, len(fontesBusca))
+	for _, f := range fontesBusca {
+		if len(filtroTipos) > 0 && !filtroTipos[f.tipo] {
+			continue
+		}
+		subconsultas = append(subconsultas, f.sqlFonte(c, escopo, phTermo, phPadrao, phIndices))
+		fontes[f.tipo] = f
+	}
+	if len(subconsultas) == 0 {
+		return []models.ResultadoBusca{}, nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT tipo, origem, id, id_requisicao, caso, nome, documento, chave, agencia, conta, relevancia, cifrados
+		FROM (%s) resultados
+		ORDER BY relevancia DESC, id DESC
+		LIMIT %d
+	`, strings.Join(subconsultas, "\n\t\tUNION ALL"), limite)
+
+	rows, err := r.DB.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resultados := make([]models.ResultadoBusca, 0)
+	for rows.Next() {
+		var res models.ResultadoBusca
+		var cifradosJSON []byte
+		err := rows.Scan(
+			&res.Tipo, &res.Origem, &res.ID, &res.IDRequisicao, &res.Caso, &res.Nome,
+			&res.Documento, &res.Chave, &res.Agencia, &res.Conta, &res.Relevancia, &cifradosJSON,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := fontes[res.Tipo].abrirResultado(&res, cifradosJSON); err != nil {
+			return nil, err
+		}
+		resultados = append(resultados, res)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return resultados, nil
+}
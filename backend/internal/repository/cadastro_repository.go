@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/database"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+// Situações do usuário (usuario.situacao)
+const (
+	SituacaoPendente  = "pendente"
+	SituacaoAtivo     = "ativo"
+	SituacaoRejeitado = "rejeitado"
+	SituacaoInativo   = "inativo"
+)
+
+var (
+	// ErrCadastroNaoEncontrado indica cadastro inexistente, já decidido ou de
+	// outra lotação
+	ErrCadastroNaoEncontrado = errors.New("cadastro pendente não encontrado")
+	// ErrEmailNaoVerificado indica cadastro cujo e-mail ainda não foi confirmado
+	ErrEmailNaoVerificado = errors.New("e-mail do cadastro ainda não confirmado")
+)
+
+// CadastroRepository trata os autocadastros pendentes de aprovação
+type CadastroRepository struct {
+	DB *sql.DB
+}
+
+func NewCadastroRepository() *CadastroRepository {
+	return &CadastroRepository{
+		DB: database.GetDB(),
+	}
+}
+
+// ConfirmarEmail marca o e-mail do cadastro pendente como confirmado. O
+// e-mail precisa ser o mesmo para o qual o link foi enviado.
+func (r *CadastroRepository) ConfirmarEmail(id int, email string) error {
+	resultado, err := r.DB.Exec(`
+		UPDATE usuario SET email_verificado_em = COALESCE(email_verificado_em, NOW())
+		WHERE id = $1 AND email = $2 AND situacao = $3
+	`, id, email, SituacaoPendente)
+	if err != nil {
+		return err
+	}
+	if n, _ := resultado.RowsAffected(); n == 0 {
+		return ErrCadastroNaoEncontrado
+	}
+	return nil
+}
+
+// ListarPendentes lista os cadastros pendentes da lotação, do mais antigo ao mais recente
+func (r *CadastroRepository) ListarPendentes(lotacao string) ([]models.CadastroPendente, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, nome, cpf, email, lotacao, COALESCE(matricula, ''), cadastrado_em, email_verificado_em IS NOT NULL
+		FROM usuario
+		WHERE situacao = $1 AND lotacao = $2
+		ORDER BY cadastrado_em
+	`, SituacaoPendente, lotacao)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cadastros := []models.CadastroPendente{}
+	for rows.Next() {
+		var c models.CadastroPendente
+		if err := rows.Scan(&c.ID, &c.Nome, &c.CPF, &c.Email, &c.Lotacao, &c.Matricula, &c.CadastradoEm, &c.EmailVerificado); err != nil {
+			return nil, err
+		}
+		cadastros = append(cadastros, c)
+	}
+	return cadastros, rows.Err()
+}
+
+// Decidir aprova ou rejeita o cadastro pendente da lotação do administrador.
+// A aprovação exige o e-mail confirmado.
+func (r *CadastroRepository) Decidir(id int, lotacao, cpfAdmin string, aprovar bool) (*models.Usuario, error) {
+	var emailVerificado bool
+	err := r.DB.QueryRow(`
+		SELECT email_verificado_em IS NOT NULL FROM usuario
+		WHERE id = $1 AND situacao = $2 AND lotacao = $3
+	`, id, SituacaoPendente, lotacao).Scan(&emailVerificado)
+	if err == sql.ErrNoRows {
+		return nil, ErrCadastroNaoEncontrado
+	}
+	if err != nil {
+		return nil, err
+	}
+	if aprovar && !emailVerificado {
+		return nil, ErrEmailNaoVerificado
+	}
+
+	situacao := SituacaoRejeitado
+	if aprovar {
+		situacao = SituacaoAtivo
+	}
+	user := models.Usuario{ID: id, Situacao: situacao}
+	err = r.DB.QueryRow(`
+		UPDATE usuario SET situacao = $4, decidido_por = $3, decidido_em = NOW()
+		WHERE id = $1 AND situacao = $5 AND lotacao = $2
+		RETURNING nome, cpf, email, COALESCE(lotacao, '')
+	`, id, lotacao, cpfAdmin, situacao, SituacaoPendente).Scan(&user.Nome, &user.CPF, &user.Email, &user.Lotacao)
+	if err == sql.ErrNoRows {
+		return nil, ErrCadastroNaoEncontrado
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// RemoverEncerrados apaga os cadastros rejeitados e os pendentes feitos antes
+// de limite, devolvendo quantos foram removidos
+func (r *CadastroRepository) RemoverEncerrados(limite time.Time) (int64, error) {
+	resultado, err := r.DB.Exec(`
+		DELETE FROM usuario
+		WHERE situacao = $1 OR (situacao = $2 AND cadastrado_em < $3)
+	`, SituacaoRejeitado, SituacaoPendente, limite)
+	if err != nil {
+		return 0, err
+	}
+	return resultado.RowsAffected()
+}
@@ -2,8 +2,9 @@ package repository
 
 import (
 	"database/sql"
-	_"encoding/json"
-	_"fmt"
+	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/tassyosilva/consultapix/internal/database"
 	"github.com/tassyosilva/consultapix/internal/database/models"
@@ -27,55 +28,52 @@ func (r *CCSRepository) CriarRequisicaoRelacionamentoCCS(req *models.RequisicaoR
 	}
 	defer tx.Rollback()
 
-	// Inserir requisição de relacionamento CCS
+	pessoaID, err := vincularPessoa(tx, documentoRequisicaoCCS(req), req.Nome, req.DataRequisicao)
+	if err != nil {
+		return 0, err
+	}
+
+	// Inserir requisição de relacionamento CCS; os documentos e o nome são
+	// gravados depois, cifrados com o ID da linha
 	insertQuery := `
 		INSERT INTO requisicao_relacionamento_ccs (
-			data_requisicao, data_inicio_consulta, data_fim_consulta, cpf_cnpj_consulta,
+			data_requisicao, data_inicio_consulta, data_fim_consulta,
 			numero_processo, motivo_busca, cpf_responsavel, lotacao, caso,
-			numero_requisicao, cpf_cnpj, tipo_pessoa, nome, autorizado,
+			numero_requisicao, tipo_pessoa, autorizado,
 			cpf_autorizacao, nome_autorizacao, data_hora_autorizacao, token_autorizacao,
-			status, detalhamento
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+			status, detalhamento, pessoa_id, id_credencial_bacen, usuario_bacen, id_unidade
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
 		RETURNING id
 	`
 	var id int
 	err = tx.QueryRow(
 		insertQuery,
-		req.DataRequisicao, req.DataInicioConsulta, req.DataFimConsulta, req.CPFCNPJConsulta,
+		req.DataRequisicao, req.DataInicioConsulta, req.DataFimConsulta,
 		req.NumeroProcesso, req.MotivoBusca, req.CPFResponsavel, req.Lotacao, req.Caso,
-		req.NumeroRequisicao, req.CPFCNPJ, req.TipoPessoa, req.Nome, req.Autorizado,
+		req.NumeroRequisicao, req.TipoPessoa, req.Autorizado,
 		req.CPFAutorizacao, req.NomeAutorizacao, req.DataHoraAutorizacao, req.TokenAutorizacao,
-		req.Status, req.Detalhamento,
+		req.Status, req.Detalhamento, pessoaID, req.IDCredencialBacen, req.UsuarioBacen, req.IDUnidade,
 	).Scan(&id)
 	if err != nil {
 		return 0, err
 	}
+	err = gravarDadosSensiveis(tx, cifraDados.Load(), tabelaRequisicaoCCS, id, valoresCampos(camposRequisicaoCCS(req)))
+	if err != nil {
+		return 0, err
+	}
 
 	// Se há relacionamentos, inseri-los
 	if len(req.RelacionamentosCCS) > 0 {
 		for _, relacionamento := range req.RelacionamentosCCS {
-			relID, err := r.inserirRelacionamentoCCS(tx, relacionamento, id)
+			relID, err := r.inserirRelacionamentoCCS(tx, relacionamento, id, req.DataRequisicao)
 			if err != nil {
 				return 0, err
 			}
 
 			// Se há bens/direitos/valores, inseri-los
-			if len(relacionamento.BemDireitoValorCCS) > 0 {
-				for _, bdv := range relacionamento.BemDireitoValorCCS {
-					bdvID, err := r.inserirBemDireitoValorCCS(tx, bdv, relID)
-					if err != nil {
-						return 0, err
-					}
-
-					// Se há vinculados, inseri-los
-					if len(bdv.Vinculados) > 0 {
-						for _, vinculado := range bdv.Vinculados {
-							err = r.inserirVinculadosBDVCCS(tx, vinculado, bdvID)
-							if err != nil {
-								return 0, err
-							}
-						}
-					}
+			for _, bdv := range relacionamento.BemDireitoValorCCS {
+				if err := r.inserirBDVComVinculados(tx, bdv, relID, req.DataRequisicao); err != nil {
+					return 0, err
 				}
 			}
 		}
@@ -88,248 +86,367 @@ func (r *CCSRepository) CriarRequisicaoRelacionamentoCCS(req *models.RequisicaoR
 	return id, nil
 }
 
+// documentoRequisicaoCCS prefere o documento devolvido pelo BACEN ao informado na consulta
+func documentoRequisicaoCCS(req *models.RequisicaoRelacionamentoCCS) string {
+	if req.CPFCNPJ != "" {
+		return req.CPFCNPJ
+	}
+	return req.CPFCNPJConsulta
+}
+
+// SalvarBDVsCCS grava os bens, direitos e valores recebidos no detalhamento de
+// um relacionamento, com seus vinculados
+func (r *CCSRepository) SalvarBDVsCCS(idRelacionamento int, bdvs []models.BemDireitoValorCCS) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var visto time.Time
+	err = tx.QueryRow(`
+		SELECT COALESCE(q.data_requisicao, NOW())
+		FROM relacionamento_ccs rc
+		JOIN requisicao_relacionamento_ccs q ON q.id = rc.id_requisicao
+		WHERE rc.id = $1
+	`, idRelacionamento).Scan(&visto)
+	if err != nil {
+		return err
+	}
+
+	for _, bdv := range bdvs {
+		if err := r.inserirBDVComVinculados(tx, bdv, idRelacionamento, visto); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// inserirBDVComVinculados insere um BDV e seus vinculados
+func (r *CCSRepository) inserirBDVComVinculados(tx *sql.Tx, bdv models.BemDireitoValorCCS, idRelacionamento int, visto time.Time) error {
+	bdvID, err := r.inserirBemDireitoValorCCS(tx, bdv, idRelacionamento, visto)
+	if err != nil {
+		return err
+	}
+
+	// Se há vinculados, inseri-los
+	for _, vinculado := range bdv.Vinculados {
+		if err := r.inserirVinculadosBDVCCS(tx, vinculado, bdvID, visto); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // inserirRelacionamentoCCS insere um relacionamento CCS e retorna o ID
-func (r *CCSRepository) inserirRelacionamentoCCS(tx *sql.Tx, rel models.RelacionamentoCCS, idRequisicao int) (int, error) {
+func (r *CCSRepository) inserirRelacionamentoCCS(tx *sql.Tx, rel models.RelacionamentoCCS, idRequisicao int, visto time.Time) (int, error) {
+	pessoaID, err := vincularPessoa(tx, rel.IDPessoa, rel.NomePessoa, visto)
+	if err != nil {
+		return 0, err
+	}
+
+	// As colunas sensíveis são gravadas depois, cifradas com o ID da linha
 	insertQuery := `
 		INSERT INTO relacionamento_ccs (
-			numero_requisicao, id_pessoa, nome_pessoa, tipo_pessoa, cnpj_responsavel,
+			numero_requisicao, tipo_pessoa, cnpj_responsavel,
 			numero_banco_responsavel, nome_banco_responsavel, cnpj_participante,
 			numero_banco_participante, nome_banco_participante, data_inicio_relacionamento,
 			data_fim_relacionamento, id_requisicao, data_requisicao_detalhamento,
 			status_detalhamento, responde_detalhamento, resposta, codigo_resposta,
-			codigo_if_resposta, nuop_resposta
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+			codigo_if_resposta, nuop_resposta, pessoa_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
 		RETURNING id
 	`
 	var id int
-	err := tx.QueryRow(
+	err = tx.QueryRow(
 		insertQuery,
-		rel.NumeroRequisicao, rel.IDPessoa, rel.NomePessoa, rel.TipoPessoa, rel.CNPJResponsavel,
+		rel.NumeroRequisicao, rel.TipoPessoa, rel.CNPJResponsavel,
 		rel.NumeroBancoResponsavel, rel.NomeBancoResponsavel, rel.CNPJParticipante,
 		rel.NumeroBancoParticipante, rel.NomeBancoParticipante, rel.DataInicioRelacionamento,
 		rel.DataFimRelacionamento, idRequisicao, rel.DataRequisicaoDetalhamento,
 		rel.StatusDetalhamento, rel.RespondeDetalhamento, rel.Resposta, rel.CodigoResposta,
-		rel.CodigoIfResposta, rel.NuopResposta,
+		rel.CodigoIfResposta, rel.NuopResposta, pessoaID,
 	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	err = gravarDadosSensiveis(tx, cifraDados.Load(), tabelaRelacionamentoCCS, id, valoresCampos(camposRelacionamentoCCS(&rel)))
 	return id, err
 }
 
-// inserirBemDireitoValorCCS insere um BDV CCS e retorna o ID
-func (r *CCSRepository) inserirBemDireitoValorCCS(tx *sql.Tx, bdv models.BemDireitoValorCCS, idRelacionamento int) (int, error) {
+// inserirBemDireitoValorCCS insere um BDV CCS, vinculado à conta citada, e retorna o ID
+func (r *CCSRepository) inserirBemDireitoValorCCS(tx *sql.Tx, bdv models.BemDireitoValorCCS, idRelacionamento int, visto time.Time) (int, error) {
+	contaID, err := vincularConta(tx, bdv.CNPJParticipante, bdv.Agencia, bdv.Conta, "", "", visto)
+	if err != nil {
+		return 0, err
+	}
+
 	insertQuery := `
 		INSERT INTO bem_direito_valor_ccs (
-			cnpj_participante, tipo, agencia, conta, vinculo, nome_pessoa, 
-			data_inicio, data_fim, id_relacionamento
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			cnpj_participante, tipo, vinculo, data_inicio, data_fim, id_relacionamento, conta_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id
 	`
 	var id int
-	err := tx.QueryRow(
+	err = tx.QueryRow(
 		insertQuery,
-		bdv.CNPJParticipante, bdv.Tipo, bdv.Agencia, bdv.Conta, bdv.Vinculo,
-		bdv.NomePessoa, bdv.DataInicio, bdv.DataFim, idRelacionamento,
+		bdv.CNPJParticipante, bdv.Tipo, bdv.Vinculo,
+		bdv.DataInicio, bdv.DataFim, idRelacionamento, contaID,
 	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	err = gravarDadosSensiveis(tx, cifraDados.Load(), tabelaBemDireitoValorCCS, id, valoresCampos(camposBemDireitoValorCCS(&bdv)))
 	return id, err
 }
 
-// inserirVinculadosBDVCCS insere um vinculado BDV CCS
-func (r *CCSRepository) inserirVinculadosBDVCCS(tx *sql.Tx, vinc models.VinculadosBDVCCS, idBDV int) error {
+// inserirVinculadosBDVCCS insere um vinculado BDV CCS ligado à sua pessoa
+func (r *CCSRepository) inserirVinculadosBDVCCS(tx *sql.Tx, vinc models.VinculadosBDVCCS, idBDV int, visto time.Time) error {
+	pessoaID, err := vincularPessoa(tx, vinc.IDPessoa, vinc.NomePessoa, visto)
+	if err != nil {
+		return err
+	}
+
 	insertQuery := `
 		INSERT INTO vinculados_bdv_ccs (
-			id_bdv, data_inicio, data_fim, id_pessoa, nome_pessoa, nome_pessoa_receita, tipo
-		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+			id_bdv, data_inicio, data_fim, tipo, pessoa_id
+		) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
 	`
-	_, err := tx.Exec(
+	var id int
+	err = tx.QueryRow(
 		insertQuery,
-		idBDV, vinc.DataInicio, vinc.DataFim, vinc.IDPessoa, vinc.NomePessoa,
-		vinc.NomePessoaReceita, vinc.Tipo,
-	)
-	return err
+		idBDV, vinc.DataInicio, vinc.DataFim, vinc.Tipo, pessoaID,
+	).Scan(&id)
+	if err != nil {
+		return err
+	}
+	return gravarDadosSensiveis(tx, cifraDados.Load(), tabelaVinculadosBDVCCS, id, valoresCampos(camposVinculadosBDVCCS(&vinc)))
 }
 
-// BuscarRequisicoesRelacionamentoCCS busca todas as requisições CCS de um CPF responsável
-func (r *CCSRepository) BuscarRequisicoesRelacionamentoCCS(cpfResponsavel string) ([]models.RequisicaoRelacionamentoCCS, error) {
-	query := `
-		SELECT id, data_requisicao, data_inicio_consulta, data_fim_consulta, cpf_cnpj_consulta,
-			numero_processo, motivo_busca, cpf_responsavel, lotacao, caso,
-			numero_requisicao, cpf_cnpj, tipo_pessoa, nome, autorizado,
-			cpf_autorizacao, nome_autorizacao, data_hora_autorizacao, token_autorizacao,
-			status, detalhamento
-		FROM requisicao_relacionamento_ccs
-		WHERE cpf_responsavel = $1
-		ORDER BY id DESC
-	`
-	rows, err := r.DB.Query(query, cpfResponsavel)
+// jsonRelacionamentosCCS agrega os relacionamentos de uma requisição (alias r),
+// com seus BDVs e vinculados, em um único JSON. As linhas cifradas vêm em
+// "cifrado" e são abertas por abrirRelacionamentosCCS.
+var jsonRelacionamentosCCS = `
+	COALESCE((
+		SELECT json_agg(json_build_object(
+			'id', rc.id, 'numeroRequisicao', rc.numero_requisicao, 'idPessoa', rc.id_pessoa,
+			'nomePessoa', rc.nome_pessoa, 'tipoPessoa', rc.tipo_pessoa,
+			'cnpjResponsavel', rc.cnpj_responsavel, 'numeroBancoResponsavel', rc.numero_banco_responsavel,
+			'nomeBancoResponsavel', rc.nome_banco_responsavel, 'cnpjParticipante', rc.cnpj_participante,
+			'numeroBancoParticipante', rc.numero_banco_participante,
+			'nomeBancoParticipante', rc.nome_banco_participante,
+			'dataInicioRelacionamento', rc.data_inicio_relacionamento,
+			'dataFimRelacionamento', rc.data_fim_relacionamento, 'idRequisicao', rc.id_requisicao,
+			'dataRequisicaoDetalhamento', rc.data_requisicao_detalhamento,
+			'statusDetalhamento', rc.status_detalhamento,
+			'respondeDetalhamento', COALESCE(rc.responde_detalhamento, FALSE),
+			'resposta', rc.resposta, 'codigoResposta', rc.codigo_resposta,
+			'codigoIfResposta', rc.codigo_if_resposta, 'nuopResposta', rc.nuop_resposta,
+			'cifrado', ` + jsonCifrado("rc") + `,
+			'bemDireitoValorCCS', COALESCE((
+				SELECT json_agg(json_build_object(
+					'id', b.id, 'cnpjParticipante', b.cnpj_participante, 'tipo', b.tipo,
+					'agencia', b.agencia, 'conta', b.conta, 'vinculo', b.vinculo,
+					'nomePessoa', b.nome_pessoa, 'dataInicio', b.data_inicio, 'dataFim', b.data_fim,
+					'idRelacionamento', b.id_relacionamento, 'cifrado', ` + jsonCifrado("b") + `,
+					'vinculados', COALESCE((
+						SELECT json_agg(json_build_object(
+							'id', v.id, 'idBDV', v.id_bdv, 'dataInicio', v.data_inicio,
+							'dataFim', v.data_fim, 'idPessoa', v.id_pessoa, 'nomePessoa', v.nome_pessoa,
+							'nomePessoaReceita', v.nome_pessoa_receita, 'tipo', v.tipo,
+							'cifrado', ` + jsonCifrado("v") + `
+						) ORDER BY v.id)
+						FROM vinculados_bdv_ccs v
+						WHERE v.id_bdv = b.id
+					), '[]')
+				) ORDER BY b.data_inicio DESC)
+				FROM bem_direito_valor_ccs b
+				WHERE b.id_relacionamento = rc.id
+			), '[]')
+		) ORDER BY rc.numero_banco_responsavel ASC)
+		FROM relacionamento_ccs rc
+		WHERE rc.id_requisicao = r.id
+	), '[]')`
+
+// colunaDataRequisicaoCCS é a expressão de ordenação das requisições CCS;
+// data_requisicao pode ser nula em registros que o BACEN devolveu sem data
+const colunaDataRequisicaoCCS = "COALESCE(r.data_requisicao, TIMESTAMPTZ 'epoch')"
+
+// selectRequisicaoCCSCompleta seleciona uma requisição CCS com toda a árvore de relacionamentos
+var selectRequisicaoCCSCompleta = `
+	SELECT r.id, ` + colunaDataRequisicaoCCS + `, r.data_inicio_consulta, r.data_fim_consulta,
+		COALESCE(r.cpf_cnpj_consulta, ''), r.numero_processo, r.motivo_busca, r.cpf_responsavel, r.lotacao, r.caso,
+		r.numero_requisicao, COALESCE(r.cpf_cnpj, ''), r.tipo_pessoa, COALESCE(r.nome, ''), r.autorizado,
+		r.cpf_autorizacao, r.nome_autorizacao, r.data_hora_autorizacao, r.token_autorizacao,
+		r.status, r.detalhamento, r.id_credencial_bacen, COALESCE(r.usuario_bacen, ''),
+		r.id_chave_dados, r.dados_cifrados,
+		` + jsonRelacionamentosCCS + `
+	FROM requisicao_relacionamento_ccs r
+`
+
+// scanRequisicaoCCSCompleta lê uma linha de selectRequisicaoCCSCompleta
+func scanRequisicaoCCSCompleta(scanner interface{ Scan(...interface{}) error }) (models.RequisicaoRelacionamentoCCS, error) {
+	var req models.RequisicaoRelacionamentoCCS
+	var relacionamentosJSON, dadosCifrados []byte
+	var idChaveDados sql.NullInt64
+
+	err := scanner.Scan(
+		&req.ID, &req.DataRequisicao, &req.DataInicioConsulta, &req.DataFimConsulta,
+		&req.CPFCNPJConsulta, &req.NumeroProcesso, &req.MotivoBusca, &req.CPFResponsavel,
+		&req.Lotacao, &req.Caso, &req.NumeroRequisicao, &req.CPFCNPJ, &req.TipoPessoa,
+		&req.Nome, &req.Autorizado, &req.CPFAutorizacao, &req.NomeAutorizacao,
+		&req.DataHoraAutorizacao, &req.TokenAutorizacao, &req.Status, &req.Detalhamento,
+		&req.IDCredencialBacen, &req.UsuarioBacen, &idChaveDados, &dadosCifrados, &relacionamentosJSON,
+	)
 	if err != nil {
-		return nil, err
+		return req, err
 	}
-	defer rows.Close()
-
-	var requisicoes []models.RequisicaoRelacionamentoCCS
-	for rows.Next() {
-		var req models.RequisicaoRelacionamentoCCS
-		
-		err := rows.Scan(
-			&req.ID, &req.DataRequisicao, &req.DataInicioConsulta, &req.DataFimConsulta, 
-			&req.CPFCNPJConsulta, &req.NumeroProcesso, &req.MotivoBusca, &req.CPFResponsavel, 
-			&req.Lotacao, &req.Caso, &req.NumeroRequisicao, &req.CPFCNPJ, &req.TipoPessoa, 
-			&req.Nome, &req.Autorizado, &req.CPFAutorizacao, &req.NomeAutorizacao, 
-			&req.DataHoraAutorizacao, &req.TokenAutorizacao, &req.Status, &req.Detalhamento,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		// Buscar relacionamentos
-		relacionamentos, err := r.buscarRelacionamentosPorRequisicao(req.ID)
-		if err != nil {
-			return nil, err
-		}
-		req.RelacionamentosCCS = relacionamentos
-
-		requisicoes = append(requisicoes, req)
+	if err = abrirRequisicaoCCS(&req, idChaveDados, dadosCifrados); err != nil {
+		return req, err
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, err
+	if err = json.Unmarshal(relacionamentosJSON, &req.RelacionamentosCCS); err != nil {
+		return req, err
 	}
-
-	return requisicoes, nil
+	err = abrirRelacionamentosCCS(req.RelacionamentosCCS)
+	return req, err
 }
 
-// buscarRelacionamentosPorRequisicao busca todos os relacionamentos CCS de uma requisição
-func (r *CCSRepository) buscarRelacionamentosPorRequisicao(idRequisicao int) ([]models.RelacionamentoCCS, error) {
-	query := `
-		SELECT id, numero_requisicao, id_pessoa, nome_pessoa, tipo_pessoa, cnpj_responsavel,
-			numero_banco_responsavel, nome_banco_responsavel, cnpj_participante,
-			numero_banco_participante, nome_banco_participante, data_inicio_relacionamento,
-			data_fim_relacionamento, id_requisicao, data_requisicao_detalhamento,
-			status_detalhamento, responde_detalhamento, resposta, codigo_resposta,
-			codigo_if_resposta, nuop_resposta
-		FROM relacionamento_ccs
-		WHERE id_requisicao = $1
-		ORDER BY numero_banco_responsavel ASC
+// BuscarRequisicoesRelacionamentoCCS busca todas as requisições CCS visíveis no escopo
+func (r *CCSRepository) BuscarRequisicoesRelacionamentoCCS(escopo Escopo) ([]models.RequisicaoRelacionamentoCCS, error) {
+	c := &consultaSQL{}
+	c.onde(escopo.condicao(c, "r"))
+
+	query := selectRequisicaoCCSCompleta + c.where() + `
+		ORDER BY r.id DESC
 	`
-	rows, err := r.DB.Query(query, idRequisicao)
+	rows, err := r.DB.Query(query, c.args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var relacionamentos []models.RelacionamentoCCS
+	var requisicoes []models.RequisicaoRelacionamentoCCS
 	for rows.Next() {
-		var rel models.RelacionamentoCCS
-		
-		err := rows.Scan(
-			&rel.ID, &rel.NumeroRequisicao, &rel.IDPessoa, &rel.NomePessoa, &rel.TipoPessoa, 
-			&rel.CNPJResponsavel, &rel.NumeroBancoResponsavel, &rel.NomeBancoResponsavel, 
-			&rel.CNPJParticipante, &rel.NumeroBancoParticipante, &rel.NomeBancoParticipante, 
-			&rel.DataInicioRelacionamento, &rel.DataFimRelacionamento, &rel.IDRequisicao, 
-			&rel.DataRequisicaoDetalhamento, &rel.StatusDetalhamento, &rel.RespondeDetalhamento, 
-			&rel.Resposta, &rel.CodigoResposta, &rel.CodigoIfResposta, &rel.NuopResposta,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		// Buscar BDVs
-		bdvs, err := r.buscarBDVPorRelacionamento(rel.ID)
+		req, err := scanRequisicaoCCSCompleta(rows)
 		if err != nil {
 			return nil, err
 		}
-		rel.BemDireitoValorCCS = bdvs
-
-		relacionamentos = append(relacionamentos, rel)
+		requisicoes = append(requisicoes, req)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return relacionamentos, nil
+	return requisicoes, nil
 }
 
-// buscarBDVPorRelacionamento busca todos os BDVs de um relacionamento
-func (r *CCSRepository) buscarBDVPorRelacionamento(idRelacionamento int) ([]models.BemDireitoValorCCS, error) {
-	query := `
-		SELECT id, cnpj_participante, tipo, agencia, conta, vinculo, nome_pessoa, 
-			data_inicio, data_fim, id_relacionamento
-		FROM bem_direito_valor_ccs
-		WHERE id_relacionamento = $1
-		ORDER BY data_inicio DESC
-	`
-	rows, err := r.DB.Query(query, idRelacionamento)
+// BuscarRequisicaoCCSPorID carrega uma requisição CCS completa em uma única consulta
+func (r *CCSRepository) BuscarRequisicaoCCSPorID(escopo Escopo, id int) (*models.RequisicaoRelacionamentoCCS, error) {
+	c := &consultaSQL{}
+	c.onde("r.id = " + c.arg(id))
+	c.onde(escopo.condicao(c, "r"))
+
+	req, err := scanRequisicaoCCSCompleta(r.DB.QueryRow(selectRequisicaoCCSCompleta+c.where(), c.args...))
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRequisicaoNaoEncontrada
+		}
 		return nil, err
 	}
-	defer rows.Close()
-
-	var bdvs []models.BemDireitoValorCCS
-	for rows.Next() {
-		var bdv models.BemDireitoValorCCS
-		
-		err := rows.Scan(
-			&bdv.ID, &bdv.CNPJParticipante, &bdv.Tipo, &bdv.Agencia, &bdv.Conta, 
-			&bdv.Vinculo, &bdv.NomePessoa, &bdv.DataInicio, &bdv.DataFim, &bdv.IDRelacionamento,
-		)
-		if err != nil {
-			return nil, err
-		}
+	return &req, nil
+}
 
-		// Buscar vinculados
-		vinculados, err := r.buscarVinculadosPorBDV(bdv.ID)
-		if err != nil {
-			return nil, err
-		}
-		bdv.Vinculados = vinculados
+// ListarRequisicoesCCS lista, paginado por cursor, o resumo das requisições CCS visíveis no escopo
+func (r *CCSRepository) ListarRequisicoesCCS(escopo Escopo, filtro FiltroHistorico) ([]models.ResumoRequisicaoCCS, string, error) {
+	c := &consultaSQL{}
+	c.onde(escopo.condicao(c, "r"))
 
-		bdvs = append(bdvs, bdv)
+	if filtro.DataInicio != nil {
+		c.onde("r.data_requisicao >= " + c.arg(*filtro.DataInicio))
 	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
+	if filtro.DataFim != nil {
+		c.onde("r.data_requisicao < " + c.arg(*filtro.DataFim))
+	}
+	if filtro.Caso != "" {
+		c.onde("r.caso ILIKE " + c.arg("%"+filtro.Caso+"%"))
+	}
+	if filtro.Alvo != "" {
+		c.onde(condicaoIndice(c, "r", "cpf_cnpj_consulta", IndiceDocumento, filtro.Alvo))
+	}
+	if filtro.Status != "" {
+		c.onde("r.status = " + c.arg(filtro.Status))
+	}
+	// Para o CCS, o resultado é o status de detalhamento de algum dos relacionamentos
+	if filtro.Resultado != "" {
+		c.onde("EXISTS (SELECT 1 FROM relacionamento_ccs rc WHERE rc.id_requisicao = r.id AND rc.status_detalhamento = " + c.arg(filtro.Resultado) + ")")
+	}
+	if filtro.CPFResponsavel != "" {
+		c.onde("r.cpf_responsavel = " + c.arg(filtro.CPFResponsavel))
 	}
 
-	return bdvs, nil
-}
+	ordem, err := c.paginar(filtro, colunaDataRequisicaoCCS, "r.id")
+	if err != nil {
+		return nil, "", err
+	}
 
-// buscarVinculadosPorBDV busca todos os vinculados de um BDV
-func (r *CCSRepository) buscarVinculadosPorBDV(idBDV int) ([]models.VinculadosBDVCCS, error) {
-	query := `
-		SELECT id, id_bdv, data_inicio, data_fim, id_pessoa, nome_pessoa, nome_pessoa_receita, tipo
-		FROM vinculados_bdv_ccs
-		WHERE id_bdv = $1
-	`
-	rows, err := r.DB.Query(query, idBDV)
+	query := fmt.Sprintf(`
+		SELECT r.id, %s, COALESCE(r.cpf_cnpj_consulta, ''), COALESCE(r.nome, ''), r.numero_processo, r.numero_requisicao,
+			r.cpf_responsavel, r.lotacao, r.caso, r.status,
+			COUNT(rc.id), COUNT(rc.id) FILTER (WHERE rc.status_detalhamento = 'Concluído'),
+			r.id_chave_dados, r.dados_cifrados
+		FROM requisicao_relacionamento_ccs r
+		LEFT JOIN relacionamento_ccs rc ON rc.id_requisicao = r.id
+		%s
+		GROUP BY r.id
+		%s
+	`, colunaDataRequisicaoCCS, c.where(), ordem)
+	rows, err := r.DB.Query(query, c.args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
 
-	var vinculados []models.VinculadosBDVCCS
+	resumos := make([]models.ResumoRequisicaoCCS, 0)
 	for rows.Next() {
-		var vinc models.VinculadosBDVCCS
-		
+		var res models.ResumoRequisicaoCCS
+		var idChaveDados sql.NullInt64
+		var dadosCifrados []byte
 		err := rows.Scan(
-			&vinc.ID, &vinc.IDBDV, &vinc.DataInicio, &vinc.DataFim, &vinc.IDPessoa, 
-			&vinc.NomePessoa, &vinc.NomePessoaReceita, &vinc.Tipo,
+			&res.ID, &res.DataRequisicao, &res.CPFCNPJConsulta, &res.Nome, &res.NumeroProcesso,
+			&res.NumeroRequisicao, &res.CPFResponsavel, &res.Lotacao, &res.Caso, &res.Status,
+			&res.QuantidadeRelacionamentos, &res.DetalhamentosConcluidos, &idChaveDados, &dadosCifrados,
 		)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
-
-		vinculados = append(vinculados, vinc)
+		req := models.RequisicaoRelacionamentoCCS{ID: res.ID, CPFCNPJConsulta: res.CPFCNPJConsulta, Nome: res.Nome}
+		if err := abrirRequisicaoCCS(&req, idChaveDados, dadosCifrados); err != nil {
+			return nil, "", err
+		}
+		res.CPFCNPJConsulta, res.Nome = req.CPFCNPJConsulta, req.Nome
+		resumos = append(resumos, res)
 	}
-
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	return vinculados, nil
+	var proximoCursor string
+	if len(resumos) > filtro.limite() {
+		resumos = resumos[:filtro.limite()]
+		ultimo := resumos[len(resumos)-1]
+		proximoCursor = codificarCursor(ultimo.DataRequisicao, ultimo.ID)
+	}
+
+	return resumos, proximoCursor, nil
 }
 
 // AtualizarStatusDetalhamentoCCS atualiza o status de detalhamento de um relacionamento CCS
-func (r *CCSRepository) AtualizarStatusDetalhamentoCCS(id int, status string, respondeDetalhamento, resposta bool, dataRequisicaoDetalhamento, codigoResposta, codigoIfResposta, nuopResposta string) error {
+func (r *CCSRepository) AtualizarStatusDetalhamentoCCS(id int, status string, respondeDetalhamento, resposta bool, dataRequisicaoDetalhamento *time.Time, codigoResposta, codigoIfResposta, nuopResposta string) error {
 	query := `
 		UPDATE relacionamento_ccs
 		SET status_detalhamento = $1,
@@ -355,14 +472,64 @@ func (r *CCSRepository) AtualizarStatusDetalhamentoCCS(id int, status string, re
 	return err
 }
 
+// CredencialDoRelacionamento retorna o perfil de credencial usado na requisição
+// do relacionamento (nil para a credencial padrão)
+func (r *CCSRepository) CredencialDoRelacionamento(idRelacionamento int) (*int, error) {
+	var idCredencial *int
+	err := r.DB.QueryRow(`
+		SELECT q.id_credencial_bacen
+		FROM relacionamento_ccs rc
+		JOIN requisicao_relacionamento_ccs q ON q.id = rc.id_requisicao
+		WHERE rc.id = $1
+	`, idRelacionamento).Scan(&idCredencial)
+	if err == sql.ErrNoRows {
+		return nil, ErrRequisicaoNaoEncontrada
+	}
+	return idCredencial, err
+}
+
+// ResponsavelDoRelacionamento retorna o CPF de quem fez a requisição do relacionamento
+func (r *CCSRepository) ResponsavelDoRelacionamento(idRelacionamento int) (string, error) {
+	var cpf string
+	err := r.DB.QueryRow(`
+		SELECT q.cpf_responsavel
+		FROM relacionamento_ccs rc
+		JOIN requisicao_relacionamento_ccs q ON q.id = rc.id_requisicao
+		WHERE rc.id = $1
+	`, idRelacionamento).Scan(&cpf)
+	if err == sql.ErrNoRows {
+		return "", ErrRequisicaoNaoEncontrada
+	}
+	return cpf, err
+}
+
+// VerificarRelacionamentoNoEscopo confirma que o relacionamento pertence a uma
+// requisição visível no escopo; do contrário retorna ErrRequisicaoNaoEncontrada
+func (r *CCSRepository) VerificarRelacionamentoNoEscopo(escopo Escopo, idRelacionamento int) error {
+	c := &consultaSQL{}
+	c.onde("rc.id = " + c.arg(idRelacionamento))
+	c.onde(escopo.condicao(c, "r"))
+
+	var id int
+	err := r.DB.QueryRow(`
+		SELECT rc.id
+		FROM relacionamento_ccs rc
+		JOIN requisicao_relacionamento_ccs r ON r.id = rc.id_requisicao
+	`+c.where(), c.args...).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrRequisicaoNaoEncontrada
+	}
+	return err
+}
+
 // BuscarRelacionamentosNaFila busca todos os relacionamentos CCS com status "Na fila"
 func (r *CCSRepository) BuscarRelacionamentosNaFila() ([]models.RequisicaoRelacionamentoCCS, error) {
 	query := `
-		SELECT r.id, r.data_requisicao, r.data_inicio_consulta, r.data_fim_consulta, r.cpf_cnpj_consulta,
+		SELECT r.id, r.data_requisicao, r.data_inicio_consulta, r.data_fim_consulta, COALESCE(r.cpf_cnpj_consulta, ''),
 			r.numero_processo, r.motivo_busca, r.cpf_responsavel, r.lotacao, r.caso,
-			r.numero_requisicao, r.cpf_cnpj, r.tipo_pessoa, r.nome, r.autorizado,
+			r.numero_requisicao, COALESCE(r.cpf_cnpj, ''), r.tipo_pessoa, COALESCE(r.nome, ''), r.autorizado,
 			r.cpf_autorizacao, r.nome_autorizacao, r.data_hora_autorizacao, r.token_autorizacao,
-			r.status, r.detalhamento
+			r.status, r.detalhamento, r.id_credencial_bacen, r.id_chave_dados, r.dados_cifrados
 		FROM requisicao_relacionamento_ccs r
 		INNER JOIN relacionamento_ccs rc ON r.id = rc.id_requisicao
 		WHERE rc.status_detalhamento = 'Na fila'
@@ -378,6 +545,8 @@ func (r *CCSRepository) BuscarRelacionamentosNaFila() ([]models.RequisicaoRelaci
 	var requisicoes []models.RequisicaoRelacionamentoCCS
 	for rows.Next() {
 		var req models.RequisicaoRelacionamentoCCS
+		var idChaveDadosReq sql.NullInt64
+		var dadosCifradosReq []byte
 		
 		err := rows.Scan(
 			&req.ID, &req.DataRequisicao, &req.DataInicioConsulta, &req.DataFimConsulta, 
@@ -385,19 +554,23 @@ func (r *CCSRepository) BuscarRelacionamentosNaFila() ([]models.RequisicaoRelaci
 			&req.Lotacao, &req.Caso, &req.NumeroRequisicao, &req.CPFCNPJ, &req.TipoPessoa, 
 			&req.Nome, &req.Autorizado, &req.CPFAutorizacao, &req.NomeAutorizacao, 
 			&req.DataHoraAutorizacao, &req.TokenAutorizacao, &req.Status, &req.Detalhamento,
+			&req.IDCredencialBacen, &idChaveDadosReq, &dadosCifradosReq,
 		)
 		if err != nil {
 			return nil, err
 		}
+		if err = abrirRequisicaoCCS(&req, idChaveDadosReq, dadosCifradosReq); err != nil {
+			return nil, err
+		}
 
 		// Buscar apenas relacionamentos na fila
 		query := `
-			SELECT id, numero_requisicao, id_pessoa, nome_pessoa, tipo_pessoa, cnpj_responsavel,
+			SELECT id, numero_requisicao, COALESCE(id_pessoa, ''), COALESCE(nome_pessoa, ''), tipo_pessoa, cnpj_responsavel,
 				numero_banco_responsavel, nome_banco_responsavel, cnpj_participante,
 				numero_banco_participante, nome_banco_participante, data_inicio_relacionamento,
 				data_fim_relacionamento, id_requisicao, data_requisicao_detalhamento,
 				status_detalhamento, responde_detalhamento, resposta, codigo_resposta,
-				codigo_if_resposta, nuop_resposta
+				codigo_if_resposta, nuop_resposta, id_chave_dados, dados_cifrados
 			FROM relacionamento_ccs
 			WHERE id_requisicao = $1 AND status_detalhamento = 'Na fila'
 		`
@@ -409,6 +582,8 @@ func (r *CCSRepository) BuscarRelacionamentosNaFila() ([]models.RequisicaoRelaci
 		var relacionamentos []models.RelacionamentoCCS
 		for relRows.Next() {
 			var rel models.RelacionamentoCCS
+			var idChaveDados sql.NullInt64
+			var dadosCifrados []byte
 			
 			err := relRows.Scan(
 				&rel.ID, &rel.NumeroRequisicao, &rel.IDPessoa, &rel.NomePessoa, &rel.TipoPessoa, 
@@ -417,11 +592,15 @@ func (r *CCSRepository) BuscarRelacionamentosNaFila() ([]models.RequisicaoRelaci
 				&rel.DataInicioRelacionamento, &rel.DataFimRelacionamento, &rel.IDRequisicao, 
 				&rel.DataRequisicaoDetalhamento, &rel.StatusDetalhamento, &rel.RespondeDetalhamento, 
 				&rel.Resposta, &rel.CodigoResposta, &rel.CodigoIfResposta, &rel.NuopResposta,
+				&idChaveDados, &dadosCifrados,
 			)
 			if err != nil {
 				relRows.Close()
 				return nil, err
 			}
+			if dadosCifrados != nil {
+				rel.Cifrado = &models.DadosCifrados{Chave: int(idChaveDados.Int64), Dados: dadosCifrados}
+			}
 
 			relacionamentos = append(relacionamentos, rel)
 		}
@@ -430,6 +609,9 @@ func (r *CCSRepository) BuscarRelacionamentosNaFila() ([]models.RequisicaoRelaci
 		if err = relRows.Err(); err != nil {
 			return nil, err
 		}
+		if err = abrirRelacionamentosCCS(relacionamentos); err != nil {
+			return nil, err
+		}
 
 		req.RelacionamentosCCS = relacionamentos
 		requisicoes = append(requisicoes, req)
@@ -445,11 +627,11 @@ func (r *CCSRepository) BuscarRelacionamentosNaFila() ([]models.RequisicaoRelaci
 // BuscarRelacionamentosAguardandoResposta busca todos os relacionamentos CCS com status "Solicitado. Aguardando..."
 func (r *CCSRepository) BuscarRelacionamentosAguardandoResposta() ([]models.RequisicaoRelacionamentoCCS, error) {
 	query := `
-		SELECT r.id, r.data_requisicao, r.data_inicio_consulta, r.data_fim_consulta, r.cpf_cnpj_consulta,
+		SELECT r.id, r.data_requisicao, r.data_inicio_consulta, r.data_fim_consulta, COALESCE(r.cpf_cnpj_consulta, ''),
 			r.numero_processo, r.motivo_busca, r.cpf_responsavel, r.lotacao, r.caso,
-			r.numero_requisicao, r.cpf_cnpj, r.tipo_pessoa, r.nome, r.autorizado,
+			r.numero_requisicao, COALESCE(r.cpf_cnpj, ''), r.tipo_pessoa, COALESCE(r.nome, ''), r.autorizado,
 			r.cpf_autorizacao, r.nome_autorizacao, r.data_hora_autorizacao, r.token_autorizacao,
-			r.status, r.detalhamento
+			r.status, r.detalhamento, r.id_credencial_bacen, r.id_chave_dados, r.dados_cifrados
 		FROM requisicao_relacionamento_ccs r
 		INNER JOIN relacionamento_ccs rc ON r.id = rc.id_requisicao
 		WHERE rc.status_detalhamento = 'Solicitado. Aguardando...'
@@ -465,6 +647,8 @@ func (r *CCSRepository) BuscarRelacionamentosAguardandoResposta() ([]models.Requ
 	var requisicoes []models.RequisicaoRelacionamentoCCS
 	for rows.Next() {
 		var req models.RequisicaoRelacionamentoCCS
+		var idChaveDadosReq sql.NullInt64
+		var dadosCifradosReq []byte
 		
 		err := rows.Scan(
 			&req.ID, &req.DataRequisicao, &req.DataInicioConsulta, &req.DataFimConsulta, 
@@ -472,19 +656,23 @@ func (r *CCSRepository) BuscarRelacionamentosAguardandoResposta() ([]models.Requ
 			&req.Lotacao, &req.Caso, &req.NumeroRequisicao, &req.CPFCNPJ, &req.TipoPessoa, 
 			&req.Nome, &req.Autorizado, &req.CPFAutorizacao, &req.NomeAutorizacao, 
 			&req.DataHoraAutorizacao, &req.TokenAutorizacao, &req.Status, &req.Detalhamento,
+			&req.IDCredencialBacen, &idChaveDadosReq, &dadosCifradosReq,
 		)
 		if err != nil {
 			return nil, err
 		}
+		if err = abrirRequisicaoCCS(&req, idChaveDadosReq, dadosCifradosReq); err != nil {
+			return nil, err
+		}
 
 		// Buscar apenas relacionamentos aguardando resposta
 		query := `
-			SELECT id, numero_requisicao, id_pessoa, nome_pessoa, tipo_pessoa, cnpj_responsavel,
+			SELECT id, numero_requisicao, COALESCE(id_pessoa, ''), COALESCE(nome_pessoa, ''), tipo_pessoa, cnpj_responsavel,
 				numero_banco_responsavel, nome_banco_responsavel, cnpj_participante,
 				numero_banco_participante, nome_banco_participante, data_inicio_relacionamento,
 				data_fim_relacionamento, id_requisicao, data_requisicao_detalhamento,
 				status_detalhamento, responde_detalhamento, resposta, codigo_resposta,
-				codigo_if_resposta, nuop_resposta
+				codigo_if_resposta, nuop_resposta, id_chave_dados, dados_cifrados
 			FROM relacionamento_ccs
 			WHERE id_requisicao = $1 AND status_detalhamento = 'Solicitado. Aguardando...'
 		`
@@ -496,6 +684,8 @@ func (r *CCSRepository) BuscarRelacionamentosAguardandoResposta() ([]models.Requ
 		var relacionamentos []models.RelacionamentoCCS
 		for relRows.Next() {
 			var rel models.RelacionamentoCCS
+			var idChaveDados sql.NullInt64
+			var dadosCifrados []byte
 			
 			err := relRows.Scan(
 				&rel.ID, &rel.NumeroRequisicao, &rel.IDPessoa, &rel.NomePessoa, &rel.TipoPessoa, 
@@ -504,11 +694,15 @@ func (r *CCSRepository) BuscarRelacionamentosAguardandoResposta() ([]models.Requ
 				&rel.DataInicioRelacionamento, &rel.DataFimRelacionamento, &rel.IDRequisicao, 
 				&rel.DataRequisicaoDetalhamento, &rel.StatusDetalhamento, &rel.RespondeDetalhamento, 
 				&rel.Resposta, &rel.CodigoResposta, &rel.CodigoIfResposta, &rel.NuopResposta,
+				&idChaveDados, &dadosCifrados,
 			)
 			if err != nil {
 				relRows.Close()
 				return nil, err
 			}
+			if dadosCifrados != nil {
+				rel.Cifrado = &models.DadosCifrados{Chave: int(idChaveDados.Int64), Dados: dadosCifrados}
+			}
 
 			relacionamentos = append(relacionamentos, rel)
 		}
@@ -517,6 +711,9 @@ func (r *CCSRepository) BuscarRelacionamentosAguardandoResposta() ([]models.Requ
 		if err = relRows.Err(); err != nil {
 			return nil, err
 		}
+		if err = abrirRelacionamentosCCS(relacionamentos); err != nil {
+			return nil, err
+		}
 
 		req.RelacionamentosCCS = relacionamentos
 		requisicoes = append(requisicoes, req)
@@ -527,4 +724,25 @@ func (r *CCSRepository) BuscarRelacionamentosAguardandoResposta() ([]models.Requ
 	}
 
 	return requisicoes, nil
-}
\ No newline at end of file
+}
+// BuscarIDRequisicaoCCSAnterior retorna a requisição visível no escopo feita
+// imediatamente antes da informada para o mesmo CPF/CNPJ, ignorando falhas
+func (r *CCSRepository) BuscarIDRequisicaoCCSAnterior(escopo Escopo, req *models.RequisicaoRelacionamentoCCS) (int, error) {
+	c := &consultaSQL{}
+	c.onde(escopo.condicao(c, "r"))
+	c.onde(condicaoIndice(c, "r", "cpf_cnpj_consulta", IndiceDocumento, req.CPFCNPJConsulta))
+	c.onde(fmt.Sprintf("(r.data_requisicao, r.id) < (%s, %s)", c.arg(req.DataRequisicao), c.arg(req.ID)))
+	c.onde("r.status <> 'Falha'")
+
+	var id int
+	err := r.DB.QueryRow(fmt.Sprintf(`
+		SELECT r.id FROM requisicao_relacionamento_ccs r
+		%s
+		ORDER BY r.data_requisicao DESC, r.id DESC
+		LIMIT 1
+	`, c.where()), c.args...).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrRequisicaoNaoEncontrada
+	}
+	return id, err
+}
@@ -0,0 +1,382 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/cripto"
+	"github.com/tassyosilva/consultapix/internal/database"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+// Finalidades das chaves de dados
+const (
+	FinalidadeDados  = "dados"
+	FinalidadeIndice = "indice"
+)
+
+// loteRecifragem é o número de linhas recifradas por transação
+const loteRecifragem = 500
+
+// ChaveDadosRepository mantém as chaves de dados, embrulhadas pela chave
+// mestra, e recifra as linhas quando elas mudam
+type ChaveDadosRepository struct {
+	DB *sql.DB
+}
+
+func NewChaveDadosRepository() *ChaveDadosRepository {
+	return &ChaveDadosRepository{
+		DB: database.GetDB(),
+	}
+}
+
+// contextoChaveDados vincula a chave embrulhada à sua finalidade
+func contextoChaveDados(finalidade string) []byte {
+	return []byte("chave_dados:" + finalidade)
+}
+
+// carregar desembrulha todas as chaves, criando a chave ativa de cada
+// finalidade se ainda não existir
+func (r *ChaveDadosRepository) carregar(chaveiro *cripto.Chaveiro) (*chavesDados, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, finalidade := range []string{FinalidadeDados, FinalidadeIndice} {
+		if err := criarChaveDados(tx, chaveiro, finalidade); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := tx.Query(`SELECT id, finalidade, chave_mestra, chave_embrulhada, ativa FROM chave_dados`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chaves := &chavesDados{chaveiro: chaveiro, dados: map[int]*cripto.Cifrador{}}
+	for rows.Next() {
+		var id int
+		var finalidade, mestra string
+		var embrulhada []byte
+		var ativa bool
+		if err := rows.Scan(&id, &finalidade, &mestra, &embrulhada, &ativa); err != nil {
+			return nil, err
+		}
+		chave, err := chaveiro.Desembrulhar(mestra, embrulhada, contextoChaveDados(finalidade))
+		if err != nil {
+			return nil, fmt.Errorf("chave de dados %d: %w", id, err)
+		}
+
+		switch {
+		case finalidade == FinalidadeIndice && ativa:
+			chaves.indice = chave
+		case finalidade == FinalidadeDados:
+			if chaves.dados[id], err = cripto.NovoCifrador(chave); err != nil {
+				return nil, err
+			}
+			if ativa {
+				chaves.atual = id
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return chaves, nil
+}
+
+// criarChaveDados gera e grava a chave ativa da finalidade, se não houver
+// uma. Processos iniciados juntos não criam chaves duplicadas.
+func criarChaveDados(tx *sql.Tx, chaveiro *cripto.Chaveiro, finalidade string) error {
+	chave, err := cripto.NovaChave()
+	if err != nil {
+		return err
+	}
+	mestra, embrulhada, err := chaveiro.Embrulhar(chave, contextoChaveDados(finalidade))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO chave_dados (finalidade, chave_mestra, chave_embrulhada)
+		SELECT $1::varchar, $2, $3
+		WHERE NOT EXISTS (SELECT 1 FROM chave_dados WHERE finalidade = $1 AND ativa)
+		ON CONFLICT (finalidade) WHERE ativa DO NOTHING
+	`, finalidade, mestra, embrulhada)
+	return err
+}
+
+// Reembrulhar embrulha com a chave mestra atual as chaves de dados que ainda
+// estão em uma chave mestra anterior. Os dados não precisam ser recifrados.
+func (r *ChaveDadosRepository) Reembrulhar(chaveiro *cripto.Chaveiro) (int, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, finalidade, chave_mestra, chave_embrulhada FROM chave_dados
+		WHERE chave_mestra <> $1
+		FOR UPDATE
+	`, chaveiro.Atual())
+	if err != nil {
+		return 0, err
+	}
+	type chaveEmbrulhada struct {
+		id         int
+		embrulhada []byte
+	}
+	var pendentes []chaveEmbrulhada
+	for rows.Next() {
+		var id int
+		var finalidade, mestra string
+		var embrulhada []byte
+		if err := rows.Scan(&id, &finalidade, &mestra, &embrulhada); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		chave, err := chaveiro.Desembrulhar(mestra, embrulhada, contextoChaveDados(finalidade))
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("chave de dados %d: %w", id, err)
+		}
+		if _, embrulhada, err = chaveiro.Embrulhar(chave, contextoChaveDados(finalidade)); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pendentes = append(pendentes, chaveEmbrulhada{id, embrulhada})
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+
+	for _, p := range pendentes {
+		if _, err := tx.Exec(`UPDATE chave_dados SET chave_mestra = $2, chave_embrulhada = $3 WHERE id = $1`,
+			p.id, chaveiro.Atual(), p.embrulhada); err != nil {
+			return 0, err
+		}
+	}
+	return len(pendentes), tx.Commit()
+}
+
+// Rotacionar desativa a chave de dados atual e cria outra. As linhas
+// continuam legíveis pela chave anterior até serem recifradas.
+func (r *ChaveDadosRepository) Rotacionar(chaveiro *cripto.Chaveiro) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE chave_dados SET ativa = FALSE, desativada_em = NOW() WHERE finalidade = $1 AND ativa
+	`, FinalidadeDados); err != nil {
+		return err
+	}
+	if err := criarChaveDados(tx, chaveiro, FinalidadeDados); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Recifrar grava na chave de dados ativa as linhas em claro ou em chaves
+// anteriores, tabela a tabela; com emClaro devolve tudo às colunas em claro.
+// As chaves precisam ter sido carregadas com AtivarCifraDados.
+func (r *ChaveDadosRepository) Recifrar(emClaro bool, progresso func(tabela string, linhas int)) error {
+	chaves := cifraDados.Load()
+	if chaves == nil {
+		return ErrCifraDadosInativa
+	}
+	alvo, idAlvo := chaves, sql.NullInt64{Int64: int64(chaves.atual), Valid: true}
+	if emClaro {
+		alvo, idAlvo = nil, sql.NullInt64{}
+	}
+
+	for _, t := range tabelasCifradas {
+		linhas, err := r.recifrarTabela(t, alvo, idAlvo)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.nome, err)
+		}
+		progresso(t.nome, linhas)
+	}
+	return nil
+}
+
+// linhaRecifragem é uma linha lida para recifragem
+type linhaRecifragem struct {
+	id      int
+	campos  map[string]string
+	cifrado *models.DadosCifrados
+}
+
+func (r *ChaveDadosRepository) recifrarTabela(t tabelaCifrada, alvo *chavesDados, idAlvo sql.NullInt64) (int, error) {
+	colunas := make([]string, len(t.colunas))
+	for i, coluna := range t.colunas {
+		colunas[i] = coluna + "::text"
+	}
+	// Ao cifrar, também as linhas cifradas que ainda têm colunas em claro
+	pendentes := "id_chave_dados IS DISTINCT FROM $2"
+	if alvo != nil {
+		pendentes = "(" + pendentes + " OR " + t.emClaro() + ")"
+	}
+	query := fmt.Sprintf(`
+		SELECT id, %s, id_chave_dados, dados_cifrados FROM %s
+		WHERE id > $1 AND %s
+		ORDER BY id
+		LIMIT %d
+		FOR UPDATE
+	`, strings.Join(colunas, ", "), t.nome, pendentes, loteRecifragem)
+
+	total, ultimo := 0, 0
+	for {
+		tx, err := r.DB.Begin()
+		if err != nil {
+			return total, err
+		}
+		linhas, err := lerLinhasRecifragem(tx, t, query, ultimo, idAlvo)
+		if err != nil {
+			tx.Rollback()
+			return total, err
+		}
+
+		for _, linha := range linhas {
+			campos := linha.campos
+			if linha.cifrado != nil {
+				decifrados, err := decifrarCampos(t, linha.id, linha.cifrado)
+				if err != nil {
+					tx.Rollback()
+					return total, err
+				}
+				for coluna, valor := range decifrados {
+					campos[coluna] = valor
+				}
+			}
+			if err := gravarDadosSensiveis(tx, alvo, t, linha.id, campos); err != nil {
+				tx.Rollback()
+				return total, err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return total, err
+		}
+
+		total += len(linhas)
+		if len(linhas) < loteRecifragem {
+			return total, nil
+		}
+		ultimo = linhas[len(linhas)-1].id
+	}
+}
+
+func lerLinhasRecifragem(tx *sql.Tx, t tabelaCifrada, query string, ultimo int, idAlvo sql.NullInt64) ([]linhaRecifragem, error) {
+	rows, err := tx.Query(query, ultimo, idAlvo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var linhas []linhaRecifragem
+	for rows.Next() {
+		var linha linhaRecifragem
+		valores := make([]sql.NullString, len(t.colunas))
+		var idChave sql.NullInt64
+		var dados []byte
+		destinos := []interface{}{&linha.id}
+		for i := range valores {
+			destinos = append(destinos, &valores[i])
+		}
+		destinos = append(destinos, &idChave, &dados)
+		if err := rows.Scan(destinos...); err != nil {
+			return nil, err
+		}
+
+		// As colunas em claro completam as que não estão no conteúdo cifrado
+		if dados != nil {
+			linha.cifrado = &models.DadosCifrados{Chave: int(idChave.Int64), Dados: dados}
+		}
+		linha.campos = make(map[string]string, len(t.colunas))
+		for i, coluna := range t.colunas {
+			linha.campos[coluna] = valores[i].String
+		}
+		linhas = append(linhas, linha)
+	}
+	return linhas, rows.Err()
+}
+
+// RemoverSemUso exclui as chaves de dados inativas que não cifram mais
+// nenhuma linha. A carência dá tempo aos processos em execução de recarregar
+// as chaves e deixar de usar a anterior.
+func (r *ChaveDadosRepository) RemoverSemUso(carencia time.Duration) (int, error) {
+	condicoes := make([]string, len(tabelasCifradas))
+	for i, t := range tabelasCifradas {
+		condicoes[i] = "NOT EXISTS (SELECT 1 FROM " + t.nome + " WHERE id_chave_dados = k.id)"
+	}
+	resultado, err := r.DB.Exec(`
+		DELETE FROM chave_dados k
+		WHERE k.finalidade = $1 AND NOT k.ativa AND k.desativada_em < NOW() - make_interval(secs => $2)
+			AND `+strings.Join(condicoes, " AND "),
+		FinalidadeDados, carencia.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	n, _ := resultado.RowsAffected()
+	return int(n), nil
+}
+
+// Listar lista as chaves, sem o conteúdo, com o número de linhas cifradas em cada uma
+func (r *ChaveDadosRepository) Listar() ([]models.ChaveDados, error) {
+	contagens := make([]string, len(tabelasCifradas))
+	for i, t := range tabelasCifradas {
+		contagens[i] = "(SELECT COUNT(*) FROM " + t.nome + " WHERE id_chave_dados = k.id)"
+	}
+	rows, err := r.DB.Query(`
+		SELECT k.id, k.finalidade, k.chave_mestra, k.ativa, k.criada_em, k.desativada_em, ` +
+		strings.Join(contagens, " + ") + `
+		FROM chave_dados k
+		ORDER BY k.finalidade, k.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chaves := []models.ChaveDados{}
+	for rows.Next() {
+		var c models.ChaveDados
+		var desativada sql.NullTime
+		if err := rows.Scan(&c.ID, &c.Finalidade, &c.ChaveMestra, &c.Ativa, &c.CriadaEm, &desativada, &c.Linhas); err != nil {
+			return nil, err
+		}
+		if desativada.Valid {
+			c.DesativadaEm = &desativada.Time
+		}
+		chaves = append(chaves, c)
+	}
+	return chaves, rows.Err()
+}
+
+// LinhasEmClaro conta, por tabela, as linhas com colunas sensíveis em claro
+func (r *ChaveDadosRepository) LinhasEmClaro() (map[string]int, error) {
+	linhas := map[string]int{}
+	for _, t := range tabelasCifradas {
+		var n int
+		if err := r.DB.QueryRow(`SELECT COUNT(*) FROM ` + t.nome + ` WHERE ` + t.emClaro()).Scan(&n); err != nil {
+			return nil, err
+		}
+		linhas[t.nome] = n
+	}
+	return linhas, nil
+}
@@ -0,0 +1,464 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tassyosilva/consultapix/internal/cripto"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+// Tipos de índice cego. Cada tipo normaliza o valor do seu jeito antes do
+// HMAC, para que a busca encontre o dado com ou sem pontuação.
+const (
+	IndiceDocumento = "documento"
+	IndiceChave     = "chave"
+	IndiceConta     = "conta"
+	// IndiceContaCompleta identifica a conta por ISPB, agência e número
+	IndiceContaCompleta = "conta_completa"
+)
+
+// ErrCifraDadosInativa indica linha cifrada lida sem DATA_ENCRYPTION_KEY configurada
+var ErrCifraDadosInativa = errors.New("há dados cifrados, mas DATA_ENCRYPTION_KEY não está configurada")
+
+// chavesDados são as chaves de dados já desembrulhadas pelo processo
+type chavesDados struct {
+	chaveiro *cripto.Chaveiro
+	dados    map[int]*cripto.Cifrador
+	atual    int
+	indice   []byte
+}
+
+// cifraDados guarda as chaves do processo; nil enquanto a cifragem não for
+// ativada, caso em que as colunas sensíveis são gravadas em claro
+var cifraDados atomic.Pointer[chavesDados]
+
+// AtivarCifraDados carrega as chaves de dados, criando as que faltarem, e
+// passa a cifrar as colunas sensíveis nas gravações seguintes
+func AtivarCifraDados(chaveiro *cripto.Chaveiro) error {
+	chaves, err := NewChaveDadosRepository().carregar(chaveiro)
+	if err != nil {
+		return err
+	}
+	cifraDados.Store(chaves)
+	return nil
+}
+
+// RecarregarCifraDados relê as chaves do banco, para seguir rotações feitas
+// por outro processo
+func RecarregarCifraDados() error {
+	atuais := cifraDados.Load()
+	if atuais == nil {
+		return nil
+	}
+	return AtivarCifraDados(atuais.chaveiro)
+}
+
+// MonitorarCifraDados recarrega as chaves periodicamente
+func MonitorarCifraDados(intervalo time.Duration) {
+	for range time.Tick(intervalo) {
+		if err := RecarregarCifraDados(); err != nil {
+			log.Printf("Erro ao recarregar as chaves de dados: %v", err)
+		}
+	}
+}
+
+// indiceColuna indica uma coluna sensível com índice cego
+type indiceColuna struct {
+	tipo   string
+	coluna string
+}
+
+// indiceUnico é o índice cego que identifica a linha, calculado sobre as
+// colunas já normalizadas, unidas por "|"
+type indiceUnico struct {
+	tipo    string
+	colunas []string
+}
+
+// tabelaCifrada descreve as colunas sensíveis de uma tabela. Com a cifragem
+// ativa elas ficam nulas e o conteúdo vai, em JSON, para dados_cifrados.
+type tabelaCifrada struct {
+	nome    string
+	colunas []string
+	// jsonb são as colunas do tipo JSONB, convertidas ao gravar em claro
+	jsonb map[string]bool
+	// indices são os valores pesquisáveis; sem eles a tabela não tem a coluna indices
+	indices []indiceColuna
+	// unico, nas tabelas consolidadas (pessoa e conta), vai para a coluna
+	// indice_unico, pela qual as gravações encontram a linha cifrada
+	unico *indiceUnico
+}
+
+var (
+	tabelaRequisicaoPix = tabelaCifrada{
+		nome:    "requisicao_pix",
+		colunas: []string{"chave_busca", "vinculos"},
+		jsonb:   map[string]bool{"vinculos": true},
+		indices: []indiceColuna{{IndiceChave, "chave_busca"}},
+	}
+	tabelaRequisicaoCCS = tabelaCifrada{
+		nome:    "requisicao_relacionamento_ccs",
+		colunas: []string{"cpf_cnpj_consulta", "cpf_cnpj", "nome"},
+		indices: []indiceColuna{{IndiceDocumento, "cpf_cnpj_consulta"}, {IndiceDocumento, "cpf_cnpj"}},
+	}
+	tabelaChavePix = tabelaCifrada{
+		nome: "chave_pix",
+		colunas: []string{"chave", "cpf_cnpj", "nome_proprietario", "nome_fantasia", "agencia", "numero_conta",
+			"cpf_cnpj_busca", "nome_proprietario_busca"},
+		indices: []indiceColuna{
+			{IndiceChave, "chave"}, {IndiceDocumento, "cpf_cnpj"}, {IndiceDocumento, "cpf_cnpj_busca"}, {IndiceConta, "numero_conta"},
+		},
+	}
+	tabelaEventoChavePix = tabelaCifrada{
+		nome:    "evento_chave_pix",
+		colunas: []string{"chave", "cpf_cnpj", "nome_proprietario", "nome_fantasia", "agencia", "numero_conta"},
+		indices: []indiceColuna{{IndiceChave, "chave"}, {IndiceDocumento, "cpf_cnpj"}, {IndiceConta, "numero_conta"}},
+	}
+	tabelaRelacionamentoCCS = tabelaCifrada{
+		nome:    "relacionamento_ccs",
+		colunas: []string{"id_pessoa", "nome_pessoa"},
+		indices: []indiceColuna{{IndiceDocumento, "id_pessoa"}},
+	}
+	tabelaBemDireitoValorCCS = tabelaCifrada{
+		nome:    "bem_direito_valor_ccs",
+		colunas: []string{"agencia", "conta", "nome_pessoa"},
+		indices: []indiceColuna{{IndiceConta, "conta"}},
+	}
+	tabelaVinculadosBDVCCS = tabelaCifrada{
+		nome:    "vinculados_bdv_ccs",
+		colunas: []string{"id_pessoa", "nome_pessoa", "nome_pessoa_receita"},
+		indices: []indiceColuna{{IndiceDocumento, "id_pessoa"}},
+	}
+
+	tabelaPessoa = tabelaCifrada{
+		nome:    "pessoa",
+		colunas: []string{"documento", "nome_principal"},
+		unico:   &indiceUnico{IndiceDocumento, []string{"documento"}},
+	}
+	tabelaConta = tabelaCifrada{
+		nome:    "conta",
+		colunas: []string{"ispb", "agencia", "numero"},
+		unico:   &indiceUnico{IndiceContaCompleta, []string{"ispb", "agencia", "numero"}},
+	}
+
+	// tabelasCifradas são percorridas pela recifragem
+	tabelasCifradas = []tabelaCifrada{
+		tabelaRequisicaoPix, tabelaChavePix, tabelaEventoChavePix,
+		tabelaRequisicaoCCS, tabelaRelacionamentoCCS, tabelaBemDireitoValorCCS, tabelaVinculadosBDVCCS,
+		tabelaPessoa, tabelaConta,
+	}
+)
+
+// contexto vincula o texto cifrado à linha, impedindo que seja copiado para outra
+func (t tabelaCifrada) contexto(id int) []byte {
+	return []byte(t.nome + ":" + strconv.Itoa(id))
+}
+
+// emClaro é a condição das linhas com alguma coluna sensível em claro,
+// inclusive as cifradas antes de a coluna passar a ser cifrada
+func (t tabelaCifrada) emClaro() string {
+	return "(dados_cifrados IS NULL OR num_nonnulls(" + strings.Join(t.colunas, ", ") + ") > 0)"
+}
+
+// jsonCifrado monta, para as consultas que agregam linhas em JSON, o campo
+// "cifrado" lido em models.DadosCifrados
+func jsonCifrado(alias string) string {
+	return fmt.Sprintf(`CASE WHEN %[1]s.dados_cifrados IS NULL THEN NULL
+		ELSE json_build_object('chave', %[1]s.id_chave_dados, 'dados', encode(%[1]s.dados_cifrados, 'base64')) END`, alias)
+}
+
+// gravarDadosSensiveis grava as colunas sensíveis de uma linha já inserida:
+// cifradas com a chave de dados atual ou, com chaves nil, em claro
+func gravarDadosSensiveis(tx *sql.Tx, chaves *chavesDados, t tabelaCifrada, id int, campos map[string]string) error {
+	args := []interface{}{id}
+	var sets []string
+	if chaves == nil {
+		for _, coluna := range t.colunas {
+			args = append(args, campos[coluna])
+			valor := "$" + strconv.Itoa(len(args))
+			if t.jsonb[coluna] {
+				valor = "NULLIF(" + valor + ", '')::jsonb"
+			}
+			sets = append(sets, coluna+" = "+valor)
+		}
+		sets = append(sets, "id_chave_dados = NULL", "dados_cifrados = NULL")
+		if len(t.indices) > 0 {
+			sets = append(sets, "indices = NULL")
+		}
+		if t.unico != nil {
+			sets = append(sets, "indice_unico = NULL")
+		}
+	} else {
+		conteudo, err := json.Marshal(campos)
+		if err != nil {
+			return err
+		}
+		cifrado, err := chaves.dados[chaves.atual].Cifrar(conteudo, t.contexto(id))
+		if err != nil {
+			return err
+		}
+		for _, coluna := range t.colunas {
+			sets = append(sets, coluna+" = NULL")
+		}
+		args = append(args, chaves.atual, cifrado)
+		sets = append(sets, "id_chave_dados = $2", "dados_cifrados = $3")
+		if len(t.indices) > 0 {
+			args = append(args, chaves.indicesDe(t, campos))
+			sets = append(sets, "indices = $"+strconv.Itoa(len(args)))
+		}
+		if t.unico != nil {
+			args = append(args, chaves.indiceUnicoDe(t, campos))
+			sets = append(sets, "indice_unico = $"+strconv.Itoa(len(args)))
+		}
+	}
+
+	_, err := tx.Exec(`UPDATE `+t.nome+` SET `+strings.Join(sets, ", ")+` WHERE id = $1`, args...)
+	return err
+}
+
+// decifrarCampos decifra o conteúdo de uma linha, relendo as chaves do banco
+// se ela estiver numa chave criada depois da última carga
+func decifrarCampos(t tabelaCifrada, id int, cifrado *models.DadosCifrados) (map[string]string, error) {
+	chaves := cifraDados.Load()
+	if chaves == nil {
+		return nil, ErrCifraDadosInativa
+	}
+	cifrador, ok := chaves.dados[cifrado.Chave]
+	if !ok {
+		if err := RecarregarCifraDados(); err != nil {
+			return nil, err
+		}
+		if cifrador, ok = cifraDados.Load().dados[cifrado.Chave]; !ok {
+			return nil, fmt.Errorf("chave de dados %d não encontrada", cifrado.Chave)
+		}
+	}
+
+	conteudo, err := cifrador.Decifrar(cifrado.Dados, t.contexto(id))
+	if err != nil {
+		return nil, fmt.Errorf("%s %d: %w", t.nome, id, err)
+	}
+	campos := map[string]string{}
+	if err := json.Unmarshal(conteudo, &campos); err != nil {
+		return nil, err
+	}
+	return campos, nil
+}
+
+// abrirCampos decifra a linha lida do banco, quando cifrada, e preenche os
+// campos do modelo, descartando o conteúdo cifrado
+func abrirCampos(t tabelaCifrada, id int, cifrado **models.DadosCifrados, campos map[string]*string) error {
+	if *cifrado == nil {
+		return nil
+	}
+	valores, err := decifrarCampos(t, id, *cifrado)
+	if err != nil {
+		return err
+	}
+	for coluna, campo := range campos {
+		*campo = valores[coluna]
+	}
+	*cifrado = nil
+	return nil
+}
+
+// valoresCampos lê os campos do modelo para a gravação
+func valoresCampos(campos map[string]*string) map[string]string {
+	valores := make(map[string]string, len(campos))
+	for coluna, campo := range campos {
+		valores[coluna] = *campo
+	}
+	return valores
+}
+
+// indicesDe calcula os índices cegos dos valores pesquisáveis de uma linha
+func (c *chavesDados) indicesDe(t tabelaCifrada, campos map[string]string) pq.ByteaArray {
+	indices := pq.ByteaArray{}
+	vistos := map[string]bool{}
+	for _, ic := range t.indices {
+		indice := c.indiceCego(ic.tipo, campos[ic.coluna])
+		if indice == nil || vistos[string(indice)] {
+			continue
+		}
+		vistos[string(indice)] = true
+		indices = append(indices, indice)
+	}
+	return indices
+}
+
+// indiceUnicoDe calcula o índice cego que identifica a linha
+func (c *chavesDados) indiceUnicoDe(t tabelaCifrada, campos map[string]string) []byte {
+	valores := make([]string, len(t.unico.colunas))
+	for i, coluna := range t.unico.colunas {
+		valores[i] = campos[coluna]
+	}
+	return c.indiceCego(t.unico.tipo, strings.Join(valores, "|"))
+}
+
+func (c *chavesDados) indiceCego(tipo, valor string) []byte {
+	switch tipo {
+	case IndiceDocumento:
+		valor = NormalizarDocumento(valor)
+	case IndiceConta:
+		valor = NormalizarNumeroConta(valor)
+	default:
+		valor = strings.ToLower(strings.TrimSpace(valor))
+	}
+	if valor == "" {
+		return nil
+	}
+	return cripto.IndiceCego(c.indice, tipo, valor)
+}
+
+// indicesBusca calcula os índices cegos que um termo de busca pode ter como
+// documento, chave PIX ou conta. Sem a cifragem ativa retorna vazio.
+func indicesBusca(termo string) pq.ByteaArray {
+	chaves := cifraDados.Load()
+	if chaves == nil {
+		return pq.ByteaArray{}
+	}
+	tipos := []string{IndiceChave}
+	if strings.ContainsAny(termo, "0123456789") {
+		tipos = append(tipos, IndiceDocumento, IndiceConta)
+	}
+
+	indices := pq.ByteaArray{}
+	for _, tipo := range tipos {
+		if indice := chaves.indiceCego(tipo, termo); indice != nil {
+			indices = append(indices, indice)
+		}
+	}
+	return indices
+}
+
+// condicaoIndice monta a igualdade sobre uma coluna sensível: pelo valor em
+// claro ou, nas linhas cifradas, pelo índice cego do tipo informado
+func condicaoIndice(c *consultaSQL, alias, coluna, tipo, valor string) string {
+	indices := pq.ByteaArray{}
+	if chaves := cifraDados.Load(); chaves != nil {
+		if indice := chaves.indiceCego(tipo, valor); indice != nil {
+			indices = append(indices, indice)
+		}
+	}
+	return fmt.Sprintf("(%[1]s.%[2]s = %[3]s OR %[1]s.indices && %[4]s::bytea[])", alias, coluna, c.arg(valor), c.arg(indices))
+}
+
+// indiceUnicoBusca calcula o indice_unico procurado; nil sem a cifragem ativa
+func indiceUnicoBusca(t tabelaCifrada, campos map[string]string) []byte {
+	chaves := cifraDados.Load()
+	if chaves == nil {
+		return nil
+	}
+	return chaves.indiceUnicoDe(t, campos)
+}
+
+// Campos sensíveis de cada modelo, por coluna
+
+func camposChavePix(c *models.ChavePix) map[string]*string {
+	return map[string]*string{
+		"chave": &c.Chave, "cpf_cnpj": &c.CPFCNPJ, "nome_proprietario": &c.NomeProprietario,
+		"nome_fantasia": &c.NomeFantasia, "agencia": &c.Agencia, "numero_conta": &c.NumeroConta,
+		"cpf_cnpj_busca": &c.CPFCNPJBusca, "nome_proprietario_busca": &c.NomeProprietarioBusca,
+	}
+}
+
+func camposEventoChavePix(e *models.EventoChavePix) map[string]*string {
+	return map[string]*string{
+		"chave": &e.Chave, "cpf_cnpj": &e.CPFCNPJ, "nome_proprietario": &e.NomeProprietario,
+		"nome_fantasia": &e.NomeFantasia, "agencia": &e.Agencia, "numero_conta": &e.NumeroConta,
+	}
+}
+
+func camposRequisicaoCCS(r *models.RequisicaoRelacionamentoCCS) map[string]*string {
+	return map[string]*string{"cpf_cnpj_consulta": &r.CPFCNPJConsulta, "cpf_cnpj": &r.CPFCNPJ, "nome": &r.Nome}
+}
+
+func camposRelacionamentoCCS(r *models.RelacionamentoCCS) map[string]*string {
+	return map[string]*string{"id_pessoa": &r.IDPessoa, "nome_pessoa": &r.NomePessoa}
+}
+
+func camposBemDireitoValorCCS(b *models.BemDireitoValorCCS) map[string]*string {
+	return map[string]*string{"agencia": &b.Agencia, "conta": &b.Conta, "nome_pessoa": &b.NomePessoa}
+}
+
+func camposVinculadosBDVCCS(v *models.VinculadosBDVCCS) map[string]*string {
+	return map[string]*string{"id_pessoa": &v.IDPessoa, "nome_pessoa": &v.NomePessoa, "nome_pessoa_receita": &v.NomePessoaReceita}
+}
+
+// abrirChavesPix decifra as chaves de uma requisição e os seus eventos
+func abrirChavesPix(chaves []models.ChavePix) error {
+	for i := range chaves {
+		c := &chaves[i]
+		if err := abrirCampos(tabelaChavePix, c.ID, &c.Cifrado, camposChavePix(c)); err != nil {
+			return err
+		}
+		for j := range c.EventosVinculo {
+			e := &c.EventosVinculo[j]
+			if err := abrirCampos(tabelaEventoChavePix, e.ID, &e.Cifrado, camposEventoChavePix(e)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// abrirRelacionamentosCCS decifra os relacionamentos, os bens e os vinculados
+func abrirRelacionamentosCCS(relacionamentos []models.RelacionamentoCCS) error {
+	for i := range relacionamentos {
+		r := &relacionamentos[i]
+		if err := abrirCampos(tabelaRelacionamentoCCS, r.ID, &r.Cifrado, camposRelacionamentoCCS(r)); err != nil {
+			return err
+		}
+		for j := range r.BemDireitoValorCCS {
+			b := &r.BemDireitoValorCCS[j]
+			if err := abrirCampos(tabelaBemDireitoValorCCS, b.ID, &b.Cifrado, camposBemDireitoValorCCS(b)); err != nil {
+				return err
+			}
+			for k := range b.Vinculados {
+				v := &b.Vinculados[k]
+				if err := abrirCampos(tabelaVinculadosBDVCCS, v.ID, &v.Cifrado, camposVinculadosBDVCCS(v)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// abrirRequisicaoPix decifra a chave buscada e os vínculos de uma requisição
+// PIX, quando cifrados, e devolve o JSON dos vínculos. As linhas cifradas
+// antes de chave_busca entrar na cifragem ainda a trazem em claro.
+func abrirRequisicaoPix(req *models.RequisicaoPix, vinculosJSON []byte, idChave sql.NullInt64, dadosCifrados []byte) ([]byte, error) {
+	if dadosCifrados == nil {
+		return vinculosJSON, nil
+	}
+	campos, err := decifrarCampos(tabelaRequisicaoPix, req.ID, &models.DadosCifrados{Chave: int(idChave.Int64), Dados: dadosCifrados})
+	if err != nil {
+		return nil, err
+	}
+	if chave, ok := campos["chave_busca"]; ok {
+		req.ChaveBusca = chave
+	}
+	if campos["vinculos"] == "" {
+		return nil, nil
+	}
+	return []byte(campos["vinculos"]), nil
+}
+
+// abrirRequisicaoCCS decifra o documento consultado, o documento e o nome
+// devolvidos pelo BACEN de uma requisição CCS
+func abrirRequisicaoCCS(req *models.RequisicaoRelacionamentoCCS, idChave sql.NullInt64, dadosCifrados []byte) error {
+	if dadosCifrados == nil {
+		return nil
+	}
+	cifrado := &models.DadosCifrados{Chave: int(idChave.Int64), Dados: dadosCifrados}
+	return abrirCampos(tabelaRequisicaoCCS, req.ID, &cifrado, camposRequisicaoCCS(req))
+}
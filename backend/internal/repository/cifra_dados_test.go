@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/tassyosilva/consultapix/internal/cripto"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+// ativarCifraDadosTeste ativa a cifragem com chaves geradas no teste, sem
+// banco, e restaura o estado anterior ao final
+func ativarCifraDadosTeste(t *testing.T) *chavesDados {
+	t.Helper()
+	chaveDados, err := cripto.NovaChave()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chaveIndice, err := cripto.NovaChave()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cifrador, err := cripto.NovoCifrador(chaveDados)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chaves := &chavesDados{dados: map[int]*cripto.Cifrador{1: cifrador}, atual: 1, indice: chaveIndice}
+	anteriores := cifraDados.Swap(chaves)
+	t.Cleanup(func() { cifraDados.Store(anteriores) })
+	return chaves
+}
+
+func TestIndiceCegoNormalizado(t *testing.T) {
+	chaves := ativarCifraDadosTeste(t)
+
+	casos := []struct {
+		nome  string
+		tipo  string
+		valor string
+		igual string
+	}{
+		{"CPF formatado", IndiceDocumento, "123.456.789-09", "12345678909"},
+		{"CPF com espaços", IndiceDocumento, " 123 456 789 09 ", "12345678909"},
+		{"CPF sem zeros à esquerda", IndiceDocumento, "1234567890", "01234567890"},
+		{"CNPJ formatado", IndiceDocumento, "12.345.678/0001-95", "12345678000195"},
+		{"conta com dígito", IndiceConta, "00012345-X", "12345x"},
+		{"chave PIX", IndiceChave, "  Fulano@Exemplo.COM ", "fulano@exemplo.com"},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			a, b := chaves.indiceCego(c.tipo, c.valor), chaves.indiceCego(c.tipo, c.igual)
+			if a == nil || !bytes.Equal(a, b) {
+				t.Fatalf("índice de %q difere do de %q", c.valor, c.igual)
+			}
+		})
+	}
+
+	if bytes.Equal(chaves.indiceCego(IndiceDocumento, "12345678909"), chaves.indiceCego(IndiceConta, "12345678909")) {
+		t.Error("documento e conta com os mesmos dígitos geraram o mesmo índice")
+	}
+	for _, valor := range []string{"", "   ", "000.000.000-00"} {
+		if indice := chaves.indiceCego(IndiceDocumento, valor); indice != nil {
+			t.Errorf("documento %q gerou índice", valor)
+		}
+	}
+}
+
+func TestIndicesDeLinha(t *testing.T) {
+	chaves := ativarCifraDadosTeste(t)
+
+	// cpf_cnpj e cpf_cnpj_busca trazem o mesmo documento em formatos diferentes
+	indices := chaves.indicesDe(tabelaChavePix, map[string]string{
+		"chave": "123.456.789-09", "cpf_cnpj": "123.456.789-09", "cpf_cnpj_busca": "12345678909", "numero_conta": "",
+	})
+	if len(indices) != 2 {
+		t.Fatalf("%d índices, esperados 2 (chave e documento, sem repetição nem conta vazia)", len(indices))
+	}
+
+	// A busca pelo CPF sem formatação encontra a linha gravada com ele formatado
+	busca := indicesBusca("12345678909")
+	encontrado := false
+	for _, b := range busca {
+		for _, i := range indices {
+			encontrado = encontrado || bytes.Equal(b, i)
+		}
+	}
+	if !encontrado {
+		t.Fatal("índices da busca não alcançam os da linha")
+	}
+}
+
+func TestDecifrarCampos(t *testing.T) {
+	chaves := ativarCifraDadosTeste(t)
+	campos := map[string]string{"cpf_cnpj": "123.456.789-09", "nome_proprietario": "Fulano de Tal"}
+
+	cifrado, err := cifrarCamposTeste(chaves, tabelaChavePix, 7, campos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	abertos, err := decifrarCampos(tabelaChavePix, 7, cifrado)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if abertos["cpf_cnpj"] != campos["cpf_cnpj"] || abertos["nome_proprietario"] != campos["nome_proprietario"] {
+		t.Fatalf("campos decifrados %v, esperados %v", abertos, campos)
+	}
+
+	// O conteúdo está preso à linha e à tabela em que foi gravado
+	if _, err := decifrarCampos(tabelaChavePix, 8, cifrado); !errors.Is(err, cripto.ErrDadosCorrompidos) {
+		t.Fatalf("outra linha: decifrarCampos = %v, esperado ErrDadosCorrompidos", err)
+	}
+	if _, err := decifrarCampos(tabelaEventoChavePix, 7, cifrado); !errors.Is(err, cripto.ErrDadosCorrompidos) {
+		t.Fatalf("outra tabela: decifrarCampos = %v, esperado ErrDadosCorrompidos", err)
+	}
+
+	cifraDados.Store(nil)
+	if _, err := decifrarCampos(tabelaChavePix, 7, cifrado); !errors.Is(err, ErrCifraDadosInativa) {
+		t.Fatalf("sem a cifragem ativa: decifrarCampos = %v, esperado ErrCifraDadosInativa", err)
+	}
+}
+
+// cifrarCamposTeste cifra os campos como gravarDadosSensiveis, sem o banco
+func cifrarCamposTeste(chaves *chavesDados, tabela tabelaCifrada, id int, campos map[string]string) (*models.DadosCifrados, error) {
+	conteudo, err := json.Marshal(campos)
+	if err != nil {
+		return nil, err
+	}
+	dados, err := chaves.dados[chaves.atual].Cifrar(conteudo, tabela.contexto(id))
+	if err != nil {
+		return nil, err
+	}
+	return &models.DadosCifrados{Chave: chaves.atual, Dados: dados}, nil
+}
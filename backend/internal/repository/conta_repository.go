@@ -0,0 +1,375 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tassyosilva/consultapix/internal/database"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+// Papéis de uma pessoa em relação à conta
+const (
+	PapelTitular   = "titular"
+	PapelVinculado = "vinculado"
+)
+
+// ErrContaNaoEncontrada indica que a conta não existe ou não aparece em
+// nenhuma requisição visível ao usuário
+var ErrContaNaoEncontrada = errors.New("conta não encontrada")
+
+type ContaRepository struct {
+	DB *sql.DB
+}
+
+func NewContaRepository() *ContaRepository {
+	return &ContaRepository{
+		DB: database.GetDB(),
+	}
+}
+
+// NormalizarISPB aceita o ISPB do participante PIX ou o CNPJ do participante
+// CCS, cuja raiz de 8 dígitos é o ISPB. Deve seguir consultapix_normalizar_ispb
+// (migração 0007).
+func NormalizarISPB(valor string) string {
+	var b strings.Builder
+	for _, r := range valor {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	digitos := b.String()
+
+	switch {
+	case digitos == "":
+		return ""
+	case len(digitos) <= 8:
+		return strings.Repeat("0", 8-len(digitos)) + digitos
+	case len(digitos) <= 14:
+		return (strings.Repeat("0", 14-len(digitos)) + digitos)[:8]
+	default:
+		return ""
+	}
+}
+
+// NormalizarNumeroConta remove pontuação e zeros à esquerda de agências e
+// números de conta. Deve seguir consultapix_normalizar_conta (migração 0007).
+func NormalizarNumeroConta(valor string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(valor) {
+		if (r >= '0' && r <= '9') || (r >= 'A' && r <= 'Z') {
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimLeft(b.String(), "0")
+}
+
+// vincularConta garante que a conta exista e atualiza tipo, instituição e datas
+// de ocorrência. Retorna nil quando faltam instituição ou número da conta. Com
+// a cifragem ativa instituição, agência e número vão cifrados, e a conta é
+// encontrada pelo índice cego dos três.
+func vincularConta(tx *sql.Tx, participante, agencia, numero, tipoConta, instituicao string, visto time.Time) (*int, error) {
+	ispb := NormalizarISPB(participante)
+	agencia = NormalizarNumeroConta(agencia)
+	numero = NormalizarNumeroConta(numero)
+	if ispb == "" || numero == "" {
+		return nil, nil
+	}
+
+	instituicao = strings.TrimSpace(instituicao)
+	if instituicao == bancoNaoInformado {
+		instituicao = ""
+	}
+
+	campos := map[string]string{"ispb": ispb, "agencia": agencia, "numero": numero}
+	chaves := cifraDados.Load()
+	conflito := "ispb, agencia, numero"
+	var indice []byte
+	if chaves != nil {
+		conflito = "indice_unico"
+		indice = chaves.indiceUnicoDe(tabelaConta, campos)
+		// A conta gravada em claro antes da cifragem passa a ser encontrada pelo índice
+		_, err := tx.Exec(`
+			UPDATE conta SET indice_unico = $1
+			WHERE ispb = $2 AND agencia = $3 AND numero = $4 AND indice_unico IS NULL
+		`, indice, ispb, agencia, numero)
+		if err != nil {
+			return nil, err
+		}
+	}
+	emClaro := func(valor string) sql.NullString {
+		return sql.NullString{String: valor, Valid: chaves == nil}
+	}
+
+	var id int
+	err := tx.QueryRow(`
+		INSERT INTO conta (ispb, agencia, numero, indice_unico, tipo_conta, nome_instituicao, primeira_ocorrencia, ultima_ocorrencia)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $7)
+		ON CONFLICT (`+conflito+`) DO UPDATE SET
+			tipo_conta = COALESCE(EXCLUDED.tipo_conta, conta.tipo_conta),
+			nome_instituicao = COALESCE(EXCLUDED.nome_instituicao, conta.nome_instituicao),
+			primeira_ocorrencia = LEAST(conta.primeira_ocorrencia, EXCLUDED.primeira_ocorrencia),
+			ultima_ocorrencia = GREATEST(conta.ultima_ocorrencia, EXCLUDED.ultima_ocorrencia)
+		RETURNING id
+	`, emClaro(ispb), emClaro(agencia), emClaro(numero), indice, strings.TrimSpace(tipoConta), instituicao, visto).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := gravarDadosSensiveis(tx, chaves, tabelaConta, id, campos); err != nil {
+		return nil, err
+	}
+
+	return &id, nil
+}
+
+// BuscarContaPorNumero localiza o ID da conta pela instituição (ISPB ou CNPJ),
+// agência e número, em qualquer formatação, em claro ou pelo índice cego
+func (r *ContaRepository) BuscarContaPorNumero(participante, agencia, numero string) (int, error) {
+	campos := map[string]string{
+		"ispb": NormalizarISPB(participante), "agencia": NormalizarNumeroConta(agencia), "numero": NormalizarNumeroConta(numero),
+	}
+	var id int
+	err := r.DB.QueryRow(`
+		SELECT id FROM conta
+		WHERE (ispb = $1 AND agencia = $2 AND numero = $3) OR indice_unico = $4
+	`, campos["ispb"], campos["agencia"], campos["numero"], indiceUnicoBusca(tabelaConta, campos)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrContaNaoEncontrada
+	}
+	return id, err
+}
+
+// fontesConta monta uma CTE "fontes" com as linhas visíveis no escopo que citam
+// a conta, cada uma com a pessoa que ela associa à conta
+func fontesConta(c *consultaSQL, escopo Escopo, idConta int) string {
+	id := c.arg(idConta)
+	return `
+		WITH fontes AS (
+			SELECT 'chave_pix' AS tipo, 'pix' AS origem, x.id, req.id AS id_requisicao, req.data AS data,
+				x.chave, x.tipo_chave, x.status, x.pessoa_id, '` + PapelTitular + `' AS papel,
+				x.id_chave_dados, x.dados_cifrados
+			FROM chave_pix x
+			JOIN requisicao_pix req ON req.id = x.id_requisicao
+			WHERE x.conta_id = ` + id + ` AND ` + escopo.condicao(c, "req") + `
+			UNION ALL
+			SELECT 'evento_chave_pix', 'pix', x.id, req.id, req.data,
+				x.chave, x.tipo_chave, NULL, x.pessoa_id, '` + PapelTitular + `',
+				x.id_chave_dados, x.dados_cifrados
+			FROM evento_chave_pix x
+			JOIN chave_pix cp ON cp.id = x.id_chave
+			JOIN requisicao_pix req ON req.id = cp.id_requisicao
+			WHERE x.conta_id = ` + id + ` AND ` + escopo.condicao(c, "req") + `
+			UNION ALL
+			SELECT 'bem_direito_valor_ccs', 'ccs', x.id, req.id, COALESCE(req.data_requisicao, TIMESTAMPTZ 'epoch'),
+				NULL, NULL, NULL, rc.pessoa_id, '` + PapelTitular + `', NULL, NULL
+			FROM bem_direito_valor_ccs x
+			JOIN relacionamento_ccs rc ON rc.id = x.id_relacionamento
+			JOIN requisicao_relacionamento_ccs req ON req.id = rc.id_requisicao
+			WHERE x.conta_id = ` + id + ` AND ` + escopo.condicao(c, "req") + `
+			UNION ALL
+			SELECT 'vinculados_bdv_ccs', 'ccs', v.id, req.id, COALESCE(req.data_requisicao, TIMESTAMPTZ 'epoch'),
+				NULL, NULL, NULL, v.pessoa_id, '` + PapelVinculado + `', NULL, NULL
+			FROM vinculados_bdv_ccs v
+			JOIN bem_direito_valor_ccs x ON x.id = v.id_bdv
+			JOIN relacionamento_ccs rc ON rc.id = x.id_relacionamento
+			JOIN requisicao_relacionamento_ccs req ON req.id = rc.id_requisicao
+			WHERE x.conta_id = ` + id + ` AND ` + escopo.condicao(c, "req") + `
+		)`
+}
+
+// BuscarDetalheConta responde quais chaves e quais pessoas apontam para a conta,
+// considerando apenas as requisições visíveis no escopo
+func (r *ContaRepository) BuscarDetalheConta(escopo Escopo, id int) (*models.DetalheConta, error) {
+	var detalhe models.DetalheConta
+	var tipoConta, nomeInstituicao sql.NullString
+	var idChaveDados sql.NullInt64
+	var dadosCifrados []byte
+	err := r.DB.QueryRow(`
+		SELECT id, COALESCE(ispb, ''), COALESCE(agencia, ''), COALESCE(numero, ''), tipo_conta, nome_instituicao,
+			primeira_ocorrencia, ultima_ocorrencia, id_chave_dados, dados_cifrados
+		FROM conta
+		WHERE id = $1
+	`, id).Scan(
+		&detalhe.ID, &detalhe.ISPB, &detalhe.Agencia, &detalhe.Numero, &tipoConta, &nomeInstituicao,
+		&detalhe.PrimeiraOcorrencia, &detalhe.UltimaOcorrencia, &idChaveDados, &dadosCifrados,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrContaNaoEncontrada
+	}
+	if err != nil {
+		return nil, err
+	}
+	if dadosCifrados != nil {
+		cifrado := &models.DadosCifrados{Chave: int(idChaveDados.Int64), Dados: dadosCifrados}
+		err = abrirCampos(tabelaConta, detalhe.ID, &cifrado, map[string]*string{
+			"ispb": &detalhe.ISPB, "agencia": &detalhe.Agencia, "numero": &detalhe.Numero,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	detalhe.TipoConta = tipoConta.String
+	detalhe.NomeInstituicao = nomeInstituicao.String
+
+	if detalhe.Fontes, err = r.buscarFontes(escopo, id); err != nil {
+		return nil, err
+	}
+	if len(detalhe.Fontes) == 0 && !escopo.Total() {
+		return nil, ErrContaNaoEncontrada
+	}
+	if detalhe.Chaves, err = r.buscarChaves(escopo, id); err != nil {
+		return nil, err
+	}
+	if detalhe.Pessoas, err = r.buscarPessoas(escopo, id); err != nil {
+		return nil, err
+	}
+
+	return &detalhe, nil
+}
+
+func (r *ContaRepository) buscarFontes(escopo Escopo, idConta int) ([]models.FonteConta, error) {
+	c := &consultaSQL{}
+	rows, err := r.DB.Query(fontesConta(c, escopo, idConta)+`
+		SELECT tipo, origem, id, id_requisicao, data
+		FROM fontes
+		ORDER BY data DESC, tipo, id
+	`, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fontes := make([]models.FonteConta, 0)
+	for rows.Next() {
+		var f models.FonteConta
+		if err := rows.Scan(&f.Tipo, &f.Origem, &f.ID, &f.IDRequisicao, &f.Data); err != nil {
+			return nil, err
+		}
+		fontes = append(fontes, f)
+	}
+
+	return fontes, rows.Err()
+}
+
+// buscarChaves agrupa por chave as linhas de chave PIX e de evento. O
+// agrupamento é feito aqui porque a chave pode estar cifrada.
+func (r *ContaRepository) buscarChaves(escopo Escopo, idConta int) ([]models.ChaveConta, error) {
+	c := &consultaSQL{}
+	rows, err := r.DB.Query(fontesConta(c, escopo, idConta)+`
+		SELECT tipo, id, data, COALESCE(chave, ''), COALESCE(tipo_chave, ''), status, id_chave_dados, dados_cifrados
+		FROM fontes
+		WHERE tipo IN ('chave_pix', 'evento_chave_pix')
+		ORDER BY data DESC
+	`, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chaves := make([]models.ChaveConta, 0)
+	porChave := map[string]int{}
+	for rows.Next() {
+		var tipo, chave, tipoChave string
+		var id int
+		var data time.Time
+		var status sql.NullString
+		var idChaveDados sql.NullInt64
+		var dadosCifrados []byte
+		if err := rows.Scan(&tipo, &id, &data, &chave, &tipoChave, &status, &idChaveDados, &dadosCifrados); err != nil {
+			return nil, err
+		}
+		if dadosCifrados != nil {
+			tabela := tabelaChavePix
+			if tipo == "evento_chave_pix" {
+				tabela = tabelaEventoChavePix
+			}
+			campos, err := decifrarCampos(tabela, id, &models.DadosCifrados{Chave: int(idChaveDados.Int64), Dados: dadosCifrados})
+			if err != nil {
+				return nil, err
+			}
+			chave = campos["chave"]
+		}
+		if chave == "" {
+			continue
+		}
+
+		i, ok := porChave[chave]
+		if !ok {
+			i = len(chaves)
+			porChave[chave] = i
+			chaves = append(chaves, models.ChaveConta{Chave: chave, PrimeiraOcorrencia: data, UltimaOcorrencia: data})
+		}
+		ch := &chaves[i]
+		if tipoChave > ch.TipoChave {
+			ch.TipoChave = tipoChave
+		}
+		// As linhas vêm da mais recente para a mais antiga
+		if ch.Status == "" && status.Valid {
+			ch.Status = status.String
+		}
+		if !slices.Contains(ch.Fontes, tipo) {
+			ch.Fontes = append(ch.Fontes, tipo)
+		}
+		ch.PrimeiraOcorrencia = data
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range chaves {
+		sort.Strings(chaves[i].Fontes)
+	}
+	sort.SliceStable(chaves, func(i, j int) bool {
+		if !chaves[i].UltimaOcorrencia.Equal(chaves[j].UltimaOcorrencia) {
+			return chaves[i].UltimaOcorrencia.After(chaves[j].UltimaOcorrencia)
+		}
+		return chaves[i].Chave < chaves[j].Chave
+	})
+	return chaves, nil
+}
+
+func (r *ContaRepository) buscarPessoas(escopo Escopo, idConta int) ([]models.PessoaConta, error) {
+	c := &consultaSQL{}
+	rows, err := r.DB.Query(fontesConta(c, escopo, idConta)+`
+		SELECT p.id, COALESCE(p.documento, ''), COALESCE(p.nome_principal, ''), f.papel,
+			array_agg(DISTINCT f.tipo), MIN(f.data), MAX(f.data), p.id_chave_dados, p.dados_cifrados
+		FROM fontes f
+		JOIN pessoa p ON p.id = f.pessoa_id
+		GROUP BY p.id, f.papel
+		ORDER BY f.papel, MAX(f.data) DESC, p.id
+	`, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pessoas := make([]models.PessoaConta, 0)
+	for rows.Next() {
+		var p models.PessoaConta
+		var idChaveDados sql.NullInt64
+		var dadosCifrados []byte
+		err := rows.Scan(
+			&p.ID, &p.Documento, &p.Nome, &p.Papel, pq.Array(&p.Fontes), &p.PrimeiraOcorrencia, &p.UltimaOcorrencia,
+			&idChaveDados, &dadosCifrados,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if dadosCifrados != nil {
+			cifrado := &models.DadosCifrados{Chave: int(idChaveDados.Int64), Dados: dadosCifrados}
+			err = abrirCampos(tabelaPessoa, p.ID, &cifrado, map[string]*string{"documento": &p.Documento, "nome_principal": &p.Nome})
+			if err != nil {
+				return nil, err
+			}
+		}
+		pessoas = append(pessoas, p)
+	}
+
+	return pessoas, rows.Err()
+}
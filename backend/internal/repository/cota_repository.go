@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/tassyosilva/consultapix/internal/database"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+// Operações do BACEN sujeitas a cota
+const (
+	OperacaoPixChave          = "pix_chave"
+	OperacaoPixCPFCNPJ        = "pix_cpf_cnpj"
+	OperacaoCCSRelacionamento = "ccs_relacionamento"
+	OperacaoCCSDetalhamento   = "ccs_detalhamento"
+)
+
+// Escopos de limite de cota
+const (
+	EscopoCotaUsuario = "usuario"
+	EscopoCotaLotacao = "lotacao"
+)
+
+// AlvoCotaPadrao é o alvo do limite aplicado a quem não tem limite próprio
+const AlvoCotaPadrao = "*"
+
+// OperacoesCota lista as operações na ordem em que o uso é apresentado
+var OperacoesCota = []string{OperacaoPixChave, OperacaoPixCPFCNPJ, OperacaoCCSRelacionamento, OperacaoCCSDetalhamento}
+
+// colunasEscopoCota mapeia o escopo para a coluna de uso_cota que o identifica
+var colunasEscopoCota = map[string]string{
+	EscopoCotaUsuario: "cpf_usuario",
+	EscopoCotaLotacao: "lotacao",
+}
+
+// ErrLimiteNaoEncontrado indica que o limite de cota não existe
+var ErrLimiteNaoEncontrado = errors.New("limite de cota não encontrado")
+
+// CotaExcedidaError indica que a consulta ultrapassaria um limite de cota
+type CotaExcedidaError struct {
+	Escopo   string
+	Operacao string
+	Periodo  string // "diario" ou "mensal"
+	Limite   int
+}
+
+func (e *CotaExcedidaError) Error() string {
+	escopo := "do usuário"
+	if e.Escopo == EscopoCotaLotacao {
+		escopo = "da lotação"
+	}
+	periodo := "diário"
+	if e.Periodo == "mensal" {
+		periodo = "mensal"
+	}
+	return fmt.Sprintf("limite %s de %d consultas %s %s atingido", periodo, e.Limite, e.Operacao, escopo)
+}
+
+// OperacaoCotaValida indica se a operação é uma das sujeitas a cota
+func OperacaoCotaValida(operacao string) bool {
+	for _, o := range OperacoesCota {
+		if o == operacao {
+			return true
+		}
+	}
+	return false
+}
+
+// EscopoCotaValido indica se o escopo é usuario ou lotacao
+func EscopoCotaValido(escopo string) bool {
+	_, ok := colunasEscopoCota[escopo]
+	return ok
+}
+
+type CotaRepository struct {
+	DB *sql.DB
+}
+
+func NewCotaRepository() *CotaRepository {
+	return &CotaRepository{
+		DB: database.GetDB(),
+	}
+}
+
+// consultor é atendido tanto por *sql.DB quanto por *sql.Tx
+type consultor interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// usoCota calcula o consumo do dia e do mês e os limites em vigor para o alvo
+func usoCota(q consultor, escopo, alvo, operacao string) (models.UsoCota, error) {
+	uso := models.UsoCota{Escopo: escopo, Alvo: alvo, Operacao: operacao}
+
+	var diario, mensal sql.NullInt64
+	err := q.QueryRow(`
+		SELECT limite_diario, limite_mensal
+		FROM limite_cota
+		WHERE escopo = $1 AND alvo IN ($2, $3) AND operacao = $4
+		ORDER BY alvo = $3
+		LIMIT 1
+	`, escopo, alvo, AlvoCotaPadrao, operacao).Scan(&diario, &mensal)
+	if err != nil && err != sql.ErrNoRows {
+		return uso, err
+	}
+	uso.LimiteDiario = intOuNulo(diario)
+	uso.LimiteMensal = intOuNulo(mensal)
+
+	// A coluna vem de colunasEscopoCota, nunca da requisição
+	err = q.QueryRow(fmt.Sprintf(`
+		SELECT COUNT(*) FILTER (WHERE criado_em >= date_trunc('day', NOW())), COUNT(*)
+		FROM uso_cota
+		WHERE %s = $1 AND operacao = $2 AND criado_em >= date_trunc('month', NOW())
+	`, colunasEscopoCota[escopo]), alvo, operacao).Scan(&uso.UsoDiario, &uso.UsoMensal)
+	return uso, err
+}
+
+func intOuNulo(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	n := int(v.Int64)
+	return &n
+}
+
+// verificarCota retorna CotaExcedidaError se mais uma consulta ultrapassar o limite
+func verificarCota(uso models.UsoCota) error {
+	if uso.LimiteDiario != nil && uso.UsoDiario >= *uso.LimiteDiario {
+		return &CotaExcedidaError{Escopo: uso.Escopo, Operacao: uso.Operacao, Periodo: "diario", Limite: *uso.LimiteDiario}
+	}
+	if uso.LimiteMensal != nil && uso.UsoMensal >= *uso.LimiteMensal {
+		return &CotaExcedidaError{Escopo: uso.Escopo, Operacao: uso.Operacao, Periodo: "mensal", Limite: *uso.LimiteMensal}
+	}
+	return nil
+}
+
+// Consumir registra uma consulta do usuário se nem a cota dele nem a da lotação
+// estiverem esgotadas e retorna o uso de ambas já contando esta consulta. O
+// consumo de uma lotação é serializado por um advisory lock, para que
+// consultas simultâneas não ultrapassem o limite.
+func (r *CotaRepository) Consumir(cpf, lotacao, operacao string) ([]models.UsoCota, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('uso_cota:' || $1))`, lotacao); err != nil {
+		return nil, err
+	}
+
+	alvos := []struct{ escopo, alvo string }{
+		{EscopoCotaUsuario, cpf},
+		{EscopoCotaLotacao, lotacao},
+	}
+	usos := make([]models.UsoCota, 0, len(alvos))
+	for _, a := range alvos {
+		uso, err := usoCota(tx, a.escopo, a.alvo, operacao)
+		if err != nil {
+			return nil, err
+		}
+		if err := verificarCota(uso); err != nil {
+			return nil, err
+		}
+		uso.UsoDiario++
+		uso.UsoMensal++
+		usos = append(usos, uso)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO uso_cota (cpf_usuario, lotacao, operacao)
+		VALUES ($1, $2, $3)
+	`, cpf, lotacao, operacao); err != nil {
+		return nil, err
+	}
+
+	return usos, tx.Commit()
+}
+
+// Uso retorna o consumo de todas as operações para o usuário e para a lotação
+func (r *CotaRepository) Uso(cpf, lotacao string) ([]models.UsoCota, error) {
+	usos := make([]models.UsoCota, 0, 2*len(OperacoesCota))
+	for _, escopo := range []struct{ escopo, alvo string }{
+		{EscopoCotaUsuario, cpf},
+		{EscopoCotaLotacao, lotacao},
+	} {
+		for _, operacao := range OperacoesCota {
+			uso, err := usoCota(r.DB, escopo.escopo, escopo.alvo, operacao)
+			if err != nil {
+				return nil, err
+			}
+			usos = append(usos, uso)
+		}
+	}
+	return usos, nil
+}
+
+// ListarLimites retorna os limites configurados
+func (r *CotaRepository) ListarLimites() ([]models.LimiteCota, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, escopo, alvo, operacao, limite_diario, limite_mensal, atualizado_em
+		FROM limite_cota
+		ORDER BY escopo, alvo, operacao
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	limites := make([]models.LimiteCota, 0)
+	for rows.Next() {
+		var l models.LimiteCota
+		var diario, mensal sql.NullInt64
+		if err := rows.Scan(&l.ID, &l.Escopo, &l.Alvo, &l.Operacao, &diario, &mensal, &l.AtualizadoEm); err != nil {
+			return nil, err
+		}
+		l.LimiteDiario = intOuNulo(diario)
+		l.LimiteMensal = intOuNulo(mensal)
+		limites = append(limites, l)
+	}
+
+	return limites, rows.Err()
+}
+
+// SalvarLimite cria ou substitui o limite do alvo para a operação
+func (r *CotaRepository) SalvarLimite(l *models.LimiteCota) error {
+	return r.DB.QueryRow(`
+		INSERT INTO limite_cota (escopo, alvo, operacao, limite_diario, limite_mensal)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (escopo, alvo, operacao) DO UPDATE SET
+			limite_diario = EXCLUDED.limite_diario,
+			limite_mensal = EXCLUDED.limite_mensal,
+			atualizado_em = NOW()
+		RETURNING id, atualizado_em
+	`, l.Escopo, l.Alvo, l.Operacao, l.LimiteDiario, l.LimiteMensal).Scan(&l.ID, &l.AtualizadoEm)
+}
+
+// RemoverLimite exclui um limite; o alvo volta a seguir o limite padrão
+func (r *CotaRepository) RemoverLimite(id int) error {
+	result, err := r.DB.Exec(`DELETE FROM limite_cota WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrLimiteNaoEncontrado
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/tassyosilva/consultapix/internal/database"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+// ErrCredencialNaoEncontrada indica que o perfil de credencial não existe
+var ErrCredencialNaoEncontrada = errors.New("credencial do BACEN não encontrada")
+
+type CredencialBacenRepository struct {
+	DB *sql.DB
+}
+
+func NewCredencialBacenRepository() *CredencialBacenRepository {
+	return &CredencialBacenRepository{
+		DB: database.GetDB(),
+	}
+}
+
+const selectCredencialBacen = `
+	SELECT id, lotacao, COALESCE(descricao, ''), usuario, senha_cifrada, ativo, criado_em, atualizado_em
+	FROM credencial_bacen
+`
+
+func scanCredencialBacen(scanner interface{ Scan(...interface{}) error }) (*models.CredencialBacen, error) {
+	var c models.CredencialBacen
+	err := scanner.Scan(&c.ID, &c.Lotacao, &c.Descricao, &c.Usuario, &c.SenhaCifrada, &c.Ativo, &c.CriadoEm, &c.AtualizadoEm)
+	if err == sql.ErrNoRows {
+		return nil, ErrCredencialNaoEncontrada
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// BuscarPorLotacao retorna o perfil ativo da lotação
+func (r *CredencialBacenRepository) BuscarPorLotacao(lotacao string) (*models.CredencialBacen, error) {
+	return scanCredencialBacen(r.DB.QueryRow(selectCredencialBacen+`WHERE lotacao = $1 AND ativo = true`, lotacao))
+}
+
+// BuscarPorID retorna o perfil, ativo ou não
+func (r *CredencialBacenRepository) BuscarPorID(id int) (*models.CredencialBacen, error) {
+	return scanCredencialBacen(r.DB.QueryRow(selectCredencialBacen+`WHERE id = $1`, id))
+}
+
+// Listar retorna todos os perfis ordenados por lotação
+func (r *CredencialBacenRepository) Listar() ([]models.CredencialBacen, error) {
+	rows, err := r.DB.Query(selectCredencialBacen + `ORDER BY lotacao`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credenciais := make([]models.CredencialBacen, 0)
+	for rows.Next() {
+		c, err := scanCredencialBacen(rows)
+		if err != nil {
+			return nil, err
+		}
+		credenciais = append(credenciais, *c)
+	}
+
+	return credenciais, rows.Err()
+}
+
+// Criar insere um perfil com a senha já cifrada e preenche o ID
+func (r *CredencialBacenRepository) Criar(c *models.CredencialBacen) error {
+	return r.DB.QueryRow(`
+		INSERT INTO credencial_bacen (lotacao, descricao, usuario, senha_cifrada, ativo)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, criado_em, atualizado_em
+	`, c.Lotacao, c.Descricao, c.Usuario, c.SenhaCifrada, c.Ativo).Scan(&c.ID, &c.CriadoEm, &c.AtualizadoEm)
+}
+
+// Atualizar grava o perfil; a senha só é trocada quando SenhaCifrada é informada
+func (r *CredencialBacenRepository) Atualizar(c *models.CredencialBacen) error {
+	var senha interface{}
+	if len(c.SenhaCifrada) > 0 {
+		senha = c.SenhaCifrada
+	}
+
+	result, err := r.DB.Exec(`
+		UPDATE credencial_bacen
+		SET lotacao = $1, descricao = $2, usuario = $3,
+			senha_cifrada = COALESCE($4::bytea, senha_cifrada), ativo = $5, atualizado_em = NOW()
+		WHERE id = $6
+	`, c.Lotacao, c.Descricao, c.Usuario, senha, c.Ativo, c.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrCredencialNaoEncontrada
+	}
+	return nil
+}
+
+// Remover exclui o perfil; as requisições que o usaram mantêm o usuário registrado
+func (r *CredencialBacenRepository) Remover(id int) error {
+	result, err := r.DB.Exec(`DELETE FROM credencial_bacen WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrCredencialNaoEncontrada
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/tassyosilva/consultapix/internal/database"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+// ErrUsuarioNaoInativo indica usuário inexistente ou que não está desativado
+var ErrUsuarioNaoInativo = errors.New("usuário desativado não encontrado")
+
+// DesativacaoRepository trata a desativação das contas e os pedidos de
+// reativação. A conta desativada continua no banco, com as requisições e a
+// auditoria ligadas a ela.
+type DesativacaoRepository struct {
+	DB *sql.DB
+}
+
+func NewDesativacaoRepository() *DesativacaoRepository {
+	return &DesativacaoRepository{
+		DB: database.GetDB(),
+	}
+}
+
+// Desativar bloqueia o acesso de um usuário ativo, registrando o motivo e o
+// administrador. Os links de redefinição de senha e os desafios do segundo
+// fator em aberto são descartados.
+func (r *DesativacaoRepository) Desativar(id int, cpfAdmin, motivo string) (*models.Usuario, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user := models.Usuario{ID: id, Situacao: SituacaoInativo}
+	err = tx.QueryRow(`
+		UPDATE usuario
+		SET situacao = $2, desativado_em = NOW(), desativado_por = $3, motivo_desativacao = $4,
+			reativacao_solicitada_em = NULL, justificativa_reativacao = NULL
+		WHERE id = $1 AND situacao = $5
+		RETURNING nome, cpf, email, COALESCE(lotacao, '')
+	`, id, SituacaoInativo, cpfAdmin, motivo, SituacaoAtivo).Scan(&user.Nome, &user.CPF, &user.Email, &user.Lotacao)
+	if err == sql.ErrNoRows {
+		return nil, ErrUsuarioNaoEncontrado
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tabela := range []string{"redefinicao_senha", "desafio_dois_fatores"} {
+		if _, err := tx.Exec(`DELETE FROM `+tabela+` WHERE id_usuario = $1`, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SolicitarReativacao registra o pedido de reativação de uma conta
+// desativada. Um novo pedido substitui o anterior.
+func (r *DesativacaoRepository) SolicitarReativacao(id int, justificativa string) error {
+	resultado, err := r.DB.Exec(`
+		UPDATE usuario SET reativacao_solicitada_em = NOW(), justificativa_reativacao = $2
+		WHERE id = $1 AND situacao = $3
+	`, id, justificativa, SituacaoInativo)
+	if err != nil {
+		return err
+	}
+	if n, _ := resultado.RowsAffected(); n == 0 {
+		return ErrUsuarioNaoInativo
+	}
+	return nil
+}
+
+// ListarInativos lista as contas desativadas, primeiro as com pedido de
+// reativação e depois as desativadas mais recentemente
+func (r *DesativacaoRepository) ListarInativos() ([]models.UsuarioInativo, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, nome, cpf, email, COALESCE(lotacao, ''), desativado_em, COALESCE(desativado_por, ''),
+			COALESCE(motivo_desativacao, ''), reativacao_solicitada_em, COALESCE(justificativa_reativacao, '')
+		FROM usuario
+		WHERE situacao = $1
+		ORDER BY reativacao_solicitada_em DESC NULLS LAST, desativado_em DESC
+	`, SituacaoInativo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usuarios := []models.UsuarioInativo{}
+	for rows.Next() {
+		var u models.UsuarioInativo
+		var pedido sql.NullTime
+		if err := rows.Scan(&u.ID, &u.Nome, &u.CPF, &u.Email, &u.Lotacao, &u.DesativadoEm, &u.DesativadoPor, &u.Motivo, &pedido, &u.Justificativa); err != nil {
+			return nil, err
+		}
+		if pedido.Valid {
+			u.ReativacaoSolicitadaEm = &pedido.Time
+		}
+		usuarios = append(usuarios, u)
+	}
+	return usuarios, rows.Err()
+}
+
+// Reativar devolve o acesso a uma conta desativada. O motivo e o autor da
+// desativação ficam registrados na auditoria.
+func (r *DesativacaoRepository) Reativar(id int) (*models.Usuario, error) {
+	user := models.Usuario{ID: id, Situacao: SituacaoAtivo}
+	err := r.DB.QueryRow(`
+		UPDATE usuario
+		SET situacao = $2, desativado_em = NULL, desativado_por = NULL, motivo_desativacao = NULL,
+			reativacao_solicitada_em = NULL, justificativa_reativacao = NULL
+		WHERE id = $1 AND situacao = $3
+		RETURNING nome, cpf, email, COALESCE(lotacao, '')
+	`, id, SituacaoAtivo, SituacaoInativo).Scan(&user.Nome, &user.CPF, &user.Email, &user.Lotacao)
+	if err == sql.ErrNoRows {
+		return nil, ErrUsuarioNaoInativo
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// RejeitarReativacao descarta o pedido de reativação; a conta continua desativada
+func (r *DesativacaoRepository) RejeitarReativacao(id int) (*models.Usuario, error) {
+	user := models.Usuario{ID: id, Situacao: SituacaoInativo}
+	err := r.DB.QueryRow(`
+		UPDATE usuario SET reativacao_solicitada_em = NULL, justificativa_reativacao = NULL
+		WHERE id = $1 AND situacao = $2 AND reativacao_solicitada_em IS NOT NULL
+		RETURNING nome, cpf, email, COALESCE(lotacao, '')
+	`, id, SituacaoInativo).Scan(&user.Nome, &user.CPF, &user.Email, &user.Lotacao)
+	if err == sql.ErrNoRows {
+		return nil, ErrUsuarioNaoInativo
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
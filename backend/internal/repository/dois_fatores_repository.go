@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tassyosilva/consultapix/internal/database"
+)
+
+// Papéis usados na política de dois fatores
+const (
+	PapelAdmin   = "admin"
+	PapelUsuario = "usuario"
+)
+
+// PapeisUsuario lista os papéis na ordem em que a política é apresentada
+var PapeisUsuario = []string{PapelAdmin, PapelUsuario}
+
+// Papel retorna o papel do usuário conforme o perfil de administrador
+func Papel(admin bool) string {
+	if admin {
+		return PapelAdmin
+	}
+	return PapelUsuario
+}
+
+// tentativasDesafio é o máximo de códigos aceitos por desafio de login
+const tentativasDesafio = 5
+
+var (
+	// ErrDoisFatoresJaAtivo indica que o usuário precisa desativar o segundo
+	// fator antes de cadastrar outro
+	ErrDoisFatoresJaAtivo = errors.New("autenticação em dois fatores já ativa")
+	// ErrDoisFatoresNaoCadastrado indica que não há segredo TOTP para o usuário
+	ErrDoisFatoresNaoCadastrado = errors.New("autenticação em dois fatores não cadastrada")
+	// ErrDesafioInvalido indica desafio inexistente, expirado ou sem tentativas
+	ErrDesafioInvalido = errors.New("desafio de login inválido ou expirado")
+)
+
+// SegredoTOTP é o estado do segundo fator de um usuário
+type SegredoTOTP struct {
+	Cifrado []byte
+	Ativo   bool
+}
+
+type DoisFatoresRepository struct {
+	DB *sql.DB
+}
+
+func NewDoisFatoresRepository() *DoisFatoresRepository {
+	return &DoisFatoresRepository{
+		DB: database.GetDB(),
+	}
+}
+
+// SalvarSegredoPendente grava o segredo de um cadastro ainda não confirmado
+func (r *DoisFatoresRepository) SalvarSegredoPendente(idUsuario int, cifrado []byte) error {
+	result, err := r.DB.Exec(`
+		UPDATE usuario SET totp_segredo = $2, totp_ultimo_passo = NULL
+		WHERE id = $1 AND NOT totp_ativo
+	`, idUsuario, cifrado)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrDoisFatoresJaAtivo
+	}
+	return nil
+}
+
+// Segredo retorna o segredo cifrado e se o segundo fator está ativo
+func (r *DoisFatoresRepository) Segredo(idUsuario int) (*SegredoTOTP, error) {
+	var s SegredoTOTP
+	err := r.DB.QueryRow(`SELECT totp_segredo, totp_ativo FROM usuario WHERE id = $1`, idUsuario).Scan(&s.Cifrado, &s.Ativo)
+	if err == sql.ErrNoRows {
+		return nil, ErrUsuarioNaoEncontrado
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Cifrado) == 0 {
+		return nil, ErrDoisFatoresNaoCadastrado
+	}
+	return &s, nil
+}
+
+// RegistrarPasso aceita o passo de tempo de um código TOTP uma única vez. Falso
+// indica que o código (ou um mais recente) já foi usado.
+func (r *DoisFatoresRepository) RegistrarPasso(idUsuario int, passo int64) (bool, error) {
+	result, err := r.DB.Exec(`
+		UPDATE usuario SET totp_ultimo_passo = $2
+		WHERE id = $1 AND (totp_ultimo_passo IS NULL OR totp_ultimo_passo < $2)
+	`, idUsuario, passo)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// Ativar confirma o cadastro e substitui os códigos de recuperação
+func (r *DoisFatoresRepository) Ativar(idUsuario int, hashesCodigos []string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE usuario SET totp_ativo = TRUE WHERE id = $1 AND totp_segredo IS NOT NULL`, idUsuario); err != nil {
+		return err
+	}
+	if err := substituirCodigos(tx, idUsuario, hashesCodigos); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Desativar apaga o segredo e os códigos de recuperação do usuário
+func (r *DoisFatoresRepository) Desativar(idUsuario int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE usuario SET totp_segredo = NULL, totp_ativo = FALSE, totp_ultimo_passo = NULL
+		WHERE id = $1
+	`, idUsuario); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM codigo_recuperacao WHERE id_usuario = $1`, idUsuario); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SubstituirCodigos troca os códigos de recuperação do usuário
+func (r *DoisFatoresRepository) SubstituirCodigos(idUsuario int, hashesCodigos []string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := substituirCodigos(tx, idUsuario, hashesCodigos); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func substituirCodigos(tx *sql.Tx, idUsuario int, hashesCodigos []string) error {
+	if _, err := tx.Exec(`DELETE FROM codigo_recuperacao WHERE id_usuario = $1`, idUsuario); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		INSERT INTO codigo_recuperacao (id_usuario, hash_codigo)
+		SELECT $1, unnest($2::text[])
+	`, idUsuario, pq.Array(hashesCodigos))
+	return err
+}
+
+// UsarCodigoRecuperacao consome um código de recuperação não usado
+func (r *DoisFatoresRepository) UsarCodigoRecuperacao(idUsuario int, hash string) (bool, error) {
+	result, err := r.DB.Exec(`
+		UPDATE codigo_recuperacao SET usado_em = NOW()
+		WHERE id_usuario = $1 AND hash_codigo = $2 AND usado_em IS NULL
+	`, idUsuario, hash)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// CodigosRestantes conta os códigos de recuperação ainda não usados
+func (r *DoisFatoresRepository) CodigosRestantes(idUsuario int) (int, error) {
+	var n int
+	err := r.DB.QueryRow(`
+		SELECT COUNT(*) FROM codigo_recuperacao WHERE id_usuario = $1 AND usado_em IS NULL
+	`, idUsuario).Scan(&n)
+	return n, err
+}
+
+// CriarDesafio registra o segundo passo pendente de um login
+func (r *DoisFatoresRepository) CriarDesafio(hash string, idUsuario int, expiraEm time.Time) error {
+	if _, err := r.DB.Exec(`DELETE FROM desafio_dois_fatores WHERE expira_em < NOW()`); err != nil {
+		return err
+	}
+	_, err := r.DB.Exec(`
+		INSERT INTO desafio_dois_fatores (hash_desafio, id_usuario, expira_em)
+		VALUES ($1, $2, $3)
+	`, hash, idUsuario, expiraEm)
+	return err
+}
+
+// TentarDesafio conta uma tentativa e retorna o usuário do desafio
+func (r *DoisFatoresRepository) TentarDesafio(hash string) (int, error) {
+	var idUsuario int
+	err := r.DB.QueryRow(`
+		UPDATE desafio_dois_fatores SET tentativas = tentativas + 1
+		WHERE hash_desafio = $1 AND expira_em > NOW() AND tentativas < $2
+		RETURNING id_usuario
+	`, hash, tentativasDesafio).Scan(&idUsuario)
+	if err == sql.ErrNoRows {
+		return 0, ErrDesafioInvalido
+	}
+	return idUsuario, err
+}
+
+// RemoverDesafio descarta o desafio depois do login concluído
+func (r *DoisFatoresRepository) RemoverDesafio(hash string) error {
+	_, err := r.DB.Exec(`DELETE FROM desafio_dois_fatores WHERE hash_desafio = $1`, hash)
+	return err
+}
+
+// Obrigatorio indica se a política exige o segundo fator para o papel
+func (r *DoisFatoresRepository) Obrigatorio(papel string) (bool, error) {
+	var obrigatorio bool
+	err := r.DB.QueryRow(`SELECT obrigatorio FROM politica_dois_fatores WHERE papel = $1`, papel).Scan(&obrigatorio)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return obrigatorio, err
+}
+
+// Politica retorna a obrigatoriedade de cada papel
+func (r *DoisFatoresRepository) Politica() (map[string]bool, error) {
+	politica := make(map[string]bool, len(PapeisUsuario))
+	for _, papel := range PapeisUsuario {
+		obrigatorio, err := r.Obrigatorio(papel)
+		if err != nil {
+			return nil, err
+		}
+		politica[papel] = obrigatorio
+	}
+	return politica, nil
+}
+
+// SalvarPolitica define se o segundo fator é obrigatório para o papel
+func (r *DoisFatoresRepository) SalvarPolitica(papel string, obrigatorio bool, cpfAdmin string) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO politica_dois_fatores (papel, obrigatorio, atualizado_por)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (papel) DO UPDATE SET
+			obrigatorio = EXCLUDED.obrigatorio,
+			atualizado_em = NOW(),
+			atualizado_por = EXCLUDED.atualizado_por
+	`, papel, obrigatorio, cpfAdmin)
+	return err
+}
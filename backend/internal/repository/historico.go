@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	limitePadraoHistorico = 50
+	limiteMaximoHistorico = 200
+)
+
+// ErrRequisicaoNaoEncontrada indica que a requisição não existe ou não é visível ao usuário
+var ErrRequisicaoNaoEncontrada = errors.New("requisição não encontrada")
+
+// ErrCursorInvalido indica um cursor de paginação malformado
+var ErrCursorInvalido = errors.New("cursor de paginação inválido")
+
+// Escopo delimita quais requisições um usuário pode ver: o analista vê as
+// próprias, o supervisor também as da sua unidade e das subordinadas, e o
+// auditor e o administrador veem todas
+type Escopo struct {
+	CPF     string
+	Lotacao string
+	Admin   bool
+	Perfil  string
+	Unidade int
+}
+
+// EscopoSistema é usado por rotinas internas, como o agendador de
+// monitoramentos, que precisam ler requisições de qualquer usuário
+var EscopoSistema = Escopo{Admin: true}
+
+// Total indica escopo que alcança as requisições de todos os usuários
+func (e Escopo) Total() bool {
+	return e.Admin || e.Perfil == PerfilAuditor
+}
+
+// Compartilhado indica escopo que alcança requisições de outros usuários,
+// caso em que as listagens aceitam filtrar pelo responsável
+func (e Escopo) Compartilhado() bool {
+	return e.Total() || (e.Perfil == PerfilSupervisor && e.Unidade > 0)
+}
+
+// condicao devolve o filtro SQL do escopo para a tabela de requisições com o alias informado
+func (e Escopo) condicao(c *consultaSQL, alias string) string {
+	if e.Total() {
+		return "TRUE"
+	}
+	if e.Perfil == PerfilSupervisor && e.Unidade > 0 {
+		return fmt.Sprintf("(%s.cpf_responsavel = %s OR %s.id_unidade IN (%s))",
+			alias, c.arg(e.CPF), alias, subarvoreUnidade(c.arg(e.Unidade)))
+	}
+	return fmt.Sprintf("%s.cpf_responsavel = %s", alias, c.arg(e.CPF))
+}
+
+// FiltroHistorico reúne os filtros aceitos pelas listagens de requisições
+type FiltroHistorico struct {
+	DataInicio     *time.Time
+	DataFim        *time.Time // exclusivo
+	Caso           string
+	TipoBusca      string
+	Alvo           string
+	Resultado      string
+	Status         string
+	CPFResponsavel string
+	Crescente      bool
+	Cursor         string
+	Limite         int
+}
+
+// limite devolve o tamanho de página efetivo
+func (f FiltroHistorico) limite() int {
+	if f.Limite <= 0 {
+		return limitePadraoHistorico
+	}
+	if f.Limite > limiteMaximoHistorico {
+		return limiteMaximoHistorico
+	}
+	return f.Limite
+}
+
+// cursorHistorico identifica o último item de uma página (ordenação por data e id)
+type cursorHistorico struct {
+	Data time.Time `json:"d"`
+	ID   int       `json:"i"`
+}
+
+func codificarCursor(data time.Time, id int) string {
+	bytes, _ := json.Marshal(cursorHistorico{Data: data, ID: id})
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+func decodificarCursor(cursor string) (*cursorHistorico, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	bytes, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrCursorInvalido
+	}
+	var c cursorHistorico
+	if err := json.Unmarshal(bytes, &c); err != nil {
+		return nil, ErrCursorInvalido
+	}
+	return &c, nil
+}
+
+// consultaSQL monta cláusulas WHERE com parâmetros numerados
+type consultaSQL struct {
+	condicoes []string
+	args      []interface{}
+}
+
+// arg registra um parâmetro e devolve seu placeholder
+func (c *consultaSQL) arg(valor interface{}) string {
+	c.args = append(c.args, valor)
+	return fmt.Sprintf("$%d", len(c.args))
+}
+
+func (c *consultaSQL) onde(condicao string) {
+	c.condicoes = append(c.condicoes, condicao)
+}
+
+func (c *consultaSQL) where() string {
+	if len(c.condicoes) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(c.condicoes, " AND ")
+}
+
+// paginar aplica o cursor sobre a expressão de data informada e devolve as
+// cláusulas ORDER BY e LIMIT correspondentes
+func (c *consultaSQL) paginar(f FiltroHistorico, colunaData, colunaID string) (string, error) {
+	cursor, err := decodificarCursor(f.Cursor)
+	if err != nil {
+		return "", err
+	}
+
+	direcao, comparador := "DESC", "<"
+	if f.Crescente {
+		direcao, comparador = "ASC", ">"
+	}
+	if cursor != nil {
+		c.onde(fmt.Sprintf("(%s, %s) %s (%s, %s)", colunaData, colunaID, comparador, c.arg(cursor.Data), c.arg(cursor.ID)))
+	}
+
+	// Busca um item a mais para saber se há próxima página
+	return fmt.Sprintf("ORDER BY %s %s, %s %s LIMIT %d", colunaData, direcao, colunaID, direcao, f.limite()+1), nil
+}
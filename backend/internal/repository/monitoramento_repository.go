@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/tassyosilva/consultapix/internal/database"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+// MotivoAutorizacaoExpirada é o motivo de encerramento automático
+const MotivoAutorizacaoExpirada = "Autorização expirada"
+
+// ErrMonitoramentoNaoEncontrado indica que o monitoramento não existe ou não é visível ao usuário
+var ErrMonitoramentoNaoEncontrado = errors.New("monitoramento não encontrado")
+
+type MonitoramentoRepository struct {
+	DB *sql.DB
+}
+
+func NewMonitoramentoRepository() *MonitoramentoRepository {
+	return &MonitoramentoRepository{
+		DB: database.GetDB(),
+	}
+}
+
+const selectMonitoramento = `
+	SELECT m.id, m.tipo_busca, m.alvo, m.caso, m.motivo, m.numero_autorizacao,
+		m.autorizacao_expira_em, m.frequencia_horas, m.cpf_responsavel, COALESCE(m.lotacao, ''),
+		ARRAY(SELECT mr.cpf_usuario FROM monitoramento_responsavel mr WHERE mr.id_monitoramento = m.id ORDER BY mr.cpf_usuario),
+		m.ativo, m.encerrado_em, COALESCE(m.motivo_encerramento, ''), m.ultima_execucao,
+		m.proxima_execucao, m.id_ultima_requisicao, COALESCE(m.ultimo_erro, ''), m.criado_em
+	FROM monitoramento m
+`
+
+func scanMonitoramento(scanner interface{ Scan(...interface{}) error }) (*models.Monitoramento, error) {
+	var m models.Monitoramento
+	var responsaveis pq.StringArray
+	err := scanner.Scan(
+		&m.ID, &m.TipoBusca, &m.Alvo, &m.Caso, &m.Motivo, &m.NumeroAutorizacao,
+		&m.AutorizacaoExpiraEm, &m.FrequenciaHoras, &m.CPFResponsavel, &m.Lotacao,
+		&responsaveis, &m.Ativo, &m.EncerradoEm, &m.MotivoEncerramento, &m.UltimaExecucao,
+		&m.ProximaExecucao, &m.IDUltimaRequisicao, &m.UltimoErro, &m.CriadoEm,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrMonitoramentoNaoEncontrado
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.Responsaveis = []string(responsaveis)
+	return &m, nil
+}
+
+// condicaoMonitoramento restringe aos monitoramentos criados pelo usuário ou
+// em que ele é um dos responsáveis
+func condicaoMonitoramento(escopo Escopo, c *consultaSQL) string {
+	if escopo.Total() {
+		return "TRUE"
+	}
+	cpf := c.arg(escopo.CPF)
+	return fmt.Sprintf(`(m.cpf_responsavel = %s OR EXISTS (
+		SELECT 1 FROM monitoramento_responsavel mr WHERE mr.id_monitoramento = m.id AND mr.cpf_usuario = %s))`, cpf, cpf)
+}
+
+// Criar grava o monitoramento e seus responsáveis. A primeira execução fica
+// agendada para o próximo ciclo do agendador.
+func (r *MonitoramentoRepository) Criar(m *models.Monitoramento) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO monitoramento (
+			tipo_busca, alvo, caso, motivo, numero_autorizacao, autorizacao_expira_em,
+			frequencia_horas, cpf_responsavel, lotacao
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, ativo, proxima_execucao, criado_em
+	`, m.TipoBusca, m.Alvo, m.Caso, m.Motivo, m.NumeroAutorizacao, m.AutorizacaoExpiraEm,
+		m.FrequenciaHoras, m.CPFResponsavel, m.Lotacao,
+	).Scan(&m.ID, &m.Ativo, &m.ProximaExecucao, &m.CriadoEm)
+	if err != nil {
+		return err
+	}
+
+	for _, cpf := range m.Responsaveis {
+		if _, err := tx.Exec(`
+			INSERT INTO monitoramento_responsavel (id_monitoramento, cpf_usuario)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, m.ID, cpf); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Listar retorna os monitoramentos visíveis no escopo, ativos primeiro
+func (r *MonitoramentoRepository) Listar(escopo Escopo) ([]models.Monitoramento, error) {
+	c := &consultaSQL{}
+	c.onde(condicaoMonitoramento(escopo, c))
+
+	rows, err := r.DB.Query(selectMonitoramento+c.where()+`
+		ORDER BY m.ativo DESC, m.criado_em DESC
+	`, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	monitoramentos := make([]models.Monitoramento, 0)
+	for rows.Next() {
+		m, err := scanMonitoramento(rows)
+		if err != nil {
+			return nil, err
+		}
+		monitoramentos = append(monitoramentos, *m)
+	}
+
+	return monitoramentos, rows.Err()
+}
+
+// BuscarPorID retorna o monitoramento se ele for visível no escopo
+func (r *MonitoramentoRepository) BuscarPorID(escopo Escopo, id int) (*models.Monitoramento, error) {
+	c := &consultaSQL{}
+	c.onde("m.id = " + c.arg(id))
+	c.onde(condicaoMonitoramento(escopo, c))
+	return scanMonitoramento(r.DB.QueryRow(selectMonitoramento+c.where(), c.args...))
+}
+
+// Encerrar interrompe um monitoramento ativo
+func (r *MonitoramentoRepository) Encerrar(id int, motivo string) error {
+	result, err := r.DB.Exec(`
+		UPDATE monitoramento
+		SET ativo = false, encerrado_em = NOW(), motivo_encerramento = $2
+		WHERE id = $1 AND ativo
+	`, id, motivo)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrMonitoramentoNaoEncontrado
+	}
+	return nil
+}
+
+// EncerrarExpirados encerra os monitoramentos cuja autorização expirou e os retorna
+func (r *MonitoramentoRepository) EncerrarExpirados() ([]models.Monitoramento, error) {
+	rows, err := r.DB.Query(`
+		UPDATE monitoramento
+		SET ativo = false, encerrado_em = NOW(), motivo_encerramento = $1
+		WHERE ativo AND autorizacao_expira_em <= NOW()
+		RETURNING id
+	`, MotivoAutorizacaoExpirada)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := lerIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+	return r.buscarPorIDs(ids)
+}
+
+// ReservarPendentes marca como em execução até limite monitoramentos vencidos,
+// já agendando a próxima execução. FOR UPDATE SKIP LOCKED evita que duas
+// instâncias da API executem o mesmo monitoramento. Os monitoramentos de
+// responsável desativado ficam parados até a reativação.
+func (r *MonitoramentoRepository) ReservarPendentes(limite int) ([]models.Monitoramento, error) {
+	rows, err := r.DB.Query(`
+		UPDATE monitoramento m
+		SET proxima_execucao = NOW() + make_interval(hours => m.frequencia_horas)
+		WHERE m.id IN (
+			SELECT id FROM monitoramento
+			WHERE ativo AND proxima_execucao <= NOW() AND autorizacao_expira_em > NOW()
+				AND EXISTS (SELECT 1 FROM usuario u WHERE u.cpf = monitoramento.cpf_responsavel AND u.situacao = $2)
+			ORDER BY proxima_execucao
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING m.id
+	`, limite, SituacaoAtivo)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := lerIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+	return r.buscarPorIDs(ids)
+}
+
+// RegistrarExecucao grava o resultado de uma execução. idRequisicao nil
+// mantém a última requisição bem-sucedida como base da próxima comparação.
+func (r *MonitoramentoRepository) RegistrarExecucao(id int, idRequisicao *int, erro string) error {
+	_, err := r.DB.Exec(`
+		UPDATE monitoramento
+		SET ultima_execucao = NOW(),
+			id_ultima_requisicao = COALESCE($2, id_ultima_requisicao),
+			ultimo_erro = NULLIF($3, '')
+		WHERE id = $1
+	`, id, idRequisicao, erro)
+	return err
+}
+
+func lerIDs(rows *sql.Rows) ([]int, error) {
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *MonitoramentoRepository) buscarPorIDs(ids []int) ([]models.Monitoramento, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	rows, err := r.DB.Query(selectMonitoramento+`
+		WHERE m.id = ANY($1)
+		ORDER BY m.id
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var monitoramentos []models.Monitoramento
+	for rows.Next() {
+		m, err := scanMonitoramento(rows)
+		if err != nil {
+			return nil, err
+		}
+		monitoramentos = append(monitoramentos, *m)
+	}
+
+	return monitoramentos, rows.Err()
+}
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/tassyosilva/consultapix/internal/database"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+// Tipos de notificação (eventos)
+const (
+	NotificacaoMonitoramentoAlteracao = "monitoramento_alteracao"
+	NotificacaoMonitoramentoEncerrado = "monitoramento_encerrado"
+	NotificacaoDetalhamentoConcluido  = "detalhamento_concluido"
+	NotificacaoDetalhamentoRecusado   = "detalhamento_recusado"
+	NotificacaoCotaProximaDoLimite    = "cota_proxima_limite"
+)
+
+// EventosNotificacao lista os eventos para os quais há preferência; um evento
+// só entra aqui quando algum fluxo passa a emiti-lo
+var EventosNotificacao = []string{
+	NotificacaoDetalhamentoConcluido,
+	NotificacaoDetalhamentoRecusado,
+	NotificacaoCotaProximaDoLimite,
+	NotificacaoMonitoramentoAlteracao,
+	NotificacaoMonitoramentoEncerrado,
+}
+
+// Canais de notificação
+const (
+	CanalInbox   = "inbox"
+	CanalEmail   = "email"
+	CanalWebhook = "webhook"
+)
+
+// CanaisNotificacao lista os canais na ordem de entrega
+var CanaisNotificacao = []string{CanalInbox, CanalEmail, CanalWebhook}
+
+// EventoNotificacaoValido indica se o evento aceita preferência
+func EventoNotificacaoValido(evento string) bool {
+	for _, e := range EventosNotificacao {
+		if e == evento {
+			return true
+		}
+	}
+	return false
+}
+
+// CanalNotificacaoValido indica se o canal é inbox, email ou webhook
+func CanalNotificacaoValido(canal string) bool {
+	for _, c := range CanaisNotificacao {
+		if c == canal {
+			return true
+		}
+	}
+	return false
+}
+
+// CanalAtivoPorPadrao indica se o canal vale para quem não definiu preferência
+func CanalAtivoPorPadrao(canal string) bool {
+	return canal == CanalInbox
+}
+
+// limiteNotificacoes é o máximo de notificações devolvidas por listagem
+const limiteNotificacoes = 100
+
+// ErrNotificacaoNaoEncontrada indica que a notificação não existe ou é de outro usuário
+var ErrNotificacaoNaoEncontrada = errors.New("notificação não encontrada")
+
+type NotificacaoRepository struct {
+	DB *sql.DB
+}
+
+func NewNotificacaoRepository() *NotificacaoRepository {
+	return &NotificacaoRepository{
+		DB: database.GetDB(),
+	}
+}
+
+// Criar grava a notificação na caixa de entrada do usuário
+func (r *NotificacaoRepository) Criar(n *models.Notificacao) error {
+	dados := n.Dados
+	if dados == nil {
+		dados = map[string]interface{}{}
+	}
+	dadosJSON, err := json.Marshal(dados)
+	if err != nil {
+		return err
+	}
+
+	return r.DB.QueryRow(`
+		INSERT INTO notificacao (cpf_usuario, tipo, titulo, mensagem, dados)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, criado_em
+	`, n.CPFUsuario, n.Tipo, n.Titulo, n.Mensagem, dadosJSON).Scan(&n.ID, &n.CriadoEm)
+}
+
+// Listar retorna as notificações mais recentes do usuário
+func (r *NotificacaoRepository) Listar(cpf string, somenteNaoLidas bool) ([]models.Notificacao, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, cpf_usuario, tipo, titulo, mensagem, dados, lida_em, criado_em
+		FROM notificacao
+		WHERE cpf_usuario = $1 AND (NOT $2 OR lida_em IS NULL)
+		ORDER BY criado_em DESC, id DESC
+		LIMIT $3
+	`, cpf, somenteNaoLidas, limiteNotificacoes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notificacoes := make([]models.Notificacao, 0)
+	for rows.Next() {
+		var n models.Notificacao
+		var dadosJSON []byte
+		if err := rows.Scan(&n.ID, &n.CPFUsuario, &n.Tipo, &n.Titulo, &n.Mensagem, &dadosJSON, &n.LidaEm, &n.CriadoEm); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(dadosJSON, &n.Dados); err != nil {
+			return nil, err
+		}
+		notificacoes = append(notificacoes, n)
+	}
+
+	return notificacoes, rows.Err()
+}
+
+// MarcarLida marca a notificação do usuário como lida
+func (r *NotificacaoRepository) MarcarLida(cpf string, id int64) error {
+	result, err := r.DB.Exec(`
+		UPDATE notificacao SET lida_em = COALESCE(lida_em, NOW())
+		WHERE id = $1 AND cpf_usuario = $2
+	`, id, cpf)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotificacaoNaoEncontrada
+	}
+	return nil
+}
+
+// PreferenciasEvento retorna, para cada canal, se o usuário recebe o evento,
+// aplicando o padrão aos canais sem preferência registrada
+func (r *NotificacaoRepository) PreferenciasEvento(cpf, evento string) (map[string]bool, error) {
+	preferencias := make(map[string]bool, len(CanaisNotificacao))
+	for _, canal := range CanaisNotificacao {
+		preferencias[canal] = CanalAtivoPorPadrao(canal)
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT canal, ativo FROM preferencia_notificacao
+		WHERE cpf_usuario = $1 AND evento = $2
+	`, cpf, evento)
+	if err != nil {
+		return preferencias, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var canal string
+		var ativo bool
+		if err := rows.Scan(&canal, &ativo); err != nil {
+			return preferencias, err
+		}
+		preferencias[canal] = ativo
+	}
+	return preferencias, rows.Err()
+}
+
+// Preferencias retorna todas as combinações de evento e canal do usuário
+func (r *NotificacaoRepository) Preferencias(cpf string) ([]models.PreferenciaNotificacao, error) {
+	preferencias := make([]models.PreferenciaNotificacao, 0, len(EventosNotificacao)*len(CanaisNotificacao))
+	for _, evento := range EventosNotificacao {
+		porCanal, err := r.PreferenciasEvento(cpf, evento)
+		if err != nil {
+			return nil, err
+		}
+		for _, canal := range CanaisNotificacao {
+			preferencias = append(preferencias, models.PreferenciaNotificacao{Evento: evento, Canal: canal, Ativo: porCanal[canal]})
+		}
+	}
+	return preferencias, nil
+}
+
+// SalvarPreferencias grava as preferências informadas; as demais não mudam
+func (r *NotificacaoRepository) SalvarPreferencias(cpf string, preferencias []models.PreferenciaNotificacao) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, p := range preferencias {
+		if _, err := tx.Exec(`
+			INSERT INTO preferencia_notificacao (cpf_usuario, evento, canal, ativo)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (cpf_usuario, evento, canal) DO UPDATE SET
+				ativo = EXCLUDED.ativo,
+				atualizado_em = NOW()
+		`, cpf, p.Evento, p.Canal, p.Ativo); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
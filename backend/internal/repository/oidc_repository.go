@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/database"
+)
+
+// ErrLoginOIDCInvalido indica estado desconhecido, expirado ou já usado
+var ErrLoginOIDCInvalido = errors.New("login OIDC inválido ou expirado")
+
+type OIDCRepository struct {
+	DB *sql.DB
+}
+
+func NewOIDCRepository() *OIDCRepository {
+	return &OIDCRepository{
+		DB: database.GetDB(),
+	}
+}
+
+// CriarLogin registra um login OIDC enviado ao provedor
+func (r *OIDCRepository) CriarLogin(hashEstado, verificador, nonce string, expiraEm time.Time) error {
+	if _, err := r.DB.Exec(`DELETE FROM login_oidc WHERE expira_em < NOW()`); err != nil {
+		return err
+	}
+	_, err := r.DB.Exec(`
+		INSERT INTO login_oidc (hash_estado, verificador, nonce, expira_em)
+		VALUES ($1, $2, $3, $4)
+	`, hashEstado, verificador, nonce, expiraEm)
+	return err
+}
+
+// ConsumirLogin remove o login e retorna o verificador PKCE e o nonce. Cada
+// estado vale uma única vez.
+func (r *OIDCRepository) ConsumirLogin(hashEstado string) (verificador, nonce string, err error) {
+	err = r.DB.QueryRow(`
+		DELETE FROM login_oidc
+		WHERE hash_estado = $1 AND expira_em > NOW()
+		RETURNING verificador, nonce
+	`, hashEstado).Scan(&verificador, &nonce)
+	if err == sql.ErrNoRows {
+		return "", "", ErrLoginOIDCInvalido
+	}
+	return verificador, nonce, err
+}
@@ -0,0 +1,568 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/database"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+// Tipos de atributo observados para uma pessoa
+const (
+	AtributoNome        = "nome"
+	AtributoChavePix    = "chave_pix"
+	AtributoConta       = "conta"
+	AtributoInstituicao = "instituicao"
+)
+
+// bancoNaoInformado é o nome gravado pelo serviço PIX quando o banco não é conhecido
+const bancoNaoInformado = "BANCO NÃO INFORMADO"
+
+// ErrPessoaNaoEncontrada indica que a pessoa não existe ou não aparece em
+// nenhuma requisição visível ao usuário
+var ErrPessoaNaoEncontrada = errors.New("pessoa não encontrada")
+
+type PessoaRepository struct {
+	DB *sql.DB
+}
+
+func NewPessoaRepository() *PessoaRepository {
+	return &PessoaRepository{
+		DB: database.GetDB(),
+	}
+}
+
+// NormalizarDocumento mantém apenas os dígitos de um CPF/CNPJ e completa com
+// zeros à esquerda até 11 ou 14 dígitos. Retorna "" para valores que não são
+// documentos. Deve seguir consultapix_normalizar_documento (migração 0006).
+func NormalizarDocumento(valor string) string {
+	var b strings.Builder
+	for _, r := range valor {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	digitos := b.String()
+
+	if strings.Trim(digitos, "0") == "" {
+		return ""
+	}
+	switch {
+	case len(digitos) <= 11:
+		return strings.Repeat("0", 11-len(digitos)) + digitos
+	case len(digitos) <= 14:
+		return strings.Repeat("0", 14-len(digitos)) + digitos
+	default:
+		return ""
+	}
+}
+
+// formatarConta identifica uma conta como "instituição / agência / conta",
+// omitindo as partes vazias. Retorna "" quando não há número de conta.
+func formatarConta(instituicao, agencia, conta string) string {
+	conta = strings.TrimSpace(conta)
+	if conta == "" {
+		return ""
+	}
+	partes := make([]string, 0, 3)
+	for _, p := range []string{instituicao, agencia} {
+		if p = strings.TrimSpace(p); p != "" {
+			partes = append(partes, p)
+		}
+	}
+	return strings.Join(append(partes, conta), " / ")
+}
+
+// nomeInstituicao prefere o nome do banco e recorre ao identificador (ISPB/CNPJ)
+func nomeInstituicao(nome, identificador string) string {
+	nome = strings.TrimSpace(nome)
+	if nome == "" || nome == bancoNaoInformado {
+		return strings.TrimSpace(identificador)
+	}
+	return nome
+}
+
+// vincularPessoa garante que a pessoa do documento exista e atualiza o nome
+// e as datas de ocorrência. Retorna nil quando o documento não é um CPF/CNPJ
+// válido. Com a cifragem ativa o documento e o nome vão cifrados, e a pessoa
+// é encontrada pelo índice cego do documento.
+func vincularPessoa(tx *sql.Tx, documento, nome string, visto time.Time) (*int, error) {
+	documento = NormalizarDocumento(documento)
+	if documento == "" {
+		return nil, nil
+	}
+
+	tipoPessoa := "PF"
+	if len(documento) == 14 {
+		tipoPessoa = "PJ"
+	}
+	nome = strings.TrimSpace(nome)
+
+	chaves := cifraDados.Load()
+	conflito := "documento"
+	var indice []byte
+	if chaves != nil {
+		conflito = "indice_unico"
+		indice = chaves.indiceUnicoDe(tabelaPessoa, map[string]string{"documento": documento})
+		// A pessoa gravada em claro antes da cifragem passa a ser encontrada pelo índice
+		_, err := tx.Exec(`UPDATE pessoa SET indice_unico = $1 WHERE documento = $2 AND indice_unico IS NULL`, indice, documento)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var id int
+	var ultima time.Time
+	var nomeAtual sql.NullString
+	var idChaveDados sql.NullInt64
+	var dadosCifrados []byte
+	err := tx.QueryRow(`
+		INSERT INTO pessoa (documento, indice_unico, tipo_pessoa, primeira_ocorrencia, ultima_ocorrencia)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (`+conflito+`) DO UPDATE SET
+			primeira_ocorrencia = LEAST(pessoa.primeira_ocorrencia, EXCLUDED.primeira_ocorrencia),
+			ultima_ocorrencia = GREATEST(pessoa.ultima_ocorrencia, EXCLUDED.ultima_ocorrencia)
+		RETURNING id, ultima_ocorrencia, nome_principal, id_chave_dados, dados_cifrados
+	`, sql.NullString{String: documento, Valid: chaves == nil}, indice, tipoPessoa, visto).Scan(
+		&id, &ultima, &nomeAtual, &idChaveDados, &dadosCifrados,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// O nome observado por último prevalece; sem nome novo, fica o anterior
+	nomePrincipal := nomeAtual.String
+	if dadosCifrados != nil {
+		campos, err := decifrarCampos(tabelaPessoa, id, &models.DadosCifrados{Chave: int(idChaveDados.Int64), Dados: dadosCifrados})
+		if err != nil {
+			return nil, err
+		}
+		nomePrincipal = campos["nome_principal"]
+	}
+	if nome != "" && (nomePrincipal == "" || !visto.Before(ultima)) {
+		nomePrincipal = nome
+	}
+
+	err = gravarDadosSensiveis(tx, chaves, tabelaPessoa, id, map[string]string{"documento": documento, "nome_principal": nomePrincipal})
+	if err != nil {
+		return nil, err
+	}
+
+	return &id, nil
+}
+
+// atributoPessoa é um valor observado para uma pessoa em uma linha armazenada
+type atributoPessoa struct {
+	tipo  string
+	valor string
+}
+
+// fontePerfil descreve as linhas de uma tabela em que a pessoa aparece e os
+// atributos que cada uma revela. No from, r é a requisição que trouxe a
+// linha, e é o escopo dela que decide se a linha entra no perfil.
+type fontePerfil struct {
+	fonte string
+	from  string
+	// linha é o alias da linha lida, com as colunas e o conteúdo cifrado
+	linha string
+	// pessoa é a coluna que referencia a pessoa
+	pessoa string
+	data   string
+	// tabela é nil quando a linha não tem colunas cifradas
+	tabela    *tabelaCifrada
+	colunas   []string
+	atributos func(campos map[string]string) []atributoPessoa
+}
+
+var (
+	colunasChavePixPerfil = []string{"chave", "nome_proprietario", "agencia", "numero_conta", "participante", "nome_banco"}
+	colunasBDVPerfil      = []string{"cnpj_participante", "agencia", "conta"}
+)
+
+// atributosChavePix são os atributos do titular de uma chave ou de um evento
+func atributosChavePix(campos map[string]string) []atributoPessoa {
+	return []atributoPessoa{
+		{AtributoNome, campos["nome_proprietario"]},
+		{AtributoChavePix, campos["chave"]},
+		{AtributoConta, formatarConta(campos["participante"], campos["agencia"], campos["numero_conta"])},
+		{AtributoInstituicao, nomeInstituicao(campos["nome_banco"], campos["participante"])},
+	}
+}
+
+// atributosContaBDV é a conta de um bem, direito ou valor
+func atributosContaBDV(campos map[string]string) []atributoPessoa {
+	return []atributoPessoa{
+		{AtributoConta, formatarConta(campos["cnpj_participante"], campos["agencia"], campos["conta"])},
+	}
+}
+
+var fontesPerfil = []fontePerfil{
+	{
+		fonte:     "chave_pix",
+		from:      "chave_pix x JOIN requisicao_pix r ON r.id = x.id_requisicao",
+		linha:     "x",
+		pessoa:    "x.pessoa_id",
+		data:      "r.data",
+		tabela:    &tabelaChavePix,
+		colunas:   colunasChavePixPerfil,
+		atributos: atributosChavePix,
+	},
+	{
+		fonte:   "chave_pix",
+		from:    "chave_pix x JOIN requisicao_pix r ON r.id = x.id_requisicao",
+		linha:   "x",
+		pessoa:  "x.pessoa_busca_id",
+		data:    "r.data",
+		tabela:  &tabelaChavePix,
+		colunas: []string{"nome_proprietario_busca"},
+		atributos: func(campos map[string]string) []atributoPessoa {
+			return []atributoPessoa{{AtributoNome, campos["nome_proprietario_busca"]}}
+		},
+	},
+	{
+		fonte: "evento_chave_pix",
+		from: `evento_chave_pix x
+			JOIN chave_pix cp ON cp.id = x.id_chave
+			JOIN requisicao_pix r ON r.id = cp.id_requisicao`,
+		linha:     "x",
+		pessoa:    "x.pessoa_id",
+		data:      "r.data",
+		tabela:    &tabelaEventoChavePix,
+		colunas:   colunasChavePixPerfil,
+		atributos: atributosChavePix,
+	},
+	{
+		fonte:   "requisicao_relacionamento_ccs",
+		from:    "requisicao_relacionamento_ccs r",
+		linha:   "r",
+		pessoa:  "r.pessoa_id",
+		data:    colunaDataRequisicaoCCS,
+		tabela:  &tabelaRequisicaoCCS,
+		colunas: []string{"nome"},
+		atributos: func(campos map[string]string) []atributoPessoa {
+			return []atributoPessoa{{AtributoNome, campos["nome"]}}
+		},
+	},
+	{
+		fonte:   "relacionamento_ccs",
+		from:    "relacionamento_ccs x JOIN requisicao_relacionamento_ccs r ON r.id = x.id_requisicao",
+		linha:   "x",
+		pessoa:  "x.pessoa_id",
+		data:    colunaDataRequisicaoCCS,
+		tabela:  &tabelaRelacionamentoCCS,
+		colunas: []string{"nome_pessoa", "cnpj_participante", "nome_banco_participante"},
+		atributos: func(campos map[string]string) []atributoPessoa {
+			return []atributoPessoa{
+				{AtributoNome, campos["nome_pessoa"]},
+				{AtributoInstituicao, nomeInstituicao(campos["nome_banco_participante"], campos["cnpj_participante"])},
+			}
+		},
+	},
+	{
+		// A conta de um BDV é do titular do relacionamento
+		fonte: "bem_direito_valor_ccs",
+		from: `bem_direito_valor_ccs x
+			JOIN relacionamento_ccs rc ON rc.id = x.id_relacionamento
+			JOIN requisicao_relacionamento_ccs r ON r.id = rc.id_requisicao`,
+		linha:     "x",
+		pessoa:    "rc.pessoa_id",
+		data:      colunaDataRequisicaoCCS,
+		tabela:    &tabelaBemDireitoValorCCS,
+		colunas:   colunasBDVPerfil,
+		atributos: atributosContaBDV,
+	},
+	{
+		fonte: "vinculados_bdv_ccs",
+		from: `vinculados_bdv_ccs x
+			JOIN bem_direito_valor_ccs b ON b.id = x.id_bdv
+			JOIN relacionamento_ccs rc ON rc.id = b.id_relacionamento
+			JOIN requisicao_relacionamento_ccs r ON r.id = rc.id_requisicao`,
+		linha:   "x",
+		pessoa:  "x.pessoa_id",
+		data:    colunaDataRequisicaoCCS,
+		tabela:  &tabelaVinculadosBDVCCS,
+		colunas: []string{"nome_pessoa", "nome_pessoa_receita"},
+		atributos: func(campos map[string]string) []atributoPessoa {
+			return []atributoPessoa{
+				{AtributoNome, campos["nome_pessoa"]},
+				{AtributoNome, campos["nome_pessoa_receita"]},
+			}
+		},
+	},
+	{
+		// e também de cada vinculado
+		fonte: "vinculados_bdv_ccs",
+		from: `bem_direito_valor_ccs x
+			JOIN vinculados_bdv_ccs v ON v.id_bdv = x.id
+			JOIN relacionamento_ccs rc ON rc.id = x.id_relacionamento
+			JOIN requisicao_relacionamento_ccs r ON r.id = rc.id_requisicao`,
+		linha:     "x",
+		pessoa:    "v.pessoa_id",
+		data:      colunaDataRequisicaoCCS,
+		tabela:    &tabelaBemDireitoValorCCS,
+		colunas:   colunasBDVPerfil,
+		atributos: atributosContaBDV,
+	},
+}
+
+// consultaFontesPerfil monta a consulta das linhas do escopo em que a pessoa
+// aparece. Cada linha traz o índice da fonte em fontesPerfil, as colunas em
+// claro em JSON e o conteúdo cifrado.
+func consultaFontesPerfil(c *consultaSQL, escopo Escopo, idPessoa int) string {
+	id := c.arg(idPessoa)
+	partes := make([]string, len(fontesPerfil))
+	for i, f := range fontesPerfil {
+		campos := make([]string, 0, 2*len(f.colunas))
+		for _, coluna := range f.colunas {
+			campos = append(campos, "'"+coluna+"'", f.linha+"."+coluna)
+		}
+		cifrado := "NULL::int, NULL::bytea"
+		if f.tabela != nil {
+			cifrado = f.linha + ".id_chave_dados, " + f.linha + ".dados_cifrados"
+		}
+		partes[i] = fmt.Sprintf(`SELECT %d, %s.id, %s, json_strip_nulls(json_build_object(%s)), %s
+		FROM %s
+		WHERE %s = %s AND %s`,
+			i, f.linha, f.data, strings.Join(campos, ", "), cifrado,
+			f.from, f.pessoa, id, escopo.condicao(c, "r"))
+	}
+	return strings.Join(partes, "\n\t\tUNION ALL\n\t\t")
+}
+
+// visivelNoEscopo monta a condição que exige que a pessoa (alias p) apareça em
+// ao menos uma requisição visível no escopo
+func visivelNoEscopo(c *consultaSQL, escopo Escopo) string {
+	if escopo.Total() {
+		return "TRUE"
+	}
+	return `(EXISTS (
+			SELECT 1 FROM chave_pix cp JOIN requisicao_pix req ON req.id = cp.id_requisicao
+			WHERE (cp.pessoa_id = p.id OR cp.pessoa_busca_id = p.id) AND ` + escopo.condicao(c, "req") + `
+		) OR EXISTS (
+			SELECT 1 FROM evento_chave_pix e
+			JOIN chave_pix cp ON cp.id = e.id_chave
+			JOIN requisicao_pix req ON req.id = cp.id_requisicao
+			WHERE e.pessoa_id = p.id AND ` + escopo.condicao(c, "req") + `
+		) OR EXISTS (
+			SELECT 1 FROM requisicao_relacionamento_ccs req
+			WHERE ` + escopo.condicao(c, "req") + ` AND (req.pessoa_id = p.id
+				OR EXISTS (SELECT 1 FROM relacionamento_ccs rc WHERE rc.id_requisicao = req.id AND rc.pessoa_id = p.id)
+				OR EXISTS (
+					SELECT 1 FROM vinculados_bdv_ccs v
+					JOIN bem_direito_valor_ccs b ON b.id = v.id_bdv
+					JOIN relacionamento_ccs rc ON rc.id = b.id_relacionamento
+					WHERE rc.id_requisicao = req.id AND v.pessoa_id = p.id
+				))
+		))`
+}
+
+// BuscarPerfil consolida tudo o que se sabe sobre a pessoa do documento.
+// A pessoa precisa aparecer em alguma requisição do escopo; os atributos, o
+// nome principal, as datas e as requisições do perfil também ficam restritos
+// a ele.
+func (r *PessoaRepository) BuscarPerfil(escopo Escopo, documento string) (*models.PerfilPessoa, error) {
+	documento = NormalizarDocumento(documento)
+	if documento == "" {
+		return nil, ErrPessoaNaoEncontrada
+	}
+
+	// A pessoa cifrada é encontrada pelo índice cego do documento
+	indice := indiceUnicoBusca(tabelaPessoa, map[string]string{"documento": documento})
+	c := &consultaSQL{}
+	c.onde("(p.documento = " + c.arg(documento) + " OR p.indice_unico = " + c.arg(indice) + ")")
+	c.onde(visivelNoEscopo(c, escopo))
+
+	var perfil models.PerfilPessoa
+	perfil.Documento = documento
+	err := r.DB.QueryRow(`
+		SELECT p.id, p.tipo_pessoa, p.primeira_ocorrencia, p.ultima_ocorrencia
+		FROM pessoa p
+		`+c.where(),
+		c.args...,
+	).Scan(
+		&perfil.ID, &perfil.TipoPessoa,
+		&perfil.PrimeiraOcorrencia, &perfil.UltimaOcorrencia,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrPessoaNaoEncontrada
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.carregarAtributos(escopo, &perfil); err != nil {
+		return nil, err
+	}
+
+	perfil.Requisicoes, err = r.buscarRequisicoes(escopo, perfil.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &perfil, nil
+}
+
+// carregarAtributos reconstrói nomes, chaves, contas e instituições do
+// perfil a partir das linhas visíveis no escopo, decifrando as cifradas. O
+// nome principal é o observado mais recentemente.
+func (r *PessoaRepository) carregarAtributos(escopo Escopo, perfil *models.PerfilPessoa) error {
+	c := &consultaSQL{}
+	rows, err := r.DB.Query(consultaFontesPerfil(c, escopo, perfil.ID), c.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	agregados := map[atributoPessoa]*models.AtributoPessoa{}
+	tipos := map[*models.AtributoPessoa]string{}
+	primeiraLinha := true
+	for rows.Next() {
+		var indice, id int
+		var visto time.Time
+		var camposJSON, dadosCifrados []byte
+		var idChaveDados sql.NullInt64
+		if err := rows.Scan(&indice, &id, &visto, &camposJSON, &idChaveDados, &dadosCifrados); err != nil {
+			return err
+		}
+		f := fontesPerfil[indice]
+
+		campos := map[string]string{}
+		if err := json.Unmarshal(camposJSON, &campos); err != nil {
+			return err
+		}
+		if dadosCifrados != nil && f.tabela != nil {
+			decifrados, err := decifrarCampos(*f.tabela, id, &models.DadosCifrados{Chave: int(idChaveDados.Int64), Dados: dadosCifrados})
+			if err != nil {
+				return err
+			}
+			for coluna, valor := range decifrados {
+				campos[coluna] = valor
+			}
+		}
+
+		if primeiraLinha || visto.Before(perfil.PrimeiraOcorrencia) {
+			perfil.PrimeiraOcorrencia = visto
+		}
+		if primeiraLinha || visto.After(perfil.UltimaOcorrencia) {
+			perfil.UltimaOcorrencia = visto
+		}
+		primeiraLinha = false
+
+		for _, a := range f.atributos(campos) {
+			a.valor = valorAtributo(a.valor)
+			if a.valor == "" {
+				continue
+			}
+			agregado, ok := agregados[a]
+			if !ok {
+				agregado = &models.AtributoPessoa{Valor: a.valor, Fontes: []string{}, PrimeiraOcorrencia: visto, UltimaOcorrencia: visto}
+				agregados[a] = agregado
+				tipos[agregado] = a.tipo
+			}
+			if !contem(agregado.Fontes, f.fonte) {
+				agregado.Fontes = append(agregado.Fontes, f.fonte)
+			}
+			if visto.Before(agregado.PrimeiraOcorrencia) {
+				agregado.PrimeiraOcorrencia = visto
+			}
+			if visto.After(agregado.UltimaOcorrencia) {
+				agregado.UltimaOcorrencia = visto
+			}
+			agregado.Ocorrencias++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	listas := map[string][]models.AtributoPessoa{}
+	for agregado, tipo := range tipos {
+		listas[tipo] = append(listas[tipo], *agregado)
+	}
+	for _, lista := range listas {
+		sort.Slice(lista, func(i, j int) bool {
+			if !lista[i].UltimaOcorrencia.Equal(lista[j].UltimaOcorrencia) {
+				return lista[i].UltimaOcorrencia.After(lista[j].UltimaOcorrencia)
+			}
+			return lista[i].Valor < lista[j].Valor
+		})
+	}
+
+	perfil.Nomes = append([]models.AtributoPessoa{}, listas[AtributoNome]...)
+	perfil.Chaves = append([]models.AtributoPessoa{}, listas[AtributoChavePix]...)
+	perfil.Contas = append([]models.AtributoPessoa{}, listas[AtributoConta]...)
+	perfil.Instituicoes = append([]models.AtributoPessoa{}, listas[AtributoInstituicao]...)
+	if len(perfil.Nomes) > 0 {
+		perfil.NomePrincipal = perfil.Nomes[0].Valor
+	}
+
+	return nil
+}
+
+// valorAtributo remove os espaços das pontas e limita o valor a 255 caracteres
+func valorAtributo(valor string) string {
+	valor = strings.TrimSpace(valor)
+	if runas := []rune(valor); len(runas) > 255 {
+		valor = string(runas[:255])
+	}
+	return valor
+}
+
+func contem(lista []string, valor string) bool {
+	for _, item := range lista {
+		if item == valor {
+			return true
+		}
+	}
+	return false
+}
+
+// buscarRequisicoes lista as requisições do escopo em que a pessoa aparece
+func (r *PessoaRepository) buscarRequisicoes(escopo Escopo, idPessoa int) ([]models.ReferenciaRequisicao, error) {
+	c := &consultaSQL{}
+	id := c.arg(idPessoa)
+
+	query := `
+		SELECT 'pix' AS origem, req.id, req.data, COALESCE(req.caso, ''), COALESCE(req.cpf_responsavel, '')
+		FROM requisicao_pix req
+		WHERE ` + escopo.condicao(c, "req") + ` AND EXISTS (
+			SELECT 1 FROM chave_pix cp
+			LEFT JOIN evento_chave_pix e ON e.id_chave = cp.id
+			WHERE cp.id_requisicao = req.id
+				AND (cp.pessoa_id = ` + id + ` OR cp.pessoa_busca_id = ` + id + ` OR e.pessoa_id = ` + id + `)
+		)
+		UNION ALL
+		SELECT 'ccs', r.id, ` + colunaDataRequisicaoCCS + `, COALESCE(r.caso, ''), COALESCE(r.cpf_responsavel, '')
+		FROM requisicao_relacionamento_ccs r
+		WHERE ` + escopo.condicao(c, "r") + ` AND (r.pessoa_id = ` + id + ` OR EXISTS (
+			SELECT 1 FROM relacionamento_ccs rc
+			LEFT JOIN bem_direito_valor_ccs b ON b.id_relacionamento = rc.id
+			LEFT JOIN vinculados_bdv_ccs v ON v.id_bdv = b.id
+			WHERE rc.id_requisicao = r.id AND (rc.pessoa_id = ` + id + ` OR v.pessoa_id = ` + id + `)
+		))
+		ORDER BY 3 DESC, 2 DESC
+	`
+
+	rows, err := r.DB.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requisicoes := make([]models.ReferenciaRequisicao, 0)
+	for rows.Next() {
+		var ref models.ReferenciaRequisicao
+		if err := rows.Scan(&ref.Origem, &ref.ID, &ref.Data, &ref.Caso, &ref.CPFResponsavel); err != nil {
+			return nil, err
+		}
+		requisicoes = append(requisicoes, ref)
+	}
+
+	return requisicoes, rows.Err()
+}
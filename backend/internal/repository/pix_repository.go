@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	_"errors"
+	"fmt"
+	"time"
 
 	"github.com/tassyosilva/consultapix/internal/database"
 	"github.com/tassyosilva/consultapix/internal/database/models"
@@ -33,30 +35,39 @@ func (r *PixRepository) CriarRequisicaoPix(req *models.RequisicaoPix) (int, erro
 	}
 	defer tx.Rollback()
 
-	// Inserir requisição PIX
+	// Inserir requisição PIX; a chave buscada e os vínculos são gravados
+	// depois, cifrados com o ID da linha
 	insertQuery := `
 		INSERT INTO requisicao_pix (
-			data, cpf_responsavel, lotacao, caso, tipo_busca, chave_busca, 
-			motivo_busca, resultado, vinculos, autorizado, cpf_autorizacao, 
-			nome_autorizacao, data_hora_autorizacao, token_autorizacao
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+			data, cpf_responsavel, lotacao, caso, tipo_busca,
+			motivo_busca, resultado, autorizado, cpf_autorizacao, 
+			nome_autorizacao, data_hora_autorizacao, token_autorizacao,
+			id_credencial_bacen, usuario_bacen, id_unidade
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 		RETURNING id
 	`
 	var id int
 	err = tx.QueryRow(
 		insertQuery,
 		req.Data, req.CPFResponsavel, req.Lotacao, req.Caso, req.TipoBusca,
-		req.ChaveBusca, req.MotivoBusca, req.Resultado, vinculosJSON, req.Autorizado,
+		req.MotivoBusca, req.Resultado, req.Autorizado,
 		req.CPFAutorizacao, req.NomeAutorizacao, req.DataHoraAutorizacao, req.TokenAutorizacao,
+		req.IDCredencialBacen, req.UsuarioBacen, req.IDUnidade,
 	).Scan(&id)
 	if err != nil {
 		return 0, err
 	}
+	err = gravarDadosSensiveis(tx, cifraDados.Load(), tabelaRequisicaoPix, id, map[string]string{
+		"chave_busca": req.ChaveBusca, "vinculos": string(vinculosJSON),
+	})
+	if err != nil {
+		return 0, err
+	}
 
 	// Se há chaves PIX, inseri-las
 	if len(req.Chaves) > 0 {
 		for _, chave := range req.Chaves {
-			chaveID, err := r.inserirChavePix(tx, chave, id)
+			chaveID, err := r.inserirChavePix(tx, chave, id, req.Data)
 			if err != nil {
 				return 0, err
 			}
@@ -64,7 +75,7 @@ func (r *PixRepository) CriarRequisicaoPix(req *models.RequisicaoPix) (int, erro
 			// Se há eventos, inseri-los
 			if len(chave.EventosVinculo) > 0 {
 				for _, evento := range chave.EventosVinculo {
-					err = r.inserirEventoChavePix(tx, evento, chaveID)
+					err = r.inserirEventoChavePix(tx, evento, chaveID, req.Data)
 					if err != nil {
 						return 0, err
 					}
@@ -80,61 +91,91 @@ func (r *PixRepository) CriarRequisicaoPix(req *models.RequisicaoPix) (int, erro
 	return id, nil
 }
 
-// inserirChavePix insere uma chave PIX e retorna o ID
-func (r *PixRepository) inserirChavePix(tx *sql.Tx, chave models.ChavePix, idRequisicao int) (int, error) {
+// inserirChavePix insere uma chave PIX, vinculada ao titular e ao documento
+// buscado, e retorna o ID
+func (r *PixRepository) inserirChavePix(tx *sql.Tx, chave models.ChavePix, idRequisicao int, visto time.Time) (int, error) {
+	pessoaID, err := vincularPessoa(tx, chave.CPFCNPJ, chave.NomeProprietario, visto)
+	if err != nil {
+		return 0, err
+	}
+	pessoaBuscaID, err := vincularPessoa(tx, chave.CPFCNPJBusca, chave.NomeProprietarioBusca, visto)
+	if err != nil {
+		return 0, err
+	}
+	contaID, err := vincularConta(tx, chave.Participante, chave.Agencia, chave.NumeroConta, chave.TipoConta, chave.NomeBanco, visto)
+	if err != nil {
+		return 0, err
+	}
+
+	// As colunas sensíveis são gravadas depois, cifradas com o ID da linha
 	insertQuery := `
 		INSERT INTO chave_pix (
-			chave, tipo_chave, status, data_abertura_reivindicacao, cpf_cnpj, 
-			nome_proprietario, nome_fantasia, participante, agencia, numero_conta, 
+			tipo_chave, status, data_abertura_reivindicacao, participante,
 			tipo_conta, data_abertura_conta, proprietario_da_chave_desde, data_criacao, 
-			ultima_modificacao, numero_banco, nome_banco, cpf_cnpj_busca, 
-			nome_proprietario_busca, id_requisicao
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+			ultima_modificacao, numero_banco, nome_banco, id_requisicao, pessoa_id, pessoa_busca_id, conta_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 		RETURNING id
 	`
 	var id int
-	err := tx.QueryRow(
+	err = tx.QueryRow(
 		insertQuery,
-		chave.Chave, chave.TipoChave, chave.Status, chave.DataAberturaReivindicacao,
-		chave.CPFCNPJ, chave.NomeProprietario, chave.NomeFantasia, chave.Participante,
-		chave.Agencia, chave.NumeroConta, chave.TipoConta, chave.DataAberturaConta,
+		chave.TipoChave, chave.Status, chave.DataAberturaReivindicacao, chave.Participante,
+		chave.TipoConta, chave.DataAberturaConta,
 		chave.ProprietarioDaChaveDesde, chave.DataCriacao, chave.UltimaModificacao,
-		chave.NumeroBanco, chave.NomeBanco, chave.CPFCNPJBusca, chave.NomeProprietarioBusca,
-		idRequisicao,
+		chave.NumeroBanco, chave.NomeBanco, idRequisicao, pessoaID, pessoaBuscaID, contaID,
 	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	err = gravarDadosSensiveis(tx, cifraDados.Load(), tabelaChavePix, id, valoresCampos(camposChavePix(&chave)))
 	return id, err
 }
 
-// inserirEventoChavePix insere um evento de chave PIX
-func (r *PixRepository) inserirEventoChavePix(tx *sql.Tx, evento models.EventoChavePix, idChave int) error {
+// inserirEventoChavePix insere um evento de chave PIX vinculado à pessoa do evento
+func (r *PixRepository) inserirEventoChavePix(tx *sql.Tx, evento models.EventoChavePix, idChave int, visto time.Time) error {
+	pessoaID, err := vincularPessoa(tx, evento.CPFCNPJ, evento.NomeProprietario, visto)
+	if err != nil {
+		return err
+	}
+	contaID, err := vincularConta(tx, evento.Participante, evento.Agencia, evento.NumeroConta, evento.TipoConta, evento.NomeBanco, visto)
+	if err != nil {
+		return err
+	}
+
 	insertQuery := `
 		INSERT INTO evento_chave_pix (
-			tipo_evento, motivo_evento, data_evento, chave, tipo_chave, cpf_cnpj, 
-			nome_proprietario, nome_fantasia, participante, agencia, numero_conta, 
-			tipo_conta, data_abertura_conta, numero_banco, nome_banco, id_chave
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+			tipo_evento, motivo_evento, data_evento, tipo_chave, participante,
+			tipo_conta, data_abertura_conta, numero_banco, nome_banco, id_chave, pessoa_id, conta_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id
 	`
-	_, err := tx.Exec(
+	var id int
+	err = tx.QueryRow(
 		insertQuery,
-		evento.TipoEvento, evento.MotivoEvento, evento.DataEvento, evento.Chave,
-		evento.TipoChave, evento.CPFCNPJ, evento.NomeProprietario, evento.NomeFantasia,
-		evento.Participante, evento.Agencia, evento.NumeroConta, evento.TipoConta,
-		evento.DataAberturaConta, evento.NumeroBanco, evento.NomeBanco, idChave,
-	)
-	return err
+		evento.TipoEvento, evento.MotivoEvento, evento.DataEvento,
+		evento.TipoChave, evento.Participante, evento.TipoConta,
+		evento.DataAberturaConta, evento.NumeroBanco, evento.NomeBanco, idChave, pessoaID, contaID,
+	).Scan(&id)
+	if err != nil {
+		return err
+	}
+	return gravarDadosSensiveis(tx, cifraDados.Load(), tabelaEventoChavePix, id, valoresCampos(camposEventoChavePix(&evento)))
 }
 
-// BuscarRequisicoesPix busca todas as requisições PIX de um CPF responsável
-func (r *PixRepository) BuscarRequisicoesPix(cpfResponsavel string) ([]models.RequisicaoPix, error) {
+// BuscarRequisicoesPix busca todas as requisições PIX visíveis no escopo
+func (r *PixRepository) BuscarRequisicoesPix(escopo Escopo) ([]models.RequisicaoPix, error) {
+	c := &consultaSQL{}
+	c.onde(escopo.condicao(c, "r"))
+
 	query := `
-		SELECT id, data, cpf_responsavel, lotacao, caso, tipo_busca, chave_busca, 
-			motivo_busca, resultado, vinculos, autorizado, cpf_autorizacao, 
-			nome_autorizacao, data_hora_autorizacao, token_autorizacao
-		FROM requisicao_pix
-		WHERE cpf_responsavel = $1
-		ORDER BY id DESC
+		SELECT r.id, r.data, r.cpf_responsavel, r.lotacao, r.caso, r.tipo_busca, COALESCE(r.chave_busca, ''), 
+			r.motivo_busca, r.resultado, r.vinculos, r.autorizado, r.cpf_autorizacao, 
+			r.nome_autorizacao, r.data_hora_autorizacao, r.token_autorizacao,
+			r.id_chave_dados, r.dados_cifrados
+		FROM requisicao_pix r ` + c.where() + `
+		ORDER BY r.id DESC
 	`
-	rows, err := r.DB.Query(query, cpfResponsavel)
+	rows, err := r.DB.Query(query, c.args...)
 	if err != nil {
 		return nil, err
 	}
@@ -143,17 +184,21 @@ func (r *PixRepository) BuscarRequisicoesPix(cpfResponsavel string) ([]models.Re
 	var requisicoes []models.RequisicaoPix
 	for rows.Next() {
 		var req models.RequisicaoPix
-		var vinculosJSON []byte
+		var vinculosJSON, dadosCifrados []byte
+		var idChaveDados sql.NullInt64
 		
 		err := rows.Scan(
 			&req.ID, &req.Data, &req.CPFResponsavel, &req.Lotacao, &req.Caso, 
 			&req.TipoBusca, &req.ChaveBusca, &req.MotivoBusca, &req.Resultado, 
 			&vinculosJSON, &req.Autorizado, &req.CPFAutorizacao, &req.NomeAutorizacao, 
-			&req.DataHoraAutorizacao, &req.TokenAutorizacao,
+			&req.DataHoraAutorizacao, &req.TokenAutorizacao, &idChaveDados, &dadosCifrados,
 		)
 		if err != nil {
 			return nil, err
 		}
+		if vinculosJSON, err = abrirRequisicaoPix(&req, vinculosJSON, idChaveDados, dadosCifrados); err != nil {
+			return nil, err
+		}
 
 		// Converter JSON para interface{}
 		if len(vinculosJSON) > 0 {
@@ -171,4 +216,239 @@ func (r *PixRepository) BuscarRequisicoesPix(cpfResponsavel string) ([]models.Re
 	}
 
 	return requisicoes, nil
-}
\ No newline at end of file
+}
+
+// jsonChavesPix agrega as chaves de uma requisição (alias r) com seus eventos em
+// um único JSON. As linhas cifradas vêm em "cifrado" e são abertas por abrirChavesPix.
+var jsonChavesPix = `
+	COALESCE((
+		SELECT json_agg(json_build_object(
+			'id', c.id, 'chave', c.chave, 'tipoChave', c.tipo_chave, 'status', c.status,
+			'dataAberturaReivindicacao', c.data_abertura_reivindicacao, 'cpfCnpj', c.cpf_cnpj,
+			'nomeProprietario', c.nome_proprietario, 'nomeFantasia', c.nome_fantasia,
+			'participante', c.participante, 'agencia', c.agencia, 'numeroConta', c.numero_conta,
+			'tipoConta', c.tipo_conta, 'dataAberturaConta', c.data_abertura_conta,
+			'proprietarioDaChaveDesde', c.proprietario_da_chave_desde, 'dataCriacao', c.data_criacao,
+			'ultimaModificacao', c.ultima_modificacao, 'numeroBanco', c.numero_banco,
+			'nomeBanco', c.nome_banco, 'cpfCnpjBusca', c.cpf_cnpj_busca,
+			'nomeProprietarioBusca', c.nome_proprietario_busca, 'idRequisicao', c.id_requisicao,
+			'cifrado', ` + jsonCifrado("c") + `,
+			'eventosVinculo', COALESCE((
+				SELECT json_agg(json_build_object(
+					'id', e.id, 'tipoEvento', e.tipo_evento, 'motivoEvento', e.motivo_evento,
+					'dataEvento', e.data_evento, 'chave', e.chave, 'tipoChave', e.tipo_chave,
+					'cpfCnpj', e.cpf_cnpj, 'nomeProprietario', e.nome_proprietario,
+					'nomeFantasia', e.nome_fantasia, 'participante', e.participante,
+					'agencia', e.agencia, 'numeroConta', e.numero_conta, 'tipoConta', e.tipo_conta,
+					'dataAberturaConta', e.data_abertura_conta, 'numeroBanco', e.numero_banco,
+					'nomeBanco', e.nome_banco, 'idChave', e.id_chave,
+					'cifrado', ` + jsonCifrado("e") + `
+				) ORDER BY e.data_evento, e.id)
+				FROM evento_chave_pix e
+				WHERE e.id_chave = c.id
+			), '[]')
+		) ORDER BY c.id)
+		FROM chave_pix c
+		WHERE c.id_requisicao = r.id
+	), '[]')`
+
+// ListarRequisicoesPix lista, paginado por cursor, o resumo das requisições PIX visíveis no escopo
+func (r *PixRepository) ListarRequisicoesPix(escopo Escopo, filtro FiltroHistorico) ([]models.ResumoRequisicaoPix, string, error) {
+	c := &consultaSQL{}
+	c.onde(escopo.condicao(c, "r"))
+
+	if filtro.DataInicio != nil {
+		c.onde("r.data >= " + c.arg(*filtro.DataInicio))
+	}
+	if filtro.DataFim != nil {
+		c.onde("r.data < " + c.arg(*filtro.DataFim))
+	}
+	if filtro.Caso != "" {
+		c.onde("r.caso ILIKE " + c.arg("%"+filtro.Caso+"%"))
+	}
+	if filtro.TipoBusca != "" {
+		c.onde("r.tipo_busca = " + c.arg(filtro.TipoBusca))
+	}
+	if filtro.Alvo != "" {
+		c.onde(condicaoIndice(c, "r", "chave_busca", IndiceChave, filtro.Alvo))
+	}
+	if filtro.Resultado != "" {
+		c.onde("r.resultado ILIKE " + c.arg("%"+filtro.Resultado+"%"))
+	}
+	if filtro.Status != "" {
+		c.onde("EXISTS (SELECT 1 FROM chave_pix c WHERE c.id_requisicao = r.id AND c.status = " + c.arg(filtro.Status) + ")")
+	}
+	if filtro.CPFResponsavel != "" {
+		c.onde("r.cpf_responsavel = " + c.arg(filtro.CPFResponsavel))
+	}
+
+	ordem, err := c.paginar(filtro, "r.data", "r.id")
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT r.id, r.data, r.cpf_responsavel, r.lotacao, r.caso, r.tipo_busca, COALESCE(r.chave_busca, ''),
+			r.motivo_busca, r.resultado, r.autorizado,
+			(SELECT COUNT(*) FROM chave_pix c WHERE c.id_requisicao = r.id),
+			r.id_chave_dados, r.dados_cifrados
+		FROM requisicao_pix r
+		%s
+		%s
+	`, c.where(), ordem)
+	rows, err := r.DB.Query(query, c.args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	resumos := make([]models.ResumoRequisicaoPix, 0)
+	for rows.Next() {
+		var res models.ResumoRequisicaoPix
+		var idChaveDados sql.NullInt64
+		var dadosCifrados []byte
+		err := rows.Scan(
+			&res.ID, &res.Data, &res.CPFResponsavel, &res.Lotacao, &res.Caso, &res.TipoBusca,
+			&res.ChaveBusca, &res.MotivoBusca, &res.Resultado, &res.Autorizado, &res.QuantidadeChaves,
+			&idChaveDados, &dadosCifrados,
+		)
+		if err != nil {
+			return nil, "", err
+		}
+		req := models.RequisicaoPix{ID: res.ID, ChaveBusca: res.ChaveBusca}
+		if _, err := abrirRequisicaoPix(&req, nil, idChaveDados, dadosCifrados); err != nil {
+			return nil, "", err
+		}
+		res.ChaveBusca = req.ChaveBusca
+		resumos = append(resumos, res)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var proximoCursor string
+	if len(resumos) > filtro.limite() {
+		resumos = resumos[:filtro.limite()]
+		ultimo := resumos[len(resumos)-1]
+		proximoCursor = codificarCursor(ultimo.Data, ultimo.ID)
+	}
+
+	return resumos, proximoCursor, nil
+}
+
+// BuscarRequisicaoPixPorID carrega uma requisição PIX completa, com chaves e eventos, em uma única consulta
+func (r *PixRepository) BuscarRequisicaoPixPorID(escopo Escopo, id int) (*models.RequisicaoPix, error) {
+	c := &consultaSQL{}
+	c.onde("r.id = " + c.arg(id))
+	c.onde(escopo.condicao(c, "r"))
+
+	query := fmt.Sprintf(`
+		SELECT r.id, r.data, r.cpf_responsavel, r.lotacao, r.caso, r.tipo_busca, COALESCE(r.chave_busca, ''),
+			r.motivo_busca, r.resultado, r.vinculos, r.autorizado, r.cpf_autorizacao,
+			r.nome_autorizacao, r.data_hora_autorizacao, r.token_autorizacao,
+			r.id_credencial_bacen, COALESCE(r.usuario_bacen, ''), r.id_chave_dados, r.dados_cifrados,
+			%s
+		FROM requisicao_pix r
+		%s
+	`, jsonChavesPix, c.where())
+
+	var req models.RequisicaoPix
+	var vinculosJSON, dadosCifrados, chavesJSON []byte
+	var idChaveDados sql.NullInt64
+	err := r.DB.QueryRow(query, c.args...).Scan(
+		&req.ID, &req.Data, &req.CPFResponsavel, &req.Lotacao, &req.Caso,
+		&req.TipoBusca, &req.ChaveBusca, &req.MotivoBusca, &req.Resultado,
+		&vinculosJSON, &req.Autorizado, &req.CPFAutorizacao, &req.NomeAutorizacao,
+		&req.DataHoraAutorizacao, &req.TokenAutorizacao, &req.IDCredencialBacen, &req.UsuarioBacen,
+		&idChaveDados, &dadosCifrados, &chavesJSON,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRequisicaoNaoEncontrada
+		}
+		return nil, err
+	}
+
+	if vinculosJSON, err = abrirRequisicaoPix(&req, vinculosJSON, idChaveDados, dadosCifrados); err != nil {
+		return nil, err
+	}
+	if len(vinculosJSON) > 0 {
+		if err = json.Unmarshal(vinculosJSON, &req.Vinculos); err != nil {
+			return nil, err
+		}
+	}
+	if err = json.Unmarshal(chavesJSON, &req.Chaves); err != nil {
+		return nil, err
+	}
+	if err = abrirChavesPix(req.Chaves); err != nil {
+		return nil, err
+	}
+
+	return &req, nil
+}
+
+// BuscarResultadoRecente retorna a requisição visível no escopo mais recente
+// do mesmo tipo e alvo feita no caso desde o instante informado, ou nil se não
+// houver. Requisições que falharam no BACEN não são reaproveitadas.
+func (r *PixRepository) BuscarResultadoRecente(escopo Escopo, tipoBusca, chaveBusca, caso string, desde time.Time) (*models.RequisicaoPix, error) {
+	c := &consultaSQL{}
+	c.onde(escopo.condicao(c, "r"))
+	c.onde("r.tipo_busca = " + c.arg(tipoBusca))
+	c.onde(condicaoIndice(c, "r", "chave_busca", IndiceChave, chaveBusca))
+	c.onde("r.caso = " + c.arg(caso))
+	c.onde("r.data >= " + c.arg(desde))
+	c.onde("r.resultado <> 'Erro no processamento da Solicitação'")
+
+	var req models.RequisicaoPix
+	var vinculosJSON, dadosCifrados []byte
+	var idChaveDados sql.NullInt64
+	err := r.DB.QueryRow(`
+		SELECT r.id, r.data, r.tipo_busca, COALESCE(r.chave_busca, ''), COALESCE(r.caso, ''), r.resultado, r.vinculos,
+			r.id_chave_dados, r.dados_cifrados
+		FROM requisicao_pix r
+		`+c.where()+`
+		ORDER BY r.data DESC, r.id DESC
+		LIMIT 1
+	`, c.args...).Scan(
+		&req.ID, &req.Data, &req.TipoBusca, &req.ChaveBusca, &req.Caso, &req.Resultado, &vinculosJSON,
+		&idChaveDados, &dadosCifrados,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if vinculosJSON, err = abrirRequisicaoPix(&req, vinculosJSON, idChaveDados, dadosCifrados); err != nil {
+		return nil, err
+	}
+
+	// Mantém o JSON original para devolvê-lo exatamente como foi gravado
+	if len(vinculosJSON) > 0 {
+		req.Vinculos = json.RawMessage(vinculosJSON)
+	}
+	return &req, nil
+}
+
+// BuscarIDRequisicaoPixAnterior retorna a requisição visível no escopo feita
+// imediatamente antes da informada sobre o mesmo alvo, ignorando falhas do BACEN
+func (r *PixRepository) BuscarIDRequisicaoPixAnterior(escopo Escopo, req *models.RequisicaoPix) (int, error) {
+	c := &consultaSQL{}
+	c.onde(escopo.condicao(c, "r"))
+	c.onde("r.tipo_busca = " + c.arg(req.TipoBusca))
+	c.onde(condicaoIndice(c, "r", "chave_busca", IndiceChave, req.ChaveBusca))
+	c.onde(fmt.Sprintf("(r.data, r.id) < (%s, %s)", c.arg(req.Data), c.arg(req.ID)))
+	c.onde("r.resultado <> 'Erro no processamento da Solicitação'")
+
+	var id int
+	err := r.DB.QueryRow(fmt.Sprintf(`
+		SELECT r.id FROM requisicao_pix r
+		%s
+		ORDER BY r.data DESC, r.id DESC
+		LIMIT 1
+	`, c.where()), c.args...).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrRequisicaoNaoEncontrada
+	}
+	return id, err
+}
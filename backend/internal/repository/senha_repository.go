@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tassyosilva/consultapix/internal/database"
+)
+
+// ErrRedefinicaoInvalida indica link de redefinição inexistente, expirado ou já usado
+var ErrRedefinicaoInvalida = errors.New("link de redefinição de senha inválido ou expirado")
+
+// SenhaRepository trata o histórico de senhas, os links de redefinição e as
+// tentativas de login usadas no bloqueio
+type SenhaRepository struct {
+	DB *sql.DB
+}
+
+func NewSenhaRepository() *SenhaRepository {
+	return &SenhaRepository{
+		DB: database.GetDB(),
+	}
+}
+
+// UltimasSenhas retorna os hashes da senha atual e das anteriores, da mais
+// recente para a mais antiga, até n no total
+func (r *SenhaRepository) UltimasSenhas(idUsuario, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	rows, err := r.DB.Query(`
+		SELECT hash FROM (
+			SELECT password AS hash, 0 AS ordem FROM usuario WHERE id = $1 AND password <> ''
+			UNION ALL
+			SELECT hash, ROW_NUMBER() OVER (ORDER BY id DESC) FROM historico_senha WHERE id_usuario = $1
+		) senhas
+		ORDER BY ordem
+		LIMIT $2
+	`, idUsuario, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+// AlterarSenha grava o novo hash e descarta os links de redefinição em aberto.
+// O histórico, a data da troca e a invalidação dos tokens ficam a cargo dos
+// gatilhos da tabela usuario.
+func (r *SenhaRepository) AlterarSenha(idUsuario int, hash string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	resultado, err := tx.Exec(`UPDATE usuario SET password = $2 WHERE id = $1`, idUsuario, hash)
+	if err != nil {
+		return err
+	}
+	if n, _ := resultado.RowsAffected(); n == 0 {
+		return ErrUsuarioNaoEncontrado
+	}
+	if _, err := tx.Exec(`DELETE FROM redefinicao_senha WHERE id_usuario = $1`, idUsuario); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CriarRedefinicao registra um link de redefinição, substituindo os anteriores do usuário
+func (r *SenhaRepository) CriarRedefinicao(hash string, idUsuario int, expiraEm time.Time) error {
+	if _, err := r.DB.Exec(`DELETE FROM redefinicao_senha WHERE expira_em < NOW() OR id_usuario = $1`, idUsuario); err != nil {
+		return err
+	}
+	_, err := r.DB.Exec(`
+		INSERT INTO redefinicao_senha (hash_token, id_usuario, expira_em)
+		VALUES ($1, $2, $3)
+	`, hash, idUsuario, expiraEm)
+	return err
+}
+
+// ConsultarRedefinicao retorna o usuário de um link válido sem consumi-lo
+func (r *SenhaRepository) ConsultarRedefinicao(hash string) (int, error) {
+	var idUsuario int
+	err := r.DB.QueryRow(`
+		SELECT id_usuario FROM redefinicao_senha WHERE hash_token = $1 AND expira_em > NOW()
+	`, hash).Scan(&idUsuario)
+	if err == sql.ErrNoRows {
+		return 0, ErrRedefinicaoInvalida
+	}
+	return idUsuario, err
+}
+
+// ConsumirRedefinicao remove o link e retorna o usuário. Cada link vale uma única vez.
+func (r *SenhaRepository) ConsumirRedefinicao(hash string) (int, error) {
+	var idUsuario int
+	err := r.DB.QueryRow(`
+		DELETE FROM redefinicao_senha
+		WHERE hash_token = $1 AND expira_em > NOW()
+		RETURNING id_usuario
+	`, hash).Scan(&idUsuario)
+	if err == sql.ErrNoRows {
+		return 0, ErrRedefinicaoInvalida
+	}
+	return idUsuario, err
+}
+
+// BloqueadoAte retorna o fim do bloqueio mais longo em vigor entre as chaves,
+// ou o instante zero se nenhuma estiver bloqueada
+func (r *SenhaRepository) BloqueadoAte(chaves ...string) (time.Time, error) {
+	var ate sql.NullTime
+	err := r.DB.QueryRow(`
+		SELECT MAX(bloqueado_ate) FROM tentativa_login
+		WHERE chave = ANY($1) AND bloqueado_ate > NOW()
+	`, pq.Array(chaves)).Scan(&ate)
+	return ate.Time, err
+}
+
+// RegistrarFalha conta uma falha de login na chave e retorna o total. A
+// contagem recomeça quando a última falha e o último bloqueio ficaram mais
+// de janela para trás.
+func (r *SenhaRepository) RegistrarFalha(chave string, janela time.Duration) (int, error) {
+	// Remove as chaves esquecidas, como em CriarDesafio
+	if _, err := r.DB.Exec(`
+		DELETE FROM tentativa_login
+		WHERE GREATEST(ultima_falha, COALESCE(bloqueado_ate, ultima_falha)) < NOW() - make_interval(secs => $1)
+	`, janela.Seconds()); err != nil {
+		return 0, err
+	}
+
+	var falhas int
+	err := r.DB.QueryRow(`
+		INSERT INTO tentativa_login (chave, falhas, ultima_falha)
+		VALUES ($1, 1, NOW())
+		ON CONFLICT (chave) DO UPDATE SET falhas = tentativa_login.falhas + 1, ultima_falha = NOW()
+		RETURNING falhas
+	`, chave).Scan(&falhas)
+	return falhas, err
+}
+
+// Bloquear impede novas tentativas na chave até o instante informado
+func (r *SenhaRepository) Bloquear(chave string, ate time.Time) error {
+	_, err := r.DB.Exec(`UPDATE tentativa_login SET bloqueado_ate = $2 WHERE chave = $1`, chave, ate)
+	return err
+}
+
+// LimparFalhas zera a contagem das chaves depois de um login concluído
+func (r *SenhaRepository) LimparFalhas(chaves ...string) error {
+	_, err := r.DB.Exec(`DELETE FROM tentativa_login WHERE chave = ANY($1)`, pq.Array(chaves))
+	return err
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/database"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+// Motivos de encerramento gravados em sessao.motivo_encerramento
+const (
+	MotivoLogout         = "logout"
+	MotivoRevogada       = "revogada"
+	MotivoAdministrador  = "administrador"
+	MotivoInatividade    = "inatividade"
+	MotivoTodasEncerrada = "todas"
+)
+
+// retencaoSessoes é por quanto tempo as sessões encerradas ou abandonadas
+// continuam no banco para consulta
+const retencaoSessoes = 90 * 24 * time.Hour
+
+// ErrSessaoNaoEncontrada indica sessão inexistente, já encerrada ou de outro usuário
+var ErrSessaoNaoEncontrada = errors.New("sessão não encontrada")
+
+// SessaoRepository trata as sessões abertas em cada login
+type SessaoRepository struct {
+	DB *sql.DB
+}
+
+func NewSessaoRepository() *SessaoRepository {
+	return &SessaoRepository{
+		DB: database.GetDB(),
+	}
+}
+
+// Criar registra a sessão de um login e aproveita para remover as antigas do usuário
+func (r *SessaoRepository) Criar(idUsuario int, familia, ip, agenteUsuario string) (int64, error) {
+	if _, err := r.DB.Exec(`
+		DELETE FROM sessao
+		WHERE id_usuario = $1 AND COALESCE(encerrada_em, ultima_atividade) < $2
+	`, idUsuario, time.Now().Add(-retencaoSessoes)); err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err := r.DB.QueryRow(`
+		INSERT INTO sessao (id_usuario, familia, ip, agente_usuario)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''))
+		RETURNING id
+	`, idUsuario, familia, ip, truncar(agenteUsuario, 512)).Scan(&id)
+	return id, err
+}
+
+const selectSessao = `SELECT id, id_usuario, familia, COALESCE(ip, ''), COALESCE(agente_usuario, ''), criada_em, ultima_atividade, encerrada_em IS NOT NULL FROM sessao`
+
+func scanSessao(row interface{ Scan(...interface{}) error }) (*models.Sessao, error) {
+	var s models.Sessao
+	err := row.Scan(&s.ID, &s.IDUsuario, &s.Familia, &s.IP, &s.AgenteUsuario, &s.CriadaEm, &s.UltimaAtividade, &s.Encerrada)
+	if err == sql.ErrNoRows {
+		return nil, ErrSessaoNaoEncontrada
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Buscar retorna a sessão do usuário, encerrada ou não
+func (r *SessaoRepository) Buscar(id int64, idUsuario int) (*models.Sessao, error) {
+	return scanSessao(r.DB.QueryRow(selectSessao+` WHERE id = $1 AND id_usuario = $2`, id, idUsuario))
+}
+
+// BuscarPorFamilia retorna a sessão dona de uma família de refresh tokens
+func (r *SessaoRepository) BuscarPorFamilia(familia string) (*models.Sessao, error) {
+	return scanSessao(r.DB.QueryRow(selectSessao+` WHERE familia = $1`, familia))
+}
+
+// RegistrarAtividade atualiza a última atividade da sessão
+func (r *SessaoRepository) RegistrarAtividade(id int64) error {
+	_, err := r.DB.Exec(`UPDATE sessao SET ultima_atividade = NOW() WHERE id = $1`, id)
+	return err
+}
+
+// ListarAtivas lista as sessões do usuário que ainda podem ser usadas: não
+// encerradas, abertas depois da última invalidação dos tokens, com atividade
+// recente (se inatividade > 0) e com um refresh token válido
+func (r *SessaoRepository) ListarAtivas(idUsuario int, inatividade time.Duration) ([]models.Sessao, error) {
+	rows, err := r.DB.Query(`
+		SELECT s.id, s.id_usuario, s.familia, COALESCE(s.ip, ''), COALESCE(s.agente_usuario, ''), s.criada_em, s.ultima_atividade, FALSE
+		FROM sessao s
+		JOIN usuario u ON u.id = s.id_usuario
+		WHERE s.id_usuario = $1
+			AND s.encerrada_em IS NULL
+			AND s.criada_em >= u.tokens_validos_desde
+			AND ($2 = 0 OR s.ultima_atividade > NOW() - make_interval(secs => $2))
+			AND EXISTS (
+				SELECT 1 FROM refresh_token rt
+				WHERE rt.familia = s.familia AND rt.usado_em IS NULL AND rt.revogado_em IS NULL AND rt.expira_em > NOW()
+			)
+		ORDER BY s.ultima_atividade DESC
+	`, idUsuario, int64(inatividade.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessoes := []models.Sessao{}
+	for rows.Next() {
+		s, err := scanSessao(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessoes = append(sessoes, *s)
+	}
+	return sessoes, rows.Err()
+}
+
+// Encerrar encerra a sessão do usuário e revoga os refresh tokens dela. Os
+// tokens de acesso deixam de valer porque a sessão é conferida a cada requisição.
+func (r *SessaoRepository) Encerrar(id int64, idUsuario int, motivo string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var familia string
+	err = tx.QueryRow(`
+		UPDATE sessao SET encerrada_em = NOW(), motivo_encerramento = $3
+		WHERE id = $1 AND id_usuario = $2 AND encerrada_em IS NULL
+		RETURNING familia
+	`, id, idUsuario, motivo).Scan(&familia)
+	if err == sql.ErrNoRows {
+		return ErrSessaoNaoEncontrada
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE refresh_token SET revogado_em = NOW()
+		WHERE familia = $1 AND revogado_em IS NULL
+	`, familia); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// truncar limita o texto a n caracteres
+func truncar(texto string, n int) string {
+	runas := []rune(texto)
+	if len(runas) <= n {
+		return texto
+	}
+	return string(runas[:n])
+}
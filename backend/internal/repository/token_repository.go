@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/database"
+)
+
+var (
+	// ErrRefreshTokenInvalido indica token inexistente, expirado, revogado ou
+	// emitido antes da última invalidação das sessões do usuário
+	ErrRefreshTokenInvalido = errors.New("refresh token inválido")
+	// ErrRefreshTokenReutilizado indica que um token já trocado foi apresentado
+	// de novo, sinal de que foi copiado; a família inteira é revogada
+	ErrRefreshTokenReutilizado = errors.New("refresh token reutilizado")
+	// ErrUsuarioNaoEncontrado indica que o usuário do token não existe mais
+	ErrUsuarioNaoEncontrado = errors.New("usuário não encontrado")
+)
+
+type TokenRepository struct {
+	DB *sql.DB
+}
+
+func NewTokenRepository() *TokenRepository {
+	return &TokenRepository{
+		DB: database.GetDB(),
+	}
+}
+
+// CriarRefreshToken grava o primeiro token de uma família (um login)
+func (r *TokenRepository) CriarRefreshToken(idUsuario int, familia, hash string, expiraEm time.Time) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO refresh_token (id_usuario, familia, hash_token, expira_em)
+		VALUES ($1, $2, $3, $4)
+	`, idUsuario, familia, hash, expiraEm)
+	return err
+}
+
+// RotacionarRefreshToken troca o token atual pelo novo na mesma família e
+// retorna o usuário dono e a família. O token atual só pode ser usado uma vez.
+func (r *TokenRepository) RotacionarRefreshToken(hashAtual, hashNovo string, expiraEm time.Time) (int, string, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	var idUsuario int
+	var familia string
+	var usado, valido bool
+	err = tx.QueryRow(`
+		SELECT rt.id, rt.id_usuario, rt.familia, rt.usado_em IS NOT NULL,
+			rt.revogado_em IS NULL AND rt.expira_em > NOW() AND rt.criado_em >= u.tokens_validos_desde
+		FROM refresh_token rt
+		JOIN usuario u ON u.id = rt.id_usuario
+		WHERE rt.hash_token = $1
+		FOR UPDATE OF rt
+	`, hashAtual).Scan(&id, &idUsuario, &familia, &usado, &valido)
+	if err == sql.ErrNoRows {
+		return 0, "", ErrRefreshTokenInvalido
+	}
+	if err != nil {
+		return 0, "", err
+	}
+
+	if usado {
+		if _, err := tx.Exec(`
+			UPDATE refresh_token SET revogado_em = NOW()
+			WHERE familia = $1 AND revogado_em IS NULL
+		`, familia); err != nil {
+			return 0, "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, "", err
+		}
+		return 0, "", ErrRefreshTokenReutilizado
+	}
+	if !valido {
+		return 0, "", ErrRefreshTokenInvalido
+	}
+
+	if _, err := tx.Exec(`UPDATE refresh_token SET usado_em = NOW() WHERE id = $1`, id); err != nil {
+		return 0, "", err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO refresh_token (id_usuario, familia, hash_token, expira_em)
+		VALUES ($1, $2, $3, $4)
+	`, idUsuario, familia, hashNovo, expiraEm); err != nil {
+		return 0, "", err
+	}
+
+	return idUsuario, familia, tx.Commit()
+}
+
+// RevogarFamilia revoga a sessão do refresh token, se ele for do usuário
+func (r *TokenRepository) RevogarFamilia(idUsuario int, hash string) error {
+	_, err := r.DB.Exec(`
+		UPDATE refresh_token SET revogado_em = NOW()
+		WHERE familia = (SELECT familia FROM refresh_token WHERE hash_token = $1 AND id_usuario = $2)
+			AND revogado_em IS NULL
+	`, hash, idUsuario)
+	return err
+}
+
+// RevogarAccessToken coloca o JWT na lista de revogados até ele expirar e
+// aproveita para limpar os que já expiraram
+func (r *TokenRepository) RevogarAccessToken(jti string, expiraEm time.Time) error {
+	if _, err := r.DB.Exec(`DELETE FROM token_revogado WHERE expira_em < NOW()`); err != nil {
+		return err
+	}
+	_, err := r.DB.Exec(`
+		INSERT INTO token_revogado (jti, expira_em)
+		VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`, jti, expiraEm)
+	return err
+}
+
+// EncerrarSessoes invalida todos os tokens já emitidos para o usuário
+func (r *TokenRepository) EncerrarSessoes(idUsuario int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE usuario SET tokens_validos_desde = NOW() WHERE id = $1`, idUsuario); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE refresh_token SET revogado_em = NOW()
+		WHERE id_usuario = $1 AND revogado_em IS NULL
+	`, idUsuario); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE sessao SET encerrada_em = NOW(), motivo_encerramento = $2
+		WHERE id_usuario = $1 AND encerrada_em IS NULL
+	`, idUsuario, MotivoTodasEncerrada); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// EstadoToken retorna desde quando os tokens do usuário valem e se o jti foi
+// revogado. Usuário excluído resulta em ErrUsuarioNaoEncontrado.
+func (r *TokenRepository) EstadoToken(idUsuario int, jti string) (time.Time, bool, error) {
+	var validosDesde time.Time
+	var revogado bool
+	err := r.DB.QueryRow(`
+		SELECT u.tokens_validos_desde, EXISTS (SELECT 1 FROM token_revogado WHERE jti = $2)
+		FROM usuario u
+		WHERE u.id = $1
+	`, idUsuario, jti).Scan(&validosDesde, &revogado)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, ErrUsuarioNaoEncontrado
+	}
+	return validosDesde, revogado, err
+}
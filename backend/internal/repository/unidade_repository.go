@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/tassyosilva/consultapix/internal/database"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+// Perfis de acesso às requisições
+const (
+	PerfilAnalista   = "analista"
+	PerfilSupervisor = "supervisor"
+	PerfilAuditor    = "auditor"
+)
+
+// PerfisAcesso lista os perfis na ordem em que são apresentados
+var PerfisAcesso = []string{PerfilAnalista, PerfilSupervisor, PerfilAuditor}
+
+// Tipos de unidade, do nível mais alto ao mais baixo
+const (
+	TipoDepartamento = "departamento"
+	TipoRegional     = "regional"
+	TipoDelegacia    = "delegacia"
+)
+
+// TiposUnidade lista os níveis da hierarquia, do mais alto ao mais baixo
+var TiposUnidade = []string{TipoDepartamento, TipoRegional, TipoDelegacia}
+
+// tipoSuperior indica a que nível cada tipo de unidade se subordina
+var tipoSuperior = map[string]string{
+	TipoDepartamento: "",
+	TipoRegional:     TipoDepartamento,
+	TipoDelegacia:    TipoRegional,
+}
+
+var (
+	// ErrUnidadeNaoEncontrada indica unidade inexistente
+	ErrUnidadeNaoEncontrada = errors.New("unidade não encontrada")
+	// ErrUnidadeEmUso indica unidade com subordinadas, usuários ou requisições
+	ErrUnidadeEmUso = errors.New("a unidade tem unidades subordinadas, usuários ou requisições")
+)
+
+// ErroHierarquiaUnidade indica unidade superior ausente ou de nível errado
+type ErroHierarquiaUnidade struct {
+	Tipo string
+}
+
+func (e *ErroHierarquiaUnidade) Error() string {
+	if tipoSuperior[e.Tipo] == "" {
+		return "um departamento não tem unidade superior"
+	}
+	return fmt.Sprintf("a unidade superior de uma %s deve ser um %s", e.Tipo, tipoSuperior[e.Tipo])
+}
+
+// TipoUnidadeValido indica se o tipo é departamento, regional ou delegacia
+func TipoUnidadeValido(tipo string) bool {
+	_, ok := tipoSuperior[tipo]
+	return ok
+}
+
+// PerfilAcessoValido indica se o perfil é analista, supervisor ou auditor
+func PerfilAcessoValido(perfil string) bool {
+	for _, p := range PerfisAcesso {
+		if p == perfil {
+			return true
+		}
+	}
+	return false
+}
+
+// subarvoreUnidade devolve a subconsulta com a unidade informada e todas as
+// subordinadas a ela, em qualquer nível
+func subarvoreUnidade(idUnidade string) string {
+	return `WITH RECURSIVE subarvore AS (
+			SELECT id FROM unidade WHERE id = ` + idUnidade + `
+			UNION ALL
+			SELECT u.id FROM unidade u JOIN subarvore s ON u.id_superior = s.id
+		) SELECT id FROM subarvore`
+}
+
+// UnidadeRepository trata a hierarquia de unidades e a atribuição dos usuários
+type UnidadeRepository struct {
+	DB *sql.DB
+}
+
+func NewUnidadeRepository() *UnidadeRepository {
+	return &UnidadeRepository{
+		DB: database.GetDB(),
+	}
+}
+
+const selectUnidade = `
+	SELECT u.id, u.nome, u.tipo, u.id_superior, u.criada_em,
+		(SELECT COUNT(*) FROM usuario us WHERE us.id_unidade = u.id)
+	FROM unidade u`
+
+func scanUnidade(row interface{ Scan(...interface{}) error }) (*models.Unidade, error) {
+	var u models.Unidade
+	var superior sql.NullInt64
+	err := row.Scan(&u.ID, &u.Nome, &u.Tipo, &superior, &u.CriadaEm, &u.Usuarios)
+	if err == sql.ErrNoRows {
+		return nil, ErrUnidadeNaoEncontrada
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.IDSuperior = intOuNulo(superior)
+	return &u, nil
+}
+
+// Listar retorna todas as unidades, do nível mais alto ao mais baixo
+func (r *UnidadeRepository) Listar() ([]models.Unidade, error) {
+	rows, err := r.DB.Query(selectUnidade + `
+		ORDER BY array_position(ARRAY['departamento', 'regional', 'delegacia']::VARCHAR[], u.tipo), u.nome
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	unidades := make([]models.Unidade, 0)
+	for rows.Next() {
+		u, err := scanUnidade(rows)
+		if err != nil {
+			return nil, err
+		}
+		unidades = append(unidades, *u)
+	}
+	return unidades, rows.Err()
+}
+
+// Buscar retorna uma unidade
+func (r *UnidadeRepository) Buscar(id int) (*models.Unidade, error) {
+	return scanUnidade(r.DB.QueryRow(selectUnidade+` WHERE u.id = $1`, id))
+}
+
+// Criar grava uma unidade, conferindo o nível da unidade superior
+func (r *UnidadeRepository) Criar(u *models.Unidade) error {
+	if err := r.conferirSuperior(u.Tipo, u.IDSuperior); err != nil {
+		return err
+	}
+	return r.DB.QueryRow(`
+		INSERT INTO unidade (nome, tipo, id_superior)
+		VALUES ($1, $2, $3)
+		RETURNING id, criada_em
+	`, u.Nome, u.Tipo, u.IDSuperior).Scan(&u.ID, &u.CriadaEm)
+}
+
+// Atualizar renomeia a unidade ou a move para outra superior do mesmo nível.
+// O tipo não muda, o que mantém a hierarquia sem ciclos.
+func (r *UnidadeRepository) Atualizar(u *models.Unidade) error {
+	atual, err := r.Buscar(u.ID)
+	if err != nil {
+		return err
+	}
+	u.Tipo = atual.Tipo
+	if err := r.conferirSuperior(u.Tipo, u.IDSuperior); err != nil {
+		return err
+	}
+	_, err = r.DB.Exec(`UPDATE unidade SET nome = $2, id_superior = $3 WHERE id = $1`, u.ID, u.Nome, u.IDSuperior)
+	return err
+}
+
+// conferirSuperior exige que a unidade superior exista e seja do nível
+// imediatamente acima do tipo
+func (r *UnidadeRepository) conferirSuperior(tipo string, idSuperior *int) error {
+	esperado := tipoSuperior[tipo]
+	if esperado == "" {
+		if idSuperior != nil {
+			return &ErroHierarquiaUnidade{Tipo: tipo}
+		}
+		return nil
+	}
+	if idSuperior == nil {
+		return &ErroHierarquiaUnidade{Tipo: tipo}
+	}
+
+	var tipoAtual string
+	err := r.DB.QueryRow(`SELECT tipo FROM unidade WHERE id = $1`, *idSuperior).Scan(&tipoAtual)
+	if err == sql.ErrNoRows || (err == nil && tipoAtual != esperado) {
+		return &ErroHierarquiaUnidade{Tipo: tipo}
+	}
+	return err
+}
+
+// Remover exclui uma unidade sem subordinadas, usuários nem requisições
+func (r *UnidadeRepository) Remover(id int) error {
+	var emUso bool
+	err := r.DB.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM unidade WHERE id_superior = $1)
+			OR EXISTS (SELECT 1 FROM usuario WHERE id_unidade = $1)
+			OR EXISTS (SELECT 1 FROM requisicao_pix WHERE id_unidade = $1)
+			OR EXISTS (SELECT 1 FROM requisicao_relacionamento_ccs WHERE id_unidade = $1)
+	`, id).Scan(&emUso)
+	if err != nil {
+		return err
+	}
+	if emUso {
+		return ErrUnidadeEmUso
+	}
+
+	result, err := r.DB.Exec(`DELETE FROM unidade WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUnidadeNaoEncontrada
+	}
+	return nil
+}
+
+// AtribuirUsuario define a unidade (zero para nenhuma) e o perfil de acesso
+// do usuário. As requisições feitas por ele antes de ter unidade passam à
+// nova unidade, para que o supervisor também as veja.
+func (r *UnidadeRepository) AtribuirUsuario(idUsuario, idUnidade int, perfil string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var unidade sql.NullInt64
+	if idUnidade > 0 {
+		unidade = sql.NullInt64{Int64: int64(idUnidade), Valid: true}
+	}
+
+	var cpf string
+	err = tx.QueryRow(`
+		UPDATE usuario SET id_unidade = $2, perfil = $3
+		WHERE id = $1
+		RETURNING cpf
+	`, idUsuario, unidade, perfil).Scan(&cpf)
+	if err == sql.ErrNoRows {
+		return ErrUsuarioNaoEncontrado
+	}
+	if err != nil {
+		return err
+	}
+
+	if unidade.Valid {
+		for _, tabela := range []string{"requisicao_pix", "requisicao_relacionamento_ccs"} {
+			if _, err := tx.Exec(`UPDATE `+tabela+` SET id_unidade = $2 WHERE cpf_responsavel = $1 AND id_unidade IS NULL`, cpf, unidade); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
@@ -19,11 +19,13 @@ func NewUserRepository() *UserRepository {
 	}
 }
 
-func (r *UserRepository) FindByEmail(email string) (*models.Usuario, error) {
+// selectUsuario lista as colunas lidas por scanUsuario
+const selectUsuario = `SELECT id, nome, cpf, email, password, COALESCE(lotacao, ''), COALESCE(matricula, ''), admin, totp_ativo, origem, situacao, senha_alterada_em, troca_senha_obrigatoria, perfil, COALESCE(id_unidade, 0) FROM usuario`
+
+func scanUsuario(row *sql.Row) (*models.Usuario, error) {
 	var user models.Usuario
-	query := `SELECT id, nome, cpf, email, password, lotacao, matricula, admin FROM usuario WHERE email = $1`
-	err := r.DB.QueryRow(query, email).Scan(
-		&user.ID, &user.Nome, &user.CPF, &user.Email, &user.Password, &user.Lotacao, &user.Matricula, &user.Admin,
+	err := row.Scan(
+		&user.ID, &user.Nome, &user.CPF, &user.Email, &user.Password, &user.Lotacao, &user.Matricula, &user.Admin, &user.DoisFatoresAtivo, &user.Origem, &user.Situacao, &user.SenhaAlteradaEm, &user.TrocaSenhaObrigatoria, &user.Perfil, &user.IDUnidade,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -34,6 +36,77 @@ func (r *UserRepository) FindByEmail(email string) (*models.Usuario, error) {
 	return &user, nil
 }
 
+func (r *UserRepository) FindByEmail(email string) (*models.Usuario, error) {
+	return scanUsuario(r.DB.QueryRow(selectUsuario+` WHERE email = $1`, email))
+}
+
+// FindByCPF busca o usuário pelo CPF
+func (r *UserRepository) FindByCPF(cpf string) (*models.Usuario, error) {
+	return scanUsuario(r.DB.QueryRow(selectUsuario+` WHERE cpf = $1`, cpf))
+}
+
+// FindByID busca o usuário pelo ID
+func (r *UserRepository) FindByID(id int) (*models.Usuario, error) {
+	return scanUsuario(r.DB.QueryRow(selectUsuario+` WHERE id = $1`, id))
+}
+
+// ErrContaExistente indica que o CPF da identidade externa já pertence a
+// outra conta. O vínculo não é automático: qualquer identidade do provedor
+// com o mesmo CPF assumiria a conta, inclusive a de um administrador local.
+var ErrContaExistente = errors.New("já existe uma conta com este CPF; procure um administrador")
+
+// ProvisionarExterno cria ou atualiza, no login, o usuário de um provedor
+// externo, localizado pelo identificador no provedor. Se outra conta já usa o
+// CPF, o login é recusado com ErrContaExistente. Com gerenciarAdmin, o perfil
+// de administrador vem do provedor; a conta criada só é administradora se o
+// mapeamento de grupos conceder.
+func (r *UserRepository) ProvisionarExterno(user *models.Usuario, idExterno string, gerenciarAdmin bool) (*models.Usuario, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	var propria bool
+	err = tx.QueryRow(`
+		SELECT id, (origem = $1 AND id_externo = $2) IS TRUE FROM usuario
+		WHERE (origem = $1 AND id_externo = $2) OR regexp_replace(cpf, '\D', '', 'g') = $3
+		ORDER BY (origem = $1 AND id_externo = $2) IS TRUE DESC
+		LIMIT 1
+		FOR UPDATE
+	`, user.Origem, idExterno, user.CPF).Scan(&id, &propria)
+	switch {
+	case err == sql.ErrNoRows:
+		err = tx.QueryRow(`
+			INSERT INTO usuario (nome, cpf, email, password, lotacao, matricula, admin, origem, id_externo)
+			VALUES ($1, $2, $3, '', NULLIF($4, ''), NULLIF($5, ''), $6, $7, $8)
+			RETURNING id
+		`, user.Nome, user.CPF, user.Email, user.Lotacao, user.Matricula, gerenciarAdmin && user.Admin, user.Origem, idExterno).Scan(&id)
+	case err == nil && !propria:
+		return nil, ErrContaExistente
+	case err == nil:
+		// Um autocadastro pendente é ativado, pois o provedor confirma a
+		// identidade. A conta desativada continua desativada: só um
+		// administrador a reativa.
+		_, err = tx.Exec(`
+			UPDATE usuario
+			SET nome = $2, cpf = CASE WHEN regexp_replace(cpf, '\D', '', 'g') = $3 THEN cpf ELSE $3 END, email = $4, lotacao = NULLIF($5, ''), matricula = NULLIF($6, ''),
+				admin = CASE WHEN $7 THEN $8 ELSE admin END,
+				situacao = CASE WHEN situacao = 'inativo' THEN situacao ELSE 'ativo' END
+			WHERE id = $1
+		`, id, user.Nome, user.CPF, user.Email, user.Lotacao, user.Matricula, gerenciarAdmin, user.Admin)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.FindByID(id)
+}
+
 func (r *UserRepository) Create(user *models.Usuario) (int, error) {
 	// Verificar se já existe usuário com o email, cpf ou matrícula
 	var count int
@@ -53,16 +126,22 @@ func (r *UserRepository) Create(user *models.Usuario) (int, error) {
 	}
 	user.Password = string(hashedPassword)
 
+	// Sem situação informada o usuário já nasce ativo
+	situacao := user.Situacao
+	if situacao == "" {
+		situacao = SituacaoAtivo
+	}
+
 	// Inserir usuário
 	insertQuery := `
-		INSERT INTO usuario (nome, cpf, email, password, lotacao, matricula, admin)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO usuario (nome, cpf, email, password, lotacao, matricula, admin, situacao)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id
 	`
 	var id int
 	err = r.DB.QueryRow(
 		insertQuery, 
-		user.Nome, user.CPF, user.Email, user.Password, user.Lotacao, user.Matricula, user.Admin,
+		user.Nome, user.CPF, user.Email, user.Password, user.Lotacao, user.Matricula, user.Admin, situacao,
 	).Scan(&id)
 	if err != nil {
 		return 0, err
@@ -104,14 +183,8 @@ func (r *UserRepository) Update(user *models.Usuario) error {
 	return err
 }
 
-func (r *UserRepository) Delete(id int) error {
-	query := `DELETE FROM usuario WHERE id = $1`
-	_, err := r.DB.Exec(query, id)
-	return err
-}
-
 func (r *UserRepository) GetAll() ([]models.Usuario, error) {
-	query := `SELECT id, nome, cpf, email, '', lotacao, matricula, admin FROM usuario ORDER BY nome ASC`
+	query := `SELECT id, nome, cpf, email, '', COALESCE(lotacao, ''), COALESCE(matricula, ''), admin, totp_ativo, origem, situacao, perfil, COALESCE(id_unidade, 0) FROM usuario ORDER BY nome ASC`
 	rows, err := r.DB.Query(query)
 	if err != nil {
 		return nil, err
@@ -122,7 +195,7 @@ func (r *UserRepository) GetAll() ([]models.Usuario, error) {
 	for rows.Next() {
 		var user models.Usuario
 		err := rows.Scan(
-			&user.ID, &user.Nome, &user.CPF, &user.Email, &user.Password, &user.Lotacao, &user.Matricula, &user.Admin,
+			&user.ID, &user.Nome, &user.CPF, &user.Email, &user.Password, &user.Lotacao, &user.Matricula, &user.Admin, &user.DoisFatoresAtivo, &user.Origem, &user.Situacao, &user.Perfil, &user.IDUnidade,
 		)
 		if err != nil {
 			return nil, err
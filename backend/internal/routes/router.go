@@ -1,49 +1,202 @@
 package routes
 
 import (
-	_"net/http"
+	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/handlers/admin/auditoria"
+	"github.com/tassyosilva/consultapix/internal/handlers/admin/cadastros"
+	"github.com/tassyosilva/consultapix/internal/handlers/admin/cotas"
+	"github.com/tassyosilva/consultapix/internal/handlers/admin/credenciaisbacen"
+	"github.com/tassyosilva/consultapix/internal/handlers/admin/desativacoes"
+	admindoisfatores "github.com/tassyosilva/consultapix/internal/handlers/admin/doisfatores"
+	adminsessoes "github.com/tassyosilva/consultapix/internal/handlers/admin/sessoes"
+	"github.com/tassyosilva/consultapix/internal/handlers/admin/unidades"
 	"github.com/tassyosilva/consultapix/internal/handlers/bacen/ccs/detalhamento"
+	"github.com/tassyosilva/consultapix/internal/handlers/bacen/ccs/historicoccs"
 	"github.com/tassyosilva/consultapix/internal/handlers/bacen/ccs/relacionamento"
 	"github.com/tassyosilva/consultapix/internal/handlers/bacen/ccs/requisicoesccs"
 	"github.com/tassyosilva/consultapix/internal/handlers/bacen/pix/chave"
 	"github.com/tassyosilva/consultapix/internal/handlers/bacen/pix/cpfcnpj"
+	"github.com/tassyosilva/consultapix/internal/handlers/bacen/pix/historicopix"
 	"github.com/tassyosilva/consultapix/internal/handlers/bacen/pix/requisicoespix"
+	"github.com/tassyosilva/consultapix/internal/handlers/busca"
+	"github.com/tassyosilva/consultapix/internal/handlers/conta"
+	"github.com/tassyosilva/consultapix/internal/handlers/doisfatores"
+	"github.com/tassyosilva/consultapix/internal/handlers/eventos"
+	"github.com/tassyosilva/consultapix/internal/handlers/cota"
+	"github.com/tassyosilva/consultapix/internal/handlers/monitoramento"
+	"github.com/tassyosilva/consultapix/internal/handlers/notificacao"
+	"github.com/tassyosilva/consultapix/internal/handlers/pessoa"
 	"github.com/tassyosilva/consultapix/internal/handlers/user"
 	"github.com/tassyosilva/consultapix/internal/handlers/utils/processafilaccs"
 	"github.com/tassyosilva/consultapix/internal/handlers/utils/recebebdvccs"
 	"github.com/tassyosilva/consultapix/internal/middleware"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/bacen"
 )
 
 func SetupRoutes(router *mux.Router, cfg *config.Config) {
 	// Middleware de autenticação
 	authMiddleware := middleware.NewAuthMiddleware(cfg)
 
+	// Cotas de consultas ao BACEN por usuário e lotação
+	cotaMiddleware := middleware.NewCotaMiddleware(cfg)
+
+	// Reaproveitamento de consultas PIX recentes do mesmo caso
+	reaproveitamento := middleware.NewReaproveitamentoMiddleware(cfg)
+
 	// Rotas públicas
-	router.HandleFunc("/api/user/login", user.NewLoginHandler(cfg).Handle).Methods("POST")
-	router.HandleFunc("/api/user/register", user.NewRegisterHandler().Handle).Methods("POST")
+	loginHandler := user.NewLoginHandler(cfg)
+	router.HandleFunc("/api/user/login", loginHandler.Handle).Methods("POST")
+	router.HandleFunc("/api/user/login/2fa", loginHandler.HandleDoisFatores).Methods("POST")
+	registerHandler := user.NewRegisterHandler(cfg)
+	router.HandleFunc("/api/user/register", registerHandler.Handle).Methods("POST")
+	router.HandleFunc("/api/user/register/verificar", registerHandler.HandleVerificar).Methods("GET")
+	router.HandleFunc("/api/user/refresh", user.NewRefreshHandler(cfg).Handle).Methods("POST")
+
+	// Política de senhas e redefinição pelo link enviado por e-mail
+	senhaHandler := user.NewSenhaHandler(cfg)
+	router.HandleFunc("/api/user/senha/politica", senhaHandler.HandlePolitica).Methods("GET")
+	router.HandleFunc("/api/user/senha/esqueci", senhaHandler.HandleEsqueci).Methods("POST")
+	router.HandleFunc("/api/user/senha/redefinir", senhaHandler.HandleRedefinir).Methods("POST")
+
+	// Pedido de reativação da conta desativada, analisado por um administrador
+	router.HandleFunc("/api/user/reativacao", user.NewReativacaoHandler(cfg).Handle).Methods("POST")
+
+	// Login único por OpenID Connect
+	oidcHandler := user.NewOIDCHandler(cfg)
+	router.HandleFunc("/api/user/provedores", oidcHandler.HandleProvedores).Methods("GET")
+	router.HandleFunc("/api/user/oidc/login", oidcHandler.HandleLogin).Methods("GET")
+	router.HandleFunc("/api/user/oidc/callback", oidcHandler.HandleCallback).Methods("GET")
 
 	// Rotas protegidas por autenticação
 	protectedRouter := router.PathPrefix("/api").Subrouter()
 	protectedRouter.Use(authMiddleware.Authenticate)
+	// O auditor só lê: todas as rotas que alteram dados passam pelo perfil
+	protectedRouter.Use(middleware.ExigirPerfilNaEscrita)
 
 	// Rotas de usuário
+	protectedRouter.HandleFunc("/user/sessao", user.NewSessaoHandler().Handle).Methods("GET")
 	protectedRouter.HandleFunc("/user/list", user.NewListHandler().Handle).Methods("GET")
-	protectedRouter.HandleFunc("/user/edit", user.NewEditHandler().Handle).Methods("POST")
-	protectedRouter.HandleFunc("/user/delete", user.NewDeleteHandler().Handle).Methods("POST")
+	// A edição altera dados de qualquer usuário, inclusive o perfil de administrador
+	protectedRouter.Handle("/user/edit", middleware.SomenteAdmin(http.HandlerFunc(user.NewEditHandler(cfg).Handle))).Methods("POST")
+	protectedRouter.HandleFunc("/user/senha", senhaHandler.Handle).Methods("POST")
+	protectedRouter.HandleFunc("/user/delete", user.NewDeleteHandler(cfg).Handle).Methods("POST")
+	logoutHandler := user.NewLogoutHandler(cfg)
+	protectedRouter.HandleFunc("/user/logout", logoutHandler.Handle).Methods("POST")
+	protectedRouter.HandleFunc("/user/logout-all", logoutHandler.HandleTodas).Methods("POST")
+
+	// Sessões abertas do usuário, por dispositivo
+	sessoesHandler := user.NewSessoesHandler(cfg)
+	protectedRouter.HandleFunc("/user/sessoes", sessoesHandler.Handle).Methods("GET")
+	protectedRouter.HandleFunc("/user/sessoes/{id:[0-9]+}", sessoesHandler.HandleRevogar).Methods("DELETE")
+
+	// Autenticação em dois fatores (TOTP)
+	doisFatoresHandler := doisfatores.NewHandler(cfg)
+	protectedRouter.HandleFunc("/user/2fa", doisFatoresHandler.Handle).Methods("GET")
+	protectedRouter.HandleFunc("/user/2fa/cadastro", doisFatoresHandler.HandleCadastro).Methods("POST")
+	protectedRouter.HandleFunc("/user/2fa/ativar", doisFatoresHandler.HandleAtivar).Methods("POST")
+	protectedRouter.HandleFunc("/user/2fa/desativar", doisFatoresHandler.HandleDesativar).Methods("POST")
+	protectedRouter.HandleFunc("/user/2fa/codigos-recuperacao", doisFatoresHandler.HandleCodigosRecuperacao).Methods("POST")
 
 	// Rotas PIX
-	protectedRouter.HandleFunc("/bacen/pix/chave", chave.NewHandler(cfg).Handle).Methods("GET")
-	protectedRouter.HandleFunc("/bacen/pix/cpfCnpj", cpfcnpj.NewHandler(cfg).Handle).Methods("GET")
+	protectedRouter.HandleFunc("/bacen/pix/chave", middleware.ExigirConsulta(reaproveitamento.Reaproveitar(bacen.TipoBuscaChave, "chave",
+		cotaMiddleware.Limitar(repository.OperacaoPixChave, chave.NewHandler(cfg).Handle)))).Methods("GET")
+	protectedRouter.HandleFunc("/bacen/pix/cpfCnpj", middleware.ExigirConsulta(reaproveitamento.Reaproveitar(bacen.TipoBuscaCPFCNPJ, "cpfCnpj",
+		cotaMiddleware.Limitar(repository.OperacaoPixCPFCNPJ, cpfcnpj.NewHandler(cfg).Handle)))).Methods("GET")
 	protectedRouter.HandleFunc("/bacen/pix/requisicoespix", requisicoespix.NewHandler().Handle).Methods("GET")
+
+	historicoPix := historicopix.NewHandler()
+	protectedRouter.HandleFunc("/bacen/pix/historico", historicoPix.Handle).Methods("GET")
+	protectedRouter.HandleFunc("/bacen/pix/historico/{id:[0-9]+}", historicoPix.HandleDetalhe).Methods("GET")
+	protectedRouter.HandleFunc("/bacen/pix/historico/{id:[0-9]+}/comparacao", historicoPix.HandleComparacao).Methods("GET")
 	
 	// Rotas CCS
-	protectedRouter.HandleFunc("/bacen/ccs/relacionamento", relacionamento.NewHandler(cfg).Handle).Methods("GET")
-	protectedRouter.HandleFunc("/bacen/ccs/detalhamento", detalhamento.NewHandler(cfg).Handle).Methods("GET")
+	protectedRouter.HandleFunc("/bacen/ccs/relacionamento", middleware.ExigirConsulta(cotaMiddleware.Limitar(repository.OperacaoCCSRelacionamento, relacionamento.NewHandler(cfg).Handle))).Methods("GET")
+	protectedRouter.HandleFunc("/bacen/ccs/detalhamento", middleware.ExigirConsulta(cotaMiddleware.Limitar(repository.OperacaoCCSDetalhamento, detalhamento.NewHandler(cfg).Handle))).Methods("GET")
 	protectedRouter.HandleFunc("/bacen/ccs/requisicoesccs", requisicoesccs.NewHandler(cfg).Handle).Methods("GET")
+
+	historicoCCS := historicoccs.NewHandler()
+	protectedRouter.HandleFunc("/bacen/ccs/historico", historicoCCS.Handle).Methods("GET")
+	protectedRouter.HandleFunc("/bacen/ccs/historico/{id:[0-9]+}", historicoCCS.HandleDetalhe).Methods("GET")
+	protectedRouter.HandleFunc("/bacen/ccs/historico/{id:[0-9]+}/comparacao", historicoCCS.HandleComparacao).Methods("GET")
 	
+	// Busca textual sobre os dados armazenados
+	protectedRouter.HandleFunc("/busca", busca.NewHandler().Handle).Methods("GET")
+
+	// Perfil consolidado de pessoas (CPF/CNPJ)
+	protectedRouter.HandleFunc("/pessoas/{documento}", pessoa.NewHandler().Handle).Methods("GET")
+
+	// Contas consolidadas: chaves e pessoas que apontam para cada conta
+	contaHandler := conta.NewHandler()
+	protectedRouter.HandleFunc("/contas", contaHandler.Handle).Methods("GET")
+	protectedRouter.HandleFunc("/contas/{id:[0-9]+}", contaHandler.HandleDetalhe).Methods("GET")
+
+	// Consumo de cotas do usuário e da lotação
+	protectedRouter.HandleFunc("/cotas/uso", cota.NewHandler().Handle).Methods("GET")
+
+	// Monitoramento periódico de chaves PIX e CPFs/CNPJs
+	monitoramentoHandler := monitoramento.NewHandler()
+	protectedRouter.HandleFunc("/monitoramentos", monitoramentoHandler.Handle).Methods("GET")
+	protectedRouter.HandleFunc("/monitoramentos", monitoramentoHandler.HandleCriar).Methods("POST")
+	protectedRouter.HandleFunc("/monitoramentos/{id:[0-9]+}/encerrar", monitoramentoHandler.HandleEncerrar).Methods("POST")
+
+	// Caixa de entrada de notificações
+	notificacaoHandler := notificacao.NewHandler()
+	protectedRouter.HandleFunc("/notificacoes", notificacaoHandler.Handle).Methods("GET")
+	protectedRouter.HandleFunc("/notificacoes/{id:[0-9]+}/lida", notificacaoHandler.HandleMarcarLida).Methods("POST")
+	protectedRouter.HandleFunc("/notificacoes/preferencias", notificacaoHandler.HandlePreferencias).Methods("GET")
+	protectedRouter.HandleFunc("/notificacoes/preferencias", notificacaoHandler.HandleSalvarPreferencias).Methods("POST")
+
+	// Stream de eventos (Server-Sent Events)
+	protectedRouter.HandleFunc("/eventos", eventos.NewHandler().Handle).Methods("GET")
+
+	// Rotas administrativas
+	adminRouter := protectedRouter.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(middleware.SomenteAdmin)
+
+	credenciaisBacen := credenciaisbacen.NewHandler(cfg)
+	adminRouter.HandleFunc("/credenciais-bacen", credenciaisBacen.HandleListar).Methods("GET")
+	adminRouter.HandleFunc("/credenciais-bacen", credenciaisBacen.HandleSalvar).Methods("POST")
+	adminRouter.HandleFunc("/credenciais-bacen/{id:[0-9]+}", credenciaisBacen.HandleRemover).Methods("DELETE")
+
+	limitesCota := cotas.NewHandler()
+	adminRouter.HandleFunc("/cotas", limitesCota.HandleListar).Methods("GET")
+	adminRouter.HandleFunc("/cotas", limitesCota.HandleSalvar).Methods("POST")
+	adminRouter.HandleFunc("/cotas/{id:[0-9]+}", limitesCota.HandleRemover).Methods("DELETE")
+
+	adminRouter.HandleFunc("/auditoria", auditoria.NewHandler().Handle).Methods("GET")
+
+	politicaDoisFatores := admindoisfatores.NewHandler(cfg)
+	adminRouter.HandleFunc("/dois-fatores/politica", politicaDoisFatores.HandlePolitica).Methods("GET")
+	adminRouter.HandleFunc("/dois-fatores/politica", politicaDoisFatores.HandleSalvarPolitica).Methods("POST")
+	adminRouter.HandleFunc("/usuarios/{id:[0-9]+}/dois-fatores/reset", politicaDoisFatores.HandleReset).Methods("POST")
+
+	hierarquia := unidades.NewHandler()
+	adminRouter.HandleFunc("/unidades", hierarquia.HandleListar).Methods("GET")
+	adminRouter.HandleFunc("/unidades", hierarquia.HandleCriar).Methods("POST")
+	adminRouter.HandleFunc("/unidades/{id:[0-9]+}", hierarquia.HandleAtualizar).Methods("PUT")
+	adminRouter.HandleFunc("/unidades/{id:[0-9]+}", hierarquia.HandleRemover).Methods("DELETE")
+	adminRouter.HandleFunc("/usuarios/{id:[0-9]+}/acesso", hierarquia.HandleAtribuir).Methods("POST")
+
+	sessoesUsuario := adminsessoes.NewHandler(cfg)
+	adminRouter.HandleFunc("/usuarios/{id:[0-9]+}/sessoes", sessoesUsuario.HandleListar).Methods("GET")
+	adminRouter.HandleFunc("/usuarios/{id:[0-9]+}/sessoes/encerrar", sessoesUsuario.HandleEncerrarTodas).Methods("POST")
+	adminRouter.HandleFunc("/usuarios/{id:[0-9]+}/sessoes/{sid:[0-9]+}", sessoesUsuario.HandleEncerrar).Methods("DELETE")
+
+	contasDesativadas := desativacoes.NewHandler(cfg)
+	adminRouter.HandleFunc("/usuarios/inativos", contasDesativadas.HandleListar).Methods("GET")
+	adminRouter.HandleFunc("/usuarios/{id:[0-9]+}/desativar", contasDesativadas.HandleDesativar).Methods("POST")
+	adminRouter.HandleFunc("/usuarios/{id:[0-9]+}/reativar", contasDesativadas.HandleReativar).Methods("POST")
+	adminRouter.HandleFunc("/usuarios/{id:[0-9]+}/reativacao/rejeitar", contasDesativadas.HandleRejeitar).Methods("POST")
+
+	cadastrosPendentes := cadastros.NewHandler(cfg)
+	adminRouter.HandleFunc("/cadastros", cadastrosPendentes.HandleListar).Methods("GET")
+	adminRouter.HandleFunc("/cadastros/{id:[0-9]+}/aprovar", cadastrosPendentes.HandleAprovar).Methods("POST")
+	adminRouter.HandleFunc("/cadastros/{id:[0-9]+}/rejeitar", cadastrosPendentes.HandleRejeitar).Methods("POST")
+
 	// Rotas para processamento em segundo plano
 	router.HandleFunc("/api/utils/processaFilaCCS", processafilaccs.NewHandler(cfg).Handle).Methods("GET")
 	router.HandleFunc("/api/utils/recebeBDVCCS", recebebdvccs.NewHandler(cfg).Handle).Methods("GET")
@@ -1,19 +1,35 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"log"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/tassyosilva/consultapix/internal/config"
 	"github.com/tassyosilva/consultapix/internal/database/models"
 	"github.com/tassyosilva/consultapix/internal/repository"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrSessaoInvalida indica token revogado, emitido antes da última
+// invalidação das sessões do usuário ou de um usuário excluído
+var ErrSessaoInvalida = errors.New("sessão encerrada")
+
+// duracaoDesafio é o prazo para informar o código depois da senha
+const duracaoDesafio = 5 * time.Minute
+
 type AuthService struct {
-	userRepo *repository.UserRepository
-	config   *config.Config
+	provedores  []ProvedorIdentidade
+	userRepo    *repository.UserRepository
+	tokenRepo   *repository.TokenRepository
+	sessaoRepo  *repository.SessaoRepository
+	doisFatores *DoisFatoresService
+	bloqueio    *controleBloqueio
+	config      *config.Config
 }
 
 type JWTClaims struct {
@@ -25,32 +41,270 @@ type JWTClaims struct {
 	Lotacao   string `json:"lotacao"`
 	Matricula string `json:"matricula"`
 	Admin     bool   `json:"admin"`
+	// Perfil e Unidade definem o escopo das requisições visíveis
+	Perfil  string `json:"perfil,omitempty"`
+	Unidade int    `json:"unidade,omitempty"`
+	// Sessao é a sessão aberta no login, conferida a cada requisição
+	Sessao int64 `json:"sid"`
+	// CadastroDoisFatores restringe o token ao cadastro do segundo fator,
+	// exigido pela política e ainda não feito
+	CadastroDoisFatores bool `json:"cadastro2fa,omitempty"`
+	// TrocaSenha restringe o token à troca da senha local expirada
+	TrocaSenha bool `json:"trocaSenha,omitempty"`
+}
+
+// Tokens é o par emitido no login e em cada renovação
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	// Desafio é preenchido, no lugar dos tokens, quando o login ainda
+	// depende do código do segundo fator
+	Desafio string
+	// CadastroDoisFatores indica que o token de acesso só serve para
+	// cadastrar o segundo fator exigido pela política
+	CadastroDoisFatores bool
+	// TrocaSenha indica que o token de acesso só serve para trocar a senha expirada
+	TrocaSenha bool
 }
 
 func NewAuthService(cfg *config.Config) *AuthService {
+	userRepo := repository.NewUserRepository()
 	return &AuthService{
-		userRepo: repository.NewUserRepository(),
-		config:   cfg,
+		provedores:  novosProvedores(cfg, userRepo),
+		userRepo:    userRepo,
+		tokenRepo:   repository.NewTokenRepository(),
+		sessaoRepo:  repository.NewSessaoRepository(),
+		doisFatores: NewDoisFatoresService(cfg),
+		bloqueio:    novoControleBloqueio(cfg),
+		config:      cfg,
+	}
+}
+
+// Login tenta os provedores de identidade em ordem até um aceitar as
+// credenciais. Uma falha de comunicação com um provedor é registrada e não
+// impede os seguintes, para que o login local continue disponível. Falhas
+// seguidas no mesmo login ou no mesmo IP bloqueiam novas tentativas.
+func (s *AuthService) Login(email, password string, cliente ClienteSessao) (*Tokens, *models.Usuario, error) {
+	user, err := s.autenticar(email, password, cliente)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens, user, err := s.concluirLogin(user, cliente)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Com o segundo fator pendente, a contagem só é zerada depois do código
+	if tokens.Desafio == "" {
+		s.bloqueio.limpar(email, user.Email)
+	}
+	return tokens, user, nil
+}
+
+// autenticar confere as credenciais nos provedores de identidade, contando as
+// falhas para o bloqueio da conta e do IP
+func (s *AuthService) autenticar(email, password string, cliente ClienteSessao) (*models.Usuario, error) {
+	if err := s.bloqueio.verificar(chaveConta(email), chaveIP(cliente.IP)); err != nil {
+		return nil, err
+	}
+
+	var user *models.Usuario
+	for _, provedor := range s.provedores {
+		var err error
+		user, err = provedor.Autenticar(email, password)
+		if err == nil {
+			break
+		}
+		// O provedor confirmou a identidade, mas o CPF já é de outra conta
+		if err == repository.ErrContaExistente {
+			return nil, err
+		}
+		if err != ErrCredenciaisInvalidas {
+			log.Printf("Erro no provedor de identidade %s: %v", provedor.Nome(), err)
+		}
+	}
+	if user == nil {
+		if err := s.bloqueio.falhar(email, cliente.IP); err != nil {
+			return nil, err
+		}
+		return nil, ErrCredenciaisInvalidas
+	}
+
+	// Códigos errados do segundo fator bloqueiam a conta pelo e-mail, mesmo
+	// com a senha certa
+	if err := s.bloqueio.verificar(chaveConta(user.Email)); err != nil {
+		return nil, err
 	}
+	return user, nil
 }
 
-func (s *AuthService) Login(email, password string) (string, *models.Usuario, error) {
-	user, err := s.userRepo.FindByEmail(email)
+// concluirLogin inicia a sessão de um usuário autenticado pelo provedor de
+// identidade ou, com o segundo fator ativo, devolve o desafio para o código
+func (s *AuthService) concluirLogin(user *models.Usuario, cliente ClienteSessao) (*Tokens, *models.Usuario, error) {
+	if err := situacaoLogin(user); err != nil {
+		return nil, nil, err
+	}
+
+	if user.DoisFatoresAtivo {
+		desafio := tokenAleatorio(32)
+		if err := s.doisFatores.repo.CriarDesafio(hashToken(desafio), user.ID, time.Now().Add(duracaoDesafio)); err != nil {
+			return nil, nil, err
+		}
+		return &Tokens{Desafio: desafio}, user, nil
+	}
+
+	return s.iniciarSessao(user, cliente)
+}
+
+// situacaoLogin recusa a conta desativada e o autocadastro ainda sem
+// confirmação do e-mail ou sem aprovação
+func situacaoLogin(user *models.Usuario) error {
+	switch user.Situacao {
+	case repository.SituacaoAtivo:
+		return nil
+	case repository.SituacaoInativo:
+		return ErrContaDesativada
+	default:
+		return ErrCadastroInativo
+	}
+}
+
+// ConfirmarDoisFatores conclui o login com o código TOTP ou de recuperação.
+// Cada desafio aceita poucas tentativas e é descartado no sucesso; os códigos
+// errados também contam para o bloqueio da conta e do IP.
+func (s *AuthService) ConfirmarDoisFatores(desafio, codigo string, cliente ClienteSessao) (*Tokens, *models.Usuario, error) {
+	hash := hashToken(desafio)
+	idUsuario, err := s.doisFatores.repo.TentarDesafio(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.userRepo.FindByID(idUsuario)
 	if err != nil {
-		return "", nil, errors.New("email ou senha inválidos")
+		return nil, nil, err
+	}
+	// A conta pode ter sido desativada entre a senha e o código
+	if err := situacaoLogin(user); err != nil {
+		return nil, nil, err
+	}
+	if err := s.bloqueio.verificar(chaveConta(user.Email), chaveIP(cliente.IP)); err != nil {
+		return nil, nil, err
+	}
+	if err := s.doisFatores.Verificar(user, codigo); err != nil {
+		if err == ErrCodigoInvalido {
+			if bloqueio := s.bloqueio.falhar(user.Email, cliente.IP); bloqueio != nil {
+				return nil, nil, bloqueio
+			}
+		}
+		return nil, nil, err
+	}
+	if err := s.doisFatores.repo.RemoverDesafio(hash); err != nil {
+		return nil, nil, err
 	}
 
-	// Verificar senha
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	s.bloqueio.limpar(user.Email)
+	return s.iniciarSessao(user, cliente)
+}
+
+// iniciarSessao registra a sessão de um login concluído e emite o par de tokens
+func (s *AuthService) iniciarSessao(user *models.Usuario, cliente ClienteSessao) (*Tokens, *models.Usuario, error) {
+	// Cada login abre uma nova sessão, com a sua família de refresh tokens
+	familia := tokenAleatorio(16)
+	refreshToken := tokenAleatorio(32)
+	err := s.tokenRepo.CriarRefreshToken(user.ID, familia, hashToken(refreshToken), time.Now().Add(s.config.DuracaoRefreshToken))
+	if err != nil {
+		return nil, nil, err
+	}
+	idSessao, err := s.sessaoRepo.Criar(user.ID, familia, cliente.IP, cliente.AgenteUsuario)
 	if err != nil {
-		return "", nil, errors.New("email ou senha inválidos")
+		return nil, nil, err
 	}
 
-	// Criar token JWT
+	tokens, err := s.emitirAccessToken(user, idSessao)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens.RefreshToken = refreshToken
+	return tokens, user, nil
+}
+
+// Renovar troca o refresh token por um novo par de tokens. Os dados do
+// usuário são relidos, então mudanças de perfil valem a partir daqui. A
+// sessão precisa estar aberta e, com o limite de inatividade, em uso recente.
+func (s *AuthService) Renovar(refreshToken string) (*Tokens, *models.Usuario, error) {
+	novo := tokenAleatorio(32)
+	idUsuario, familia, err := s.tokenRepo.RotacionarRefreshToken(hashToken(refreshToken), hashToken(novo), time.Now().Add(s.config.DuracaoRefreshToken))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sessao, err := s.sessaoRepo.BuscarPorFamilia(familia)
+	if err == repository.ErrSessaoNaoEncontrada {
+		return nil, nil, ErrSessaoInvalida
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.conferirSessao(sessao); err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.userRepo.FindByID(idUsuario)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.emitirAccessToken(user, sessao.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens.RefreshToken = novo
+	return tokens, user, nil
+}
+
+// Logout encerra a sessão do token, revoga o token de acesso em uso e, se
+// informado, a sessão do refresh token
+func (s *AuthService) Logout(claims *JWTClaims, refreshToken string) error {
+	if err := s.sessaoRepo.Encerrar(claims.Sessao, claims.ID, repository.MotivoLogout); err != nil && err != repository.ErrSessaoNaoEncontrada {
+		return err
+	}
+	if refreshToken != "" {
+		if err := s.tokenRepo.RevogarFamilia(claims.ID, hashToken(refreshToken)); err != nil {
+			return err
+		}
+	}
+	if claims.RegisteredClaims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	return s.tokenRepo.RevogarAccessToken(claims.RegisteredClaims.ID, claims.ExpiresAt.Time)
+}
+
+// EncerrarSessoes invalida todos os tokens do usuário, em qualquer dispositivo
+func (s *AuthService) EncerrarSessoes(idUsuario int) error {
+	return s.tokenRepo.EncerrarSessoes(idUsuario)
+}
+
+// emitirAccessToken assina o token de acesso da sessão e indica se ele está
+// restrito ao cadastro do segundo fator ou à troca da senha expirada
+func (s *AuthService) emitirAccessToken(user *models.Usuario, idSessao int64) (*Tokens, error) {
+	cadastroPendente := false
+	if !user.DoisFatoresAtivo {
+		obrigatorio, err := s.doisFatores.Obrigatorio(user)
+		if err != nil {
+			return nil, err
+		}
+		cadastroPendente = obrigatorio
+	}
+	trocaSenha := SenhaExpirada(s.config, user)
+
+	agora := time.Now()
 	claims := JWTClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(s.config.TokenExpiryHours))),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        tokenAleatorio(16),
+			ExpiresAt: jwt.NewNumericDate(agora.Add(s.config.DuracaoAccessToken)),
+			IssuedAt:  jwt.NewNumericDate(agora),
 		},
 		ID:        user.ID,
 		CPF:       user.CPF,
@@ -59,19 +313,26 @@ func (s *AuthService) Login(email, password string) (string, *models.Usuario, er
 		Lotacao:   user.Lotacao,
 		Matricula: user.Matricula,
 		Admin:     user.Admin,
+		Perfil:    user.Perfil,
+		Unidade:   user.IDUnidade,
+		Sessao:    idSessao,
+
+		CadastroDoisFatores: cadastroPendente,
+		TrocaSenha:          trocaSenha,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	
 	// Assinar token
-	tokenString, err := token.SignedString([]byte(s.config.JWTSecret))
+	assinado, err := token.SignedString([]byte(s.config.JWTSecret))
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
-
-	return tokenString, user, nil
+	return &Tokens{AccessToken: assinado, CadastroDoisFatores: cadastroPendente, TrocaSenha: trocaSenha}, nil
 }
 
+// ValidateToken confere a assinatura e a validade do token e se ele não foi
+// revogado por logout, por invalidação das sessões ou pela exclusão do usuário
 func (s *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(s.config.JWTSecret), nil
@@ -81,9 +342,37 @@ func (s *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*JWTClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("token inválido")
 	}
 
-	return nil, errors.New("token inválido")
-}
\ No newline at end of file
+	validosDesde, revogado, err := s.tokenRepo.EstadoToken(claims.ID, claims.RegisteredClaims.ID)
+	if err == repository.ErrUsuarioNaoEncontrado {
+		return nil, ErrSessaoInvalida
+	}
+	if err != nil {
+		return nil, err
+	}
+	// O iat tem precisão de segundos
+	if revogado || claims.IssuedAt == nil || claims.IssuedAt.Time.Before(validosDesde.Truncate(time.Second)) {
+		return nil, ErrSessaoInvalida
+	}
+
+	return claims, nil
+}
+
+// tokenAleatorio gera n bytes aleatórios em base64 para URLs
+func tokenAleatorio(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hashToken é a forma em que o refresh token é guardado no banco
+func hashToken(token string) string {
+	soma := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(soma[:])
+}
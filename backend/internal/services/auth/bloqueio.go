@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+// ErroBloqueio indica login recusado pelo excesso de falhas recentes na
+// conta ou no IP
+type ErroBloqueio struct {
+	Espera time.Duration
+}
+
+func (e *ErroBloqueio) Error() string {
+	return fmt.Sprintf("muitas tentativas sem sucesso; tente novamente em %d minuto(s)", e.Minutos())
+}
+
+// Minutos arredonda a espera para cima, para exibição
+func (e *ErroBloqueio) Minutos() int {
+	return int(math.Ceil(e.Espera.Minutes()))
+}
+
+// chaveConta e chaveIP identificam as contagens de falhas em tentativa_login
+func chaveConta(login string) string {
+	return "conta:" + strings.ToLower(strings.TrimSpace(login))
+}
+
+func chaveIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	return "ip:" + ip
+}
+
+// controleBloqueio conta as falhas de login por conta e por IP e bloqueia
+// novas tentativas por um tempo que dobra a cada falha além do limite
+type controleBloqueio struct {
+	config    config.BloqueioLogin
+	repo      *repository.SenhaRepository
+	auditoria *repository.AuditoriaRepository
+}
+
+func novoControleBloqueio(cfg *config.Config) *controleBloqueio {
+	return &controleBloqueio{
+		config:    cfg.BloqueioLogin,
+		repo:      repository.NewSenhaRepository(),
+		auditoria: repository.NewAuditoriaRepository(),
+	}
+}
+
+// verificar recusa a tentativa se alguma das chaves estiver bloqueada
+func (c *controleBloqueio) verificar(chaves ...string) error {
+	ate, err := c.repo.BloqueadoAte(chaves...)
+	if err != nil {
+		return err
+	}
+	if espera := time.Until(ate); espera > 0 {
+		return &ErroBloqueio{Espera: espera}
+	}
+	return nil
+}
+
+// falhar conta a falha na conta e no IP e retorna o ErroBloqueio se uma
+// delas passou do limite. Erros do banco só são registrados, para não
+// alterar a resposta do login.
+func (c *controleBloqueio) falhar(login, ip string) error {
+	var bloqueio *ErroBloqueio
+	for _, alvo := range []struct {
+		chave  string
+		limite int
+	}{
+		{chaveConta(login), c.config.TentativasConta},
+		{chaveIP(ip), c.config.TentativasIP},
+	} {
+		if alvo.chave == "" || alvo.limite <= 0 {
+			continue
+		}
+		falhas, err := c.repo.RegistrarFalha(alvo.chave, c.config.Janela)
+		if err != nil {
+			log.Printf("Erro ao registrar falha de login: %v", err)
+			continue
+		}
+		if falhas < alvo.limite {
+			continue
+		}
+
+		espera := c.espera(falhas - alvo.limite)
+		if err := c.repo.Bloquear(alvo.chave, time.Now().Add(espera)); err != nil {
+			log.Printf("Erro ao bloquear %s: %v", alvo.chave, err)
+			continue
+		}
+		if err := c.auditoria.Registrar(&models.EventoAuditoria{
+			Acao: repository.AcaoLoginBloqueado,
+			Alvo: alvo.chave,
+			Detalhes: map[string]interface{}{
+				"falhas":         falhas,
+				"esperaSegundos": int(espera.Seconds()),
+				"ip":             ip,
+			},
+		}); err != nil {
+			log.Printf("Erro ao auditar o bloqueio de %s: %v", alvo.chave, err)
+		}
+		if bloqueio == nil || espera > bloqueio.Espera {
+			bloqueio = &ErroBloqueio{Espera: espera}
+		}
+	}
+	if bloqueio != nil {
+		return bloqueio
+	}
+	return nil
+}
+
+// espera dobra o bloqueio inicial a cada falha além do limite, até o máximo
+func (c *controleBloqueio) espera(excesso int) time.Duration {
+	espera := c.config.Espera
+	for i := 0; i < excesso && espera < c.config.EsperaMaxima; i++ {
+		espera *= 2
+	}
+	if espera > c.config.EsperaMaxima {
+		espera = c.config.EsperaMaxima
+	}
+	return espera
+}
+
+// limpar zera as contagens das contas depois de um login concluído. A do IP
+// continua, para que entrar na própria conta não libere novas tentativas.
+func (c *controleBloqueio) limpar(logins ...string) {
+	chaves := make([]string, len(logins))
+	for i, login := range logins {
+		chaves[i] = chaveConta(login)
+	}
+	if err := c.repo.LimparFalhas(chaves...); err != nil {
+		log.Printf("Erro ao limpar as falhas de login: %v", err)
+	}
+}
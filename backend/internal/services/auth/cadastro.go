@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/notificacao"
+)
+
+// contextoConfirmacaoEmail separa a chave dos links de confirmação da chave
+// de assinatura dos tokens, ambas derivadas de JWT_SECRET
+const contextoConfirmacaoEmail = "consultapix:confirmacao-email"
+
+var (
+	// ErrCadastroInativo indica cadastro sem e-mail confirmado, sem aprovação ou rejeitado
+	ErrCadastroInativo = errors.New("cadastro aguardando a confirmação do e-mail e a aprovação de um administrador")
+	// ErrLinkConfirmacaoInvalido indica link adulterado, expirado ou de cadastro já removido
+	ErrLinkConfirmacaoInvalido = errors.New("link de confirmação inválido ou expirado")
+)
+
+// CadastroService conduz o autocadastro: o usuário nasce pendente, confirma o
+// e-mail por um link assinado e aguarda a aprovação de um administrador da
+// sua lotação
+type CadastroService struct {
+	config    *config.Config
+	senhas    *SenhaService
+	userRepo  *repository.UserRepository
+	repo      *repository.CadastroRepository
+	auditoria *repository.AuditoriaRepository
+}
+
+func NewCadastroService(cfg *config.Config) *CadastroService {
+	return &CadastroService{
+		config:    cfg,
+		senhas:    NewSenhaService(cfg),
+		userRepo:  repository.NewUserRepository(),
+		repo:      repository.NewCadastroRepository(),
+		auditoria: repository.NewAuditoriaRepository(),
+	}
+}
+
+// Cadastrar cria o usuário pendente, sem perfil de administrador, e envia o
+// link de confirmação do e-mail. A senha precisa atender à política.
+func (s *CadastroService) Cadastrar(user *models.Usuario) error {
+	if err := s.senhas.Validar(user, user.Password); err != nil {
+		return err
+	}
+	user.Admin = false
+	user.Situacao = repository.SituacaoPendente
+	id, err := s.userRepo.Create(user)
+	if err != nil {
+		return err
+	}
+	user.ID = id
+
+	if err := s.auditoria.Registrar(&models.EventoAuditoria{
+		CPFUsuario: user.CPF,
+		Lotacao:    user.Lotacao,
+		Acao:       repository.AcaoCadastroSolicitado,
+		Alvo:       user.CPF,
+		Detalhes:   map[string]interface{}{"idUsuario": id, "email": user.Email},
+	}); err != nil {
+		log.Printf("Erro ao auditar o cadastro de %s: %v", user.CPF, err)
+	}
+
+	link := s.linkConfirmacao(user, time.Now().Add(s.config.PrazoCadastro))
+	go enviarEmail(s.config, user, "Confirme seu e-mail", fmt.Sprintf(
+		"Recebemos seu cadastro no ConsultaPix. Para confirmar o e-mail, acesse:\r\n\r\n%s\r\n\r\n"+
+			"Depois disso, um administrador da lotação %s precisa aprovar o acesso. O link vale até %s.",
+		link, user.Lotacao, time.Now().Add(s.config.PrazoCadastro).Format("02/01/2006 15:04")), link)
+	return nil
+}
+
+// ConfirmarEmail valida o link recebido por e-mail e marca o e-mail como confirmado
+func (s *CadastroService) ConfirmarEmail(token string) error {
+	partes := strings.Split(token, ".")
+	if len(partes) != 3 {
+		return ErrLinkConfirmacaoInvalido
+	}
+	id, err := strconv.Atoi(partes[0])
+	if err != nil {
+		return ErrLinkConfirmacaoInvalido
+	}
+	expira, err := strconv.ParseInt(partes[1], 10, 64)
+	if err != nil || time.Now().Unix() > expira {
+		return ErrLinkConfirmacaoInvalido
+	}
+
+	user, err := s.userRepo.FindByID(id)
+	if err != nil {
+		return ErrLinkConfirmacaoInvalido
+	}
+	esperada := s.assinar(partes[0]+"."+partes[1], user.Email)
+	if !hmac.Equal([]byte(esperada), []byte(partes[2])) {
+		return ErrLinkConfirmacaoInvalido
+	}
+
+	if err := s.repo.ConfirmarEmail(id, user.Email); err != nil {
+		if err == repository.ErrCadastroNaoEncontrado {
+			return ErrLinkConfirmacaoInvalido
+		}
+		return err
+	}
+	return nil
+}
+
+// ListarPendentes lista os cadastros aguardando aprovação na lotação
+func (s *CadastroService) ListarPendentes(lotacao string) ([]models.CadastroPendente, error) {
+	return s.repo.ListarPendentes(lotacao)
+}
+
+// Aprovar libera o acesso de um cadastro da lotação do administrador
+func (s *CadastroService) Aprovar(cpfAdmin, lotacaoAdmin string, id int) error {
+	user, err := s.repo.Decidir(id, lotacaoAdmin, cpfAdmin, true)
+	if err != nil {
+		return err
+	}
+
+	go enviarEmail(s.config, user, "Cadastro aprovado", "Seu cadastro no ConsultaPix foi aprovado. Você já pode entrar com seu e-mail e senha.", "")
+	return s.auditoria.Registrar(&models.EventoAuditoria{
+		CPFUsuario: cpfAdmin,
+		Lotacao:    lotacaoAdmin,
+		Acao:       repository.AcaoCadastroAprovado,
+		Alvo:       user.CPF,
+		Detalhes:   map[string]interface{}{"idUsuario": id},
+	})
+}
+
+// Rejeitar recusa um cadastro da lotação do administrador. O cadastro é
+// removido na próxima limpeza.
+func (s *CadastroService) Rejeitar(cpfAdmin, lotacaoAdmin string, id int, motivo string) error {
+	user, err := s.repo.Decidir(id, lotacaoAdmin, cpfAdmin, false)
+	if err != nil {
+		return err
+	}
+
+	mensagem := "Seu cadastro no ConsultaPix não foi aprovado."
+	if motivo != "" {
+		mensagem += " Motivo: " + motivo
+	}
+	go enviarEmail(s.config, user, "Cadastro não aprovado", mensagem, "")
+	return s.auditoria.Registrar(&models.EventoAuditoria{
+		CPFUsuario: cpfAdmin,
+		Lotacao:    lotacaoAdmin,
+		Acao:       repository.AcaoCadastroRejeitado,
+		Alvo:       user.CPF,
+		Detalhes:   map[string]interface{}{"idUsuario": id, "motivo": motivo},
+	})
+}
+
+// Executar remove periodicamente os cadastros rejeitados e os que passaram do prazo
+func (s *CadastroService) Executar(intervalo time.Duration) {
+	ticker := time.NewTicker(intervalo)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		removidos, err := s.repo.RemoverEncerrados(time.Now().Add(-s.config.PrazoCadastro))
+		if err != nil {
+			log.Printf("Erro na limpeza de cadastros: %v", err)
+			continue
+		}
+		if removidos > 0 {
+			log.Printf("Cadastros rejeitados ou expirados removidos: %d", removidos)
+		}
+	}
+}
+
+// linkConfirmacao monta o link com o ID, a validade e a assinatura, que
+// também cobre o e-mail: trocar o e-mail invalida o link
+func (s *CadastroService) linkConfirmacao(user *models.Usuario, expira time.Time) string {
+	dados := fmt.Sprintf("%d.%d", user.ID, expira.Unix())
+	return s.config.URLPublica + "/api/user/register/verificar?token=" + dados + "." + s.assinar(dados, user.Email)
+}
+
+func (s *CadastroService) assinar(dados, email string) string {
+	derivacao := hmac.New(sha256.New, []byte(s.config.JWTSecret))
+	derivacao.Write([]byte(contextoConfirmacaoEmail))
+	mac := hmac.New(sha256.New, derivacao.Sum(nil))
+	mac.Write([]byte(dados + "." + strings.ToLower(email)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// enviarEmail avisa o usuário sem atrasar a resposta. Sem SMTP configurado, o
+// link do e-mail só aparece no log em modo de desenvolvimento.
+func enviarEmail(cfg *config.Config, user *models.Usuario, assunto, mensagem, link string) {
+	err := notificacao.EnviarEmail(cfg, user, assunto, mensagem)
+	if err == nil {
+		return
+	}
+	log.Printf("Erro ao enviar o e-mail %q para %s: %v", assunto, user.Email, err)
+	if link != "" && cfg.Desenvolvimento() {
+		log.Printf("Link do e-mail %q para %s (desenvolvimento): %s", assunto, user.Email, link)
+	}
+}
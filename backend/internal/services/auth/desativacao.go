@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+// tamanhoTextoDesativacao acompanha as colunas motivo_desativacao e
+// justificativa_reativacao
+const tamanhoTextoDesativacao = 500
+
+var (
+	// ErrContaDesativada indica login em conta desativada por um administrador
+	ErrContaDesativada = errors.New("conta desativada; solicite a reativação a um administrador")
+	// ErrAutoDesativacao indica administrador tentando desativar a própria conta
+	ErrAutoDesativacao = errors.New("o administrador não pode desativar a própria conta")
+	// ErrMotivoDesativacao indica motivo ausente ou longo demais
+	ErrMotivoDesativacao = errors.New("informe o motivo da desativação, com até 500 caracteres")
+	// ErrJustificativaReativacao indica justificativa ausente ou longa demais
+	ErrJustificativaReativacao = errors.New("informe a justificativa do pedido, com até 500 caracteres")
+)
+
+// DesativacaoService desativa contas no lugar de excluí-las, para que as
+// requisições e a auditoria continuem ligadas a uma pessoa, e conduz a
+// reativação, que depende sempre de um administrador
+type DesativacaoService struct {
+	config    *config.Config
+	auth      *AuthService
+	repo      *repository.DesativacaoRepository
+	auditoria *repository.AuditoriaRepository
+}
+
+func NewDesativacaoService(cfg *config.Config) *DesativacaoService {
+	return &DesativacaoService{
+		config:    cfg,
+		auth:      NewAuthService(cfg),
+		repo:      repository.NewDesativacaoRepository(),
+		auditoria: repository.NewAuditoriaRepository(),
+	}
+}
+
+// Desativar bloqueia o login do usuário e encerra as suas sessões
+func (s *DesativacaoService) Desativar(cpfAdmin, lotacaoAdmin string, idAdmin, id int, motivo string) error {
+	if id == idAdmin {
+		return ErrAutoDesativacao
+	}
+	motivo, ok := textoDesativacao(motivo)
+	if !ok {
+		return ErrMotivoDesativacao
+	}
+
+	user, err := s.repo.Desativar(id, cpfAdmin, motivo)
+	if err != nil {
+		return err
+	}
+	if err := s.auth.EncerrarSessoes(id); err != nil {
+		return err
+	}
+
+	go enviarEmail(s.config, user, "Conta desativada", "Sua conta no ConsultaPix foi desativada. Motivo: "+motivo+
+		"\r\n\r\nPara voltar a usar o sistema, peça a reativação na tela de login.", "")
+	s.auditar(cpfAdmin, lotacaoAdmin, repository.AcaoUsuarioDesativado, user, map[string]interface{}{"motivo": motivo})
+	return nil
+}
+
+// SolicitarReativacao registra o pedido do próprio usuário, que se identifica
+// com as credenciais da conta desativada. As falhas contam para o bloqueio
+// como no login.
+func (s *DesativacaoService) SolicitarReativacao(email, senha, justificativa string, cliente ClienteSessao) error {
+	justificativa, ok := textoDesativacao(justificativa)
+	if !ok {
+		return ErrJustificativaReativacao
+	}
+
+	user, err := s.auth.autenticar(email, senha, cliente)
+	if err != nil {
+		return err
+	}
+	if user.Situacao != repository.SituacaoInativo {
+		return repository.ErrUsuarioNaoInativo
+	}
+	if err := s.repo.SolicitarReativacao(user.ID, justificativa); err != nil {
+		return err
+	}
+	s.auth.bloqueio.limpar(email, user.Email)
+
+	s.auditar(user.CPF, user.Lotacao, repository.AcaoReativacaoSolicitada, user, map[string]interface{}{
+		"justificativa": justificativa,
+		"ip":            cliente.IP,
+	})
+	return nil
+}
+
+// ListarInativos lista as contas desativadas e os pedidos de reativação
+func (s *DesativacaoService) ListarInativos() ([]models.UsuarioInativo, error) {
+	return s.repo.ListarInativos()
+}
+
+// Reativar devolve o acesso à conta desativada, com ou sem pedido
+func (s *DesativacaoService) Reativar(cpfAdmin, lotacaoAdmin string, id int) error {
+	user, err := s.repo.Reativar(id)
+	if err != nil {
+		return err
+	}
+
+	go enviarEmail(s.config, user, "Conta reativada", "Sua conta no ConsultaPix foi reativada. Você já pode entrar novamente.", "")
+	s.auditar(cpfAdmin, lotacaoAdmin, repository.AcaoUsuarioReativado, user, map[string]interface{}{})
+	return nil
+}
+
+// RejeitarReativacao recusa o pedido; a conta continua desativada e o
+// usuário pode pedir de novo
+func (s *DesativacaoService) RejeitarReativacao(cpfAdmin, lotacaoAdmin string, id int, motivo string) error {
+	user, err := s.repo.RejeitarReativacao(id)
+	if err != nil {
+		return err
+	}
+
+	mensagem := "Seu pedido de reativação da conta no ConsultaPix não foi aprovado."
+	if motivo != "" {
+		mensagem += " Motivo: " + motivo
+	}
+	go enviarEmail(s.config, user, "Reativação não aprovada", mensagem, "")
+	s.auditar(cpfAdmin, lotacaoAdmin, repository.AcaoReativacaoRejeitada, user, map[string]interface{}{"motivo": motivo})
+	return nil
+}
+
+func (s *DesativacaoService) auditar(cpf, lotacao, acao string, user *models.Usuario, detalhes map[string]interface{}) {
+	detalhes["idUsuario"] = user.ID
+	if err := s.auditoria.Registrar(&models.EventoAuditoria{
+		CPFUsuario: cpf,
+		Lotacao:    lotacao,
+		Acao:       acao,
+		Alvo:       user.CPF,
+		Detalhes:   detalhes,
+	}); err != nil {
+		log.Printf("Erro ao auditar %s de %s: %v", acao, user.CPF, err)
+	}
+}
+
+// textoDesativacao apara o motivo ou a justificativa e confere o tamanho
+func textoDesativacao(texto string) (string, bool) {
+	texto = strings.TrimSpace(texto)
+	return texto, texto != "" && utf8.RuneCountInString(texto) <= tamanhoTextoDesativacao
+}
@@ -0,0 +1,289 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/cripto"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+// quantidadeCodigosRecuperacao é quantos códigos de uso único são gerados por vez
+const quantidadeCodigosRecuperacao = 10
+
+var (
+	// ErrChaveDoisFatoresAusente indica que MFA_ENCRYPTION_KEY não foi configurada
+	ErrChaveDoisFatoresAusente = errors.New("cadastro de dois fatores indisponível: MFA_ENCRYPTION_KEY não configurada")
+	// ErrCodigoInvalido indica código TOTP ou de recuperação incorreto ou já usado
+	ErrCodigoInvalido = errors.New("código de verificação inválido")
+	// ErrDoisFatoresObrigatorio impede desativar o segundo fator exigido pela política
+	ErrDoisFatoresObrigatorio = errors.New("a autenticação em dois fatores é obrigatória para o seu perfil")
+)
+
+// StatusDoisFatores resume o segundo fator do usuário
+type StatusDoisFatores struct {
+	Ativo              bool `json:"ativo"`
+	Obrigatorio        bool `json:"obrigatorio"`
+	CodigosRecuperacao int  `json:"codigosRecuperacao"`
+}
+
+// DoisFatoresService cuida do cadastro e da verificação do TOTP e dos
+// códigos de recuperação
+type DoisFatoresService struct {
+	config    *config.Config
+	repo      *repository.DoisFatoresRepository
+	tokens    *repository.TokenRepository
+	auditoria *repository.AuditoriaRepository
+}
+
+func NewDoisFatoresService(cfg *config.Config) *DoisFatoresService {
+	return &DoisFatoresService{
+		config:    cfg,
+		repo:      repository.NewDoisFatoresRepository(),
+		tokens:    repository.NewTokenRepository(),
+		auditoria: repository.NewAuditoriaRepository(),
+	}
+}
+
+func (s *DoisFatoresService) cifrador() (*cripto.Cifrador, error) {
+	if len(s.config.ChaveDoisFatores) == 0 {
+		return nil, ErrChaveDoisFatoresAusente
+	}
+	return cripto.NovoCifrador(s.config.ChaveDoisFatores)
+}
+
+// contextoCifraTOTP vincula o segredo cifrado ao usuário dono
+func contextoCifraTOTP(idUsuario int) []byte {
+	return []byte("usuario:" + strconv.Itoa(idUsuario) + ":totp")
+}
+
+// Obrigatorio indica se a política exige o segundo fator para o usuário
+func (s *DoisFatoresService) Obrigatorio(user *models.Usuario) (bool, error) {
+	return s.repo.Obrigatorio(repository.Papel(user.Admin))
+}
+
+// Status retorna se o segundo fator está ativo, se é obrigatório e quantos
+// códigos de recuperação restam
+func (s *DoisFatoresService) Status(user *models.Usuario) (*StatusDoisFatores, error) {
+	obrigatorio, err := s.Obrigatorio(user)
+	if err != nil {
+		return nil, err
+	}
+	restantes, err := s.repo.CodigosRestantes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &StatusDoisFatores{Ativo: user.DoisFatoresAtivo, Obrigatorio: obrigatorio, CodigosRecuperacao: restantes}, nil
+}
+
+// Cadastrar gera um novo segredo, ainda pendente de confirmação, e retorna o
+// segredo e a URI do QR code
+func (s *DoisFatoresService) Cadastrar(user *models.Usuario) (string, string, error) {
+	cifrador, err := s.cifrador()
+	if err != nil {
+		return "", "", err
+	}
+
+	segredo := novoSegredoTOTP()
+	cifrado, err := cifrador.Cifrar([]byte(segredo), contextoCifraTOTP(user.ID))
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.repo.SalvarSegredoPendente(user.ID, cifrado); err != nil {
+		return "", "", err
+	}
+
+	return segredo, URIProvisionamento(segredo, user.Email), nil
+}
+
+// Ativar confirma o cadastro com um código do aplicativo e retorna os códigos
+// de recuperação, exibidos só desta vez. As sessões abertas sem o segundo
+// fator são encerradas.
+func (s *DoisFatoresService) Ativar(user *models.Usuario, codigo string) ([]string, error) {
+	segredo, err := s.segredo(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if segredo.Ativo {
+		return nil, repository.ErrDoisFatoresJaAtivo
+	}
+	if segredo.Cifrado == nil {
+		return nil, repository.ErrDoisFatoresNaoCadastrado
+	}
+	if err := s.verificarTOTP(user.ID, segredo, codigo); err != nil {
+		return nil, err
+	}
+
+	codigos, hashes := gerarCodigosRecuperacao()
+	if err := s.repo.Ativar(user.ID, hashes); err != nil {
+		return nil, err
+	}
+	if err := s.tokens.EncerrarSessoes(user.ID); err != nil {
+		return nil, err
+	}
+
+	s.auditar(user.CPF, user.Lotacao, repository.AcaoDoisFatoresAtivado, user.CPF, nil)
+	return codigos, nil
+}
+
+// Desativar remove o segundo fator a pedido do próprio usuário, que confirma
+// com um código válido
+func (s *DoisFatoresService) Desativar(user *models.Usuario, codigo string) error {
+	obrigatorio, err := s.Obrigatorio(user)
+	if err != nil {
+		return err
+	}
+	if obrigatorio {
+		return ErrDoisFatoresObrigatorio
+	}
+	if err := s.Verificar(user, codigo); err != nil {
+		return err
+	}
+	if err := s.repo.Desativar(user.ID); err != nil {
+		return err
+	}
+
+	s.auditar(user.CPF, user.Lotacao, repository.AcaoDoisFatoresDesativado, user.CPF, nil)
+	return nil
+}
+
+// GerarCodigosRecuperacao substitui os códigos de recuperação, mediante um código válido
+func (s *DoisFatoresService) GerarCodigosRecuperacao(user *models.Usuario, codigo string) ([]string, error) {
+	if err := s.Verificar(user, codigo); err != nil {
+		return nil, err
+	}
+
+	codigos, hashes := gerarCodigosRecuperacao()
+	if err := s.repo.SubstituirCodigos(user.ID, hashes); err != nil {
+		return nil, err
+	}
+
+	s.auditar(user.CPF, user.Lotacao, repository.AcaoCodigosRecuperacaoGerados, user.CPF, nil)
+	return codigos, nil
+}
+
+// Resetar remove o segundo fator de um usuário que perdeu o dispositivo e os
+// códigos. Só administradores o fazem, com justificativa registrada na
+// auditoria; as sessões do usuário são encerradas.
+func (s *DoisFatoresService) Resetar(cpfAdmin, lotacaoAdmin string, user *models.Usuario, justificativa string) error {
+	if err := s.repo.Desativar(user.ID); err != nil {
+		return err
+	}
+	if err := s.tokens.EncerrarSessoes(user.ID); err != nil {
+		return err
+	}
+	return s.auditoria.Registrar(&models.EventoAuditoria{
+		CPFUsuario: cpfAdmin,
+		Lotacao:    lotacaoAdmin,
+		Acao:       repository.AcaoDoisFatoresResetado,
+		Alvo:       user.CPF,
+		Detalhes:   map[string]interface{}{"justificativa": justificativa, "idUsuario": user.ID},
+	})
+}
+
+// Verificar aceita um código TOTP ou, na falta do aplicativo, um código de recuperação
+func (s *DoisFatoresService) Verificar(user *models.Usuario, codigo string) error {
+	segredo, err := s.segredo(user.ID)
+	if err != nil {
+		return err
+	}
+	if !segredo.Ativo {
+		return repository.ErrDoisFatoresNaoCadastrado
+	}
+
+	codigo = strings.TrimSpace(codigo)
+	if len(codigo) == digitosTOTP {
+		return s.verificarTOTP(user.ID, segredo, codigo)
+	}
+
+	usado, err := s.repo.UsarCodigoRecuperacao(user.ID, hashToken(normalizarCodigoRecuperacao(codigo)))
+	if err != nil {
+		return err
+	}
+	if !usado {
+		return ErrCodigoInvalido
+	}
+	s.auditar(user.CPF, user.Lotacao, repository.AcaoCodigoRecuperacaoUsado, user.CPF, nil)
+	return nil
+}
+
+func (s *DoisFatoresService) segredo(idUsuario int) (*repository.SegredoTOTP, error) {
+	return s.repo.Segredo(idUsuario)
+}
+
+// verificarTOTP confere o código e registra o passo, para que o mesmo código
+// não seja aceito duas vezes
+func (s *DoisFatoresService) verificarTOTP(idUsuario int, segredo *repository.SegredoTOTP, codigo string) error {
+	cifrador, err := s.cifrador()
+	if err != nil {
+		return err
+	}
+	claro, err := cifrador.Decifrar(segredo.Cifrado, contextoCifraTOTP(idUsuario))
+	if err != nil {
+		return err
+	}
+
+	passo, ok := verificarTOTP(string(claro), strings.TrimSpace(codigo), time.Now())
+	if !ok {
+		return ErrCodigoInvalido
+	}
+	aceito, err := s.repo.RegistrarPasso(idUsuario, passo)
+	if err != nil {
+		return err
+	}
+	if !aceito {
+		return ErrCodigoInvalido
+	}
+	return nil
+}
+
+// auditar registra o evento; uma falha na auditoria não desfaz a operação
+func (s *DoisFatoresService) auditar(cpf, lotacao, acao, alvo string, detalhes map[string]interface{}) {
+	s.auditoria.Registrar(&models.EventoAuditoria{CPFUsuario: cpf, Lotacao: lotacao, Acao: acao, Alvo: alvo, Detalhes: detalhes})
+}
+
+// gerarCodigosRecuperacao retorna os códigos para exibição e os hashes para o banco
+func gerarCodigosRecuperacao() ([]string, []string) {
+	codigos := make([]string, quantidadeCodigosRecuperacao)
+	hashes := make([]string, quantidadeCodigosRecuperacao)
+	for i := range codigos {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+		texto := strings.ToLower(base32SemPadding.EncodeToString(b))[:10]
+		codigos[i] = texto[:5] + "-" + texto[5:]
+		hashes[i] = hashToken(texto)
+	}
+	return codigos, hashes
+}
+
+func normalizarCodigoRecuperacao(codigo string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(codigo))
+}
+
+// Politica retorna, por papel, se o segundo fator é obrigatório
+func (s *DoisFatoresService) Politica() (map[string]bool, error) {
+	return s.repo.Politica()
+}
+
+// SalvarPolitica altera a obrigatoriedade de um papel. Quem ainda não cadastrou
+// o segundo fator recebe tokens restritos ao cadastro a partir da próxima
+// renovação.
+func (s *DoisFatoresService) SalvarPolitica(cpfAdmin, lotacaoAdmin, papel string, obrigatorio bool) error {
+	if err := s.repo.SalvarPolitica(papel, obrigatorio, cpfAdmin); err != nil {
+		return err
+	}
+	return s.auditoria.Registrar(&models.EventoAuditoria{
+		CPFUsuario: cpfAdmin,
+		Lotacao:    lotacaoAdmin,
+		Acao:       repository.AcaoPoliticaDoisFatoresAlterada,
+		Alvo:       papel,
+		Detalhes:   map[string]interface{}{"obrigatorio": obrigatorio},
+	})
+}
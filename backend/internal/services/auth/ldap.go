@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/ldap"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+// provedorLDAP autentica pelo bind no diretório (LDAP ou Active Directory) e
+// provisiona o usuário com os atributos dele
+type provedorLDAP struct {
+	config   config.ConfigLDAP
+	tls      *tls.Config
+	userRepo *repository.UserRepository
+}
+
+func novoProvedorLDAP(cfg config.ConfigLDAP, userRepo *repository.UserRepository) *provedorLDAP {
+	return &provedorLDAP{
+		config:   cfg,
+		tls:      &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: cfg.AutoridadesCA},
+		userRepo: userRepo,
+	}
+}
+
+func (p *provedorLDAP) Nome() string {
+	return config.ProvedorLDAP
+}
+
+// Autenticar localiza o usuário pelo login com a conta de serviço, confirma a
+// senha com um bind no DN dele e aplica o mapeamento de grupos
+func (p *provedorLDAP) Autenticar(login, senha string) (*models.Usuario, error) {
+	login = strings.TrimSpace(login)
+	if login == "" || senha == "" {
+		return nil, ErrCredenciaisInvalidas
+	}
+
+	conn, err := ldap.Conectar(ldap.Opcoes{URL: p.config.URL, StartTLS: p.config.StartTLS, TLS: p.tls})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Fechar()
+
+	if p.config.BindDN != "" {
+		if err := conn.Bind(p.config.BindDN, p.config.BindSenha); err != nil {
+			return nil, fmt.Errorf("bind da conta de serviço LDAP: %w", err)
+		}
+	}
+
+	entradas, err := conn.Buscar(p.config.BaseDN,
+		map[string]string{"objectClass": p.config.ClasseUsuario, p.config.AtributoLogin: login},
+		[]string{p.config.AtributoNome, p.config.AtributoEmail, p.config.AtributoCPF,
+			p.config.AtributoLotacao, p.config.AtributoMatricula, p.config.AtributoGrupos})
+	if err != nil {
+		return nil, err
+	}
+	// Login ambíguo também é recusado
+	if len(entradas) != 1 {
+		return nil, ErrCredenciaisInvalidas
+	}
+	entrada := entradas[0]
+
+	if err := conn.Bind(entrada.DN, senha); err != nil {
+		if errors.Is(err, ldap.ErrCredenciaisInvalidas) {
+			return nil, ErrCredenciaisInvalidas
+		}
+		return nil, err
+	}
+
+	grupos := entrada.Valores(p.config.AtributoGrupos)
+	if len(p.config.GruposPermitidos) > 0 && !membroDeAlgum(grupos, p.config.GruposPermitidos) {
+		return nil, ErrCredenciaisInvalidas
+	}
+
+	user := &models.Usuario{
+		Nome:      entrada.Valor(p.config.AtributoNome),
+		Email:     entrada.Valor(p.config.AtributoEmail),
+		CPF:       somenteDigitos(entrada.Valor(p.config.AtributoCPF)),
+		Lotacao:   entrada.Valor(p.config.AtributoLotacao),
+		Matricula: entrada.Valor(p.config.AtributoMatricula),
+		Admin:     membroDeAlgum(grupos, p.config.GruposAdmin),
+		Origem:    OrigemLDAP,
+	}
+	if user.CPF == "" || user.Email == "" {
+		return nil, fmt.Errorf("usuário LDAP %s sem os atributos %s e %s", entrada.DN, p.config.AtributoCPF, p.config.AtributoEmail)
+	}
+	if user.Nome == "" {
+		user.Nome = login
+	}
+
+	return p.userRepo.ProvisionarExterno(user, strings.ToLower(entrada.DN), len(p.config.GruposAdmin) > 0)
+}
+
+// membroDeAlgum compara DNs de grupo sem diferenciar maiúsculas
+func membroDeAlgum(grupos, procurados []string) bool {
+	for _, g := range grupos {
+		for _, p := range procurados {
+			if strings.EqualFold(strings.TrimSpace(g), p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func somenteDigitos(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
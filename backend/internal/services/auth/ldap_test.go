@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/ldap"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+const (
+	dnServicoTeste = "cn=servico,dc=exemplo,dc=local"
+	grupoPolicia   = "cn=policia,dc=exemplo,dc=local"
+	grupoAdmin     = "cn=admin,dc=exemplo,dc=local"
+)
+
+// conectarBancoTeste usa o PostgreSQL indicado em CONSULTAPIX_TEST_DATABASE_URL,
+// aplicando as migrações pendentes. Sem a variável o teste é ignorado.
+func conectarBancoTeste(t *testing.T) {
+	t.Helper()
+	databaseURL := os.Getenv("CONSULTAPIX_TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("defina CONSULTAPIX_TEST_DATABASE_URL para executar os testes com banco")
+	}
+
+	if err := database.Conectar(&config.Config{DatabaseURL: databaseURL}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.DB.Close() })
+	if _, err := database.Migrar(database.DB); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// entradaLDAPTeste é um usuário do diretório simulado com a senha senha123
+func entradaLDAPTeste(login, cpf string, grupos ...string) ldap.EntradaSimulada {
+	return ldap.EntradaSimulada{
+		Entrada: ldap.Entrada{DN: "uid=" + login + ",ou=pessoas,dc=exemplo,dc=local", Atributos: map[string][]string{
+			"objectClass":    {"person"},
+			"uid":            {login},
+			"displayName":    {"Usuário " + login},
+			"mail":           {login + "@exemplo.local"},
+			"employeeNumber": {cpf},
+			"department":     {"Delegacia de Testes"},
+			"employeeID":     {"M-" + login},
+			"memberOf":       grupos,
+		}},
+		Senha: "senha123",
+	}
+}
+
+// novoProvedorLDAPTeste sobe o diretório simulado com as entradas e a conta
+// de serviço e devolve o provedor configurado para ele
+func novoProvedorLDAPTeste(t *testing.T, userRepo *repository.UserRepository, gruposAdmin, gruposPermitidos []string, entradas ...ldap.EntradaSimulada) *provedorLDAP {
+	t.Helper()
+	entradas = append(entradas, ldap.EntradaSimulada{Entrada: ldap.Entrada{DN: dnServicoTeste}, Senha: "servico123"})
+	servidor, err := ldap.NovoServidorSimulado(entradas...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { servidor.Fechar() })
+
+	return novoProvedorLDAP(config.ConfigLDAP{
+		URL:               servidor.URL,
+		BindDN:            dnServicoTeste,
+		BindSenha:         "servico123",
+		BaseDN:            "dc=exemplo,dc=local",
+		ClasseUsuario:     "person",
+		AtributoLogin:     "uid",
+		AtributoNome:      "displayName",
+		AtributoEmail:     "mail",
+		AtributoCPF:       "employeeNumber",
+		AtributoLotacao:   "department",
+		AtributoMatricula: "employeeID",
+		AtributoGrupos:    "memberOf",
+		GruposAdmin:       gruposAdmin,
+		GruposPermitidos:  gruposPermitidos,
+	}, userRepo)
+}
+
+// cpfTeste gera um CPF de 11 dígitos diferente a cada execução
+func cpfTeste(deslocamento int64) string {
+	n := (time.Now().UnixNano()/1000 + deslocamento) % 100000000000
+	cpf := strconv.FormatInt(n, 10)
+	for len(cpf) < 11 {
+		cpf = "0" + cpf
+	}
+	return cpf
+}
+
+func TestLDAPRecusaCredenciais(t *testing.T) {
+	// Sem provisionamento, o provedor não chega ao banco
+	provedor := novoProvedorLDAPTeste(t, nil, nil, []string{grupoPolicia},
+		entradaLDAPTeste("agente", "12345678909", grupoPolicia),
+		entradaLDAPTeste("visitante", "98765432100"),
+		entradaLDAPTeste("duplicado", "11111111111", grupoPolicia),
+		entradaLDAPTeste("duplicado", "22222222222", grupoPolicia),
+	)
+
+	casos := []struct {
+		nome  string
+		login string
+		senha string
+	}{
+		{"senha errada", "agente", "errada"},
+		{"senha vazia", "agente", ""},
+		{"login inexistente", "ninguem", "senha123"},
+		{"fora dos grupos permitidos", "visitante", "senha123"},
+		{"login ambíguo", "duplicado", "senha123"},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			if _, err := provedor.Autenticar(c.login, c.senha); err != ErrCredenciaisInvalidas {
+				t.Fatalf("Autenticar = %v, esperado ErrCredenciaisInvalidas", err)
+			}
+		})
+	}
+}
+
+func TestLDAPNaoAssumeContaComMesmoCPF(t *testing.T) {
+	conectarBancoTeste(t)
+	db := database.GetDB()
+	userRepo := repository.NewUserRepository()
+
+	// Administrador local com o CPF formatado, como o semeado na instalação
+	cpf := cpfTeste(0)
+	cpfFormatado := cpf[:3] + "." + cpf[3:6] + "." + cpf[6:9] + "-" + cpf[9:]
+	sufixo := strconv.FormatInt(time.Now().UnixNano(), 10)
+	idLocal, err := userRepo.Create(&models.Usuario{
+		Nome: "Admin local", CPF: cpfFormatado, Email: "admin-" + sufixo + "@exemplo.local",
+		Password: "Senha-Local-123", Matricula: "L-" + sufixo, Admin: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM usuario WHERE id = $1`, idLocal) })
+	antes, err := userRepo.FindByID(idLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Com ou sem o mapeamento de grupos, a identidade do diretório não herda a conta
+	for _, gruposAdmin := range [][]string{nil, {grupoAdmin}} {
+		provedor := novoProvedorLDAPTeste(t, userRepo, gruposAdmin, nil,
+			entradaLDAPTeste("impostor-"+sufixo, cpf, grupoAdmin))
+		if _, err := provedor.Autenticar("impostor-"+sufixo, "senha123"); err != repository.ErrContaExistente {
+			t.Fatalf("Autenticar com gruposAdmin %v = %v, esperado ErrContaExistente", gruposAdmin, err)
+		}
+	}
+
+	depois, err := userRepo.FindByID(idLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if depois.Origem != OrigemLocal || depois.Password != antes.Password || !depois.Admin {
+		t.Fatalf("conta local alterada: origem %q, admin %v, senha mantida %v",
+			depois.Origem, depois.Admin, depois.Password == antes.Password)
+	}
+}
+
+func TestLDAPProvisionaAdminSoPeloGrupo(t *testing.T) {
+	conectarBancoTeste(t)
+	db := database.GetDB()
+	userRepo := repository.NewUserRepository()
+	sufixo := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	casos := []struct {
+		nome        string
+		gruposAdmin []string
+		grupos      []string
+		admin       bool
+	}{
+		{"sem mapeamento de grupos", nil, []string{grupoAdmin}, false},
+		{"fora do grupo de administradores", []string{grupoAdmin}, []string{grupoPolicia}, false},
+		{"no grupo de administradores", []string{grupoAdmin}, []string{grupoPolicia, grupoAdmin}, true},
+	}
+	for i, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			login := "agente-" + strconv.Itoa(i) + "-" + sufixo
+			provedor := novoProvedorLDAPTeste(t, userRepo, c.gruposAdmin, nil,
+				entradaLDAPTeste(login, cpfTeste(int64(i+1)), c.grupos...))
+
+			user, err := provedor.Autenticar(login, "senha123")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Exec(`DELETE FROM usuario WHERE id = $1`, user.ID) })
+
+			if user.Origem != OrigemLDAP || user.Admin != c.admin {
+				t.Fatalf("usuário com origem %q e admin %v, esperado ldap e %v", user.Origem, user.Admin, c.admin)
+			}
+			if user.Lotacao != "Delegacia de Testes" || user.Matricula != "M-"+login {
+				t.Errorf("lotação %q e matrícula %q não vieram do diretório", user.Lotacao, user.Matricula)
+			}
+
+			// O segundo login encontra a mesma conta pelo DN
+			denovo, err := provedor.Autenticar(login, "senha123")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if denovo.ID != user.ID {
+				t.Fatalf("segundo login criou outra conta: %d != %d", denovo.ID, user.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/oidc"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+// duracaoLoginOIDC é o prazo para o usuário voltar do provedor
+const duracaoLoginOIDC = 10 * time.Minute
+
+var (
+	// ErrOIDCDesabilitado indica que o provedor oidc não está em AUTH_PROVIDERS
+	ErrOIDCDesabilitado = errors.New("login único (OIDC) não habilitado")
+	// ErrAcessoNegado indica usuário fora dos grupos permitidos
+	ErrAcessoNegado = errors.New("usuário sem permissão de acesso")
+)
+
+// OIDCService conduz o login único por OpenID Connect e provisiona o usuário
+// com as claims do ID token
+type OIDCService struct {
+	config   config.ConfigOIDC
+	provedor *oidc.Provedor
+	repo     *repository.OIDCRepository
+	userRepo *repository.UserRepository
+	auth     *AuthService
+}
+
+func NewOIDCService(cfg *config.Config) *OIDCService {
+	s := &OIDCService{
+		config:   cfg.OIDC,
+		repo:     repository.NewOIDCRepository(),
+		userRepo: repository.NewUserRepository(),
+		auth:     NewAuthService(cfg),
+	}
+	if cfg.ProvedorHabilitado(config.ProvedorOIDC) {
+		s.provedor = oidc.Novo(oidc.Opcoes{
+			URLDescoberta:  cfg.OIDC.URLDescoberta,
+			ClienteID:      cfg.OIDC.ClienteID,
+			ClienteSegredo: cfg.OIDC.ClienteSegredo,
+			URLRetorno:     cfg.OIDC.URLRetorno,
+			Escopos:        cfg.OIDC.Escopos,
+		})
+	}
+	return s
+}
+
+// Habilitado indica se o login único está configurado
+func (s *OIDCService) Habilitado() bool {
+	return s.provedor != nil
+}
+
+// NomeExibicao é o nome do provedor mostrado no botão de login
+func (s *OIDCService) NomeExibicao() string {
+	return s.config.NomeExibicao
+}
+
+// URLFrontend é a página do frontend que recebe o resultado do login
+func (s *OIDCService) URLFrontend() string {
+	return s.config.URLFrontend
+}
+
+// IniciarLogin registra o login e retorna a URL de autorização e o estado,
+// que o navegador também guarda para o retorno
+func (s *OIDCService) IniciarLogin() (urlAutorizacao, estado string, err error) {
+	if !s.Habilitado() {
+		return "", "", ErrOIDCDesabilitado
+	}
+	autorizacao, err := s.provedor.IniciarAutorizacao()
+	if err != nil {
+		return "", "", err
+	}
+	err = s.repo.CriarLogin(hashToken(autorizacao.Estado), autorizacao.Verificador, autorizacao.Nonce, time.Now().Add(duracaoLoginOIDC))
+	if err != nil {
+		return "", "", err
+	}
+	return autorizacao.URL, autorizacao.Estado, nil
+}
+
+// ConcluirLogin troca o código do retorno pelo ID token, provisiona o usuário
+// e inicia a sessão como no login por senha, inclusive com o segundo fator
+func (s *OIDCService) ConcluirLogin(estado, codigo string, cliente ClienteSessao) (*Tokens, *models.Usuario, error) {
+	if !s.Habilitado() {
+		return nil, nil, ErrOIDCDesabilitado
+	}
+	verificador, nonce, err := s.repo.ConsumirLogin(hashToken(estado))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resposta, err := s.provedor.TrocarCodigo(codigo, verificador)
+	if err != nil {
+		return nil, nil, err
+	}
+	claims, err := s.provedor.ValidarIDToken(resposta.IDToken, nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Provedores que deixam os dados fora do ID token os entregam no userinfo
+	if claims.Texto(s.config.ClaimCPF) == "" || claims.Texto(s.config.ClaimEmail) == "" {
+		if err := s.completarClaims(claims, resposta.AccessToken); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	grupos := claims.Lista(s.config.ClaimGrupos)
+	if len(s.config.GruposPermitidos) > 0 && !membroDeAlgum(grupos, s.config.GruposPermitidos) {
+		return nil, nil, ErrAcessoNegado
+	}
+
+	user := &models.Usuario{
+		Nome:      claims.Texto(s.config.ClaimNome),
+		Email:     claims.Texto(s.config.ClaimEmail),
+		CPF:       somenteDigitos(claims.Texto(s.config.ClaimCPF)),
+		Lotacao:   claims.Texto(s.config.ClaimLotacao),
+		Matricula: claims.Texto(s.config.ClaimMatricula),
+		Admin:     membroDeAlgum(grupos, s.config.GruposAdmin),
+		Origem:    OrigemOIDC,
+	}
+	sub := claims.Texto("sub")
+	if user.CPF == "" || user.Email == "" {
+		return nil, nil, fmt.Errorf("usuário OIDC %s sem as claims %s e %s", sub, s.config.ClaimCPF, s.config.ClaimEmail)
+	}
+	if user.Nome == "" {
+		user.Nome = user.Email
+	}
+
+	user, err = s.userRepo.ProvisionarExterno(user, claims.Texto("iss")+"|"+sub, len(s.config.GruposAdmin) > 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.auth.concluirLogin(user, cliente)
+}
+
+// completarClaims preenche as claims ausentes com as do userinfo, que só vale
+// se for do mesmo sub (OIDC Core, 5.3.2)
+func (s *OIDCService) completarClaims(claims oidc.Claims, accessToken string) error {
+	informacoes, err := s.provedor.InformacoesUsuario(accessToken)
+	if err != nil {
+		return err
+	}
+	if informacoes.Texto("sub") != claims.Texto("sub") {
+		return errors.New("oidc: sub do userinfo difere do ID token")
+	}
+	for nome, valor := range informacoes {
+		if _, ok := claims[nome]; !ok {
+			claims[nome] = valor
+		}
+	}
+	return nil
+}
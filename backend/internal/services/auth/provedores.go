@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Origens de usuário gravadas em usuario.origem
+const (
+	OrigemLocal = "local"
+	OrigemLDAP  = "ldap"
+	OrigemOIDC  = "oidc"
+)
+
+// ErrCredenciaisInvalidas indica login ou senha recusados pelo provedor; o
+// login segue para o próximo provedor
+var ErrCredenciaisInvalidas = errors.New("email ou senha inválidos")
+
+// ProvedorIdentidade autentica login e senha e devolve o usuário da
+// aplicação, criando-o ou atualizando-o se a identidade for externa
+type ProvedorIdentidade interface {
+	Nome() string
+	Autenticar(login, senha string) (*models.Usuario, error)
+}
+
+// novosProvedores monta os provedores na ordem de AUTH_PROVIDERS, já
+// validada na leitura da configuração. O oidc não autentica senhas e fica no
+// OIDCService.
+func novosProvedores(cfg *config.Config, userRepo *repository.UserRepository) []ProvedorIdentidade {
+	var provedores []ProvedorIdentidade
+	for _, nome := range cfg.ProvedoresAutenticacao {
+		switch nome {
+		case config.ProvedorLocal:
+			provedores = append(provedores, &provedorLocal{userRepo: userRepo})
+		case config.ProvedorLDAP:
+			provedores = append(provedores, novoProvedorLDAP(cfg.LDAP, userRepo))
+		}
+	}
+	return provedores
+}
+
+// provedorLocal confere a senha bcrypt gravada em usuario.password
+type provedorLocal struct {
+	userRepo *repository.UserRepository
+}
+
+func (p *provedorLocal) Nome() string {
+	return config.ProvedorLocal
+}
+
+func (p *provedorLocal) Autenticar(email, senha string) (*models.Usuario, error) {
+	user, err := p.userRepo.FindByEmail(email)
+	if err != nil {
+		return nil, ErrCredenciaisInvalidas
+	}
+	// Usuários de provedores externos não têm senha local
+	if user.Origem != OrigemLocal {
+		return nil, ErrCredenciaisInvalidas
+	}
+
+	// Verificar senha
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(senha)); err != nil {
+		return nil, ErrCredenciaisInvalidas
+	}
+	return user, nil
+}
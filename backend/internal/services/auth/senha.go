@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// tamanhoMaximoSenha é o limite do bcrypt, em bytes
+const tamanhoMaximoSenha = 72
+
+var (
+	// ErrSenhaAtualIncorreta indica que a senha atual informada na troca não confere
+	ErrSenhaAtualIncorreta = errors.New("senha atual incorreta")
+	// ErrSenhaExterna indica conta cuja senha é gerenciada pelo provedor de identidade
+	ErrSenhaExterna = errors.New("a senha desta conta é gerenciada pelo provedor de identidade")
+)
+
+// ErroPoliticaSenha lista as exigências da política que a senha não atende
+type ErroPoliticaSenha struct {
+	Problemas []string
+}
+
+func (e *ErroPoliticaSenha) Error() string {
+	return "A senha deve " + strings.Join(e.Problemas, "; ")
+}
+
+// SenhaExpirada indica que a senha local do usuário passou da validade da
+// política ou que a conta precisa trocá-la no primeiro login
+func SenhaExpirada(cfg *config.Config, user *models.Usuario) bool {
+	if user.Origem != OrigemLocal {
+		return false
+	}
+	return user.TrocaSenhaObrigatoria || cfg.PoliticaSenha.Validade > 0 &&
+		time.Since(user.SenhaAlteradaEm) > cfg.PoliticaSenha.Validade
+}
+
+// SenhaService aplica a política de senhas e conduz a troca e a redefinição
+// da senha local
+type SenhaService struct {
+	config    *config.Config
+	repo      *repository.SenhaRepository
+	userRepo  *repository.UserRepository
+	auditoria *repository.AuditoriaRepository
+	auth      *AuthService
+}
+
+func NewSenhaService(cfg *config.Config) *SenhaService {
+	return &SenhaService{
+		config:    cfg,
+		repo:      repository.NewSenhaRepository(),
+		userRepo:  repository.NewUserRepository(),
+		auditoria: repository.NewAuditoriaRepository(),
+		auth:      NewAuthService(cfg),
+	}
+}
+
+// Validar confere a senha com a política. Para um usuário já cadastrado
+// (ID preenchido), também recusa as últimas senhas usadas.
+func (s *SenhaService) Validar(user *models.Usuario, senha string) error {
+	politica := s.config.PoliticaSenha
+	var problemas []string
+
+	if utf8.RuneCountInString(senha) < politica.TamanhoMinimo {
+		problemas = append(problemas, fmt.Sprintf("ter pelo menos %d caracteres", politica.TamanhoMinimo))
+	}
+	if len(senha) > tamanhoMaximoSenha {
+		problemas = append(problemas, fmt.Sprintf("ter no máximo %d caracteres", tamanhoMaximoSenha))
+	}
+	if classesCaracteres(senha) < politica.ClassesMinimas {
+		problemas = append(problemas, fmt.Sprintf("combinar pelo menos %d tipos de caractere entre minúsculas, maiúsculas, números e símbolos", politica.ClassesMinimas))
+	}
+	if contemDadoPessoal(senha, user) {
+		problemas = append(problemas, "não conter o CPF nem o e-mail")
+	}
+
+	if len(problemas) == 0 && user.ID != 0 {
+		hashes, err := s.repo.UltimasSenhas(user.ID, politica.Historico)
+		if err != nil {
+			return err
+		}
+		for _, hash := range hashes {
+			if bcrypt.CompareHashAndPassword([]byte(hash), []byte(senha)) == nil {
+				problemas = append(problemas, fmt.Sprintf("ser diferente das últimas %d senhas", politica.Historico))
+				break
+			}
+		}
+	}
+
+	if len(problemas) > 0 {
+		return &ErroPoliticaSenha{Problemas: problemas}
+	}
+	return nil
+}
+
+// Alterar troca a senha do próprio usuário, que confirma a atual. A troca
+// encerra as demais sessões; a nova sessão é devolvida.
+func (s *SenhaService) Alterar(idUsuario int, atual, nova string, cliente ClienteSessao) (*Tokens, *models.Usuario, error) {
+	user, err := s.userRepo.FindByID(idUsuario)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.Origem != OrigemLocal {
+		return nil, nil, ErrSenhaExterna
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(atual)) != nil {
+		return nil, nil, ErrSenhaAtualIncorreta
+	}
+	if err := s.Validar(user, nova); err != nil {
+		return nil, nil, err
+	}
+	if err := s.gravar(user.ID, nova); err != nil {
+		return nil, nil, err
+	}
+
+	s.auditar(user.CPF, user.Lotacao, repository.AcaoSenhaAlterada, user, nil)
+
+	// Relê o usuário para a nova data da troca
+	user, err = s.userRepo.FindByID(idUsuario)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.auth.iniciarSessao(user, cliente)
+}
+
+// RegistrarAlteracao audita a senha definida por outro caminho, como a
+// edição do cadastro
+func (s *SenhaService) RegistrarAlteracao(cpfAutor, lotacaoAutor string, user *models.Usuario) {
+	s.auditar(cpfAutor, lotacaoAutor, repository.AcaoSenhaAlterada, user, map[string]interface{}{"edicaoCadastro": true})
+}
+
+// SolicitarRedefinicao envia ao e-mail, se ele for de uma conta local ativa,
+// o link de redefinição da senha. Para não revelar quais e-mails existem, o
+// resultado não depende disso.
+func (s *SenhaService) SolicitarRedefinicao(email, ip string) error {
+	user, err := s.userRepo.FindByEmail(strings.TrimSpace(email))
+	if err != nil || user.Origem != OrigemLocal || user.Situacao != repository.SituacaoAtivo {
+		return nil
+	}
+
+	token := tokenAleatorio(32)
+	expira := time.Now().Add(s.config.PrazoRedefinicaoSenha)
+	if err := s.repo.CriarRedefinicao(hashToken(token), user.ID, expira); err != nil {
+		return err
+	}
+
+	s.auditar(user.CPF, user.Lotacao, repository.AcaoRedefinicaoSenhaSolicitada, user, map[string]interface{}{"ip": ip})
+
+	// O token vai no fragmento, que o navegador não envia ao servidor nem aos logs
+	link := s.config.URLPublica + "/redefinir-senha#token=" + token
+	go enviarEmail(s.config, user, "Redefinição de senha", fmt.Sprintf(
+		"Recebemos um pedido para redefinir a sua senha no ConsultaPix. Para cadastrar uma nova senha, acesse:\r\n\r\n%s\r\n\r\n"+
+			"O link vale até %s e só pode ser usado uma vez. Se você não fez o pedido, ignore esta mensagem.",
+		link, expira.Format("02/01/2006 15:04")), link)
+	return nil
+}
+
+// Redefinir cadastra a nova senha com o link recebido por e-mail. O link só é
+// consumido quando a senha é aceita; a redefinição encerra todas as sessões
+// e libera a conta bloqueada por tentativas.
+func (s *SenhaService) Redefinir(token, nova, ip string) error {
+	hash := hashToken(token)
+	idUsuario, err := s.repo.ConsultarRedefinicao(hash)
+	if err != nil {
+		return err
+	}
+	user, err := s.userRepo.FindByID(idUsuario)
+	if err != nil {
+		return repository.ErrRedefinicaoInvalida
+	}
+	if err := s.Validar(user, nova); err != nil {
+		return err
+	}
+
+	if _, err := s.repo.ConsumirRedefinicao(hash); err != nil {
+		return err
+	}
+	if err := s.gravar(user.ID, nova); err != nil {
+		return err
+	}
+	s.auth.bloqueio.limpar(user.Email)
+
+	s.auditar(user.CPF, user.Lotacao, repository.AcaoSenhaRedefinida, user, map[string]interface{}{"ip": ip})
+	return nil
+}
+
+// gravar guarda o hash bcrypt da nova senha
+func (s *SenhaService) gravar(idUsuario int, senha string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(senha), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return s.repo.AlterarSenha(idUsuario, string(hash))
+}
+
+func (s *SenhaService) auditar(cpf, lotacao, acao string, user *models.Usuario, detalhes map[string]interface{}) {
+	if detalhes == nil {
+		detalhes = map[string]interface{}{}
+	}
+	detalhes["idUsuario"] = user.ID
+	if err := s.auditoria.Registrar(&models.EventoAuditoria{
+		CPFUsuario: cpf,
+		Lotacao:    lotacao,
+		Acao:       acao,
+		Alvo:       user.CPF,
+		Detalhes:   detalhes,
+	}); err != nil {
+		log.Printf("Erro ao auditar %s de %s: %v", acao, user.CPF, err)
+	}
+}
+
+// classesCaracteres conta quantas classes (minúsculas, maiúsculas, dígitos e
+// símbolos) aparecem na senha
+func classesCaracteres(senha string) int {
+	var minuscula, maiuscula, digito, simbolo bool
+	for _, c := range senha {
+		switch {
+		case unicode.IsLower(c):
+			minuscula = true
+		case unicode.IsUpper(c):
+			maiuscula = true
+		case unicode.IsDigit(c):
+			digito = true
+		default:
+			simbolo = true
+		}
+	}
+	n := 0
+	for _, presente := range []bool{minuscula, maiuscula, digito, simbolo} {
+		if presente {
+			n++
+		}
+	}
+	return n
+}
+
+// contemDadoPessoal indica senha que contém o CPF ou o nome do e-mail do usuário
+func contemDadoPessoal(senha string, user *models.Usuario) bool {
+	senha = strings.ToLower(senha)
+	if cpf := somenteDigitos(user.CPF); len(cpf) >= 11 && strings.Contains(somenteDigitos(senha), cpf) {
+		return true
+	}
+	nome, _, _ := strings.Cut(strings.ToLower(user.Email), "@")
+	return len(nome) >= 4 && strings.Contains(senha, nome)
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+func TestSenhaExpirada(t *testing.T) {
+	recente, antiga := time.Now().Add(-time.Hour), time.Now().Add(-100*24*time.Hour)
+
+	casos := []struct {
+		nome     string
+		validade time.Duration
+		user     models.Usuario
+		expirada bool
+	}{
+		{"sem validade", 0, models.Usuario{Origem: OrigemLocal, SenhaAlteradaEm: antiga}, false},
+		{"dentro da validade", 90 * 24 * time.Hour, models.Usuario{Origem: OrigemLocal, SenhaAlteradaEm: recente}, false},
+		{"fora da validade", 90 * 24 * time.Hour, models.Usuario{Origem: OrigemLocal, SenhaAlteradaEm: antiga}, true},
+		{"troca obrigatória sem validade", 0, models.Usuario{Origem: OrigemLocal, SenhaAlteradaEm: recente, TrocaSenhaObrigatoria: true}, true},
+		{"conta externa", 90 * 24 * time.Hour, models.Usuario{Origem: OrigemLDAP, SenhaAlteradaEm: antiga, TrocaSenhaObrigatoria: true}, false},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			cfg := &config.Config{PoliticaSenha: config.PoliticaSenha{Validade: c.validade}}
+			if got := SenhaExpirada(cfg, &c.user); got != c.expirada {
+				t.Fatalf("SenhaExpirada = %v, esperado %v", got, c.expirada)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+// intervaloAtividade evita gravar a última atividade a cada requisição
+const intervaloAtividade = time.Minute
+
+// ErrSessaoInativa indica sessão encerrada por ficar sem uso além do limite
+var ErrSessaoInativa = errors.New("sessão encerrada por inatividade")
+
+// ClienteSessao identifica o dispositivo que abre a sessão
+type ClienteSessao struct {
+	IP            string
+	AgenteUsuario string
+}
+
+// RegistrarAtividade confere se a sessão do token continua aberta e marca o
+// uso. A sessão parada além do limite de inatividade é encerrada aqui.
+func (s *AuthService) RegistrarAtividade(claims *JWTClaims) error {
+	sessao, err := s.sessaoRepo.Buscar(claims.Sessao, claims.ID)
+	if err == repository.ErrSessaoNaoEncontrada {
+		return ErrSessaoInvalida
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.conferirSessao(sessao); err != nil {
+		return err
+	}
+	if time.Since(sessao.UltimaAtividade) < intervaloAtividade {
+		return nil
+	}
+	return s.sessaoRepo.RegistrarAtividade(sessao.ID)
+}
+
+// conferirSessao recusa a sessão encerrada e encerra a que passou do limite
+// de inatividade
+func (s *AuthService) conferirSessao(sessao *models.Sessao) error {
+	if sessao.Encerrada {
+		return ErrSessaoInvalida
+	}
+	if s.config.InatividadeSessao > 0 && time.Since(sessao.UltimaAtividade) > s.config.InatividadeSessao {
+		if err := s.sessaoRepo.Encerrar(sessao.ID, sessao.IDUsuario, repository.MotivoInatividade); err != nil && err != repository.ErrSessaoNaoEncontrada {
+			return err
+		}
+		return ErrSessaoInativa
+	}
+	return nil
+}
+
+// SessaoService lista e encerra as sessões abertas, pelo próprio usuário ou
+// por um administrador
+type SessaoService struct {
+	config    *config.Config
+	repo      *repository.SessaoRepository
+	auditoria *repository.AuditoriaRepository
+	auth      *AuthService
+}
+
+func NewSessaoService(cfg *config.Config) *SessaoService {
+	return &SessaoService{
+		config:    cfg,
+		repo:      repository.NewSessaoRepository(),
+		auditoria: repository.NewAuditoriaRepository(),
+		auth:      NewAuthService(cfg),
+	}
+}
+
+// Listar retorna as sessões ativas do usuário, marcando a da requisição atual
+func (s *SessaoService) Listar(idUsuario int, atual int64) ([]models.Sessao, error) {
+	sessoes, err := s.repo.ListarAtivas(idUsuario, s.config.InatividadeSessao)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessoes {
+		sessoes[i].Atual = sessoes[i].ID == atual
+	}
+	return sessoes, nil
+}
+
+// Revogar encerra uma sessão do próprio usuário
+func (s *SessaoService) Revogar(idUsuario int, id int64) error {
+	return s.repo.Encerrar(id, idUsuario, repository.MotivoRevogada)
+}
+
+// EncerrarPorAdmin encerra uma sessão de qualquer usuário, com auditoria
+func (s *SessaoService) EncerrarPorAdmin(cpfAdmin, lotacaoAdmin string, user *models.Usuario, id int64) error {
+	if err := s.repo.Encerrar(id, user.ID, repository.MotivoAdministrador); err != nil {
+		return err
+	}
+	s.auditar(cpfAdmin, lotacaoAdmin, user, map[string]interface{}{"sessao": id})
+	return nil
+}
+
+// EncerrarTodasPorAdmin encerra todas as sessões de um usuário, com auditoria
+func (s *SessaoService) EncerrarTodasPorAdmin(cpfAdmin, lotacaoAdmin string, user *models.Usuario) error {
+	if err := s.auth.EncerrarSessoes(user.ID); err != nil {
+		return err
+	}
+	s.auditar(cpfAdmin, lotacaoAdmin, user, map[string]interface{}{"todas": true})
+	return nil
+}
+
+func (s *SessaoService) auditar(cpfAdmin, lotacaoAdmin string, user *models.Usuario, detalhes map[string]interface{}) {
+	detalhes["idUsuario"] = user.ID
+	if err := s.auditoria.Registrar(&models.EventoAuditoria{
+		CPFUsuario: cpfAdmin,
+		Lotacao:    lotacaoAdmin,
+		Acao:       repository.AcaoSessaoEncerrada,
+		Alvo:       user.CPF,
+		Detalhes:   detalhes,
+	}); err != nil {
+		log.Printf("Erro ao auditar o encerramento de sessão de %s: %v", user.CPF, err)
+	}
+}
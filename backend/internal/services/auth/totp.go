@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Parâmetros do TOTP (RFC 6238), os padrões aceitos pelos aplicativos autenticadores
+const (
+	periodoTOTP      = 30
+	digitosTOTP      = 6
+	tamanhoSegredo   = 20
+	emissorTOTP      = "ConsultaPix"
+	toleranciaPassos = 1 // aceita o passo anterior e o seguinte, pela diferença de relógio
+)
+
+var base32SemPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// novoSegredoTOTP gera um segredo aleatório em base32
+func novoSegredoTOTP() string {
+	b := make([]byte, tamanhoSegredo)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base32SemPadding.EncodeToString(b)
+}
+
+// URIProvisionamento é o conteúdo do QR code lido pelo aplicativo autenticador
+func URIProvisionamento(segredo, conta string) string {
+	rotulo := url.PathEscape(emissorTOTP + ":" + conta)
+	parametros := url.Values{}
+	parametros.Set("secret", segredo)
+	parametros.Set("issuer", emissorTOTP)
+	parametros.Set("algorithm", "SHA1")
+	parametros.Set("digits", fmt.Sprint(digitosTOTP))
+	parametros.Set("period", fmt.Sprint(periodoTOTP))
+	return "otpauth://totp/" + rotulo + "?" + parametros.Encode()
+}
+
+// codigoTOTP calcula o código do passo de tempo (HOTP da RFC 4226)
+func codigoTOTP(chave []byte, passo int64) string {
+	var contador [8]byte
+	binary.BigEndian.PutUint64(contador[:], uint64(passo))
+	mac := hmac.New(sha1.New, chave)
+	mac.Write(contador[:])
+	soma := mac.Sum(nil)
+
+	deslocamento := soma[len(soma)-1] & 0x0f
+	valor := binary.BigEndian.Uint32(soma[deslocamento:deslocamento+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digitosTOTP, valor%1000000)
+}
+
+// verificarTOTP retorna o passo em que o código confere, dentro da tolerância
+func verificarTOTP(segredo, codigo string, agora time.Time) (int64, bool) {
+	chave, err := base32SemPadding.DecodeString(strings.ToUpper(segredo))
+	if err != nil || len(codigo) != digitosTOTP {
+		return 0, false
+	}
+
+	atual := agora.Unix() / periodoTOTP
+	for passo := atual - toleranciaPassos; passo <= atual+toleranciaPassos; passo++ {
+		if hmac.Equal([]byte(codigoTOTP(chave, passo)), []byte(codigo)) {
+			return passo, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"crypto/rand"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+// segredoRFC6238 é o segredo SHA1 dos vetores do apêndice B da RFC 6238,
+// "12345678901234567890" em base32
+const segredoRFC6238 = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodigoTOTPRFC4226(t *testing.T) {
+	// Apêndice D da RFC 4226: HOTP com os contadores 0 a 9
+	esperados := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for contador, espera := range esperados {
+		if got := codigoTOTP([]byte("12345678901234567890"), int64(contador)); got != espera {
+			t.Errorf("contador %d: código %s, esperado %s", contador, got, espera)
+		}
+	}
+}
+
+func TestVerificarTOTPRFC6238(t *testing.T) {
+	// Apêndice B da RFC 6238, com os seis últimos dígitos dos códigos de oito
+	casos := []struct {
+		unix   int64
+		codigo string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, c := range casos {
+		t.Run(strconv.FormatInt(c.unix, 10), func(t *testing.T) {
+			agora := time.Unix(c.unix, 0)
+			passo, ok := verificarTOTP(segredoRFC6238, c.codigo, agora)
+			if !ok {
+				t.Fatalf("código %s recusado", c.codigo)
+			}
+			if passo != c.unix/periodoTOTP {
+				t.Fatalf("passo %d, esperado %d", passo, c.unix/periodoTOTP)
+			}
+			// Segredo em minúsculas, como alguns aplicativos exibem
+			if _, ok := verificarTOTP(strings.ToLower(segredoRFC6238), c.codigo, agora); !ok {
+				t.Fatal("segredo em minúsculas recusado")
+			}
+		})
+	}
+}
+
+func TestVerificarTOTPTolerancia(t *testing.T) {
+	chave := []byte("12345678901234567890")
+	agora := time.Unix(1234567890, 0)
+	atual := agora.Unix() / periodoTOTP
+
+	casos := []struct {
+		nome   string
+		codigo string
+		aceito bool
+	}{
+		{"passo atual", codigoTOTP(chave, atual), true},
+		{"passo anterior", codigoTOTP(chave, atual-1), true},
+		{"passo seguinte", codigoTOTP(chave, atual+1), true},
+		{"dois passos atrás", codigoTOTP(chave, atual-2), false},
+		{"dois passos à frente", codigoTOTP(chave, atual+2), false},
+		{"curto", codigoTOTP(chave, atual)[:5], false},
+		{"vazio", "", false},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			if _, ok := verificarTOTP(segredoRFC6238, c.codigo, agora); ok != c.aceito {
+				t.Fatalf("verificarTOTP = %v, esperado %v", ok, c.aceito)
+			}
+		})
+	}
+
+	if _, ok := verificarTOTP("segredo inválido!", codigoTOTP(chave, atual), agora); ok {
+		t.Fatal("segredo fora do base32 aceito")
+	}
+}
+
+func TestDoisFatoresRecusaCodigoRepetido(t *testing.T) {
+	conectarBancoTeste(t)
+	db := database.GetDB()
+
+	chave := make([]byte, 32)
+	if _, err := rand.Read(chave); err != nil {
+		t.Fatal(err)
+	}
+	servico := NewDoisFatoresService(&config.Config{ChaveDoisFatores: chave})
+
+	sufixo := strconv.FormatInt(time.Now().UnixNano(), 10)
+	id, err := repository.NewUserRepository().Create(&models.Usuario{
+		Nome: "Usuário TOTP", CPF: cpfTeste(0), Email: "totp-" + sufixo + "@exemplo.local",
+		Password: "Senha-Local-123", Matricula: "T-" + sufixo,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM usuario WHERE id = $1`, id) })
+	user := &models.Usuario{ID: id}
+
+	segredo, _, err := servico.Cadastrar(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chaveTOTP, err := base32SemPadding.DecodeString(segredo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	atual := time.Now().Unix() / periodoTOTP
+
+	// O código do passo seguinte ativa o cadastro e não vale de novo
+	if _, err := servico.Ativar(user, codigoTOTP(chaveTOTP, atual+1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := servico.Verificar(user, codigoTOTP(chaveTOTP, atual+1)); err != ErrCodigoInvalido {
+		t.Fatalf("código repetido: Verificar = %v, esperado ErrCodigoInvalido", err)
+	}
+	// Nem um código anterior ao já usado, ainda dentro da tolerância
+	if err := servico.Verificar(user, codigoTOTP(chaveTOTP, atual)); err != ErrCodigoInvalido {
+		t.Fatalf("código anterior ao usado: Verificar = %v, esperado ErrCodigoInvalido", err)
+	}
+}
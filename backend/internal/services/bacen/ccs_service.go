@@ -2,7 +2,6 @@ package bacen
 
 import (
 	_"bytes"
-	"encoding/base64"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
@@ -14,12 +13,15 @@ import (
 	"github.com/tassyosilva/consultapix/internal/config"
 	"github.com/tassyosilva/consultapix/internal/database/models"
 	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/notificacao"
 )
 
 type CCSService struct {
-	config   *config.Config
-	ccsRepo  *repository.CCSRepository
-	client   *http.Client
+	config       *config.Config
+	ccsRepo      *repository.CCSRepository
+	credenciais  *CredenciaisBacenService
+	client       *clienteBacen
+	notificacoes *notificacao.NotificacaoService
 }
 
 // Estruturas para trabalhar com XML do BACEN
@@ -98,22 +100,27 @@ type BemDireitoValorsXML struct {
 
 func NewCCSService(cfg *config.Config) *CCSService {
 	return &CCSService{
-		config:  cfg,
-		ccsRepo: repository.NewCCSRepository(),
-		client:  &http.Client{Timeout: 30 * time.Second},
+		config:       cfg,
+		ccsRepo:      repository.NewCCSRepository(),
+		credenciais:  NewCredenciaisBacenService(cfg),
+		client:       novoClienteBacen(cfg),
+		notificacoes: notificacao.NewNotificacaoService(cfg),
 	}
 }
 
-// getBasicAuth retorna o cabeçalho de autenticação básica
-func (s *CCSService) getBasicAuth() string {
-	credentials := fmt.Sprintf("%s:%s", s.config.BacenUsername, s.config.BacenPassword)
-	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
-}
-
 // ConsultarRelacionamento consulta relacionamentos CCS de um CPF/CNPJ
-func (s *CCSService) ConsultarRelacionamento(cpfCnpj, dataInicio, dataFim, numProcesso, motivo string, cpfResponsavel, lotacao, caso string) ([]models.RequisicaoRelacionamentoCCS, error) {
+func (s *CCSService) ConsultarRelacionamento(cpfCnpj, dataInicio, dataFim, numProcesso, motivo string, responsavel Responsavel, caso string) ([]models.RequisicaoRelacionamentoCCS, error) {
+	// Credencial do BACEN da lotação do usuário
+	credencial, err := s.credenciais.ParaLotacao(responsavel.Lotacao)
+	if err != nil {
+		return nil, err
+	}
+
+	dataInicioConsulta := dataBacen(dataInicio)
+	dataFimConsulta := dataBacen(dataFim)
+
 	url := fmt.Sprintf("https://www3.bcb.gov.br/bc_ccs/rest/requisitar-relacionamentos?id-cliente=%s&data-inicio=%s&data-fim=%s&numero-processo=%s&motivo=%s", 
-		cpfCnpj, dataInicio, dataFim, numProcesso, motivo)
+		cpfCnpj, normalizarDataParametro(dataInicio), normalizarDataParametro(dataFim), numProcesso, motivo)
 	
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -121,26 +128,29 @@ func (s *CCSService) ConsultarRelacionamento(cpfCnpj, dataInicio, dataFim, numPr
 	}
 	
 	req.Header.Add("Accept", "*/*")
-	req.Header.Add("Authorization", s.getBasicAuth())
+	req.Header.Add("Authorization", credencial.basicAuth())
 	
 	resp, err := s.client.Do(req)
 	if err != nil {
 		// Registrar falha na requisição
 		requisicao := &models.RequisicaoRelacionamentoCCS{
-			DataRequisicao:     time.Now().Format(time.RFC3339),
-			DataInicioConsulta: dataInicio,
-			DataFimConsulta:    dataFim,
+			DataRequisicao:     time.Now(),
+			DataInicioConsulta: dataInicioConsulta,
+			DataFimConsulta:    dataFimConsulta,
 			CPFCNPJConsulta:    cpfCnpj,
 			NumeroProcesso:     numProcesso,
 			MotivoBusca:        motivo,
-			CPFResponsavel:     cpfResponsavel,
-			Lotacao:            lotacao,
+			CPFResponsavel:     responsavel.CPF,
+			Lotacao:            responsavel.Lotacao,
+			IDUnidade:          responsavel.idUnidade(),
 			Caso:               caso,
 			NumeroRequisicao:   "",
 			CPFCNPJ:            "",
 			TipoPessoa:         "",
 			Nome:               "",
 			Autorizado:         true,
+			IDCredencialBacen:  credencial.ID,
+			UsuarioBacen:       credencial.Usuario,
 			Status:             "Falha",
 		}
 		
@@ -171,20 +181,23 @@ func (s *CCSService) ConsultarRelacionamento(cpfCnpj, dataInicio, dataFim, numPr
 	if len(requisicaoXML.Clientes.Clientes) == 0 {
 		// Registrar requisição sem relacionamentos
 		requisicao := &models.RequisicaoRelacionamentoCCS{
-			DataRequisicao:     requisicaoXML.DataMovimento,
-			DataInicioConsulta: dataInicio,
-			DataFimConsulta:    dataFim,
+			DataRequisicao:     dataOuAgora(requisicaoXML.DataMovimento),
+			DataInicioConsulta: dataInicioConsulta,
+			DataFimConsulta:    dataFimConsulta,
 			CPFCNPJConsulta:    cpfCnpj,
 			NumeroProcesso:     requisicaoXML.NumeroProcesso,
 			MotivoBusca:        requisicaoXML.Motivo,
-			CPFResponsavel:     cpfResponsavel,
-			Lotacao:            lotacao,
+			CPFResponsavel:     responsavel.CPF,
+			Lotacao:            responsavel.Lotacao,
+			IDUnidade:          responsavel.idUnidade(),
 			Caso:               caso,
 			NumeroRequisicao:   requisicaoXML.NumeroRequisicao,
 			CPFCNPJ:            "",
 			TipoPessoa:         "",
 			Nome:               "",
 			Autorizado:         true,
+			IDCredencialBacen:  credencial.ID,
+			UsuarioBacen:       credencial.Usuario,
 			Status:             "Sucesso",
 		}
 		
@@ -207,20 +220,23 @@ func (s *CCSService) ConsultarRelacionamento(cpfCnpj, dataInicio, dataFim, numPr
 	if len(cliente.Relacionamentos.Relacionamentos) == 0 {
 		// Registrar requisição sem relacionamentos
 		requisicao := &models.RequisicaoRelacionamentoCCS{
-			DataRequisicao:     requisicaoXML.DataMovimento,
-			DataInicioConsulta: dataInicio,
-			DataFimConsulta:    dataFim,
+			DataRequisicao:     dataOuAgora(requisicaoXML.DataMovimento),
+			DataInicioConsulta: dataInicioConsulta,
+			DataFimConsulta:    dataFimConsulta,
 			CPFCNPJConsulta:    cpfCnpj,
 			NumeroProcesso:     requisicaoXML.NumeroProcesso,
 			MotivoBusca:        requisicaoXML.Motivo,
-			CPFResponsavel:     cpfResponsavel,
-			Lotacao:            lotacao,
+			CPFResponsavel:     responsavel.CPF,
+			Lotacao:            responsavel.Lotacao,
+			IDUnidade:          responsavel.idUnidade(),
 			Caso:               caso,
 			NumeroRequisicao:   requisicaoXML.NumeroRequisicao,
 			CPFCNPJ:            cliente.ID,
 			TipoPessoa:         cliente.TipoPessoa,
 			Nome:               cliente.Nome,
 			Autorizado:         true,
+			IDCredencialBacen:  credencial.ID,
+			UsuarioBacen:       credencial.Usuario,
 			Status:             "Sucesso",
 		}
 		
@@ -265,10 +281,10 @@ func (s *CCSService) ConsultarRelacionamento(cpfCnpj, dataInicio, dataFim, numPr
 		}
 		
 		// Obter datas de início e fim do relacionamento
-		var dataInicioRel, dataFimRel string
+		var dataInicioRel, dataFimRel *time.Time
 		if len(relXML.Periodos.Periodos) > 0 {
-			dataInicioRel = relXML.Periodos.Periodos[0].DataInicio
-			dataFimRel = relXML.Periodos.Periodos[0].DataFim
+			dataInicioRel = dataBacen(relXML.Periodos.Periodos[0].DataInicio)
+			dataFimRel = dataBacen(relXML.Periodos.Periodos[0].DataFim)
 		}
 		
 		// Criar modelo de relacionamento
@@ -293,14 +309,15 @@ func (s *CCSService) ConsultarRelacionamento(cpfCnpj, dataInicio, dataFim, numPr
 	
 	// Criar requisição para salvar no banco
 	requisicao := &models.RequisicaoRelacionamentoCCS{
-		DataRequisicao:     requisicaoXML.DataMovimento,
-		DataInicioConsulta: dataInicio,
-		DataFimConsulta:    dataFim,
+		DataRequisicao:     dataOuAgora(requisicaoXML.DataMovimento),
+		DataInicioConsulta: dataInicioConsulta,
+		DataFimConsulta:    dataFimConsulta,
 		CPFCNPJConsulta:    cpfCnpj,
 		NumeroProcesso:     requisicaoXML.NumeroProcesso,
 		MotivoBusca:        requisicaoXML.Motivo,
-		CPFResponsavel:     cpfResponsavel,
-		Lotacao:            lotacao,
+		CPFResponsavel:     responsavel.CPF,
+		Lotacao:            responsavel.Lotacao,
+		IDUnidade:          responsavel.idUnidade(),
 		Caso:               caso,
 		NumeroRequisicao:   requisicaoXML.NumeroRequisicao,
 		CPFCNPJ:            cliente.ID,
@@ -308,30 +325,24 @@ func (s *CCSService) ConsultarRelacionamento(cpfCnpj, dataInicio, dataFim, numPr
 		Nome:               cliente.Nome,
 		RelacionamentosCCS: relacionamentos,
 		Autorizado:         true,
+		IDCredencialBacen:  credencial.ID,
+		UsuarioBacen:       credencial.Usuario,
 		Status:             "Sucesso",
 	}
 	
 	// Salvar requisição
-	_, err = s.ccsRepo.CriarRequisicaoRelacionamentoCCS(requisicao)
+	id, err := s.ccsRepo.CriarRequisicaoRelacionamentoCCS(requisicao)
 	if err != nil {
 		return nil, err
 	}
 	
 	// Buscar a requisição salva com todos os relacionamentos
-	reqSalva, err := s.ccsRepo.BuscarRequisicoesRelacionamentoCCS(cpfResponsavel)
+	reqSalva, err := s.ccsRepo.BuscarRequisicaoCCSPorID(repository.Escopo{CPF: responsavel.CPF}, id)
 	if err != nil {
 		return nil, err
 	}
 	
-	// Retornar apenas a requisição recém-criada (a primeira)
-	if len(reqSalva) > 0 {
-		return []models.RequisicaoRelacionamentoCCS{reqSalva[0]}, nil
-	}
-	
-	return []models.RequisicaoRelacionamentoCCS{{
-		CPFCNPJConsulta: cpfCnpj,
-		Status:          "Sucesso",
-	}}, nil
+	return []models.RequisicaoRelacionamentoCCS{*reqSalva}, nil
 }
 
 // SolicitarDetalhamento solicita detalhamento de um relacionamento CCS
@@ -345,7 +356,7 @@ func (s *CCSService) SolicitarDetalhamento(numeroRequisicao, cpfCnpj, cnpjRespon
 	
 	// Se fora do horário, colocar na fila
 	if now.Before(early) || now.After(late) || !isWeekday {
-		err := s.ccsRepo.AtualizarStatusDetalhamentoCCS(idRelacionamento, "Na fila", false, false, "", "", "", "")
+		err := s.ccsRepo.AtualizarStatusDetalhamentoCCS(idRelacionamento, "Na fila", false, false, nil, "", "", "")
 		if err != nil {
 			return nil, err
 		}
@@ -359,9 +370,19 @@ func (s *CCSService) SolicitarDetalhamento(numeroRequisicao, cpfCnpj, cnpjRespon
 		}, nil
 	}
 	
+	// O detalhamento usa a mesma credencial da requisição de relacionamento
+	idCredencial, err := s.ccsRepo.CredencialDoRelacionamento(idRelacionamento)
+	if err != nil {
+		return nil, err
+	}
+	credencial, err := s.credenciais.PorID(idCredencial)
+	if err != nil {
+		return nil, err
+	}
+	
 	// Fazer requisição de detalhamento
 	url := fmt.Sprintf("https://www3.bcb.gov.br/bc_ccs/rest/requisitar-detalhamentos?numeros-requisicoes=%s&ids-pessoa=%s&cnpj-responsaveis=%s&cnpj-participantes=%s&datas-inicio=%s",
-		numeroRequisicao, cpfCnpj, cnpjResponsavel, cnpjParticipante, dataInicioRelacionamento)
+		numeroRequisicao, cpfCnpj, cnpjResponsavel, cnpjParticipante, normalizarDataParametro(dataInicioRelacionamento))
 	
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -369,7 +390,7 @@ func (s *CCSService) SolicitarDetalhamento(numeroRequisicao, cpfCnpj, cnpjRespon
 	}
 	
 	req.Header.Add("Accept", "*/*")
-	req.Header.Add("Authorization", s.getBasicAuth())
+	req.Header.Add("Authorization", credencial.basicAuth())
 	
 	resp, err := s.client.Do(req)
 	if err != nil {
@@ -391,7 +412,7 @@ func (s *CCSService) SolicitarDetalhamento(numeroRequisicao, cpfCnpj, cnpjRespon
 			"IF não detalha",
 			false,
 			true,
-			time.Now().Format(time.RFC3339),
+			agora(),
 			"",
 			"",
 			"",
@@ -400,6 +421,10 @@ func (s *CCSService) SolicitarDetalhamento(numeroRequisicao, cpfCnpj, cnpjRespon
 			return nil, err
 		}
 		
+		if cpfResponsavel, err := s.ccsRepo.ResponsavelDoRelacionamento(idRelacionamento); err == nil {
+			s.notificarDetalhamento(cpfResponsavel, repository.NotificacaoDetalhamentoRecusado, numeroRequisicao, cpfCnpj, idRelacionamento, nomeBancoResponsavel)
+		}
+		
 		return []map[string]string{
 			{
 				"banco":  nomeBancoResponsavel,
@@ -417,11 +442,12 @@ func (s *CCSService) SolicitarDetalhamento(numeroRequisicao, cpfCnpj, cnpjRespon
 	}
 	
 	// Obter data de requisição do detalhamento
-	var dataRequisicaoDetalhamento string
+	var dataRequisicaoDetalhamento *time.Time
 	if len(requisicaoDetalhamentosXML.RequisicaoDetalhamento) > 0 {
-		dataRequisicaoDetalhamento = requisicaoDetalhamentosXML.RequisicaoDetalhamento[0].DataHoraRequisicao
-	} else {
-		dataRequisicaoDetalhamento = time.Now().Format(time.RFC3339)
+		dataRequisicaoDetalhamento = dataBacen(requisicaoDetalhamentosXML.RequisicaoDetalhamento[0].DataHoraRequisicao)
+	}
+	if dataRequisicaoDetalhamento == nil {
+		dataRequisicaoDetalhamento = agora()
 	}
 	
 	// Atualizar status do relacionamento
@@ -477,10 +503,16 @@ func (s *CCSService) ProcessarFilaCCS() ([]map[string]string, error) {
 	
 	// Processar cada requisição
 	for _, req := range requisicoes {
+		// Cada requisição usa a credencial com que foi feita
+		credencial, err := s.credenciais.PorID(req.IDCredencialBacen)
+		if err != nil {
+			continue
+		}
+		
 		for _, relacionamento := range req.RelacionamentosCCS {
 			// Fazer requisição de detalhamento
 			url := fmt.Sprintf("https://www3.bcb.gov.br/bc_ccs/rest/requisitar-detalhamentos?numeros-requisicoes=%s&ids-pessoa=%s&cnpj-responsaveis=%s&cnpj-participantes=%s&datas-inicio=%s",
-				req.NumeroRequisicao, req.CPFCNPJ, relacionamento.CNPJResponsavel, relacionamento.CNPJParticipante, relacionamento.DataInicioRelacionamento)
+				req.NumeroRequisicao, req.CPFCNPJ, relacionamento.CNPJResponsavel, relacionamento.CNPJParticipante, FormatarDataBacen(relacionamento.DataInicioRelacionamento))
 			
 			httpReq, err := http.NewRequest("GET", url, nil)
 			if err != nil {
@@ -488,7 +520,7 @@ func (s *CCSService) ProcessarFilaCCS() ([]map[string]string, error) {
 			}
 			
 			httpReq.Header.Add("Accept", "*/*")
-			httpReq.Header.Add("Authorization", s.getBasicAuth())
+			httpReq.Header.Add("Authorization", credencial.basicAuth())
 			
 			resp, err := s.client.Do(httpReq)
 			if err != nil {
@@ -511,12 +543,13 @@ func (s *CCSService) ProcessarFilaCCS() ([]map[string]string, error) {
 					"IF não detalha",
 					false,
 					true,
-					time.Now().Format(time.RFC3339),
+					agora(),
 					"",
 					"",
 					"",
 				)
 				if err == nil {
+					s.notificarDetalhamento(req.CPFResponsavel, repository.NotificacaoDetalhamentoRecusado, req.NumeroRequisicao, req.CPFCNPJ, relacionamento.ID, relacionamento.NomeBancoResponsavel)
 					resultados = append(resultados, map[string]string{
 						"banco":  relacionamento.NomeBancoResponsavel,
 						"msg":    "Sem detalhamento",
@@ -534,11 +567,12 @@ func (s *CCSService) ProcessarFilaCCS() ([]map[string]string, error) {
 			}
 			
 			// Obter data de requisição do detalhamento
-			var dataRequisicaoDetalhamento string
+			var dataRequisicaoDetalhamento *time.Time
 			if len(requisicaoDetalhamentosXML.RequisicaoDetalhamento) > 0 {
-				dataRequisicaoDetalhamento = requisicaoDetalhamentosXML.RequisicaoDetalhamento[0].DataHoraRequisicao
-			} else {
-				dataRequisicaoDetalhamento = time.Now().Format(time.RFC3339)
+				dataRequisicaoDetalhamento = dataBacen(requisicaoDetalhamentosXML.RequisicaoDetalhamento[0].DataHoraRequisicao)
+			}
+			if dataRequisicaoDetalhamento == nil {
+				dataRequisicaoDetalhamento = agora()
 			}
 			
 			// Atualizar status do relacionamento
@@ -575,6 +609,12 @@ func (s *CCSService) ReceberBDVCCS() error {
 	
 	// Processar cada requisição
 	for _, req := range requisicoes {
+		// Cada requisição usa a credencial com que foi feita
+		credencial, err := s.credenciais.PorID(req.IDCredencialBacen)
+		if err != nil {
+			continue
+		}
+		
 		for _, relacionamento := range req.RelacionamentosCCS {
 			// Fazer requisição para obter respostas de detalhamento
 			url := fmt.Sprintf("https://www3.bcb.gov.br/bc_ccs/rest/obter-respostas-detalhamento?numero-requisicao=%s&id-pessoa=%s&cnpj-responsavel=%s&cnpj-participante=%s",
@@ -586,7 +626,7 @@ func (s *CCSService) ReceberBDVCCS() error {
 			}
 			
 			httpReq.Header.Add("Accept", "*/*")
-			httpReq.Header.Add("Authorization", s.getBasicAuth())
+			httpReq.Header.Add("Authorization", credencial.basicAuth())
 			
 			resp, err := s.client.Do(httpReq)
 			if err != nil {
@@ -650,7 +690,7 @@ func (s *CCSService) ReceberBDVCCS() error {
 					}
 					
 					httpReqBDV.Header.Add("Accept", "*/*")
-					httpReqBDV.Header.Add("Authorization", s.getBasicAuth())
+					httpReqBDV.Header.Add("Authorization", credencial.basicAuth())
 					
 					respBDV, err := s.client.Do(httpReqBDV)
 					if err != nil {
@@ -673,6 +713,7 @@ func (s *CCSService) ReceberBDVCCS() error {
 					}
 					
 					// Processar cada BDV
+					var bdvs []models.BemDireitoValorCCS
 					for _, bdvXML := range bemDireitoValorsXML.BemDireitoValor {
 						// Criar modelo de BDV
 						bdv := models.BemDireitoValorCCS{
@@ -682,8 +723,8 @@ func (s *CCSService) ReceberBDVCCS() error {
 							Conta:             bdvXML.Conta,
 							Vinculo:           bdvXML.Vinculo,
 							NomePessoa:        bdvXML.NomePessoa,
-							DataInicio:        bdvXML.DataInicio,
-							DataFim:           bdvXML.DataFim,
+							DataInicio:        dataBacen(bdvXML.DataInicio),
+							DataFim:           dataBacen(bdvXML.DataFim),
 							IDRelacionamento:  relacionamento.ID,
 						}
 						
@@ -693,8 +734,8 @@ func (s *CCSService) ReceberBDVCCS() error {
 							for _, vincXML := range bdvXML.Vinculados.Vinculados {
 								vinculado := models.VinculadosBDVCCS{
 									IDPessoa:          vincXML.IDPessoa,
-									DataInicio:        vincXML.DataInicio,
-									DataFim:           vincXML.DataFim,
+									DataInicio:        dataBacen(vincXML.DataInicio),
+									DataFim:           dataBacen(vincXML.DataFim),
 									NomePessoa:        vincXML.NomePessoa,
 									NomePessoaReceita: vincXML.NomePessoaReceita,
 									Tipo:              vincXML.Tipo,
@@ -704,11 +745,14 @@ func (s *CCSService) ReceberBDVCCS() error {
 						}
 						
 						bdv.Vinculados = vinculados
-						
-						// Salvar BDV e vinculados
-						// Implementação simplificada: na prática, você usaria o repositório
-						// para salvar esses dados
+						bdvs = append(bdvs, bdv)
 					}
+					
+					// Salvar BDVs e vinculados
+					if err := s.ccsRepo.SalvarBDVsCCS(relacionamento.ID, bdvs); err != nil {
+						continue
+					}
+					s.notificarDetalhamento(req.CPFResponsavel, repository.NotificacaoDetalhamentoConcluido, req.NumeroRequisicao, req.CPFCNPJ, relacionamento.ID, relacionamento.NomeBancoResponsavel)
 				}
 			}
 		}
@@ -717,9 +761,32 @@ func (s *CCSService) ReceberBDVCCS() error {
 	return nil
 }
 
-// BuscarRequisicoesCCS busca todas as requisições CCS de um CPF responsável
-func (s *CCSService) BuscarRequisicoesCCS(cpfResponsavel string) ([]models.RequisicaoRelacionamentoCCS, error) {
-	return s.ccsRepo.BuscarRequisicoesRelacionamentoCCS(cpfResponsavel)
+// notificarDetalhamento avisa o responsável pela requisição sobre o resultado
+// do detalhamento de um relacionamento
+func (s *CCSService) notificarDetalhamento(cpfResponsavel, tipo, numeroRequisicao, cpfCnpj string, idRelacionamento int, banco string) {
+	titulo := "Detalhamento CCS concluído"
+	mensagem := fmt.Sprintf("%s enviou o detalhamento de %s (requisição %s).", banco, cpfCnpj, numeroRequisicao)
+	if tipo == repository.NotificacaoDetalhamentoRecusado {
+		titulo = "Detalhamento CCS recusado"
+		mensagem = fmt.Sprintf("%s não detalha o relacionamento de %s (requisição %s).", banco, cpfCnpj, numeroRequisicao)
+	}
+	
+	s.notificacoes.Notificar(&models.Notificacao{
+		CPFUsuario: cpfResponsavel,
+		Tipo:       tipo,
+		Titulo:     titulo,
+		Mensagem:   mensagem,
+		Dados: map[string]interface{}{
+			"numeroRequisicao": numeroRequisicao,
+			"idRelacionamento": idRelacionamento,
+			"banco":            banco,
+		},
+	})
+}
+
+// BuscarRequisicoesCCS busca todas as requisições CCS visíveis no escopo
+func (s *CCSService) BuscarRequisicoesCCS(escopo repository.Escopo) ([]models.RequisicaoRelacionamentoCCS, error) {
+	return s.ccsRepo.BuscarRequisicoesRelacionamentoCCS(escopo)
 }
 
 // ConsultarParticipante consulta informações do participante pelo CNPJ
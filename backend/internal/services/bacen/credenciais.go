@@ -0,0 +1,144 @@
+package bacen
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/cripto"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+// contextoCifraCredencial vincula o texto cifrado das senhas à tabela credencial_bacen
+var contextoCifraCredencial = []byte("credencial_bacen")
+
+// ErrChaveCredenciaisAusente indica que BACEN_CREDENTIALS_KEY não foi configurada
+var ErrChaveCredenciaisAusente = errors.New("BACEN_CREDENTIALS_KEY não configurada")
+
+// ErrCredencialDesativada indica que a requisição original usou um perfil desativado
+var ErrCredencialDesativada = errors.New("credencial do BACEN desativada")
+
+// CredencialEmUso é a credencial efetivamente usada em uma chamada ao BACEN.
+// ID é nil quando se trata da credencial padrão da configuração.
+type CredencialEmUso struct {
+	ID      *int
+	Usuario string
+	Senha   string
+}
+
+// basicAuth retorna o cabeçalho de autenticação básica da credencial
+func (c CredencialEmUso) basicAuth() string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Usuario+":"+c.Senha))
+}
+
+// CredenciaisBacenService seleciona a credencial do BACEN pela lotação e
+// mantém os perfis cifrados no banco
+type CredenciaisBacenService struct {
+	config *config.Config
+	repo   *repository.CredencialBacenRepository
+}
+
+func NewCredenciaisBacenService(cfg *config.Config) *CredenciaisBacenService {
+	return &CredenciaisBacenService{
+		config: cfg,
+		repo:   repository.NewCredencialBacenRepository(),
+	}
+}
+
+func (s *CredenciaisBacenService) cifrador() (*cripto.Cifrador, error) {
+	if len(s.config.ChaveCredenciaisBacen) == 0 {
+		return nil, ErrChaveCredenciaisAusente
+	}
+	return cripto.NovoCifrador(s.config.ChaveCredenciaisBacen)
+}
+
+// padrao retorna a credencial padrão da configuração
+func (s *CredenciaisBacenService) padrao() CredencialEmUso {
+	c := s.config.CredenciaisBacen()
+	return CredencialEmUso{Usuario: c.Usuario, Senha: c.Senha}
+}
+
+// emUso decifra a senha de um perfil
+func (s *CredenciaisBacenService) emUso(c *models.CredencialBacen) (CredencialEmUso, error) {
+	cifrador, err := s.cifrador()
+	if err != nil {
+		return CredencialEmUso{}, err
+	}
+	senha, err := cifrador.Decifrar(c.SenhaCifrada, contextoCifraCredencial)
+	if err != nil {
+		return CredencialEmUso{}, fmt.Errorf("erro ao decifrar a credencial da lotação %s: %w", c.Lotacao, err)
+	}
+	id := c.ID
+	return CredencialEmUso{ID: &id, Usuario: c.Usuario, Senha: string(senha)}, nil
+}
+
+// ParaLotacao retorna o perfil ativo da lotação ou, se ela não tiver um, a
+// credencial padrão da configuração
+func (s *CredenciaisBacenService) ParaLotacao(lotacao string) (CredencialEmUso, error) {
+	perfil, err := s.repo.BuscarPorLotacao(lotacao)
+	if err == repository.ErrCredencialNaoEncontrada {
+		return s.padrao(), nil
+	}
+	if err != nil {
+		return CredencialEmUso{}, err
+	}
+	return s.emUso(perfil)
+}
+
+// PorID retorna a credencial registrada em uma requisição, para que as etapas
+// seguintes (detalhamento, BDVs) usem o mesmo usuário do BACEN
+func (s *CredenciaisBacenService) PorID(id *int) (CredencialEmUso, error) {
+	if id == nil {
+		return s.padrao(), nil
+	}
+	perfil, err := s.repo.BuscarPorID(*id)
+	if err != nil {
+		return CredencialEmUso{}, err
+	}
+	if !perfil.Ativo {
+		return CredencialEmUso{}, ErrCredencialDesativada
+	}
+	return s.emUso(perfil)
+}
+
+// Listar retorna os perfis cadastrados, sem as senhas
+func (s *CredenciaisBacenService) Listar() ([]models.CredencialBacen, error) {
+	return s.repo.Listar()
+}
+
+// Salvar cria (ID zero) ou atualiza um perfil, cifrando a senha informada. Na
+// atualização, senha vazia mantém a senha atual.
+func (s *CredenciaisBacenService) Salvar(c *models.CredencialBacen, senha string) error {
+	c.Lotacao = strings.TrimSpace(c.Lotacao)
+	c.Usuario = strings.TrimSpace(c.Usuario)
+	if c.Lotacao == "" || c.Usuario == "" {
+		return errors.New("lotação e usuário são obrigatórios")
+	}
+	if c.ID == 0 && senha == "" {
+		return errors.New("a senha é obrigatória")
+	}
+
+	c.SenhaCifrada = nil
+	if senha != "" {
+		cifrador, err := s.cifrador()
+		if err != nil {
+			return err
+		}
+		if c.SenhaCifrada, err = cifrador.Cifrar([]byte(senha), contextoCifraCredencial); err != nil {
+			return err
+		}
+	}
+
+	if c.ID == 0 {
+		return s.repo.Criar(c)
+	}
+	return s.repo.Atualizar(c)
+}
+
+// Remover exclui um perfil
+func (s *CredenciaisBacenService) Remover(id int) error {
+	return s.repo.Remover(id)
+}
@@ -0,0 +1,97 @@
+package bacen
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // A imagem alpine não traz a base de fusos horários
+)
+
+// fusoBrasilia é o fuso usado pelo BACEN quando a data não informa offset
+var fusoBrasilia = carregarFusoBrasilia()
+
+// layoutsDataBacen lista os formatos de data encontrados nas respostas do BACEN.
+// Frações de segundo são aceitas automaticamente após o campo de segundos.
+var layoutsDataBacen = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+	"02/01/2006",
+	"20060102150405",
+	"20060102",
+}
+
+func carregarFusoBrasilia() *time.Location {
+	loc, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		return time.FixedZone("BRT", -3*60*60)
+	}
+	return loc
+}
+
+// ParseDataBacen interpreta uma data em qualquer dos formatos usados pelo BACEN.
+// Retorna nil, sem erro, quando o valor está vazio (data omitida pelo BACEN).
+func ParseDataBacen(valor string) (*time.Time, error) {
+	valor = strings.TrimSpace(valor)
+	if valor == "" {
+		return nil, nil
+	}
+
+	for _, layout := range layoutsDataBacen {
+		if t, err := time.ParseInLocation(layout, valor, fusoBrasilia); err == nil {
+			return &t, nil
+		}
+	}
+
+	return nil, fmt.Errorf("data em formato não reconhecido: %q", valor)
+}
+
+// dataBacen é a versão tolerante de ParseDataBacen usada ao converter respostas:
+// um valor ilegível é descartado em vez de abortar o processamento.
+func dataBacen(valor string) *time.Time {
+	t, err := ParseDataBacen(valor)
+	if err != nil {
+		return nil
+	}
+	return t
+}
+
+// FormatarDataBacen formata uma data no padrão aaaa-mm-dd esperado pelos
+// parâmetros das APIs do BACEN
+func FormatarDataBacen(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.In(fusoBrasilia).Format("2006-01-02")
+}
+
+// normalizarDataParametro converte uma data recebida do frontend, em qualquer
+// formato aceito, para o padrão dos parâmetros do BACEN
+func normalizarDataParametro(valor string) string {
+	t, err := ParseDataBacen(valor)
+	if err != nil || t == nil {
+		return valor
+	}
+	return FormatarDataBacen(t)
+}
+
+// dataOuAgora interpreta uma data do BACEN e usa o horário atual quando ela é
+// omitida ou ilegível, para campos obrigatórios como a data da requisição
+func dataOuAgora(valor string) time.Time {
+	if t := dataBacen(valor); t != nil {
+		return *t
+	}
+	return time.Now()
+}
+
+// agora retorna o horário atual como ponteiro, para campos de data opcionais
+func agora() *time.Time {
+	t := time.Now()
+	return &t
+}
@@ -0,0 +1,134 @@
+package bacen
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDataBacen(t *testing.T) {
+	utc := time.UTC
+	casos := []struct {
+		nome   string
+		valor  string
+		espera time.Time
+	}{
+		{"ISO 8601 com fuso Z", "2024-03-15T10:30:00Z", time.Date(2024, 3, 15, 10, 30, 0, 0, utc)},
+		{"ISO 8601 com offset", "2024-03-15T10:30:00-03:00", time.Date(2024, 3, 15, 13, 30, 0, 0, utc)},
+		{"ISO 8601 com offset sem dois-pontos", "2024-03-15T10:30:00-0300", time.Date(2024, 3, 15, 13, 30, 0, 0, utc)},
+		{"ISO 8601 com fração de segundo", "2024-03-15T10:30:00.123Z", time.Date(2024, 3, 15, 10, 30, 0, 123000000, utc)},
+		{"ISO 8601 sem fuso", "2024-03-15T10:30:00", time.Date(2024, 3, 15, 10, 30, 0, 0, fusoBrasilia)},
+		{"ISO 8601 com espaço e sem fuso", "2024-03-15 10:30:00", time.Date(2024, 3, 15, 10, 30, 0, 0, fusoBrasilia)},
+		{"ISO 8601 sem segundos", "2024-03-15T10:30", time.Date(2024, 3, 15, 10, 30, 0, 0, fusoBrasilia)},
+		{"ISO 8601 sem hora", "2024-03-15", time.Date(2024, 3, 15, 0, 0, 0, 0, fusoBrasilia)},
+		{"dd/mm/aaaa com hora", "15/03/2024 10:30:45", time.Date(2024, 3, 15, 10, 30, 45, 0, fusoBrasilia)},
+		{"dd/mm/aaaa com hora sem segundos", "15/03/2024 10:30", time.Date(2024, 3, 15, 10, 30, 0, 0, fusoBrasilia)},
+		{"dd/mm/aaaa", "15/03/2024", time.Date(2024, 3, 15, 0, 0, 0, 0, fusoBrasilia)},
+		{"aaaammddhhmmss", "20240315103045", time.Date(2024, 3, 15, 10, 30, 45, 0, fusoBrasilia)},
+		{"aaaammdd", "20240315", time.Date(2024, 3, 15, 0, 0, 0, 0, fusoBrasilia)},
+		{"com espaços nas pontas", "  15/03/2024 ", time.Date(2024, 3, 15, 0, 0, 0, 0, fusoBrasilia)},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			got, err := ParseDataBacen(c.valor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got == nil || !got.Equal(c.espera) {
+				t.Fatalf("ParseDataBacen(%q) = %v, esperado %v", c.valor, got, c.espera)
+			}
+		})
+	}
+}
+
+func TestParseDataBacenVazia(t *testing.T) {
+	for _, valor := range []string{"", "   ", "\t"} {
+		got, err := ParseDataBacen(valor)
+		if err != nil || got != nil {
+			t.Errorf("ParseDataBacen(%q) = %v, %v, esperado nil sem erro", valor, got, err)
+		}
+	}
+	if got := dataBacen(""); got != nil {
+		t.Errorf("dataBacen(\"\") = %v, esperado nil", got)
+	}
+	if got := FormatarDataBacen(nil); got != "" {
+		t.Errorf("FormatarDataBacen(nil) = %q, esperado vazio", got)
+	}
+}
+
+func TestParseDataBacenInvalida(t *testing.T) {
+	for _, valor := range []string{"ontem", "2024-13-01", "31/02/2024", "15-03-2024", "2024031"} {
+		if got, err := ParseDataBacen(valor); err == nil {
+			t.Errorf("ParseDataBacen(%q) = %v, esperado erro", valor, got)
+		}
+		// A versão tolerante descarta o valor
+		if got := dataBacen(valor); got != nil {
+			t.Errorf("dataBacen(%q) = %v, esperado nil", valor, got)
+		}
+	}
+}
+
+func TestFormatarDataBacen(t *testing.T) {
+	casos := []struct {
+		nome   string
+		data   time.Time
+		espera string
+	}{
+		{"horário de Brasília", time.Date(2024, 3, 15, 23, 59, 0, 0, fusoBrasilia), "2024-03-15"},
+		// 01:00 UTC ainda é o dia anterior em Brasília
+		{"UTC convertido para Brasília", time.Date(2024, 3, 16, 1, 0, 0, 0, time.UTC), "2024-03-15"},
+		{"meia-noite", time.Date(2024, 1, 1, 0, 0, 0, 0, fusoBrasilia), "2024-01-01"},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			if got := FormatarDataBacen(&c.data); got != c.espera {
+				t.Fatalf("FormatarDataBacen = %q, esperado %q", got, c.espera)
+			}
+		})
+	}
+}
+
+func TestFormatarEInterpretarDataBacen(t *testing.T) {
+	for _, valor := range []string{"2024-03-15", "15/03/2024", "20240315", "2024-03-15T10:30:00-03:00", "2024-02-29"} {
+		t.Run(valor, func(t *testing.T) {
+			original, err := ParseDataBacen(valor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			formatada := FormatarDataBacen(original)
+			relida, err := ParseDataBacen(formatada)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if FormatarDataBacen(relida) != formatada {
+				t.Fatalf("ida e volta de %q: %q virou %q", valor, formatada, FormatarDataBacen(relida))
+			}
+			dia := original.In(fusoBrasilia)
+			if !relida.Equal(time.Date(dia.Year(), dia.Month(), dia.Day(), 0, 0, 0, 0, fusoBrasilia)) {
+				t.Fatalf("%q relida como %v, esperado o início do dia %s", formatada, relida, formatada)
+			}
+		})
+	}
+}
+
+func TestNormalizarDataParametro(t *testing.T) {
+	casos := []struct {
+		nome   string
+		valor  string
+		espera string
+	}{
+		{"aaaa-mm-dd", "2024-03-15", "2024-03-15"},
+		{"dd/mm/aaaa", "15/03/2024", "2024-03-15"},
+		{"aaaammdd", "20240315", "2024-03-15"},
+		{"ISO 8601 com hora e fuso", "2024-03-15T10:30:00Z", "2024-03-15"},
+		{"vazio", "", ""},
+		// Um valor ilegível segue como veio, para o BACEN recusar
+		{"ilegível", "amanhã", "amanhã"},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			if got := normalizarDataParametro(c.valor); got != c.espera {
+				t.Fatalf("normalizarDataParametro(%q) = %q, esperado %q", c.valor, got, c.espera)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package bacen
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+)
+
+// limitadorBacen é um token bucket: acumula até capacidade fichas, repostas à
+// taxa por segundo, e cada chamada ao BACEN consome uma. Sem fichas, a chamada
+// espera a próxima reposição em vez de falhar.
+type limitadorBacen struct {
+	mu         sync.Mutex
+	taxa       float64
+	capacidade float64
+	fichas     float64
+	ultimo     time.Time
+}
+
+func novoLimitadorBacen(taxa float64, capacidade int) *limitadorBacen {
+	if capacidade < 1 {
+		capacidade = 1
+	}
+	return &limitadorBacen{
+		taxa:       taxa,
+		capacidade: float64(capacidade),
+		fichas:     float64(capacidade),
+		ultimo:     time.Now(),
+	}
+}
+
+// reservar consome uma ficha e retorna quanto tempo esperar até que ela exista.
+// O saldo pode ficar negativo, o que enfileira as chamadas seguintes.
+func (l *limitadorBacen) reservar() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	agora := time.Now()
+	l.fichas += agora.Sub(l.ultimo).Seconds() * l.taxa
+	if l.fichas > l.capacidade {
+		l.fichas = l.capacidade
+	}
+	l.ultimo = agora
+
+	l.fichas--
+	if l.fichas >= 0 {
+		return 0
+	}
+	return time.Duration(-l.fichas / l.taxa * float64(time.Second))
+}
+
+// Aguardar bloqueia até haver ficha disponível ou o contexto ser cancelado
+func (l *limitadorBacen) Aguardar(ctx context.Context) error {
+	if l == nil || l.taxa <= 0 {
+		return nil
+	}
+	espera := l.reservar()
+	if espera == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(espera)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// O limitador é único no processo: os serviços são criados por handler, mas
+// todos falam com o mesmo BACEN
+var (
+	limitadorUnico     *limitadorBacen
+	limitadorUnicoOnce sync.Once
+)
+
+// clienteBacen é o cliente HTTP das APIs do BACEN, com o limitador de taxa à frente
+type clienteBacen struct {
+	http      *http.Client
+	limitador *limitadorBacen
+}
+
+func novoClienteBacen(cfg *config.Config) *clienteBacen {
+	limitadorUnicoOnce.Do(func() {
+		limitadorUnico = novoLimitadorBacen(cfg.TaxaBacenPorSegundo, cfg.RajadaBacen)
+	})
+	return &clienteBacen{
+		http:      &http.Client{Timeout: 30 * time.Second},
+		limitador: limitadorUnico,
+	}
+}
+
+// Do aguarda a vez no limitador e executa a requisição
+func (c *clienteBacen) Do(req *http.Request) (*http.Response, error) {
+	if err := c.limitador.Aguardar(req.Context()); err != nil {
+		return nil, err
+	}
+	return c.http.Do(req)
+}
@@ -0,0 +1,203 @@
+package bacen
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/repository"
+	"github.com/tassyosilva/consultapix/internal/services/comparacao"
+	"github.com/tassyosilva/consultapix/internal/services/notificacao"
+)
+
+// loteMonitoramentos é o máximo de monitoramentos executados por ciclo
+const loteMonitoramentos = 20
+
+// camposTitularidadeConta são os campos de chave cuja mudança indica troca de
+// titular, de conta ou de instituição
+var camposTitularidadeConta = map[string]bool{
+	"cpfCnpj":          true,
+	"nomeProprietario": true,
+	"participante":     true,
+	"nomeBanco":        true,
+	"agencia":          true,
+	"numeroConta":      true,
+	"tipoConta":        true,
+}
+
+// MonitoramentoService reconsulta periodicamente as chaves e CPFs/CNPJs
+// monitorados e avisa os responsáveis quando titular ou conta mudam
+type MonitoramentoService struct {
+	pix            *PixService
+	pixRepo        *repository.PixRepository
+	monitoramentos *repository.MonitoramentoRepository
+	cotas          *repository.CotaRepository
+	usuarios       *repository.UserRepository
+	notificacoes   *notificacao.NotificacaoService
+}
+
+func NewMonitoramentoService(cfg *config.Config) *MonitoramentoService {
+	return &MonitoramentoService{
+		pix:            NewPixService(cfg),
+		pixRepo:        repository.NewPixRepository(),
+		monitoramentos: repository.NewMonitoramentoRepository(),
+		cotas:          repository.NewCotaRepository(),
+		usuarios:       repository.NewUserRepository(),
+		notificacoes:   notificacao.NewNotificacaoService(cfg),
+	}
+}
+
+// Executar roda ExecutarPendentes a cada intervalo
+func (s *MonitoramentoService) Executar(intervalo time.Duration) {
+	ticker := time.NewTicker(intervalo)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.ExecutarPendentes(); err != nil {
+			log.Printf("Erro no agendador de monitoramentos: %v", err)
+		}
+	}
+}
+
+// ExecutarPendentes encerra os monitoramentos com autorização expirada e
+// executa os que estão vencidos
+func (s *MonitoramentoService) ExecutarPendentes() error {
+	expirados, err := s.monitoramentos.EncerrarExpirados()
+	if err != nil {
+		return err
+	}
+	for _, m := range expirados {
+		s.notificar(m, repository.NotificacaoMonitoramentoEncerrado, "Monitoramento encerrado",
+			fmt.Sprintf("O monitoramento de %s (caso %s) foi encerrado porque a autorização %s expirou.", m.Alvo, m.Caso, m.NumeroAutorizacao),
+			map[string]interface{}{"idMonitoramento": m.ID})
+	}
+
+	pendentes, err := s.monitoramentos.ReservarPendentes(loteMonitoramentos)
+	if err != nil {
+		return err
+	}
+	for _, m := range pendentes {
+		s.executar(m)
+	}
+	return nil
+}
+
+// executar reconsulta o alvo com os dados do responsável pelo monitoramento,
+// consumindo a cota dele, e compara com a última consulta bem-sucedida
+func (s *MonitoramentoService) executar(m models.Monitoramento) {
+	operacao := repository.OperacaoPixCPFCNPJ
+	if m.TipoBusca == TipoBuscaChave {
+		operacao = repository.OperacaoPixChave
+	}
+	// A requisição fica na unidade atual do responsável, como uma consulta
+	// feita por ele agora
+	usuario, err := s.usuarios.FindByCPF(m.CPFResponsavel)
+	if err != nil {
+		s.registrarErro(m, err)
+		return
+	}
+	responsavel := Responsavel{CPF: m.CPFResponsavel, Lotacao: m.Lotacao, Unidade: usuario.IDUnidade}
+
+	usos, err := s.cotas.Consumir(m.CPFResponsavel, m.Lotacao, operacao)
+	if err != nil {
+		s.registrarErro(m, err)
+		return
+	}
+	s.notificacoes.AvisarCotaProxima(m.CPFResponsavel, usos)
+
+	inicio := time.Now()
+	if m.TipoBusca == TipoBuscaChave {
+		_, err = s.pix.ConsultarChavePix(m.Alvo, m.Motivo, responsavel, m.Caso)
+	} else {
+		_, err = s.pix.ConsultarPorCPFCNPJ(m.Alvo, m.Motivo, responsavel, m.Caso)
+	}
+	if err != nil {
+		s.registrarErro(m, err)
+		return
+	}
+
+	// A consulta acabou de ser gravada em nome do responsável pelo monitoramento
+	atual, err := s.pixRepo.BuscarResultadoRecente(repository.Escopo{CPF: m.CPFResponsavel}, m.TipoBusca, m.Alvo, m.Caso, inicio)
+	if err != nil {
+		s.registrarErro(m, err)
+		return
+	}
+	if atual == nil {
+		s.registrarErro(m, fmt.Errorf("o BACEN não retornou resultado válido"))
+		return
+	}
+
+	if m.IDUltimaRequisicao != nil {
+		if err := s.compararENotificar(m, *m.IDUltimaRequisicao, atual.ID); err != nil {
+			log.Printf("Erro ao comparar monitoramento %d: %v", m.ID, err)
+		}
+	}
+
+	if err := s.monitoramentos.RegistrarExecucao(m.ID, &atual.ID, ""); err != nil {
+		log.Printf("Erro ao registrar execução do monitoramento %d: %v", m.ID, err)
+	}
+}
+
+func (s *MonitoramentoService) registrarErro(m models.Monitoramento, causa error) {
+	log.Printf("Monitoramento %d: %v", m.ID, causa)
+	if err := s.monitoramentos.RegistrarExecucao(m.ID, nil, causa.Error()); err != nil {
+		log.Printf("Erro ao registrar execução do monitoramento %d: %v", m.ID, err)
+	}
+}
+
+// compararENotificar avisa os responsáveis quando chaves surgem, somem ou
+// mudam de titular, conta ou instituição
+func (s *MonitoramentoService) compararENotificar(m models.Monitoramento, idAnterior, idAtual int) error {
+	anterior, err := s.pixRepo.BuscarRequisicaoPixPorID(repository.EscopoSistema, idAnterior)
+	if err != nil {
+		return err
+	}
+	atual, err := s.pixRepo.BuscarRequisicaoPixPorID(repository.EscopoSistema, idAtual)
+	if err != nil {
+		return err
+	}
+
+	var linhas []string
+	c := comparacao.CompararPix(anterior, atual)
+	for _, item := range c.Adicionados {
+		if item.Tipo == comparacao.TipoChave {
+			linhas = append(linhas, "Nova chave: "+item.Identificador)
+		}
+	}
+	for _, item := range c.Removidos {
+		if item.Tipo == comparacao.TipoChave {
+			linhas = append(linhas, "Chave removida: "+item.Identificador)
+		}
+	}
+	for _, item := range c.Modificados {
+		for _, campo := range item.Campos {
+			if camposTitularidadeConta[campo.Campo] {
+				linhas = append(linhas, fmt.Sprintf("%s: %s de %q para %q", item.Identificador, campo.Campo, campo.Anterior, campo.Atual))
+			}
+		}
+	}
+	if len(linhas) == 0 {
+		return nil
+	}
+
+	s.notificar(m, repository.NotificacaoMonitoramentoAlteracao,
+		fmt.Sprintf("Alterações em %s (caso %s)", m.Alvo, m.Caso),
+		strings.Join(linhas, "\n"),
+		map[string]interface{}{"idMonitoramento": m.ID, "idAnterior": idAnterior, "idAtual": idAtual})
+	return nil
+}
+
+// notificar envia a notificação ao criador e aos responsáveis do monitoramento
+func (s *MonitoramentoService) notificar(m models.Monitoramento, tipo, titulo, mensagem string, dados map[string]interface{}) {
+	destinatarios := map[string]bool{m.CPFResponsavel: true}
+	for _, cpf := range m.Responsaveis {
+		destinatarios[cpf] = true
+	}
+
+	for cpf := range destinatarios {
+		s.notificacoes.Notificar(&models.Notificacao{CPFUsuario: cpf, Tipo: tipo, Titulo: titulo, Mensagem: mensagem, Dados: dados})
+	}
+}
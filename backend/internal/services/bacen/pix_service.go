@@ -1,7 +1,6 @@
 package bacen
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,9 +13,10 @@ import (
 )
 
 type PixService struct {
-	config   *config.Config
-	pixRepo  *repository.PixRepository
-	client   *http.Client
+	config      *config.Config
+	pixRepo     *repository.PixRepository
+	credenciais *CredenciaisBacenService
+	client      *clienteBacen
 }
 
 type ParticipanteResponse struct {
@@ -73,18 +73,13 @@ type VinculosPixResponse struct {
 
 func NewPixService(cfg *config.Config) *PixService {
 	return &PixService{
-		config:  cfg,
-		pixRepo: repository.NewPixRepository(),
-		client:  &http.Client{Timeout: 30 * time.Second},
+		config:      cfg,
+		pixRepo:     repository.NewPixRepository(),
+		credenciais: NewCredenciaisBacenService(cfg),
+		client:      novoClienteBacen(cfg),
 	}
 }
 
-// getBasicAuth retorna o cabeçalho de autenticação básica
-func (s *PixService) getBasicAuth() string {
-	credentials := fmt.Sprintf("%s:%s", s.config.BacenUsername, s.config.BacenPassword)
-	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
-}
-
 // ConsultarParticipante consulta informações do participante pelo CNPJ
 func (s *PixService) ConsultarParticipante(cnpj string) (*ParticipanteResponse, error) {
 	url := fmt.Sprintf("https://www3.bcb.gov.br/informes/rest/pessoasJuridicas?cnpj=%s", cnpj)
@@ -114,7 +109,13 @@ func (s *PixService) ConsultarParticipante(cnpj string) (*ParticipanteResponse,
 }
 
 // ConsultarChavePix consulta informações de uma chave PIX
-func (s *PixService) ConsultarChavePix(chave, motivo string, cpfResponsavel, lotacao, caso string) ([]ChavePixResponse, error) {
+func (s *PixService) ConsultarChavePix(chave, motivo string, responsavel Responsavel, caso string) ([]ChavePixResponse, error) {
+	// Credencial do BACEN da lotação do usuário
+	credencial, err := s.credenciais.ParaLotacao(responsavel.Lotacao)
+	if err != nil {
+		return nil, err
+	}
+
 	url := fmt.Sprintf("https://www3.bcb.gov.br/bc_ccs/rest/consultar-vinculo-pix?chave=%s&motivo=%s", chave, motivo)
 	
 	req, err := http.NewRequest("GET", url, nil)
@@ -123,7 +124,7 @@ func (s *PixService) ConsultarChavePix(chave, motivo string, cpfResponsavel, lot
 	}
 	
 	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Authorization", s.getBasicAuth())
+	req.Header.Add("Authorization", credencial.basicAuth())
 	
 	resp, err := s.client.Do(req)
 	if err != nil {
@@ -146,14 +147,17 @@ func (s *PixService) ConsultarChavePix(chave, motivo string, cpfResponsavel, lot
 		// Registrar requisição sem chave encontrada
 		req := &models.RequisicaoPix{
 			Data:           time.Now(),
-			CPFResponsavel: cpfResponsavel,
-			Lotacao:        lotacao,
+			CPFResponsavel: responsavel.CPF,
+			Lotacao:        responsavel.Lotacao,
+			IDUnidade:      responsavel.idUnidade(),
 			Caso:           caso,
-			TipoBusca:      "chave",
+			TipoBusca:      TipoBuscaChave,
 			ChaveBusca:     chave,
 			MotivoBusca:    motivo,
 			Resultado:      "Chave não encontrada",
 			Autorizado:     true,
+			IDCredencialBacen: credencial.ID,
+			UsuarioBacen:   credencial.Usuario,
 		}
 		
 		_, err = s.pixRepo.CriarRequisicaoPix(req)
@@ -207,21 +211,24 @@ func (s *PixService) ConsultarChavePix(chave, motivo string, cpfResponsavel, lot
 	// Salvar a requisição no banco de dados
 	requisicaoPix := &models.RequisicaoPix{
 		Data:           time.Now(),
-		CPFResponsavel: cpfResponsavel,
-		Lotacao:        lotacao,
+		CPFResponsavel: responsavel.CPF,
+		Lotacao:        responsavel.Lotacao,
+		IDUnidade:      responsavel.idUnidade(),
 		Caso:           caso,
-		TipoBusca:      "chave",
+		TipoBusca:      TipoBuscaChave,
 		ChaveBusca:     chave,
 		MotivoBusca:    motivo,
 		Resultado:      "Sucesso",
 		Vinculos:       chaveResp,
 		Autorizado:     true,
+		IDCredencialBacen: credencial.ID,
+		UsuarioBacen:   credencial.Usuario,
 		Chaves: []models.ChavePix{
 			{
 				Chave:                     chaveResp.Chave,
 				TipoChave:                 chaveResp.TipoChave,
 				Status:                    chaveResp.Status,
-				DataAberturaReivindicacao: chaveResp.DataAberturaReivindicacao,
+				DataAberturaReivindicacao: dataBacen(chaveResp.DataAberturaReivindicacao),
 				CPFCNPJ:                   chaveResp.CPFCNPJ,
 				NomeProprietario:          chaveResp.NomeProprietario,
 				NomeFantasia:              chaveResp.NomeFantasia,
@@ -229,10 +236,10 @@ func (s *PixService) ConsultarChavePix(chave, motivo string, cpfResponsavel, lot
 				Agencia:                   chaveResp.Agencia,
 				NumeroConta:               chaveResp.NumeroConta,
 				TipoConta:                 chaveResp.TipoConta,
-				DataAberturaConta:         chaveResp.DataAberturaConta,
-				ProprietarioDaChaveDesde:  chaveResp.ProprietarioDaChaveDesde,
-				DataCriacao:               chaveResp.DataCriacao,
-				UltimaModificacao:         chaveResp.UltimaModificacao,
+				DataAberturaConta:         dataBacen(chaveResp.DataAberturaConta),
+				ProprietarioDaChaveDesde:  dataBacen(chaveResp.ProprietarioDaChaveDesde),
+				DataCriacao:               dataBacen(chaveResp.DataCriacao),
+				UltimaModificacao:         dataBacen(chaveResp.UltimaModificacao),
 				NumeroBanco:               chaveResp.NumeroBanco,
 				NomeBanco:                 chaveResp.NomeBanco,
 				CPFCNPJBusca:              chaveResp.CPFCNPJBusca,
@@ -251,7 +258,13 @@ func (s *PixService) ConsultarChavePix(chave, motivo string, cpfResponsavel, lot
 }
 
 // ConsultarPorCPFCNPJ consulta todas as chaves PIX associadas a um CPF/CNPJ
-func (s *PixService) ConsultarPorCPFCNPJ(cpfCnpj, motivo, cpfResponsavel, lotacao, caso string) (interface{}, error) {
+func (s *PixService) ConsultarPorCPFCNPJ(cpfCnpj, motivo string, responsavel Responsavel, caso string) (interface{}, error) {
+	// Credencial do BACEN da lotação do usuário
+	credencial, err := s.credenciais.ParaLotacao(responsavel.Lotacao)
+	if err != nil {
+		return nil, err
+	}
+
 	url := fmt.Sprintf("https://www3.bcb.gov.br/bc_ccs/rest/consultar-vinculos-pix?cpfCnpj=%s&motivo=%s", cpfCnpj, motivo)
 	
 	req, err := http.NewRequest("GET", url, nil)
@@ -260,7 +273,7 @@ func (s *PixService) ConsultarPorCPFCNPJ(cpfCnpj, motivo, cpfResponsavel, lotaca
 	}
 	
 	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Authorization", s.getBasicAuth())
+	req.Header.Add("Authorization", credencial.basicAuth())
 	
 	resp, err := s.client.Do(req)
 	if err != nil {
@@ -272,13 +285,16 @@ func (s *PixService) ConsultarPorCPFCNPJ(cpfCnpj, motivo, cpfResponsavel, lotaca
 		// Armazenar falha na requisição
 		errReq := &models.RequisicaoPix{
 			Data:           time.Now(),
-			CPFResponsavel: cpfResponsavel,
-			Lotacao:        lotacao,
+			CPFResponsavel: responsavel.CPF,
+			Lotacao:        responsavel.Lotacao,
+			IDUnidade:      responsavel.idUnidade(),
 			Caso:           caso,
-			TipoBusca:      "cpf/cnpj",
+			TipoBusca:      TipoBuscaCPFCNPJ,
 			ChaveBusca:     cpfCnpj,
 			MotivoBusca:    motivo,
 			Autorizado:     true,
+			IDCredencialBacen: credencial.ID,
+			UsuarioBacen:   credencial.Usuario,
 			Resultado:      "Erro no processamento da Solicitação",
 		}
 		
@@ -305,13 +321,16 @@ func (s *PixService) ConsultarPorCPFCNPJ(cpfCnpj, motivo, cpfResponsavel, lotaca
 	if len(vinculosResp.VinculosPix) == 0 {
 		req := &models.RequisicaoPix{
 			Data:           time.Now(),
-			CPFResponsavel: cpfResponsavel,
-			Lotacao:        lotacao,
+			CPFResponsavel: responsavel.CPF,
+			Lotacao:        responsavel.Lotacao,
+			IDUnidade:      responsavel.idUnidade(),
 			Caso:           caso,
-			TipoBusca:      "cpf/cnpj",
+			TipoBusca:      TipoBuscaCPFCNPJ,
 			ChaveBusca:     cpfCnpj,
 			MotivoBusca:    motivo,
 			Autorizado:     true,
+			IDCredencialBacen: credencial.ID,
+			UsuarioBacen:   credencial.Usuario,
 			Resultado:      "Nenhuma Chave PIX encontrada",
 		}
 		
@@ -373,7 +392,7 @@ func (s *PixService) ConsultarPorCPFCNPJ(cpfCnpj, motivo, cpfResponsavel, lotaca
 			Chave:                     chave.Chave,
 			TipoChave:                 chave.TipoChave,
 			Status:                    chave.Status,
-			DataAberturaReivindicacao: chave.DataAberturaReivindicacao,
+			DataAberturaReivindicacao: dataBacen(chave.DataAberturaReivindicacao),
 			CPFCNPJ:                   chave.CPFCNPJ,
 			NomeProprietario:          chave.NomeProprietario,
 			NomeFantasia:              chave.NomeFantasia,
@@ -381,10 +400,10 @@ func (s *PixService) ConsultarPorCPFCNPJ(cpfCnpj, motivo, cpfResponsavel, lotaca
 			Agencia:                   chave.Agencia,
 			NumeroConta:               chave.NumeroConta,
 			TipoConta:                 chave.TipoConta,
-			DataAberturaConta:         chave.DataAberturaConta,
-			ProprietarioDaChaveDesde:  chave.ProprietarioDaChaveDesde,
-			DataCriacao:               chave.DataCriacao,
-			UltimaModificacao:         chave.UltimaModificacao,
+			DataAberturaConta:         dataBacen(chave.DataAberturaConta),
+			ProprietarioDaChaveDesde:  dataBacen(chave.ProprietarioDaChaveDesde),
+			DataCriacao:               dataBacen(chave.DataCriacao),
+			UltimaModificacao:         dataBacen(chave.UltimaModificacao),
 			NumeroBanco:               chave.NumeroBanco,
 			NomeBanco:                 chave.NomeBanco,
 			CPFCNPJBusca:              chave.CPFCNPJBusca,
@@ -396,15 +415,18 @@ func (s *PixService) ConsultarPorCPFCNPJ(cpfCnpj, motivo, cpfResponsavel, lotaca
 	// Salvar requisição
 	requisicao := &models.RequisicaoPix{
 		Data:           time.Now(),
-		CPFResponsavel: cpfResponsavel,
-		Lotacao:        lotacao,
+		CPFResponsavel: responsavel.CPF,
+		Lotacao:        responsavel.Lotacao,
+		IDUnidade:      responsavel.idUnidade(),
 		Caso:           caso,
-		TipoBusca:      "cpf/cnpj",
+		TipoBusca:      TipoBuscaCPFCNPJ,
 		ChaveBusca:     cpfCnpj,
 		MotivoBusca:    motivo,
 		Resultado:      "Sucesso",
 		Vinculos:       vinculosResp.VinculosPix,
 		Autorizado:     true,
+		IDCredencialBacen: credencial.ID,
+		UsuarioBacen:   credencial.Usuario,
 		Chaves:         chavesModels,
 	}
 	
@@ -423,7 +445,7 @@ func convertEventosVinculo(eventos []EventoVinculoResponse) []models.EventoChave
 		result[i] = models.EventoChavePix{
 			TipoEvento:        evento.TipoEvento,
 			MotivoEvento:      evento.MotivoEvento,
-			DataEvento:        evento.DataEvento,
+			DataEvento:        dataBacen(evento.DataEvento),
 			Chave:             evento.Chave,
 			TipoChave:         evento.TipoChave,
 			CPFCNPJ:           evento.CPFCNPJ,
@@ -433,7 +455,7 @@ func convertEventosVinculo(eventos []EventoVinculoResponse) []models.EventoChave
 			Agencia:           evento.Agencia,
 			NumeroConta:       evento.NumeroConta,
 			TipoConta:         evento.TipoConta,
-			DataAberturaConta: evento.DataAberturaConta,
+			DataAberturaConta: dataBacen(evento.DataAberturaConta),
 			NumeroBanco:       evento.NumeroBanco,
 			NomeBanco:         evento.NomeBanco,
 		}
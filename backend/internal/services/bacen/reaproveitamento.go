@@ -0,0 +1,61 @@
+package bacen
+
+import (
+	"strings"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+// Tipos de busca PIX, como gravados em requisicao_pix.tipo_busca
+const (
+	TipoBuscaChave   = "chave"
+	TipoBuscaCPFCNPJ = "cpf/cnpj"
+)
+
+// ResultadoReaproveitado é o resultado gravado de uma consulta PIX feita dentro
+// da janela de reaproveitamento
+type ResultadoReaproveitado struct {
+	IDRequisicao int
+	Data         time.Time
+	// Resultado tem o mesmo formato da resposta de uma consulta nova
+	Resultado interface{}
+}
+
+// ResultadoRecente procura uma consulta do mesmo alvo feita no mesmo caso dentro
+// da janela configurada, entre as visíveis no escopo de quem consulta. Retorna
+// nil se o reaproveitamento estiver desativado, se o caso não for informado ou
+// se não houver consulta recente.
+func (s *PixService) ResultadoRecente(escopo repository.Escopo, tipoBusca, alvo, caso string) (*ResultadoReaproveitado, error) {
+	janela := s.config.JanelaReaproveitamento
+	if janela <= 0 || strings.TrimSpace(caso) == "" || alvo == "" {
+		return nil, nil
+	}
+
+	req, err := s.pixRepo.BuscarResultadoRecente(escopo, tipoBusca, alvo, caso, time.Now().Add(-janela))
+	if err != nil || req == nil {
+		return nil, err
+	}
+
+	return &ResultadoReaproveitado{
+		IDRequisicao: req.ID,
+		Data:         req.Data,
+		Resultado:    respostaGravada(req.TipoBusca, req.Resultado, req.Vinculos),
+	}, nil
+}
+
+// respostaGravada remonta, a partir da requisição gravada, a resposta que
+// ConsultarChavePix ou ConsultarPorCPFCNPJ deu na ocasião
+func respostaGravada(tipoBusca, resultado string, vinculos interface{}) interface{} {
+	sucesso := resultado == "Sucesso" && vinculos != nil
+	if tipoBusca == TipoBuscaChave {
+		if sucesso {
+			return []interface{}{vinculos}
+		}
+		return []ChavePixResponse{}
+	}
+	if sucesso {
+		return vinculos
+	}
+	return []string{resultado}
+}
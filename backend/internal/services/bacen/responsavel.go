@@ -0,0 +1,21 @@
+package bacen
+
+// Responsavel identifica quem responde por uma consulta ao BACEN. Vem sempre
+// do token do usuário ou do monitoramento, nunca dos parâmetros da consulta:
+// o CPF e a unidade decidem quem vê a requisição, e a lotação escolhe a
+// credencial e a cota.
+type Responsavel struct {
+	CPF     string
+	Lotacao string
+	// Unidade é zero quando o usuário não tem unidade
+	Unidade int
+}
+
+// idUnidade devolve a unidade como gravada na requisição
+func (r Responsavel) idUnidade() *int {
+	if r.Unidade == 0 {
+		return nil
+	}
+	unidade := r.Unidade
+	return &unidade
+}
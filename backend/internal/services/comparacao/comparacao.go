@@ -0,0 +1,238 @@
+// Package comparacao calcula o que mudou entre duas consultas do mesmo alvo:
+// chaves PIX e eventos de vínculo, ou relacionamentos CCS e seus BDVs.
+package comparacao
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+// Tipos de item comparados
+const (
+	TipoChave          = "chave"
+	TipoEvento         = "evento"
+	TipoRelacionamento = "relacionamento"
+	TipoBDV            = "bdv"
+)
+
+// campo extrai de um item o valor comparado, já formatado para exibição
+type campo[T any] struct {
+	nome  string
+	valor func(T) string
+}
+
+func data(d *time.Time) string {
+	if d == nil {
+		return ""
+	}
+	return d.Format("02/01/2006")
+}
+
+var camposChave = []campo[models.ChavePix]{
+	{"tipoChave", func(c models.ChavePix) string { return c.TipoChave }},
+	{"status", func(c models.ChavePix) string { return c.Status }},
+	{"cpfCnpj", func(c models.ChavePix) string { return c.CPFCNPJ }},
+	{"nomeProprietario", func(c models.ChavePix) string { return c.NomeProprietario }},
+	{"nomeFantasia", func(c models.ChavePix) string { return c.NomeFantasia }},
+	{"participante", func(c models.ChavePix) string { return c.Participante }},
+	{"nomeBanco", func(c models.ChavePix) string { return c.NomeBanco }},
+	{"agencia", func(c models.ChavePix) string { return c.Agencia }},
+	{"numeroConta", func(c models.ChavePix) string { return c.NumeroConta }},
+	{"tipoConta", func(c models.ChavePix) string { return c.TipoConta }},
+	{"dataAberturaConta", func(c models.ChavePix) string { return data(c.DataAberturaConta) }},
+	{"proprietarioDaChaveDesde", func(c models.ChavePix) string { return data(c.ProprietarioDaChaveDesde) }},
+	{"dataAberturaReivindicacao", func(c models.ChavePix) string { return data(c.DataAberturaReivindicacao) }},
+	{"ultimaModificacao", func(c models.ChavePix) string { return data(c.UltimaModificacao) }},
+}
+
+var camposRelacionamento = []campo[models.RelacionamentoCCS]{
+	{"nomePessoa", func(r models.RelacionamentoCCS) string { return r.NomePessoa }},
+	{"nomeBancoResponsavel", func(r models.RelacionamentoCCS) string { return r.NomeBancoResponsavel }},
+	{"nomeBancoParticipante", func(r models.RelacionamentoCCS) string { return r.NomeBancoParticipante }},
+	{"dataFimRelacionamento", func(r models.RelacionamentoCCS) string { return data(r.DataFimRelacionamento) }},
+}
+
+var camposBDV = []campo[models.BemDireitoValorCCS]{
+	{"nomePessoa", func(b models.BemDireitoValorCCS) string { return b.NomePessoa }},
+	{"dataInicio", func(b models.BemDireitoValorCCS) string { return data(b.DataInicio) }},
+	{"dataFim", func(b models.BemDireitoValorCCS) string { return data(b.DataFim) }},
+}
+
+func compararCampos[T any](campos []campo[T], anterior, atual T) []models.AlteracaoCampo {
+	var alteracoes []models.AlteracaoCampo
+	for _, c := range campos {
+		a, b := c.valor(anterior), c.valor(atual)
+		if strings.TrimSpace(a) != strings.TrimSpace(b) {
+			alteracoes = append(alteracoes, models.AlteracaoCampo{Campo: c.nome, Anterior: a, Atual: b})
+		}
+	}
+	return alteracoes
+}
+
+// indexar agrupa os itens pela identidade, preservando a ordem de chegada.
+// Identidades repetidas recebem sufixo para não se sobreporem.
+func indexar[T any](itens []T, identidade func(T) string) ([]string, map[string]T) {
+	ordem := make([]string, 0, len(itens))
+	porID := make(map[string]T, len(itens))
+	for _, item := range itens {
+		id := identidade(item)
+		for n := 2; ; n++ {
+			if _, existe := porID[id]; !existe {
+				break
+			}
+			id = identidade(item) + " #" + strconv.Itoa(n)
+		}
+		ordem = append(ordem, id)
+		porID[id] = item
+	}
+	return ordem, porID
+}
+
+// comparador acumula os itens da comparação
+type comparador struct {
+	resultado *models.ComparacaoRequisicoes
+}
+
+func (c *comparador) adicionar(situacao, tipo, identificador string, campos []models.AlteracaoCampo) {
+	item := models.ItemComparacao{Tipo: tipo, Identificador: identificador, Situacao: situacao, Campos: campos}
+	switch situacao {
+	case models.SituacaoAdicionado:
+		c.resultado.Adicionados = append(c.resultado.Adicionados, item)
+	case models.SituacaoRemovido:
+		c.resultado.Removidos = append(c.resultado.Removidos, item)
+	default:
+		c.resultado.Modificados = append(c.resultado.Modificados, item)
+	}
+}
+
+// comparar percorre as duas listas pela identidade e chama aoManter para os
+// itens presentes nas duas
+func comparar[T any](c *comparador, tipo, prefixo string, anteriores, atuais []T, identidade func(T) string, campos []campo[T], aoManter func(id string, anterior, atual T)) {
+	ordemAnterior, porIDAnterior := indexar(anteriores, identidade)
+	ordemAtual, porIDAtual := indexar(atuais, identidade)
+
+	for _, id := range ordemAtual {
+		atual := porIDAtual[id]
+		anterior, existia := porIDAnterior[id]
+		if !existia {
+			c.adicionar(models.SituacaoAdicionado, tipo, prefixo+id, nil)
+			continue
+		}
+		if alteracoes := compararCampos(campos, anterior, atual); len(alteracoes) > 0 {
+			c.adicionar(models.SituacaoModificado, tipo, prefixo+id, alteracoes)
+		}
+		if aoManter != nil {
+			aoManter(id, anterior, atual)
+		}
+	}
+	for _, id := range ordemAnterior {
+		if _, existe := porIDAtual[id]; !existe {
+			c.adicionar(models.SituacaoRemovido, tipo, prefixo+id, nil)
+		}
+	}
+}
+
+func identidadeEvento(e models.EventoChavePix) string {
+	partes := []string{e.TipoEvento, e.MotivoEvento}
+	if e.DataEvento != nil {
+		partes = append(partes, e.DataEvento.Format(time.RFC3339))
+	}
+	return strings.Join(partes, " ")
+}
+
+func identidadeRelacionamento(r models.RelacionamentoCCS) string {
+	return strings.Join([]string{r.CNPJParticipante, r.CNPJResponsavel, data(r.DataInicioRelacionamento)}, " ")
+}
+
+func identidadeBDV(b models.BemDireitoValorCCS) string {
+	return strings.Join([]string{b.Tipo, b.Agencia, b.Conta, b.Vinculo}, " ")
+}
+
+func novaComparacao(origem, alvo string, idAnterior int, dataAnterior time.Time, idAtual int, dataAtual time.Time) *models.ComparacaoRequisicoes {
+	return &models.ComparacaoRequisicoes{
+		Origem:       origem,
+		Alvo:         alvo,
+		IDAnterior:   idAnterior,
+		DataAnterior: dataAnterior,
+		IDAtual:      idAtual,
+		DataAtual:    dataAtual,
+		Adicionados:  []models.ItemComparacao{},
+		Removidos:    []models.ItemComparacao{},
+		Modificados:  []models.ItemComparacao{},
+	}
+}
+
+// CompararPix compara as chaves e eventos de vínculo de duas requisições PIX.
+// Chaves são identificadas pelo valor da chave; eventos, por tipo, motivo e data.
+func CompararPix(anterior, atual *models.RequisicaoPix) *models.ComparacaoRequisicoes {
+	resultado := novaComparacao("pix", atual.ChaveBusca, anterior.ID, anterior.Data, atual.ID, atual.Data)
+	c := &comparador{resultado: resultado}
+
+	comparar(c, TipoChave, "", anterior.Chaves, atual.Chaves,
+		func(ch models.ChavePix) string { return ch.Chave }, camposChave,
+		func(id string, a, b models.ChavePix) {
+			comparar(c, TipoEvento, id+" / ", a.EventosVinculo, b.EventosVinculo, identidadeEvento, nil, nil)
+		})
+
+	ordenar(resultado)
+	return resultado
+}
+
+// CompararCCS compara os relacionamentos e BDVs de duas requisições CCS.
+// Relacionamentos são identificados pelos CNPJs da instituição e pela data de
+// início; BDVs, por tipo, agência, conta e vínculo.
+func CompararCCS(anterior, atual *models.RequisicaoRelacionamentoCCS) *models.ComparacaoRequisicoes {
+	resultado := novaComparacao("ccs", atual.CPFCNPJConsulta, anterior.ID, anterior.DataRequisicao, atual.ID, atual.DataRequisicao)
+	c := &comparador{resultado: resultado}
+
+	comparar(c, TipoRelacionamento, "", anterior.RelacionamentosCCS, atual.RelacionamentosCCS,
+		identidadeRelacionamento, camposRelacionamento,
+		func(id string, a, b models.RelacionamentoCCS) {
+			// BDVs só existem depois do detalhamento; sem eles nos dois lados não há o que comparar
+			if len(a.BemDireitoValorCCS) == 0 || len(b.BemDireitoValorCCS) == 0 {
+				return
+			}
+			comparar(c, TipoBDV, id+" / ", a.BemDireitoValorCCS, b.BemDireitoValorCCS, identidadeBDV, camposBDV, nil)
+		})
+
+	ordenar(resultado)
+	return resultado
+}
+
+// ordenar agrupa cada lista por tipo, mantendo a ordem original dentro do tipo
+func ordenar(c *models.ComparacaoRequisicoes) {
+	prioridade := map[string]int{TipoChave: 0, TipoRelacionamento: 0, TipoEvento: 1, TipoBDV: 1}
+	for _, lista := range [][]models.ItemComparacao{c.Adicionados, c.Removidos, c.Modificados} {
+		sort.SliceStable(lista, func(i, j int) bool {
+			return prioridade[lista[i].Tipo] < prioridade[lista[j].Tipo]
+		})
+	}
+}
+
+// EscreverCSV grava a comparação em CSV, uma linha por campo alterado, para
+// anexar a relatórios
+func EscreverCSV(w io.Writer, c *models.ComparacaoRequisicoes) error {
+	csvw := csv.NewWriter(w)
+	csvw.Write([]string{"situacao", "tipo", "item", "campo", "anterior", "atual"})
+
+	for _, lista := range [][]models.ItemComparacao{c.Adicionados, c.Removidos, c.Modificados} {
+		for _, item := range lista {
+			if len(item.Campos) == 0 {
+				csvw.Write([]string{item.Situacao, item.Tipo, item.Identificador, "", "", ""})
+				continue
+			}
+			for _, campo := range item.Campos {
+				csvw.Write([]string{item.Situacao, item.Tipo, item.Identificador, campo.Campo, campo.Anterior, campo.Atual})
+			}
+		}
+	}
+
+	csvw.Flush()
+	return csvw.Error()
+}
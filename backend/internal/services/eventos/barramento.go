@@ -0,0 +1,154 @@
+package eventos
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// canalPostgres é o canal de NOTIFY usado pelos gatilhos da migração 0013
+const canalPostgres = "consultapix_eventos"
+
+// tamanhoFilaAssinante é quantos eventos um cliente lento pode acumular antes
+// que os seguintes sejam descartados
+const tamanhoFilaAssinante = 32
+
+// Tipos de evento do stream
+const (
+	TipoRelacionamentoCCS = "relacionamento_ccs"
+	TipoNotificacao       = "notificacao"
+	TipoMonitoramento     = "monitoramento"
+	// TipoSincronizar avisa que eventos podem ter sido perdidos (reconexão com
+	// o banco ou fila cheia) e que o cliente deve recarregar o que exibe
+	TipoSincronizar = "sincronizar"
+)
+
+// Evento é uma mensagem enviada ao usuário pelo stream
+type Evento struct {
+	Tipo  string          `json:"tipo"`
+	Dados json.RawMessage `json:"dados"`
+}
+
+// mensagem é o payload publicado pelos gatilhos
+type mensagem struct {
+	Destinatarios []string        `json:"destinatarios"`
+	Tipo          string          `json:"tipo"`
+	Dados         json.RawMessage `json:"dados"`
+}
+
+// Barramento repassa os eventos do PostgreSQL às conexões abertas de cada usuário
+type Barramento struct {
+	mu         sync.Mutex
+	assinantes map[string]map[chan Evento]struct{}
+}
+
+var barramento = &Barramento{assinantes: make(map[string]map[chan Evento]struct{})}
+
+// Padrao retorna o barramento único do processo
+func Padrao() *Barramento {
+	return barramento
+}
+
+// Assinar registra uma conexão do usuário. A função retornada cancela a
+// assinatura e deve ser chamada quando a conexão terminar.
+func (b *Barramento) Assinar(cpf string) (<-chan Evento, func()) {
+	ch := make(chan Evento, tamanhoFilaAssinante)
+
+	b.mu.Lock()
+	if b.assinantes[cpf] == nil {
+		b.assinantes[cpf] = make(map[chan Evento]struct{})
+	}
+	b.assinantes[cpf][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.assinantes[cpf], ch)
+		if len(b.assinantes[cpf]) == 0 {
+			delete(b.assinantes, cpf)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// Escutar recebe os eventos do canal do PostgreSQL e os entrega aos
+// assinantes. Bloqueia; a reconexão com o banco é automática.
+func (b *Barramento) Escutar(databaseURL string) error {
+	listener := pq.NewListener(databaseURL, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Stream de eventos: %v", err)
+		}
+	})
+	if err := listener.Listen(canalPostgres); err != nil {
+		listener.Close()
+		return err
+	}
+
+	for {
+		select {
+		case n := <-listener.Notify:
+			if n == nil {
+				// A conexão caiu e foi refeita; o que foi publicado nesse
+				// intervalo se perdeu
+				b.difundir(Evento{Tipo: TipoSincronizar, Dados: json.RawMessage("{}")})
+				continue
+			}
+			b.despachar(n.Extra)
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+	}
+}
+
+func (b *Barramento) despachar(payload string) {
+	var m mensagem
+	if err := json.Unmarshal([]byte(payload), &m); err != nil {
+		log.Printf("Stream de eventos: payload inválido: %v", err)
+		return
+	}
+
+	evento := Evento{Tipo: m.Tipo, Dados: m.Dados}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, cpf := range m.Destinatarios {
+		for ch := range b.assinantes[cpf] {
+			entregar(ch, evento)
+		}
+	}
+}
+
+func (b *Barramento) difundir(evento Evento) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, canais := range b.assinantes {
+		for ch := range canais {
+			entregar(ch, evento)
+		}
+	}
+}
+
+// entregar não bloqueia. Com a fila cheia o cliente está atrasado: a fila é
+// descartada e substituída por TipoSincronizar, para que ele recarregue tudo.
+// Só o barramento envia nos canais, sob b.mu, então após esvaziar há espaço.
+func entregar(ch chan Evento, evento Evento) {
+	select {
+	case ch <- evento:
+		return
+	default:
+	}
+
+	log.Printf("Stream de eventos: assinante atrasado, %d eventos descartados", len(ch))
+	for len(ch) > 0 {
+		select {
+		case <-ch:
+		default:
+		}
+	}
+	select {
+	case ch <- Evento{Tipo: TipoSincronizar, Dados: json.RawMessage("{}")}:
+	default:
+	}
+}
@@ -0,0 +1,71 @@
+package notificacao
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+// canalEmail envia a notificação por SMTP. Sem usuário configurado o envio é
+// feito sem autenticação, o que permite usar um servidor local de testes
+// como o MailHog.
+type canalEmail struct {
+	host      string
+	endereco  string
+	usuario   string
+	senha     string
+	remetente string
+}
+
+func novoCanalEmail(cfg *config.Config) *canalEmail {
+	return &canalEmail{
+		host:      cfg.SMTPHost,
+		endereco:  net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+		usuario:   cfg.SMTPUsuario,
+		senha:     cfg.SMTPSenha,
+		remetente: cfg.SMTPRemetente,
+	}
+}
+
+func (c *canalEmail) Enviar(n *models.Notificacao, usuario *models.Usuario) error {
+	if usuario == nil || usuario.Email == "" {
+		return errors.New("usuário sem e-mail cadastrado")
+	}
+
+	var auth smtp.Auth
+	if c.usuario != "" {
+		auth = smtp.PlainAuth("", c.usuario, c.senha, c.host)
+	}
+	return smtp.SendMail(c.endereco, auth, c.remetente, []string{usuario.Email}, c.montarMensagem(n, usuario))
+}
+
+// EnviarEmail envia uma mensagem avulsa, fora das preferências de
+// notificação, como os avisos do autocadastro
+func EnviarEmail(cfg *config.Config, usuario *models.Usuario, assunto, mensagem string) error {
+	if cfg.SMTPHost == "" {
+		return errors.New("SMTP_HOST não configurado")
+	}
+	return novoCanalEmail(cfg).Enviar(&models.Notificacao{Titulo: assunto, Mensagem: mensagem}, usuario)
+}
+
+// montarMensagem gera a mensagem em texto puro. O assunto passa por
+// codificação MIME, o que também impede quebras de linha vindas do título.
+func (c *canalEmail) montarMensagem(n *models.Notificacao, usuario *models.Usuario) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", c.remetente)
+	fmt.Fprintf(&msg, "To: %s\r\n", usuario.Email)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "[ConsultaPix] "+n.Titulo))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	fmt.Fprintf(&msg, "Olá, %s.\r\n\r\n%s\r\n", usuario.Nome, n.Mensagem)
+	return msg.Bytes()
+}
@@ -0,0 +1,140 @@
+package notificacao
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+	"github.com/tassyosilva/consultapix/internal/repository"
+)
+
+// percentualAvisoCota é a fração do limite a partir da qual o usuário é avisado
+const percentualAvisoCota = 0.8
+
+// Canal entrega uma notificação ao usuário por um meio específico
+type Canal interface {
+	Enviar(n *models.Notificacao, usuario *models.Usuario) error
+}
+
+// NotificacaoService distribui as notificações pelos canais que o usuário
+// escolheu para cada evento. A caixa de entrada é gravada antes de retornar;
+// e-mail e webhook são enviados em segundo plano para não atrasar a consulta
+// que originou o evento.
+type NotificacaoService struct {
+	notificacoes *repository.NotificacaoRepository
+	usuarios     *repository.UserRepository
+	canais       map[string]Canal
+}
+
+func NewNotificacaoService(cfg *config.Config) *NotificacaoService {
+	notificacoes := repository.NewNotificacaoRepository()
+	canais := map[string]Canal{
+		repository.CanalInbox: &canalInbox{notificacoes: notificacoes},
+	}
+	if cfg.SMTPHost != "" {
+		canais[repository.CanalEmail] = novoCanalEmail(cfg)
+	}
+	if cfg.WebhookNotificacaoURL != "" {
+		canais[repository.CanalWebhook] = novoCanalWebhook(cfg)
+	}
+
+	return &NotificacaoService{
+		notificacoes: notificacoes,
+		usuarios:     repository.NewUserRepository(),
+		canais:       canais,
+	}
+}
+
+// Notificar entrega a notificação nos canais configurados que o destinatário
+// mantém ativos para o tipo do evento
+func (s *NotificacaoService) Notificar(n *models.Notificacao) {
+	preferencias, err := s.notificacoes.PreferenciasEvento(n.CPFUsuario, n.Tipo)
+	if err != nil {
+		// Sem as preferências vale o padrão, que já vem preenchido
+		log.Printf("Erro ao ler preferências de notificação de %s: %v", n.CPFUsuario, err)
+	}
+
+	if preferencias[repository.CanalInbox] {
+		if err := s.canais[repository.CanalInbox].Enviar(n, nil); err != nil {
+			log.Printf("Erro ao notificar %s (%s) pela caixa de entrada: %v", n.CPFUsuario, n.Tipo, err)
+		}
+	}
+
+	var externos []string
+	for _, canal := range repository.CanaisNotificacao {
+		if canal != repository.CanalInbox && preferencias[canal] && s.canais[canal] != nil {
+			externos = append(externos, canal)
+		}
+	}
+	if len(externos) == 0 {
+		return
+	}
+
+	go func() {
+		usuario, err := s.usuarios.FindByCPF(n.CPFUsuario)
+		if err != nil {
+			log.Printf("Erro ao buscar o destinatário %s da notificação: %v", n.CPFUsuario, err)
+			return
+		}
+		for _, canal := range externos {
+			if err := s.canais[canal].Enviar(n, usuario); err != nil {
+				log.Printf("Erro ao notificar %s (%s) por %s: %v", n.CPFUsuario, n.Tipo, canal, err)
+			}
+		}
+	}()
+}
+
+// AvisarCotaProxima notifica o usuário quando a consulta que acabou de ser
+// contada fez o uso cruzar o limiar de aviso. Só a consulta que cruza avisa,
+// para não repetir a notificação a cada consulta seguinte.
+func (s *NotificacaoService) AvisarCotaProxima(cpf string, usos []models.UsoCota) {
+	for _, uso := range usos {
+		periodos := []struct {
+			nome   string
+			uso    int
+			limite *int
+		}{
+			{"diário", uso.UsoDiario, uso.LimiteDiario},
+			{"mensal", uso.UsoMensal, uso.LimiteMensal},
+		}
+		for _, p := range periodos {
+			if p.limite == nil || *p.limite <= 0 {
+				continue
+			}
+			limiar := int(math.Ceil(float64(*p.limite) * percentualAvisoCota))
+			if p.uso < limiar || p.uso-1 >= limiar {
+				continue
+			}
+
+			dono := "Sua cota"
+			if uso.Escopo == repository.EscopoCotaLotacao {
+				dono = fmt.Sprintf("A cota da lotação %s", uso.Alvo)
+			}
+			s.Notificar(&models.Notificacao{
+				CPFUsuario: cpf,
+				Tipo:       repository.NotificacaoCotaProximaDoLimite,
+				Titulo:     "Cota de consultas próxima do limite",
+				Mensagem:   fmt.Sprintf("%s de %s já usou %d das %d consultas do limite %s.", dono, uso.Operacao, p.uso, *p.limite, p.nome),
+				Dados: map[string]interface{}{
+					"escopo":   uso.Escopo,
+					"alvo":     uso.Alvo,
+					"operacao": uso.Operacao,
+					"periodo":  p.nome,
+					"uso":      p.uso,
+					"limite":   *p.limite,
+				},
+			})
+		}
+	}
+}
+
+// canalInbox grava a notificação na caixa de entrada do usuário
+type canalInbox struct {
+	notificacoes *repository.NotificacaoRepository
+}
+
+func (c *canalInbox) Enviar(n *models.Notificacao, _ *models.Usuario) error {
+	return c.notificacoes.Criar(n)
+}
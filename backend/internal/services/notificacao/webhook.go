@@ -0,0 +1,90 @@
+package notificacao
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tassyosilva/consultapix/internal/config"
+	"github.com/tassyosilva/consultapix/internal/database/models"
+)
+
+// tentativasWebhook é o número de envios antes de desistir da notificação
+const tentativasWebhook = 3
+
+// Cabeçalhos enviados no webhook
+const (
+	CabecalhoEvento     = "X-ConsultaPix-Evento"
+	CabecalhoTimestamp  = "X-ConsultaPix-Timestamp"
+	CabecalhoAssinatura = "X-ConsultaPix-Assinatura"
+)
+
+// canalWebhook envia a notificação em JSON para a URL configurada
+type canalWebhook struct {
+	url     string
+	segredo []byte
+	client  *http.Client
+}
+
+func novoCanalWebhook(cfg *config.Config) *canalWebhook {
+	return &canalWebhook{
+		url:     cfg.WebhookNotificacaoURL,
+		segredo: []byte(cfg.WebhookNotificacaoSegredo),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AssinarWebhook calcula a assinatura enviada em X-ConsultaPix-Assinatura:
+// HMAC-SHA256 de "<timestamp>.<corpo>" com o segredo compartilhado. O
+// receptor deve recalculá-la e rejeitar timestamps antigos.
+func AssinarWebhook(segredo []byte, timestamp string, corpo []byte) string {
+	mac := hmac.New(sha256.New, segredo)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(corpo)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c *canalWebhook) Enviar(n *models.Notificacao, _ *models.Usuario) error {
+	corpo, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	for tentativa := 1; ; tentativa++ {
+		err = c.enviar(n.Tipo, corpo)
+		if err == nil || tentativa == tentativasWebhook {
+			return err
+		}
+		time.Sleep(time.Duration(tentativa) * 2 * time.Second)
+	}
+}
+
+func (c *canalWebhook) enviar(evento string, corpo []byte) error {
+	req, err := http.NewRequest("POST", c.url, bytes.NewReader(corpo))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(CabecalhoEvento, evento)
+	req.Header.Set(CabecalhoTimestamp, timestamp)
+	req.Header.Set(CabecalhoAssinatura, AssinarWebhook(c.segredo, timestamp, corpo))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook respondeu %d", resp.StatusCode)
+	}
+	return nil
+}
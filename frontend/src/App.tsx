@@ -9,6 +9,10 @@ import Login from './pages/auth/Login';
 import Dashboard from './pages/dashboard/Dashboard';
 import NovoPix from './pages/pix/NovoPix';
 import ListaPix from './pages/pix/ListaPix';
+import DoisFatores from './pages/auth/DoisFatores';
+import LoginSSO from './pages/auth/LoginSSO';
+import RedefinirSenha from './pages/auth/RedefinirSenha';
+import AlterarSenha from './pages/auth/AlterarSenha';
 
 // Componente de rota protegida
 const PrivateRoute = ({ children }: { children: React.ReactNode }) => {
@@ -26,6 +30,8 @@ const AppContent = () => {
   return (
     <Routes>
       <Route path="/" element={<Login />} />
+      <Route path="/login/sso" element={<LoginSSO />} />
+      <Route path="/redefinir-senha" element={<RedefinirSenha />} />
       <Route
         path="/dashboard"
         element={
@@ -50,6 +56,22 @@ const AppContent = () => {
           </PrivateRoute>
         }
       />
+      <Route
+        path="/seguranca"
+        element={
+          <PrivateRoute>
+            <DoisFatores />
+          </PrivateRoute>
+        }
+      />
+      <Route
+        path="/senha"
+        element={
+          <PrivateRoute>
+            <AlterarSenha />
+          </PrivateRoute>
+        }
+      />
     </Routes>
   );
 };
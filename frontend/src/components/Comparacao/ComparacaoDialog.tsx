@@ -0,0 +1,169 @@
+// src/components/Comparacao/ComparacaoDialog.tsx
+import React, { useEffect, useState } from 'react';
+import {
+    Box,
+    Button,
+    Chip,
+    CircularProgress,
+    Dialog,
+    DialogActions,
+    DialogContent,
+    DialogTitle,
+    Table,
+    TableBody,
+    TableCell,
+    TableHead,
+    TableRow,
+    Typography,
+} from '@mui/material';
+import api from '../../services/api';
+
+interface AlteracaoCampo {
+    campo: string;
+    anterior: string;
+    atual: string;
+}
+
+interface ItemComparacao {
+    tipo: string;
+    identificador: string;
+    situacao: 'adicionado' | 'removido' | 'modificado';
+    campos?: AlteracaoCampo[];
+}
+
+interface Comparacao {
+    alvo: string;
+    idAnterior: number;
+    dataAnterior: string;
+    idAtual: number;
+    dataAtual: string;
+    adicionados: ItemComparacao[];
+    removidos: ItemComparacao[];
+    modificados: ItemComparacao[];
+}
+
+interface ComparacaoDialogProps {
+    // Origem da requisição: pix ou ccs
+    origem: 'pix' | 'ccs';
+    // Requisição a comparar com a consulta anterior do mesmo alvo; null fecha o diálogo
+    idRequisicao: number | null;
+    onClose: () => void;
+}
+
+const coresSituacao = {
+    adicionado: 'success',
+    removido: 'error',
+    modificado: 'warning',
+} as const;
+
+const ComparacaoDialog: React.FC<ComparacaoDialogProps> = ({ origem, idRequisicao, onClose }) => {
+    const [loading, setLoading] = useState(false);
+    const [erro, setErro] = useState('');
+    const [comparacao, setComparacao] = useState<Comparacao | null>(null);
+
+    useEffect(() => {
+        if (idRequisicao === null) {
+            return;
+        }
+
+        const fetchComparacao = async () => {
+            setLoading(true);
+            setErro('');
+            setComparacao(null);
+            try {
+                const response = await api.get(`/api/bacen/${origem}/historico/${idRequisicao}/comparacao`);
+                setComparacao(response.data);
+            } catch (error: any) {
+                setErro(error.response?.data || 'Erro ao comparar consultas');
+            } finally {
+                setLoading(false);
+            }
+        };
+
+        fetchComparacao();
+    }, [origem, idRequisicao]);
+
+    const handleExportarCSV = async () => {
+        const response = await api.get(`/api/bacen/${origem}/historico/${idRequisicao}/comparacao`, {
+            params: { formato: 'csv' },
+            responseType: 'blob',
+        });
+        const url = URL.createObjectURL(response.data);
+        const link = document.createElement('a');
+        link.href = url;
+        link.download = `comparacao-${origem}-${comparacao?.idAnterior}-${comparacao?.idAtual}.csv`;
+        link.click();
+        URL.revokeObjectURL(url);
+    };
+
+    const itens = comparacao
+        ? [...comparacao.adicionados, ...comparacao.removidos, ...comparacao.modificados]
+        : [];
+
+    return (
+        <Dialog open={idRequisicao !== null} onClose={onClose} maxWidth="md" fullWidth>
+            <DialogTitle>Alterações desde a consulta anterior</DialogTitle>
+            <DialogContent>
+                {loading && (
+                    <Box sx={{ display: 'flex', justifyContent: 'center', p: 2 }}>
+                        <CircularProgress />
+                    </Box>
+                )}
+
+                {erro && <Typography color="error">{erro}</Typography>}
+
+                {comparacao && (
+                    <>
+                        <Typography variant="body2" gutterBottom>
+                            {comparacao.alvo}: {new Date(comparacao.dataAnterior).toLocaleString()} →{' '}
+                            {new Date(comparacao.dataAtual).toLocaleString()}
+                        </Typography>
+
+                        {itens.length === 0 ? (
+                            <Typography>Nenhuma alteração encontrada</Typography>
+                        ) : (
+                            <Table size="small">
+                                <TableHead>
+                                    <TableRow>
+                                        <TableCell>Situação</TableCell>
+                                        <TableCell>Tipo</TableCell>
+                                        <TableCell>Item</TableCell>
+                                        <TableCell>Campos alterados</TableCell>
+                                    </TableRow>
+                                </TableHead>
+                                <TableBody>
+                                    {itens.map((item, index) => (
+                                        <TableRow key={index}>
+                                            <TableCell>
+                                                <Chip
+                                                    size="small"
+                                                    label={item.situacao}
+                                                    color={coresSituacao[item.situacao]}
+                                                />
+                                            </TableCell>
+                                            <TableCell>{item.tipo}</TableCell>
+                                            <TableCell>{item.identificador}</TableCell>
+                                            <TableCell>
+                                                {item.campos?.map((c) => (
+                                                    <div key={c.campo}>
+                                                        <strong>{c.campo}</strong>: {c.anterior || '—'} → {c.atual || '—'}
+                                                    </div>
+                                                ))}
+                                            </TableCell>
+                                        </TableRow>
+                                    ))}
+                                </TableBody>
+                            </Table>
+                        )}
+                    </>
+                )}
+            </DialogContent>
+            <DialogActions>
+                {comparacao && <Button onClick={handleExportarCSV}>Exportar CSV</Button>}
+                <Button onClick={onClose}>Fechar</Button>
+            </DialogActions>
+        </Dialog>
+    );
+};
+
+export default ComparacaoDialog;
@@ -1,9 +1,11 @@
-import React from 'react';
+import React, { useCallback, useEffect, useState } from 'react';
 import { AppBar, Toolbar, Typography, IconButton, Badge } from '@mui/material';
 import MenuIcon from '@mui/icons-material/Menu';
 import NotificationsIcon from '@mui/icons-material/Notifications';
 import AccountCircleIcon from '@mui/icons-material/AccountCircle';
 import { useAuth } from '../../context/AuthContext';
+import api from '../../services/api';
+import { assinarEventos } from '../../services/eventos';
 
 interface HeaderProps {
     onDrawerToggle?: () => void;
@@ -11,6 +13,25 @@ interface HeaderProps {
 
 const Header: React.FC<HeaderProps> = ({ onDrawerToggle }) => {
     const { signOut } = useAuth();
+    const [naoLidas, setNaoLidas] = useState(0);
+
+    const carregarNaoLidas = useCallback(async () => {
+        try {
+            const response = await api.get('/api/notificacoes', { params: { naoLidas: true } });
+            setNaoLidas(response.data.length);
+        } catch (error) {
+            console.error('Erro ao carregar notificações:', error);
+        }
+    }, []);
+
+    // Atualiza o contador quando o stream avisa de uma nova notificação
+    useEffect(() => {
+        carregarNaoLidas();
+        return assinarEventos({
+            notificacao: () => setNaoLidas((total) => total + 1),
+            sincronizar: carregarNaoLidas,
+        });
+    }, [carregarNaoLidas]);
 
     return (
         <AppBar position="static">
@@ -28,7 +49,7 @@ const Header: React.FC<HeaderProps> = ({ onDrawerToggle }) => {
                     Consulta PIX/CCS
                 </Typography>
                 <IconButton color="inherit">
-                    <Badge badgeContent={4} color="secondary">
+                    <Badge badgeContent={naoLidas} color="secondary">
                         <NotificationsIcon />
                     </Badge>
                 </IconButton>
@@ -13,6 +13,8 @@ import {
 import DashboardIcon from '@mui/icons-material/Dashboard';
 import SearchIcon from '@mui/icons-material/Search';
 import PeopleIcon from '@mui/icons-material/People';
+import SecurityIcon from '@mui/icons-material/Security';
+import KeyIcon from '@mui/icons-material/Key';
 import { Link } from 'react-router-dom';
 import { useAuth } from '../../context/AuthContext';
 
@@ -41,17 +43,34 @@ const Sidebar: React.FC = () => {
                     </ListItemIcon>
                     <ListItemText primary="Dashboard" />
                 </ListItemButton>
-                <ListItemButton component={Link} to="/pix/novo">
+                {/* O auditor só lê: as consultas ao BACEN ficam ocultas */}
+                {(user?.admin || user?.perfil !== 'auditor') && (
+                    <>
+                        <ListItemButton component={Link} to="/pix/novo">
+                            <ListItemIcon>
+                                <SearchIcon />
+                            </ListItemIcon>
+                            <ListItemText primary="Consulta PIX" />
+                        </ListItemButton>
+                        <ListItemButton component={Link} to="/ccs/novo">
+                            <ListItemIcon>
+                                <SearchIcon />
+                            </ListItemIcon>
+                            <ListItemText primary="Consulta CCS" />
+                        </ListItemButton>
+                    </>
+                )}
+                <ListItemButton component={Link} to="/seguranca">
                     <ListItemIcon>
-                        <SearchIcon />
+                        <SecurityIcon />
                     </ListItemIcon>
-                    <ListItemText primary="Consulta PIX" />
+                    <ListItemText primary="Segurança" />
                 </ListItemButton>
-                <ListItemButton component={Link} to="/ccs/novo">
+                <ListItemButton component={Link} to="/senha">
                     <ListItemIcon>
-                        <SearchIcon />
+                        <KeyIcon />
                     </ListItemIcon>
-                    <ListItemText primary="Consulta CCS" />
+                    <ListItemText primary="Alterar senha" />
                 </ListItemButton>
                 {user?.admin && (
                     <ListItemButton component={Link} to="/user">
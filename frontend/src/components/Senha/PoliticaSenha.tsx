@@ -0,0 +1,45 @@
+import React, { useEffect, useState } from 'react';
+import { Typography } from '@mui/material';
+import api from '../../services/api';
+
+interface Politica {
+    tamanhoMinimo: number;
+    classesMinimas: number;
+    historico: number;
+    validadeDias: number;
+}
+
+// Resume as exigências da política de senhas configurada na API
+const PoliticaSenha: React.FC = () => {
+    const [politica, setPolitica] = useState<Politica | null>(null);
+
+    useEffect(() => {
+        api.get('/api/user/senha/politica')
+            .then((response) => setPolitica(response.data))
+            .catch(() => setPolitica(null));
+    }, []);
+
+    if (!politica) {
+        return null;
+    }
+
+    let texto = `A senha deve ter pelo menos ${politica.tamanhoMinimo} caracteres`;
+    if (politica.classesMinimas > 1) {
+        texto += `, combinando ${politica.classesMinimas} tipos entre minúsculas, maiúsculas, números e símbolos`;
+    }
+    texto += ', e não pode conter o CPF nem o e-mail.';
+    if (politica.historico > 0) {
+        texto += ` Não repita as últimas ${politica.historico} senhas.`;
+    }
+    if (politica.validadeDias > 0) {
+        texto += ` A senha expira em ${politica.validadeDias} dias.`;
+    }
+
+    return (
+        <Typography variant="body2" color="text.secondary" sx={{ mt: 1 }}>
+            {texto}
+        </Typography>
+    );
+};
+
+export default PoliticaSenha;
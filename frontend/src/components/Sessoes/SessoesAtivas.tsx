@@ -0,0 +1,82 @@
+import React, { useEffect, useState } from 'react';
+import {
+    Alert,
+    Button,
+    List,
+    ListItem,
+    ListItemText,
+    Typography,
+} from '@mui/material';
+import api from '../../services/api';
+
+interface Sessao {
+    id: number;
+    ip: string;
+    agenteUsuario: string;
+    criadaEm: string;
+    ultimaAtividade: string;
+    atual: boolean;
+}
+
+const formatarData = (data: string) => new Date(data).toLocaleString('pt-BR');
+
+// Lista as sessões abertas do usuário e permite encerrar as de outros dispositivos
+const SessoesAtivas: React.FC = () => {
+    const [sessoes, setSessoes] = useState<Sessao[]>([]);
+    const [error, setError] = useState('');
+
+    const carregar = async () => {
+        try {
+            const response = await api.get('/api/user/sessoes');
+            setSessoes(response.data);
+        } catch (err) {
+            setError('Erro ao consultar as sessões');
+        }
+    };
+
+    useEffect(() => {
+        carregar();
+    }, []);
+
+    const encerrar = async (id: number) => {
+        setError('');
+        try {
+            await api.delete(`/api/user/sessoes/${id}`);
+            await carregar();
+        } catch (err: any) {
+            setError(err.response?.data || 'Não foi possível encerrar a sessão');
+        }
+    };
+
+    return (
+        <>
+            <Typography variant="h6" gutterBottom>
+                Sessões ativas
+            </Typography>
+            {error && <Alert severity="error" sx={{ mb: 2 }}>{error}</Alert>}
+            <List dense>
+                {sessoes.map((sessao) => (
+                    <ListItem
+                        key={sessao.id}
+                        divider
+                        secondaryAction={
+                            !sessao.atual && (
+                                <Button size="small" color="error" onClick={() => encerrar(sessao.id)}>
+                                    Encerrar
+                                </Button>
+                            )
+                        }
+                    >
+                        <ListItemText
+                            primary={`${sessao.agenteUsuario || 'Navegador desconhecido'}${sessao.atual ? ' (esta sessão)' : ''}`}
+                            secondary={`IP ${sessao.ip || '-'} · aberta em ${formatarData(sessao.criadaEm)} · última atividade em ${formatarData(sessao.ultimaAtividade)}`}
+                            primaryTypographyProps={{ noWrap: true, sx: { pr: 10 } }}
+                        />
+                    </ListItem>
+                ))}
+            </List>
+        </>
+    );
+};
+
+export default SessoesAtivas;
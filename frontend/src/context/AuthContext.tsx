@@ -1,6 +1,6 @@
 import React, { createContext, useState, useContext, ReactNode } from 'react';
 import { useNavigate } from 'react-router-dom';
-import api from '../services/api';
+import api, { CHAVE_USUARIO, limparSessao } from '../services/api';
 
 interface User {
     id: number;
@@ -10,10 +10,16 @@ interface User {
     lotacao: string;
     matricula: string;
     admin: boolean;
+    // Perfil de acesso: analista, supervisor ou auditor (somente leitura)
+    perfil?: string;
+    idUnidade?: number;
+    doisFatoresAtivo?: boolean;
+    cadastroDoisFatores?: boolean;
+    // A senha expirou: a API só libera a troca
+    trocaSenha?: boolean;
 }
 
 interface AuthState {
-    token: string | null;
     user: User | null;
 }
 
@@ -21,7 +27,15 @@ interface AuthContextData {
     signed: boolean;
     user: User | null;
     loading: boolean;
+    // Preenchido quando a senha foi aceita e falta o código do segundo fator
+    desafio: string | null;
     signIn: (email: string, password: string) => Promise<void>;
+    confirmarCodigo: (codigo: string) => Promise<void>;
+    cancelarDesafio: () => void;
+    // Conclui o login único: a API já gravou a sessão em cookies ou devolveu o desafio
+    concluirLoginSSO: (desafio: string | null) => Promise<void>;
+    // Guarda a nova sessão devolvida pela troca de senha
+    concluirTrocaSenha: (payload: User) => void;
     signOut: () => void;
 }
 
@@ -33,38 +47,45 @@ interface AuthProviderProps {
 
 export const AuthProvider: React.FC<AuthProviderProps> = ({ children }) => {
     const [data, setData] = useState<AuthState>(() => {
-        const token = localStorage.getItem('@ConsultaPix:token');
-        const user = localStorage.getItem('@ConsultaPix:user');
+        // Os tokens estão em cookies HttpOnly; aqui fica só o usuário exibido
+        const user = localStorage.getItem(CHAVE_USUARIO);
 
-        if (token && user) {
-            api.defaults.headers.common['Authorization'] = `Bearer ${token}`;
-            return { token, user: JSON.parse(user) };
+        if (user) {
+            return { user: JSON.parse(user) };
         }
 
         return {} as AuthState;
     });
 
     const [loading, setLoading] = useState(false);
+    const [desafio, setDesafio] = useState<string | null>(null);
     const navigate = useNavigate();
 
+    function iniciarSessao(payload: User) {
+        localStorage.setItem(CHAVE_USUARIO, JSON.stringify(payload));
+
+        setData({ user: payload });
+        // Com a senha expirada ou o segundo fator obrigatório e não cadastrado, a
+        // API só libera a troca da senha ou o cadastro, nessa ordem
+        navigate(payload.trocaSenha ? '/senha' : payload.cadastroDoisFatores ? '/seguranca' : '/dashboard');
+    }
+
     async function signIn(email: string, password: string) {
         try {
             setLoading(true);
-            const response = await api.post('/api/user/login', { email, password });
+            const response = await api.post('/api/user/login', { email, password, cookie: true });
 
-            if (response.data.status === 409) {
+            // 409: credenciais recusadas; 429: login bloqueado por excesso de tentativas
+            if (response.data.status === 409 || response.data.status === 429) {
                 throw new Error(response.data.message);
             }
 
-            const { token, payload } = response.data;
-
-            localStorage.setItem('@ConsultaPix:token', token);
-            localStorage.setItem('@ConsultaPix:user', JSON.stringify(payload));
-
-            api.defaults.headers.common['Authorization'] = `Bearer ${token}`;
+            if (response.data.status === 202) {
+                setDesafio(response.data.desafio);
+                return;
+            }
 
-            setData({ token, user: payload });
-            navigate('/dashboard');
+            iniciarSessao(response.data.payload);
         } catch (error) {
             throw error;
         } finally {
@@ -72,9 +93,49 @@ export const AuthProvider: React.FC<AuthProviderProps> = ({ children }) => {
         }
     }
 
+    async function confirmarCodigo(codigo: string) {
+        try {
+            setLoading(true);
+            const response = await api.post('/api/user/login/2fa', { desafio, codigo, cookie: true });
+
+            if (response.data.status === 401) {
+                setDesafio(null);
+                throw new Error(response.data.message);
+            }
+            if (response.data.status !== 201) {
+                throw new Error(response.data.message);
+            }
+
+            setDesafio(null);
+            iniciarSessao(response.data.payload);
+        } finally {
+            setLoading(false);
+        }
+    }
+
+    function cancelarDesafio() {
+        setDesafio(null);
+    }
+
+    async function concluirLoginSSO(desafioSSO: string | null) {
+        if (desafioSSO) {
+            setDesafio(desafioSSO);
+            navigate('/', { replace: true });
+            return;
+        }
+
+        const response = await api.get('/api/user/sessao');
+        iniciarSessao(response.data.payload);
+    }
+
+    function concluirTrocaSenha(payload: User) {
+        iniciarSessao(payload);
+    }
+
     function signOut() {
-        localStorage.removeItem('@ConsultaPix:token');
-        localStorage.removeItem('@ConsultaPix:user');
+        // Revoga os tokens e apaga os cookies; a sessão local é encerrada mesmo se falhar
+        api.post('/api/user/logout').catch(() => undefined);
+        limparSessao();
         setData({} as AuthState);
         navigate('/');
     }
@@ -85,7 +146,12 @@ export const AuthProvider: React.FC<AuthProviderProps> = ({ children }) => {
                 signed: !!data.user,
                 user: data.user,
                 loading,
+                desafio,
                 signIn,
+                confirmarCodigo,
+                cancelarDesafio,
+                concluirLoginSSO,
+                concluirTrocaSenha,
                 signOut,
             }}
         >
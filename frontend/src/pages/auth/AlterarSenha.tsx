@@ -0,0 +1,117 @@
+// src/pages/auth/AlterarSenha.tsx
+import React, { useState } from 'react';
+import {
+    Alert,
+    Box,
+    Button,
+    Container,
+    Paper,
+    TextField,
+    Typography,
+} from '@mui/material';
+import { useAuth } from '../../context/AuthContext';
+import api from '../../services/api';
+import Header from '../../components/Menu/Header';
+import Sidebar from '../../components/Menu/Sidebar';
+import PoliticaSenha from '../../components/Senha/PoliticaSenha';
+
+const AlterarSenha: React.FC = () => {
+    const [senhaAtual, setSenhaAtual] = useState('');
+    const [novaSenha, setNovaSenha] = useState('');
+    const [confirmacao, setConfirmacao] = useState('');
+    const [error, setError] = useState('');
+    const [enviando, setEnviando] = useState(false);
+    const { user, concluirTrocaSenha } = useAuth();
+
+    async function handleSubmit(event: React.FormEvent) {
+        event.preventDefault();
+        setError('');
+        if (novaSenha !== confirmacao) {
+            setError('As senhas não conferem');
+            return;
+        }
+
+        try {
+            setEnviando(true);
+            // A troca encerra as outras sessões e devolve uma nova para este navegador
+            const response = await api.post('/api/user/senha', { senhaAtual, novaSenha, cookie: true });
+            concluirTrocaSenha(response.data.payload);
+        } catch (err: any) {
+            setError(err.response?.data || 'Não foi possível alterar a senha');
+        } finally {
+            setEnviando(false);
+            setSenhaAtual('');
+        }
+    }
+
+    return (
+        <Box sx={{ display: 'flex' }}>
+            <Sidebar />
+            <Box
+                component="main"
+                sx={{
+                    backgroundColor: (theme) =>
+                        theme.palette.mode === 'light'
+                            ? theme.palette.grey[100]
+                            : theme.palette.grey[900],
+                    flexGrow: 1,
+                    height: '100vh',
+                    overflow: 'auto',
+                }}
+            >
+                <Header />
+                <Container maxWidth="sm" sx={{ mt: 4, mb: 4 }}>
+                    <Paper sx={{ p: 3 }}>
+                        <Typography variant="h6" gutterBottom>
+                            Alterar senha
+                        </Typography>
+                        {user?.trocaSenha && (
+                            <Alert severity="warning" sx={{ mb: 2 }}>
+                                Sua senha expirou. Cadastre uma nova senha para continuar.
+                            </Alert>
+                        )}
+                        {error && <Alert severity="error" sx={{ mb: 2 }}>{error}</Alert>}
+                        <Box component="form" onSubmit={handleSubmit}>
+                            <TextField
+                                margin="normal"
+                                required
+                                fullWidth
+                                label="Senha atual"
+                                type="password"
+                                autoComplete="current-password"
+                                value={senhaAtual}
+                                onChange={(e) => setSenhaAtual(e.target.value)}
+                            />
+                            <TextField
+                                margin="normal"
+                                required
+                                fullWidth
+                                label="Nova senha"
+                                type="password"
+                                autoComplete="new-password"
+                                value={novaSenha}
+                                onChange={(e) => setNovaSenha(e.target.value)}
+                            />
+                            <TextField
+                                margin="normal"
+                                required
+                                fullWidth
+                                label="Confirme a nova senha"
+                                type="password"
+                                autoComplete="new-password"
+                                value={confirmacao}
+                                onChange={(e) => setConfirmacao(e.target.value)}
+                            />
+                            <PoliticaSenha />
+                            <Button type="submit" variant="contained" sx={{ mt: 2 }} disabled={enviando}>
+                                {enviando ? 'Salvando...' : 'Alterar senha'}
+                            </Button>
+                        </Box>
+                    </Paper>
+                </Container>
+            </Box>
+        </Box>
+    );
+};
+
+export default AlterarSenha;
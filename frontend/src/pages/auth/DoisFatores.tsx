@@ -0,0 +1,189 @@
+// src/pages/auth/DoisFatores.tsx
+import React, { useEffect, useState } from 'react';
+import {
+    Alert,
+    Box,
+    Button,
+    Container,
+    Paper,
+    TextField,
+    Typography,
+} from '@mui/material';
+import { useAuth } from '../../context/AuthContext';
+import api from '../../services/api';
+import Header from '../../components/Menu/Header';
+import Sidebar from '../../components/Menu/Sidebar';
+import SessoesAtivas from '../../components/Sessoes/SessoesAtivas';
+
+interface StatusDoisFatores {
+    ativo: boolean;
+    obrigatorio: boolean;
+    codigosRecuperacao: number;
+}
+
+interface Cadastro {
+    segredo: string;
+    uri: string;
+    qrCode: string;
+}
+
+const DoisFatores: React.FC = () => {
+    const [status, setStatus] = useState<StatusDoisFatores | null>(null);
+    const [cadastro, setCadastro] = useState<Cadastro | null>(null);
+    const [codigo, setCodigo] = useState('');
+    const [codigosRecuperacao, setCodigosRecuperacao] = useState<string[]>([]);
+    const [error, setError] = useState('');
+    const { user, signOut } = useAuth();
+
+    const carregarStatus = async () => {
+        try {
+            const response = await api.get('/api/user/2fa');
+            setStatus(response.data);
+        } catch (err) {
+            setError('Erro ao consultar a autenticação em dois fatores');
+        }
+    };
+
+    useEffect(() => {
+        carregarStatus();
+    }, []);
+
+    const executar = async (operacao: () => Promise<void>) => {
+        setError('');
+        try {
+            await operacao();
+        } catch (err: any) {
+            setError(err.response?.data || 'Não foi possível concluir a operação');
+        } finally {
+            setCodigo('');
+        }
+    };
+
+    const iniciarCadastro = () => executar(async () => {
+        const response = await api.post('/api/user/2fa/cadastro');
+        setCadastro(response.data);
+    });
+
+    const ativar = () => executar(async () => {
+        const response = await api.post('/api/user/2fa/ativar', { codigo });
+        setCadastro(null);
+        setCodigosRecuperacao(response.data.codigosRecuperacao);
+    });
+
+    const gerarCodigos = () => executar(async () => {
+        const response = await api.post('/api/user/2fa/codigos-recuperacao', { codigo });
+        setCodigosRecuperacao(response.data.codigosRecuperacao);
+        await carregarStatus();
+    });
+
+    const desativar = () => executar(async () => {
+        await api.post('/api/user/2fa/desativar', { codigo });
+        await carregarStatus();
+    });
+
+    const campoCodigo = (
+        <TextField
+            margin="normal"
+            fullWidth
+            label="Código de verificação"
+            autoComplete="one-time-code"
+            value={codigo}
+            onChange={(e) => setCodigo(e.target.value)}
+        />
+    );
+
+    return (
+        <Box sx={{ display: 'flex' }}>
+            <Sidebar />
+            <Box
+                component="main"
+                sx={{
+                    backgroundColor: (theme) =>
+                        theme.palette.mode === 'light'
+                            ? theme.palette.grey[100]
+                            : theme.palette.grey[900],
+                    flexGrow: 1,
+                    height: '100vh',
+                    overflow: 'auto',
+                }}
+            >
+                <Header />
+                <Container maxWidth="sm" sx={{ mt: 4, mb: 4 }}>
+                    <Paper sx={{ p: 3 }}>
+                        <Typography variant="h6" gutterBottom>
+                            Autenticação em dois fatores
+                        </Typography>
+                        {error && <Alert severity="error" sx={{ mb: 2 }}>{error}</Alert>}
+
+                        {codigosRecuperacao.length > 0 ? (
+                            <>
+                                <Alert severity="warning" sx={{ mb: 2 }}>
+                                    Guarde estes códigos de recuperação em local seguro. Cada um vale
+                                    uma vez e eles não serão exibidos novamente.
+                                </Alert>
+                                <Box component="pre" sx={{ fontFamily: 'monospace', mb: 2 }}>
+                                    {codigosRecuperacao.join('\n')}
+                                </Box>
+                                {/* Ativar o segundo fator encerra as sessões abertas */}
+                                <Button variant="contained" onClick={status?.ativo ? () => setCodigosRecuperacao([]) : signOut}>
+                                    {status?.ativo ? 'Concluir' : 'Entrar novamente'}
+                                </Button>
+                            </>
+                        ) : cadastro ? (
+                            <>
+                                <Typography variant="body2" gutterBottom>
+                                    Leia o QR code com o aplicativo autenticador, ou digite o
+                                    segredo, e informe o código gerado.
+                                </Typography>
+                                <Box sx={{ display: 'flex', justifyContent: 'center', my: 2 }}>
+                                    <img src={cadastro.qrCode} alt="QR code de cadastro" width={220} height={220} />
+                                </Box>
+                                <TextField margin="normal" fullWidth label="Segredo" value={cadastro.segredo} InputProps={{ readOnly: true }} />
+                                {campoCodigo}
+                                <Button variant="contained" onClick={ativar} disabled={!codigo} sx={{ mt: 2 }}>
+                                    Ativar
+                                </Button>
+                            </>
+                        ) : status?.ativo ? (
+                            <>
+                                <Typography gutterBottom>
+                                    Ativa. Códigos de recuperação restantes: {status.codigosRecuperacao}
+                                </Typography>
+                                {campoCodigo}
+                                <Box sx={{ display: 'flex', gap: 2, mt: 2 }}>
+                                    <Button variant="contained" onClick={gerarCodigos} disabled={!codigo}>
+                                        Gerar novos códigos
+                                    </Button>
+                                    {!status.obrigatorio && (
+                                        <Button color="error" onClick={desativar} disabled={!codigo}>
+                                            Desativar
+                                        </Button>
+                                    )}
+                                </Box>
+                            </>
+                        ) : status ? (
+                            <>
+                                <Typography gutterBottom>
+                                    {status.obrigatorio
+                                        ? 'O cadastro do segundo fator é obrigatório para o seu perfil.'
+                                        : 'A autenticação em dois fatores está desativada.'}
+                                </Typography>
+                                <Button variant="contained" onClick={iniciarCadastro} sx={{ mt: 2 }}>
+                                    Cadastrar
+                                </Button>
+                            </>
+                        ) : null}
+                    </Paper>
+                    {/* Com o cadastro pendente, o token só alcança as rotas do segundo fator */}
+                    {!user?.cadastroDoisFatores && (
+                        <Paper sx={{ p: 3, mt: 3 }}>
+                            <SessoesAtivas />
+                        </Paper>
+                    )}
+                </Container>
+            </Box>
+        </Box>
+    );
+};
+
+export default DoisFatores;
@@ -1,4 +1,5 @@
-import React, { useState } from 'react';
+import React, { useEffect, useState } from 'react';
+import { useLocation } from 'react-router-dom';
 import {
     Box,
     Button,
@@ -10,21 +11,75 @@ import {
     CssBaseline,
     FormControlLabel,
     Checkbox,
+    Divider,
+    Link,
 } from '@mui/material';
 import { useAuth } from '../../context/AuthContext';
+import api, { getBaseUrl } from '../../services/api';
 
 const Login: React.FC = () => {
     const [email, setEmail] = useState('');
     const [password, setPassword] = useState('');
-    const [error, setError] = useState('');
-    const { signIn, loading } = useAuth();
+    const [codigo, setCodigo] = useState('');
+    const location = useLocation();
+    // Erros do login único chegam no estado da navegação
+    const [error, setError] = useState<string>(location.state?.erro || '');
+    const [nomeSSO, setNomeSSO] = useState<string | null>(null);
+    // Esqueci a senha: o formulário passa a pedir só o e-mail
+    const [esqueci, setEsqueci] = useState(false);
+    // Conta desativada: o formulário passa a pedir a reativação, com as mesmas credenciais
+    const [reativacao, setReativacao] = useState(false);
+    const [justificativa, setJustificativa] = useState('');
+    // Avisos de outras páginas, como a redefinição de senha, chegam no estado da navegação
+    const [aviso, setAviso] = useState<string>(() => {
+        if (location.state?.aviso) {
+            return location.state.aviso;
+        }
+        // Resultado do link de confirmação do cadastro, no fragmento da URL
+        const cadastro = new URLSearchParams(window.location.hash.slice(1)).get('cadastro');
+        if (cadastro === 'email-confirmado') {
+            return 'E-mail confirmado. O acesso será liberado após a aprovação de um administrador da sua lotação.';
+        }
+        if (cadastro === 'link-invalido') {
+            return 'Link de confirmação inválido ou expirado.';
+        }
+        return '';
+    });
+    const { signIn, confirmarCodigo, cancelarDesafio, desafio, loading } = useAuth();
+
+    useEffect(() => {
+        api.get('/api/user/provedores')
+            .then((response) => setNomeSSO(response.data.oidc ? response.data.nomeOIDC : null))
+            .catch(() => setNomeSSO(null));
+    }, []);
+
+    function entrarComSSO() {
+        // Navegação completa: o provedor devolve o navegador à API, que volta a /login/sso
+        window.location.href = `${getBaseUrl()}/api/user/oidc/login`;
+    }
 
     async function handleSubmit(event: React.FormEvent) {
         event.preventDefault();
+        setError('');
         try {
-            await signIn(email, password);
+            if (esqueci) {
+                await api.post('/api/user/senha/esqueci', { email });
+                setEsqueci(false);
+                setAviso('Se o e-mail estiver cadastrado, você receberá um link para redefinir a senha.');
+            } else if (reativacao) {
+                await api.post('/api/user/reativacao', { email, password, justificativa });
+                setReativacao(false);
+                setJustificativa('');
+                setAviso('Pedido de reativação enviado. Você receberá um e-mail com a decisão do administrador.');
+            } else if (desafio) {
+                await confirmarCodigo(codigo);
+            } else {
+                await signIn(email, password);
+            }
         } catch (err: any) {
-            setError(err.message || 'Ocorreu um erro ao fazer login');
+            setError(err.response?.data || err.message || 'Ocorreu um erro ao fazer login');
+        } finally {
+            setCodigo('');
         }
     }
 
@@ -54,34 +109,97 @@ const Login: React.FC = () => {
                     </Typography>
                     <Box component="form" onSubmit={handleSubmit} sx={{ mt: 3 }}>
                         {error && <Alert severity="error">{error}</Alert>}
-                        <TextField
-                            margin="normal"
-                            required
-                            fullWidth
-                            id="email"
-                            label="Email"
-                            name="email"
-                            autoComplete="email"
-                            autoFocus
-                            value={email}
-                            onChange={(e) => setEmail(e.target.value)}
-                        />
-                        <TextField
-                            margin="normal"
-                            required
-                            fullWidth
-                            name="password"
-                            label="Senha"
-                            type="password"
-                            id="password"
-                            autoComplete="current-password"
-                            value={password}
-                            onChange={(e) => setPassword(e.target.value)}
-                        />
-                        <FormControlLabel
-                            control={<Checkbox value="remember" color="primary" />}
-                            label="Lembrar-me"
-                        />
+                        {aviso && !error && <Alert severity="info">{aviso}</Alert>}
+                        {desafio ? (
+                            <>
+                                <Typography variant="body2" sx={{ mt: 2 }}>
+                                    Informe o código do aplicativo autenticador ou um código de recuperação.
+                                </Typography>
+                                <TextField
+                                    margin="normal"
+                                    required
+                                    fullWidth
+                                    id="codigo"
+                                    label="Código de verificação"
+                                    name="codigo"
+                                    autoComplete="one-time-code"
+                                    autoFocus
+                                    value={codigo}
+                                    onChange={(e) => setCodigo(e.target.value)}
+                                />
+                            </>
+                        ) : esqueci ? (
+                            <>
+                                <Typography variant="body2" sx={{ mt: 2 }}>
+                                    Informe o e-mail da conta para receber o link de redefinição da senha.
+                                </Typography>
+                                <TextField
+                                    margin="normal"
+                                    required
+                                    fullWidth
+                                    id="email"
+                                    label="Email"
+                                    name="email"
+                                    type="email"
+                                    autoComplete="email"
+                                    autoFocus
+                                    value={email}
+                                    onChange={(e) => setEmail(e.target.value)}
+                                />
+                            </>
+                        ) : (
+                            <>
+                                {reativacao && (
+                                    <Typography variant="body2" sx={{ mt: 2 }}>
+                                        Confirme as credenciais da conta desativada e explique por que ela deve ser reativada.
+                                    </Typography>
+                                )}
+                                <TextField
+                                    margin="normal"
+                                    required
+                                    fullWidth
+                                    id="email"
+                                    label="Email ou usuário"
+                                    name="email"
+                                    autoComplete="email"
+                                    autoFocus
+                                    value={email}
+                                    onChange={(e) => setEmail(e.target.value)}
+                                />
+                                <TextField
+                                    margin="normal"
+                                    required
+                                    fullWidth
+                                    name="password"
+                                    label="Senha"
+                                    type="password"
+                                    id="password"
+                                    autoComplete="current-password"
+                                    value={password}
+                                    onChange={(e) => setPassword(e.target.value)}
+                                />
+                                {reativacao ? (
+                                    <TextField
+                                        margin="normal"
+                                        required
+                                        fullWidth
+                                        multiline
+                                        minRows={3}
+                                        id="justificativa"
+                                        label="Justificativa"
+                                        name="justificativa"
+                                        inputProps={{ maxLength: 500 }}
+                                        value={justificativa}
+                                        onChange={(e) => setJustificativa(e.target.value)}
+                                    />
+                                ) : (
+                                    <FormControlLabel
+                                        control={<Checkbox value="remember" color="primary" />}
+                                        label="Lembrar-me"
+                                    />
+                                )}
+                            </>
+                        )}
                         <Button
                             type="submit"
                             fullWidth
@@ -89,8 +207,42 @@ const Login: React.FC = () => {
                             sx={{ mt: 3, mb: 2 }}
                             disabled={loading}
                         >
-                            {loading ? 'Entrando...' : 'Entrar'}
+                            {loading ? 'Entrando...' : desafio ? 'Confirmar' : esqueci ? 'Enviar link' : reativacao ? 'Solicitar reativação' : 'Entrar'}
                         </Button>
+                        {(desafio || esqueci || reativacao) && (
+                            <Button
+                                fullWidth
+                                onClick={() => (esqueci ? setEsqueci(false) : reativacao ? setReativacao(false) : cancelarDesafio())}
+                            >
+                                Voltar
+                            </Button>
+                        )}
+                        {!desafio && !esqueci && !reativacao && (
+                            <Box sx={{ display: 'flex', justifyContent: 'space-between', mb: 2 }}>
+                                <Link
+                                    component="button"
+                                    type="button"
+                                    variant="body2"
+                                    onClick={() => {
+                                        setError('');
+                                        setReativacao(true);
+                                    }}
+                                >
+                                    Conta desativada?
+                                </Link>
+                                <Link component="button" type="button" variant="body2" onClick={() => setEsqueci(true)}>
+                                    Esqueci minha senha
+                                </Link>
+                            </Box>
+                        )}
+                        {!desafio && !esqueci && !reativacao && nomeSSO && (
+                            <>
+                                <Divider sx={{ mb: 2 }}>ou</Divider>
+                                <Button fullWidth variant="outlined" onClick={entrarComSSO} disabled={loading}>
+                                    Entrar com {nomeSSO}
+                                </Button>
+                            </>
+                        )}
                     </Box>
                 </Paper>
             </Box>
@@ -0,0 +1,42 @@
+import React, { useEffect, useRef } from 'react';
+import { useNavigate } from 'react-router-dom';
+import { Box, CircularProgress, Typography } from '@mui/material';
+import { useAuth } from '../../context/AuthContext';
+
+// Página de retorno do login único. A API informa o resultado no fragmento da
+// URL: nada (sessão aberta em cookies), #desafio=... ou #erro=...
+const LoginSSO: React.FC = () => {
+    const { concluirLoginSSO } = useAuth();
+    const navigate = useNavigate();
+    const processado = useRef(false);
+
+    useEffect(() => {
+        if (processado.current) {
+            return;
+        }
+        processado.current = true;
+
+        const parametros = new URLSearchParams(window.location.hash.slice(1));
+        // O desafio não deve ficar no histórico do navegador
+        window.history.replaceState(null, '', window.location.pathname);
+
+        const erro = parametros.get('erro');
+        if (erro) {
+            navigate('/', { replace: true, state: { erro } });
+            return;
+        }
+
+        concluirLoginSSO(parametros.get('desafio')).catch(() => {
+            navigate('/', { replace: true, state: { erro: 'Não foi possível concluir o login' } });
+        });
+    }, [concluirLoginSSO, navigate]);
+
+    return (
+        <Box sx={{ mt: 8, display: 'flex', flexDirection: 'column', alignItems: 'center', gap: 2 }}>
+            <CircularProgress />
+            <Typography>Concluindo o login...</Typography>
+        </Box>
+    );
+};
+
+export default LoginSSO;
@@ -0,0 +1,96 @@
+// src/pages/auth/RedefinirSenha.tsx
+import React, { useState } from 'react';
+import { useNavigate } from 'react-router-dom';
+import {
+    Alert,
+    Box,
+    Button,
+    Container,
+    CssBaseline,
+    Paper,
+    TextField,
+    Typography,
+} from '@mui/material';
+import api from '../../services/api';
+import PoliticaSenha from '../../components/Senha/PoliticaSenha';
+
+// Página do link de redefinição enviado por e-mail; o token vem no fragmento da URL
+const RedefinirSenha: React.FC = () => {
+    const [token] = useState(() => new URLSearchParams(window.location.hash.slice(1)).get('token') || '');
+    const [novaSenha, setNovaSenha] = useState('');
+    const [confirmacao, setConfirmacao] = useState('');
+    const [error, setError] = useState(token ? '' : 'Link de redefinição inválido.');
+    const [enviando, setEnviando] = useState(false);
+    const navigate = useNavigate();
+
+    async function handleSubmit(event: React.FormEvent) {
+        event.preventDefault();
+        setError('');
+        if (novaSenha !== confirmacao) {
+            setError('As senhas não conferem');
+            return;
+        }
+
+        try {
+            setEnviando(true);
+            await api.post('/api/user/senha/redefinir', { token, novaSenha });
+            navigate('/', { replace: true, state: { aviso: 'Senha redefinida. Entre com a nova senha.' } });
+        } catch (err: any) {
+            setError(err.response?.data || 'Não foi possível redefinir a senha');
+        } finally {
+            setEnviando(false);
+        }
+    }
+
+    return (
+        <Container component="main" maxWidth="xs">
+            <CssBaseline />
+            <Box sx={{ marginTop: 8, display: 'flex', flexDirection: 'column', alignItems: 'center' }}>
+                <Paper elevation={3} sx={{ padding: 4, width: '100%' }}>
+                    <Typography component="h1" variant="h5">
+                        Redefinir senha
+                    </Typography>
+                    <Box component="form" onSubmit={handleSubmit} sx={{ mt: 3 }}>
+                        {error && <Alert severity="error">{error}</Alert>}
+                        <TextField
+                            margin="normal"
+                            required
+                            fullWidth
+                            label="Nova senha"
+                            type="password"
+                            autoComplete="new-password"
+                            autoFocus
+                            value={novaSenha}
+                            onChange={(e) => setNovaSenha(e.target.value)}
+                        />
+                        <TextField
+                            margin="normal"
+                            required
+                            fullWidth
+                            label="Confirme a nova senha"
+                            type="password"
+                            autoComplete="new-password"
+                            value={confirmacao}
+                            onChange={(e) => setConfirmacao(e.target.value)}
+                        />
+                        <PoliticaSenha />
+                        <Button
+                            type="submit"
+                            fullWidth
+                            variant="contained"
+                            sx={{ mt: 3, mb: 2 }}
+                            disabled={enviando || !token}
+                        >
+                            {enviando ? 'Salvando...' : 'Redefinir'}
+                        </Button>
+                        <Button fullWidth onClick={() => navigate('/')}>
+                            Voltar ao login
+                        </Button>
+                    </Box>
+                </Paper>
+            </Box>
+        </Container>
+    );
+};
+
+export default RedefinirSenha;
@@ -19,6 +19,7 @@ import { useAuth } from '../../context/AuthContext';
 import api from '../../services/api';
 import Header from '../../components/Menu/Header';
 import Sidebar from '../../components/Menu/Sidebar';
+import ComparacaoDialog from '../../components/Comparacao/ComparacaoDialog';
 
 interface ChavePix {
     chave: string;
@@ -35,7 +36,10 @@ interface ChavePix {
 const ListaPix: React.FC = () => {
     const [loading, setLoading] = useState(false);
     const [requisicoes, setRequisicoes] = useState<any[]>([]);
+    const [comparando, setComparando] = useState<number | null>(null);
     const { user } = useAuth();
+    // O auditor só consulta o histórico
+    const podeConsultar = user?.admin || user?.perfil !== 'auditor';
     const location = useLocation();
     const navigate = useNavigate();
 
@@ -48,11 +52,8 @@ const ListaPix: React.FC = () => {
         const fetchRequisicoes = async () => {
             setLoading(true);
             try {
-                const response = await api.get('/api/bacen/pix/requisicoespix', {
-                    params: {
-                        cpfCnpj: user?.cpf,
-                    },
-                });
+                // A API devolve as requisições visíveis ao perfil do usuário
+                const response = await api.get('/api/bacen/pix/requisicoespix');
                 setRequisicoes(response.data);
             } catch (error) {
                 console.error('Erro ao buscar requisições:', error);
@@ -126,13 +127,15 @@ const ListaPix: React.FC = () => {
                                 <Typography>Nenhum resultado encontrado</Typography>
                             )}
 
-                            <Button
-                                variant="contained"
-                                onClick={handleNovaPesquisa}
-                                sx={{ mt: 2 }}
-                            >
-                                Nova Consulta
-                            </Button>
+                            {podeConsultar && (
+                                <Button
+                                    variant="contained"
+                                    onClick={handleNovaPesquisa}
+                                    sx={{ mt: 2 }}
+                                >
+                                    Nova Consulta
+                                </Button>
+                            )}
                         </Paper>
                     )}
 
@@ -155,6 +158,7 @@ const ListaPix: React.FC = () => {
                                             <TableCell>Chave/CPF/CNPJ</TableCell>
                                             <TableCell>Motivo</TableCell>
                                             <TableCell>Resultado</TableCell>
+                                            <TableCell>Alterações</TableCell>
                                         </TableRow>
                                     </TableHead>
                                     <TableBody>
@@ -165,11 +169,16 @@ const ListaPix: React.FC = () => {
                                                 <TableCell>{req.chaveBusca}</TableCell>
                                                 <TableCell>{req.motivoBusca}</TableCell>
                                                 <TableCell>{req.resultado}</TableCell>
+                                                <TableCell>
+                                                    <Button size="small" onClick={() => setComparando(req.id)}>
+                                                        Comparar
+                                                    </Button>
+                                                </TableCell>
                                             </TableRow>
                                         ))}
                                         {requisicoes.length === 0 && (
                                             <TableRow>
-                                                <TableCell colSpan={5} align="center">
+                                                <TableCell colSpan={6} align="center">
                                                     Nenhuma consulta realizada
                                                 </TableCell>
                                             </TableRow>
@@ -179,15 +188,22 @@ const ListaPix: React.FC = () => {
                             </TableContainer>
                         )}
 
-                        <Button
-                            variant="contained"
-                            onClick={handleNovaPesquisa}
-                            sx={{ mt: 2 }}
-                        >
-                            Nova Consulta
-                        </Button>
+                        {podeConsultar && (
+                            <Button
+                                variant="contained"
+                                onClick={handleNovaPesquisa}
+                                sx={{ mt: 2 }}
+                            >
+                                Nova Consulta
+                            </Button>
+                        )}
                     </Paper>
                 </Container>
+                <ComparacaoDialog
+                    origem="pix"
+                    idRequisicao={comparando}
+                    onClose={() => setComparando(null)}
+                />
             </Box>
         </Box>
     );
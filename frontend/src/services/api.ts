@@ -1,7 +1,7 @@
-import axios from 'axios';
+import axios, { AxiosError, AxiosRequestConfig } from 'axios';
 
 // Determina a URL base com base no ambiente
-const getBaseUrl = () => {
+export const getBaseUrl = () => {
     // Verifica se está em ambiente de produção (Docker/Nginx)
     if (window.location.hostname !== 'localhost' || window.location.port === '80') {
         return ''; // Remova o '/api' daqui já que as rotas já incluem isso
@@ -10,18 +10,33 @@ const getBaseUrl = () => {
     return 'http://localhost:8080';
 };
 
+export const CHAVE_USUARIO = '@ConsultaPix:user';
+
+// Os tokens ficam em cookies HttpOnly definidos pela API; o JavaScript só lê o
+// cookie de CSRF, que é devolvido no cabeçalho das requisições que alteram dados
+const COOKIE_CSRF = 'consultapix_csrf';
+const CABECALHO_CSRF = 'X-CSRF-Token';
+
+const lerCookie = (nome: string) => {
+    const cookie = document.cookie.split('; ').find((c) => c.startsWith(`${nome}=`));
+    return cookie ? decodeURIComponent(cookie.substring(nome.length + 1)) : undefined;
+};
+
+const cabecalhosCSRF = (): Record<string, string> => {
+    const csrf = lerCookie(COOKIE_CSRF);
+    return csrf ? { [CABECALHO_CSRF]: csrf } : {};
+};
+
 const api = axios.create({
     baseURL: getBaseUrl(),
+    withCredentials: true,
 });
 
 api.interceptors.request.use(
     (config) => {
-        const token = localStorage.getItem('@ConsultaPix:token');
-        if (token) {
-            config.params = {
-                ...(config.params || {}),
-                token
-            };
+        const metodo = (config.method || 'get').toLowerCase();
+        if (!['get', 'head', 'options'].includes(metodo)) {
+            config.headers = { ...(config.headers || {}), ...cabecalhosCSRF() } as any;
         }
         return config;
     },
@@ -30,4 +45,47 @@ api.interceptors.request.use(
     }
 );
 
-export default api;
\ No newline at end of file
+export const limparSessao = () => {
+    localStorage.removeItem(CHAVE_USUARIO);
+};
+
+// Uma única renovação por vez: o refresh token só pode ser usado uma vez, e
+// reapresentá-lo encerra a sessão
+let renovacaoEmAndamento: Promise<void> | null = null;
+
+export const renovarSessao = (): Promise<void> => {
+    if (!renovacaoEmAndamento) {
+        renovacaoEmAndamento = axios
+            .post(`${getBaseUrl()}/api/user/refresh`, {}, { withCredentials: true, headers: cabecalhosCSRF() })
+            .then((response) => {
+                localStorage.setItem(CHAVE_USUARIO, JSON.stringify(response.data.payload));
+            })
+            .finally(() => {
+                renovacaoEmAndamento = null;
+            });
+    }
+    return renovacaoEmAndamento;
+};
+
+// Com o token de acesso expirado, renova a sessão e repete a requisição uma vez
+api.interceptors.response.use(
+    (response) => response,
+    async (error: AxiosError) => {
+        const config = error.config as (AxiosRequestConfig & { _renovado?: boolean }) | undefined;
+        if (error.response?.status !== 401 || !config || config._renovado || !localStorage.getItem(CHAVE_USUARIO)) {
+            return Promise.reject(error);
+        }
+
+        config._renovado = true;
+        try {
+            await renovarSessao();
+        } catch (erroRenovacao) {
+            limparSessao();
+            window.location.href = '/';
+            return Promise.reject(erroRenovacao);
+        }
+        return api(config);
+    }
+);
+
+export default api;
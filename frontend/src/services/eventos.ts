@@ -0,0 +1,51 @@
+import { CHAVE_USUARIO, getBaseUrl, renovarSessao } from './api';
+
+export type TipoEvento = 'relacionamento_ccs' | 'notificacao' | 'monitoramento' | 'sincronizar';
+
+export type ManipuladoresEventos = Partial<Record<TipoEvento, (dados: any) => void>>;
+
+// Intervalo antes de reabrir o stream depois de uma falha
+const ESPERA_RECONEXAO_MS = 5000;
+
+// Abre o stream de eventos da API, autenticado pelo cookie de sessão. O
+// servidor encerra o stream quando o token expira; antes de reabrir, a sessão
+// é renovada. Retorna a função que fecha o stream.
+export const assinarEventos = (manipuladores: ManipuladoresEventos): (() => void) => {
+    let fonte: EventSource | null = null;
+    let reconexao: ReturnType<typeof setTimeout> | undefined;
+    let encerrado = false;
+
+    const abrir = () => {
+        if (encerrado || !localStorage.getItem(CHAVE_USUARIO)) {
+            return;
+        }
+
+        fonte = new EventSource(`${getBaseUrl()}/api/eventos`, { withCredentials: true });
+        (Object.keys(manipuladores) as TipoEvento[]).forEach((tipo) => {
+            fonte?.addEventListener(tipo, (evento) => {
+                manipuladores[tipo]?.(JSON.parse((evento as MessageEvent).data));
+            });
+        });
+        fonte.onerror = () => {
+            // O EventSource não informa o status; renovar a sessão cobre o
+            // caso do token expirado antes de reconectar
+            fonte?.close();
+            reconexao = setTimeout(() => {
+                renovarSessao()
+                    .catch(() => undefined)
+                    .finally(() => {
+                        abrir();
+                        manipuladores.sincronizar?.({});
+                    });
+            }, ESPERA_RECONEXAO_MS);
+        };
+    };
+
+    abrir();
+
+    return () => {
+        encerrado = true;
+        clearTimeout(reconexao);
+        fonte?.close();
+    };
+};